@@ -15,10 +15,12 @@
 package v1alpha1
 
 import (
+	"crypto/sha256"
 	"fmt"
 	"hash/adler32"
 	"strconv"
 
+	networkingv1 "k8s.io/api/networking/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/json"
 )
@@ -36,6 +38,49 @@ type ClusterContext struct {
 	AdditionalConfigs map[string]FlexString `json:"additionalConfigs,omitempty" protobuf:"bytes,2,rep,name=data"`
 }
 
+// GetClusterName returns the name of the cluster, empty when it is not set
+func (c ClusterContext) GetClusterName() string {
+	return c.ClusterName
+}
+
+// GetRegion returns the region of the cluster, empty when it is not set
+func (c ClusterContext) GetRegion() string {
+	return c.ClusterRegion
+}
+
+// GetConfig returns the additional config named name
+func (c ClusterContext) GetConfig(name string) (string, bool) {
+	v, ok := c.AdditionalConfigs[name]
+	return string(v), ok
+}
+
+// IsEmpty returns true when the context has no values
+func (c ClusterContext) IsEmpty() bool {
+	return c.ClusterName == "" && c.ClusterRegion == "" && len(c.AdditionalConfigs) == 0
+}
+
+// Merge returns the context with the values set in override on top, additional configs are merged. The context
+// is not modified.
+func (c ClusterContext) Merge(override ClusterContext) ClusterContext {
+	merged := ClusterContext{ClusterName: c.ClusterName, ClusterRegion: c.ClusterRegion}
+	if override.ClusterName != "" {
+		merged.ClusterName = override.ClusterName
+	}
+	if override.ClusterRegion != "" {
+		merged.ClusterRegion = override.ClusterRegion
+	}
+	if len(c.AdditionalConfigs)+len(override.AdditionalConfigs) > 0 {
+		merged.AdditionalConfigs = make(map[string]FlexString, len(c.AdditionalConfigs)+len(override.AdditionalConfigs))
+		for k, v := range c.AdditionalConfigs {
+			merged.AdditionalConfigs[k] = v
+		}
+		for k, v := range override.AdditionalConfigs {
+			merged.AdditionalConfigs[k] = v
+		}
+	}
+	return merged
+}
+
 // AddonParams are the parameters which will be available to the template workflows
 type AddonParams struct {
 	// +kubebuilder:validation:MinLength=1
@@ -46,6 +91,22 @@ type AddonParams struct {
 	// Data values that will be parameters injected into workflows
 	// +optional
 	Data map[string]FlexString `json:"data,omitempty"`
+	// Sops references a SOPS encrypted document of additional data parameters, decrypted values take precedence over data
+	// +optional
+	Sops *SopsParams `json:"sops,omitempty"`
+}
+
+// ParamsLabel marks the ConfigMaps holding SOPS params documents, only labelled ConfigMaps are read and watched
+const ParamsLabel = "addonmgr.keikoproj.io/params"
+
+// SopsParams references a SOPS encrypted YAML or JSON document of parameters stored in a ConfigMap in the addon
+// namespace, the ConfigMap must be labelled with ParamsLabel
+type SopsParams struct {
+	// ConfigMap holding the encrypted document
+	ConfigMap string `json:"configMap"`
+	// Key of the document in the ConfigMap, defaults to params.yaml
+	// +optional
+	Key string `json:"key,omitempty"`
 }
 
 // FlexString is a ptr to string type that is used to provide additional configs
@@ -167,7 +228,8 @@ const (
 	Unknown DeploymentPhase = "Unknown"
 )
 
-// LifecycleStep is a string representation of the lifecycle steps available in Addon spec: prereqs, install, delete, validate
+// LifecycleStep is a string representation of the lifecycle steps available in Addon spec: prereqs, install, upgrade,
+// delete, validate
 type LifecycleStep string
 
 const (
@@ -179,6 +241,8 @@ const (
 	Delete LifecycleStep = "delete"
 	// Validate constant
 	Validate LifecycleStep = "validate"
+	// Upgrade constant
+	Upgrade LifecycleStep = "upgrade"
 )
 
 // AddonOverridesSpec represents a template of the resources that can be deployed or patched alongside the main deployment
@@ -191,11 +255,67 @@ type AddonOverridesSpec struct {
 	Template map[string]string `json:"template,omitempty" protobuf:"bytes,2,rep,name=template"`
 }
 
+// AddonNetworkPolicySpec are the allow rules of the baseline network policies in the addon target namespace
+type AddonNetworkPolicySpec struct {
+	// Ingress rules allowed to the pods of the target namespace
+	// +optional
+	Ingress []networkingv1.NetworkPolicyIngressRule `json:"ingress,omitempty"`
+	// Egress rules allowed from the pods of the target namespace, DNS is always allowed
+	// +optional
+	Egress []networkingv1.NetworkPolicyEgressRule `json:"egress,omitempty"`
+}
+
 // SecretCmdSpec is a secret list and/or generator for secrets using the available commands: random, cert.
 type SecretCmdSpec struct {
 	Name string   `json:"name"`
 	Cmd  CmdType  `json:"cmd,omitempty"`
 	Args []string `json:"args,omitempty" protobuf:"bytes,4,rep,name=args"`
+	// From fetches the secret data from an external store and creates the secret before the install workflow runs
+	// +optional
+	From *SecretSource `json:"from,omitempty"`
+}
+
+// SecretSource is an external secret store, exactly one store must be set
+type SecretSource struct {
+	// Vault reads the secret from a HashiCorp Vault KV secret engine
+	// +optional
+	Vault *VaultSecretSource `json:"vault,omitempty"`
+	// AWSSecretsManager reads the secret from AWS Secrets Manager
+	// +optional
+	AWSSecretsManager *AWSSecretsManagerSource `json:"awsSecretsManager,omitempty"`
+	// SealedSecret creates a Bitnami SealedSecret that the sealed-secrets controller unseals into the secret
+	// +optional
+	SealedSecret *SealedSecretSource `json:"sealedSecret,omitempty"`
+}
+
+// SealedSecretSource is the encrypted data of a SealedSecret, sealed for the secret name and target namespace
+type SealedSecretSource struct {
+	// EncryptedData are the kubeseal encrypted values keyed by secret key
+	EncryptedData map[string]string `json:"encryptedData"`
+}
+
+// VaultSecretSource references a Vault KV secret
+type VaultSecretSource struct {
+	// Path of the secret including the mount, e.g. secret/data/myapp for KV version 2
+	Path string `json:"path"`
+	// Keys to copy into the secret, all keys are copied when empty
+	// +optional
+	Keys []string `json:"keys,omitempty"`
+}
+
+// AWSSecretsManagerSource references an AWS Secrets Manager secret
+type AWSSecretsManagerSource struct {
+	// SecretID is the name or ARN of the secret
+	SecretID string `json:"secretId"`
+	// VersionStage of the secret, defaults to AWSCURRENT
+	// +optional
+	VersionStage string `json:"versionStage,omitempty"`
+	// Key the secret string is stored under when it is not a JSON object, defaults to value
+	// +optional
+	Key string `json:"key,omitempty"`
+	// Keys to copy into the secret when the secret string is a JSON object, all keys are copied when empty
+	// +optional
+	Keys []string `json:"keys,omitempty"`
 }
 
 // WorkflowType allows user to specify workflow templates with optional namePrefix, workflowRole or role.
@@ -212,6 +332,23 @@ type WorkflowType struct {
 	WorkflowRole string `json:"workflowRole,omitempty"`
 	// Template is used to provide the workflow spec
 	Template string `json:"template"`
+	// TemplateFrom references the ConfigMap the manager offloaded the template to when the addon grew close to the
+	// object size limit, it is set in place of Template and resolved before the workflow is submitted
+	// +optional
+	TemplateFrom *TemplateReference `json:"templateFrom,omitempty"`
+	// Skip does not run the workflow of the step, e.g. the validate workflow in dev clusters or the prereqs workflow
+	// when the namespace is pre-provisioned. Skipped steps succeed and are listed in the status.
+	// +optional
+	Skip bool `json:"skip,omitempty"`
+}
+
+// TemplateReference references a workflow template stored in a ConfigMap in the addon namespace
+type TemplateReference struct {
+	// ConfigMap holding the template
+	ConfigMap string `json:"configMap"`
+	// Key of the template in the ConfigMap, defaults to template
+	// +optional
+	Key string `json:"key,omitempty"`
 }
 
 // LifecycleWorkflowSpec is where all of the lifecycle workflow templates will be specified under
@@ -220,18 +357,65 @@ type LifecycleWorkflowSpec struct {
 	Install  WorkflowType `json:"install,omitempty"`
 	Delete   WorkflowType `json:"delete,omitempty"`
 	Validate WorkflowType `json:"validate,omitempty"`
+	// Upgrade runs in place of the install workflow when the spec of an installed addon changes, e.g. to migrate
+	// schemas or drain nodes before applying the new version. Install is still run for new addons and remains the
+	// declaration of the addon objects. Blue-green installs always run the install workflow in the candidate slot.
+	// +optional
+	Upgrade WorkflowType `json:"upgrade,omitempty"`
+	// RollbackOnFailure installs the spec of the last revision installed successfully again when the install or
+	// upgrade workflow of the spec fails. The spec is not changed, the addon stays Failed until a new spec installs.
+	// +optional
+	RollbackOnFailure bool `json:"rollbackOnFailure,omitempty"`
+	// ValidateGate requires the validate workflow to succeed after install before the addon is Succeeded,
+	// defaults to the validate gate setting of the manager. Blue-green installs always validate.
+	// +optional
+	ValidateGate *bool `json:"validateGate,omitempty"`
+	// ArchiveLogs copies the logs of the lifecycle workflow pods to the artifact repository so they outlive the
+	// workflows deleted after their TTL, defaults to the log archive setting of the manager.
+	// +optional
+	ArchiveLogs *bool `json:"archiveLogs,omitempty"`
 }
 
 // PackageSpec is the package level details needed by addon
 type PackageSpec struct {
-	PkgChannel     string            `json:"pkgChannel,omitempty"`
-	PkgName        string            `json:"pkgName"`
-	PkgVersion     string            `json:"pkgVersion"`
-	PkgType        PackageType       `json:"pkgType"`
+	PkgChannel string `json:"pkgChannel,omitempty"`
+	PkgName    string `json:"pkgName"`
+	// PkgVersion may be omitted when the addon is installed from a catalog, it then defaults to the channel or
+	// the latest version
+	// +optional
+	PkgVersion string `json:"pkgVersion"`
+	// +optional
+	PkgType PackageType `json:"pkgType"`
+	// +optional
 	PkgDescription string            `json:"pkgDescription"`
 	PkgDeps        map[string]string `json:"pkgDeps,omitempty"`
 }
 
+// AddonDependency references the addons of a dependency by name or labels, in the namespace of the addon or another
+// one. Addons in other namespaces must be shared with the namespace of the dependent through SharedWithAnnotation.
+type AddonDependency struct {
+	// Namespace of the required addons, defaults to the addon namespace
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+	// Name of the required addon
+	// +optional
+	Name string `json:"name,omitempty"`
+	// Selector matches the required addons by labels when Name is empty, one of them must be installed
+	// +optional
+	Selector *metav1.LabelSelector `json:"selector,omitempty"`
+	// Version is the semver constraint the package version of the required addon must satisfy
+	// +optional
+	Version string `json:"version,omitempty"`
+}
+
+// SharedWithAnnotation lists the namespaces, comma separated or *, whose addons may depend on the annotated addon
+// through AddonDeps
+const SharedWithAnnotation = "addonmgr.keikoproj.io/shared-with"
+
+// ResumeAnnotation closes the circuit of an addon whose failure budget is exhausted, the failed workflow is submitted
+// again and the annotation is removed
+const ResumeAnnotation = "addonmgr.keikoproj.io/resume"
+
 // AddonSpec defines the desired state of Addon
 type AddonSpec struct {
 	PackageSpec `json:",inline"`
@@ -248,15 +432,489 @@ type AddonSpec struct {
 	// Secrets is a list of secret names expected to exist in the target namespace
 	// +optional
 	Secrets []SecretCmdSpec `json:"secrets,omitempty"`
+	// NetworkPolicy declares the traffic allowed in the target namespace on top of a default deny,
+	// policies are only created when the manager has network policy generation enabled
+	// +optional
+	NetworkPolicy *AddonNetworkPolicySpec `json:"networkPolicy,omitempty"`
+	// ClusterScoped allows lifecycle workflows to run as a service account with cluster-admin permissions
+	// when the manager enforces namespace scoped workflows
+	// +optional
+	ClusterScoped bool `json:"clusterScoped,omitempty"`
+	// Target is the cluster the addon is installed in, defaults to the cluster the manager runs in
+	// +optional
+	Target *AddonTarget `json:"target,omitempty"`
+	// Source locates the addon manifests when the manager renders GitOps objects instead of submitting workflows,
+	// and the chart of helm packages without an install workflow
+	// +optional
+	Source *AddonSource `json:"source,omitempty"`
+	// KubeVersion is the semver range of Kubernetes versions the package supports, e.g. >=1.18 <1.22,
+	// pre-release and build suffixes of cluster versions are ignored
+	// +optional
+	KubeVersion string `json:"kubeVersion,omitempty"`
+	// Catalog is the name of the AddonCatalog in the addon namespace the package is installed from, the type,
+	// dependencies and lifecycle of the package version come from the catalog template
+	// +optional
+	Catalog string `json:"catalog,omitempty"`
+	// TemplateRef is the name of the AddonTemplate in the addon namespace the lifecycle workflows and default params
+	// come from, workflows and params set on the addon override the template
+	// +optional
+	TemplateRef string `json:"templateRef,omitempty"`
+	// AutoUpdate upgrades an addon installed from a catalog when the catalog publishes newer versions,
+	// it is ignored while the addon follows a channel
+	// +optional
+	AutoUpdate *AutoUpdatePolicy `json:"autoUpdate,omitempty"`
+	// InstallDependencies creates the addons of dependencies that are not installed from the catalog
+	// +kubebuilder:validation:Enum=Never;IfNotPresent
+	// +optional
+	InstallDependencies InstallDependenciesPolicy `json:"installDependencies,omitempty"`
+	// DeletionPolicy controls the deletion of the addon while installed addons depend on its package
+	// +kubebuilder:validation:Enum=Block;Cascade
+	// +optional
+	DeletionPolicy DeletionPolicy `json:"deletionPolicy,omitempty"`
+	// Strategy is how a new version of the addon replaces the installed one
+	// +optional
+	Strategy *InstallStrategy `json:"strategy,omitempty"`
+	// MaintenanceWindow defers upgrades of the installed addon, e.g. from spec or catalog changes, until the window
+	// is open. It is not part of the checksum, changing the window does not upgrade the addon.
+	// +optional
+	MaintenanceWindow *MaintenanceWindow `json:"maintenanceWindow,omitempty"`
+
+	// AddonDeps are addons the addon depends on by name or labels, e.g. shared infrastructure addons installed in
+	// other namespaces, they are waited on like package dependencies
+	// +optional
+	AddonDeps []AddonDependency `json:"addonDeps,omitempty"`
 
 	// +optional
 	Lifecycle LifecycleWorkflowSpec `json:"lifecycle,omitempty"`
 }
 
+// GetClusterName returns the name of the cluster of the addon context
+func (s *AddonSpec) GetClusterName() string {
+	return s.Params.Context.GetClusterName()
+}
+
+// GetRegion returns the region of the cluster of the addon context
+func (s *AddonSpec) GetRegion() string {
+	return s.Params.Context.GetRegion()
+}
+
+// ResolvedParams returns the parameters of the addon merged into one map: the namespace, the cluster name and region,
+// the additional configs of the context and the data parameters. Every key is set, data parameters take precedence
+// over additional configs which take precedence over the namespace and cluster fields of the same name.
+func (s *AddonSpec) ResolvedParams() map[string]string {
+	ctx := s.Params.Context
+	params := make(map[string]string, 3+len(ctx.AdditionalConfigs)+len(s.Params.Data))
+	params["namespace"] = s.Params.Namespace
+	params["clusterName"] = ctx.GetClusterName()
+	params["clusterRegion"] = ctx.GetRegion()
+	for k, v := range ctx.AdditionalConfigs {
+		params[k] = string(v)
+	}
+	for k, v := range s.Params.Data {
+		params[k] = string(v)
+	}
+	return params
+}
+
+// MaintenanceWindow is a recurring window upgrades of an addon are started in
+type MaintenanceWindow struct {
+	// Schedules are the cron expressions the window opens at: minute hour day-of-month month day-of-week,
+	// e.g. "0 2 * * 6" opens the window at 02:00 on Saturdays
+	// +kubebuilder:validation:MinItems=1
+	Schedules []string `json:"schedules"`
+	// Duration the window stays open for, upgrades started in the window complete after it closes
+	Duration metav1.Duration `json:"duration"`
+	// TimeZone of the schedules, e.g. Europe/Berlin, defaults to UTC
+	// +optional
+	TimeZone string `json:"timeZone,omitempty"`
+}
+
+// PendingUpgrade is a change of an installed addon waiting for the maintenance window
+type PendingUpgrade struct {
+	// Checksum of the spec waiting for the window
+	Checksum string `json:"checksum"`
+	// PkgVersion of the spec waiting for the window
+	// +optional
+	PkgVersion string `json:"pkgVersion,omitempty"`
+	// NextWindow is when the maintenance window opens next
+	// +optional
+	NextWindow *metav1.Time `json:"nextWindow,omitempty"`
+}
+
+// InstallDependenciesPolicy controls whether missing dependencies are installed from the catalog
+type InstallDependenciesPolicy string
+
+const (
+	// InstallDependenciesNever waits for dependencies to be installed by their own addons
+	InstallDependenciesNever InstallDependenciesPolicy = "Never"
+	// InstallDependenciesIfNotPresent creates the catalog addon of dependencies that are not installed
+	InstallDependenciesIfNotPresent InstallDependenciesPolicy = "IfNotPresent"
+)
+
+// UpdatePolicy is the range of newer releases an addon is upgraded to
+type UpdatePolicy string
+
+const (
+	// PatchUpdates upgrades to patch releases of the installed minor version
+	PatchUpdates UpdatePolicy = "Patch"
+	// MinorUpdates upgrades to minor and patch releases of the installed major version
+	MinorUpdates UpdatePolicy = "Minor"
+	// MajorUpdates upgrades to any newer release
+	MajorUpdates UpdatePolicy = "Major"
+)
+
+// AutoUpdatePolicy controls automated upgrades of addons installed from a catalog
+type AutoUpdatePolicy struct {
+	// Policy is the range of releases the addon is upgraded to, defaults to Major
+	// +kubebuilder:validation:Enum=Patch;Minor;Major
+	// +optional
+	Policy UpdatePolicy `json:"policy,omitempty"`
+	// Ceiling is a semver constraint upgrades must satisfy, e.g. <1.25
+	// +optional
+	Ceiling string `json:"ceiling,omitempty"`
+}
+
+// DeletionPolicy controls the deletion of addons with dependents
+type DeletionPolicy string
+
+const (
+	// DeletionPolicyBlock keeps the addon until its dependents are deleted
+	DeletionPolicyBlock DeletionPolicy = "Block"
+	// DeletionPolicyCascade deletes the dependents first, in reverse dependency order
+	DeletionPolicyCascade DeletionPolicy = "Cascade"
+)
+
+// StrategyType is how a new version of the addon replaces the installed one
+type StrategyType string
+
+const (
+	// InPlaceStrategy installs the new version over the installed one
+	InPlaceStrategy StrategyType = "InPlace"
+	// BlueGreenStrategy installs the new version alongside the installed one and deletes the old version
+	// once the validate workflow of the new version succeeds
+	BlueGreenStrategy StrategyType = "BlueGreen"
+)
+
+const (
+	// BlueSlot is the first slot blue-green addons are installed in
+	BlueSlot = "blue"
+	// GreenSlot is the slot alternating with BlueSlot
+	GreenSlot = "green"
+)
+
+// InstallStrategy controls upgrades of the addon
+type InstallStrategy struct {
+	// Type of the strategy, defaults to InPlace
+	// +kubebuilder:validation:Enum=InPlace;BlueGreen
+	// +optional
+	Type StrategyType `json:"type,omitempty"`
+}
+
+// StrategyStatus is the state of a blue-green install
+type StrategyStatus struct {
+	// Active is the slot of the installed version
+	// +optional
+	Active string `json:"active,omitempty"`
+	// ActiveChecksum is the checksum of the spec installed in the active slot
+	// +optional
+	ActiveChecksum string `json:"activeChecksum,omitempty"`
+	// Candidate is the slot the new version is installed and validated in before the cut over
+	// +optional
+	Candidate string `json:"candidate,omitempty"`
+}
+
+const (
+	// DependentExistsCondition is true while the deletion of the addon waits for its dependents
+	DependentExistsCondition = "DependentExists"
+	// DependenciesReadyCondition is true once all package and addon dependencies are installed successfully
+	DependenciesReadyCondition = "DependenciesReady"
+	// ConflictCondition is true while other addons require versions of a dependency incompatible with the addon
+	ConflictCondition = "Conflict"
+	// DeprecatedCondition is true while the catalog deprecates the installed package version
+	DeprecatedCondition = "Deprecated"
+	// KubeVersionCompatibleCondition is true when the version of the target cluster is in the package KubeVersion range
+	KubeVersionCompatibleCondition = "KubeVersionCompatible"
+	// OutOfSyncCondition is true while the installed spec is not the current spec, e.g. while its workflows run
+	OutOfSyncCondition = "OutOfSync"
+	// CircuitOpenCondition is true while the workflows of an addon whose failure budget is exhausted are not
+	// submitted, until the addon is resumed or its spec changes
+	CircuitOpenCondition = "CircuitOpen"
+
+	// DepsNotReadyReason is the reason of the DependenciesReady condition and of the events of addons whose workflows
+	// wait on dependencies that did not succeed yet
+	DepsNotReadyReason = "DepsNotReady"
+)
+
+// AddonSource is the repository of a Helm chart or of manifests, the addon parameters are passed as Helm values
+type AddonSource struct {
+	// RepoURL of the Git or Helm repository
+	RepoURL string `json:"repoURL"`
+	// Chart name for helm packages in a Helm repository
+	// +optional
+	Chart string `json:"chart,omitempty"`
+	// Path of the chart, kustomization or manifests in a Git repository
+	// +optional
+	Path string `json:"path,omitempty"`
+	// TargetRevision is the chart version or Git revision, defaults to the package version
+	// +optional
+	TargetRevision string `json:"targetRevision,omitempty"`
+	// Values is a YAML document of Helm values of charts installed with generated workflows, addon params are set
+	// over them
+	// +optional
+	Values string `json:"values,omitempty"`
+}
+
+// HelmReleaseStatus is the state of the Helm release of an addon installed from a chart
+type HelmReleaseStatus struct {
+	// Name and Namespace of the release
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+	// Chart and Version installed
+	Chart   string `json:"chart"`
+	Version string `json:"version,omitempty"`
+	// Revision is the latest release revision
+	Revision int64 `json:"revision,omitempty"`
+	// Status of the latest revision, e.g. deployed, failed or pending-upgrade
+	Status string `json:"status,omitempty"`
+}
+
+// AddonTarget selects the cluster the addon lifecycle workflows are submitted to
+type AddonTarget struct {
+	// ClusterRef references a Secret in the addon namespace holding a kubeconfig for the remote cluster
+	// +optional
+	ClusterRef *ClusterReference `json:"clusterRef,omitempty"`
+	// ClusterSelector installs the addon in every Cluster API cluster in the addon namespace matching the selector,
+	// a member addon targeting the cluster kubeconfig Secret is created per cluster
+	// +optional
+	ClusterSelector *metav1.LabelSelector `json:"clusterSelector,omitempty"`
+	// Rollout updates the selected clusters in waves, by default all clusters are updated at once
+	// +optional
+	Rollout *RolloutStrategy `json:"rollout,omitempty"`
+	// Overrides set params that differ per selected cluster, later overrides take precedence
+	// +optional
+	Overrides []ClusterOverride `json:"overrides,omitempty"`
+}
+
+// ClusterOverride sets params of the member addons of the clusters it matches
+type ClusterOverride struct {
+	// Clusters are the names of the clusters the override applies to
+	// +optional
+	Clusters []string `json:"clusters,omitempty"`
+	// ClusterSelector selects the clusters the override applies to by label
+	// +optional
+	ClusterSelector *metav1.LabelSelector `json:"clusterSelector,omitempty"`
+	// Context values replacing the addon context, additional configs are merged by name
+	// +optional
+	Context ClusterContext `json:"context,omitempty"`
+	// Data values merged into the addon data params by name
+	// +optional
+	Data map[string]FlexString `json:"data,omitempty"`
+}
+
+// RolloutStrategy defines how addon changes are rolled out to the clusters selected by the cluster selector,
+// clusters are updated in order of their name
+type RolloutStrategy struct {
+	// CanaryPercent of the clusters updated in the first wave, at least one cluster is updated
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=100
+	// +optional
+	CanaryPercent int32 `json:"canaryPercent,omitempty"`
+	// WavePercent of the clusters updated in each following wave, defaults to all remaining clusters
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=100
+	// +optional
+	WavePercent int32 `json:"wavePercent,omitempty"`
+	// SoakTime a wave must be installed before the next wave starts
+	// +optional
+	SoakTime *metav1.Duration `json:"soakTime,omitempty"`
+	// MaxFailures is the number of failed clusters tolerated before the rollout halts
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	MaxFailures int32 `json:"maxFailures,omitempty"`
+}
+
+// ClusterReference references a kubeconfig Secret
+type ClusterReference struct {
+	// Name of the Secret
+	Name string `json:"name"`
+	// Key of the kubeconfig in the Secret, defaults to kubeconfig
+	// +optional
+	Key string `json:"key,omitempty"`
+}
+
+// DependencyStatus is the state of a package or addon dependency declared by an addon
+type DependencyStatus struct {
+	// Name of the required package, or namespace/name or namespace/selector of the required addons
+	Name string `json:"name"`
+	// Required version constraint
+	Required string `json:"required"`
+	// Found is the highest installed version satisfying the constraint
+	// +optional
+	Found string `json:"found,omitempty"`
+	// Addon installing the found version
+	// +optional
+	Addon string `json:"addon,omitempty"`
+	// Installed is the install phase of the found version
+	// +optional
+	Installed ApplicationAssemblyPhase `json:"installed,omitempty"`
+	// Satisfied is true once the found version is installed successfully
+	Satisfied bool `json:"satisfied"`
+	// Message explains why an addon dependency is not found
+	// +optional
+	Message string `json:"message,omitempty"`
+	// Conflicts are the addons requiring versions of the package incompatible with Required
+	// +optional
+	Conflicts []DependencyConflict `json:"conflicts,omitempty"`
+}
+
+// DependencyConflict is an addon requiring a version of a dependency that no version satisfying the constraint
+// of the addon matches
+type DependencyConflict struct {
+	// Addon is the namespace/name of the conflicting addon
+	Addon string `json:"addon"`
+	// Required is the version constraint of the conflicting addon
+	Required string `json:"required"`
+}
+
+// CatalogSyncStatus is the catalog index revision the spec of an addon was rendered from
+type CatalogSyncStatus struct {
+	// Revision of the catalog index
+	Revision string `json:"revision"`
+	// Channel the addon follows, empty for addons with a version
+	// +optional
+	Channel string `json:"channel,omitempty"`
+	// Version is the rendered package version, the channel head for addons following a channel
+	Version string `json:"version"`
+	// LastSyncTime is when the addon was first rendered from the revision
+	// +optional
+	LastSyncTime *metav1.Time `json:"lastSyncTime,omitempty"`
+}
+
+// TemplateStatus is the AddonTemplate the spec of an addon is completed from
+type TemplateStatus struct {
+	// Name of the AddonTemplate
+	Name string `json:"name"`
+	// Defaults are the spec fields set from the template, e.g. lifecycle.install or params.data.replicas
+	// +optional
+	Defaults []string `json:"defaults,omitempty"`
+}
+
+// ClusterStatus is the install status of an addon in one of the clusters it targets
+type ClusterStatus struct {
+	// Cluster is the name of the Cluster API cluster or of the hub managed cluster
+	Cluster string `json:"cluster"`
+	// Addon is the name of the member addon installing the addon in the cluster, empty when a hub installs it
+	// +optional
+	Addon string `json:"addon,omitempty"`
+	// Installed is the install phase of the member addon
+	Installed ApplicationAssemblyPhase `json:"installed,omitempty"`
+	// +optional
+	Reason string `json:"reason,omitempty"`
+	// Outdated is true while the member addon waits for its rollout wave
+	// +optional
+	Outdated bool `json:"outdated,omitempty"`
+}
+
+// ClusterSummary counts the install phases of the clusters an addon targets
+type ClusterSummary struct {
+	// Ready is the number of succeeded clusters out of all clusters, e.g. 8/10
+	Ready string `json:"ready"`
+	// Total is the number of targeted clusters
+	Total int32 `json:"total"`
+	// Succeeded is the number of clusters the addon is installed in
+	Succeeded int32 `json:"succeeded"`
+	// Failed is the number of clusters the addon failed to install or delete in
+	Failed int32 `json:"failed"`
+	// Pending is the number of clusters the addon is being installed, updated or deleted in
+	Pending int32 `json:"pending"`
+}
+
+// NewClusterSummary counts the phases of the cluster statuses, outdated clusters are pending
+func NewClusterSummary(statuses []ClusterStatus) *ClusterSummary {
+	summary := &ClusterSummary{Total: int32(len(statuses))}
+	for _, s := range statuses {
+		switch {
+		case s.Outdated:
+			summary.Pending++
+		case s.Installed == Succeeded:
+			summary.Succeeded++
+		case s.Installed == Failed || s.Installed == DeleteFailed:
+			summary.Failed++
+		default:
+			summary.Pending++
+		}
+	}
+	summary.Ready = fmt.Sprintf("%d/%d", summary.Succeeded, summary.Total)
+	return summary
+}
+
+// String returns the summary as 8/10 Succeeded, 1 Failed, 1 Pending
+func (s *ClusterSummary) String() string {
+	return fmt.Sprintf("%s Succeeded, %d Failed, %d Pending", s.Ready, s.Failed, s.Pending)
+}
+
+// RolloutStatus reports the progress of a wave based rollout
+type RolloutStatus struct {
+	// Clusters is the number of selected clusters
+	Clusters int32 `json:"clusters"`
+	// Updated is the number of clusters updated to the current addon spec
+	Updated int32 `json:"updated"`
+	// Halted is true when more clusters failed than the rollout tolerates
+	// +optional
+	Halted bool `json:"halted,omitempty"`
+	// SoakStartTime is when the last wave completed
+	// +optional
+	SoakStartTime *metav1.Time `json:"soakStartTime,omitempty"`
+}
+
 // AddonStatusLifecycle defines the lifecycle status for steps.
 type AddonStatusLifecycle struct {
 	Prereqs   ApplicationAssemblyPhase `json:"prereqs,omitempty"`
 	Installed ApplicationAssemblyPhase `json:"installed,omitempty"`
+	// FailedStep is the lifecycle step whose workflow failed last, cleared once the step succeeds
+	// +optional
+	FailedStep LifecycleStep `json:"failedStep,omitempty"`
+	// Checksums are the step checksums of the lifecycle steps that succeeded since the addon was last installed,
+	// retries skip the steps whose checksum did not change
+	// +optional
+	Checksums map[LifecycleStep]string `json:"checksums,omitempty"`
+	// Skipped are the lifecycle steps skipped by their spec flags
+	// +optional
+	Skipped []LifecycleStep `json:"skipped,omitempty"`
+}
+
+// LifecycleStepTiming records when a lifecycle step workflow started and completed
+type LifecycleStepTiming struct {
+	// Workflow is the name of the workflow that was timed
+	Workflow string `json:"workflow,omitempty"`
+	// StartTime is when the workflow was first submitted
+	// +optional
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+	// CompletionTime is when the workflow was observed as Succeeded or Failed
+	// +optional
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+	// Duration of the step, set once the step completes
+	// +optional
+	Duration *metav1.Duration `json:"duration,omitempty"`
+}
+
+// AddonStatusTimings holds the timing of the last run of each lifecycle step
+type AddonStatusTimings struct {
+	Prereqs  *LifecycleStepTiming `json:"prereqs,omitempty"`
+	Install  *LifecycleStepTiming `json:"install,omitempty"`
+	Validate *LifecycleStepTiming `json:"validate,omitempty"`
+	Delete   *LifecycleStepTiming `json:"delete,omitempty"`
+	Upgrade  *LifecycleStepTiming `json:"upgrade,omitempty"`
+}
+
+// WorkflowProgress reports the progress of the currently running lifecycle workflow
+type WorkflowProgress struct {
+	// Workflow is the name of the running workflow
+	Workflow string `json:"workflow,omitempty"`
+	// Progress is the number of completed workflow nodes out of the total, e.g. 2/5
+	Progress string `json:"progress,omitempty"`
+	// CurrentTemplate is the name of the template(s) currently executing
+	CurrentTemplate string `json:"currentTemplate,omitempty"`
 }
 
 // ObjectStatus is a generic status holder for objects
@@ -274,6 +932,64 @@ type ObjectStatus struct {
 	Status string `json:"status,omitempty"`
 }
 
+// ResourceReference identifies an object applied by the addon artifacts
+type ResourceReference struct {
+	Group   string `json:"group,omitempty"`
+	Version string `json:"version"`
+	Kind    string `json:"kind"`
+	// Namespace of the object, empty for cluster scoped objects and objects applied in the workflow namespace
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name"`
+	// Digest of the applied object, upgrades changing it report the object as changed
+	// +optional
+	Digest string `json:"digest,omitempty"`
+}
+
+// UpgradeDiff summarizes the objects an upgrade added, removed and changed in the cluster
+type UpgradeDiff struct {
+	// FromChecksum is the checksum of the spec installed before the upgrade
+	FromChecksum string `json:"fromChecksum"`
+	// ToChecksum is the checksum of the upgraded spec
+	ToChecksum string   `json:"toChecksum"`
+	Added      []string `json:"added,omitempty"`
+	Removed    []string `json:"removed,omitempty"`
+	Changed    []string `json:"changed,omitempty"`
+}
+
+// RollbackStatus is the rollback of a failed install to the last revision installed successfully
+type RollbackStatus struct {
+	// Revision is the number of the spec revision rolled back to
+	Revision int64 `json:"revision"`
+	// Checksum of the spec rolled back to
+	Checksum string `json:"checksum"`
+	// FailedChecksum is the checksum of the spec whose install failed
+	FailedChecksum string `json:"failedChecksum"`
+	// Workflow is the name of the workflow installing the revision again
+	Workflow string `json:"workflow"`
+	// Phase of the rollback workflow
+	// +optional
+	Phase ApplicationAssemblyPhase `json:"phase,omitempty"`
+}
+
+// FailureBudgetStatus counts the install failures of a spec within the failure budget window
+type FailureBudgetStatus struct {
+	// Checksum of the spec the failures are counted for, a new spec starts with the full budget
+	Checksum string `json:"checksum"`
+	// Failures is the number of failed installs within the window
+	Failures int `json:"failures"`
+	// WindowStart is when the first failure of the window was observed
+	WindowStart metav1.Time `json:"windowStart"`
+	// LastFailure is when the last failure was observed, retries back off from it
+	LastFailure metav1.Time `json:"lastFailure"`
+}
+
+// InstalledResources are the objects applied by the artifacts of an installed spec
+type InstalledResources struct {
+	// Checksum of the spec the resources were installed with
+	Checksum  string              `json:"checksum"`
+	Resources []ResourceReference `json:"resources,omitempty"`
+}
+
 // AddonStatus defines the observed state of Addon
 type AddonStatus struct {
 	Checksum  string               `json:"checksum"`
@@ -281,6 +997,74 @@ type AddonStatus struct {
 	Resources []ObjectStatus       `json:"resources"`
 	Reason    string               `json:"reason"`
 	StartTime int64                `json:"starttime,omitempty"`
+	// InstalledChecksum is the checksum of the spec that was last installed successfully
+	// +optional
+	InstalledChecksum string `json:"installedChecksum,omitempty"`
+	// PendingUpgrade is the change waiting for the maintenance window, cleared once it is applied
+	// +optional
+	PendingUpgrade *PendingUpgrade `json:"pendingUpgrade,omitempty"`
+	// InstalledResources are the objects applied by the last installed spec, objects the artifacts of a new spec
+	// no longer contain are pruned after it is installed
+	// +optional
+	InstalledResources *InstalledResources `json:"installedResources,omitempty"`
+	// LastUpgrade is the change of the objects applied by the last upgrade
+	// +optional
+	LastUpgrade *UpgradeDiff `json:"lastUpgrade,omitempty"`
+	// Rollback is the last rollback of a failed install, with spec.lifecycle.rollbackOnFailure
+	// +optional
+	Rollback *RollbackStatus `json:"rollback,omitempty"`
+	// FailureBudget counts the failed installs of the spec, the CircuitOpen condition is set once the budget is
+	// exhausted
+	// +optional
+	FailureBudget *FailureBudgetStatus `json:"failureBudget,omitempty"`
+	// Helm is the release of helm packages installed from a chart with generated workflows
+	// +optional
+	Helm *HelmReleaseStatus `json:"helm,omitempty"`
+	// SourceDigest is the digest of the content resolved from outside the spec, the SOPS params ConfigMap, the
+	// common params ConfigMap and the data of external secrets, it is part of the checksum so upstream changes are installed
+	// +optional
+	SourceDigest string `json:"sourceDigest,omitempty"`
+	// Timings of the lifecycle step workflows
+	// +optional
+	Timings AddonStatusTimings `json:"timings,omitempty"`
+	// Progress of the lifecycle workflow while it is running
+	// +optional
+	Progress *WorkflowProgress `json:"progress,omitempty"`
+	// Cluster is the API server of the remote cluster the addon was installed in
+	// +optional
+	Cluster string `json:"cluster,omitempty"`
+	// Clusters is the status of each cluster selected by the cluster selector
+	// +optional
+	Clusters []ClusterStatus `json:"clusters,omitempty"`
+	// Rollout is the progress of the wave based rollout to the selected clusters
+	// +optional
+	Rollout *RolloutStatus `json:"rollout,omitempty"`
+	// Summary counts the install phases of Clusters
+	// +optional
+	Summary *ClusterSummary `json:"summary,omitempty"`
+	// Revision is the number of the last applied spec revision
+	// +optional
+	Revision int64 `json:"revision,omitempty"`
+	// Dependencies is the state of each package dependency
+	// +optional
+	Dependencies []DependencyStatus `json:"dependencies,omitempty"`
+	// Strategy is the state of the blue-green install
+	// +optional
+	Strategy *StrategyStatus `json:"strategy,omitempty"`
+	// Catalog is the catalog revision an addon installed from an AddonCatalog is synced to
+	// +optional
+	Catalog *CatalogSyncStatus `json:"catalog,omitempty"`
+	// Template records the spec fields an addon referencing an AddonTemplate gets from the template
+	// +optional
+	Template *TemplateStatus `json:"template,omitempty"`
+	// Images are the container images of the workloads observed for the addon
+	// +optional
+	Images []string `json:"images,omitempty"`
+	// Conditions of the addon
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
 }
 
 // +kubebuilder:object:root=true
@@ -293,6 +1077,10 @@ type AddonStatus struct {
 // +kubebuilder:printcolumn:name="VERSION",type="string",JSONPath=".spec.pkgVersion"
 // +kubebuilder:printcolumn:name="STATUS",type="string",JSONPath=".status.lifecycle.installed"
 // +kubebuilder:printcolumn:name="REASON",type="string",JSONPath=".status.reason"
+// +kubebuilder:printcolumn:name="CLUSTER",type="string",JSONPath=".status.cluster",priority=1
+// +kubebuilder:printcolumn:name="CLUSTERS",type="string",JSONPath=".status.summary.ready"
+// +kubebuilder:printcolumn:name="PROGRESS",type="string",JSONPath=".status.progress.progress",priority=1
+// +kubebuilder:printcolumn:name="REVISION",type="integer",JSONPath=".status.revision",priority=1
 // +kubebuilder:printcolumn:name="AGE",type="date",JSONPath=".metadata.creationTimestamp"
 type Addon struct {
 	metav1.TypeMeta   `json:",inline"`
@@ -329,17 +1117,7 @@ func (a *Addon) GetPackageSpec() PackageSpec {
 
 // GetAllAddonParameters returns an object copying the params submitted as part of the addon spec
 func (a *Addon) GetAllAddonParameters() map[string]string {
-	params := make(map[string]string)
-	params["namespace"] = a.Spec.Params.Namespace
-	params["clusterName"] = a.Spec.Params.Context.ClusterName
-	params["clusterRegion"] = a.Spec.Params.Context.ClusterRegion
-	for k, v := range a.Spec.Params.Context.AdditionalConfigs {
-		params[k] = string(v)
-	}
-	for k, v := range a.Spec.Params.Data {
-		params[k] = string(v)
-	}
-	return params
+	return a.Spec.ResolvedParams()
 }
 
 // GetWorkflowType returns the WorkflowType under the addon lifecycle spec
@@ -354,6 +1132,8 @@ func (a *Addon) GetWorkflowType(step LifecycleStep) (*WorkflowType, error) {
 		wt = &a.Spec.Lifecycle.Delete
 	case Validate:
 		wt = &a.Spec.Lifecycle.Validate
+	case Upgrade:
+		wt = &a.Spec.Lifecycle.Upgrade
 	default:
 		return nil, fmt.Errorf("no WorkflowType of type %s exists", step)
 	}
@@ -361,8 +1141,72 @@ func (a *Addon) GetWorkflowType(step LifecycleStep) (*WorkflowType, error) {
 	return wt, nil
 }
 
+// StepSkipped returns true when the spec skips the workflow of the lifecycle step
+func (a *Addon) StepSkipped(step LifecycleStep) bool {
+	wt, err := a.GetWorkflowType(step)
+	return err == nil && wt.Skip
+}
+
+// SkippedSteps returns the lifecycle steps the spec skips in lifecycle order
+func (a *Addon) SkippedSteps() []LifecycleStep {
+	var skipped []LifecycleStep
+	for _, step := range []LifecycleStep{Prereqs, Install, Upgrade, Validate, Delete} {
+		if a.StepSkipped(step) {
+			skipped = append(skipped, step)
+		}
+	}
+	return skipped
+}
+
+// GetStepTiming returns the status timing of the lifecycle step, nil if the step has not run
+func (a *Addon) GetStepTiming(step LifecycleStep) *LifecycleStepTiming {
+	switch step {
+	case Prereqs:
+		return a.Status.Timings.Prereqs
+	case Install:
+		return a.Status.Timings.Install
+	case Validate:
+		return a.Status.Timings.Validate
+	case Delete:
+		return a.Status.Timings.Delete
+	case Upgrade:
+		return a.Status.Timings.Upgrade
+	}
+	return nil
+}
+
+// SetStepTiming sets the status timing of the lifecycle step
+func (a *Addon) SetStepTiming(step LifecycleStep, timing *LifecycleStepTiming) {
+	switch step {
+	case Prereqs:
+		a.Status.Timings.Prereqs = timing
+	case Install:
+		a.Status.Timings.Install = timing
+	case Validate:
+		a.Status.Timings.Validate = timing
+	case Delete:
+		a.Status.Timings.Delete = timing
+	case Upgrade:
+		a.Status.Timings.Upgrade = timing
+	}
+}
+
+// InstallStep returns the lifecycle step installing the current spec, the upgrade step when the addon has an upgrade
+// workflow and a previously installed spec, unless the install step already succeeded for the current spec
+func (a *Addon) InstallStep() LifecycleStep {
+	if a.Spec.Lifecycle.Upgrade.Template == "" || a.IsBlueGreen() || a.Status.InstalledChecksum == "" || a.StepSucceeded(Install) {
+		return Install
+	}
+	return Upgrade
+}
+
 // GetFormattedWorkflowName used the addon name, workflow prefix, addon checksum, and lifecycle step to compose the workflow name
 func (a *Addon) GetFormattedWorkflowName(lifecycleStep LifecycleStep) string {
+	return a.WorkflowName(lifecycleStep, a.CalculateChecksum())
+}
+
+// WorkflowName returns the workflow name of the lifecycle step for an already calculated spec checksum
+func (a *Addon) WorkflowName(lifecycleStep LifecycleStep, checksum string) string {
 	wt, err := a.GetWorkflowType(lifecycleStep)
 	if err != nil {
 		return ""
@@ -372,16 +1216,101 @@ func (a *Addon) GetFormattedWorkflowName(lifecycleStep LifecycleStep) string {
 	if wt.NamePrefix != "" {
 		prefix = fmt.Sprintf("%s-%s", prefix, wt.NamePrefix)
 	}
-	wfIdentifierName := fmt.Sprintf("%s-%s-%s-wf", prefix, lifecycleStep, a.CalculateChecksum())
+	wfIdentifierName := fmt.Sprintf("%s-%s-%s-wf", prefix, lifecycleStep, checksum)
 	return wfIdentifierName
 }
 
 // CalculateChecksum converts the AddonSpec into a hash string (using Alder32 algo)
 func (a *Addon) CalculateChecksum() string {
-	return fmt.Sprintf("%x", adler32.Checksum([]byte(fmt.Sprintf("%+v", a.Spec))))
+	// The maintenance window only schedules upgrades
+	s := a.Spec
+	s.MaintenanceWindow = nil
+	return fmt.Sprintf("%x", adler32.Checksum(checksumContent(s)))
+}
+
+// CalculateStepChecksum returns the checksum of the spec a lifecycle step depends on, the spec without the workflows
+// of the other steps combined with the source digest
+func (a *Addon) CalculateStepChecksum(step LifecycleStep) string {
+	wt, err := a.GetWorkflowType(step)
+	if err != nil {
+		return ""
+	}
+	stepAddon := &Addon{Spec: *a.Spec.DeepCopy()}
+	stepAddon.Spec.Lifecycle = LifecycleWorkflowSpec{}
+	stepWt, _ := stepAddon.GetWorkflowType(step)
+	wt.DeepCopyInto(stepWt)
+	return CombineChecksum(stepAddon.CalculateChecksum(), a.Status.SourceDigest)
+}
+
+// StepSucceeded returns true when the lifecycle step succeeded since the addon was last installed and the spec it
+// depends on did not change
+func (a *Addon) StepSucceeded(step LifecycleStep) bool {
+	checksum, ok := a.Status.Lifecycle.Checksums[step]
+	return ok && checksum == a.CalculateStepChecksum(step)
+}
+
+// SetStepSucceeded records the step checksum of a succeeded lifecycle step
+func (a *Addon) SetStepSucceeded(step LifecycleStep) {
+	if a.Status.Lifecycle.Checksums == nil {
+		a.Status.Lifecycle.Checksums = map[LifecycleStep]string{}
+	}
+	a.Status.Lifecycle.Checksums[step] = a.CalculateStepChecksum(step)
+}
+
+// CalculateStatusChecksum returns the checksum the reconcile records in the status, the spec checksum combined with
+// the source digest
+func (a *Addon) CalculateStatusChecksum() string {
+	return CombineChecksum(a.CalculateChecksum(), a.Status.SourceDigest)
+}
+
+// CombineChecksum returns the checksum of a spec checksum and a source digest, the spec checksum when there
+// is no digest so addons without external sources keep their checksum
+func CombineChecksum(specChecksum, sourceDigest string) string {
+	if sourceDigest == "" {
+		return specChecksum
+	}
+	return fmt.Sprintf("%x", adler32.Checksum([]byte(specChecksum+"/"+sourceDigest)))
+}
+
+// SourceDigest returns the digest of the content resolved from outside the spec, empty when there is none
+func SourceDigest(contents ...string) string {
+	h := sha256.New()
+	empty := true
+	for _, c := range contents {
+		if c != "" {
+			empty = false
+		}
+		fmt.Fprintf(h, "%d:%s", len(c), c)
+	}
+	if empty {
+		return ""
+	}
+	return fmt.Sprintf("sha256:%x", h.Sum(nil))
+}
+
+// GetChecksum returns the checksum recorded in the status by the reconcile, it is calculated when none was recorded.
+// Specs modified after the reconcile recorded the checksum must use CalculateStatusChecksum.
+func (a *Addon) GetChecksum() string {
+	if a.Status.Checksum != "" {
+		return a.Status.Checksum
+	}
+	return a.CalculateStatusChecksum()
 }
 
 // GetInstallStatus returns the install phase for addon
 func (a *Addon) GetInstallStatus() ApplicationAssemblyPhase {
 	return a.Status.Lifecycle.Installed
 }
+
+// IsBlueGreen returns true when new versions of the addon are installed alongside the installed one
+func (a *Addon) IsBlueGreen() bool {
+	return a.Spec.Strategy != nil && a.Spec.Strategy.Type == BlueGreenStrategy
+}
+
+// OtherSlot returns the blue-green slot alternating with slot, the blue slot for an empty slot
+func OtherSlot(slot string) string {
+	if slot == BlueSlot {
+		return GreenSlot
+	}
+	return BlueSlot
+}
@@ -165,6 +165,12 @@ var _ = Describe("Addon", func() {
 			checksum := fetched.CalculateChecksum()
 			Expect(checksum).To(Equal("4a77025d"))
 
+			// Fields added to the spec since are hashed by value
+			withTarget := fetched.DeepCopy()
+			withTarget.Spec.Target = &AddonTarget{}
+			Expect(withTarget.CalculateChecksum()).ToNot(Equal(checksum))
+			Expect(withTarget.DeepCopy().CalculateChecksum()).To(Equal(withTarget.CalculateChecksum()))
+
 			// Update status checksum
 			fetched.Status.Checksum = checksum
 
@@ -186,4 +192,116 @@ var _ = Describe("Addon", func() {
 
 	})
 
+	Context("Step checksums", func() {
+
+		It("should only change with the spec a step depends on", func() {
+			a := &Addon{
+				Spec: AddonSpec{
+					PackageSpec: PackageSpec{PkgName: "my-addon", PkgVersion: "1.0.0"},
+					Lifecycle: LifecycleWorkflowSpec{
+						Prereqs: WorkflowType{Template: wfSpecTemplate},
+						Install: WorkflowType{Template: wfSpecTemplate},
+					},
+				},
+			}
+			Expect(a.StepSucceeded(Prereqs)).To(BeFalse())
+			a.SetStepSucceeded(Prereqs)
+			Expect(a.StepSucceeded(Prereqs)).To(BeTrue())
+			Expect(a.StepSucceeded(Install)).To(BeFalse())
+
+			By("changing the install workflow")
+			a.Spec.Lifecycle.Install.Template = "changed"
+			Expect(a.StepSucceeded(Prereqs)).To(BeTrue())
+
+			By("changing the params every step depends on")
+			a.Spec.Params.Namespace = "changed"
+			Expect(a.StepSucceeded(Prereqs)).To(BeFalse())
+
+			a.SetStepSucceeded(Prereqs)
+			a.Status.SourceDigest = SourceDigest("params")
+			Expect(a.StepSucceeded(Prereqs)).To(BeFalse())
+		})
+
+	})
+
+	Context("Install step", func() {
+
+		It("should upgrade installed addons with an upgrade workflow", func() {
+			a := &Addon{
+				Spec: AddonSpec{
+					PackageSpec: PackageSpec{PkgName: "my-addon", PkgVersion: "1.0.0"},
+					Lifecycle: LifecycleWorkflowSpec{
+						Install: WorkflowType{Template: wfSpecTemplate},
+					},
+				},
+			}
+			Expect(a.InstallStep()).To(Equal(Install))
+
+			By("adding an upgrade workflow to an addon that was not installed")
+			a.Spec.Lifecycle.Upgrade.Template = wfSpecTemplate
+			Expect(a.InstallStep()).To(Equal(Install))
+
+			By("installing the spec")
+			a.SetStepSucceeded(Install)
+			a.Status.InstalledChecksum = a.CalculateChecksum()
+			Expect(a.InstallStep()).To(Equal(Install))
+
+			By("changing the spec of the installed addon")
+			a.Spec.PkgVersion = "1.1.0"
+			Expect(a.InstallStep()).To(Equal(Upgrade))
+			a.SetStepSucceeded(Upgrade)
+			Expect(a.InstallStep()).To(Equal(Upgrade))
+			Expect(a.StepSucceeded(Upgrade)).To(BeTrue())
+		})
+
+	})
+
+	Context("Cluster context", func() {
+
+		It("should resolve the params of the spec", func() {
+			spec := &AddonSpec{
+				Params: AddonParams{
+					Namespace: "foo-ns",
+					Context: ClusterContext{
+						ClusterName:       "foo-cluster",
+						ClusterRegion:     "foo-region",
+						AdditionalConfigs: map[string]FlexString{"shared": "context", "additional": "config"},
+					},
+					Data: map[string]FlexString{"shared": "data"},
+				},
+			}
+			Expect(spec.GetClusterName()).To(Equal("foo-cluster"))
+			Expect(spec.GetRegion()).To(Equal("foo-region"))
+			Expect(spec.ResolvedParams()).To(Equal(map[string]string{
+				"namespace":     "foo-ns",
+				"clusterName":   "foo-cluster",
+				"clusterRegion": "foo-region",
+				"additional":    "config",
+				"shared":        "data",
+			}))
+
+			By("resolving the params of an empty spec")
+			Expect((&AddonSpec{}).ResolvedParams()).To(Equal(map[string]string{"namespace": "", "clusterName": "", "clusterRegion": ""}))
+		})
+
+		It("should merge contexts without modifying them", func() {
+			base := ClusterContext{ClusterName: "base", ClusterRegion: "us-west-2", AdditionalConfigs: map[string]FlexString{"a": "1", "b": "1"}}
+			merged := base.Merge(ClusterContext{ClusterRegion: "us-east-1", AdditionalConfigs: map[string]FlexString{"b": "2"}})
+			Expect(merged.GetClusterName()).To(Equal("base"))
+			Expect(merged.GetRegion()).To(Equal("us-east-1"))
+			Expect(merged.AdditionalConfigs).To(Equal(map[string]FlexString{"a": "1", "b": "2"}))
+			Expect(base.AdditionalConfigs).To(HaveKeyWithValue("b", FlexString("1")))
+
+			v, ok := merged.GetConfig("a")
+			Expect(ok).To(BeTrue())
+			Expect(v).To(Equal("1"))
+			_, ok = merged.GetConfig("missing")
+			Expect(ok).To(BeFalse())
+
+			Expect(ClusterContext{}.IsEmpty()).To(BeTrue())
+			Expect(merged.IsEmpty()).To(BeFalse())
+		})
+
+	})
+
 })
@@ -0,0 +1,155 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package v1alpha1
+
+import (
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// CatalogSourceType is the kind of location an addon catalog index is fetched from
+type CatalogSourceType string
+
+const (
+	// HTTPCatalog is an index file served over HTTP(S)
+	HTTPCatalog CatalogSourceType = "HTTP"
+	// OCICatalog is an index pushed as the single layer of an OCI artifact
+	OCICatalog CatalogSourceType = "OCI"
+	// GitCatalog is an index file in a Git repository
+	GitCatalog CatalogSourceType = "Git"
+)
+
+// CatalogReadyCondition is true when the last sync of the catalog index succeeded
+const CatalogReadyCondition = "Ready"
+
+// AddonCatalogSpec defines the index of addon packages a catalog syncs
+type AddonCatalogSpec struct {
+	// Type of the index location
+	// +kubebuilder:validation:Enum=HTTP;OCI;Git
+	Type CatalogSourceType `json:"type"`
+	// URL of the index file for HTTP, artifact reference for OCI, e.g. ghcr.io/org/catalog:v1, or repository for Git
+	URL string `json:"url"`
+	// Revision is the branch, tag or commit of a Git repository, defaults to HEAD
+	// +optional
+	Revision string `json:"revision,omitempty"`
+	// Path of the index file in a Git repository, defaults to index.yaml
+	// +optional
+	Path string `json:"path,omitempty"`
+	// SecretRef names a Secret in the catalog namespace with the username and password, or the token, used to
+	// fetch the index, they are only sent to https sources
+	// +optional
+	SecretRef string `json:"secretRef,omitempty"`
+	// TLS configures the verification of the source server and the client certificate presented to it, for
+	// private mirrors and TLS intercepting proxies
+	// +optional
+	TLS *CatalogTLS `json:"tls,omitempty"`
+	// Interval between syncs of the index, defaults to 30m
+	// +optional
+	Interval *metav1.Duration `json:"interval,omitempty"`
+	// BlockEndOfLife rejects installs of package versions past their end of life, installed addons keep running
+	// +optional
+	BlockEndOfLife bool `json:"blockEndOfLife,omitempty"`
+}
+
+// CatalogTLS configures the TLS client of a catalog source
+type CatalogTLS struct {
+	// SecretRef names a Secret in the catalog namespace with the ca.crt CA bundle trusted in addition to the system
+	// roots, and the tls.crt and tls.key client certificate, each key is optional
+	// +optional
+	SecretRef string `json:"secretRef,omitempty"`
+	// InsecureSkipVerify disables the verification of the server certificate
+	// +optional
+	InsecureSkipVerify bool `json:"insecureSkipVerify,omitempty"`
+}
+
+// Deprecation marks a package or a package version of a catalog as deprecated
+type Deprecation struct {
+	// Message explaining the deprecation, e.g. the replacement package
+	// +optional
+	Message string `json:"message,omitempty"`
+	// EndOfLife is the RFC 3339 time support ends
+	// +optional
+	EndOfLife *metav1.Time `json:"endOfLife,omitempty"`
+}
+
+// IsEndOfLife returns true once the end of life is past
+func (d *Deprecation) IsEndOfLife(now time.Time) bool {
+	return d.EndOfLife != nil && !now.Before(d.EndOfLife.Time)
+}
+
+// CatalogPackage summarizes a package of the catalog index
+type CatalogPackage struct {
+	// Name of the package
+	Name string `json:"name"`
+	// +optional
+	Description string `json:"description,omitempty"`
+	// Versions of the package, highest first
+	Versions []string `json:"versions"`
+	// Channels maps channel names to the package version they point at
+	// +optional
+	Channels map[string]string `json:"channels,omitempty"`
+	// Deprecation of the package
+	// +optional
+	Deprecation *Deprecation `json:"deprecation,omitempty"`
+}
+
+// AddonCatalogStatus defines the observed state of AddonCatalog
+type AddonCatalogStatus struct {
+	// LastSyncTime is the time of the last successful sync
+	// +optional
+	LastSyncTime *metav1.Time `json:"lastSyncTime,omitempty"`
+	// Revision is the sha256 digest of the synced index
+	// +optional
+	Revision string `json:"revision,omitempty"`
+	// Packages of the synced index
+	// +optional
+	Packages []CatalogPackage `json:"packages,omitempty"`
+	// Conditions of the catalog
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// AddonCatalog is an index of addon packages that addons can be installed from by name
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:path=addoncatalogs
+// +kubebuilder:printcolumn:name="TYPE",type="string",JSONPath=".spec.type"
+// +kubebuilder:printcolumn:name="URL",type="string",JSONPath=".spec.url"
+// +kubebuilder:printcolumn:name="LAST SYNC",type="date",JSONPath=".status.lastSyncTime"
+// +kubebuilder:printcolumn:name="AGE",type="date",JSONPath=".metadata.creationTimestamp"
+type AddonCatalog struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   AddonCatalogSpec   `json:"spec,omitempty"`
+	Status AddonCatalogStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// AddonCatalogList contains a list of AddonCatalog
+type AddonCatalogList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []AddonCatalog `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&AddonCatalog{}, &AddonCatalogList{})
+}
@@ -0,0 +1,110 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ProfileReadyCondition is true once every addon of the profile is installed successfully
+const ProfileReadyCondition = "Ready"
+
+// ProfileAddon is a catalog package installed by a profile
+type ProfileAddon struct {
+	// Name of the addon, defaults to the last element of the package name
+	// +optional
+	Name string `json:"name,omitempty"`
+	// PkgName of the catalog package
+	PkgName string `json:"pkgName"`
+	// PkgVersion pins the package version, defaults to the channel or the latest version
+	// +optional
+	PkgVersion string `json:"pkgVersion,omitempty"`
+	// PkgChannel is the catalog channel the addon follows
+	// +optional
+	PkgChannel string `json:"pkgChannel,omitempty"`
+	// Params overlay the profile params, data parameters are merged
+	// +optional
+	Params AddonParams `json:"params,omitempty"`
+}
+
+// AddonProfileSpec defines the catalog packages of a profile
+type AddonProfileSpec struct {
+	// Catalog is the name of the AddonCatalog in the profile namespace the packages are installed from
+	Catalog string `json:"catalog"`
+	// Params are the defaults of every addon of the profile
+	// +optional
+	Params AddonParams `json:"params,omitempty"`
+	// Addons of the profile
+	Addons []ProfileAddon `json:"addons"`
+}
+
+// ProfileAddonStatus is the install status of an addon of a profile
+type ProfileAddonStatus struct {
+	// Name of the addon
+	Name string `json:"name"`
+	// PkgVersion of the installed package
+	// +optional
+	PkgVersion string `json:"pkgVersion,omitempty"`
+	// Installed is the install phase of the addon
+	// +optional
+	Installed ApplicationAssemblyPhase `json:"installed,omitempty"`
+	// Reason of the addon status
+	// +optional
+	Reason string `json:"reason,omitempty"`
+}
+
+// AddonProfileStatus defines the observed state of AddonProfile
+type AddonProfileStatus struct {
+	// Addons of the profile in spec order
+	// +optional
+	Addons []ProfileAddonStatus `json:"addons,omitempty"`
+	// Ready is the number of successfully installed addons out of the profile addons, e.g. 3/5
+	// +optional
+	Ready string `json:"ready,omitempty"`
+	// Conditions of the profile
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// AddonProfile is a set of catalog packages with version pins and params expanded into addons
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:path=addonprofiles
+// +kubebuilder:printcolumn:name="CATALOG",type="string",JSONPath=".spec.catalog"
+// +kubebuilder:printcolumn:name="READY",type="string",JSONPath=".status.ready"
+// +kubebuilder:printcolumn:name="AGE",type="date",JSONPath=".metadata.creationTimestamp"
+type AddonProfile struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   AddonProfileSpec   `json:"spec,omitempty"`
+	Status AddonProfileStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// AddonProfileList contains a list of AddonProfile
+type AddonProfileList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []AddonProfile `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&AddonProfile{}, &AddonProfileList{})
+}
@@ -0,0 +1,55 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// AddonTemplateSpec defines the lifecycle workflows and params shared by the addons referencing the template
+type AddonTemplateSpec struct {
+	// Lifecycle workflows of the addons referencing the template, a workflow set on an addon replaces the template
+	// workflow of the same lifecycle step
+	// +optional
+	Lifecycle LifecycleWorkflowSpec `json:"lifecycle,omitempty"`
+	// Params are the defaults of the addons referencing the template, data parameters are merged
+	// +optional
+	Params AddonParams `json:"params,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// AddonTemplate is a library of lifecycle workflows and default params addons reference by name
+// +kubebuilder:resource:path=addontemplates
+// +kubebuilder:printcolumn:name="AGE",type="date",JSONPath=".metadata.creationTimestamp"
+type AddonTemplate struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec AddonTemplateSpec `json:"spec,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// AddonTemplateList contains a list of AddonTemplate
+type AddonTemplateList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []AddonTemplate `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&AddonTemplate{}, &AddonTemplateList{})
+}
@@ -0,0 +1,122 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package v1alpha1
+
+import (
+	"bytes"
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/json"
+)
+
+// legacyAddonSpec has the fields of the AddonSpec checksums were first calculated from, formatting it reproduces the
+// checksums of addons installed before the spec was extended
+// +kubebuilder:object:generate=false
+type legacyAddonSpec struct {
+	PackageSpec PackageSpec
+	Params      legacyAddonParams
+	Selector    metav1.LabelSelector
+	Overrides   AddonOverridesSpec
+	Secrets     []legacySecretCmdSpec
+	Lifecycle   legacyLifecycleWorkflowSpec
+}
+
+// +kubebuilder:object:generate=false
+type legacyAddonParams struct {
+	Namespace string
+	Context   ClusterContext
+	Data      map[string]FlexString
+}
+
+// +kubebuilder:object:generate=false
+type legacySecretCmdSpec struct {
+	Name string
+	Cmd  CmdType
+	Args []string
+}
+
+// +kubebuilder:object:generate=false
+type legacyLifecycleWorkflowSpec struct {
+	Prereqs  legacyWorkflowType
+	Install  legacyWorkflowType
+	Delete   legacyWorkflowType
+	Validate legacyWorkflowType
+}
+
+// +kubebuilder:object:generate=false
+type legacyWorkflowType struct {
+	NamePrefix   string
+	Role         string
+	WorkflowRole string
+	Template     string
+}
+
+// emptySpec is the JSON encoding of a spec without fields
+var emptySpec, _ = json.Marshal(AddonSpec{})
+
+// checksumContent returns the content the checksum of the spec is calculated from. The fields the spec was first
+// released with are formatted as before so upgrading the manager does not reinstall addons, fields added since are
+// JSON encoded since formatting would print pointer fields as addresses.
+func checksumContent(s AddonSpec) []byte {
+	legacy, rest := splitLegacySpec(s)
+	content := []byte(fmt.Sprintf("%+v", legacy))
+	ext, err := json.Marshal(rest)
+	if err != nil {
+		return append(content, fmt.Sprintf("%+v", rest)...)
+	}
+	if !bytes.Equal(ext, emptySpec) {
+		content = append(content, ext...)
+	}
+	return content
+}
+
+// splitLegacySpec returns the legacy fields of the spec and the spec without them
+func splitLegacySpec(s AddonSpec) (legacyAddonSpec, AddonSpec) {
+	legacy := legacyAddonSpec{
+		PackageSpec: s.PackageSpec,
+		Params:      legacyAddonParams{Namespace: s.Params.Namespace, Context: s.Params.Context, Data: s.Params.Data},
+		Selector:    s.Selector,
+		Overrides:   s.Overrides,
+		Lifecycle: legacyLifecycleWorkflowSpec{
+			Prereqs:  legacyWorkflow(&s.Lifecycle.Prereqs),
+			Install:  legacyWorkflow(&s.Lifecycle.Install),
+			Delete:   legacyWorkflow(&s.Lifecycle.Delete),
+			Validate: legacyWorkflow(&s.Lifecycle.Validate),
+		},
+	}
+	s.PackageSpec, s.Selector, s.Overrides = PackageSpec{}, metav1.LabelSelector{}, AddonOverridesSpec{}
+	s.Params.Namespace, s.Params.Context, s.Params.Data = "", ClusterContext{}, nil
+
+	secrets := s.Secrets
+	s.Secrets = nil
+	for i, secret := range secrets {
+		legacy.Secrets = append(legacy.Secrets, legacySecretCmdSpec{Name: secret.Name, Cmd: secret.Cmd, Args: secret.Args})
+		if secret.From != nil {
+			if s.Secrets == nil {
+				s.Secrets = make([]SecretCmdSpec, len(secrets))
+			}
+			s.Secrets[i].From = secret.From
+		}
+	}
+	return legacy, s
+}
+
+// legacyWorkflow returns the legacy fields of the workflow type and clears them
+func legacyWorkflow(wt *WorkflowType) legacyWorkflowType {
+	legacy := legacyWorkflowType{NamePrefix: wt.NamePrefix, Role: wt.Role, WorkflowRole: wt.WorkflowRole, Template: wt.Template}
+	wt.NamePrefix, wt.Role, wt.WorkflowRole, wt.Template = "", "", "", ""
+	return legacy
+}
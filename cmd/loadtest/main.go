@@ -0,0 +1,48 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/keikoproj/addon-manager/pkg/loadtest"
+)
+
+func main() {
+	var cfg loadtest.Config
+	var timeout time.Duration
+	flag.IntVar(&cfg.Addons, "addons", 1000, "Number of addons installed by the simulation.")
+	flag.IntVar(&cfg.Workers, "workers", 5, "Number of addons reconciled in parallel.")
+	flag.DurationVar(&cfg.WorkflowDuration, "workflow-duration", time.Second, "How long fake install workflows run before they succeed.")
+	flag.IntVar(&cfg.TemplateSize, "template-size", 16*1024, "Number of bytes embedded in every install workflow template.")
+	flag.DurationVar(&timeout, "timeout", 10*time.Minute, "Maximum duration of the simulation.")
+	flag.Parse()
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	result, err := loadtest.Run(ctx, cfg)
+	if result != nil {
+		_ = result.Write(os.Stdout)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "load test failed. %v\n", err)
+		os.Exit(1)
+	}
+}
@@ -16,7 +16,11 @@ package controllers
 
 import (
 	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"strconv"
 	"strings"
 	"time"
 
@@ -26,19 +30,28 @@ import (
 	batchv1 "k8s.io/api/batch/v1"
 	batchv1beta1 "k8s.io/api/batch/v1beta1"
 	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/fields"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/clock"
+	"k8s.io/client-go/discovery"
 	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/dynamic/dynamicinformer"
 	"k8s.io/client-go/informers"
+	corev1informers "k8s.io/client-go/informers/core/v1"
 	"k8s.io/client-go/kubernetes"
+	toolscache "k8s.io/client-go/tools/cache"
 	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/cache"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
@@ -46,13 +59,55 @@ import (
 
 	addonmgrv1alpha1 "github.com/keikoproj/addon-manager/api/v1alpha1"
 	"github.com/keikoproj/addon-manager/pkg/addon"
+	"github.com/keikoproj/addon-manager/pkg/audit"
+	"github.com/keikoproj/addon-manager/pkg/catalog"
 	"github.com/keikoproj/addon-manager/pkg/common"
+	"github.com/keikoproj/addon-manager/pkg/deps"
+	"github.com/keikoproj/addon-manager/pkg/faults"
+	"github.com/keikoproj/addon-manager/pkg/fleet"
+	"github.com/keikoproj/addon-manager/pkg/gitops"
+	"github.com/keikoproj/addon-manager/pkg/inventory"
+	"github.com/keikoproj/addon-manager/pkg/metrics"
+	"github.com/keikoproj/addon-manager/pkg/netpol"
+	"github.com/keikoproj/addon-manager/pkg/notify"
+	"github.com/keikoproj/addon-manager/pkg/provenance"
+	"github.com/keikoproj/addon-manager/pkg/rbac"
+	"github.com/keikoproj/addon-manager/pkg/redact"
+	"github.com/keikoproj/addon-manager/pkg/remote"
+	"github.com/keikoproj/addon-manager/pkg/revision"
+	"github.com/keikoproj/addon-manager/pkg/secrets"
+	"github.com/keikoproj/addon-manager/pkg/shard"
+	"github.com/keikoproj/addon-manager/pkg/sops"
 	"github.com/keikoproj/addon-manager/pkg/workflows"
 )
 
 // addon ttl time
 const TTL int64 = 180000
 
+// remotePollInterval is how often workflows running in remote clusters are checked
+const remotePollInterval = 15 * time.Second
+
+// gitopsPollInterval is how often the status of GitOps objects is checked until the addon completes
+const gitopsPollInterval = 30 * time.Second
+
+// dependencyResyncInterval is how often addons waiting on dependencies are checked when no dependency change is seen
+const dependencyResyncInterval = time.Minute
+
+// pkgNameField indexes cached addons by package name
+const pkgNameField = "spec.pkgName"
+
+// fleetSyncInterval is how often the clusters selected by fleet addons are listed
+const fleetSyncInterval = time.Minute
+
+// notifyTimeout bounds the delivery of a lifecycle notification to all receivers including retries
+const notifyTimeout = time.Minute
+
+// requiredByAnnotation names the addon a dependency was installed from the catalog for
+const requiredByAnnotation = "addonmgr.keikoproj.io/required-by"
+
+// managedNamespace is the namespace workflows of addons are watched and cached in
+const managedNamespace = "addon-manager-system"
+
 // Watched resources
 var (
 	resources = [...]runtime.Object{
@@ -72,34 +127,316 @@ type AddonReconciler struct {
 	client.Client
 	Log             logr.Logger
 	Scheme          *runtime.Scheme
+	Notifier        notify.Notifier
 	versionCache    addon.VersionCacheClient
+	checksums       *addon.ChecksumCache
+	debouncer       *addon.Debouncer
+	shard           *shard.Shard
 	dynClient       dynamic.Interface
 	generatedClient *kubernetes.Clientset
 	recorder        record.EventRecorder
+	auditor         *audit.Recorder
+	history         *revision.History
+	templates       *addon.TemplateStore
+	catalog         *catalog.Catalog
+	catalogs        *catalog.Store
+	secrets         *secrets.Materializer
+	sops            *sops.Decryptor
+	imageVerifier   workflows.ImageVerifier
+	provenance      *provenance.Verifier
+	serviceAccounts rbac.Provisioner
+	securityContext *workflows.SecurityContextDefaults
+	propagation     workflows.PropagationPolicy
+	logArchive      workflows.LogArchive
+	ownerMode       workflows.OwnerMode
+	workflowGC      workflows.GCPolicy
+	failureBudget   addon.FailureBudget
+	commonParams    types.NamespacedName
+	networkPolicies *netpol.Generator
+	redactor        *redact.Redactor
+	cloudProvider   common.CloudProvider
+	saChecker       workflows.ServiceAccountChecker
+	secretParams    bool
+	clusters        *remote.Resolver
+	fleet           *fleet.Syncer
+	clusterHooks    bool
+	addonTemplates  bool
+	gitops          gitops.Generator
+	hub             bool
+	kubeVersions    addon.KubeVersionPolicy
+	validateGate    bool
+	workflowNs      string
+	maxConcurrent   int
+	simulator       *workflows.Simulator
+	faults          *faults.Injector
+	clock           clock.Clock
+	// workflowInformer caches the workflows in the managed namespace, set up with the manager
+	workflowInformer toolscache.SharedIndexInformer
+	// paramsInformer caches the ConfigMaps labelled as SOPS params, set up with the manager
+	paramsInformer corev1informers.ConfigMapInformer
+	// commonParamsInformer caches the common params ConfigMap, set up with the manager
+	commonParamsInformer corev1informers.ConfigMapInformer
 }
 
 // NewAddonReconciler returns an instance of AddonReconciler
 func NewAddonReconciler(mgr manager.Manager, log logr.Logger) *AddonReconciler {
-	return &AddonReconciler{
+	generatedClient := kubernetes.NewForConfigOrDie(mgr.GetConfig())
+	dynClient := dynamic.NewForConfigOrDie(mgr.GetConfig())
+	redactor := redact.New()
+	r := &AddonReconciler{
 		Client:          mgr.GetClient(),
-		Log:             log,
+		Log:             redact.NewLogger(log, redactor),
 		Scheme:          mgr.GetScheme(),
 		versionCache:    addon.NewAddonVersionCacheClient(),
-		dynClient:       dynamic.NewForConfigOrDie(mgr.GetConfig()),
-		generatedClient: kubernetes.NewForConfigOrDie(mgr.GetConfig()),
-		recorder:        mgr.GetEventRecorderFor("addons"),
+		checksums:       addon.NewChecksumCache(),
+		dynClient:       dynClient,
+		generatedClient: generatedClient,
+		recorder:        redact.NewEventRecorder(mgr.GetEventRecorderFor("addons"), redactor),
+		auditor:         audit.NewAuditRecorder(generatedClient),
+		history:         revision.NewHistory(generatedClient),
+		templates:       addon.NewTemplateStore(generatedClient, addon.DefaultTemplateOffloadThreshold),
+		redactor:        redactor,
+		clusters:        remote.NewResolver(generatedClient, mgr.GetScheme()),
+		fleet:           fleet.NewSyncer(mgr.GetClient(), dynClient, mgr.GetScheme()),
+		clock:           clock.RealClock{},
+	}
+	r.SetSecretStores(nil, nil)
+	return r
+}
+
+// SetImageVerifier configures verification of workflow container images before submission
+func (r *AddonReconciler) SetImageVerifier(v workflows.ImageVerifier) {
+	r.imageVerifier = v
+}
+
+// SetProvenanceVerifier requires addon packages to be signed by a trusted key before install
+func (r *AddonReconciler) SetProvenanceVerifier(v *provenance.Verifier) {
+	r.provenance = v
+}
+
+// SetClusterLifecycleHooks watches Cluster API clusters to install fleet addons once clusters are ready and holds
+// clusters until member addons ran their delete workflows, must be called before SetupWithManager
+func (r *AddonReconciler) SetClusterLifecycleHooks(enabled bool) {
+	r.clusterHooks = enabled
+	r.fleet.SetLifecycleHooks(enabled)
+}
+
+// SetAddonTemplates completes the specs of addons with spec.templateRef from their AddonTemplate and watches
+// AddonTemplates, the AddonTemplate CRD must be installed. Must be called before SetupWithManager.
+func (r *AddonReconciler) SetAddonTemplates(enabled bool) {
+	r.addonTemplates = enabled
+}
+
+// SetCatalog enables creating the catalog addons of missing dependencies for addons with an IfNotPresent policy
+func (r *AddonReconciler) SetCatalog(c *catalog.Catalog) {
+	r.catalog = c
+}
+
+// SetCatalogStore sets the synced AddonCatalogs addons with spec.catalog are rendered from
+func (r *AddonReconciler) SetCatalogStore(s *catalog.Store) {
+	r.catalogs = s
+}
+
+// SetGitOpsGenerator renders addons as GitOps objects instead of submitting lifecycle workflows
+func (r *AddonReconciler) SetGitOpsGenerator(g gitops.Generator) {
+	r.gitops = g
+}
+
+// SetHubGenerator delivers addons to the clusters they target through a multi-cluster hub, the hub selects the
+// clusters instead of fleets and remote kubeconfigs
+func (r *AddonReconciler) SetHubGenerator(g gitops.Generator) {
+	r.gitops = g
+	r.hub = true
+}
+
+// SetServiceAccountProvisioner configures a dedicated workflow service account per addon, annotated with the workflow role
+func (r *AddonReconciler) SetServiceAccountProvisioner(p rbac.Provisioner) {
+	r.serviceAccounts = p
+}
+
+// SetSecurityContextDefaults configures the security context injected into workflow and artifact pods
+func (r *AddonReconciler) SetSecurityContextDefaults(d workflows.SecurityContextDefaults) {
+	r.securityContext = &d
+}
+
+// SetPropagationPolicy copies the addon labels and annotations selected by p onto its workflows and artifacts
+func (r *AddonReconciler) SetPropagationPolicy(p workflows.PropagationPolicy) {
+	r.propagation = p
+}
+
+// SetLogArchive archives the logs of the lifecycle workflows in the artifact repository, addons override it with
+// spec.lifecycle.archiveLogs
+func (r *AddonReconciler) SetLogArchive(a workflows.LogArchive) {
+	r.logArchive = a
+}
+
+// SetCommonParams merges the data of the ConfigMap into the global parameters of the workflows of every addon. The
+// ConfigMap is cached by an informer watching it alone, a change reconciles every addon and installs it again with
+// the new params. Must be called before SetupWithManager.
+func (r *AddonReconciler) SetCommonParams(ref types.NamespacedName) {
+	r.commonParams = ref
+}
+
+// SetWorkflowOwnerMode sets how the workflows of addons reference their addon
+func (r *AddonReconciler) SetWorkflowOwnerMode(m workflows.OwnerMode) {
+	r.ownerMode = m
+}
+
+// SetWorkflowGCPolicy sets when the succeeded workflows of addons are deleted
+func (r *AddonReconciler) SetWorkflowGCPolicy(p workflows.GCPolicy) {
+	r.workflowGC = p
+}
+
+// SetFailureBudget stops submitting the workflows of addons whose install fails more often than the budget allows
+// until they are resumed, retries of failed installs back off
+func (r *AddonReconciler) SetFailureBudget(b addon.FailureBudget) {
+	r.failureBudget = b
+}
+
+// SetNetworkPolicyGenerator enables the baseline network policies declared by addons in their target namespace
+func (r *AddonReconciler) SetNetworkPolicyGenerator(g *netpol.Generator) {
+	r.networkPolicies = g
+}
+
+// SetServiceAccountChecker blocks workflows of addons that are not cluster scoped when their service account fails the check
+func (r *AddonReconciler) SetServiceAccountChecker(c workflows.ServiceAccountChecker) {
+	r.saChecker = c
+}
+
+// SetSecretParams passes decrypted SOPS params to workflow containers from a Secret instead of workflow parameters
+func (r *AddonReconciler) SetSecretParams(enabled bool) {
+	r.secretParams = enabled
+}
+
+// SetCloudProvider selects how workflow roles map to workload identities
+func (r *AddonReconciler) SetCloudProvider(p common.CloudProvider) {
+	r.cloudProvider = p
+}
+
+// SetKubeVersionPolicy sets how installs of packages that do not support the target cluster version are handled
+func (r *AddonReconciler) SetKubeVersionPolicy(p addon.KubeVersionPolicy) {
+	r.kubeVersions = p
+}
+
+// SetValidateGate requires the validate workflow to succeed after in-place installs before addons are Succeeded,
+// addons override it with spec.lifecycle.validateGate
+func (r *AddonReconciler) SetValidateGate(enabled bool) {
+	r.validateGate = enabled
+}
+
+// SetWorkflowNamespace runs the workflows of addons in the local cluster in namespace instead of the addon namespace
+// so workflow pods do not consume the quotas of tenants, the workflows are deleted by the addon finalizer
+func (r *AddonReconciler) SetWorkflowNamespace(namespace string) {
+	r.workflowNs = namespace
+}
+
+// informerNamespace returns the namespace workflows are watched and cached in
+func (r *AddonReconciler) informerNamespace() string {
+	if r.workflowNs != "" {
+		return r.workflowNs
+	}
+	return managedNamespace
+}
+
+// SetMaxConcurrentReconciles sets how many addons are reconciled in parallel, addons waiting on dependencies
+// do not hold workers. Must be called before SetupWithManager.
+func (r *AddonReconciler) SetMaxConcurrentReconciles(n int) {
+	r.maxConcurrent = n
+}
+
+// SetSpecDebounce delays the workflows of edited addons until their spec did not change for window
+func (r *AddonReconciler) SetSpecDebounce(window time.Duration) {
+	r.debouncer = addon.NewDebouncer(window)
+	r.debouncer.SetClock(r.clock)
+}
+
+// SetTemplateOffloadThreshold offloads the workflow templates of addons larger than threshold bytes to ConfigMaps,
+// zero never offloads templates
+func (r *AddonReconciler) SetTemplateOffloadThreshold(threshold int) {
+	r.templates = addon.NewTemplateStore(r.generatedClient, threshold)
+}
+
+// SetWorkflowSimulator runs workflows with the simulator instead of Argo, workflows are not watched
+func (r *AddonReconciler) SetWorkflowSimulator(s *workflows.Simulator) {
+	r.simulator = s
+	if s != nil {
+		s.SetClock(r.clock)
+	}
+}
+
+// SetClock sets the clock install TTLs, debounce windows, fleet soak times and simulated workflows are measured with
+// and status, audit and notification timestamps are taken from
+func (r *AddonReconciler) SetClock(c clock.Clock) {
+	r.clock = c
+	r.fleet.SetClock(c)
+	r.auditor.SetClock(c)
+	r.debouncer.SetClock(c)
+	if r.simulator != nil {
+		r.simulator.SetClock(c)
 	}
 }
 
+// SetFaultInjector injects workflow and API write failures for chaos testing
+func (r *AddonReconciler) SetFaultInjector(i *faults.Injector) {
+	r.faults = i
+	r.Client = i.Client(r.Client)
+}
+
+// SetShard only processes the addons in namespaces of the shard, addons of other shards are cached as dependencies
+func (r *AddonReconciler) SetShard(s *shard.Shard) {
+	r.shard = s
+}
+
+// SetSopsDecryptor configures the decryptor of SOPS encrypted addon params
+func (r *AddonReconciler) SetSopsDecryptor(d *sops.Decryptor) {
+	r.sops = d
+}
+
+// SetSecretStores configures the external stores addon secrets can be fetched from, stores may be nil
+func (r *AddonReconciler) SetSecretStores(vault, secretsManager secrets.Store) {
+	r.secrets = secrets.NewMaterializer(r.generatedClient, vault, secretsManager)
+	r.secrets.SetRedactor(r.redactor)
+	r.secrets.SetDynamicClient(r.dynClient)
+}
+
+// SetSecretSourcePolicy restricts the external secret sources addons may read and sets how often unchanged secrets are
+// fetched again, it must be called after SetSecretStores
+func (r *AddonReconciler) SetSecretSourcePolicy(policy secrets.SourcePolicy, refresh time.Duration) {
+	r.secrets.SetSourcePolicy(policy)
+	r.secrets.SetRefreshInterval(refresh)
+}
+
 // +kubebuilder:rbac:groups=addonmgr.keikoproj.io,resources=addons,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=addonmgr.keikoproj.io,resources=addons/status,verbs=get;update;patch
-// +kubebuilder:rbac:groups=argoproj.io,resources=workflows,namespace=system,verbs=get;list;watch;create;update;patch;delete
-// +kubebuilder:rbac:groups=core,resources=secrets,verbs=list
+// +kubebuilder:rbac:groups=addonmgr.keikoproj.io,resources=addontemplates,verbs=get;list;watch
+// +kubebuilder:rbac:groups=argoproj.io,resources=workflows,namespace=system,verbs=get;list;watch;create;update;patch;delete;deletecollection
+// +kubebuilder:rbac:groups=argoproj.io,resources=applications,verbs=get;list;create;update;delete
+// +kubebuilder:rbac:groups=source.toolkit.fluxcd.io,resources=helmrepositories;gitrepositories,verbs=get;list;create;update;delete
+// +kubebuilder:rbac:groups=helm.toolkit.fluxcd.io,resources=helmreleases,verbs=get;list;create;update;delete
+// +kubebuilder:rbac:groups=kustomize.toolkit.fluxcd.io,resources=kustomizations,verbs=get;list;create;update;delete
+// +kubebuilder:rbac:groups=core,resources=secrets,verbs=get;list;create;update;patch
+// +kubebuilder:rbac:groups=apps,resources=controllerrevisions,verbs=get;list;create;update;delete
 // +kubebuilder:rbac:groups=rbac.authorization.k8s.io,resources=clusterroles;clusterrolebindings,verbs=get;list;patch;create
+// +kubebuilder:rbac:groups=rbac.authorization.k8s.io,resources=roles;rolebindings;clusterroles;clusterrolebindings,verbs=get;list;create;update;delete;deletecollection
+// Generated workflow roles only grant rules the manager holds and are bound without bind. The shared cluster role of
+// --workflow-service-accounts is the only role bound on top, operators naming it other than addon-manager-workflow
+// change the resourceNames of the bind rule in config/rbac/role.yaml.
+// +kubebuilder:rbac:groups=rbac.authorization.k8s.io,resources=clusterroles,resourceNames=addon-manager-workflow,verbs=bind
+// +kubebuilder:rbac:groups="",resources=pods/log,verbs=get;watch
+// +kubebuilder:rbac:groups="",resources=serviceaccounts,verbs=delete;deletecollection
+// +kubebuilder:rbac:groups="",resources=configmaps;services,verbs=delete
+// +kubebuilder:rbac:groups=bitnami.com,resources=sealedsecrets,verbs=get;list;create;update
+// +kubebuilder:rbac:groups=cluster.x-k8s.io,resources=clusters,verbs=get;list;watch;update
+// +kubebuilder:rbac:groups=cluster.open-cluster-management.io,resources=managedclusters,verbs=get;list
+// +kubebuilder:rbac:groups=work.open-cluster-management.io,resources=manifestworks,verbs=get;list;create;update;delete
+// +kubebuilder:rbac:groups=policy.karmada.io,resources=clusterpropagationpolicies,verbs=get;list;create;update;delete
+// +kubebuilder:rbac:groups=work.karmada.io,resources=resourcebindings;clusterresourcebindings,verbs=get;list
+// +kubebuilder:rbac:groups=authorization.k8s.io,resources=subjectaccessreviews,verbs=create
+// +kubebuilder:rbac:groups=networking.k8s.io,resources=networkpolicies,verbs=get;list;create;update;delete
 // +kubebuilder:rbac:groups="",resources=namespaces;clusterroles;configmaps;events;pods;serviceaccounts;services,verbs=get;list;watch;create;update;patch
-// +kubebuilder:rbac:groups=apps,resources=deployments;daemonsets;replicasets;statefulsets,verbs=get;list;watch;create;update;patch
+// +kubebuilder:rbac:groups=apps,resources=deployments;daemonsets;replicasets;statefulsets,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=extensions,resources=deployments;daemonsets;replicasets;ingresses,verbs=get;list;watch;create;update;patch
-// +kubebuilder:rbac:groups=batch,resources=jobs;cronjobs,verbs=get;list;watch;create;update;patch
+// +kubebuilder:rbac:groups=batch,resources=jobs;cronjobs,verbs=get;list;watch;create;update;patch;delete
 
 // Reconcile method for all addon requests
 func (r *AddonReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
@@ -110,15 +447,29 @@ func (r *AddonReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 	var instance = &addonmgrv1alpha1.Addon{}
 	if err := r.Get(context.TODO(), req.NamespacedName, instance); err != nil {
 		log.Info("Addon not found.")
+		r.checksums.Remove(req.NamespacedName)
+		r.debouncer.Forget(req.NamespacedName)
+		if r.sops != nil {
+			r.sops.Forget(req.NamespacedName)
+		}
 
 		// Remove version from cache
 		if ok, v := r.versionCache.HasVersionName(req.Name); ok {
 			r.versionCache.RemoveVersion(v.PkgName, v.PkgVersion)
+			metrics.RecordDependencyGraph(addon.GetDependencyGraphStats(r.versionCache))
 		}
 
 		return reconcile.Result{}, ignoreNotFound(err)
 	}
 
+	// Addons of other shards satisfy the dependencies of the addons of this shard
+	if !r.shard.Owns(req.Namespace) {
+		if instance.Spec.Target == nil {
+			r.addAddonToCache(instance)
+		}
+		return reconcile.Result{}, nil
+	}
+
 	return r.execAddon(ctx, req, log, instance)
 }
 
@@ -128,17 +479,70 @@ func (r *AddonReconciler) execAddon(ctx context.Context, req reconcile.Request,
 			log.Info("Error: Panic occurred during execAdd %s/%s due to %s", instance.Namespace, instance.Name, err)
 		}
 	}()
+	prevStatus := instance.Status.DeepCopy()
+	prevPhase := instance.Status.Lifecycle.Installed
+	prevStartTime := instance.Status.StartTime
+
 	// Process addon instance
-	ret, procErr := r.processAddon(ctx, req, log, instance)
+	var ret reconcile.Result
+	var procErr error
+	if applied, res, err := r.applyAddonTemplate(ctx, log, instance); !applied {
+		ret, procErr = res, err
+	} else if resolved, res, err := r.offloadTemplates(ctx, log, instance); !resolved {
+		ret, procErr = res, err
+	} else if ready, res, err := r.rollbackAddon(ctx, log, instance); !ready {
+		ret, procErr = res, err
+	} else if rendered, res, err := r.renderCatalogAddon(ctx, log, instance); !rendered {
+		ret, procErr = res, err
+	} else if fleet.IsFleet(instance) && !r.hub {
+		ret, procErr = r.processFleet(ctx, log, instance)
+	} else {
+		ret, procErr = r.processAddon(ctx, req, log, instance)
+	}
+	instance.Status.Reason = r.redactor.String(instance.Status.Reason)
+
+	// Tell addons installed with an outdated spec from addons in sync
+	if instance.ObjectMeta.DeletionTimestamp.IsZero() {
+		r.setSyncCondition(instance)
+	}
+	instance.Status.Lifecycle.Skipped = instance.SkippedSteps()
+
+	// Keep the applied specs for rollbacks
+	if instance.Status.Lifecycle.Installed == addonmgrv1alpha1.Succeeded && prevPhase != addonmgrv1alpha1.Succeeded {
+		if cr, err := r.history.Record(ctx, instance); err != nil {
+			log.Error(err, "Failed to record addon spec revision.")
+		} else {
+			instance.Status.Revision = cr.Revision
+		}
+	}
 
 	// Always update cache, status
-	r.addAddonToCache(instance)
+	// Addons installed in other clusters do not satisfy or conflict with local packages
+	if instance.Spec.Target == nil {
+		r.addAddonToCache(instance)
+	}
+
+	// Record metrics for dashboards
+	metrics.RecordDependencyGraph(addon.GetDependencyGraphStats(r.versionCache))
+	phaseChanged := instance.Status.Lifecycle.Installed != prevPhase
+	if phaseChanged {
+		metrics.RecordInstallResult(instance.Spec.PkgName, instance.Status.Lifecycle.Installed)
+		metrics.RecordPhaseTransition(instance)
+	}
 
-	err := r.updateAddonStatus(ctx, log, instance)
+	err := r.updateAddonStatus(ctx, log, instance, prevStatus)
 	if err != nil {
 		// Force retry when status fails to update
+		metrics.RecordReconcileError(instance)
 		return reconcile.Result{RequeueAfter: 1 * time.Second}, err
 	}
+	// Only transitions that were saved are notified, a failed status update is retried and notifies then
+	if phaseChanged {
+		r.notifyPhaseChange(log, instance, prevPhase, prevStartTime)
+	}
+	if procErr != nil {
+		metrics.RecordReconcileError(instance)
+	}
 
 	return ret, procErr
 }
@@ -146,25 +550,203 @@ func (r *AddonReconciler) execAddon(ctx context.Context, req reconcile.Request,
 // SetupWithManager is called to setup manager and watchers
 func (r *AddonReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	log := r.Log
-	managedNS := "addon-manager-system"
 
-	nsInformers := dynamicinformer.NewFilteredDynamicSharedInformerFactory(r.dynClient, time.Minute*30, managedNS, nil)
-	wfInf := nsInformers.ForResource(common.WorkflowGVR())
+	// Addons of a dependency package are looked up by index
+	if err := mgr.GetFieldIndexer().IndexField(context.TODO(), &addonmgrv1alpha1.Addon{}, pkgNameField, func(obj runtime.Object) []string {
+		a, ok := obj.(*addonmgrv1alpha1.Addon)
+		if !ok || a.Spec.PkgName == "" {
+			return nil
+		}
+		return []string{a.Spec.PkgName}
+	}); err != nil {
+		return err
+	}
 	bldr := ctrl.NewControllerManagedBy(mgr).
+		WithOptions(controller.Options{MaxConcurrentReconciles: r.maxConcurrent}).
 		For(&addonmgrv1alpha1.Addon{}).
+		// Watch member addons of fleet addons
+		Owns(&addonmgrv1alpha1.Addon{}).
+		// Reconcile dependents when a dependency changes so they proceed once it succeeds
+		Watches(&source.Kind{Type: &addonmgrv1alpha1.Addon{}}, &handler.EnqueueRequestsFromMapFunc{
+			ToRequests: handler.ToRequestsFunc(func(a handler.MapObject) []reconcile.Request {
+				dependency, ok := a.Object.(*addonmgrv1alpha1.Addon)
+				if !ok {
+					return nil
+				}
+				reqs := r.dependentRequests(dependency.Spec.PkgName)
+				// Addons requiring the same packages report conflicts with the addon
+				for name := range dependency.Spec.PkgDeps {
+					reqs = append(reqs, r.dependentRequests(strings.TrimSpace(name))...)
+				}
+				return reqs
+			}),
+		})
+
+	// Workflow status checks are served from the informer cache, workflows of an addon are looked up by index.
+	// Templates and artifact data of large workflows are not cached. Simulated workflows do not exist.
+	var wfInf toolscache.SharedIndexInformer
+	if r.simulator == nil {
+		wfInf = workflows.NewWorkflowInformer(r.dynClient, r.informerNamespace(), time.Minute*30)
+		r.workflowInformer = wfInf
 		// Watch workflows created by addon only in addon-manager-system namespace
-		Watches(&source.Informer{Informer: wfInf.Informer().(cache.Informer)}, &handler.EnqueueRequestForOwner{
-			IsController: true,
+		bldr = bldr.Watches(&source.Informer{Informer: wfInf}, &handler.EnqueueRequestForOwner{
+			IsController: r.ownerMode != workflows.LooseOwner,
 			OwnerType:    &addonmgrv1alpha1.Addon{},
 		})
+		if r.workflowNs != "" {
+			// Workflows outside of the addon namespace are labelled with their addon instead of owned by it
+			bldr = bldr.Watches(&source.Informer{Informer: wfInf}, &handler.EnqueueRequestsFromMapFunc{
+				ToRequests: handler.ToRequestsFunc(func(a handler.MapObject) []reconcile.Request {
+					name, ok := workflows.AddonOf(a.Meta)
+					if !ok || name.Namespace == a.Meta.GetNamespace() {
+						return nil
+					}
+					return []reconcile.Request{{NamespacedName: name}}
+				}),
+			})
+		}
+	}
+
+	var clusterInformers dynamicinformer.DynamicSharedInformerFactory
+	if r.clusterHooks {
+		clusterInformers = dynamicinformer.NewDynamicSharedInformerFactory(r.dynClient, time.Minute*30)
+		clusterInf := clusterInformers.ForResource(common.ClusterGVR())
+		// Reconcile the fleet addons in the namespace of a cluster when it changes
+		bldr = bldr.Watches(&source.Informer{Informer: clusterInf.Informer().(cache.Informer)}, &handler.EnqueueRequestsFromMapFunc{
+			ToRequests: handler.ToRequestsFunc(func(a handler.MapObject) []reconcile.Request {
+				var addons addonmgrv1alpha1.AddonList
+				if err := r.List(context.TODO(), &addons, client.InNamespace(a.Meta.GetNamespace())); err != nil {
+					log.Error(err, "failed to list addons for cluster", "cluster", a.Meta.GetName())
+					return nil
+				}
+				var reqs = make([]reconcile.Request, 0)
+				for _, item := range addons.Items {
+					if fleet.IsFleet(&item) {
+						reqs = append(reqs, reconcile.Request{NamespacedName: types.NamespacedName{Name: item.Name, Namespace: item.Namespace}})
+					}
+				}
+				return reqs
+			}),
+		})
+	}
+
+	if r.catalogs != nil {
+		// Render the addons of a catalog again when its index is synced
+		bldr = bldr.Watches(&source.Kind{Type: &addonmgrv1alpha1.AddonCatalog{}}, &handler.EnqueueRequestsFromMapFunc{
+			ToRequests: handler.ToRequestsFunc(func(a handler.MapObject) []reconcile.Request {
+				var addons addonmgrv1alpha1.AddonList
+				if err := r.List(context.TODO(), &addons, client.InNamespace(a.Meta.GetNamespace())); err != nil {
+					log.Error(err, "failed to list addons for catalog", "catalog", a.Meta.GetName())
+					return nil
+				}
+				var reqs = make([]reconcile.Request, 0)
+				for _, item := range addons.Items {
+					if item.Spec.Catalog == a.Meta.GetName() {
+						reqs = append(reqs, reconcile.Request{NamespacedName: types.NamespacedName{Name: item.Name, Namespace: item.Namespace}})
+					}
+				}
+				return reqs
+			}),
+		})
+	}
+
+	if r.addonTemplates {
+		// Apply AddonTemplates again to the addons referencing them when they change
+		bldr = bldr.Watches(&source.Kind{Type: &addonmgrv1alpha1.AddonTemplate{}}, &handler.EnqueueRequestsFromMapFunc{
+			ToRequests: handler.ToRequestsFunc(func(a handler.MapObject) []reconcile.Request {
+				var addons addonmgrv1alpha1.AddonList
+				if err := r.List(context.TODO(), &addons, client.InNamespace(a.Meta.GetNamespace())); err != nil {
+					log.Error(err, "failed to list addons for template", "template", a.Meta.GetName())
+					return nil
+				}
+				var reqs = make([]reconcile.Request, 0)
+				for _, item := range addons.Items {
+					if item.Spec.TemplateRef == a.Meta.GetName() {
+						reqs = append(reqs, reconcile.Request{NamespacedName: types.NamespacedName{Name: item.Name, Namespace: item.Namespace}})
+					}
+				}
+				return reqs
+			}),
+		})
+	}
 
 	generatedInformers = informers.NewSharedInformerFactory(r.generatedClient, time.Minute*30)
 
+	// Only the ConfigMaps labelled as SOPS params are cached
+	var paramsInformers informers.SharedInformerFactory
+	if r.sops != nil {
+		paramsInformers = informers.NewSharedInformerFactoryWithOptions(r.generatedClient, time.Minute*30,
+			informers.WithTweakListOptions(func(o *metav1.ListOptions) {
+				o.LabelSelector = addonmgrv1alpha1.ParamsLabel
+			}))
+		r.paramsInformer = paramsInformers.Core().V1().ConfigMaps()
+
+		// Install addons again when their SOPS params document changes, its digest is part of the checksum
+		cmInf := r.paramsInformer.Informer()
+		bldr = bldr.Watches(&source.Informer{Informer: cmInf.(cache.Informer)}, &handler.EnqueueRequestsFromMapFunc{
+			ToRequests: handler.ToRequestsFunc(func(a handler.MapObject) []reconcile.Request {
+				var addons addonmgrv1alpha1.AddonList
+				if err := r.List(context.TODO(), &addons, client.InNamespace(a.Meta.GetNamespace())); err != nil {
+					log.Error(err, "failed to list addons for configmap", "configmap", a.Meta.GetName())
+					return nil
+				}
+				var reqs = make([]reconcile.Request, 0)
+				for _, item := range addons.Items {
+					if item.Spec.Params.Sops != nil && item.Spec.Params.Sops.ConfigMap == a.Meta.GetName() {
+						reqs = append(reqs, reconcile.Request{NamespacedName: types.NamespacedName{Name: item.Name, Namespace: item.Namespace}})
+					}
+				}
+				return reqs
+			}),
+		})
+	}
+
+	// Only the common params ConfigMap is cached, every addon is installed again when it changes
+	var commonParamsInformers informers.SharedInformerFactory
+	if r.commonParams.Name != "" {
+		commonParamsInformers = informers.NewSharedInformerFactoryWithOptions(r.generatedClient, time.Minute*30,
+			informers.WithNamespace(r.commonParams.Namespace),
+			informers.WithTweakListOptions(func(o *metav1.ListOptions) {
+				o.FieldSelector = fields.OneTermEqualSelector("metadata.name", r.commonParams.Name).String()
+			}))
+		r.commonParamsInformer = commonParamsInformers.Core().V1().ConfigMaps()
+
+		cmInf := r.commonParamsInformer.Informer()
+		bldr = bldr.Watches(&source.Informer{Informer: cmInf.(cache.Informer)}, &handler.EnqueueRequestsFromMapFunc{
+			ToRequests: handler.ToRequestsFunc(func(a handler.MapObject) []reconcile.Request {
+				var addons addonmgrv1alpha1.AddonList
+				if err := r.List(context.TODO(), &addons); err != nil {
+					log.Error(err, "failed to list addons for common params", "configmap", a.Meta.GetName())
+					return nil
+				}
+				var reqs = make([]reconcile.Request, 0, len(addons.Items))
+				for _, item := range addons.Items {
+					reqs = append(reqs, reconcile.Request{NamespacedName: types.NamespacedName{Name: item.Name, Namespace: item.Namespace}})
+				}
+				return reqs
+			}),
+		})
+	}
+
 	err := mgr.Add(manager.RunnableFunc(func(s <-chan struct{}) error {
 		generatedInformers.Start(s)
 		generatedInformers.WaitForCacheSync(s)
-		nsInformers.Start(s)
-		nsInformers.WaitForCacheSync(s)
+		if paramsInformers != nil {
+			paramsInformers.Start(s)
+			paramsInformers.WaitForCacheSync(s)
+		}
+		if commonParamsInformers != nil {
+			commonParamsInformers.Start(s)
+			commonParamsInformers.WaitForCacheSync(s)
+		}
+		if wfInf != nil {
+			go wfInf.Run(s)
+			toolscache.WaitForCacheSync(s, wfInf.HasSynced)
+		}
+		if clusterInformers != nil {
+			clusterInformers.Start(s)
+			clusterInformers.WaitForCacheSync(s)
+		}
 		<-s
 		return nil
 	}))
@@ -210,17 +792,78 @@ func (r *AddonReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	return bldr.Complete(r)
 }
 
+// waitingDependencies returns the package dependencies of the addon with addons that are still installing
+func (r *AddonReconciler) waitingDependencies(ctx context.Context, log logr.Logger, instance *addonmgrv1alpha1.Addon) []string {
+	if len(instance.Spec.PkgDeps) == 0 {
+		return nil
+	}
+	// Only the addons of the dependency packages are listed, cached addons are copied on every list
+	var deps []addonmgrv1alpha1.Addon
+	for name := range instance.Spec.PkgDeps {
+		var addons addonmgrv1alpha1.AddonList
+		if err := r.List(ctx, &addons, client.MatchingFields{pkgNameField: strings.TrimSpace(name)}); err != nil {
+			// Validation checks the dependencies against the version cache
+			log.Error(err, "Failed to list addons for dependencies.")
+			return nil
+		}
+		deps = append(deps, addons.Items...)
+	}
+	return addon.WaitingDependencies(instance, deps)
+}
+
+// dependentRequests returns the requests of the cached addons declaring a dependency on the package
+func (r *AddonReconciler) dependentRequests(pkgName string) []reconcile.Request {
+	var reqs = make([]reconcile.Request, 0)
+	for _, vmap := range r.versionCache.GetAllVersions() {
+		for _, v := range vmap {
+			for name := range v.PkgDeps {
+				if strings.TrimSpace(name) == pkgName {
+					reqs = append(reqs, reconcile.Request{NamespacedName: types.NamespacedName{Name: v.Name, Namespace: v.Namespace}})
+					break
+				}
+			}
+		}
+	}
+	return reqs
+}
+
 func (r *AddonReconciler) processAddon(ctx context.Context, req reconcile.Request, log logr.Logger, instance *addonmgrv1alpha1.Addon) (reconcile.Result, error) {
 
+	// Content resolved from outside the spec is part of the checksum so its changes are installed, the previous
+	// digest is kept while the content cannot be read
+	sopsDoc, sopsErr := r.sopsDocument(ctx, instance)
+	if instance.ObjectMeta.DeletionTimestamp.IsZero() {
+		commonParams, paramsErr := r.commonParamsDigest()
+		secretsDigest, secretsErr := r.secretsDigest(ctx, instance)
+		if sopsErr == nil && paramsErr == nil && secretsErr == nil {
+			instance.Status.SourceDigest = sourceDigest(sopsDoc, commonParams, secretsDigest)
+		}
+	}
+
 	// Calculate Checksum
-	instance.Status.Checksum = instance.CalculateChecksum()
+	prevChecksum := instance.Status.Checksum
+	instance.Status.Checksum = r.checksums.Checksum(instance)
+
+	// Installs of a new spec run every step, retries of a failed install skip the steps that succeeded
+	if prevChecksum != "" && prevChecksum != instance.Status.Checksum && instance.Status.Lifecycle.Installed == addonmgrv1alpha1.Succeeded {
+		instance.Status.Lifecycle.Checksums = nil
+	}
+
+	// Keep an audit trail of spec changes, the trail is only read and written when the checksum changed
+	if instance.ObjectMeta.DeletionTimestamp.IsZero() && prevChecksum != instance.Status.Checksum {
+		if entry, err := r.auditor.Record(ctx, instance); err != nil {
+			log.Error(err, "Failed to record addon audit trail.")
+		} else if entry != nil {
+			r.recorder.Event(instance, "Normal", "SpecChanged", entry.String())
+		}
+	}
 
 	// Resources list
 	instance.Status.Resources = make([]addonmgrv1alpha1.ObjectStatus, 0)
 
 	// Set ttl starttime if it is 0
 	if instance.Status.StartTime == 0 {
-		instance.Status.StartTime = common.GetCurretTimestamp()
+		instance.Status.StartTime = common.TimestampOf(r.clock.Now())
 	}
 
 	// Clear out the reason
@@ -234,7 +877,7 @@ func (r *AddonReconciler) processAddon(ctx context.Context, req reconcile.Reques
 	}
 
 	// Check if addon installation expired.
-	if instance.Status.Lifecycle.Installed == addonmgrv1alpha1.Pending && common.IsExpired(instance.Status.StartTime, TTL) {
+	if instance.Status.Lifecycle.Installed == addonmgrv1alpha1.Pending && common.IsExpiredAt(r.clock.Now(), instance.Status.StartTime, TTL) {
 		reason := fmt.Sprintf("Addon %s/%s ttl expired", instance.Namespace, instance.Name)
 		r.recorder.Event(instance, "Warning", "Failed", reason)
 		err := fmt.Errorf(reason)
@@ -247,7 +890,96 @@ func (r *AddonReconciler) processAddon(ctx context.Context, req reconcile.Reques
 		return reconcile.Result{}, err
 	}
 
-	var wfl = workflows.NewWorkflowLifecycle(r.Client, r.dynClient, instance, r.recorder, r.Scheme)
+	sopsParams, err := r.decryptSopsParams(ctx, instance, sopsDoc, sopsErr)
+	if err != nil && instance.ObjectMeta.DeletionTimestamp.IsZero() {
+		reason := fmt.Sprintf("Addon %s/%s could not decrypt sops params. %v", instance.Namespace, instance.Name, err)
+		r.recorder.Event(instance, "Warning", "Failed", reason)
+		log.Error(err, "Addon could not decrypt sops params.")
+		instance.Status.Lifecycle.Installed = addonmgrv1alpha1.Failed
+		instance.Status.StartTime = 0
+		instance.Status.Reason = reason
+
+		return reconcile.Result{}, err
+	}
+
+	// Hubs connect to the target clusters themselves
+	var cluster *remote.Cluster
+	if !r.hub {
+		cluster, err = r.clusters.Resolve(ctx, instance)
+		if err != nil {
+			reason := fmt.Sprintf("Addon %s/%s could not connect to target cluster. %v", instance.Namespace, instance.Name, err)
+			r.recorder.Event(instance, "Warning", "Failed", reason)
+			log.Error(err, "Addon could not connect to target cluster.")
+			instance.Status.Lifecycle.Installed = addonmgrv1alpha1.Failed
+			instance.Status.StartTime = 0
+			instance.Status.Reason = reason
+
+			return reconcile.Result{}, err
+		}
+	}
+	instance.Status.Cluster = ""
+	if cluster != nil {
+		instance.Status.Cluster = cluster.Host
+	}
+
+	wflOpts := []workflows.Option{workflows.WithCloudProvider(r.cloudProvider)}
+	if r.secretParams {
+		wflOpts = append(wflOpts, workflows.WithSecretParams(sopsParams))
+	} else {
+		wflOpts = append(wflOpts, workflows.WithParams(sopsParams))
+	}
+	if r.imageVerifier != nil {
+		wflOpts = append(wflOpts, workflows.WithImageVerifier(r.imageVerifier))
+	}
+	if cluster != nil {
+		// Service accounts are provisioned and checked in the local cluster only
+		wflOpts = append(wflOpts, workflows.WithRemoteCluster(cluster.Client, cluster.Dynamic))
+	} else {
+		if r.serviceAccounts != nil {
+			wflOpts = append(wflOpts, workflows.WithServiceAccountProvisioner(r.serviceAccounts))
+		}
+		if r.saChecker != nil {
+			wflOpts = append(wflOpts, workflows.WithServiceAccountChecker(r.saChecker))
+		}
+		if r.workflowNs != "" {
+			wflOpts = append(wflOpts, workflows.WithWorkflowNamespace(r.workflowNs))
+		}
+	}
+	if r.securityContext != nil {
+		wflOpts = append(wflOpts, workflows.WithSecurityContextDefaults(*r.securityContext))
+	}
+	if !r.propagation.Empty() {
+		wflOpts = append(wflOpts, workflows.WithPropagationPolicy(r.propagation))
+	}
+	if r.ownerMode != "" {
+		wflOpts = append(wflOpts, workflows.WithOwnerMode(r.ownerMode))
+	}
+	if r.logArchive != (workflows.LogArchive{}) {
+		wflOpts = append(wflOpts, workflows.WithLogArchive(r.logArchive))
+	}
+	if r.commonParams.Name != "" {
+		params, err := r.readCommonParams()
+		if err != nil {
+			reason := fmt.Sprintf("Addon %s/%s could not read common params. %v", instance.Namespace, instance.Name, err)
+			r.recorder.Event(instance, "Warning", "Failed", reason)
+			log.Error(err, "Addon could not read common params.")
+			instance.Status.Lifecycle.Installed = addonmgrv1alpha1.Failed
+			instance.Status.StartTime = 0
+			instance.Status.Reason = reason
+
+			return reconcile.Result{}, err
+		}
+		wflOpts = append(wflOpts, workflows.WithCommonParams(params))
+	}
+	if r.faults != nil {
+		wflOpts = append(wflOpts, workflows.WithFaultInjector(r.faults))
+	}
+	if r.simulator != nil {
+		wflOpts = append(wflOpts, workflows.WithSimulator(r.simulator))
+	} else if r.workflowInformer != nil {
+		wflOpts = append(wflOpts, workflows.WithWorkflowCache(r.workflowInformer.GetIndexer(), r.informerNamespace()))
+	}
+	var wfl = workflows.NewWorkflowLifecycle(r.Client, r.dynClient, instance, r.recorder, r.Scheme, wflOpts...)
 
 	// Resource is being deleted, run finalizers and exit.
 	if !instance.ObjectMeta.DeletionTimestamp.IsZero() {
@@ -258,7 +990,22 @@ func (r *AddonReconciler) processAddon(ctx context.Context, req reconcile.Reques
 			return reconcile.Result{Requeue: true}, nil
 		}
 
-		err := r.Finalize(ctx, instance, wfl, finalizerName)
+		// Dependents are deleted before the packages they depend on
+		blocked, err := r.waitForDependents(ctx, instance)
+		if err != nil {
+			reason := fmt.Sprintf("Addon %s/%s could not delete dependents. %v", instance.Namespace, instance.Name, err)
+			r.recorder.Event(instance, "Warning", "Failed", reason)
+			instance.Status.Lifecycle.Installed = addonmgrv1alpha1.DeleteFailed
+			instance.Status.StartTime = 0
+			instance.Status.Reason = reason
+			log.Error(err, "Failed to delete addon dependents.")
+			return reconcile.Result{}, err
+		}
+		if blocked {
+			return reconcile.Result{RequeueAfter: remotePollInterval}, nil
+		}
+
+		err = r.Finalize(ctx, instance, wfl, finalizerName)
 		if err != nil {
 			reason := fmt.Sprintf("Addon %s/%s could not be finalized. %v", instance.Namespace, instance.Name, err)
 			r.recorder.Event(instance, "Warning", "Failed", reason)
@@ -269,173 +1016,1553 @@ func (r *AddonReconciler) processAddon(ctx context.Context, req reconcile.Reques
 			return reconcile.Result{}, err
 		}
 
+		if (cluster != nil || r.gitops != nil || r.simulator != nil) && common.ContainsString(instance.ObjectMeta.Finalizers, finalizerName) {
+			return reconcile.Result{RequeueAfter: remotePollInterval}, nil
+		}
+
 		return reconcile.Result{}, nil
 	}
 
-	// Validate Addon
-	if ok, err := addon.NewAddonValidator(instance, r.versionCache, r.dynClient).Validate(); !ok {
-		// if an addons dependency is in a Pending state then make the parent addon Pending
-		if strings.HasPrefix(err.Error(), addon.ErrDepPending) {
-			reason := fmt.Sprintf("Addon %s/%s is waiting on dependencies to be out of Pending state.", instance.Namespace, instance.Name)
-			// Record an event if addon is not valid
-			r.recorder.Event(instance, "Normal", "Pending", reason)
-			instance.Status.Lifecycle.Installed = addonmgrv1alpha1.Pending
-			instance.Status.StartTime = 0
-			instance.Status.Reason = reason
-
-			log.Info("Addon %s/%s is waiting on dependencies to be out of Pending state.", instance.Namespace, instance.Name)
+	// Rapid spec edits only run the workflows of the final spec
+	changed := prevChecksum != "" && prevChecksum != instance.Status.Checksum
+	if wait := r.debouncer.Wait(req.NamespacedName, instance.Status.Checksum, changed); wait > 0 {
+		instance.Status.Lifecycle.Installed = addonmgrv1alpha1.Pending
+		instance.Status.StartTime = 0
+		instance.Status.Reason = fmt.Sprintf("Addon %s/%s is waiting for spec changes to settle.", instance.Namespace, instance.Name)
 
-			// requeue after 10 seconds
-			return reconcile.Result{
-				Requeue:      true,
-				RequeueAfter: 10 * time.Second,
-			}, nil
-		}
+		return reconcile.Result{RequeueAfter: wait}, nil
+	}
 
-		reason := fmt.Sprintf("Addon %s/%s is not valid. %v", instance.Namespace, instance.Name, err)
-		// Record an event if addon is not valid
+	// Upgrades of installed addons wait for the maintenance window, started upgrades complete after it closes
+	deferred, next, err := r.deferUpgrade(instance)
+	if err != nil {
+		reason := fmt.Sprintf("Addon %s/%s has an invalid maintenance window. %v", instance.Namespace, instance.Name, err)
 		r.recorder.Event(instance, "Warning", "Failed", reason)
+		log.Error(err, "Addon has an invalid maintenance window.")
 		instance.Status.Lifecycle.Installed = addonmgrv1alpha1.Failed
 		instance.Status.StartTime = 0
 		instance.Status.Reason = reason
 
-		log.Error(err, "Failed to validate addon.")
-
 		return reconcile.Result{}, err
 	}
+	if deferred {
+		// The installed spec keeps running until the window opens
+		instance.Status.Lifecycle.Installed = addonmgrv1alpha1.Succeeded
+		instance.Status.StartTime = 0
+		if next.IsZero() {
+			instance.Status.Reason = fmt.Sprintf("Addon %s/%s upgrade is deferred, the maintenance window never opens.", instance.Namespace, instance.Name)
+			return reconcile.Result{}, nil
+		}
+		instance.Status.Reason = fmt.Sprintf("Addon %s/%s upgrade is deferred to the maintenance window at %s.", instance.Namespace, instance.Name, next.UTC().Format(time.RFC3339))
+		return reconcile.Result{RequeueAfter: next.Sub(r.clock.Now())}, nil
+	}
 
-	// Record successful validation
-	r.recorder.Event(instance, "Normal", "Completed", fmt.Sprintf("Addon %s/%s is valid.", instance.Namespace, instance.Name))
-
-	// Set finalizer only after addon is valid
-	if err := r.SetFinalizer(ctx, instance, finalizerName); err != nil {
+	// Install missing dependencies from the catalog, validation waits for them while they are pending
+	if r.catalog != nil && instance.Spec.InstallDependencies == addonmgrv1alpha1.InstallDependenciesIfNotPresent {
+		created, err := r.installDependencies(ctx, instance)
+		if err != nil {
+			reason := fmt.Sprintf("Addon %s/%s could not install dependencies from the catalog. %v", instance.Namespace, instance.Name, err)
+			r.recorder.Event(instance, "Warning", "Failed", reason)
+			log.Error(err, "Addon could not install dependencies from the catalog.")
+			instance.Status.Lifecycle.Installed = addonmgrv1alpha1.Failed
+			instance.Status.StartTime = 0
+			instance.Status.Reason = reason
+
+			return reconcile.Result{}, err
+		}
+		if len(created) > 0 {
+			reason := fmt.Sprintf("Addon %s/%s is installing dependencies %s from the catalog.", instance.Namespace, instance.Name, strings.Join(created, ", "))
+			r.recorder.Event(instance, "Normal", "InstallingDependencies", reason)
+			instance.Status.Lifecycle.Installed = addonmgrv1alpha1.Pending
+			instance.Status.StartTime = 0
+			instance.Status.Reason = reason
+
+			return reconcile.Result{RequeueAfter: 10 * time.Second}, nil
+		}
+	}
+
+	waitingAddons := r.setDependencyStatus(ctx, instance)
+
+	// An addon pinning a dependency to versions incompatible with other addons is not installed over them
+	if cond := meta.FindStatusCondition(instance.Status.Conditions, addonmgrv1alpha1.ConflictCondition); cond != nil && cond.Status == metav1.ConditionTrue && !specInstalled(instance) {
+		reason := fmt.Sprintf("Addon %s/%s has conflicting dependencies. %s", instance.Namespace, instance.Name, cond.Message)
+		r.recorder.Event(instance, "Warning", "Failed", reason)
+		instance.Status.Lifecycle.Installed = addonmgrv1alpha1.Failed
+		instance.Status.StartTime = 0
+		instance.Status.Reason = reason
+
+		return reconcile.Result{}, fmt.Errorf(reason)
+	}
+
+	// Dependencies still installing are waited on without holding a worker, their changes schedule the addon again.
+	// Addon dependencies are not validated, they are waited on until they are installed.
+	if waiting := append(r.waitingDependencies(ctx, log, instance), waitingAddons...); len(waiting) > 0 {
+		reason := fmt.Sprintf("Addon %s/%s is waiting on dependencies %s to be installed.", instance.Namespace, instance.Name, strings.Join(waiting, ", "))
+		r.recorder.Event(instance, "Normal", addonmgrv1alpha1.DepsNotReadyReason, reason)
+		instance.Status.Lifecycle.Installed = addonmgrv1alpha1.Pending
+		instance.Status.StartTime = 0
+		instance.Status.Reason = reason
+
+		return reconcile.Result{RequeueAfter: dependencyResyncInterval}, nil
+	}
+
+	// Validate Addon
+	if ok, err := addon.NewAddonValidator(instance, r.versionCache, r.dynClient).Validate(); !ok {
+		// Dependencies that are pending, not installed or not Succeeded block the prereqs and install workflows
+		// until they succeed
+		if strings.HasPrefix(err.Error(), addon.ErrDepPending) || strings.HasPrefix(err.Error(), addon.ErrDepNotInstalled) {
+			reason := fmt.Sprintf("Addon %s/%s is waiting on dependencies to succeed. %v", instance.Namespace, instance.Name, err)
+			r.recorder.Event(instance, "Normal", addonmgrv1alpha1.DepsNotReadyReason, reason)
+			instance.Status.Lifecycle.Installed = addonmgrv1alpha1.Pending
+			instance.Status.StartTime = 0
+			instance.Status.Reason = reason
+
+			log.Info("Addon is waiting on dependencies to succeed.", "dependencies", err.Error())
+
+			return reconcile.Result{RequeueAfter: dependencyResyncInterval}, nil
+		}
+
+		reason := fmt.Sprintf("Addon %s/%s is not valid. %v", instance.Namespace, instance.Name, err)
+		// Record an event if addon is not valid
+		r.recorder.Event(instance, "Warning", "Failed", reason)
+		instance.Status.Lifecycle.Installed = addonmgrv1alpha1.Failed
+		instance.Status.StartTime = 0
+		instance.Status.Reason = reason
+
+		log.Error(err, "Failed to validate addon.")
+
+		return reconcile.Result{}, err
+	}
+
+	// Record successful validation
+	r.recorder.Event(instance, "Normal", "Completed", fmt.Sprintf("Addon %s/%s is valid.", instance.Namespace, instance.Name))
+
+	// Hubs install the addon in clusters the manager cannot reach
+	if instance.Spec.KubeVersion != "" && !r.hub {
+		cond, err := r.checkKubeVersion(instance, cluster)
+		if err == nil && cond.Status == metav1.ConditionFalse && r.kubeVersions != addon.WarnKubeVersion {
+			err = errors.New(cond.Message)
+		}
+		if err != nil {
+			reason := fmt.Sprintf("Addon %s/%s does not support the target cluster. %v", instance.Namespace, instance.Name, err)
+			r.recorder.Event(instance, "Warning", "Failed", reason)
+			log.Error(err, "Addon does not support the target cluster.")
+			instance.Status.Lifecycle.Installed = addonmgrv1alpha1.Failed
+			instance.Status.StartTime = 0
+			instance.Status.Reason = reason
+			return reconcile.Result{}, err
+		}
+	} else {
+		meta.RemoveStatusCondition(&instance.Status.Conditions, addonmgrv1alpha1.KubeVersionCompatibleCondition)
+	}
+
+	if r.provenance != nil {
+		if err := r.provenance.Verify(instance); err != nil {
+			reason := fmt.Sprintf("Addon %s/%s package provenance could not be verified. %v", instance.Namespace, instance.Name, err)
+			r.recorder.Event(instance, "Warning", "Failed", reason)
+			log.Error(err, "Failed to verify addon package signature.")
+			instance.Status.Lifecycle.Installed = addonmgrv1alpha1.Failed
+			instance.Status.StartTime = 0
+			instance.Status.Reason = reason
+			return reconcile.Result{}, err
+		}
+	}
+
+	// Set finalizer only after addon is valid
+	if err := r.SetFinalizer(ctx, instance, finalizerName); err != nil {
 		reason := fmt.Sprintf("Addon %s/%s could not add finalizer. %v", instance.Namespace, instance.Name, err)
 		r.recorder.Event(instance, "Warning", "Failed", reason)
 		log.Error(err, "Failed to add finalizer for addon.")
 		instance.Status.Lifecycle.Installed = addonmgrv1alpha1.Failed
 		instance.Status.StartTime = 0
-		instance.Status.Reason = reason
-		return reconcile.Result{}, err
+		instance.Status.Reason = reason
+		return reconcile.Result{}, err
+	}
+
+	// Add addon to cache
+	//r.addAddonToCache(req, addon, addonmgrv1alpha1.Pending)
+
+	if r.gitops != nil {
+		return r.applyGitOps(ctx, log, instance)
+	}
+
+	// Failed installs back off and are not retried once the failure budget is exhausted
+	if ok, res, err := r.checkFailureBudget(ctx, log, instance, wfl); !ok {
+		return res, err
+	}
+
+	// Prereqs workflow
+	prereqsPhase, err := r.runStep(addonmgrv1alpha1.Prereqs, instance, wfl)
+	instance.Status.Lifecycle.Prereqs = prereqsPhase
+	if err != nil || prereqsPhase == addonmgrv1alpha1.Failed {
+		r.recordInstallFailure(instance)
+	}
+	if err != nil {
+		reason := fmt.Sprintf("Addon %s/%s prereqs failed. %v", instance.Namespace, instance.Name, err)
+		r.recorder.Event(instance, "Warning", "Failed", reason)
+		log.Error(err, "Addon prereqs workflow failed.")
+		// if prereqs failed, set install status to failed as well so that STATUS is updated
+		instance.Status.Lifecycle.Installed = addonmgrv1alpha1.Failed
+		instance.Status.StartTime = 0
+		instance.Status.Reason = reason
+
+		return reconcile.Result{}, err
+	}
+
+	//handle Prereqs failure
+	if instance.Status.Lifecycle.Prereqs == addonmgrv1alpha1.Failed {
+		reason := fmt.Sprintf("Addon %s/%s Prereqs status is Failed", instance.Namespace, instance.Name)
+		r.recorder.Event(instance, "Warning", "Failed", reason)
+		log.Error(err, "Addon prereqs workflow failed.")
+		// if prereqs failed, set install status to failed as well so that STATUS is updated
+		instance.Status.Lifecycle.Installed = addonmgrv1alpha1.Failed
+		instance.Status.StartTime = 0
+		instance.Status.Reason = reason
+
+		return reconcile.Result{}, fmt.Errorf(reason)
+	}
+
+	// Validate secrets are in the addon deployment namespace, this is here and not in validator b/c namespace must be used to validate.
+	if instance.Status.Lifecycle.Prereqs == addonmgrv1alpha1.Succeeded {
+		// Network policies and external secrets are only managed in the local cluster
+		if r.networkPolicies != nil && cluster == nil {
+			if err := r.networkPolicies.Apply(ctx, instance); err != nil {
+				reason := fmt.Sprintf("Addon %s/%s could not apply network policies. %v", instance.Namespace, instance.Name, err)
+				r.recorder.Event(instance, "Warning", "Failed", reason)
+				log.Error(err, "Addon could not apply network policies.")
+				instance.Status.Lifecycle.Installed = addonmgrv1alpha1.Failed
+				instance.Status.StartTime = 0
+				instance.Status.Reason = reason
+
+				return reconcile.Result{}, err
+			}
+		}
+
+		if err := r.materializeSecrets(ctx, instance, cluster); err != nil {
+			reason := fmt.Sprintf("Addon %s/%s could not fetch external secrets. %v", instance.Namespace, instance.Name, err)
+			r.recorder.Event(instance, "Warning", "Failed", reason)
+			log.Error(err, "Addon could not fetch external secrets.")
+			instance.Status.Lifecycle.Installed = addonmgrv1alpha1.Failed
+			instance.Status.StartTime = 0
+			instance.Status.Reason = reason
+
+			return reconcile.Result{}, err
+		}
+
+		pending, err := r.pendingUnseal(ctx, instance, cluster)
+		if err != nil {
+			reason := fmt.Sprintf("Addon %s/%s sealed secrets could not be unsealed. %v", instance.Namespace, instance.Name, err)
+			r.recorder.Event(instance, "Warning", "Failed", reason)
+			log.Error(err, "Addon sealed secrets could not be unsealed.")
+			instance.Status.Lifecycle.Installed = addonmgrv1alpha1.Failed
+			instance.Status.StartTime = 0
+			instance.Status.Reason = reason
+
+			return reconcile.Result{}, err
+		}
+		if len(pending) > 0 {
+			reason := fmt.Sprintf("Addon %s/%s is waiting on sealed secrets %s to be unsealed.", instance.Namespace, instance.Name, strings.Join(pending, ", "))
+			r.recorder.Event(instance, "Normal", "Pending", reason)
+			instance.Status.Lifecycle.Installed = addonmgrv1alpha1.Pending
+			instance.Status.Reason = reason
+
+			return reconcile.Result{RequeueAfter: 10 * time.Second}, nil
+		}
+
+		if err := r.validateSecrets(ctx, instance, cluster); err != nil {
+			reason := fmt.Sprintf("Addon %s/%s could not validate secrets. %v", instance.Namespace, instance.Name, err)
+			r.recorder.Event(instance, "Warning", "Failed", reason)
+			log.Error(err, "Addon could not validate secrets.")
+			instance.Status.Lifecycle.Installed = addonmgrv1alpha1.Failed
+			instance.Status.StartTime = 0
+			instance.Status.Reason = reason
+
+			return reconcile.Result{}, err
+		}
+
+		var phase addonmgrv1alpha1.ApplicationAssemblyPhase
+		if instance.IsBlueGreen() {
+			phase, err = r.installBlueGreen(ctx, instance, cluster, wflOpts)
+		} else {
+			phase, err = r.installAndValidate(instance, wfl)
+			if (err != nil || phase == addonmgrv1alpha1.Failed) && instance.Spec.Lifecycle.RollbackOnFailure {
+				r.rollbackFailedInstall(ctx, log, instance, wflOpts)
+			}
+			if workflows.IsHelmChart(instance) {
+				r.recordHelmRelease(ctx, log, instance, cluster)
+			} else {
+				instance.Status.Helm = nil
+				if err == nil && phase == addonmgrv1alpha1.Succeeded && r.simulator == nil {
+					r.recordInstalledResources(ctx, log, instance, cluster)
+				}
+			}
+		}
+		if err != nil || phase == addonmgrv1alpha1.Failed {
+			r.recordInstallFailure(instance)
+		} else if phase == addonmgrv1alpha1.Succeeded {
+			instance.Status.FailureBudget = nil
+		}
+		instance.Status.Lifecycle.Installed = phase
+		if err != nil {
+			reason := fmt.Sprintf("Addon %s/%s could not be installed due to error. %v", instance.Namespace, instance.Name, err)
+			r.recorder.Event(instance, "Warning", "Failed", reason)
+			log.Error(err, "Addon install workflow failed.")
+			instance.Status.StartTime = 0
+			instance.Status.Reason = reason
+
+			return reconcile.Result{}, err
+		}
+
+		//r.addAddonToCache(req, instance, phase)
+	}
+
+	// Observe resources matching selector labels.
+	observed, images, err := r.observeResources(ctx, instance, cluster)
+	if err != nil {
+		reason := fmt.Sprintf("Addon %s/%s failed to find deployed resources. %v", instance.Namespace, instance.Name, err)
+		r.recorder.Event(instance, "Warning", "Failed", reason)
+		log.Error(err, "Addon failed to find deployed resources.")
+		instance.Status.Lifecycle.Installed = addonmgrv1alpha1.Failed
+		instance.Status.StartTime = 0
+		instance.Status.Reason = reason
+
+		return reconcile.Result{}, err
+	}
+
+	if len(observed) > 0 {
+		instance.Status.Resources = observed
+		instance.Status.Images = images
+	}
+
+	// Workflows in remote clusters are not watched, poll them until they complete
+	if cluster != nil && (instance.Status.Lifecycle.Prereqs == addonmgrv1alpha1.Pending || instance.Status.Lifecycle.Installed == addonmgrv1alpha1.Pending) {
+		return reconcile.Result{RequeueAfter: remotePollInterval}, nil
+	}
+	// Simulated workflows complete once their delay has passed
+	if r.simulator != nil && (instance.Status.Lifecycle.Prereqs == addonmgrv1alpha1.Pending || instance.Status.Lifecycle.Installed == addonmgrv1alpha1.Pending) {
+		return reconcile.Result{RequeueAfter: r.simulator.Delay()}, nil
+	}
+
+	return ctrl.Result{}, nil
+}
+
+// processFleet installs the addon in every cluster matching the cluster selector through member addons
+func (r *AddonReconciler) processFleet(ctx context.Context, log logr.Logger, instance *addonmgrv1alpha1.Addon) (reconcile.Result, error) {
+	instance.Status.Checksum = r.checksums.Checksum(instance)
+	instance.Status.Reason = ""
+
+	// Member addons run their delete workflows in their clusters
+	if !instance.ObjectMeta.DeletionTimestamp.IsZero() {
+		instance.Status.Lifecycle.Installed = addonmgrv1alpha1.Deleting
+		done, err := r.fleet.Release(ctx, instance)
+		if err != nil {
+			reason := fmt.Sprintf("Addon %s/%s could not release clusters. %v", instance.Namespace, instance.Name, err)
+			r.recorder.Event(instance, "Warning", "Failed", reason)
+			instance.Status.Lifecycle.Installed = addonmgrv1alpha1.DeleteFailed
+			instance.Status.Reason = reason
+			log.Error(err, "Failed to release clusters.")
+			return reconcile.Result{}, err
+		}
+		if !done {
+			return reconcile.Result{RequeueAfter: remotePollInterval}, nil
+		}
+		if common.ContainsString(instance.ObjectMeta.Finalizers, finalizerName) {
+			instance.ObjectMeta.Finalizers = common.RemoveString(instance.ObjectMeta.Finalizers, finalizerName)
+			if err := r.updateAddon(ctx, instance); err != nil {
+				return reconcile.Result{}, err
+			}
+		}
+		return reconcile.Result{}, nil
+	}
+
+	// Keep the addon until its cluster finalizers are released
+	if r.clusterHooks {
+		if err := r.SetFinalizer(ctx, instance, finalizerName); err != nil {
+			reason := fmt.Sprintf("Addon %s/%s could not add finalizer. %v", instance.Namespace, instance.Name, err)
+			r.recorder.Event(instance, "Warning", "Failed", reason)
+			log.Error(err, "Failed to add finalizer for addon.")
+			instance.Status.Lifecycle.Installed = addonmgrv1alpha1.Failed
+			instance.Status.Reason = reason
+			return reconcile.Result{}, err
+		}
+	}
+
+	wasHalted := instance.Status.Rollout != nil && instance.Status.Rollout.Halted
+	statuses, err := r.fleet.Sync(ctx, instance)
+	if err != nil {
+		reason := fmt.Sprintf("Addon %s/%s could not sync cluster addons. %v", instance.Namespace, instance.Name, err)
+		r.recorder.Event(instance, "Warning", "Failed", reason)
+		log.Error(err, "Addon could not sync cluster addons.")
+		instance.Status.Lifecycle.Installed = addonmgrv1alpha1.Failed
+		instance.Status.StartTime = 0
+		instance.Status.Reason = reason
+
+		return reconcile.Result{}, err
+	}
+
+	instance.Status.Clusters = statuses
+	instance.Status.Summary = addonmgrv1alpha1.NewClusterSummary(statuses)
+	instance.Status.Lifecycle.Installed = fleet.Phase(instance, statuses)
+	if len(statuses) == 0 {
+		instance.Status.Reason = fmt.Sprintf("Addon %s/%s cluster selector does not match any cluster.", instance.Namespace, instance.Name)
+	} else if instance.Status.Lifecycle.Installed != addonmgrv1alpha1.Succeeded {
+		instance.Status.Reason = fmt.Sprintf("Addon %s/%s clusters: %s.", instance.Namespace, instance.Name, instance.Status.Summary)
+	}
+	if rollout := instance.Status.Rollout; rollout != nil && rollout.Halted {
+		reason := fmt.Sprintf("Addon %s/%s rollout halted after %d of %d updated clusters failed.", instance.Namespace, instance.Name, failedClusters(statuses), rollout.Updated)
+		if !wasHalted {
+			r.recorder.Event(instance, "Warning", "RolloutHalted", reason)
+		}
+		instance.Status.Reason = reason
+	}
+
+	// Clusters are not watched, list them again to pick up new clusters
+	return reconcile.Result{RequeueAfter: fleetSyncInterval}, nil
+}
+
+// applyGitOps renders the addon for the GitOps controller and maps the status of its objects to the addon
+func (r *AddonReconciler) applyGitOps(ctx context.Context, log logr.Logger, instance *addonmgrv1alpha1.Addon) (reconcile.Result, error) {
+	phase, reason, err := r.gitops.Apply(ctx, instance)
+	if err != nil {
+		reason := fmt.Sprintf("Addon %s/%s could not be rendered for GitOps. %v", instance.Namespace, instance.Name, err)
+		r.recorder.Event(instance, "Warning", "Failed", reason)
+		log.Error(err, "Addon could not be rendered for GitOps.")
+		instance.Status.Lifecycle.Installed = addonmgrv1alpha1.Failed
+		instance.Status.StartTime = 0
+		instance.Status.Reason = reason
+
+		return reconcile.Result{}, err
+	}
+
+	// There are no prereqs workflows, the GitOps controller installs everything
+	instance.Status.Lifecycle.Prereqs = addonmgrv1alpha1.Succeeded
+	instance.Status.Lifecycle.Installed = phase
+	instance.Status.Reason = reason
+
+	if reporter, ok := r.gitops.(gitops.ClusterReporter); ok {
+		statuses, err := reporter.Clusters(ctx, instance)
+		if err != nil {
+			log.Error(err, "Addon cluster statuses could not be read.")
+			return reconcile.Result{}, err
+		}
+		instance.Status.Clusters = statuses
+		instance.Status.Summary = addonmgrv1alpha1.NewClusterSummary(statuses)
+	}
+	// GitOps controllers keep retrying failed installs, poll until the addon succeeds
+	if phase != addonmgrv1alpha1.Succeeded {
+		return reconcile.Result{RequeueAfter: gitopsPollInterval}, nil
+	}
+	return reconcile.Result{}, nil
+}
+
+// installDependencies creates the catalog addons of the dependencies that are not installed in the addon namespace
+// and returns the names of the addons that do not run yet
+func (r *AddonReconciler) installDependencies(ctx context.Context, instance *addonmgrv1alpha1.Addon) ([]string, error) {
+	snapshot, err := r.catalog.Load(ctx)
+	if err != nil {
+		return nil, err
+	}
+	plan, err := addon.ResolveDependencies(instance, r.versionCache, snapshot)
+	if err != nil {
+		// Validation reports unresolvable dependencies
+		return nil, nil
+	}
+
+	var created []string
+	for _, pkg := range plan.Missing() {
+		dep := snapshot.Addon(pkg.PkgName, pkg.PkgVersion)
+		dep.ObjectMeta = metav1.ObjectMeta{
+			Name:        dep.Name,
+			Namespace:   instance.Namespace,
+			Labels:      dep.Labels,
+			Annotations: dep.Annotations,
+		}
+		if dep.Annotations == nil {
+			dep.Annotations = map[string]string{}
+		}
+		dep.Annotations[requiredByAnnotation] = instance.Name
+
+		err := r.Create(ctx, dep)
+		if apierrors.IsAlreadyExists(err) {
+			existing := &addonmgrv1alpha1.Addon{}
+			if err := r.Get(ctx, types.NamespacedName{Namespace: dep.Namespace, Name: dep.Name}, existing); err != nil {
+				return nil, err
+			}
+			if existing.Spec.PkgName != dep.Spec.PkgName {
+				return nil, fmt.Errorf("addon %s for package %s already exists with package %s", dep.Name, dep.Spec.PkgName, existing.Spec.PkgName)
+			}
+		} else if err != nil {
+			return nil, err
+		}
+		created = append(created, pkg.String())
+	}
+	return created, nil
+}
+
+func failedClusters(statuses []addonmgrv1alpha1.ClusterStatus) int {
+	var failed int
+	for _, s := range statuses {
+		if !s.Outdated && (s.Installed == addonmgrv1alpha1.Failed || s.Installed == addonmgrv1alpha1.DeleteFailed) {
+			failed++
+		}
+	}
+	return failed
+}
+
+func ignoreNotFound(err error) error {
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+// offloadTemplates inlines the templates the addon references in ConfigMaps and offloads the templates of addons
+// grown too large, it returns true when the templates are resolved and the addon can be processed
+func (r *AddonReconciler) offloadTemplates(ctx context.Context, log logr.Logger, instance *addonmgrv1alpha1.Addon) (bool, reconcile.Result, error) {
+	deleting := !instance.ObjectMeta.DeletionTimestamp.IsZero()
+	offload := r.templates.NeedsOffload(savedAddon(instance)) && !deleting
+	if err := r.templates.Resolve(ctx, instance); err != nil {
+		// Offloaded templates may be garbage collected before the addon, deletes proceed without them
+		if deleting {
+			r.recorder.Event(instance, "Warning", "Failed", fmt.Sprintf("Addon %s/%s is deleted without its workflow templates. %v", instance.Namespace, instance.Name, err))
+			return true, reconcile.Result{}, nil
+		}
+		reason := fmt.Sprintf("Addon %s/%s could not resolve its workflow templates. %v", instance.Namespace, instance.Name, err)
+		r.recorder.Event(instance, "Warning", "Failed", reason)
+		log.Error(err, "Failed to resolve addon templates.")
+		instance.Status.Lifecycle.Installed = addonmgrv1alpha1.Failed
+		instance.Status.StartTime = 0
+		instance.Status.Reason = reason
+		return false, reconcile.Result{}, err
+	}
+	if !offload {
+		return true, reconcile.Result{}, nil
+	}
+
+	size := addon.Size(instance)
+	if err := r.updateAddon(ctx, instance); err != nil {
+		reason := fmt.Sprintf("Addon %s/%s is %d bytes and its templates could not be offloaded. %v", instance.Namespace, instance.Name, size, err)
+		r.recorder.Event(instance, "Warning", "Failed", reason)
+		log.Error(err, "Failed to offload addon templates.")
+		instance.Status.Lifecycle.Installed = addonmgrv1alpha1.Failed
+		instance.Status.StartTime = 0
+		instance.Status.Reason = reason
+		return false, reconcile.Result{}, err
+	}
+	r.recorder.Event(instance, "Normal", "TemplatesOffloaded", fmt.Sprintf("Addon %s/%s is %d bytes, its workflow templates were offloaded to ConfigMaps.", instance.Namespace, instance.Name, size))
+	return false, reconcile.Result{Requeue: true}, nil
+}
+
+// updateAddon saves the addon without the fields set from its AddonTemplate and with its templates offloaded when it
+// is too large, the defaults and templates stay inline in the instance so the spec and its checksum do not change
+func (r *AddonReconciler) updateAddon(ctx context.Context, instance *addonmgrv1alpha1.Addon) error {
+	saved := savedAddon(instance)
+	if _, err := r.templates.Offload(ctx, saved); err != nil {
+		return err
+	}
+	if err := r.Update(ctx, saved); err != nil {
+		return err
+	}
+	saved.ObjectMeta.DeepCopyInto(&instance.ObjectMeta)
+	return nil
+}
+
+// savedAddon returns a copy of the addon without the spec fields set from its AddonTemplate
+func savedAddon(instance *addonmgrv1alpha1.Addon) *addonmgrv1alpha1.Addon {
+	saved := instance.DeepCopy()
+	if saved.Status.Template != nil {
+		addon.RemoveTemplateDefaults(saved, saved.Status.Template.Defaults)
+	}
+	return saved
+}
+
+// applyAddonTemplate completes the spec of an addon referencing an AddonTemplate with the template workflows and
+// params, it returns true when the template is applied and the addon can be processed. The fields set from the
+// template are recorded in the status so they are not saved with the spec.
+func (r *AddonReconciler) applyAddonTemplate(ctx context.Context, log logr.Logger, instance *addonmgrv1alpha1.Addon) (bool, reconcile.Result, error) {
+	// Fields recorded by an earlier reconcile are not set in the instance yet
+	instance.Status.Template = nil
+	if instance.Spec.TemplateRef == "" {
+		return true, reconcile.Result{}, nil
+	}
+	if !r.addonTemplates {
+		if !instance.ObjectMeta.DeletionTimestamp.IsZero() {
+			return true, reconcile.Result{}, nil
+		}
+		reason := fmt.Sprintf("Addon %s/%s references template %s but addon templates are not enabled.", instance.Namespace, instance.Name, instance.Spec.TemplateRef)
+		r.recorder.Event(instance, "Warning", "Failed", reason)
+		instance.Status.Lifecycle.Installed = addonmgrv1alpha1.Failed
+		instance.Status.StartTime = 0
+		instance.Status.Reason = reason
+		return false, reconcile.Result{}, nil
+	}
+
+	t := &addonmgrv1alpha1.AddonTemplate{}
+	if err := r.Get(ctx, types.NamespacedName{Namespace: instance.Namespace, Name: instance.Spec.TemplateRef}, t); err != nil {
+		// Deletes proceed without the template workflows once the template is gone
+		if !instance.ObjectMeta.DeletionTimestamp.IsZero() {
+			r.recorder.Event(instance, "Warning", "Failed", fmt.Sprintf("Addon %s/%s is deleted without the workflows of template %s. %v", instance.Namespace, instance.Name, instance.Spec.TemplateRef, err))
+			return true, reconcile.Result{}, nil
+		}
+		if apierrors.IsNotFound(err) {
+			instance.Status.Lifecycle.Installed = addonmgrv1alpha1.Pending
+			instance.Status.Reason = fmt.Sprintf("Addon %s/%s is waiting for template %s.", instance.Namespace, instance.Name, instance.Spec.TemplateRef)
+			return false, reconcile.Result{}, nil
+		}
+		reason := fmt.Sprintf("Addon %s/%s could not read template %s. %v", instance.Namespace, instance.Name, instance.Spec.TemplateRef, err)
+		r.recorder.Event(instance, "Warning", "Failed", reason)
+		log.Error(err, "Failed to read addon template.")
+		instance.Status.Lifecycle.Installed = addonmgrv1alpha1.Failed
+		instance.Status.StartTime = 0
+		instance.Status.Reason = reason
+		return false, reconcile.Result{}, err
+	}
+
+	instance.Status.Template = &addonmgrv1alpha1.TemplateStatus{Name: t.Name, Defaults: addon.ApplyTemplate(instance, t)}
+	return true, reconcile.Result{}, nil
+}
+
+// rollbackAddon saves the spec of the revision the rollback annotation requests, it returns true when no rollback
+// is requested and the addon can be processed. The changed spec runs the lifecycle of the revision.
+func (r *AddonReconciler) rollbackAddon(ctx context.Context, log logr.Logger, instance *addonmgrv1alpha1.Addon) (bool, reconcile.Result, error) {
+	target, ok := instance.GetAnnotations()[revision.RollbackAnnotation]
+	if !ok || !instance.ObjectMeta.DeletionTimestamp.IsZero() {
+		return true, reconcile.Result{}, nil
+	}
+
+	number, spec, err := r.history.Find(ctx, instance, target)
+	if err != nil {
+		reason := fmt.Sprintf("Addon %s/%s could not be rolled back. %v", instance.Namespace, instance.Name, err)
+		r.recorder.Event(instance, "Warning", "Failed", reason)
+		log.Error(err, "Failed to roll back addon.")
+		var nf *revision.NotFoundError
+		if !errors.As(err, &nf) {
+			return false, reconcile.Result{}, err
+		}
+		// Unknown revisions are not retried
+		delete(instance.Annotations, revision.RollbackAnnotation)
+		if err := r.updateAddon(ctx, instance); err != nil {
+			return false, reconcile.Result{}, err
+		}
+		instance.Status.Reason = reason
+		return false, reconcile.Result{}, nil
+	}
+
+	delete(instance.Annotations, revision.RollbackAnnotation)
+	instance.Spec = *spec
+	if err := r.updateAddon(ctx, instance); err != nil {
+		log.Error(err, "Failed to save addon rollback.")
+		return false, reconcile.Result{}, err
+	}
+	r.recorder.Event(instance, "Normal", "RolledBack", fmt.Sprintf("Addon %s/%s rolled back to revision %d.", instance.Namespace, instance.Name, number))
+	return false, reconcile.Result{Requeue: true}, nil
+}
+
+// renderCatalogAddon saves the spec rendered from the catalog of addons installed from a catalog,
+// it returns true when the saved spec is up to date and the addon can be processed
+func (r *AddonReconciler) renderCatalogAddon(ctx context.Context, log logr.Logger, instance *addonmgrv1alpha1.Addon) (bool, reconcile.Result, error) {
+	if instance.Spec.Catalog == "" {
+		instance.Status.Catalog = nil
+		meta.RemoveStatusCondition(&instance.Status.Conditions, addonmgrv1alpha1.DeprecatedCondition)
+		return true, reconcile.Result{}, nil
+	}
+	if r.catalogs == nil || !instance.ObjectMeta.DeletionTimestamp.IsZero() {
+		return true, reconcile.Result{}, nil
+	}
+
+	snapshot := r.catalogs.Get(types.NamespacedName{Namespace: instance.Namespace, Name: instance.Spec.Catalog})
+	if snapshot == nil {
+		instance.Status.Lifecycle.Installed = addonmgrv1alpha1.Pending
+		instance.Status.Reason = fmt.Sprintf("Addon %s/%s is waiting for catalog %s to sync.", instance.Namespace, instance.Name, instance.Spec.Catalog)
+		return false, reconcile.Result{RequeueAfter: 10 * time.Second}, nil
+	}
+
+	spec, err := snapshot.Render(instance)
+	if err != nil {
+		reason := fmt.Sprintf("Addon %s/%s could not be rendered from catalog %s. %v", instance.Namespace, instance.Name, instance.Spec.Catalog, err)
+		r.recorder.Event(instance, "Warning", "Failed", reason)
+		log.Error(err, "Addon could not be rendered from catalog.")
+		instance.Status.Lifecycle.Installed = addonmgrv1alpha1.Failed
+		instance.Status.StartTime = 0
+		instance.Status.Reason = reason
+		return false, reconcile.Result{}, err
+	}
+	if err := r.setDeprecation(ctx, instance, snapshot, spec.PkgVersion); err != nil {
+		reason := fmt.Sprintf("Addon %s/%s cannot be installed from catalog %s. %v", instance.Namespace, instance.Name, instance.Spec.Catalog, err)
+		r.recorder.Event(instance, "Warning", "Failed", reason)
+		log.Error(err, "Addon cannot be installed from catalog.")
+		instance.Status.Lifecycle.Installed = addonmgrv1alpha1.Failed
+		instance.Status.StartTime = 0
+		instance.Status.Reason = reason
+		return false, reconcile.Result{}, err
+	}
+	if equality.Semantic.DeepEqual(*spec, instance.Spec) {
+		r.setCatalogStatus(instance, snapshot.Revision())
+		return true, reconcile.Result{}, nil
+	}
+
+	previous := instance.Spec.PkgVersion
+	instance.Spec = *spec
+	if err := r.updateAddon(ctx, instance); err != nil {
+		log.Error(err, "Failed to save addon rendered from catalog.")
+		return false, reconcile.Result{}, err
+	}
+	r.setCatalogStatus(instance, snapshot.Revision())
+	switch {
+	case previous != "" && previous != spec.PkgVersion && spec.PkgChannel != "":
+		r.recorder.Event(instance, "Normal", "Upgraded", fmt.Sprintf("Addon %s/%s upgraded from version %s to %s following channel %s of catalog %s.", instance.Namespace, instance.Name, previous, spec.PkgVersion, spec.PkgChannel, instance.Spec.Catalog))
+	case previous != "" && previous != spec.PkgVersion:
+		r.recorder.Event(instance, "Normal", "Upgraded", fmt.Sprintf("Addon %s/%s upgraded from version %s to %s from catalog %s.", instance.Namespace, instance.Name, previous, spec.PkgVersion, instance.Spec.Catalog))
+	default:
+		r.recorder.Event(instance, "Normal", "Rendered", fmt.Sprintf("Addon %s/%s rendered version %s from catalog %s.", instance.Namespace, instance.Name, spec.PkgVersion, instance.Spec.Catalog))
+	}
+	return false, reconcile.Result{Requeue: true}, nil
+}
+
+// setDeprecation records the Deprecated condition of the catalog package version with a warning event when it changes,
+// new installs of versions past their end of life fail when the catalog blocks them
+func (r *AddonReconciler) setDeprecation(ctx context.Context, instance *addonmgrv1alpha1.Addon, snapshot *catalog.Snapshot, version string) error {
+	d := snapshot.Deprecation(instance.Spec.PkgName, version)
+	previous := meta.FindStatusCondition(instance.Status.Conditions, addonmgrv1alpha1.DeprecatedCondition)
+	if d == nil {
+		if previous != nil {
+			meta.SetStatusCondition(&instance.Status.Conditions, metav1.Condition{
+				Type:    addonmgrv1alpha1.DeprecatedCondition,
+				Status:  metav1.ConditionFalse,
+				Reason:  "Supported",
+				Message: fmt.Sprintf("Package %s:%s is not deprecated.", instance.Spec.PkgName, version),
+			})
+		}
+		return nil
+	}
+
+	now := r.clock.Now()
+	cond := metav1.Condition{
+		Type:    addonmgrv1alpha1.DeprecatedCondition,
+		Status:  metav1.ConditionTrue,
+		Reason:  "Deprecated",
+		Message: fmt.Sprintf("Package %s:%s is deprecated", instance.Spec.PkgName, version),
+	}
+	switch {
+	case d.IsEndOfLife(now):
+		cond.Reason = "EndOfLife"
+		cond.Message = fmt.Sprintf("Package %s:%s reached its end of life on %s", instance.Spec.PkgName, version, d.EndOfLife.Format("2006-01-02"))
+	case d.EndOfLife != nil:
+		cond.Message += fmt.Sprintf(", its end of life is %s", d.EndOfLife.Format("2006-01-02"))
+	}
+	if d.Message != "" {
+		cond.Message += ". " + strings.TrimSuffix(d.Message, ".")
+	}
+	cond.Message += "."
+	if previous == nil || previous.Status != cond.Status || previous.Reason != cond.Reason {
+		r.recorder.Event(instance, "Warning", "Deprecated", cond.Message)
+	}
+	meta.SetStatusCondition(&instance.Status.Conditions, cond)
+
+	// Addons that were installed with the version keep running
+	if !d.IsEndOfLife(now) || (instance.Status.Revision > 0 && version == instance.Spec.PkgVersion) {
+		return nil
+	}
+	var cat addonmgrv1alpha1.AddonCatalog
+	if err := r.Get(ctx, types.NamespacedName{Namespace: instance.Namespace, Name: instance.Spec.Catalog}, &cat); err != nil {
+		return err
+	}
+	if cat.Spec.BlockEndOfLife {
+		return fmt.Errorf("package %s:%s reached its end of life", instance.Spec.PkgName, version)
+	}
+	return nil
+}
+
+// checkKubeVersion sets the KubeVersionCompatible condition from the version of the target cluster and returns it,
+// a warning event is recorded when the addon becomes unsupported
+func (r *AddonReconciler) checkKubeVersion(instance *addonmgrv1alpha1.Addon, cluster *remote.Cluster) (*metav1.Condition, error) {
+	var dc discovery.DiscoveryInterface = r.generatedClient.Discovery()
+	if cluster != nil {
+		dc = cluster.Clientset.Discovery()
+	}
+	info, err := dc.ServerVersion()
+	if err != nil {
+		return nil, fmt.Errorf("could not get the cluster version. %v", err)
+	}
+
+	cond := metav1.Condition{
+		Type:    addonmgrv1alpha1.KubeVersionCompatibleCondition,
+		Status:  metav1.ConditionTrue,
+		Reason:  "Supported",
+		Message: fmt.Sprintf("Kubernetes %s is in the supported range %s.", info.GitVersion, instance.Spec.KubeVersion),
+	}
+	if err := addon.CheckKubeVersion(instance.Spec.KubeVersion, info.GitVersion); err != nil {
+		cond.Status, cond.Reason, cond.Message = metav1.ConditionFalse, "Unsupported", err.Error()
+		if previous := meta.FindStatusCondition(instance.Status.Conditions, cond.Type); previous == nil || previous.Status != cond.Status {
+			r.recorder.Event(instance, "Warning", "UnsupportedKubeVersion", fmt.Sprintf("Addon %s/%s %v", instance.Namespace, instance.Name, err))
+		}
+	}
+	meta.SetStatusCondition(&instance.Status.Conditions, cond)
+	return &cond, nil
+}
+
+// setCatalogStatus records the catalog revision the addon spec is rendered from
+func (r *AddonReconciler) setCatalogStatus(instance *addonmgrv1alpha1.Addon, revision string) {
+	status := &addonmgrv1alpha1.CatalogSyncStatus{
+		Revision: revision,
+		Channel:  instance.Spec.PkgChannel,
+		Version:  instance.Spec.PkgVersion,
+	}
+	if previous := instance.Status.Catalog; previous != nil && previous.Revision == revision {
+		status.LastSyncTime = previous.LastSyncTime
+	} else {
+		now := metav1.NewTime(r.clock.Now())
+		status.LastSyncTime = &now
+	}
+	instance.Status.Catalog = status
+}
+
+// setDependencyStatus records the state of the addon dependencies and the DependenciesReady condition, it returns
+// the addon dependencies that are not installed yet
+func (r *AddonReconciler) setDependencyStatus(ctx context.Context, instance *addonmgrv1alpha1.Addon) []string {
+	if len(instance.Spec.PkgDeps) == 0 && len(instance.Spec.AddonDeps) == 0 {
+		instance.Status.Dependencies = nil
+		meta.RemoveStatusCondition(&instance.Status.Conditions, addonmgrv1alpha1.DependenciesReadyCondition)
+		meta.RemoveStatusCondition(&instance.Status.Conditions, addonmgrv1alpha1.ConflictCondition)
+		return nil
+	}
+
+	addonDeps := addon.AddonDependencyStatuses(ctx, r.Client, instance)
+	instance.Status.Dependencies = append(addon.DependencyStatuses(instance, r.versionCache), addonDeps...)
+	var waiting, waitingAddons []string
+	for _, d := range instance.Status.Dependencies {
+		if !d.Satisfied {
+			waiting = append(waiting, d.Name+":"+d.Required)
+		}
+	}
+	for _, d := range addonDeps {
+		if !d.Satisfied {
+			waitingAddons = append(waitingAddons, d.Name)
+		}
+	}
+
+	cond := metav1.Condition{
+		Type:    addonmgrv1alpha1.DependenciesReadyCondition,
+		Status:  metav1.ConditionTrue,
+		Reason:  "Satisfied",
+		Message: "All dependencies are installed.",
+	}
+	if len(waiting) > 0 {
+		cond.Status, cond.Reason = metav1.ConditionFalse, addonmgrv1alpha1.DepsNotReadyReason
+		cond.Message = fmt.Sprintf("Waiting on dependencies %s.", strings.Join(waiting, ", "))
+	} else if prev := meta.FindStatusCondition(instance.Status.Conditions, addonmgrv1alpha1.DependenciesReadyCondition); prev != nil && prev.Status == metav1.ConditionFalse {
+		metrics.RecordDependencyWait(r.clock.Now().Sub(prev.LastTransitionTime.Time))
+	}
+	meta.SetStatusCondition(&instance.Status.Conditions, cond)
+
+	var conflicts []string
+	for _, d := range instance.Status.Dependencies {
+		for _, c := range d.Conflicts {
+			conflicts = append(conflicts, fmt.Sprintf("%s requires %s:%s, the addon requires %s", c.Addon, d.Name, c.Required, d.Required))
+		}
+	}
+	if len(conflicts) > 0 {
+		meta.SetStatusCondition(&instance.Status.Conditions, metav1.Condition{
+			Type:    addonmgrv1alpha1.ConflictCondition,
+			Status:  metav1.ConditionTrue,
+			Reason:  "IncompatibleConstraints",
+			Message: fmt.Sprintf("Incompatible dependency versions: %s.", strings.Join(conflicts, "; ")),
+		})
+	} else if meta.FindStatusCondition(instance.Status.Conditions, addonmgrv1alpha1.ConflictCondition) != nil {
+		meta.SetStatusCondition(&instance.Status.Conditions, metav1.Condition{
+			Type:    addonmgrv1alpha1.ConflictCondition,
+			Status:  metav1.ConditionFalse,
+			Reason:  "NoConflicts",
+			Message: "No installed addons require incompatible versions of the dependencies.",
+		})
+	}
+	return waitingAddons
+}
+
+// setSyncCondition records the checksum of the installed spec and sets the OutOfSync condition while it is not the
+// checksum of the current spec
+func (r *AddonReconciler) setSyncCondition(instance *addonmgrv1alpha1.Addon) {
+	current := r.checksums.Checksum(instance)
+	if instance.Status.Lifecycle.Installed == addonmgrv1alpha1.Succeeded && instance.Status.Checksum == current && instance.Status.PendingUpgrade == nil {
+		instance.Status.InstalledChecksum = current
+	}
+
+	cond := metav1.Condition{
+		Type:    addonmgrv1alpha1.OutOfSyncCondition,
+		Status:  metav1.ConditionFalse,
+		Reason:  "Synced",
+		Message: fmt.Sprintf("The installed spec %s is the current spec.", current),
+	}
+	if installed := instance.Status.InstalledChecksum; installed != current {
+		cond.Status = metav1.ConditionTrue
+		switch {
+		case installed == "":
+			cond.Reason = "NotInstalled"
+			cond.Message = fmt.Sprintf("The current spec %s is not installed yet.", current)
+		case instance.Status.PendingUpgrade != nil:
+			cond.Reason = "Deferred"
+			cond.Message = fmt.Sprintf("The spec %s is installed, the current spec %s waits for the maintenance window.", installed, current)
+		case instance.Status.Lifecycle.Installed == addonmgrv1alpha1.Failed:
+			cond.Reason = "InstallFailed"
+			cond.Message = fmt.Sprintf("The spec %s is installed, the install of the current spec %s failed.", installed, current)
+		default:
+			cond.Reason = "Installing"
+			cond.Message = fmt.Sprintf("The spec %s is installed, the current spec %s is being installed.", installed, current)
+		}
+	}
+	meta.SetStatusCondition(&instance.Status.Conditions, cond)
+}
+
+// deferUpgrade returns true while the upgrade of the installed addon to the current spec waits for the maintenance
+// window, and the time the window opens next. The pending upgrade is recorded in the status.
+func (r *AddonReconciler) deferUpgrade(instance *addonmgrv1alpha1.Addon) (bool, time.Time, error) {
+	installed := instance.Status.InstalledChecksum
+	if instance.Spec.MaintenanceWindow == nil || installed == "" || installed == instance.Status.Checksum || upgradeStarted(instance) {
+		instance.Status.PendingUpgrade = nil
+		return false, time.Time{}, nil
+	}
+
+	open, next, err := addon.MaintenanceWindowOpen(instance.Spec.MaintenanceWindow, r.clock.Now())
+	if err != nil || open {
+		instance.Status.PendingUpgrade = nil
+		return false, time.Time{}, err
+	}
+
+	pending := &addonmgrv1alpha1.PendingUpgrade{Checksum: instance.Status.Checksum, PkgVersion: instance.Spec.PkgVersion}
+	if !next.IsZero() {
+		t := metav1.NewTime(next)
+		pending.NextWindow = &t
+	}
+	instance.Status.PendingUpgrade = pending
+	return true, next, nil
+}
+
+// upgradeStarted returns true once a lifecycle workflow of the current spec was submitted
+func upgradeStarted(instance *addonmgrv1alpha1.Addon) bool {
+	for _, step := range []addonmgrv1alpha1.LifecycleStep{addonmgrv1alpha1.Prereqs, addonmgrv1alpha1.Install, addonmgrv1alpha1.Upgrade} {
+		if timing := instance.GetStepTiming(step); timing != nil && timing.Workflow == instance.WorkflowName(step, instance.GetChecksum()) {
+			return true
+		}
+	}
+	return false
+}
+
+// specInstalled returns true when the install or upgrade workflow of the current spec succeeded
+func specInstalled(instance *addonmgrv1alpha1.Addon) bool {
+	if instance.Status.Lifecycle.Installed != addonmgrv1alpha1.Succeeded {
+		return false
+	}
+	step := instance.InstallStep()
+	timing := instance.GetStepTiming(step)
+	return timing == nil || timing.Workflow == instance.WorkflowName(step, instance.GetChecksum())
+}
+
+// waitForDependents returns true while installed addons depend on the package of the addon being deleted,
+// with the Cascade deletion policy the dependents are deleted too
+func (r *AddonReconciler) waitForDependents(ctx context.Context, instance *addonmgrv1alpha1.Addon) (bool, error) {
+	pkg := deps.Package{PackageSpec: instance.GetPackageSpec(), Addon: instance.Namespace + "/" + instance.Name}
+	dependents := deps.Dependents(pkg, addon.InstalledPackages(r.versionCache))
+	if len(dependents) == 0 {
+		if meta.FindStatusCondition(instance.Status.Conditions, addonmgrv1alpha1.DependentExistsCondition) != nil {
+			meta.SetStatusCondition(&instance.Status.Conditions, metav1.Condition{
+				Type:    addonmgrv1alpha1.DependentExistsCondition,
+				Status:  metav1.ConditionFalse,
+				Reason:  "NoDependents",
+				Message: "No installed addons depend on the addon.",
+			})
+		}
+		return false, nil
+	}
+
+	var names []string
+	for _, d := range dependents {
+		names = append(names, d.Addon)
+	}
+
+	condReason, reason := "Blocked", fmt.Sprintf("Addon %s/%s cannot be deleted, addons %s depend on it.", instance.Namespace, instance.Name, strings.Join(names, ", "))
+	if instance.Spec.DeletionPolicy == addonmgrv1alpha1.DeletionPolicyCascade {
+		for _, d := range dependents {
+			key := strings.SplitN(d.Addon, "/", 2)
+			dependent := &addonmgrv1alpha1.Addon{ObjectMeta: metav1.ObjectMeta{Namespace: key[0], Name: key[1]}}
+			if err := r.Delete(ctx, dependent); client.IgnoreNotFound(err) != nil {
+				return true, err
+			}
+		}
+		condReason, reason = "Cascading", fmt.Sprintf("Addon %s/%s is deleting dependents %s.", instance.Namespace, instance.Name, strings.Join(names, ", "))
+	}
+
+	if !meta.IsStatusConditionPresentAndEqual(instance.Status.Conditions, addonmgrv1alpha1.DependentExistsCondition, metav1.ConditionTrue) {
+		r.recorder.Event(instance, "Warning", addonmgrv1alpha1.DependentExistsCondition, reason)
+	}
+	meta.SetStatusCondition(&instance.Status.Conditions, metav1.Condition{
+		Type:    addonmgrv1alpha1.DependentExistsCondition,
+		Status:  metav1.ConditionTrue,
+		Reason:  condReason,
+		Message: reason,
+	})
+	instance.Status.Reason = reason
+
+	return true, nil
+}
+
+func (r *AddonReconciler) runWorkflow(lifecycleStep addonmgrv1alpha1.LifecycleStep, addon *addonmgrv1alpha1.Addon, wfl workflows.AddonLifecycle) (addonmgrv1alpha1.ApplicationAssemblyPhase, error) {
+	log := r.Log.WithValues("addon", fmt.Sprintf("%s/%s", addon.Namespace, addon.Name))
+
+	wt, err := workflows.LifecycleWorkflow(addon, lifecycleStep)
+	if err != nil {
+		log.Error(err, "lifecycleStep workflow could not be resolved", "lifecycleStep", lifecycleStep)
+		return addonmgrv1alpha1.Failed, err
+	}
+
+	if wt.Template == "" {
+		// No workflow was provided, so mark as succeeded
+		return addonmgrv1alpha1.Succeeded, nil
+	}
+
+	if addon.StepSkipped(lifecycleStep) {
+		r.recorder.Event(addon, "Normal", "Skipped", fmt.Sprintf("Skipped %s workflow of addon %s/%s.", strings.Title(string(lifecycleStep)), addon.Namespace, addon.Name))
+		return addonmgrv1alpha1.Succeeded, nil
+	}
+
+	wfIdentifierName := addon.WorkflowName(lifecycleStep, addon.GetChecksum())
+	if wfIdentifierName == "" {
+		return addonmgrv1alpha1.Failed, fmt.Errorf("could not generate workflow template name")
+	}
+	phase, err := wfl.Install(context.TODO(), wt, wfIdentifierName)
+	r.recordStepTiming(addon, lifecycleStep, wfIdentifierName, phase)
+	if err != nil {
+		return phase, err
+	}
+	r.recorder.Event(addon, "Normal", "Completed", fmt.Sprintf("Completed %s workflow %s/%s.", strings.Title(string(lifecycleStep)), addon.Namespace, wfIdentifierName))
+	return phase, nil
+}
+
+// installAndValidate runs the install workflow, or the upgrade workflow of installed addons, and, when the addon is
+// gated on validation, the validate workflow once the install succeeded so the addon is only Succeeded after its
+// smoke test passed
+func (r *AddonReconciler) installAndValidate(instance *addonmgrv1alpha1.Addon, wfl workflows.AddonLifecycle) (addonmgrv1alpha1.ApplicationAssemblyPhase, error) {
+	phase, err := r.runStep(instance.InstallStep(), instance, wfl)
+	if err != nil || phase != addonmgrv1alpha1.Succeeded || !r.validateGated(instance) {
+		return phase, err
+	}
+
+	phase, err = r.runStep(addonmgrv1alpha1.Validate, instance, wfl)
+	if err != nil {
+		return phase, err
+	}
+	if phase == addonmgrv1alpha1.Failed {
+		return phase, fmt.Errorf("validate workflow failed")
+	}
+	return phase, nil
+}
+
+// runStep runs the workflow of the lifecycle step unless the step succeeded with the same step checksum since the
+// addon was last installed, so retries after a partial failure resume at the failed step. The failed step is
+// recorded in the status.
+func (r *AddonReconciler) runStep(step addonmgrv1alpha1.LifecycleStep, instance *addonmgrv1alpha1.Addon, wfl workflows.AddonLifecycle) (addonmgrv1alpha1.ApplicationAssemblyPhase, error) {
+	if instance.StepSucceeded(step) {
+		r.collectWorkflow(step, instance, wfl)
+		return addonmgrv1alpha1.Succeeded, nil
+	}
+
+	phase, err := r.runWorkflow(step, instance, wfl)
+	if err != nil || phase == addonmgrv1alpha1.Failed {
+		instance.Status.Lifecycle.FailedStep = step
+	} else if phase == addonmgrv1alpha1.Succeeded {
+		instance.SetStepSucceeded(step)
+		if instance.Status.Lifecycle.FailedStep == step {
+			instance.Status.Lifecycle.FailedStep = ""
+		}
+	}
+	return phase, err
+}
+
+// checkFailureBudget returns true when the workflows of the addon may be submitted. The resume annotation closes the
+// circuit and submits the failed workflow again, a new spec starts with the full budget. While the circuit is open
+// the addon stays Failed, failed installs are submitted again once their backoff has passed.
+func (r *AddonReconciler) checkFailureBudget(ctx context.Context, log logr.Logger, instance *addonmgrv1alpha1.Addon, wfl workflows.AddonLifecycle) (bool, reconcile.Result, error) {
+	if status := instance.Status.FailureBudget; status != nil && status.Checksum != instance.Status.Checksum {
+		instance.Status.FailureBudget = nil
+		r.closeCircuit(instance, "SpecChanged", "The spec of the addon changed.")
+	}
+
+	if _, ok := instance.GetAnnotations()[addonmgrv1alpha1.ResumeAnnotation]; ok {
+		delete(instance.Annotations, addonmgrv1alpha1.ResumeAnnotation)
+		if err := r.updateAddon(ctx, instance); err != nil {
+			log.Error(err, "Failed to remove addon resume annotation.")
+			return false, reconcile.Result{}, err
+		}
+		if err := r.deleteFailedWorkflow(ctx, log, instance, wfl); err != nil {
+			return false, reconcile.Result{}, err
+		}
+		instance.Status.FailureBudget = nil
+		r.closeCircuit(instance, "Resumed", fmt.Sprintf("The addon was resumed with the %s annotation.", addonmgrv1alpha1.ResumeAnnotation))
+		r.recorder.Event(instance, "Normal", "Resumed", fmt.Sprintf("Addon %s/%s resumed, failed workflows are submitted again.", instance.Namespace, instance.Name))
+	}
+
+	status := instance.Status.FailureBudget
+	if status != nil && meta.IsStatusConditionTrue(instance.Status.Conditions, addonmgrv1alpha1.CircuitOpenCondition) {
+		instance.Status.Lifecycle.Installed = addonmgrv1alpha1.Failed
+		instance.Status.StartTime = 0
+		instance.Status.Reason = fmt.Sprintf("Addon %s/%s install failed %d times, its workflows are not submitted until it is resumed with the %s annotation.", instance.Namespace, instance.Name, status.Failures, addonmgrv1alpha1.ResumeAnnotation)
+		return false, reconcile.Result{}, nil
+	}
+	if wait := r.failureBudget.RetryAfter(status, r.clock.Now()); wait > 0 && instance.Status.Lifecycle.Installed == addonmgrv1alpha1.Failed {
+		instance.Status.StartTime = 0
+		instance.Status.Reason = fmt.Sprintf("Addon %s/%s install failed %d times, retrying in %s.", instance.Namespace, instance.Name, status.Failures, wait.Round(time.Second))
+		return false, reconcile.Result{RequeueAfter: wait}, nil
+	}
+	// Workflow names derive from the checksum, the failed workflow is deleted once the backoff passed so that the
+	// install is submitted again. The addon stays Failed until the new workflow is observed, its failure is counted.
+	if status != nil && r.failureBudget.Enabled() && instance.Status.Lifecycle.Installed == addonmgrv1alpha1.Failed {
+		if err := r.deleteFailedWorkflow(ctx, log, instance, wfl); err != nil {
+			return false, reconcile.Result{}, err
+		}
+		r.recorder.Event(instance, "Normal", "Retrying", fmt.Sprintf("Addon %s/%s install failed %d times, submitting its workflows again.", instance.Namespace, instance.Name, status.Failures))
 	}
+	return true, reconcile.Result{}, nil
+}
 
-	// Add addon to cache
-	//r.addAddonToCache(req, addon, addonmgrv1alpha1.Pending)
+// deleteFailedWorkflow deletes the workflow of the failed lifecycle step so that it is submitted again
+func (r *AddonReconciler) deleteFailedWorkflow(ctx context.Context, log logr.Logger, instance *addonmgrv1alpha1.Addon, wfl workflows.AddonLifecycle) error {
+	step := instance.Status.Lifecycle.FailedStep
+	if step == "" {
+		return nil
+	}
+	if err := wfl.Delete(ctx, instance.WorkflowName(step, instance.GetChecksum())); err != nil && !apierrors.IsNotFound(err) {
+		log.Error(err, "Failed to delete failed workflow.", "step", step)
+		return err
+	}
+	return nil
+}
 
-	// Prereqs workflow
-	prereqsPhase, err := r.runWorkflow(addonmgrv1alpha1.Prereqs, instance, wfl)
-	instance.Status.Lifecycle.Prereqs = prereqsPhase
+// recordInstallFailure counts a failed install against the failure budget and opens the circuit once it is exhausted.
+// Only the transition to Failed of a submitted install is counted, a failed workflow observed again by later
+// reconciles is not.
+func (r *AddonReconciler) recordInstallFailure(instance *addonmgrv1alpha1.Addon) {
+	if !r.failureBudget.Enabled() || instance.Status.Lifecycle.Installed == addonmgrv1alpha1.Failed {
+		return
+	}
+	status, open := r.failureBudget.RecordFailure(instance.Status.FailureBudget, instance.Status.Checksum, r.clock.Now())
+	instance.Status.FailureBudget = status
+	if !open {
+		return
+	}
+	message := fmt.Sprintf("Addon %s/%s install failed %d times within %s, its workflows are not submitted until it is resumed with the %s annotation.", instance.Namespace, instance.Name, status.Failures, r.failureBudget.Window, addonmgrv1alpha1.ResumeAnnotation)
+	meta.SetStatusCondition(&instance.Status.Conditions, metav1.Condition{
+		Type:    addonmgrv1alpha1.CircuitOpenCondition,
+		Status:  metav1.ConditionTrue,
+		Reason:  "FailureBudgetExhausted",
+		Message: message,
+	})
+	r.recorder.Event(instance, "Warning", addonmgrv1alpha1.CircuitOpenCondition, message)
+}
+
+// closeCircuit sets the CircuitOpen condition of an addon whose circuit was open to False
+func (r *AddonReconciler) closeCircuit(instance *addonmgrv1alpha1.Addon, reason, message string) {
+	if !meta.IsStatusConditionTrue(instance.Status.Conditions, addonmgrv1alpha1.CircuitOpenCondition) {
+		return
+	}
+	meta.SetStatusCondition(&instance.Status.Conditions, metav1.Condition{
+		Type:    addonmgrv1alpha1.CircuitOpenCondition,
+		Status:  metav1.ConditionFalse,
+		Reason:  reason,
+		Message: message,
+	})
+}
+
+// collectWorkflow deletes the workflow of a lifecycle step whose success is recorded in the status read from the
+// API server when workflows are garbage collected once their result is recorded. A failed delete is retried by the
+// next reconcile, the Argo TTL deletes workflows that are never collected.
+func (r *AddonReconciler) collectWorkflow(step addonmgrv1alpha1.LifecycleStep, instance *addonmgrv1alpha1.Addon, wfl workflows.AddonLifecycle) {
+	if r.workflowGC != workflows.GCOnStatusRecorded {
+		return
+	}
+	name := instance.WorkflowName(step, instance.GetChecksum())
+	collected, err := wfl.Collect(context.TODO(), name)
 	if err != nil {
-		reason := fmt.Sprintf("Addon %s/%s prereqs failed. %v", instance.Namespace, instance.Name, err)
-		r.recorder.Event(instance, "Warning", "Failed", reason)
-		log.Error(err, "Addon prereqs workflow failed.")
-		// if prereqs failed, set install status to failed as well so that STATUS is updated
-		instance.Status.Lifecycle.Installed = addonmgrv1alpha1.Failed
-		instance.Status.StartTime = 0
-		instance.Status.Reason = reason
+		r.Log.Error(err, "Failed to delete succeeded workflow.", "addon", fmt.Sprintf("%s/%s", instance.Namespace, instance.Name), "workflow", name)
+		return
+	}
+	if collected {
+		r.recorder.Event(instance, "Normal", "WorkflowDeleted", fmt.Sprintf("Deleted succeeded %s workflow %s/%s, its result is recorded in the status.", strings.Title(string(step)), instance.Namespace, name))
+	}
+}
 
-		return reconcile.Result{}, err
+// rollbackFailedInstall submits the install workflow of the last revision installed successfully again after the
+// install of the current spec failed. The spec is not changed, a rollback failing is only recorded in the status.
+func (r *AddonReconciler) rollbackFailedInstall(ctx context.Context, log logr.Logger, instance *addonmgrv1alpha1.Addon, opts []workflows.Option) {
+	if instance.Status.Revision == 0 {
+		return
+	}
+	number, spec, err := r.history.Find(ctx, instance, strconv.FormatInt(instance.Status.Revision, 10))
+	if err != nil {
+		log.Error(err, "Failed to find addon revision to roll back to.")
+		return
 	}
 
-	//handle Prereqs failure
-	if instance.Status.Lifecycle.Prereqs == addonmgrv1alpha1.Failed {
-		reason := fmt.Sprintf("Addon %s/%s Prereqs status is Failed", instance.Namespace, instance.Name)
-		r.recorder.Event(instance, "Warning", "Failed", reason)
-		log.Error(err, "Addon prereqs workflow failed.")
-		// if prereqs failed, set install status to failed as well so that STATUS is updated
-		instance.Status.Lifecycle.Installed = addonmgrv1alpha1.Failed
-		instance.Status.StartTime = 0
-		instance.Status.Reason = reason
+	prev := workflows.RevisionAddon(instance, spec)
+	if prev.CalculateChecksum() == instance.CalculateChecksum() {
+		// The failed spec is the last revision installed successfully
+		return
+	}
+	wt, err := workflows.LifecycleWorkflow(prev, addonmgrv1alpha1.Install)
+	if err != nil || wt.Template == "" {
+		return
+	}
 
-		return reconcile.Result{}, fmt.Errorf(reason)
+	status := instance.Status.Rollback
+	if status == nil || status.FailedChecksum != instance.Status.Checksum {
+		status = &addonmgrv1alpha1.RollbackStatus{
+			Revision:       number,
+			Checksum:       prev.Status.Checksum,
+			FailedChecksum: instance.Status.Checksum,
+			Workflow:       workflows.RollbackWorkflowName(instance, instance.Status.Checksum),
+		}
+		r.recorder.Event(instance, "Warning", "RollingBack", fmt.Sprintf("Addon %s/%s install failed, rolling back to revision %d.", instance.Namespace, instance.Name, number))
+	}
+	instance.Status.Rollback = status
+	// Completed rollbacks are not retried
+	if status.Phase == addonmgrv1alpha1.Succeeded || status.Phase == addonmgrv1alpha1.Failed {
+		return
 	}
 
-	// Validate secrets are in the addon deployment namespace, this is here and not in validator b/c namespace must be used to validate.
-	if instance.Status.Lifecycle.Prereqs == addonmgrv1alpha1.Succeeded {
-		if err := r.validateSecrets(ctx, instance); err != nil {
-			reason := fmt.Sprintf("Addon %s/%s could not validate secrets. %v", instance.Namespace, instance.Name, err)
-			r.recorder.Event(instance, "Warning", "Failed", reason)
-			log.Error(err, "Addon could not validate secrets.")
-			instance.Status.Lifecycle.Installed = addonmgrv1alpha1.Failed
-			instance.Status.StartTime = 0
-			instance.Status.Reason = reason
+	wfl := workflows.NewWorkflowLifecycle(r.Client, r.dynClient, prev, r.recorder, r.Scheme, opts...)
+	phase, err := wfl.Install(ctx, wt, status.Workflow)
+	if err != nil {
+		r.recorder.Event(instance, "Warning", "RollbackFailed", fmt.Sprintf("Addon %s/%s could not be rolled back to revision %d. %v", instance.Namespace, instance.Name, number, err))
+		log.Error(err, "Failed to roll back addon.")
+		return
+	}
+	status.Phase = phase
+	switch phase {
+	case addonmgrv1alpha1.Failed:
+		r.recorder.Event(instance, "Warning", "RollbackFailed", fmt.Sprintf("Addon %s/%s rollback workflow %s to revision %d failed.", instance.Namespace, instance.Name, status.Workflow, number))
+	case addonmgrv1alpha1.Succeeded:
+		r.recorder.Event(instance, "Normal", "RolledBack", fmt.Sprintf("Addon %s/%s rolled back to revision %d.", instance.Namespace, instance.Name, number))
+	}
+}
 
-			return reconcile.Result{}, err
+// recordInstalledResources records the objects the artifacts of the installed spec apply. On upgrades the change of the
+// objects is recorded and the objects the previously installed spec applied that the artifacts no longer contain are
+// pruned. Objects that could not be pruned are retried on the next reconcile, they do not fail the install.
+func (r *AddonReconciler) recordInstalledResources(ctx context.Context, log logr.Logger, instance *addonmgrv1alpha1.Addon, cluster *remote.Cluster) {
+	installed := instance.Status.InstalledResources
+	if installed != nil && installed.Checksum == instance.Status.Checksum {
+		return
+	}
+
+	refs, err := workflows.ResourceReferences(instance)
+	if err != nil {
+		log.Error(err, "Failed to list addon artifacts.")
+		return
+	}
+
+	current := &addonmgrv1alpha1.InstalledResources{Checksum: instance.Status.Checksum, Resources: refs}
+	if installed != nil {
+		dynClient, namespace := r.dynClient, instance.GetNamespace()
+		if cluster != nil {
+			dynClient = cluster.Dynamic
+		} else if r.workflowNs != "" {
+			namespace = r.workflowNs
 		}
 
-		phase, err := r.runWorkflow(addonmgrv1alpha1.Install, instance, wfl)
-		instance.Status.Lifecycle.Installed = phase
+		pruned, err := workflows.Prune(ctx, dynClient, instance, namespace, workflows.RemovedResources(installed.Resources, refs))
+		if len(pruned) > 0 {
+			r.recorder.Event(instance, "Normal", "Pruned", fmt.Sprintf("Addon %s/%s pruned resources removed from its artifacts %s.", instance.Namespace, instance.Name, strings.Join(pruned, ", ")))
+		}
 		if err != nil {
-			reason := fmt.Sprintf("Addon %s/%s could not be installed due to error. %v", instance.Namespace, instance.Name, err)
-			r.recorder.Event(instance, "Warning", "Failed", reason)
-			log.Error(err, "Addon install workflow failed.")
-			instance.Status.StartTime = 0
-			instance.Status.Reason = reason
+			r.recorder.Event(instance, "Warning", "PruneFailed", fmt.Sprintf("Addon %s/%s could not prune resources removed from its artifacts. %v", instance.Namespace, instance.Name, err))
+			log.Error(err, "Failed to prune addon resources.")
+			return
+		}
 
-			return reconcile.Result{}, err
+		diff := workflows.DiffResources(installed, current)
+		instance.Status.LastUpgrade = diff
+		r.recorder.Event(instance, "Normal", "Upgraded", fmt.Sprintf("Addon %s/%s upgrade added %d, removed %d and changed %d resources. added: [%s] removed: [%s] changed: [%s]",
+			instance.Namespace, instance.Name, len(diff.Added), len(diff.Removed), len(diff.Changed),
+			strings.Join(diff.Added, ", "), strings.Join(diff.Removed, ", "), strings.Join(diff.Changed, ", ")))
+	}
+
+	instance.Status.InstalledResources = current
+}
+
+// recordHelmRelease records the state of the Helm release of addons installed from a chart
+func (r *AddonReconciler) recordHelmRelease(ctx context.Context, log logr.Logger, instance *addonmgrv1alpha1.Addon, cluster *remote.Cluster) {
+	if r.simulator != nil {
+		return
+	}
+	dynClient := r.dynClient
+	if cluster != nil {
+		dynClient = cluster.Dynamic
+	}
+
+	status, err := workflows.HelmReleaseStatus(ctx, dynClient, instance)
+	if err != nil {
+		log.Error(err, "Failed to get addon helm release.")
+		return
+	}
+	instance.Status.Helm = status
+}
+
+// validateGated returns true when the install of the addon is not complete until its validate workflow succeeds
+func (r *AddonReconciler) validateGated(instance *addonmgrv1alpha1.Addon) bool {
+	if gate := instance.Spec.Lifecycle.ValidateGate; gate != nil {
+		return *gate
+	}
+	return r.validateGate
+}
+
+// installBlueGreen installs a new version of the addon in the candidate slot alongside the active slot and cuts over
+// once the validate workflow of the candidate succeeds, the workloads of the previous slot are deleted on cut over
+func (r *AddonReconciler) installBlueGreen(ctx context.Context, instance *addonmgrv1alpha1.Addon, cluster *remote.Cluster, opts []workflows.Option) (addonmgrv1alpha1.ApplicationAssemblyPhase, error) {
+	status := instance.Status.Strategy
+	if status == nil {
+		status = &addonmgrv1alpha1.StrategyStatus{}
+		instance.Status.Strategy = status
+	}
+
+	if status.ActiveChecksum == instance.Status.Checksum {
+		// The spec was reverted while a new version was installed, drop the candidate slot
+		if status.Candidate != "" {
+			if err := r.deleteInactiveSlots(ctx, instance, cluster, status.Active); err != nil {
+				return addonmgrv1alpha1.Failed, fmt.Errorf("could not delete workloads of the %s slot. %v", status.Candidate, err)
+			}
+			status.Candidate = ""
 		}
+		return addonmgrv1alpha1.Succeeded, nil
+	}
 
-		//r.addAddonToCache(req, instance, phase)
+	if status.Candidate == "" {
+		status.Candidate = addonmgrv1alpha1.OtherSlot(status.Active)
 	}
+	wfl := workflows.NewWorkflowLifecycle(r.Client, r.dynClient, instance, r.recorder, r.Scheme, append(opts, workflows.WithSlot(status.Candidate))...)
 
-	// Observe resources matching selector labels.
-	observed, err := r.observeResources(ctx, instance)
+	phase, err := r.runWorkflow(addonmgrv1alpha1.Install, instance, wfl)
+	if err != nil || phase != addonmgrv1alpha1.Succeeded {
+		return phase, err
+	}
+
+	phase, err = r.runWorkflow(addonmgrv1alpha1.Validate, instance, wfl)
 	if err != nil {
-		reason := fmt.Sprintf("Addon %s/%s failed to find deployed resources. %v", instance.Namespace, instance.Name, err)
-		r.recorder.Event(instance, "Warning", "Failed", reason)
-		log.Error(err, "Addon failed to find deployed resources.")
-		instance.Status.Lifecycle.Installed = addonmgrv1alpha1.Failed
-		instance.Status.StartTime = 0
-		instance.Status.Reason = reason
+		return phase, err
+	}
+	if phase == addonmgrv1alpha1.Failed {
+		return phase, fmt.Errorf("validate workflow of the %s slot failed", status.Candidate)
+	}
+	if phase != addonmgrv1alpha1.Succeeded {
+		return phase, nil
+	}
 
-		return reconcile.Result{}, err
+	// Delete the previous version before switching slots so a failed delete is retried
+	if err := r.deleteInactiveSlots(ctx, instance, cluster, status.Candidate); err != nil {
+		return addonmgrv1alpha1.Failed, fmt.Errorf("could not delete workloads of the %s slot. %v", addonmgrv1alpha1.OtherSlot(status.Candidate), err)
 	}
+	status.Active, status.ActiveChecksum, status.Candidate = status.Candidate, instance.Status.Checksum, ""
+	r.recorder.Event(instance, "Normal", "CutOver", fmt.Sprintf("Addon %s/%s cut over to the %s slot.", instance.Namespace, instance.Name, status.Active))
 
-	if len(observed) > 0 {
-		instance.Status.Resources = observed
+	return addonmgrv1alpha1.Succeeded, nil
+}
+
+// deleteInactiveSlots deletes the workloads of the addon that are not in the slot, including workloads installed before
+// the addon used the blue-green strategy
+func (r *AddonReconciler) deleteInactiveSlots(ctx context.Context, a *addonmgrv1alpha1.Addon, cluster *remote.Cluster, slot string) error {
+	dynClient := r.dynClient
+	if cluster != nil {
+		dynClient = cluster.Dynamic
 	}
 
-	return ctrl.Result{}, nil
+	selector := fmt.Sprintf("app.kubernetes.io/managed-by=%s,app.kubernetes.io/name=%s,%s notin (%s)",
+		common.AddonGVR().Group, a.GetName(), workflows.SlotLabel, slot)
+	propagation := metav1.DeletePropagationBackground
+
+	for _, resc := range resources {
+		gvk := resc.GetObjectKind().GroupVersionKind()
+		// Services are shared by both slots
+		if gvk.Kind == "Service" {
+			continue
+		}
+
+		gvr := schema.GroupVersionResource{
+			Group:    gvk.Group,
+			Version:  gvk.Version,
+			Resource: inflection.Plural(strings.ToLower(gvk.Kind)),
+		}
+		list, err := dynClient.Resource(gvr).Namespace(a.Spec.Params.Namespace).List(ctx, metav1.ListOptions{LabelSelector: selector})
+		if err != nil {
+			return err
+		}
+		for _, item := range list.Items {
+			err := dynClient.Resource(gvr).Namespace(item.GetNamespace()).Delete(ctx, item.GetName(), metav1.DeleteOptions{PropagationPolicy: &propagation})
+			if err != nil && !apierrors.IsNotFound(err) {
+				return err
+			}
+		}
+	}
+
+	return nil
 }
 
-func ignoreNotFound(err error) error {
+// recordStepTiming tracks when the step workflow started and completed in the addon status
+func (r *AddonReconciler) recordStepTiming(addon *addonmgrv1alpha1.Addon, lifecycleStep addonmgrv1alpha1.LifecycleStep, wfName string, phase addonmgrv1alpha1.ApplicationAssemblyPhase) {
+	now := metav1.NewTime(r.clock.Now())
+	timing := addon.GetStepTiming(lifecycleStep)
+	if timing == nil || timing.Workflow != wfName {
+		timing = &addonmgrv1alpha1.LifecycleStepTiming{
+			Workflow:  wfName,
+			StartTime: &now,
+		}
+	}
+
+	if timing.CompletionTime == nil && (phase == addonmgrv1alpha1.Succeeded || phase == addonmgrv1alpha1.Failed) {
+		timing.CompletionTime = &now
+		timing.Duration = &metav1.Duration{Duration: now.Sub(timing.StartTime.Time)}
+		metrics.RecordWorkflowDuration(lifecycleStep, phase, timing.Duration.Duration)
+	}
+
+	addon.SetStepTiming(lifecycleStep, timing)
+}
+
+// readCommonParams returns the data of the common params ConfigMap, empty when it does not exist
+func (r *AddonReconciler) readCommonParams() (map[string]string, error) {
+	if r.commonParamsInformer == nil {
+		return nil, fmt.Errorf("common params are not set up")
+	}
+	if !r.commonParamsInformer.Informer().HasSynced() {
+		return nil, fmt.Errorf("common params configmap is not synced yet")
+	}
+	cm, err := r.commonParamsInformer.Lister().ConfigMaps(r.commonParams.Namespace).Get(r.commonParams.Name)
 	if apierrors.IsNotFound(err) {
-		return nil
+		return nil, nil
 	}
-	return err
+	if err != nil {
+		return nil, err
+	}
+	return cm.Data, nil
 }
 
-func (r *AddonReconciler) runWorkflow(lifecycleStep addonmgrv1alpha1.LifecycleStep, addon *addonmgrv1alpha1.Addon, wfl workflows.AddonLifecycle) (addonmgrv1alpha1.ApplicationAssemblyPhase, error) {
-	log := r.Log.WithValues("addon", fmt.Sprintf("%s/%s", addon.Namespace, addon.Name))
+// sourceDigest returns the digest of the content resolved from outside the spec. Only the content that is set is
+// digested so the checksums of addons with a SOPS document alone are kept.
+func sourceDigest(sopsDoc, commonParams, secretsDigest string) string {
+	contents := []string{sopsDoc}
+	if commonParams != "" {
+		contents = append(contents, "commonParams:"+commonParams)
+	}
+	if secretsDigest != "" {
+		contents = append(contents, "secrets:"+secretsDigest)
+	}
+	return addonmgrv1alpha1.SourceDigest(contents...)
+}
 
-	wt, err := addon.GetWorkflowType(lifecycleStep)
+// commonParamsDigest returns the digest of the common params ConfigMap, empty when none is configured or it does
+// not exist
+func (r *AddonReconciler) commonParamsDigest() (string, error) {
+	if r.commonParams.Name == "" {
+		return "", nil
+	}
+	params, err := r.readCommonParams()
+	if err != nil || len(params) == 0 {
+		return "", err
+	}
+	data, err := json.Marshal(params)
 	if err != nil {
-		log.Error(err, "lifecycleStep is not a field in LifecycleWorkflowSpec", "lifecycleStep", lifecycleStep)
-		return addonmgrv1alpha1.Failed, err
+		return "", err
 	}
+	return addonmgrv1alpha1.SourceDigest(string(data)), nil
+}
 
-	if wt.Template == "" {
-		// No workflow was provided, so mark as succeeded
-		return addonmgrv1alpha1.Succeeded, nil
+// secretsDigest returns the digest of the data of the external secrets materialized for the addon, remote clusters
+// materialize their own secrets
+func (r *AddonReconciler) secretsDigest(ctx context.Context, addon *addonmgrv1alpha1.Addon) (string, error) {
+	if !r.hub && addon.Spec.Target != nil && addon.Spec.Target.ClusterRef != nil {
+		return "", nil
 	}
+	return r.secrets.Digest(ctx, addon)
+}
 
-	wfIdentifierName := addon.GetFormattedWorkflowName(lifecycleStep)
-	if wfIdentifierName == "" {
-		return addonmgrv1alpha1.Failed, fmt.Errorf("could not generate workflow template name")
+// sopsDocument returns the encrypted SOPS document referenced by the addon, empty when there is none
+func (r *AddonReconciler) sopsDocument(ctx context.Context, addon *addonmgrv1alpha1.Addon) (string, error) {
+	ref := addon.Spec.Params.Sops
+	if ref == nil {
+		return "", nil
+	}
+
+	if r.paramsInformer == nil {
+		return "", fmt.Errorf("sops decryption is not configured")
+	}
+	if !r.paramsInformer.Informer().HasSynced() {
+		return "", fmt.Errorf("params configmaps are not synced yet")
+	}
+	cm, err := r.paramsInformer.Lister().ConfigMaps(addon.Namespace).Get(ref.ConfigMap)
+	if apierrors.IsNotFound(err) {
+		return "", fmt.Errorf("configmap %s/%s labelled %s not found", addon.Namespace, ref.ConfigMap, addonmgrv1alpha1.ParamsLabel)
 	}
-	phase, err := wfl.Install(context.TODO(), wt, wfIdentifierName)
 	if err != nil {
-		return phase, err
+		return "", err
 	}
-	r.recorder.Event(addon, "Normal", "Completed", fmt.Sprintf("Completed %s workflow %s/%s.", strings.Title(string(lifecycleStep)), addon.Namespace, wfIdentifierName))
-	return phase, nil
+
+	key := ref.Key
+	if key == "" {
+		key = "params.yaml"
+	}
+	doc, ok := cm.Data[key]
+	if !ok {
+		return "", fmt.Errorf("configmap %s/%s has no key %s", addon.Namespace, ref.ConfigMap, key)
+	}
+	return doc, nil
+}
+
+// decryptSopsParams returns the decrypted params of the SOPS document referenced by the addon, if any, docErr is
+// the error reading the document
+func (r *AddonReconciler) decryptSopsParams(ctx context.Context, addon *addonmgrv1alpha1.Addon, doc string, docErr error) (map[string]string, error) {
+	if addon.Spec.Params.Sops == nil {
+		return nil, nil
+	}
+	if r.sops == nil {
+		return nil, fmt.Errorf("sops decryption is not configured")
+	}
+	if docErr != nil {
+		return nil, docErr
+	}
+
+	// Decrypting calls KMS, the values are only decrypted again when the document changed
+	key := types.NamespacedName{Namespace: addon.Namespace, Name: addon.Name}
+	params, err := r.sops.DecryptCached(ctx, key, addonmgrv1alpha1.SourceDigest(doc), []byte(doc))
+	for _, v := range params {
+		r.redactor.Add(v)
+	}
+	return params, err
+}
+
+func (r *AddonReconciler) materializeSecrets(ctx context.Context, addon *addonmgrv1alpha1.Addon, cluster *remote.Cluster) error {
+	if cluster != nil {
+		return nil
+	}
+	return r.secrets.Materialize(ctx, addon)
+}
+
+func (r *AddonReconciler) pendingUnseal(ctx context.Context, addon *addonmgrv1alpha1.Addon, cluster *remote.Cluster) ([]string, error) {
+	if cluster != nil {
+		return nil, nil
+	}
+	return r.secrets.PendingUnseal(ctx, addon)
 }
 
-func (r *AddonReconciler) validateSecrets(ctx context.Context, addon *addonmgrv1alpha1.Addon) error {
-	foundSecrets, err := r.dynClient.Resource(common.SecretGVR()).Namespace(addon.Spec.Params.Namespace).List(ctx, metav1.ListOptions{})
+func (r *AddonReconciler) validateSecrets(ctx context.Context, addon *addonmgrv1alpha1.Addon, cluster *remote.Cluster) error {
+	dynClient := r.dynClient
+	if cluster != nil {
+		dynClient = cluster.Dynamic
+	}
+	foundSecrets, err := dynClient.Resource(common.SecretGVR()).Namespace(addon.Spec.Params.Namespace).List(ctx, metav1.ListOptions{})
 	if err != nil {
 		return err
 	}
 
+	addonSecrets := make(map[string]struct{}, len(addon.Spec.Secrets))
+	for _, secret := range addon.Spec.Secrets {
+		addonSecrets[secret.Name] = struct{}{}
+	}
+
 	secretsList := make(map[string]struct{}, len(foundSecrets.Items))
 	for _, foundSecret := range foundSecrets.Items {
 		secretsList[foundSecret.UnstructuredContent()["metadata"].(map[string]interface{})["name"].(string)] = struct{}{}
@@ -447,11 +2574,33 @@ func (r *AddonReconciler) validateSecrets(ctx context.Context, addon *addonmgrv1
 		}
 	}
 
+	// Mask the values of the addon secrets in logs, events and status
+	for _, foundSecret := range foundSecrets.Items {
+		if _, ok := addonSecrets[foundSecret.GetName()]; !ok {
+			continue
+		}
+		data, _, _ := unstructured.NestedStringMap(foundSecret.Object, "data")
+		for _, v := range data {
+			if decoded, err := base64.StdEncoding.DecodeString(v); err == nil {
+				r.redactor.Add(string(decoded))
+			}
+		}
+	}
+
 	return nil
 }
 
-func (r *AddonReconciler) updateAddonStatus(ctx context.Context, log logr.Logger, addon *addonmgrv1alpha1.Addon) error {
-	if err := r.Status().Update(ctx, addon); err != nil {
+// updateAddonStatus saves the changes of the addon status since prevStatus with a JSON merge patch. The patch
+// carries the resourceVersion the status was computed from, it conflicts when the addon changed in the meantime and
+// the reconcile is retried with the current addon.
+func (r *AddonReconciler) updateAddonStatus(ctx context.Context, log logr.Logger, addon *addonmgrv1alpha1.Addon, prevStatus *addonmgrv1alpha1.AddonStatus) error {
+	// Only the status is patched, the spec with its templates is shared instead of copied
+	base := &addonmgrv1alpha1.Addon{TypeMeta: addon.TypeMeta, ObjectMeta: addon.ObjectMeta, Spec: addon.Spec, Status: *prevStatus}
+	if err := r.Status().Patch(ctx, addon, client.MergeFromWithOptions(base, client.MergeFromWithOptimisticLock{})); err != nil {
+		if apierrors.IsConflict(err) {
+			log.Info("Addon changed while it was reconciled, retrying.")
+			return err
+		}
 		log.Error(err, "Addon status could not be updated.")
 		r.recorder.Event(addon, "Warning", "Failed", fmt.Sprintf("Addon %s/%s status could not be updated. %v", addon.Namespace, addon.Name, err))
 		return err
@@ -460,6 +2609,71 @@ func (r *AddonReconciler) updateAddonStatus(ctx context.Context, log logr.Logger
 	return nil
 }
 
+func (r *AddonReconciler) notifyPhaseChange(log logr.Logger, instance *addonmgrv1alpha1.Addon, prevPhase addonmgrv1alpha1.ApplicationAssemblyPhase, startTime int64) {
+	if r.Notifier == nil {
+		return
+	}
+
+	if startTime == 0 {
+		startTime = instance.Status.StartTime
+	}
+	ev := notify.NewEvent(instance, prevPhase, startTime, lastWorkflow(instance), r.clock.Now())
+	// Workflows of local addons run in the workflow namespace with a qualified name when one is configured
+	if r.workflowNs != "" && instance.Status.Cluster == "" && ev.Workflow != "" {
+		ev.WorkflowNamespace = r.workflowNs
+		ev.Workflow = workflows.QualifyName(instance, r.workflowNs, ev.Workflow)
+	}
+
+	// Deliver asynchronously so slow receivers don't block reconciles
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), notifyTimeout)
+		defer cancel()
+		if err := r.Notifier.Notify(ctx, ev); err != nil {
+			log.Error(err, "Failed to send addon lifecycle notification.")
+		}
+	}()
+}
+
+// lastWorkflow returns the name of the lifecycle workflow behind the addon phase: the workflow of the failed step,
+// otherwise the workflow started last
+func lastWorkflow(instance *addonmgrv1alpha1.Addon) string {
+	if step := instance.Status.Lifecycle.FailedStep; step != "" {
+		if timing := instance.GetStepTiming(step); timing != nil {
+			return timing.Workflow
+		}
+	}
+
+	var last *addonmgrv1alpha1.LifecycleStepTiming
+	for _, step := range []addonmgrv1alpha1.LifecycleStep{addonmgrv1alpha1.Prereqs, addonmgrv1alpha1.Install, addonmgrv1alpha1.Upgrade, addonmgrv1alpha1.Validate, addonmgrv1alpha1.Delete} {
+		timing := instance.GetStepTiming(step)
+		if timing == nil || timing.StartTime == nil {
+			continue
+		}
+		if last == nil || !timing.StartTime.Before(last.StartTime) {
+			last = timing
+		}
+	}
+	if last == nil {
+		return ""
+	}
+	return last.Workflow
+}
+
+// Diagnostics returns a snapshot of the reconciler caches for the diagnostics endpoint
+func (r *AddonReconciler) Diagnostics() interface{} {
+	var versions = 0
+	var all = r.versionCache.GetAllVersions()
+	for _, vmap := range all {
+		versions += len(vmap)
+	}
+
+	return map[string]interface{}{
+		"cachedPackages":  len(all),
+		"cachedVersions":  versions,
+		"dependencyGraph": addon.GetDependencyGraphStats(r.versionCache),
+	}
+}
+
 func (r *AddonReconciler) addAddonToCache(instance *addonmgrv1alpha1.Addon) {
 	var version = addon.Version{
 		Name:        instance.GetName(),
@@ -470,8 +2684,9 @@ func (r *AddonReconciler) addAddonToCache(instance *addonmgrv1alpha1.Addon) {
 	r.versionCache.AddVersion(version)
 }
 
-func (r *AddonReconciler) observeResources(ctx context.Context, a *addonmgrv1alpha1.Addon) ([]addonmgrv1alpha1.ObjectStatus, error) {
+func (r *AddonReconciler) observeResources(ctx context.Context, a *addonmgrv1alpha1.Addon, cluster *remote.Cluster) ([]addonmgrv1alpha1.ObjectStatus, []string, error) {
 	var observed []addonmgrv1alpha1.ObjectStatus
+	var images []string
 	var labelSelector = a.Spec.Selector
 
 	if len(labelSelector.MatchLabels) == 0 {
@@ -483,7 +2698,7 @@ func (r *AddonReconciler) observeResources(ctx context.Context, a *addonmgrv1alp
 
 	selector, err := metav1.LabelSelectorAsSelector(&labelSelector)
 	if err != nil {
-		return observed, fmt.Errorf("label selector is invalid. %v", err)
+		return observed, nil, fmt.Errorf("label selector is invalid. %v", err)
 	}
 
 	for _, resc := range resources {
@@ -497,14 +2712,26 @@ func (r *AddonReconciler) observeResources(ctx context.Context, a *addonmgrv1alp
 			Resource: inflection.Plural(strings.ToLower(kind)),
 		}
 
-		inf, err := generatedInformers.ForResource(gvr)
-		if err != nil {
-			return observed, err
-		}
+		var objs []runtime.Object
+		if cluster != nil {
+			// Remote clusters are not watched, list the resources on every reconcile
+			list, err := cluster.Dynamic.Resource(gvr).Namespace(a.Spec.Params.Namespace).List(ctx, metav1.ListOptions{LabelSelector: selector.String()})
+			if err != nil {
+				return observed, nil, err
+			}
+			for i := range list.Items {
+				objs = append(objs, &list.Items[i])
+			}
+		} else {
+			inf, err := generatedInformers.ForResource(gvr)
+			if err != nil {
+				return observed, nil, err
+			}
 
-		objs, err := inf.Lister().ByNamespace(a.Spec.Params.Namespace).List(selector)
-		if err != nil {
-			return observed, err
+			objs, err = inf.Lister().ByNamespace(a.Spec.Params.Namespace).List(selector)
+			if err != nil {
+				return observed, nil, err
+			}
 		}
 
 		for _, item := range objs {
@@ -514,10 +2741,16 @@ func (r *AddonReconciler) observeResources(ctx context.Context, a *addonmgrv1alp
 				Name:  item.(metav1.Object).GetName(),
 				Link:  item.(metav1.Object).GetSelfLink(),
 			})
+
+			itemImages, err := inventory.Images(gvk.Kind, item)
+			if err != nil {
+				return observed, nil, err
+			}
+			images = append(images, itemImages...)
 		}
 	}
 
-	return observed, nil
+	return observed, inventory.Unique(images), nil
 }
 
 // Finalize runs finalizer for addon
@@ -525,7 +2758,14 @@ func (r *AddonReconciler) Finalize(ctx context.Context, addon *addonmgrv1alpha1.
 	// Has Delete workflow defined, let's run it.
 	var removeFinalizer = true
 
-	if addon.Spec.Lifecycle.Delete.Template != "" {
+	if r.gitops != nil {
+		// The GitOps controller removes the addon resources with its objects
+		done, err := r.gitops.Delete(ctx, addon)
+		if err != nil {
+			return err
+		}
+		removeFinalizer = done
+	} else if addon.Spec.Lifecycle.Delete.Template != "" || workflows.IsHelmChart(addon) {
 
 		removeFinalizer = false
 
@@ -544,10 +2784,35 @@ func (r *AddonReconciler) Finalize(ctx context.Context, addon *addonmgrv1alpha1.
 	// Remove version from cache
 	r.versionCache.RemoveVersion(addon.Spec.PkgName, addon.Spec.PkgVersion)
 
+	// Remove generated workflow RBAC once the delete workflow is done with it.
+	if removeFinalizer && r.serviceAccounts != nil {
+		if err := r.serviceAccounts.Cleanup(ctx, addon); err != nil {
+			return err
+		}
+	}
+	if removeFinalizer && r.networkPolicies != nil {
+		if err := r.networkPolicies.Cleanup(ctx, addon); err != nil {
+			return err
+		}
+	}
+	// Materialized secrets are only created in the local cluster
+	if removeFinalizer && addon.Status.Cluster == "" {
+		if err := r.secrets.Cleanup(ctx, addon); err != nil {
+			return err
+		}
+	}
+
+	// Workflows outside of the addon namespace are not garbage collected with the addon
+	if removeFinalizer && r.workflowNs != "" && r.simulator == nil && addon.Status.Cluster == "" {
+		if err := workflows.DeleteAddonWorkflows(ctx, r.dynClient, addon, r.workflowNs); err != nil {
+			return err
+		}
+	}
+
 	// Remove finalizer from the list and update it.
 	if removeFinalizer && common.ContainsString(addon.ObjectMeta.Finalizers, finalizerName) {
 		addon.ObjectMeta.Finalizers = common.RemoveString(addon.ObjectMeta.Finalizers, finalizerName)
-		if err := r.Update(ctx, addon); err != nil {
+		if err := r.updateAddon(ctx, addon); err != nil {
 			return err
 		}
 	}
@@ -563,7 +2828,7 @@ func (r *AddonReconciler) SetFinalizer(ctx context.Context, addon *addonmgrv1alp
 		if !common.ContainsString(addon.ObjectMeta.Finalizers, finalizerName) {
 			// Set Finalizer
 			addon.ObjectMeta.Finalizers = append(addon.ObjectMeta.Finalizers, finalizerName)
-			if err := r.Update(ctx, addon); err != nil {
+			if err := r.updateAddon(ctx, addon); err != nil {
 				return err
 			}
 		}
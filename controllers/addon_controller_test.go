@@ -4,17 +4,22 @@ import (
 	"context"
 	"fmt"
 	"io/ioutil"
+	"strings"
 	"time"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 	"gopkg.in/yaml.v3"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes/scheme"
 
 	"github.com/keikoproj/addon-manager/api/v1alpha1"
+	"github.com/keikoproj/addon-manager/pkg/common"
+	"github.com/keikoproj/addon-manager/pkg/workflows"
 )
 
 var (
@@ -64,12 +69,233 @@ var _ = Describe("AddonController", func() {
 			By("Verify addon has been reconciled by checking for checksum status")
 			Expect(instance.Status.Checksum).ShouldNot(BeEmpty())
 
+			By("Verify addon reports whether the current spec is installed")
+			Expect(meta.FindStatusCondition(instance.Status.Conditions, v1alpha1.OutOfSyncCondition)).ShouldNot(BeNil())
+
 			By("Verify addon has finalizers added which means it's valid")
 			Expect(instance.ObjectMeta.Finalizers).Should(Equal([]string{"delete.addonmgr.keikoproj.io"}))
 		})
 	})
+
+	Describe("Addon install failures", func() {
+
+		It("should stop submitting workflows once the failure budget is exhausted", func() {
+			ctx := context.TODO()
+			instance := newLifecycleAddon("circuit-test")
+			Expect(k8sClient.Create(ctx, instance)).To(Succeed())
+			defer k8sClient.Delete(ctx, instance)
+			key := types.NamespacedName{Name: instance.Name, Namespace: instance.Namespace}
+
+			By("failing the prereqs workflow")
+			Eventually(func() error {
+				return completeWorkflow(instance.Name, v1alpha1.Prereqs, "Failed")
+			}, timeout).Should(Succeed())
+			Eventually(func() (int, error) {
+				if err := k8sClient.Get(ctx, key, instance); err != nil || instance.Status.FailureBudget == nil {
+					return 0, err
+				}
+				return instance.Status.FailureBudget.Failures, nil
+			}, timeout).Should(Equal(1))
+			Expect(meta.IsStatusConditionTrue(instance.Status.Conditions, v1alpha1.CircuitOpenCondition)).To(BeFalse())
+
+			By("failing the prereqs workflow submitted again after the backoff")
+			Eventually(func() error {
+				return completeWorkflow(instance.Name, v1alpha1.Prereqs, "Failed")
+			}, timeout).Should(Succeed())
+			Eventually(func() (bool, error) {
+				if err := k8sClient.Get(ctx, key, instance); err != nil {
+					return false, err
+				}
+				return meta.IsStatusConditionTrue(instance.Status.Conditions, v1alpha1.CircuitOpenCondition), nil
+			}, timeout).Should(BeTrue())
+			Expect(instance.Status.FailureBudget.Failures).To(Equal(2))
+			Expect(instance.Status.Lifecycle.Installed).To(Equal(v1alpha1.Failed))
+
+			By("Verify no workflow is submitted while the circuit is open")
+			Consistently(func() error {
+				_, err := runningWorkflow(instance.Name, v1alpha1.Prereqs)
+				return err
+			}, 3*time.Second).Should(HaveOccurred())
+		})
+
+		It("should resume a failed install without running the succeeded prereqs again", func() {
+			ctx := context.TODO()
+			instance := newLifecycleAddon("resume-test")
+			Expect(k8sClient.Create(ctx, instance)).To(Succeed())
+			defer k8sClient.Delete(ctx, instance)
+			key := types.NamespacedName{Name: instance.Name, Namespace: instance.Namespace}
+
+			By("succeeding the prereqs workflow")
+			var prereqs *unstructured.Unstructured
+			Eventually(func() (err error) {
+				prereqs, err = runningWorkflow(instance.Name, v1alpha1.Prereqs)
+				return err
+			}, timeout).Should(Succeed())
+			Expect(completeWorkflow(instance.Name, v1alpha1.Prereqs, "Succeeded")).To(Succeed())
+
+			By("failing the install workflow")
+			Eventually(func() error {
+				return completeWorkflow(instance.Name, v1alpha1.Install, "Failed")
+			}, timeout).Should(Succeed())
+			Eventually(func() (v1alpha1.LifecycleStep, error) {
+				if err := k8sClient.Get(ctx, key, instance); err != nil {
+					return "", err
+				}
+				return instance.Status.Lifecycle.FailedStep, nil
+			}, timeout).Should(Equal(v1alpha1.Install))
+			Expect(instance.Status.Lifecycle.Installed).To(Equal(v1alpha1.Failed))
+			Expect(instance.Status.Lifecycle.Checksums).To(HaveKey(v1alpha1.Prereqs))
+			Expect(instance.StepSucceeded(v1alpha1.Prereqs)).To(BeTrue())
+
+			By("Verify the install is submitted again after the backoff while prereqs are skipped")
+			Eventually(func() error {
+				_, err := runningWorkflow(instance.Name, v1alpha1.Install)
+				return err
+			}, timeout).Should(Succeed())
+			Consistently(func() (types.UID, error) {
+				if _, err := runningWorkflow(instance.Name, v1alpha1.Prereqs); err == nil {
+					return "", fmt.Errorf("prereqs workflow of addon %s is running again", instance.Name)
+				}
+				wf, err := dynClient.Resource(common.WorkflowGVR()).Namespace(prereqs.GetNamespace()).Get(ctx, prereqs.GetName(), metav1.GetOptions{})
+				if err != nil {
+					return "", err
+				}
+				return wf.GetUID(), nil
+			}, 2*time.Second).Should(Equal(prereqs.GetUID()))
+
+			By("succeeding the install workflow")
+			Expect(completeWorkflow(instance.Name, v1alpha1.Install, "Succeeded")).To(Succeed())
+			Eventually(func() (v1alpha1.ApplicationAssemblyPhase, error) {
+				if err := k8sClient.Get(ctx, key, instance); err != nil {
+					return "", err
+				}
+				return instance.Status.Lifecycle.Installed, nil
+			}, timeout).Should(Equal(v1alpha1.Succeeded))
+			Expect(instance.Status.Lifecycle.FailedStep).To(BeEmpty())
+			Expect(instance.Status.Lifecycle.Prereqs).To(Equal(v1alpha1.Succeeded))
+		})
+	})
+
+	Describe("Addon dependencies", func() {
+
+		It("should not submit workflows until its dependencies succeeded", func() {
+			ctx := context.TODO()
+			dependency := newLifecycleAddon("deps-base")
+			Expect(k8sClient.Create(ctx, dependency)).To(Succeed())
+			defer k8sClient.Delete(ctx, dependency)
+
+			instance := newLifecycleAddon("deps-dependent")
+			instance.Spec.PkgDeps = map[string]string{dependency.Spec.PkgName: dependency.Spec.PkgVersion}
+			Expect(k8sClient.Create(ctx, instance)).To(Succeed())
+			defer k8sClient.Delete(ctx, instance)
+			key := types.NamespacedName{Name: instance.Name, Namespace: instance.Namespace}
+
+			By("Verify the dependent addon waits while the dependency is not Succeeded")
+			Eventually(func() (*metav1.Condition, error) {
+				if err := k8sClient.Get(ctx, key, instance); err != nil {
+					return nil, err
+				}
+				return meta.FindStatusCondition(instance.Status.Conditions, v1alpha1.DependenciesReadyCondition), nil
+			}, timeout).Should(And(
+				Not(BeNil()),
+				WithTransform(func(c *metav1.Condition) metav1.ConditionStatus { return c.Status }, Equal(metav1.ConditionFalse)),
+				WithTransform(func(c *metav1.Condition) string { return c.Reason }, Equal(v1alpha1.DepsNotReadyReason)),
+			))
+			Expect(instance.Status.Lifecycle.Installed).To(Equal(v1alpha1.Pending))
+
+			By("Verify no prereqs workflow is created for the dependent addon")
+			Consistently(func() error {
+				_, err := runningWorkflow(instance.Name, v1alpha1.Prereqs)
+				return err
+			}, 2*time.Second).Should(HaveOccurred())
+
+			By("succeeding the workflows of the dependency")
+			Eventually(func() error {
+				return completeWorkflow(dependency.Name, v1alpha1.Prereqs, "Succeeded")
+			}, timeout).Should(Succeed())
+			Eventually(func() error {
+				return completeWorkflow(dependency.Name, v1alpha1.Install, "Succeeded")
+			}, timeout).Should(Succeed())
+
+			By("Verify the dependent addon submits its prereqs workflow once the dependency succeeded")
+			Eventually(func() error {
+				_, err := runningWorkflow(instance.Name, v1alpha1.Prereqs)
+				return err
+			}, 2*timeout).Should(Succeed())
+			Eventually(func() (bool, error) {
+				if err := k8sClient.Get(ctx, key, instance); err != nil {
+					return false, err
+				}
+				return meta.IsStatusConditionTrue(instance.Status.Conditions, v1alpha1.DependenciesReadyCondition), nil
+			}, timeout).Should(BeTrue())
+		})
+	})
 })
 
+const lifecycleWorkflow = `apiVersion: argoproj.io/v1alpha1
+kind: Workflow
+spec:
+  entrypoint: entry
+  templates:
+  - name: entry
+    container:
+      image: alpine:3.12
+      command: [sh, -c, "true"]
+`
+
+// newLifecycleAddon returns an addon with prereqs and install workflows
+func newLifecycleAddon(name string) *v1alpha1.Addon {
+	return &v1alpha1.Addon{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: addonNamespace},
+		Spec: v1alpha1.AddonSpec{
+			PackageSpec: v1alpha1.PackageSpec{
+				PkgName:        name,
+				PkgVersion:     "v0.1",
+				PkgType:        v1alpha1.CompositePkg,
+				PkgDescription: name,
+			},
+			Params: v1alpha1.AddonParams{Namespace: name + "-ns"},
+			Lifecycle: v1alpha1.LifecycleWorkflowSpec{
+				Prereqs: v1alpha1.WorkflowType{Template: lifecycleWorkflow},
+				Install: v1alpha1.WorkflowType{Template: lifecycleWorkflow},
+			},
+		},
+	}
+}
+
+// runningWorkflow returns the workflow of the lifecycle step of the addon which did not complete yet
+func runningWorkflow(name string, step v1alpha1.LifecycleStep) (*unstructured.Unstructured, error) {
+	list, err := dynClient.Resource(common.WorkflowGVR()).Namespace(metav1.NamespaceAll).List(context.TODO(), metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("%s=%s", workflows.AddonLabel, name),
+	})
+	if err != nil {
+		return nil, err
+	}
+	for i, wf := range list.Items {
+		if !strings.HasPrefix(wf.GetName(), fmt.Sprintf("%s-%s-", name, step)) || wf.GetDeletionTimestamp() != nil {
+			continue
+		}
+		if phase, _, _ := unstructured.NestedString(wf.Object, "status", "phase"); phase != "Succeeded" && phase != "Failed" {
+			return &list.Items[i], nil
+		}
+	}
+	return nil, fmt.Errorf("no running %s workflow of addon %s", step, name)
+}
+
+// completeWorkflow sets the phase of the running workflow of the lifecycle step of the addon as the Argo workflow
+// controller would
+func completeWorkflow(name string, step v1alpha1.LifecycleStep, phase string) error {
+	wf, err := runningWorkflow(name, step)
+	if err != nil {
+		return err
+	}
+	if err := unstructured.SetNestedField(wf.Object, phase, "status", "phase"); err != nil {
+		return err
+	}
+	_, err = dynClient.Resource(common.WorkflowGVR()).Namespace(wf.GetNamespace()).Update(context.TODO(), wf, metav1.UpdateOptions{})
+	return err
+}
+
 func parseAddonYaml(data []byte) (*v1alpha1.Addon, error) {
 	var err error
 	o := &unstructured.Unstructured{}
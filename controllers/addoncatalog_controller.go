@@ -0,0 +1,183 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/clock"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	addonmgrv1alpha1 "github.com/keikoproj/addon-manager/api/v1alpha1"
+	"github.com/keikoproj/addon-manager/pkg/catalog"
+	"github.com/keikoproj/addon-manager/pkg/shard"
+)
+
+// defaultCatalogInterval is how often catalog indexes are synced when the catalog sets no interval
+const defaultCatalogInterval = 30 * time.Minute
+
+// AddonCatalogReconciler syncs the index of AddonCatalogs into a catalog store
+type AddonCatalogReconciler struct {
+	client.Client
+	Log      logr.Logger
+	Scheme   *runtime.Scheme
+	store    *catalog.Store
+	fetcher  *catalog.Fetcher
+	secrets  kubernetes.Interface
+	recorder record.EventRecorder
+	shard    *shard.Shard
+	clock    clock.PassiveClock
+}
+
+// NewAddonCatalogReconciler returns an AddonCatalogReconciler syncing catalogs into store
+func NewAddonCatalogReconciler(mgr manager.Manager, log logr.Logger, store *catalog.Store) *AddonCatalogReconciler {
+	return &AddonCatalogReconciler{
+		Client:   mgr.GetClient(),
+		Log:      log,
+		Scheme:   mgr.GetScheme(),
+		store:    store,
+		fetcher:  catalog.NewFetcher(),
+		secrets:  kubernetes.NewForConfigOrDie(mgr.GetConfig()),
+		recorder: mgr.GetEventRecorderFor("addoncatalogs"),
+		clock:    clock.RealClock{},
+	}
+}
+
+// SetClock sets the clock sync times are taken from
+func (r *AddonCatalogReconciler) SetClock(c clock.PassiveClock) {
+	r.clock = c
+}
+
+// SetShard only syncs the catalogs in namespaces of the shard, addons are rendered from catalogs in their namespace
+func (r *AddonCatalogReconciler) SetShard(s *shard.Shard) {
+	r.shard = s
+}
+
+// +kubebuilder:rbac:groups=addonmgr.keikoproj.io,resources=addoncatalogs,verbs=get;list;watch
+// +kubebuilder:rbac:groups=addonmgr.keikoproj.io,resources=addoncatalogs/status,verbs=get;update;patch
+
+// Reconcile syncs the index of an AddonCatalog, a failed sync keeps the last synced index
+func (r *AddonCatalogReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
+	ctx := context.Background()
+	log := r.Log.WithValues("addoncatalog", req.NamespacedName)
+
+	var instance = &addonmgrv1alpha1.AddonCatalog{}
+	if err := r.Get(ctx, req.NamespacedName, instance); err != nil {
+		r.store.Delete(req.NamespacedName)
+		return reconcile.Result{}, ignoreNotFound(err)
+	}
+	base := instance.DeepCopy()
+
+	interval := defaultCatalogInterval
+	if instance.Spec.Interval != nil && instance.Spec.Interval.Duration > 0 {
+		interval = instance.Spec.Interval.Duration
+	}
+
+	snapshot, revision, err := r.sync(ctx, instance)
+	if err != nil {
+		reason := fmt.Sprintf("AddonCatalog %s/%s could not be synced. %v", instance.Namespace, instance.Name, err)
+		r.recorder.Event(instance, "Warning", "Failed", reason)
+		log.Error(err, "Failed to sync addon catalog.")
+		meta.SetStatusCondition(&instance.Status.Conditions, metav1.Condition{
+			Type:    addonmgrv1alpha1.CatalogReadyCondition,
+			Status:  metav1.ConditionFalse,
+			Reason:  "SyncFailed",
+			Message: reason,
+		})
+	} else {
+		r.store.Set(req.NamespacedName, snapshot)
+		if revision != instance.Status.Revision {
+			r.recorder.Event(instance, "Normal", "Synced", fmt.Sprintf("AddonCatalog %s/%s synced index %s.", instance.Namespace, instance.Name, revision))
+		}
+		now := metav1.NewTime(r.clock.Now())
+		instance.Status.LastSyncTime = &now
+		instance.Status.Revision = revision
+		instance.Status.Packages = snapshot.Packages()
+		meta.SetStatusCondition(&instance.Status.Conditions, metav1.Condition{
+			Type:    addonmgrv1alpha1.CatalogReadyCondition,
+			Status:  metav1.ConditionTrue,
+			Reason:  "Synced",
+			Message: fmt.Sprintf("Index %s is synced.", revision),
+		})
+	}
+
+	if err := r.Status().Patch(ctx, instance, client.MergeFromWithOptions(base, client.MergeFromWithOptimisticLock{})); err != nil {
+		log.Error(err, "AddonCatalog status could not be updated.")
+		return reconcile.Result{RequeueAfter: 1 * time.Second}, err
+	}
+
+	return reconcile.Result{RequeueAfter: interval}, nil
+}
+
+// sync fetches and parses the index of the catalog, it returns the snapshot and the sha256 digest of the index
+func (r *AddonCatalogReconciler) sync(ctx context.Context, instance *addonmgrv1alpha1.AddonCatalog) (*catalog.Snapshot, string, error) {
+	var creds *catalog.Credentials
+	if instance.Spec.SecretRef != "" {
+		secret, err := r.secrets.CoreV1().Secrets(instance.Namespace).Get(ctx, instance.Spec.SecretRef, metav1.GetOptions{})
+		if err != nil {
+			return nil, "", err
+		}
+		creds = &catalog.Credentials{
+			Username: string(secret.Data["username"]),
+			Password: string(secret.Data["password"]),
+			Token:    string(secret.Data["token"]),
+		}
+	}
+
+	var tlsMaterial *catalog.TLS
+	if instance.Spec.TLS != nil && instance.Spec.TLS.SecretRef != "" {
+		secret, err := r.secrets.CoreV1().Secrets(instance.Namespace).Get(ctx, instance.Spec.TLS.SecretRef, metav1.GetOptions{})
+		if err != nil {
+			return nil, "", err
+		}
+		tlsMaterial = &catalog.TLS{
+			CA:   secret.Data["ca.crt"],
+			Cert: secret.Data["tls.crt"],
+			Key:  secret.Data["tls.key"],
+		}
+	}
+
+	data, err := r.fetcher.Fetch(ctx, instance.Spec, creds, tlsMaterial)
+	if err != nil {
+		return nil, "", err
+	}
+	snapshot, err := catalog.ParseIndex(data)
+	if err != nil {
+		return nil, "", err
+	}
+	return snapshot, snapshot.Revision(), nil
+}
+
+// SetupWithManager is called to setup manager and watchers
+func (r *AddonCatalogReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&addonmgrv1alpha1.AddonCatalog{}).
+		// Status updates of the sync must not trigger another sync
+		WithEventFilter(predicate.GenerationChangedPredicate{}).
+		WithEventFilter(r.shard.Predicate()).
+		Complete(r)
+}
@@ -0,0 +1,130 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	addonmgrv1alpha1 "github.com/keikoproj/addon-manager/api/v1alpha1"
+	"github.com/keikoproj/addon-manager/pkg/profile"
+	"github.com/keikoproj/addon-manager/pkg/shard"
+)
+
+// AddonProfileReconciler expands AddonProfiles into the addons of their catalog packages
+type AddonProfileReconciler struct {
+	client.Client
+	Log      logr.Logger
+	Scheme   *runtime.Scheme
+	syncer   *profile.Syncer
+	recorder record.EventRecorder
+	shard    *shard.Shard
+}
+
+// NewAddonProfileReconciler returns an AddonProfileReconciler
+func NewAddonProfileReconciler(mgr manager.Manager, log logr.Logger) *AddonProfileReconciler {
+	return &AddonProfileReconciler{
+		Client:   mgr.GetClient(),
+		Log:      log,
+		Scheme:   mgr.GetScheme(),
+		syncer:   profile.NewSyncer(mgr.GetClient(), mgr.GetScheme()),
+		recorder: mgr.GetEventRecorderFor("addonprofiles"),
+	}
+}
+
+// SetShard only syncs the profiles in namespaces of the shard
+func (r *AddonProfileReconciler) SetShard(s *shard.Shard) {
+	r.shard = s
+}
+
+// +kubebuilder:rbac:groups=addonmgr.keikoproj.io,resources=addonprofiles,verbs=get;list;watch
+// +kubebuilder:rbac:groups=addonmgr.keikoproj.io,resources=addonprofiles/status,verbs=get;update;patch
+
+// Reconcile creates, updates and deletes the addons of an AddonProfile and reports their install status
+func (r *AddonProfileReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
+	ctx := context.Background()
+	log := r.Log.WithValues("addonprofile", req.NamespacedName)
+
+	var instance = &addonmgrv1alpha1.AddonProfile{}
+	if err := r.Get(ctx, req.NamespacedName, instance); err != nil {
+		// Addons of deleted profiles are garbage collected through their owner references
+		return reconcile.Result{}, ignoreNotFound(err)
+	}
+	base := instance.DeepCopy()
+
+	statuses, err := r.syncer.Sync(ctx, instance)
+	if err != nil {
+		reason := fmt.Sprintf("AddonProfile %s/%s could not be synced. %v", instance.Namespace, instance.Name, err)
+		r.recorder.Event(instance, "Warning", "Failed", reason)
+		log.Error(err, "Failed to sync addon profile.")
+		meta.SetStatusCondition(&instance.Status.Conditions, metav1.Condition{
+			Type:    addonmgrv1alpha1.ProfileReadyCondition,
+			Status:  metav1.ConditionFalse,
+			Reason:  "SyncFailed",
+			Message: reason,
+		})
+	} else {
+		ready := 0
+		for _, s := range statuses {
+			if s.Installed == addonmgrv1alpha1.Succeeded {
+				ready++
+			}
+		}
+		instance.Status.Addons = statuses
+		instance.Status.Ready = fmt.Sprintf("%d/%d", ready, len(statuses))
+		condition := metav1.Condition{
+			Type:    addonmgrv1alpha1.ProfileReadyCondition,
+			Status:  metav1.ConditionFalse,
+			Reason:  "Installing",
+			Message: fmt.Sprintf("%d of %d addons are installed.", ready, len(statuses)),
+		}
+		if ready == len(statuses) {
+			condition.Status = metav1.ConditionTrue
+			condition.Reason = "Installed"
+		}
+		meta.SetStatusCondition(&instance.Status.Conditions, condition)
+	}
+
+	if err := r.Status().Patch(ctx, instance, client.MergeFromWithOptions(base, client.MergeFromWithOptimisticLock{})); err != nil {
+		log.Error(err, "AddonProfile status could not be updated.")
+		return reconcile.Result{RequeueAfter: 1 * time.Second}, err
+	}
+
+	return reconcile.Result{}, err
+}
+
+// SetupWithManager is called to setup manager and watchers
+func (r *AddonProfileReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		// Status updates of the profile must not trigger another sync
+		For(&addonmgrv1alpha1.AddonProfile{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		// Install status changes of the addons are reported on the profile
+		Owns(&addonmgrv1alpha1.Addon{}).
+		WithEventFilter(r.shard.Predicate()).
+		Complete(r)
+}
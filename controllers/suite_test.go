@@ -18,6 +18,7 @@ import (
 	"path/filepath"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/go-logr/logr"
 	. "github.com/onsi/ginkgo"
@@ -26,6 +27,7 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/envtest/printer"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
 
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/client-go/rest"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -34,6 +36,7 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 
 	addonmgrv1alpha1 "github.com/keikoproj/addon-manager/api/v1alpha1"
+	"github.com/keikoproj/addon-manager/pkg/addon"
 	// +kubebuilder:scaffold:imports
 )
 
@@ -42,6 +45,7 @@ import (
 
 var cfg *rest.Config
 var k8sClient client.Client
+var dynClient dynamic.Interface
 var testEnv *envtest.Environment
 var mgr manager.Manager
 var stopMgr chan struct{}
@@ -86,7 +90,12 @@ var _ = BeforeSuite(func(done Done) {
 	Expect(err).ToNot(HaveOccurred())
 	Expect(mgr).ToNot(BeNil())
 
-	err = NewAddonReconciler(mgr, ctrl.Log.WithName("controllers").WithName("Addon")).SetupWithManager(mgr)
+	dynClient = dynamic.NewForConfigOrDie(cfg)
+
+	reconciler := NewAddonReconciler(mgr, ctrl.Log.WithName("controllers").WithName("Addon"))
+	// Failed installs are retried after a second and stop being submitted after the second failure
+	reconciler.SetFailureBudget(addon.FailureBudget{Limit: 2, Window: time.Hour, Backoff: time.Second})
+	err = reconciler.SetupWithManager(mgr)
 	Expect(err).ToNot(HaveOccurred())
 
 	stopMgr, wg = StartTestManager(mgr)
@@ -16,16 +16,45 @@ package main
 
 import (
 	"flag"
+	"fmt"
+	"io/ioutil"
 	"os"
+	"strings"
+	"time"
 
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
 	_ "k8s.io/client-go/plugin/pkg/client/auth/gcp"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+	ctrlmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
 
 	addonmgrv1alpha1 "github.com/keikoproj/addon-manager/api/v1alpha1"
 	"github.com/keikoproj/addon-manager/controllers"
+	"github.com/keikoproj/addon-manager/pkg/addon"
+	"github.com/keikoproj/addon-manager/pkg/awsauth"
+	"github.com/keikoproj/addon-manager/pkg/catalog"
+	"github.com/keikoproj/addon-manager/pkg/common"
+	"github.com/keikoproj/addon-manager/pkg/cosign"
+	"github.com/keikoproj/addon-manager/pkg/diagnostics"
+	"github.com/keikoproj/addon-manager/pkg/faults"
+	"github.com/keikoproj/addon-manager/pkg/gitops"
+	"github.com/keikoproj/addon-manager/pkg/inventory"
+	"github.com/keikoproj/addon-manager/pkg/metrics"
+	"github.com/keikoproj/addon-manager/pkg/netpol"
+	"github.com/keikoproj/addon-manager/pkg/notify"
+	"github.com/keikoproj/addon-manager/pkg/provenance"
+	"github.com/keikoproj/addon-manager/pkg/rbac"
+	"github.com/keikoproj/addon-manager/pkg/secrets"
+	"github.com/keikoproj/addon-manager/pkg/shard"
+	"github.com/keikoproj/addon-manager/pkg/sops"
 	"github.com/keikoproj/addon-manager/pkg/version"
+	"github.com/keikoproj/addon-manager/pkg/webhook"
+	"github.com/keikoproj/addon-manager/pkg/workflows"
 	// +kubebuilder:scaffold:imports
 )
 
@@ -35,13 +64,182 @@ var (
 	debug                bool
 	metricsAddr          string
 	enableLeaderElection bool
+	enableWebhooks       bool
+	webhookPort          int
+	webhookCertDir       string
+	webhookNotifyURLs    string
+	slackWebhookURL      string
+	teamsWebhookURL      string
+	chatWebhookHosts     string
+	workflowLogsURL      string
+	snsTopicARN          string
+	sqsQueueURL          string
+	eventBridgeBusName   string
+	awsRegion            string
+	diagnosticsAddr      string
+	metricsAggregation   string
+	addonStateMetrics    bool
+	addonInventory       bool
+	vaultAddr            string
+	vaultRole            string
+	vaultAuthPath        string
+	secretSourcePolicy   string
+	secretRefresh        time.Duration
+	sopsAgeKeyFile       string
+	cosignPublicKeys     string
+	addonSigningKeys     string
+	registryConfig       string
+	generateWorkflowRBAC bool
+	grantableRules       string
+	workflowSAs          bool
+	workflowClusterRole  string
+	restrictedPods       bool
+	propagateLabels      string
+	propagateAnnotations string
+	archiveLogs          bool
+	artifactRepository   string
+	commonParams         string
+	networkPolicies      bool
+	cloudProvider        string
+	enforceNamespaced    bool
+	secretParams         bool
+	clusterHooks         bool
+	outputMode           string
+	argoCDNamespace      string
+	argoCDProject        string
+	fluxInterval         time.Duration
+	hubKubeconfig        string
+	catalogNamespace     string
+	addonCatalogs        bool
+	addonTemplates       bool
+	kubeVersionPolicy    string
+	validateGate         bool
+	workflowNamespace    string
+	workflowOwnerMode    string
+	workflowGCPolicy     string
+	failureBudget        int
+	failureWindow        time.Duration
+	failureBackoff       time.Duration
+	maxConcurrent        int
+	specDebounce         time.Duration
+	templateOffload      int
+	simulateDelay        time.Duration
+	injectFaults         string
+	shards               int
+	shardIndex           int
 )
 
 func init() {
 	flag.StringVar(&metricsAddr, "metrics-addr", ":8080", "The address the metric endpoint binds to.")
+	flag.StringVar(&metricsAggregation, "metrics-aggregation", string(metrics.AggregateByAddon),
+		"Label granularity of per-addon metrics: addon, package or namespace. Use package or namespace in very large fleets.")
+	flag.BoolVar(&addonStateMetrics, "addon-state-metrics", false,
+		"Export kube-state-metrics style kube_addon_* metrics for every Addon on the metrics endpoint. Count addons by install phase with kube_addon_status_phase.")
+	flag.BoolVar(&addonInventory, "addon-inventory", false,
+		"Serve a JSON or CSV inventory of installed addons with their versions, checksums and images on /inventory of the metrics endpoint.")
 	flag.BoolVar(&enableLeaderElection, "enable-leader-election", false,
 		"Enable leader election for controller manager. Enabling this will ensure there is only one active controller manager.")
+	flag.BoolVar(&enableWebhooks, "enable-webhooks", false,
+		"Serve the validating admission webhook rejecting invalid addons, requires a serving certificate in the webhook cert dir.")
+	flag.IntVar(&webhookPort, "webhook-port", 9443, "The port the admission webhook server binds to.")
+	flag.StringVar(&webhookCertDir, "webhook-cert-dir", "",
+		"Directory of the tls.crt and tls.key serving certificate of the webhook server, /tmp/k8s-webhook-server/serving-certs when empty.")
 	flag.BoolVar(&debug, "debug", false, "Debug logging")
+	flag.StringVar(&diagnosticsAddr, "diagnostics-addr", "",
+		"The address the pprof, expvar and diagnostics endpoints bind to, e.g. localhost:6060. Disabled when empty.")
+	flag.StringVar(&webhookNotifyURLs, "webhook-notify-urls", "",
+		"Comma separated list of URLs that receive addon lifecycle events. Payloads are signed with the ADDONMGR_WEBHOOK_SECRET env variable over the X-Addonmgr-Timestamp header and the body, receivers should reject old timestamps.")
+	flag.StringVar(&slackWebhookURL, "slack-webhook-url", "", "Slack incoming webhook URL notified of addon install results.")
+	flag.StringVar(&teamsWebhookURL, "teams-webhook-url", "", "Microsoft Teams incoming webhook URL notified of addon install results.")
+	flag.StringVar(&chatWebhookHosts, "chat-webhook-hosts", "",
+		"Comma separated list of hosts the Slack and Teams webhook URLs of addon annotations may point to. Addon webhook annotations are ignored when empty.")
+	flag.StringVar(&workflowLogsURL, "workflow-logs-url", "", "Base URL of the Argo UI, used to link notifications to workflow logs.")
+	flag.StringVar(&snsTopicARN, "sns-topic-arn", "", "AWS SNS topic ARN that addon lifecycle events are published to.")
+	flag.StringVar(&sqsQueueURL, "sqs-queue-url", "", "AWS SQS queue URL that addon lifecycle events are sent to, events of an addon are ordered on FIFO queues.")
+	flag.StringVar(&eventBridgeBusName, "eventbridge-bus-name", "", "AWS EventBridge bus name that addon lifecycle events are published to.")
+	flag.StringVar(&awsRegion, "aws-region", os.Getenv("AWS_REGION"), "AWS region used for SNS, SQS, EventBridge and Secrets Manager.")
+	flag.StringVar(&vaultAddr, "vault-addr", os.Getenv("VAULT_ADDR"), "HashiCorp Vault address addon secrets can be fetched from.")
+	flag.StringVar(&vaultRole, "vault-role", "", "Vault Kubernetes auth role, VAULT_TOKEN is used when empty.")
+	flag.StringVar(&vaultAuthPath, "vault-auth-path", "kubernetes", "Mount path of the Vault Kubernetes auth method.")
+	flag.StringVar(&secretSourcePolicy, "secret-source-policy", "",
+		"YAML file with a list of rules allowing the addons of a namespace to read Vault paths and Secrets Manager secrets into target namespaces. Without it no external secret source is allowed.")
+	flag.DurationVar(&secretRefresh, "secret-refresh-interval", secrets.DefaultRefreshInterval, "How often unchanged external secrets are fetched again from their stores.")
+	flag.StringVar(&sopsAgeKeyFile, "sops-age-key-file", os.Getenv("SOPS_AGE_KEY_FILE"), "File of age secret keys used to decrypt SOPS encrypted addon params.")
+	flag.StringVar(&cosignPublicKeys, "cosign-public-keys", "",
+		"Comma separated list of PEM public key files. When set, workflow images must have a cosign signature from one of the keys.")
+	flag.StringVar(&addonSigningKeys, "addon-signing-keys", "",
+		"Comma separated list of PEM public key files. When set, addon packages must carry a signature from one of the keys.")
+	flag.StringVar(&registryConfig, "registry-config", "", "Docker config.json with registry credentials used to fetch cosign signatures.")
+	flag.BoolVar(&generateWorkflowRBAC, "generate-workflow-rbac", false,
+		"Run addon workflows with a generated service account limited to the resources the addon manages, annotated with the workflow role.")
+	flag.StringVar(&grantableRules, "workflow-grantable-rules", "",
+		"YAML file with a list of policy rules Roles and ClusterRoles in addon artifacts may grant. Generating the workflow RBAC of an addon shipping roles with other rules fails, by default any rule.")
+	flag.BoolVar(&workflowSAs, "workflow-service-accounts", false,
+		"Run addon workflows with a dedicated service account per addon annotated with the workflow role for the cloud provider.")
+	flag.StringVar(&workflowClusterRole, "workflow-cluster-role", "",
+		"Cluster role bound to the workflow service accounts when their RBAC is not generated, required by --workflow-service-accounts. The manager role may only bind addon-manager-workflow, other names need the bind rule of the manager role changed.")
+	flag.BoolVar(&restrictedPods, "restricted-security-context", false,
+		"Inject runAsNonRoot, RuntimeDefault seccomp and dropped capabilities defaults into workflow pods and Deployment/DaemonSet artifacts.")
+	flag.StringVar(&propagateLabels, "propagate-labels", "",
+		"Comma separated list of addon label keys copied onto its workflows, workflow pods and installed resources, e.g. team,cost-center. Keys may be patterns like example.com/*.")
+	flag.StringVar(&propagateAnnotations, "propagate-annotations", "",
+		"Comma separated list of addon annotation keys copied onto its workflows, workflow pods and installed resources. Keys may be patterns like compliance.example.com/*.")
+	flag.BoolVar(&archiveLogs, "archive-workflow-logs", false,
+		"Copy the logs of lifecycle workflow pods to the Argo artifact repository so they survive the workflow TTL, addons override it with spec.lifecycle.archiveLogs.")
+	flag.StringVar(&artifactRepository, "artifact-repository", "",
+		"Artifact repository workflow logs are archived in as configmap or configmap/key, e.g. artifact-repositories/s3-logs. The ConfigMap must be in the workflow namespace, the default repository of the Argo workflow controller is used when empty.")
+	flag.StringVar(&commonParams, "common-params-configmap", "",
+		"ConfigMap as namespace/name whose keys are added to the global parameters of every addon workflow, e.g. addon-manager-system/addon-manager-common-params. Addon params with the same name take precedence.")
+	flag.BoolVar(&networkPolicies, "network-policies", false,
+		"Create a default deny network policy plus the allow rules declared in spec.networkPolicy in addon target namespaces after prereqs succeed.")
+	flag.StringVar(&cloudProvider, "cloud-provider", string(common.AWSProvider),
+		"Workload identity workflow roles map to: aws (IRSA and kube2iam annotations), gcp (GKE Workload Identity) or azure (Azure Workload Identity).")
+	flag.BoolVar(&enforceNamespaced, "enforce-namespaced-workflows", false,
+		"Refuse to submit workflows running as a service account with cluster-admin permissions unless the addon sets spec.clusterScoped.")
+	flag.BoolVar(&secretParams, "sensitive-params-from-secret", false,
+		"Pass decrypted SOPS params to workflow containers as environment variables from a Secret instead of plain text workflow parameters.")
+	flag.BoolVar(&addonCatalogs, "addon-catalogs", false,
+		"Sync AddonCatalog indexes, render addons with spec.catalog from the catalog templates and expand AddonProfiles into addons.")
+	flag.BoolVar(&addonTemplates, "addon-templates", false,
+		"Complete addons with spec.templateRef from their AddonTemplate and install them again when it changes, requires the AddonTemplate CRD.")
+	flag.BoolVar(&clusterHooks, "cluster-api-hooks", false,
+		"Watch Cluster API clusters to install fleet addons when clusters become ready and run their delete workflows before clusters are removed.")
+	flag.StringVar(&outputMode, "output-mode", "workflows",
+		"How addons are installed: workflows submits the lifecycle workflows, simulate applies the workflow artifacts directly without Argo, argocd renders an Argo CD Application and flux a Flux HelmRelease or Kustomization from spec.source, ocm and karmada propagate the workflow artifacts to spec.target clusters with ManifestWorks or a Karmada ClusterPropagationPolicy.")
+	flag.StringVar(&argoCDNamespace, "argocd-namespace", "argocd", "Namespace Argo CD Applications are created in with the argocd output mode.")
+	flag.StringVar(&argoCDProject, "argocd-project", "default", "Argo CD project of the Applications created with the argocd output mode.")
+	flag.DurationVar(&simulateDelay, "simulate-delay", 0, "How long workflows run before they succeed with the simulate output mode.")
+	flag.DurationVar(&fluxInterval, "flux-interval", 5*time.Minute, "Reconcile interval of the Flux objects created with the flux output mode.")
+	flag.StringVar(&hubKubeconfig, "hub-kubeconfig", "", "Kubeconfig of the hub API server used by the ocm and karmada output modes, the manager cluster when empty.")
+	flag.StringVar(&catalogNamespace, "catalog-namespace", "",
+		"Namespace of the catalog ConfigMaps addons with installDependencies IfNotPresent create missing dependencies from. Disabled when empty.")
+	flag.StringVar(&kubeVersionPolicy, "kube-version-policy", string(addon.EnforceKubeVersion),
+		"How installs of packages whose spec.kubeVersion excludes the target cluster version are handled: enforce fails them, warn records a warning event.")
+	flag.BoolVar(&validateGate, "validate-gate", false,
+		"Require the validate workflow of addons to succeed after install before they are Succeeded, addons override it with spec.lifecycle.validateGate.")
+	flag.StringVar(&workflowNamespace, "workflow-namespace", "",
+		"Namespace lifecycle workflows of addons in the local cluster run in so workflow pods do not consume tenant quotas, the addon namespace when empty. Workflow manifests without a namespace are applied to it.")
+	flag.StringVar(&workflowOwnerMode, "workflow-owner-mode", string(workflows.ControllerOwner),
+		"How workflows in the addon namespace reference their addon: controller sets the addon as their controller, owner adds an owner reference that does not block the addon deletion. Workflows are garbage collected with their addon either way.")
+	flag.StringVar(&workflowGCPolicy, "workflow-gc-policy", string(workflows.GCAfterTTL),
+		"When succeeded lifecycle workflows are deleted: ttl leaves them to their ttlSecondsAfterFinished, status-recorded deletes them once their result is recorded in the addon status, for clusters where workflows are a quota limited resource.")
+	flag.IntVar(&failureBudget, "install-failure-budget", 0,
+		"Number of times the install of an addon spec may fail within --install-failure-window before its workflows are no longer submitted until it is resumed, 0 retries failed installs indefinitely.")
+	flag.DurationVar(&failureWindow, "install-failure-window", time.Hour, "Window the install failures of an addon are counted in.")
+	flag.DurationVar(&failureBackoff, "install-failure-backoff", time.Minute,
+		"How long the first retry of a failed install waits, the wait doubles with every failure up to --install-failure-window.")
+	flag.IntVar(&maxConcurrent, "max-concurrent-reconciles", 1,
+		"Number of addons reconciled in parallel, addons wait for the addons of their dependencies to be installed.")
+	flag.DurationVar(&specDebounce, "spec-debounce", 0,
+		"How long the spec of an edited addon must not change before its workflows run, disabled by default.")
+	flag.IntVar(&templateOffload, "template-offload-threshold", addon.DefaultTemplateOffloadThreshold,
+		"Size in bytes over which the workflow templates of addons are offloaded to ConfigMaps so they stay below the object size limit, 0 disables offloading.")
+	flag.StringVar(&injectFaults, "inject-faults", os.Getenv(faults.EnvVar),
+		"Chaos testing faults as probabilities from 0 to 1, e.g. submit=0.1,flap=0.1,conflict=0.2,seed=42. Never enable in production.")
+	flag.IntVar(&shards, "shards", 1,
+		"Number of manager shards namespaces are split between by hash, each shard elects its own leader.")
+	flag.IntVar(&shardIndex, "shard-index", -1,
+		"Shard of namespaces reconciled by this manager, the ordinal of the StatefulSet pod name when negative.")
 	flag.Parse()
 
 	_ = addonmgrv1alpha1.AddToScheme(scheme)
@@ -53,23 +251,312 @@ func main() {
 
 	setupLog.Info(version.ToString())
 
+	if err := metrics.SetAggregation(metrics.Aggregation(metricsAggregation)); err != nil {
+		setupLog.Error(err, "invalid metrics aggregation")
+		os.Exit(1)
+	}
+
+	managerShard, err := newShard()
+	if err != nil {
+		setupLog.Error(err, "invalid shard")
+		os.Exit(1)
+	}
+	if managerShard != nil {
+		setupLog.Info("Reconciling namespaces of shard", "shard", managerShard.String())
+	}
+
 	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
 		Scheme:             scheme,
 		MetricsBindAddress: metricsAddr,
 		LeaderElection:     enableLeaderElection,
-		LeaderElectionID:   "addonmgr.keikoproj.io",
+		LeaderElectionID:   managerShard.LeaderElectionID("addonmgr.keikoproj.io"),
+		Port:               webhookPort,
+		CertDir:            webhookCertDir,
 	})
 	if err != nil {
 		setupLog.Error(err, "unable to start manager")
 		os.Exit(1)
 	}
 
-	err = controllers.NewAddonReconciler(mgr, ctrl.Log.WithName("controllers").WithName("Addon")).SetupWithManager(mgr)
+	reconciler := controllers.NewAddonReconciler(mgr, ctrl.Log.WithName("controllers").WithName("Addon"))
+
+	// The AWS session is shared by notifiers, Secrets Manager and KMS, credentials are resolved on first use
+	awsSession, err := awsauth.NewSession(awsRegion)
+	if err != nil {
+		setupLog.Error(err, "unable to create AWS session")
+		os.Exit(1)
+	}
+
+	// Chat notifiers are always enabled since addons can opt in with annotations
+	var notifiers []notify.Notifier
+	var chatHosts []string
+	if chatWebhookHosts != "" {
+		chatHosts = strings.Split(chatWebhookHosts, ",")
+	}
+	if slackWebhookURL != "" || len(chatHosts) > 0 {
+		notifiers = append(notifiers, notify.NewSlackNotifier(slackWebhookURL, workflowLogsURL, chatHosts))
+	}
+	if teamsWebhookURL != "" || len(chatHosts) > 0 {
+		notifiers = append(notifiers, notify.NewTeamsNotifier(teamsWebhookURL, workflowLogsURL, chatHosts))
+	}
+	if webhookNotifyURLs != "" {
+		notifiers = append(notifiers, notify.NewWebhookNotifier(strings.Split(webhookNotifyURLs, ","), os.Getenv("ADDONMGR_WEBHOOK_SECRET")))
+	}
+	if snsTopicARN != "" {
+		notifiers = append(notifiers, notify.NewSNSNotifier(awsSession, snsTopicARN))
+	}
+	if sqsQueueURL != "" {
+		notifiers = append(notifiers, notify.NewSQSNotifier(awsSession, sqsQueueURL))
+	}
+	if eventBridgeBusName != "" {
+		notifiers = append(notifiers, notify.NewEventBridgeNotifier(awsSession, eventBridgeBusName))
+	}
+	reconciler.Notifier = notify.NewMultiNotifier(notifiers...)
+
+	var vaultStore secrets.Store
+	if vaultAddr != "" {
+		vaultStore = secrets.NewVaultStore(vaultAddr, vaultRole, vaultAuthPath)
+	}
+	reconciler.SetSecretStores(vaultStore, secrets.NewSecretsManagerStore(awsSession))
+	var sourcePolicy secrets.SourcePolicy
+	if secretSourcePolicy != "" {
+		sourcePolicy, err = secrets.LoadSourcePolicy(secretSourcePolicy)
+		if err != nil {
+			setupLog.Error(err, "unable to load secret source policy")
+			os.Exit(1)
+		}
+	}
+	reconciler.SetSecretSourcePolicy(sourcePolicy, secretRefresh)
+
+	var ageKeys []string
+	if sopsAgeKeyFile != "" {
+		keyFile, err := ioutil.ReadFile(sopsAgeKeyFile)
+		if err != nil {
+			setupLog.Error(err, "unable to read sops age key file")
+			os.Exit(1)
+		}
+		ageKeys = sops.ParseAgeKeys(keyFile)
+	}
+	decryptor, err := sops.NewDecryptor(awsSession, ageKeys)
+	if err != nil {
+		setupLog.Error(err, "invalid sops age key")
+		os.Exit(1)
+	}
+	reconciler.SetSopsDecryptor(decryptor)
+	reconciler.SetSecretParams(secretParams)
+	reconciler.SetClusterLifecycleHooks(clusterHooks)
+	reconciler.SetAddonTemplates(addonTemplates)
+
+	if cosignPublicKeys != "" {
+		verifier, err := newImageVerifier(strings.Split(cosignPublicKeys, ","), registryConfig)
+		if err != nil {
+			setupLog.Error(err, "unable to configure cosign verification")
+			os.Exit(1)
+		}
+		reconciler.SetImageVerifier(verifier)
+	}
+
+	if addonSigningKeys != "" {
+		verifier, err := newProvenanceVerifier(strings.Split(addonSigningKeys, ","))
+		if err != nil {
+			setupLog.Error(err, "unable to configure addon package verification")
+			os.Exit(1)
+		}
+		reconciler.SetProvenanceVerifier(verifier)
+	}
+
+	provider, err := common.ParseCloudProvider(cloudProvider)
+	if err != nil {
+		setupLog.Error(err, "invalid cloud provider")
+		os.Exit(1)
+	}
+	reconciler.SetCloudProvider(provider)
+
+	kubeVersions, err := addon.ParseKubeVersionPolicy(kubeVersionPolicy)
+	if err != nil {
+		setupLog.Error(err, "invalid kube version policy")
+		os.Exit(1)
+	}
+	reconciler.SetKubeVersionPolicy(kubeVersions)
+	reconciler.SetValidateGate(validateGate)
+
+	if generateWorkflowRBAC && workflowSAs {
+		setupLog.Error(fmt.Errorf("--generate-workflow-rbac can not be combined with --workflow-service-accounts"), "invalid workflow service accounts")
+		os.Exit(1)
+	}
+	if workflowSAs && workflowClusterRole == "" {
+		// The shared cluster role is never defaulted to the broad role of the addon manager workflows
+		setupLog.Error(fmt.Errorf("--workflow-service-accounts requires --workflow-cluster-role"), "invalid workflow service accounts")
+		os.Exit(1)
+	}
+	if workflowNamespace != "" && (generateWorkflowRBAC || workflowSAs) {
+		// Workflow service accounts are provisioned in the addon namespace
+		setupLog.Error(fmt.Errorf("--workflow-namespace can not be combined with --generate-workflow-rbac or --workflow-service-accounts"), "invalid workflow namespace")
+		os.Exit(1)
+	}
+	reconciler.SetWorkflowNamespace(workflowNamespace)
+
+	ownerMode, err := workflows.ParseOwnerMode(workflowOwnerMode)
+	if err != nil {
+		setupLog.Error(err, "invalid workflow owner mode")
+		os.Exit(1)
+	}
+	reconciler.SetWorkflowOwnerMode(ownerMode)
+
+	gcPolicy, err := workflows.ParseGCPolicy(workflowGCPolicy)
+	if err != nil {
+		setupLog.Error(err, "invalid workflow gc policy")
+		os.Exit(1)
+	}
+	reconciler.SetWorkflowGCPolicy(gcPolicy)
+	reconciler.SetFailureBudget(addon.FailureBudget{Limit: failureBudget, Window: failureWindow, Backoff: failureBackoff})
+	reconciler.SetMaxConcurrentReconciles(maxConcurrent)
+	reconciler.SetSpecDebounce(specDebounce)
+	reconciler.SetTemplateOffloadThreshold(templateOffload)
+	reconciler.SetShard(managerShard)
+
+	faultConfig, err := faults.ParseConfig(injectFaults)
+	if err != nil {
+		setupLog.Error(err, "invalid faults")
+		os.Exit(1)
+	}
+	if faultConfig.Enabled() {
+		setupLog.Info("injecting faults for chaos testing", "faults", injectFaults)
+		reconciler.SetFaultInjector(faults.New(faultConfig))
+	}
+
+	if generateWorkflowRBAC {
+		generator := rbac.NewGenerator(kubernetes.NewForConfigOrDie(mgr.GetConfig()), mgr.GetRESTMapper())
+		generator.SetCloudProvider(provider)
+		if grantableRules != "" {
+			rules, err := rbac.LoadGrantableRules(grantableRules)
+			if err != nil {
+				setupLog.Error(err, "unable to load workflow grantable rules")
+				os.Exit(1)
+			}
+			generator.SetGrantableRules(rules)
+		}
+		reconciler.SetServiceAccountProvisioner(generator)
+	} else if workflowSAs {
+		identity := rbac.NewIdentity(kubernetes.NewForConfigOrDie(mgr.GetConfig()), workflowClusterRole)
+		identity.SetCloudProvider(provider)
+		reconciler.SetServiceAccountProvisioner(identity)
+	}
+
+	if restrictedPods {
+		reconciler.SetSecurityContextDefaults(workflows.RestrictedSecurityContext)
+	}
+
+	propagation, err := workflows.ParsePropagationPolicy(propagateLabels, propagateAnnotations)
+	if err != nil {
+		setupLog.Error(err, "invalid propagation policy")
+		os.Exit(1)
+	}
+	reconciler.SetPropagationPolicy(propagation)
+
+	logArchive, err := workflows.ParseLogArchive(archiveLogs, artifactRepository)
+	if err != nil {
+		setupLog.Error(err, "invalid artifact repository")
+		os.Exit(1)
+	}
+	reconciler.SetLogArchive(logArchive)
+
+	commonParamsRef, err := workflows.ParseCommonParams(commonParams)
+	if err != nil {
+		setupLog.Error(err, "invalid common params configmap")
+		os.Exit(1)
+	}
+	reconciler.SetCommonParams(commonParamsRef)
+
+	if networkPolicies {
+		reconciler.SetNetworkPolicyGenerator(netpol.NewGenerator(kubernetes.NewForConfigOrDie(mgr.GetConfig())))
+	}
+
+	if enforceNamespaced {
+		reconciler.SetServiceAccountChecker(rbac.NewScopeEnforcer(kubernetes.NewForConfigOrDie(mgr.GetConfig())))
+	}
+
+	if catalogNamespace != "" {
+		reconciler.SetCatalog(catalog.NewCatalog(kubernetes.NewForConfigOrDie(mgr.GetConfig()), catalogNamespace))
+	}
+
+	switch outputMode {
+	case "workflows":
+	case "simulate":
+		setupLog.Info("simulating workflows, Argo is not used", "delay", simulateDelay)
+		reconciler.SetWorkflowSimulator(workflows.NewSimulator(simulateDelay))
+	case "argocd":
+		reconciler.SetGitOpsGenerator(gitops.NewArgoCD(dynamic.NewForConfigOrDie(mgr.GetConfig()), argoCDNamespace, argoCDProject))
+	case "flux":
+		reconciler.SetGitOpsGenerator(gitops.NewFlux(dynamic.NewForConfigOrDie(mgr.GetConfig()), fluxInterval))
+	case "ocm", "karmada":
+		hub, err := newHubGenerator(outputMode, mgr.GetConfig())
+		if err != nil {
+			setupLog.Error(err, "unable to connect to hub")
+			os.Exit(1)
+		}
+		reconciler.SetHubGenerator(hub)
+	default:
+		setupLog.Error(fmt.Errorf("unknown output mode %s", outputMode), "invalid output mode")
+		os.Exit(1)
+	}
+
+	if addonCatalogs {
+		catalogs := catalog.NewStore()
+		reconciler.SetCatalogStore(catalogs)
+		catalogReconciler := controllers.NewAddonCatalogReconciler(mgr, ctrl.Log.WithName("controllers").WithName("AddonCatalog"), catalogs)
+		catalogReconciler.SetShard(managerShard)
+		err = catalogReconciler.SetupWithManager(mgr)
+		if err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "AddonCatalog")
+			os.Exit(1)
+		}
+		profileReconciler := controllers.NewAddonProfileReconciler(mgr, ctrl.Log.WithName("controllers").WithName("AddonProfile"))
+		profileReconciler.SetShard(managerShard)
+		err = profileReconciler.SetupWithManager(mgr)
+		if err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "AddonProfile")
+			os.Exit(1)
+		}
+	}
+
+	err = reconciler.SetupWithManager(mgr)
 	if err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "Addon")
 		os.Exit(1)
 	}
 
+	if enableWebhooks {
+		if err := webhook.Register(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "Addon")
+			os.Exit(1)
+		}
+	}
+
+	if addonStateMetrics {
+		collector := metrics.NewAddonStateCollector(mgr.GetClient(), ctrl.Log.WithName("metrics"))
+		if err := ctrlmetrics.Registry.Register(collector); err != nil {
+			setupLog.Error(err, "unable to register addon state metrics")
+			os.Exit(1)
+		}
+	}
+
+	if addonInventory {
+		if err := mgr.AddMetricsExtraHandler("/inventory", inventory.Handler(mgr.GetClient())); err != nil {
+			setupLog.Error(err, "unable to add addon inventory endpoint")
+			os.Exit(1)
+		}
+	}
+
+	if diagnosticsAddr != "" {
+		diag := diagnostics.NewServer(diagnosticsAddr)
+		diag.Register("addons", reconciler.Diagnostics)
+		if err := mgr.Add(diag); err != nil {
+			setupLog.Error(err, "unable to add diagnostics server")
+			os.Exit(1)
+		}
+	}
+
 	// +kubebuilder:scaffold:builder
 
 	setupLog.Info("starting manager")
@@ -78,3 +565,84 @@ func main() {
 		os.Exit(1)
 	}
 }
+
+func readKeyFiles(keyFiles []string) ([][]byte, error) {
+	var keys [][]byte
+	for _, f := range keyFiles {
+		key, err := ioutil.ReadFile(strings.TrimSpace(f))
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+func newProvenanceVerifier(keyFiles []string) (*provenance.Verifier, error) {
+	keys, err := readKeyFiles(keyFiles)
+	if err != nil {
+		return nil, err
+	}
+	return provenance.NewVerifier(keys)
+}
+
+func newImageVerifier(keyFiles []string, registryConfig string) (*cosign.Verifier, error) {
+	keys, err := readKeyFiles(keyFiles)
+	if err != nil {
+		return nil, err
+	}
+
+	var creds map[string]cosign.Credentials
+	if registryConfig != "" {
+		config, err := ioutil.ReadFile(registryConfig)
+		if err != nil {
+			return nil, err
+		}
+		if creds, err = cosign.ParseDockerConfig(config); err != nil {
+			return nil, err
+		}
+	}
+
+	return cosign.NewVerifier(keys, creds)
+}
+
+// newHubGenerator returns the generator of the ocm or karmada output mode
+func newHubGenerator(mode string, cfg *rest.Config) (gitops.Generator, error) {
+	if hubKubeconfig != "" {
+		var err error
+		if cfg, err = clientcmd.BuildConfigFromFlags("", hubKubeconfig); err != nil {
+			return nil, err
+		}
+	}
+	dynClient, err := dynamic.NewForConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if mode == "ocm" {
+		return gitops.NewOCM(dynClient), nil
+	}
+
+	mapper, err := apiutil.NewDynamicRESTMapper(cfg, apiutil.WithLazyDiscovery)
+	if err != nil {
+		return nil, err
+	}
+	return gitops.NewKarmada(dynClient, mapper), nil
+}
+
+// newShard returns the shard of namespaces reconciled by the manager, nil when namespaces are not sharded
+func newShard() (*shard.Shard, error) {
+	if shards <= 1 {
+		return nil, nil
+	}
+	index := shardIndex
+	if index < 0 {
+		hostname, err := os.Hostname()
+		if err != nil {
+			return nil, err
+		}
+		if index, err = shard.Ordinal(hostname); err != nil {
+			return nil, err
+		}
+	}
+	return shard.New(shards, index)
+}
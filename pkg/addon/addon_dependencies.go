@@ -0,0 +1,114 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package addon
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	addonmgrv1alpha1 "github.com/keikoproj/addon-manager/api/v1alpha1"
+	"github.com/keikoproj/addon-manager/pkg/deps"
+)
+
+// AddonDependencyStatuses returns the state of the addon dependencies declared by the addon, in declaration order.
+// Addons in other namespaces that are not shared with the addon namespace are reported like addons that do not
+// exist, as are namespaces the reader may not read, so the status of a dependent does not disclose addons its
+// namespace has no access to.
+func AddonDependencyStatuses(ctx context.Context, reader client.Reader, a *addonmgrv1alpha1.Addon) []addonmgrv1alpha1.DependencyStatus {
+	statuses := make([]addonmgrv1alpha1.DependencyStatus, 0, len(a.Spec.AddonDeps))
+	for _, dep := range a.Spec.AddonDeps {
+		statuses = append(statuses, addonDependencyStatus(ctx, reader, a, dep))
+	}
+	return statuses
+}
+
+func addonDependencyStatus(ctx context.Context, reader client.Reader, a *addonmgrv1alpha1.Addon, dep addonmgrv1alpha1.AddonDependency) addonmgrv1alpha1.DependencyStatus {
+	ns := dep.Namespace
+	if ns == "" {
+		ns = a.Namespace
+	}
+	status := addonmgrv1alpha1.DependencyStatus{Name: ns + "/" + dep.Name, Required: strings.TrimSpace(dep.Version)}
+	if status.Required == "" {
+		status.Required = "*"
+	}
+
+	var candidates []addonmgrv1alpha1.Addon
+	var err error
+	switch {
+	case dep.Name != "":
+		var found addonmgrv1alpha1.Addon
+		if err = reader.Get(ctx, types.NamespacedName{Namespace: ns, Name: dep.Name}, &found); err == nil {
+			candidates = append(candidates, found)
+		}
+	case dep.Selector != nil:
+		selector, serr := metav1.LabelSelectorAsSelector(dep.Selector)
+		if serr != nil {
+			status.Message = fmt.Sprintf("invalid selector: %v", serr)
+			return status
+		}
+		status.Name = ns + "/" + selector.String()
+		var addons addonmgrv1alpha1.AddonList
+		if err = reader.List(ctx, &addons, client.InNamespace(ns), client.MatchingLabelsSelector{Selector: selector}); err == nil {
+			candidates = addons.Items
+		}
+	default:
+		status.Message = "one of name or selector is required"
+		return status
+	}
+	if err != nil && !apierrors.IsNotFound(err) && !apierrors.IsForbidden(err) {
+		status.Message = fmt.Sprintf("failed to look up addons: %v", err)
+		return status
+	}
+
+	// Installed addons are preferred over addons still installing, addons in other clusters do not satisfy local
+	// dependencies
+	for i := range candidates {
+		c := &candidates[i]
+		if (c.Namespace == a.Namespace && c.Name == a.Name) || c.Spec.Target != nil || !SharedWith(c, a.Namespace) {
+			continue
+		}
+		if !deps.Matches(status.Required, c.Spec.PkgVersion) || (status.Addon != "" && status.Satisfied) {
+			continue
+		}
+		status.Found = c.Spec.PkgVersion
+		status.Addon = c.Namespace + "/" + c.Name
+		status.Installed = c.Status.Lifecycle.Installed
+		status.Satisfied = status.Installed == addonmgrv1alpha1.Succeeded
+	}
+	if status.Addon == "" {
+		status.Message = "no addon shared with the namespace matches"
+	}
+	return status
+}
+
+// SharedWith returns true when addons in the namespace may depend on the addon, addons are always shared with their
+// own namespace
+func SharedWith(a *addonmgrv1alpha1.Addon, namespace string) bool {
+	if a.Namespace == namespace {
+		return true
+	}
+	for _, ns := range strings.Split(a.GetAnnotations()[addonmgrv1alpha1.SharedWithAnnotation], ",") {
+		if ns = strings.TrimSpace(ns); ns == "*" || ns == namespace {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,95 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package addon
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	runtimefake "sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	addonmgrv1alpha1 "github.com/keikoproj/addon-manager/api/v1alpha1"
+)
+
+func dependencyAddon(namespace, name, version string, phase addonmgrv1alpha1.ApplicationAssemblyPhase) *addonmgrv1alpha1.Addon {
+	a := &addonmgrv1alpha1.Addon{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace}}
+	a.Spec.PkgName = name
+	a.Spec.PkgVersion = version
+	a.Status.Lifecycle.Installed = phase
+	return a
+}
+
+func TestAddonDependencyStatuses(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	sch := runtime.NewScheme()
+	g.Expect(addonmgrv1alpha1.AddToScheme(sch)).To(Succeed())
+
+	certManager := dependencyAddon("cert-manager", "cert-manager", "1.2.0", addonmgrv1alpha1.Succeeded)
+	certManager.Annotations = map[string]string{addonmgrv1alpha1.SharedWithAnnotation: "team-a, team-b"}
+	private := dependencyAddon("platform", "vault", "1.0.0", addonmgrv1alpha1.Succeeded)
+	ingressOld := dependencyAddon("ingress", "nginx-old", "0.9.0", addonmgrv1alpha1.Succeeded)
+	ingressOld.Labels = map[string]string{"tier": "ingress"}
+	ingressOld.Annotations = map[string]string{addonmgrv1alpha1.SharedWithAnnotation: "*"}
+	ingress := dependencyAddon("ingress", "nginx", "1.1.0", addonmgrv1alpha1.Pending)
+	ingress.Labels, ingress.Annotations = ingressOld.Labels, ingressOld.Annotations
+	local := dependencyAddon("team-a", "db", "2.0.0", addonmgrv1alpha1.Succeeded)
+
+	reader := runtimefake.NewFakeClientWithScheme(sch, certManager, private, ingressOld, ingress, local)
+
+	a := dependencyAddon("team-a", "app", "1.0.0", "")
+	a.Spec.AddonDeps = []addonmgrv1alpha1.AddonDependency{
+		{Namespace: "cert-manager", Name: "cert-manager", Version: ">=1.0"},
+		{Namespace: "platform", Name: "vault"},
+		{Namespace: "ingress", Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"tier": "ingress"}}, Version: ">=1.0"},
+		{Name: "db"},
+		{Namespace: "platform", Name: "missing"},
+		{Namespace: "platform"},
+	}
+
+	statuses := AddonDependencyStatuses(context.TODO(), reader, a)
+	g.Expect(statuses).To(Equal([]addonmgrv1alpha1.DependencyStatus{
+		{Name: "cert-manager/cert-manager", Required: ">=1.0", Found: "1.2.0", Addon: "cert-manager/cert-manager", Installed: addonmgrv1alpha1.Succeeded, Satisfied: true},
+		// Addons not shared with the namespace are not disclosed
+		{Name: "platform/vault", Required: "*", Message: "no addon shared with the namespace matches"},
+		{Name: "ingress/tier=ingress", Required: ">=1.0", Found: "1.1.0", Addon: "ingress/nginx", Installed: addonmgrv1alpha1.Pending},
+		{Name: "team-a/db", Required: "*", Found: "2.0.0", Addon: "team-a/db", Installed: addonmgrv1alpha1.Succeeded, Satisfied: true},
+		{Name: "platform/missing", Required: "*", Message: "no addon shared with the namespace matches"},
+		{Name: "platform/", Required: "*", Message: "one of name or selector is required"},
+	}))
+
+	// Addons shared with other namespaces only
+	a.Namespace = "team-c"
+	a.Spec.AddonDeps = a.Spec.AddonDeps[:1]
+	g.Expect(AddonDependencyStatuses(context.TODO(), reader, a)[0].Satisfied).To(BeFalse())
+}
+
+func TestSharedWith(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	a := dependencyAddon("cert-manager", "cert-manager", "1.2.0", "")
+	g.Expect(SharedWith(a, "cert-manager")).To(BeTrue())
+	g.Expect(SharedWith(a, "team-a")).To(BeFalse())
+
+	a.Annotations = map[string]string{addonmgrv1alpha1.SharedWithAnnotation: "team-a,team-b"}
+	g.Expect(SharedWith(a, "team-b")).To(BeTrue())
+	g.Expect(SharedWith(a, "team-c")).To(BeFalse())
+
+	a.Annotations[addonmgrv1alpha1.SharedWithAnnotation] = "*"
+	g.Expect(SharedWith(a, "team-c")).To(BeTrue())
+}
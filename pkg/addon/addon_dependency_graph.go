@@ -0,0 +1,89 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package addon
+
+import (
+	"strings"
+
+	addonmgrv1alpha1 "github.com/keikoproj/addon-manager/api/v1alpha1"
+)
+
+// DependencyGraphStats summarizes the dependency graph formed by all cached addon versions
+type DependencyGraphStats struct {
+	// Edges is the number of declared dependencies across all addons
+	Edges int
+	// Unsatisfied is the number of dependencies that are missing or not yet Succeeded
+	Unsatisfied int
+	// LongestChain is the number of edges in the longest dependency chain
+	LongestChain int
+}
+
+// GetDependencyGraphStats walks the version cache and computes the dependency graph stats
+func GetDependencyGraphStats(cache VersionCacheClient) DependencyGraphStats {
+	var stats DependencyGraphStats
+	var depths = make(map[string]int)
+
+	for _, vmap := range cache.GetAllVersions() {
+		for _, v := range vmap {
+			for pkgName, pkgVersion := range v.PkgDeps {
+				stats.Edges++
+				dep := cache.GetVersion(strings.TrimSpace(pkgName), strings.TrimSpace(pkgVersion))
+				if dep == nil || dep.PkgPhase != addonmgrv1alpha1.Succeeded {
+					stats.Unsatisfied++
+				}
+			}
+
+			v := v
+			if d := chainDepth(cache, &v, depths, make(map[string]bool)); d > stats.LongestChain {
+				stats.LongestChain = d
+			}
+		}
+	}
+
+	return stats
+}
+
+// chainDepth returns the number of edges in the longest chain starting at v, cycles are not followed
+func chainDepth(cache VersionCacheClient, v *Version, depths map[string]int, visiting map[string]bool) int {
+	name := v.PkgName + ":" + v.PkgVersion
+	if d, ok := depths[name]; ok {
+		return d
+	}
+	if visiting[name] {
+		return 0
+	}
+	visiting[name] = true
+
+	var longest = 0
+	for pkgName, pkgVersion := range v.PkgDeps {
+		dep := cache.GetVersion(strings.TrimSpace(pkgName), strings.TrimSpace(pkgVersion))
+		if dep == nil {
+			// Unresolved dependencies still count as an edge in the chain
+			if longest < 1 {
+				longest = 1
+			}
+			continue
+		}
+
+		if d := chainDepth(cache, dep, depths, visiting) + 1; d > longest {
+			longest = d
+		}
+	}
+
+	delete(visiting, name)
+	depths[name] = longest
+
+	return longest
+}
@@ -0,0 +1,71 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package addon
+
+import (
+	"testing"
+
+	addonmgrv1alpha1 "github.com/keikoproj/addon-manager/api/v1alpha1"
+)
+
+func newGraphVersion(name, version string, phase addonmgrv1alpha1.ApplicationAssemblyPhase, deps map[string]string) Version {
+	return Version{
+		Name:      name,
+		Namespace: "default",
+		PackageSpec: addonmgrv1alpha1.PackageSpec{
+			PkgName:    name,
+			PkgVersion: version,
+			PkgDeps:    deps,
+		},
+		PkgPhase: phase,
+	}
+}
+
+func TestGetDependencyGraphStats(t *testing.T) {
+	tests := []struct {
+		name     string
+		versions []Version
+		want     DependencyGraphStats
+	}{
+		{name: "empty-cache", versions: nil, want: DependencyGraphStats{}},
+		{name: "no-deps", versions: []Version{
+			newGraphVersion("a", "1.0.0", addonmgrv1alpha1.Succeeded, nil),
+		}, want: DependencyGraphStats{}},
+		{name: "chain-satisfied", versions: []Version{
+			newGraphVersion("a", "1.0.0", addonmgrv1alpha1.Succeeded, nil),
+			newGraphVersion("b", "1.0.0", addonmgrv1alpha1.Succeeded, map[string]string{"a": "1.0.0"}),
+			newGraphVersion("c", "1.0.0", addonmgrv1alpha1.Pending, map[string]string{"b": "*"}),
+		}, want: DependencyGraphStats{Edges: 2, Unsatisfied: 0, LongestChain: 2}},
+		{name: "chain-pending-and-missing", versions: []Version{
+			newGraphVersion("a", "1.0.0", addonmgrv1alpha1.Pending, nil),
+			newGraphVersion("b", "1.0.0", addonmgrv1alpha1.Pending, map[string]string{"a": "1.0.0", "z": "2.0.0"}),
+		}, want: DependencyGraphStats{Edges: 2, Unsatisfied: 2, LongestChain: 1}},
+		{name: "cycle", versions: []Version{
+			newGraphVersion("a", "1.0.0", addonmgrv1alpha1.Succeeded, map[string]string{"b": "1.0.0"}),
+			newGraphVersion("b", "1.0.0", addonmgrv1alpha1.Succeeded, map[string]string{"a": "1.0.0"}),
+		}, want: DependencyGraphStats{Edges: 2, Unsatisfied: 0, LongestChain: 2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewAddonVersionCacheClient()
+			for _, v := range tt.versions {
+				c.AddVersion(v)
+			}
+			if got := GetDependencyGraphStats(c); got != tt.want {
+				t.Errorf("GetDependencyGraphStats() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,58 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package addon
+
+import (
+	"sort"
+	"strings"
+
+	addonmgrv1alpha1 "github.com/keikoproj/addon-manager/api/v1alpha1"
+)
+
+// WaitingDependencies returns the package dependencies of the addon that have addons still installing, in name order.
+// Addons without waiting dependencies install in parallel, dependents are scheduled again when their dependencies
+// change so dependency chains install one after the other. Dependencies without addons are left to validation.
+func WaitingDependencies(a *addonmgrv1alpha1.Addon, addons []addonmgrv1alpha1.Addon) []string {
+	if len(a.Spec.PkgDeps) == 0 {
+		return nil
+	}
+
+	// Addons installed in other clusters do not satisfy local dependencies
+	var installing = make(map[string]bool)
+	for i := range addons {
+		dep := &addons[i]
+		if dep.Spec.Target != nil || (dep.Namespace == a.Namespace && dep.Name == a.Name) {
+			continue
+		}
+		switch dep.Status.Lifecycle.Installed {
+		case "", addonmgrv1alpha1.Pending:
+			if _, ok := installing[dep.Spec.PkgName]; !ok {
+				installing[dep.Spec.PkgName] = true
+			}
+		default:
+			installing[dep.Spec.PkgName] = false
+		}
+	}
+
+	var waiting []string
+	for name := range a.Spec.PkgDeps {
+		name = strings.TrimSpace(name)
+		if installing[name] {
+			waiting = append(waiting, name)
+		}
+	}
+	sort.Strings(waiting)
+	return waiting
+}
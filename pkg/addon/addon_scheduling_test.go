@@ -0,0 +1,55 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package addon
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	addonmgrv1alpha1 "github.com/keikoproj/addon-manager/api/v1alpha1"
+)
+
+func schedulingAddon(name, pkgName string, phase addonmgrv1alpha1.ApplicationAssemblyPhase) addonmgrv1alpha1.Addon {
+	a := addonmgrv1alpha1.Addon{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "addon-manager-system"}}
+	a.Spec.PkgName = pkgName
+	a.Status.Lifecycle.Installed = phase
+	return a
+}
+
+func TestWaitingDependencies(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	a := schedulingAddon("app", "app", "")
+	g.Expect(WaitingDependencies(&a, nil)).To(BeEmpty())
+
+	a.Spec.PkgDeps = map[string]string{"core": "*", " mesh": "*", "dns": "*", "missing": "*"}
+	remote := schedulingAddon("remote-dns", "dns", addonmgrv1alpha1.Succeeded)
+	remote.Spec.Target = &addonmgrv1alpha1.AddonTarget{}
+	addons := []addonmgrv1alpha1.Addon{
+		a,
+		schedulingAddon("core", "core", addonmgrv1alpha1.Succeeded),
+		schedulingAddon("mesh", "mesh", ""),
+		schedulingAddon("dns", "dns", addonmgrv1alpha1.Pending),
+		remote,
+	}
+	g.Expect(WaitingDependencies(&a, addons)).To(Equal([]string{"dns", "mesh"}))
+
+	// Failed dependencies are reported by validation
+	addons[2].Status.Lifecycle.Installed = addonmgrv1alpha1.Failed
+	addons[3].Status.Lifecycle.Installed = addonmgrv1alpha1.Succeeded
+	g.Expect(WaitingDependencies(&a, addons)).To(BeEmpty())
+}
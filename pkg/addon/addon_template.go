@@ -0,0 +1,141 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package addon
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	addonmgrv1alpha1 "github.com/keikoproj/addon-manager/api/v1alpha1"
+)
+
+const (
+	lifecyclePath         = "lifecycle."
+	namespacePath         = "params.namespace"
+	clusterNamePath       = "params.context.clusterName"
+	clusterRegionPath     = "params.context.clusterRegion"
+	additionalConfigsPath = "params.context.additionalConfigs."
+	dataPath              = "params.data."
+	sopsPath              = "params.sops"
+)
+
+// ApplyTemplate completes the spec of an addon with the lifecycle workflows and params of the AddonTemplate it
+// references, it returns the paths of the spec fields set from the template. Workflows and params set on the addon
+// take precedence, the skip flags of the addon apply to the template workflows.
+func ApplyTemplate(a *addonmgrv1alpha1.Addon, t *addonmgrv1alpha1.AddonTemplate) []string {
+	var defaults []string
+	tmpl := &addonmgrv1alpha1.Addon{Spec: addonmgrv1alpha1.AddonSpec{Lifecycle: t.Spec.Lifecycle}}
+	for _, step := range lifecycleSteps {
+		wt, _ := a.GetWorkflowType(step)
+		twt, _ := tmpl.GetWorkflowType(step)
+		if wt.Template != "" || wt.TemplateFrom != nil || (twt.Template == "" && twt.TemplateFrom == nil) {
+			continue
+		}
+		skip := wt.Skip
+		twt.DeepCopyInto(wt)
+		wt.Skip = skip
+		defaults = append(defaults, lifecyclePath+string(step))
+	}
+
+	params, defaultParams := &a.Spec.Params, t.Spec.Params
+	if params.Namespace == "" && defaultParams.Namespace != "" {
+		params.Namespace = defaultParams.Namespace
+		defaults = append(defaults, namespacePath)
+	}
+	if params.Context.ClusterName == "" && defaultParams.Context.ClusterName != "" {
+		params.Context.ClusterName = defaultParams.Context.ClusterName
+		defaults = append(defaults, clusterNamePath)
+	}
+	if params.Context.ClusterRegion == "" && defaultParams.Context.ClusterRegion != "" {
+		params.Context.ClusterRegion = defaultParams.Context.ClusterRegion
+		defaults = append(defaults, clusterRegionPath)
+	}
+	for _, k := range sortedKeys(defaultParams.Context.AdditionalConfigs) {
+		if _, ok := params.Context.AdditionalConfigs[k]; ok {
+			continue
+		}
+		if params.Context.AdditionalConfigs == nil {
+			params.Context.AdditionalConfigs = make(map[string]addonmgrv1alpha1.FlexString)
+		}
+		params.Context.AdditionalConfigs[k] = defaultParams.Context.AdditionalConfigs[k]
+		defaults = append(defaults, additionalConfigsPath+k)
+	}
+	for _, k := range sortedKeys(defaultParams.Data) {
+		if _, ok := params.Data[k]; ok {
+			continue
+		}
+		if params.Data == nil {
+			params.Data = make(map[string]addonmgrv1alpha1.FlexString)
+		}
+		params.Data[k] = defaultParams.Data[k]
+		defaults = append(defaults, dataPath+k)
+	}
+	if params.Sops == nil && defaultParams.Sops != nil {
+		params.Sops = defaultParams.Sops.DeepCopy()
+		defaults = append(defaults, sopsPath)
+	}
+	return defaults
+}
+
+// RemoveTemplateDefaults clears the spec fields set from the template, so the addon is saved with the spec it was
+// created with
+func RemoveTemplateDefaults(a *addonmgrv1alpha1.Addon, defaults []string) {
+	params := &a.Spec.Params
+	for _, path := range defaults {
+		switch {
+		case strings.HasPrefix(path, lifecyclePath):
+			if wt, err := a.GetWorkflowType(addonmgrv1alpha1.LifecycleStep(strings.TrimPrefix(path, lifecyclePath))); err == nil {
+				*wt = addonmgrv1alpha1.WorkflowType{Skip: wt.Skip}
+			}
+		case path == namespacePath:
+			params.Namespace = ""
+		case path == clusterNamePath:
+			params.Context.ClusterName = ""
+		case path == clusterRegionPath:
+			params.Context.ClusterRegion = ""
+		case strings.HasPrefix(path, additionalConfigsPath):
+			delete(params.Context.AdditionalConfigs, strings.TrimPrefix(path, additionalConfigsPath))
+			if len(params.Context.AdditionalConfigs) == 0 {
+				params.Context.AdditionalConfigs = nil
+			}
+		case strings.HasPrefix(path, dataPath):
+			delete(params.Data, strings.TrimPrefix(path, dataPath))
+			if len(params.Data) == 0 {
+				params.Data = nil
+			}
+		case path == sopsPath:
+			params.Sops = nil
+		}
+	}
+}
+
+// ValidateTemplateRef checks an addon referencing an AddonTemplate is not rendered from a catalog as well, the
+// catalog sets the lifecycle of the package version
+func ValidateTemplateRef(a *addonmgrv1alpha1.Addon) error {
+	if a.Spec.TemplateRef != "" && a.Spec.Catalog != "" {
+		return fmt.Errorf("addon.spec.templateRef %s cannot be used with addon.spec.catalog %s", a.Spec.TemplateRef, a.Spec.Catalog)
+	}
+	return nil
+}
+
+func sortedKeys(m map[string]addonmgrv1alpha1.FlexString) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,108 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package addon
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	addonmgrv1alpha1 "github.com/keikoproj/addon-manager/api/v1alpha1"
+)
+
+func TestApplyTemplate(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	tmpl := &addonmgrv1alpha1.AddonTemplate{
+		ObjectMeta: metav1.ObjectMeta{Name: "helm-chart", Namespace: "default"},
+		Spec: addonmgrv1alpha1.AddonTemplateSpec{
+			Lifecycle: addonmgrv1alpha1.LifecycleWorkflowSpec{
+				Prereqs: addonmgrv1alpha1.WorkflowType{Template: "kind: Workflow\n# prereqs", Role: "arn:aws:iam::123456789012:role/prereqs"},
+				Install: addonmgrv1alpha1.WorkflowType{Template: "kind: Workflow\n# install"},
+				Delete:  addonmgrv1alpha1.WorkflowType{Template: "kind: Workflow\n# delete"},
+			},
+			Params: addonmgrv1alpha1.AddonParams{
+				Namespace: "charts",
+				Context:   addonmgrv1alpha1.ClusterContext{ClusterRegion: "us-west-2"},
+				Data:      map[string]addonmgrv1alpha1.FlexString{"repository": "https://charts.example.com", "replicas": "1"},
+			},
+		},
+	}
+	a := &addonmgrv1alpha1.Addon{
+		ObjectMeta: metav1.ObjectMeta{Name: "external-dns", Namespace: "default"},
+		Spec: addonmgrv1alpha1.AddonSpec{
+			PackageSpec: addonmgrv1alpha1.PackageSpec{PkgName: "external-dns", PkgVersion: "1.0.0"},
+			TemplateRef: "helm-chart",
+			Params: addonmgrv1alpha1.AddonParams{
+				Data: map[string]addonmgrv1alpha1.FlexString{"chart": "external-dns", "replicas": "2"},
+			},
+			Lifecycle: addonmgrv1alpha1.LifecycleWorkflowSpec{
+				Prereqs: addonmgrv1alpha1.WorkflowType{Skip: true},
+				Delete:  addonmgrv1alpha1.WorkflowType{Template: "kind: Workflow\n# local delete"},
+			},
+		},
+	}
+	original := a.DeepCopy()
+
+	defaults := ApplyTemplate(a, tmpl)
+	g.Expect(defaults).To(Equal([]string{
+		"lifecycle.prereqs",
+		"lifecycle.install",
+		"params.namespace",
+		"params.context.clusterRegion",
+		"params.data.repository",
+	}))
+
+	// Template workflows keep the skip flags of the addon, workflows set on the addon take precedence
+	g.Expect(a.Spec.Lifecycle.Prereqs.Template).To(Equal("kind: Workflow\n# prereqs"))
+	g.Expect(a.Spec.Lifecycle.Prereqs.Role).To(Equal("arn:aws:iam::123456789012:role/prereqs"))
+	g.Expect(a.StepSkipped(addonmgrv1alpha1.Prereqs)).To(BeTrue())
+	g.Expect(a.Spec.Lifecycle.Install.Template).To(Equal("kind: Workflow\n# install"))
+	g.Expect(a.Spec.Lifecycle.Delete.Template).To(Equal("kind: Workflow\n# local delete"))
+
+	// Template params are defaults, data parameters are merged
+	g.Expect(a.Spec.Params.Namespace).To(Equal("charts"))
+	g.Expect(a.Spec.Params.Context.GetRegion()).To(Equal("us-west-2"))
+	g.Expect(a.Spec.Params.Data).To(Equal(map[string]addonmgrv1alpha1.FlexString{
+		"chart":      "external-dns",
+		"replicas":   "2",
+		"repository": "https://charts.example.com",
+	}))
+
+	// The template is not changed
+	g.Expect(tmpl.Spec.Params.Data).To(HaveLen(2))
+
+	// Removing the defaults restores the spec the addon was created with
+	RemoveTemplateDefaults(a, defaults)
+	g.Expect(a.Spec).To(Equal(original.Spec))
+}
+
+func TestValidateTemplateRef(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	a := &addonmgrv1alpha1.Addon{
+		ObjectMeta: metav1.ObjectMeta{Name: "external-dns", Namespace: "default"},
+		Spec: addonmgrv1alpha1.AddonSpec{
+			PackageSpec: addonmgrv1alpha1.PackageSpec{PkgName: "external-dns", PkgVersion: "1.0.0"},
+			TemplateRef: "helm-chart",
+		},
+	}
+	// The namespace may come from the template
+	g.Expect(ValidateSpec(a)).To(Succeed())
+
+	a.Spec.Catalog = "addoncatalog-sample"
+	g.Expect(ValidateSpec(a)).To(MatchError(ContainSubstring("cannot be used with addon.spec.catalog")))
+}
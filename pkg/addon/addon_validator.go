@@ -16,16 +16,20 @@ package addon
 
 import (
 	"fmt"
+	"sort"
 	"strings"
 
+	"github.com/Masterminds/semver/v3"
 	"gopkg.in/yaml.v3"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation"
 	"k8s.io/client-go/dynamic"
 
 	addonmgrv1alpha1 "github.com/keikoproj/addon-manager/api/v1alpha1"
 	"github.com/keikoproj/addon-manager/pkg/common"
+	"github.com/keikoproj/addon-manager/pkg/deps"
 )
 
 const (
@@ -56,38 +60,32 @@ func (av *addonValidator) Validate() (bool, error) {
 		PkgPhase:    av.addon.Status.Lifecycle.Installed,
 	}
 
-	// Validate version is not already set in cache, dupe.
-	err := av.validateDuplicate(version)
-	if err != nil {
-		return false, err
+	// Validate that addons installed from a catalog were rendered
+	if av.addon.Spec.Catalog != "" && av.addon.Spec.PkgVersion == "" {
+		return false, fmt.Errorf("pkgVersion is empty in addon.spec.pkgVersion, addon catalog %s was not rendered", av.addon.Spec.Catalog)
 	}
 
-	// Validate length of addon name
-	err = av.validateAddonNameLength()
+	// Validate the spec the admission webhook validates, addons may predate the webhook
+	err := ValidateSpec(av.addon)
 	if err != nil {
 		return false, err
 	}
 
-	// Validate that the addon has been given a namespace
-	if av.addon.Spec.Params.Namespace == "" {
-		return false, fmt.Errorf("namespace is empty in addon.spec.params.namespace")
-	}
-
-	// Validate workflow template is actually a workflow
-	err = av.validateWorkflow()
+	// Validate dependencies are resolvable without conflicts or cycles.
+	plan, err := av.resolveDependencies()
 	if err != nil {
 		return false, err
 	}
 
-	// Validate dependencies are resolvable, no diamond dependency cycles.
-	var visited = make(map[string]*Version)
-	err = av.resolveDependencies(version, visited, 0)
+	// Validate dependencies are installed.
+	err = av.validateDependencies(plan)
 	if err != nil {
 		return false, err
 	}
 
-	// Validate dependencies are installed.
-	err = av.validateDependencies()
+	// Validate version is not already set in cache, dupe. The version is claimed last so invalid addons do not
+	// keep valid addons from installing it.
+	err = av.validateDuplicate(version)
 	if err != nil {
 		return false, err
 	}
@@ -96,21 +94,94 @@ func (av *addonValidator) Validate() (bool, error) {
 }
 
 func (av *addonValidator) validateDuplicate(version *Version) error {
-	if v := av.cache.GetVersion(version.PkgName, version.PkgVersion); v != nil && v.Name != version.Name {
+	// Addons targeting other clusters, e.g. the members of a fleet, install the same package in different clusters
+	if av.addon.Spec.Target != nil {
+		return nil
+	}
+
+	if v := av.cache.ClaimVersion(*version); v != nil {
 		return fmt.Errorf("package version %s:%s already exists and cannot be installed as a duplicate", av.addon.Spec.PkgName, av.addon.Spec.PkgVersion)
 	}
 
 	return nil
 }
 
-func (av *addonValidator) validateWorkflow() error {
+// ValidateSpec checks the addon spec without looking up other addons or the cluster: the name, the namespace
+// param, the package version and dependency constraints, the maintenance window and the workflow templates
+func ValidateSpec(addon *addonmgrv1alpha1.Addon) error {
+	if len(addon.Name) > 31 {
+		return fmt.Errorf("Addon name %s must be less than 32 characters", addon.Name)
+	}
+
+	// The namespace of addons referencing an AddonTemplate may come from the template
+	if addon.Spec.Params.Namespace == "" && addon.Spec.TemplateRef == "" {
+		return fmt.Errorf("namespace is empty in addon.spec.params.namespace")
+	}
+
+	if err := ValidateTemplateRef(addon); err != nil {
+		return err
+	}
+
+	if err := ValidatePackageVersions(addon.Spec.PackageSpec); err != nil {
+		return err
+	}
+
+	if w := addon.Spec.MaintenanceWindow; w != nil {
+		if err := ValidateMaintenanceWindow(w); err != nil {
+			return err
+		}
+	}
+
+	return ValidateWorkflows(addon)
+}
+
+// ValidatePackageVersions checks the package version and the versions required of the dependencies are semantic
+// versions or constraints, or plain version names that only match themselves. The version of addons rendered from a
+// catalog may be empty.
+func ValidatePackageVersions(pkg addonmgrv1alpha1.PackageSpec) error {
+	if pkg.PkgVersion != "" && !validVersion(pkg.PkgVersion) {
+		return fmt.Errorf("invalid pkgVersion %q in addon.spec.pkgVersion, expected a semantic version", pkg.PkgVersion)
+	}
+	for name, constraint := range pkg.PkgDeps {
+		if strings.TrimSpace(name) == "" {
+			return fmt.Errorf("invalid pkgDeps, dependency with an empty package name")
+		}
+		constraint = strings.TrimSpace(constraint)
+		if constraint == "*" {
+			continue
+		}
+		if _, err := semver.NewConstraint(constraint); err != nil && (constraint == "" || !validVersion(constraint)) {
+			return fmt.Errorf("invalid pkgDeps, version %q of dependency %s is not a version constraint", constraint, name)
+		}
+	}
+	return nil
+}
+
+// validVersion returns true for semantic versions and for version names that are valid label values
+func validVersion(v string) bool {
+	if _, err := semver.NewVersion(v); err == nil {
+		return true
+	}
+	return len(validation.IsValidLabelValue(v)) == 0
+}
+
+// ValidateWorkflows validates the lifecycle workflow templates of the addon are Argo workflows with parameters
+// not overlapping the addon params
+func ValidateWorkflows(addon *addonmgrv1alpha1.Addon) error {
 	var data map[string]interface{}
 
+	// The workflows of helm packages installing a chart are generated from the source
+	if src := addon.Spec.Source; addon.Spec.PkgType == addonmgrv1alpha1.HelmPkg && addon.Spec.Lifecycle.Install.Template == "" &&
+		src != nil && src.Chart != "" && src.RepoURL == "" {
+		return fmt.Errorf("invalid source, chart %s has no repository", src.Chart)
+	}
+
 	workflowTypes := map[addonmgrv1alpha1.LifecycleStep]addonmgrv1alpha1.WorkflowType{
-		addonmgrv1alpha1.Prereqs:  av.addon.Spec.Lifecycle.Prereqs,
-		addonmgrv1alpha1.Install:  av.addon.Spec.Lifecycle.Install,
-		addonmgrv1alpha1.Delete:   av.addon.Spec.Lifecycle.Delete,
-		addonmgrv1alpha1.Validate: av.addon.Spec.Lifecycle.Validate,
+		addonmgrv1alpha1.Prereqs:  addon.Spec.Lifecycle.Prereqs,
+		addonmgrv1alpha1.Install:  addon.Spec.Lifecycle.Install,
+		addonmgrv1alpha1.Delete:   addon.Spec.Lifecycle.Delete,
+		addonmgrv1alpha1.Validate: addon.Spec.Lifecycle.Validate,
+		addonmgrv1alpha1.Upgrade:  addon.Spec.Lifecycle.Upgrade,
 	}
 
 	for key, wt := range workflowTypes {
@@ -151,13 +222,13 @@ func (av *addonValidator) validateWorkflow() error {
 			continue
 		}
 
-		addonParams := av.addon.GetAllAddonParameters()
+		addonParams := addon.GetAllAddonParameters()
 
 		// Ensure there are no parameter naming overlaps
 		for _, wfParam := range wfParameters {
 			wfParamName := wfParam.(map[string]interface{})["name"].(string)
 			if _, in := addonParams[wfParamName]; in {
-				return fmt.Errorf("invalid workflow, parameter named %q found in addon params and in workflow %s", wfParamName, av.addon.GetFormattedWorkflowName(key))
+				return fmt.Errorf("invalid workflow, parameter named %q found in addon params and in workflow %s", wfParamName, addon.GetFormattedWorkflowName(key))
 			}
 		}
 	}
@@ -165,98 +236,89 @@ func (av *addonValidator) validateWorkflow() error {
 	return nil
 }
 
-func (av *addonValidator) validateAddonNameLength() error {
-	if len(av.addon.Name) > 31 {
-		return fmt.Errorf("Addon name %s must be less than 32 characters", av.addon.Name)
-	}
-	return nil
+// resolveDependencies resolves the addon dependencies, transitively, against the cached addon versions
+func (av *addonValidator) resolveDependencies() (*deps.Plan, error) {
+	return ResolveDependencies(av.addon, av.cache, nil)
 }
 
-func (av *addonValidator) validateDependencies() error {
-	// Check addon cache to see that addon pkgName:pkgVersion was installed
-	for pkgName, pkgVersion := range av.addon.Spec.PkgDeps {
-		pkgName = strings.TrimSpace(pkgName)
-		pkgVersion = strings.TrimSpace(pkgVersion)
-
-		if pkgVersion == "*" {
-			// Ignore version
-			versions := av.cache.GetVersions(pkgName)
-			if versions == nil {
-				return fmt.Errorf("required dependency %s is not installed", pkgName)
-			}
-
-			// Look for any successfully installed version
-			var versionFound = false
-			for _, v := range versions {
-				if v.PkgPhase == addonmgrv1alpha1.Succeeded {
-					versionFound = true
-					break
-				}
-			}
-
-			if !versionFound {
-				return fmt.Errorf("required dependency %s has no valid versions installed", pkgName)
-			}
-		} else {
-			// Check for specific version
-			v := av.cache.GetVersion(pkgName, pkgVersion)
-			if v == nil {
-				return fmt.Errorf(ErrDepNotInstalled+": %q:%q", pkgName, pkgVersion)
-			}
+// ResolveDependencies resolves the addon dependencies against the cached addon versions and the catalog,
+// catalog may be nil
+func ResolveDependencies(addon *addonmgrv1alpha1.Addon, cache VersionCacheClient, catalog deps.Catalog) (*deps.Plan, error) {
+	return deps.NewResolver(InstalledPackages(cache), catalog).Resolve(addon.GetPackageSpec())
+}
 
-			switch v.PkgPhase {
-			case addonmgrv1alpha1.Succeeded:
-				return nil
-			case addonmgrv1alpha1.Pending:
-				return fmt.Errorf(ErrDepPending+": %q:%q", pkgName, pkgVersion)
-			default:
-				return fmt.Errorf(ErrDepNotInstalled+": %q:%q", pkgName, pkgVersion)
-			}
+// DependencyStatuses returns the state of each package dependency declared by the addon, in name order
+func DependencyStatuses(addon *addonmgrv1alpha1.Addon, cache VersionCacheClient) []addonmgrv1alpha1.DependencyStatus {
+	installed := InstalledPackages(cache)
+	statuses := make([]addonmgrv1alpha1.DependencyStatus, 0, len(addon.Spec.PkgDeps))
+	for name, constraint := range addon.Spec.PkgDeps {
+		status := addonmgrv1alpha1.DependencyStatus{Name: strings.TrimSpace(name), Required: strings.TrimSpace(constraint)}
+		if pkg, ok := deps.Find(installed, status.Name, status.Required); ok {
+			status.Found = pkg.PkgVersion
+			status.Addon = pkg.Addon
+			status.Installed = pkg.Phase
+			status.Satisfied = pkg.Phase == addonmgrv1alpha1.Succeeded
 		}
+		status.Conflicts = dependencyConflicts(addon, status.Name, status.Required, installed)
+		statuses = append(statuses, status)
 	}
-
-	return nil
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Name < statuses[j].Name })
+	return statuses
 }
 
-func (av *addonValidator) resolveDependencies(n *Version, visited map[string]*Version, depth int) error {
-	if depth >= 256 {
-		panic("Recursive max depth of 256 seen, this is bad!")
+// dependencyConflicts returns the other installed addons requiring versions of the package incompatible with the
+// constraint, in addon order
+func dependencyConflicts(addon *addonmgrv1alpha1.Addon, pkgName, constraint string, installed []deps.Package) []addonmgrv1alpha1.DependencyConflict {
+	var versions []string
+	for _, p := range installed {
+		if p.PkgName == pkgName {
+			versions = append(versions, p.PkgVersion)
+		}
 	}
 
-	// Add to visited
-	name := n.PkgName + ":" + n.PkgVersion
-	visited[name] = n
-
-	for pkgName, pkgVersion := range n.PkgDeps {
-		pkgName = strings.TrimSpace(pkgName)
-		pkgVersion = strings.TrimSpace(pkgVersion)
-
-		if pkgName == n.PkgName {
-			return fmt.Errorf("invalid package dependency, addon cannot depend on it's own package name %s:%s", pkgName, pkgVersion)
+	var conflicts []addonmgrv1alpha1.DependencyConflict
+	for _, p := range installed {
+		if p.Addon == addon.Namespace+"/"+addon.Name {
+			continue
 		}
-
-		v := av.cache.GetVersion(pkgName, pkgVersion)
-		if v == nil {
-			// Unresolvable dependency
-			return fmt.Errorf("unable to resolve required dependency %s:%s", pkgName, pkgVersion)
+		for name, required := range p.PkgDeps {
+			if strings.TrimSpace(name) == pkgName && !deps.Compatible(constraint, required, versions) {
+				conflicts = append(conflicts, addonmgrv1alpha1.DependencyConflict{Addon: p.Addon, Required: strings.TrimSpace(required)})
+			}
 		}
+	}
+	sort.Slice(conflicts, func(i, j int) bool { return conflicts[i].Addon < conflicts[j].Addon })
+	return conflicts
+}
 
-		// Validate it resolves without cyclic dependency
-		dname := v.PkgName + ":" + v.PkgVersion
-		if _, ok := visited[dname]; ok {
-			return fmt.Errorf("circular dependency was found in %s:%s", v.PkgName, v.PkgVersion)
+// InstalledPackages returns the packages of the cached addon versions
+func InstalledPackages(cache VersionCacheClient) []deps.Package {
+	var installed []deps.Package
+	for _, vmap := range cache.GetAllVersions() {
+		for _, v := range vmap {
+			installed = append(installed, deps.Package{
+				PackageSpec: v.PackageSpec,
+				Addon:       v.Namespace + "/" + v.Name,
+				Phase:       v.PkgPhase,
+			})
 		}
+	}
+	return installed
+}
 
-		// Recursive - make sure we catch all cases.
-		err := av.resolveDependencies(v, visited, depth+1)
-		if err != nil {
-			return err
+// validateDependencies checks that the packages of the plan are installed
+func (av *addonValidator) validateDependencies(plan *deps.Plan) error {
+	for _, pkg := range plan.Packages {
+		switch {
+		case !pkg.Installed():
+			return fmt.Errorf(ErrDepNotInstalled+": %q:%q", pkg.PkgName, pkg.PkgVersion)
+		case pkg.Phase == addonmgrv1alpha1.Succeeded:
+		case pkg.Phase == addonmgrv1alpha1.Pending:
+			return fmt.Errorf(ErrDepPending+": %q:%q", pkg.PkgName, pkg.PkgVersion)
+		default:
+			return fmt.Errorf(ErrDepNotInstalled+": %q:%q", pkg.PkgName, pkg.PkgVersion)
 		}
-
 	}
 
-	// Remove visited nodes after
-	delete(visited, name)
-
 	return nil
 }
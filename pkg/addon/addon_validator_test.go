@@ -26,6 +26,7 @@ import (
 	"k8s.io/client-go/dynamic/fake"
 
 	addonmgrv1alpha1 "github.com/keikoproj/addon-manager/api/v1alpha1"
+	"github.com/keikoproj/addon-manager/pkg/deps"
 )
 
 var dynClient = fake.NewSimpleDynamicClient(runtime.NewScheme())
@@ -579,26 +580,32 @@ func Test_addonValidator_Validate_With_Installed_Deps(t *testing.T) {
 }
 
 func Test_addonValidator_validateDependencies(t *testing.T) {
-	var cache = NewAddonVersionCacheClient()
-	type fields struct {
-		addon *addonmgrv1alpha1.Addon
-	}
 	tests := []struct {
-		name    string
-		fields  fields
-		wantErr bool
+		name          string
+		plan          *deps.Plan
+		errStartsWith string
 	}{
-		// TODO: Add test cases.
+		{name: "no-dependencies", plan: &deps.Plan{}},
+		{name: "succeeded-dependencies", plan: &deps.Plan{Packages: []deps.Package{
+			{PackageSpec: addonmgrv1alpha1.PackageSpec{PkgName: "core/A", PkgVersion: "1.0.0"}, Addon: "default/a", Phase: addonmgrv1alpha1.Succeeded},
+		}}},
+		{name: "pending-dependency", plan: &deps.Plan{Packages: []deps.Package{
+			{PackageSpec: addonmgrv1alpha1.PackageSpec{PkgName: "core/A", PkgVersion: "1.0.0"}, Addon: "default/a", Phase: addonmgrv1alpha1.Succeeded},
+			{PackageSpec: addonmgrv1alpha1.PackageSpec{PkgName: "core/B", PkgVersion: "1.0.0"}, Addon: "default/b", Phase: addonmgrv1alpha1.Pending},
+		}}, errStartsWith: ErrDepPending},
+		{name: "catalog-dependency", plan: &deps.Plan{Packages: []deps.Package{
+			{PackageSpec: addonmgrv1alpha1.PackageSpec{PkgName: "core/A", PkgVersion: "1.0.0"}},
+		}}, errStartsWith: ErrDepNotInstalled},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			av := &addonValidator{
-				addon:     tt.fields.addon,
-				cache:     cache,
-				dynClient: dynClient,
+			av := &addonValidator{cache: NewAddonVersionCacheClient(), dynClient: dynClient}
+			err := av.validateDependencies(tt.plan)
+			if tt.errStartsWith == "" && err != nil {
+				t.Errorf("addonValidator.validateDependencies() error = %v", err)
 			}
-			if err := av.validateDependencies(); (err != nil) != tt.wantErr {
-				t.Errorf("addonValidator.validateDependencies() error = %v, wantErr %v", err, tt.wantErr)
+			if tt.errStartsWith != "" && (err == nil || !strings.HasPrefix(err.Error(), tt.errStartsWith)) {
+				t.Errorf("addonValidator.validateDependencies() error = %v, want prefix %q", err, tt.errStartsWith)
 			}
 		})
 	}
@@ -663,12 +670,97 @@ func Test_resolveDependencies(t *testing.T) {
 		dynClient: dynClient,
 	}
 
-	var visited = make(map[string]*Version)
+	plan, err := av.resolveDependencies()
+	g.Expect(err).Should(gomega.BeNil(), "Should validate")
+	g.Expect(plan.Packages).To(gomega.HaveLen(3))
+	g.Expect(plan.Packages[0].PkgName).To(gomega.Equal("core/C"))
+}
 
-	g.Expect(av.resolveDependencies(&Version{
-		PackageSpec: av.addon.GetPackageSpec(),
+func TestDependencyStatuses(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	cached := NewAddonVersionCacheClient()
+	cached.AddVersion(Version{
+		Name:        "cert-manager",
+		Namespace:   "addon-manager-system",
+		PackageSpec: addonmgrv1alpha1.PackageSpec{PkgName: "core/cert-manager", PkgVersion: "1.0.4"},
+		PkgPhase:    addonmgrv1alpha1.Succeeded,
+	})
+	cached.AddVersion(Version{
+		Name:        "ingress",
+		Namespace:   "addon-manager-system",
+		PackageSpec: addonmgrv1alpha1.PackageSpec{PkgName: "core/ingress", PkgVersion: "2.0.0"},
 		PkgPhase:    addonmgrv1alpha1.Pending,
-	}, visited, 0)).Should(gomega.BeNil(), "Should validate")
+	})
+
+	a := &addonmgrv1alpha1.Addon{
+		Spec: addonmgrv1alpha1.AddonSpec{
+			PackageSpec: addonmgrv1alpha1.PackageSpec{
+				PkgName:    "test/addon-1",
+				PkgVersion: "1.0.0",
+				PkgDeps: map[string]string{
+					"core/ingress":      "*",
+					"core/cert-manager": "~1.0",
+					"core/metrics":      ">=1.0.0",
+				},
+			},
+		},
+	}
+
+	g.Expect(DependencyStatuses(a, cached)).To(gomega.Equal([]addonmgrv1alpha1.DependencyStatus{
+		{Name: "core/cert-manager", Required: "~1.0", Found: "1.0.4", Addon: "addon-manager-system/cert-manager", Installed: addonmgrv1alpha1.Succeeded, Satisfied: true},
+		{Name: "core/ingress", Required: "*", Found: "2.0.0", Addon: "addon-manager-system/ingress", Installed: addonmgrv1alpha1.Pending},
+		{Name: "core/metrics", Required: ">=1.0.0"},
+	}))
+}
+
+func TestDependencyStatuses_Conflicts(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	cached := NewAddonVersionCacheClient()
+	cached.AddVersion(Version{
+		Name:        "cert-manager",
+		Namespace:   "addon-manager-system",
+		PackageSpec: addonmgrv1alpha1.PackageSpec{PkgName: "core/cert-manager", PkgVersion: "1.0.4"},
+		PkgPhase:    addonmgrv1alpha1.Succeeded,
+	})
+	cached.AddVersion(Version{
+		Name:      "legacy-webhook",
+		Namespace: "addon-manager-system",
+		PackageSpec: addonmgrv1alpha1.PackageSpec{
+			PkgName:    "core/legacy-webhook",
+			PkgVersion: "0.3.0",
+			PkgDeps:    map[string]string{"core/cert-manager": "~0.9"},
+		},
+		PkgPhase: addonmgrv1alpha1.Succeeded,
+	})
+	cached.AddVersion(Version{
+		Name:      "webhook",
+		Namespace: "addon-manager-system",
+		PackageSpec: addonmgrv1alpha1.PackageSpec{
+			PkgName:    "core/webhook",
+			PkgVersion: "1.2.0",
+			PkgDeps:    map[string]string{"core/cert-manager": ">=1.0.0"},
+		},
+		PkgPhase: addonmgrv1alpha1.Succeeded,
+	})
+
+	a := &addonmgrv1alpha1.Addon{
+		ObjectMeta: metav1.ObjectMeta{Name: "addon-1", Namespace: "addon-manager-system"},
+		Spec: addonmgrv1alpha1.AddonSpec{
+			PackageSpec: addonmgrv1alpha1.PackageSpec{
+				PkgName:    "test/addon-1",
+				PkgVersion: "1.0.0",
+				PkgDeps:    map[string]string{"core/cert-manager": "~1.0"},
+			},
+		},
+	}
+
+	statuses := DependencyStatuses(a, cached)
+	g.Expect(statuses).To(gomega.HaveLen(1))
+	g.Expect(statuses[0].Conflicts).To(gomega.Equal([]addonmgrv1alpha1.DependencyConflict{
+		{Addon: "addon-manager-system/legacy-webhook", Required: "~0.9"},
+	}))
 }
 
 func Test_resolveDependencies_Fail(t *testing.T) {
@@ -734,12 +826,8 @@ func Test_resolveDependencies_Fail(t *testing.T) {
 		dynClient: dynClient,
 	}
 
-	var visited = make(map[string]*Version)
-
-	g.Expect(av.resolveDependencies(&Version{
-		PackageSpec: av.addon.GetPackageSpec(),
-		PkgPhase:    addonmgrv1alpha1.Pending,
-	}, visited, 0)).ShouldNot(gomega.Succeed(), "Should not validate")
+	_, err := av.resolveDependencies()
+	g.Expect(err).Should(gomega.MatchError(gomega.ContainSubstring("circular dependency")), "Should not validate")
 }
 
 func Test_validateDuplicate_Fail(t *testing.T) {
@@ -821,4 +909,74 @@ func Test_validateDuplicate_Fail(t *testing.T) {
 
 	g.Expect(err).Should(gomega.HaveOccurred(), "Should not validate")
 	g.Expect(err).Should(gomega.MatchError(errMsg))
+
+	// The same package version can be installed in a remote cluster
+	av.addon.Spec.Target = &addonmgrv1alpha1.AddonTarget{ClusterRef: &addonmgrv1alpha1.ClusterReference{Name: "edge-kubeconfig"}}
+	err = av.validateDuplicate(&Version{
+		Name:        av.addon.Name,
+		Namespace:   av.addon.Namespace,
+		PackageSpec: av.addon.GetPackageSpec(),
+		PkgPhase:    addonmgrv1alpha1.Pending,
+	})
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+}
+
+func TestValidate_ClaimsValidAddonsOnly(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	cached := NewAddonVersionCacheClient()
+	a := &addonmgrv1alpha1.Addon{
+		ObjectMeta: metav1.ObjectMeta{Name: "claim-test", Namespace: "default"},
+		Spec: addonmgrv1alpha1.AddonSpec{
+			PackageSpec: addonmgrv1alpha1.PackageSpec{
+				PkgType:    addonmgrv1alpha1.CompositePkg,
+				PkgName:    "test/claim",
+				PkgVersion: "1.0.0",
+			},
+		},
+	}
+
+	// The namespace param is missing, the version is not claimed
+	ok, err := NewAddonValidator(a, cached, dynClient).Validate()
+	g.Expect(ok).To(gomega.BeFalse())
+	g.Expect(err).To(gomega.HaveOccurred())
+	g.Expect(cached.GetVersion("test/claim", "1.0.0")).To(gomega.BeNil())
+
+	a.Spec.Params.Namespace = "addon-test-ns"
+	ok, err = NewAddonValidator(a, cached, dynClient).Validate()
+	g.Expect(err).ToNot(gomega.HaveOccurred())
+	g.Expect(ok).To(gomega.BeTrue())
+	g.Expect(cached.GetVersion("test/claim", "1.0.0")).ToNot(gomega.BeNil())
+}
+
+func TestValidateWorkflows_HelmChart(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	a := &addonmgrv1alpha1.Addon{
+		Spec: addonmgrv1alpha1.AddonSpec{
+			PackageSpec: addonmgrv1alpha1.PackageSpec{PkgName: "metrics-server", PkgVersion: "2.11.4", PkgType: addonmgrv1alpha1.HelmPkg},
+			Source:      &addonmgrv1alpha1.AddonSource{Chart: "metrics-server"},
+		},
+	}
+	g.Expect(ValidateWorkflows(a)).To(gomega.HaveOccurred())
+
+	a.Spec.Source.RepoURL = "https://charts.helm.sh/stable"
+	g.Expect(ValidateWorkflows(a)).To(gomega.Succeed())
+}
+
+func TestValidatePackageVersions(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	pkg := addonmgrv1alpha1.PackageSpec{PkgName: "my/addon", PkgVersion: "v1.2.0", PkgDeps: map[string]string{"core/a": "^1.0.0", "core/b": "*", "core/c": "nightly"}}
+	g.Expect(ValidatePackageVersions(pkg)).To(gomega.Succeed())
+
+	// Addons rendered from a catalog have no version yet
+	g.Expect(ValidatePackageVersions(addonmgrv1alpha1.PackageSpec{PkgName: "my/addon"})).To(gomega.Succeed())
+
+	pkg.PkgVersion = "1.0 beta"
+	g.Expect(ValidatePackageVersions(pkg)).To(gomega.MatchError(gomega.ContainSubstring("invalid pkgVersion")))
+
+	pkg.PkgVersion = "1.0.0"
+	pkg.PkgDeps["core/d"] = ""
+	g.Expect(ValidatePackageVersions(pkg)).To(gomega.MatchError(gomega.ContainSubstring("dependency core/d")))
 }
@@ -23,6 +23,7 @@ import (
 // VersionCacheClient interface clients must implement for addon version cache.
 type VersionCacheClient interface {
 	AddVersion(Version)
+	ClaimVersion(Version) *Version
 	GetVersions(pkgName string) map[string]Version
 	GetVersion(pkgName, pkgVersion string) *Version
 	HasVersionName(name string) (bool, *Version)
@@ -63,6 +64,30 @@ func (c *cached) AddVersion(v Version) {
 	c.addons[v.PkgName][v.PkgVersion] = v
 }
 
+// ClaimVersion adds the version unless the package version is cached for an addon with another name, that version
+// is returned instead. Checking and adding under one lock keeps addons reconciled in parallel from both installing
+// the same package version.
+func (c *cached) ClaimVersion(v Version) *Version {
+	c.Lock()
+	defer c.Unlock()
+
+	if m, ok := c.addons[v.PkgName]; ok {
+		existing, ok := m[v.PkgVersion]
+		if !ok {
+			if resolved := c.resolveVersion(m, v.PkgVersion); resolved != nil {
+				existing, ok = *resolved, true
+			}
+		}
+		if ok && existing.Name != v.Name {
+			return &existing
+		}
+	} else {
+		c.addons[v.PkgName] = make(map[string]Version)
+	}
+	c.addons[v.PkgName][v.PkgVersion] = v
+	return nil
+}
+
 func (c *cached) GetVersions(pkgName string) map[string]Version {
 	c.RLock()
 	defer c.RUnlock()
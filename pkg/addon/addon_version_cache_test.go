@@ -15,7 +15,10 @@
 package addon
 
 import (
+	"fmt"
 	"reflect"
+	"sync"
+	"sync/atomic"
 	"testing"
 
 	addonmgrv1alpha1 "github.com/keikoproj/addon-manager/api/v1alpha1"
@@ -76,6 +79,36 @@ func Test_cached_AddVersion(t *testing.T) {
 	}
 }
 
+func Test_cached_ClaimVersion(t *testing.T) {
+	c := NewAddonVersionCacheClient()
+	spec := addonmgrv1alpha1.PackageSpec{PkgName: "test/A", PkgVersion: "1.0.0"}
+
+	// Addons reconciled in parallel claim the package version once
+	var wg sync.WaitGroup
+	var claimed int32
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if c.ClaimVersion(Version{Name: fmt.Sprintf("addon-%d", i), PackageSpec: spec}) == nil {
+				atomic.AddInt32(&claimed, 1)
+			}
+		}(i)
+	}
+	wg.Wait()
+	if claimed != 1 {
+		t.Errorf("ClaimVersion() claimed %d times, want 1", claimed)
+	}
+
+	owner := c.GetVersion("test/A", "1.0.0")
+	if got := c.ClaimVersion(Version{Name: owner.Name, PackageSpec: spec, PkgPhase: addonmgrv1alpha1.Succeeded}); got != nil {
+		t.Errorf("ClaimVersion() = %v, want the owner to claim its version again", got)
+	}
+	if got := c.ClaimVersion(Version{Name: "other", PackageSpec: addonmgrv1alpha1.PackageSpec{PkgName: "test/A", PkgVersion: "^1.0.0"}}); got == nil || got.Name != owner.Name {
+		t.Errorf("ClaimVersion() = %v, want %s for a matching constraint", got, owner.Name)
+	}
+}
+
 func Test_cached_GetVersions(t *testing.T) {
 	a := make(map[string]map[string]Version)
 	a["test/addon-1"] = map[string]Version{
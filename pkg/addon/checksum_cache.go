@@ -0,0 +1,89 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package addon
+
+import (
+	"sync"
+
+	"k8s.io/apimachinery/pkg/types"
+
+	addonmgrv1alpha1 "github.com/keikoproj/addon-manager/api/v1alpha1"
+)
+
+type checksumEntry struct {
+	uid        types.UID
+	generation int64
+	checksum   string
+}
+
+// ChecksumCache caches the spec checksums of addons by generation. The API server bumps the generation on every
+// spec change, so serializing and hashing large embedded templates once per generation is enough.
+type ChecksumCache struct {
+	sync.RWMutex
+	checksums map[types.NamespacedName]checksumEntry
+}
+
+// NewChecksumCache returns an empty ChecksumCache
+func NewChecksumCache() *ChecksumCache {
+	return &ChecksumCache{
+		checksums: make(map[types.NamespacedName]checksumEntry),
+	}
+}
+
+// Checksum returns the status checksum of an addon as read from the API server, the spec checksum combined with
+// the source digest of the status. Addons never saved are hashed on every call. Specs modified in memory without
+// being saved must use CalculateStatusChecksum instead, specs completed from an AddonTemplate are hashed on every
+// call since template changes do not change the generation of the addon.
+func (c *ChecksumCache) Checksum(a *addonmgrv1alpha1.Addon) string {
+	if a.Status.Template != nil {
+		return a.CalculateStatusChecksum()
+	}
+	return addonmgrv1alpha1.CombineChecksum(c.specChecksum(a), a.Status.SourceDigest)
+}
+
+func (c *ChecksumCache) specChecksum(a *addonmgrv1alpha1.Addon) string {
+	uid, generation := a.GetUID(), a.GetGeneration()
+	if uid == "" || generation == 0 {
+		return a.CalculateChecksum()
+	}
+	key := types.NamespacedName{Namespace: a.GetNamespace(), Name: a.GetName()}
+
+	c.RLock()
+	e, ok := c.checksums[key]
+	c.RUnlock()
+	if ok && e.uid == uid && e.generation == generation {
+		return e.checksum
+	}
+
+	checksum := a.CalculateChecksum()
+	c.Lock()
+	defer c.Unlock()
+	c.checksums[key] = checksumEntry{uid: uid, generation: generation, checksum: checksum}
+	return checksum
+}
+
+// Remove drops the checksum of a deleted addon
+func (c *ChecksumCache) Remove(key types.NamespacedName) {
+	c.Lock()
+	defer c.Unlock()
+	delete(c.checksums, key)
+}
+
+// Len returns the number of cached checksums
+func (c *ChecksumCache) Len() int {
+	c.RLock()
+	defer c.RUnlock()
+	return len(c.checksums)
+}
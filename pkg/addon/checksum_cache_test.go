@@ -0,0 +1,109 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package addon
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	addonmgrv1alpha1 "github.com/keikoproj/addon-manager/api/v1alpha1"
+)
+
+func TestChecksumCache(t *testing.T) {
+	g := NewGomegaWithT(t)
+	c := NewChecksumCache()
+
+	a := &addonmgrv1alpha1.Addon{
+		ObjectMeta: metav1.ObjectMeta{Name: "cached", Namespace: "default", UID: "uid-1", Generation: 1},
+		Spec: addonmgrv1alpha1.AddonSpec{
+			PackageSpec: addonmgrv1alpha1.PackageSpec{PkgName: "cached", PkgVersion: "1.0.0"},
+		},
+	}
+	checksum := a.CalculateChecksum()
+	g.Expect(c.Checksum(a)).To(Equal(checksum))
+	g.Expect(c.Len()).To(Equal(1))
+
+	// The checksum of a generation is not calculated again
+	c.checksums[types.NamespacedName{Namespace: "default", Name: "cached"}] = checksumEntry{uid: "uid-1", generation: 1, checksum: "memo"}
+	g.Expect(c.Checksum(a)).To(Equal("memo"))
+
+	// New generations are hashed
+	a.Spec.PkgVersion = "1.0.1"
+	a.Generation = 2
+	g.Expect(c.Checksum(a)).To(Equal(a.CalculateChecksum()))
+	g.Expect(c.Checksum(a)).ToNot(Equal(checksum))
+
+	// Recreated addons are hashed
+	a.UID = "uid-2"
+	a.Generation = 1
+	g.Expect(c.Checksum(a)).To(Equal(a.CalculateChecksum()))
+
+	// Changes of external sources change the checksum of the same generation
+	specChecksum := c.Checksum(a)
+	a.Status.SourceDigest = addonmgrv1alpha1.SourceDigest("params: v1")
+	g.Expect(c.Checksum(a)).ToNot(Equal(specChecksum))
+	g.Expect(c.Checksum(a)).To(Equal(a.CalculateStatusChecksum()))
+	sourceChecksum := c.Checksum(a)
+	a.Status.SourceDigest = addonmgrv1alpha1.SourceDigest("params: v2")
+	g.Expect(c.Checksum(a)).ToNot(Equal(sourceChecksum))
+	a.Status.SourceDigest = addonmgrv1alpha1.SourceDigest("")
+	g.Expect(c.Checksum(a)).To(Equal(specChecksum))
+
+	// Addons never saved are not cached
+	unsaved := &addonmgrv1alpha1.Addon{ObjectMeta: metav1.ObjectMeta{Name: "unsaved", Namespace: "default"}}
+	g.Expect(c.Checksum(unsaved)).To(Equal(unsaved.CalculateChecksum()))
+	g.Expect(c.Len()).To(Equal(1))
+
+	c.Remove(types.NamespacedName{Namespace: "default", Name: "cached"})
+	g.Expect(c.Len()).To(Equal(0))
+}
+
+func TestChecksumCache_Template(t *testing.T) {
+	g := NewGomegaWithT(t)
+	c := NewChecksumCache()
+
+	saved := &addonmgrv1alpha1.Addon{
+		ObjectMeta: metav1.ObjectMeta{Name: "templated", Namespace: "default", UID: "uid-1", Generation: 1},
+		Spec: addonmgrv1alpha1.AddonSpec{
+			PackageSpec: addonmgrv1alpha1.PackageSpec{PkgName: "templated", PkgVersion: "1.0.0"},
+			TemplateRef: "helm-chart",
+		},
+	}
+	tmpl := &addonmgrv1alpha1.AddonTemplate{
+		ObjectMeta: metav1.ObjectMeta{Name: "helm-chart", Namespace: "default"},
+		Spec: addonmgrv1alpha1.AddonTemplateSpec{
+			Lifecycle: addonmgrv1alpha1.LifecycleWorkflowSpec{Install: addonmgrv1alpha1.WorkflowType{Template: "kind: Workflow\n# v1"}},
+		},
+	}
+	apply := func() *addonmgrv1alpha1.Addon {
+		a := saved.DeepCopy()
+		a.Status.Template = &addonmgrv1alpha1.TemplateStatus{Name: tmpl.Name, Defaults: ApplyTemplate(a, tmpl)}
+		return a
+	}
+
+	a := apply()
+	checksum := c.Checksum(a)
+	g.Expect(checksum).To(Equal(a.CalculateStatusChecksum()))
+	workflow := a.WorkflowName(addonmgrv1alpha1.Install, checksum)
+
+	// Editing the template changes the checksum and workflow names of the same generation
+	tmpl.Spec.Lifecycle.Install.Template = "kind: Workflow\n# v2"
+	a = apply()
+	g.Expect(c.Checksum(a)).ToNot(Equal(checksum))
+	g.Expect(a.WorkflowName(addonmgrv1alpha1.Install, c.Checksum(a))).ToNot(Equal(workflow))
+}
@@ -0,0 +1,92 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package addon
+
+import (
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/clock"
+)
+
+type pendingSpec struct {
+	checksum string
+	since    time.Time
+}
+
+// Debouncer holds back the lifecycle of edited addon specs until the spec stopped changing for a window, so rapid
+// edits, e.g. by a GitOps apply loop, only run the workflows of the final spec. Requeued addons are deduplicated by
+// the workqueue, one reconcile runs once the window passed.
+type Debouncer struct {
+	sync.Mutex
+	window  time.Duration
+	pending map[types.NamespacedName]pendingSpec
+	clock   clock.PassiveClock
+}
+
+// NewDebouncer returns a Debouncer waiting window after the last spec change, a zero window disables it
+func NewDebouncer(window time.Duration) *Debouncer {
+	return &Debouncer{
+		window:  window,
+		pending: make(map[types.NamespacedName]pendingSpec),
+		clock:   clock.RealClock{},
+	}
+}
+
+// SetClock sets the clock the window is measured with
+func (d *Debouncer) SetClock(c clock.PassiveClock) {
+	if d == nil {
+		return
+	}
+	d.Lock()
+	defer d.Unlock()
+	d.clock = c
+}
+
+// Wait returns how long the lifecycle of the spec with checksum must still wait, changed is true when the
+// checksum differs from the one of the previous reconcile. Specs that are not waited on return 0.
+func (d *Debouncer) Wait(key types.NamespacedName, checksum string, changed bool) time.Duration {
+	if d == nil || d.window <= 0 {
+		return 0
+	}
+	d.Lock()
+	defer d.Unlock()
+
+	now := d.clock.Now()
+	p, ok := d.pending[key]
+	if !ok && !changed {
+		return 0
+	}
+	if !ok || p.checksum != checksum {
+		d.pending[key] = pendingSpec{checksum: checksum, since: now}
+		return d.window
+	}
+	if remaining := d.window - now.Sub(p.since); remaining > 0 {
+		return remaining
+	}
+	delete(d.pending, key)
+	return 0
+}
+
+// Forget drops the pending spec of a deleted addon
+func (d *Debouncer) Forget(key types.NamespacedName) {
+	if d == nil {
+		return
+	}
+	d.Lock()
+	defer d.Unlock()
+	delete(d.pending, key)
+}
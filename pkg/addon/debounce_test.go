@@ -0,0 +1,55 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package addon
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/clock"
+)
+
+func TestDebouncer_Wait(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	c := clock.NewFakeClock(time.Now())
+	d := NewDebouncer(10 * time.Second)
+	d.SetClock(c)
+	key := types.NamespacedName{Namespace: "default", Name: "edited"}
+
+	// Unchanged specs are not held back
+	g.Expect(d.Wait(key, "a", false)).To(BeZero())
+
+	g.Expect(d.Wait(key, "b", true)).To(Equal(10 * time.Second))
+	c.Step(4 * time.Second)
+	g.Expect(d.Wait(key, "b", false)).To(Equal(6 * time.Second))
+
+	// Another edit restarts the window
+	g.Expect(d.Wait(key, "c", true)).To(Equal(10 * time.Second))
+	c.Step(10 * time.Second)
+	g.Expect(d.Wait(key, "c", false)).To(BeZero())
+	g.Expect(d.pending).To(BeEmpty())
+
+	g.Expect(d.Wait(key, "d", true)).To(Equal(10 * time.Second))
+	d.Forget(key)
+	g.Expect(d.pending).To(BeEmpty())
+
+	// Disabled debouncers never wait
+	var disabled *Debouncer
+	g.Expect(disabled.Wait(key, "e", true)).To(BeZero())
+	g.Expect(NewDebouncer(0).Wait(key, "e", true)).To(BeZero())
+}
@@ -0,0 +1,68 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package addon
+
+import (
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	addonmgrv1alpha1 "github.com/keikoproj/addon-manager/api/v1alpha1"
+)
+
+// FailureBudget is the number of times the install of a spec may fail within a window before its circuit opens and
+// its workflows are no longer submitted. Retries of a failed install back off exponentially from Backoff.
+type FailureBudget struct {
+	// Limit is the number of failures that opens the circuit, 0 disables the budget
+	Limit int
+	// Window failures are counted in, counting starts over with the first failure after the window
+	Window time.Duration
+	// Backoff is the wait before the first retry, it doubles with every failure up to the window
+	Backoff time.Duration
+}
+
+// Enabled returns true when the failures of addons are counted
+func (b FailureBudget) Enabled() bool {
+	return b.Limit > 0
+}
+
+// RecordFailure counts a failed install of the spec checksum at now, it returns the updated status and true when
+// the budget is exhausted
+func (b FailureBudget) RecordFailure(s *addonmgrv1alpha1.FailureBudgetStatus, checksum string, now time.Time) (*addonmgrv1alpha1.FailureBudgetStatus, bool) {
+	if s == nil || s.Checksum != checksum || (b.Window > 0 && now.Sub(s.WindowStart.Time) > b.Window) {
+		s = &addonmgrv1alpha1.FailureBudgetStatus{Checksum: checksum, WindowStart: metav1.NewTime(now)}
+	}
+	s.Failures++
+	s.LastFailure = metav1.NewTime(now)
+	return s, b.Enabled() && s.Failures >= b.Limit
+}
+
+// RetryAfter returns how long the next install of a failed spec waits, 0 when it may be submitted now
+func (b FailureBudget) RetryAfter(s *addonmgrv1alpha1.FailureBudgetStatus, now time.Time) time.Duration {
+	if !b.Enabled() || s == nil || s.Failures == 0 || b.Backoff <= 0 {
+		return 0
+	}
+	backoff := b.Backoff
+	for i := 1; i < s.Failures && (b.Window <= 0 || backoff < b.Window); i++ {
+		backoff *= 2
+	}
+	if b.Window > 0 && backoff > b.Window {
+		backoff = b.Window
+	}
+	if wait := s.LastFailure.Add(backoff).Sub(now); wait > 0 {
+		return wait
+	}
+	return 0
+}
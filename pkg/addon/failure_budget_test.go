@@ -0,0 +1,81 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package addon
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestFailureBudget_RecordFailure(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	b := FailureBudget{Limit: 3, Window: time.Hour, Backoff: time.Minute}
+	now := time.Date(2020, 6, 1, 10, 0, 0, 0, time.UTC)
+
+	s, open := b.RecordFailure(nil, "abc", now)
+	g.Expect(open).To(BeFalse())
+	g.Expect(s.Failures).To(Equal(1))
+	s, open = b.RecordFailure(s, "abc", now.Add(10*time.Minute))
+	g.Expect(open).To(BeFalse())
+	s, open = b.RecordFailure(s, "abc", now.Add(20*time.Minute))
+	g.Expect(open).To(BeTrue())
+	g.Expect(s.Failures).To(Equal(3))
+	g.Expect(s.WindowStart.Time).To(Equal(now))
+
+	// Failures after the window start counting over
+	s, open = b.RecordFailure(s, "abc", now.Add(2*time.Hour))
+	g.Expect(open).To(BeFalse())
+	g.Expect(s.Failures).To(Equal(1))
+	g.Expect(s.WindowStart.Time).To(Equal(now.Add(2 * time.Hour)))
+
+	// A new spec starts with the full budget
+	s, _ = b.RecordFailure(s, "abc", now.Add(2*time.Hour+time.Minute))
+	s, open = b.RecordFailure(s, "def", now.Add(2*time.Hour+2*time.Minute))
+	g.Expect(open).To(BeFalse())
+	g.Expect(s.Failures).To(Equal(1))
+	g.Expect(s.Checksum).To(Equal("def"))
+
+	// Disabled budgets count failures without opening the circuit
+	_, open = FailureBudget{}.RecordFailure(s, "def", now)
+	g.Expect(open).To(BeFalse())
+}
+
+func TestFailureBudget_RetryAfter(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	b := FailureBudget{Limit: 10, Window: 5 * time.Minute, Backoff: time.Minute}
+	now := time.Date(2020, 6, 1, 10, 0, 0, 0, time.UTC)
+
+	g.Expect(b.RetryAfter(nil, now)).To(BeZero())
+
+	s, _ := b.RecordFailure(nil, "abc", now)
+	g.Expect(b.RetryAfter(s, now)).To(Equal(time.Minute))
+	g.Expect(b.RetryAfter(s, now.Add(45*time.Second))).To(Equal(15 * time.Second))
+	g.Expect(b.RetryAfter(s, now.Add(time.Minute))).To(BeZero())
+
+	s, _ = b.RecordFailure(s, "abc", now)
+	g.Expect(b.RetryAfter(s, now)).To(Equal(2 * time.Minute))
+	s, _ = b.RecordFailure(s, "abc", now)
+	g.Expect(b.RetryAfter(s, now)).To(Equal(4 * time.Minute))
+
+	// The backoff is capped at the window
+	s, _ = b.RecordFailure(s, "abc", now)
+	g.Expect(b.RetryAfter(s, now)).To(Equal(5 * time.Minute))
+
+	g.Expect(FailureBudget{}.RetryAfter(s, now)).To(BeZero())
+}
@@ -0,0 +1,59 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package addon
+
+import (
+	"fmt"
+
+	"github.com/Masterminds/semver/v3"
+)
+
+// KubeVersionPolicy is how installs of packages that do not support the Kubernetes version of the cluster are handled
+type KubeVersionPolicy string
+
+const (
+	// EnforceKubeVersion fails installs outside the supported range
+	EnforceKubeVersion KubeVersionPolicy = "enforce"
+	// WarnKubeVersion records a warning event and installs the addon
+	WarnKubeVersion KubeVersionPolicy = "warn"
+)
+
+// ParseKubeVersionPolicy returns the KubeVersionPolicy named s
+func ParseKubeVersionPolicy(s string) (KubeVersionPolicy, error) {
+	switch p := KubeVersionPolicy(s); p {
+	case EnforceKubeVersion, WarnKubeVersion:
+		return p, nil
+	}
+	return "", fmt.Errorf("unknown kube version policy %q, must be one of enforce, warn", s)
+}
+
+// CheckKubeVersion returns an error when the Kubernetes version is not in the supported range, pre-release and build
+// suffixes of provider versions such as v1.19.8-eks-96780e are ignored
+func CheckKubeVersion(supported, kubeVersion string) error {
+	c, err := semver.NewConstraint(supported)
+	if err != nil {
+		return fmt.Errorf("invalid kube version range %q. %v", supported, err)
+	}
+	v, err := semver.NewVersion(kubeVersion)
+	if err != nil {
+		return fmt.Errorf("invalid cluster version %q. %v", kubeVersion, err)
+	}
+	release, _ := v.SetPrerelease("")
+	release, _ = release.SetMetadata("")
+	if !c.Check(&release) {
+		return fmt.Errorf("kubernetes %s is not in the supported range %s", kubeVersion, supported)
+	}
+	return nil
+}
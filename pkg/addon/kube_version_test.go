@@ -0,0 +1,41 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package addon
+
+import (
+	"testing"
+
+	"github.com/onsi/gomega"
+)
+
+func TestCheckKubeVersion(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	g.Expect(CheckKubeVersion(">=1.18 <1.22", "v1.19.8-eks-96780e")).To(gomega.Succeed())
+	g.Expect(CheckKubeVersion(">=1.18 <1.22", "v1.21.0+k3s1")).To(gomega.Succeed())
+	g.Expect(CheckKubeVersion(">=1.18 <1.22", "v1.22.1")).To(gomega.MatchError(gomega.ContainSubstring("not in the supported range")))
+	g.Expect(CheckKubeVersion("not a range", "v1.20.0")).To(gomega.MatchError(gomega.ContainSubstring("invalid kube version range")))
+	g.Expect(CheckKubeVersion(">=1.18", "unknown")).To(gomega.MatchError(gomega.ContainSubstring("invalid cluster version")))
+}
+
+func TestParseKubeVersionPolicy(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	p, err := ParseKubeVersionPolicy("warn")
+	g.Expect(err).ToNot(gomega.HaveOccurred())
+	g.Expect(p).To(gomega.Equal(WarnKubeVersion))
+	_, err = ParseKubeVersionPolicy("ignore")
+	g.Expect(err).To(gomega.HaveOccurred())
+}
@@ -0,0 +1,196 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package addon
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	addonmgrv1alpha1 "github.com/keikoproj/addon-manager/api/v1alpha1"
+)
+
+// maxScheduleSearch bounds the search for the next start of a schedule that never matches, e.g. February 30
+const maxScheduleSearch = 5 * 366 * 24 * time.Hour
+
+// cronField is the range of a cron expression field
+type cronField struct {
+	name     string
+	min, max int
+}
+
+var cronFields = []cronField{
+	{"minute", 0, 59},
+	{"hour", 0, 23},
+	{"day of month", 1, 31},
+	{"month", 1, 12},
+	{"day of week", 0, 7},
+}
+
+// schedule is a parsed cron expression, fields are bit sets of the matching values
+type schedule struct {
+	minute, hour, dom, month, dow uint64
+	// Restricted day of month and day of week fields match when either matches
+	domStar, dowStar bool
+}
+
+// parseSchedule parses a five field cron expression supporting *, lists, ranges and steps, day of week 7 is Sunday
+func parseSchedule(expr string) (*schedule, error) {
+	fields := strings.Fields(expr)
+	if len(fields) != len(cronFields) {
+		return nil, fmt.Errorf("invalid schedule %q, expected %d fields", expr, len(cronFields))
+	}
+	var bits [5]uint64
+	for i, f := range fields {
+		b, err := parseCronField(f, cronFields[i])
+		if err != nil {
+			return nil, fmt.Errorf("invalid schedule %q. %v", expr, err)
+		}
+		bits[i] = b
+	}
+	// Sunday is 0 and 7
+	if bits[4]&(1<<7) != 0 {
+		bits[4] |= 1
+	}
+	return &schedule{
+		minute: bits[0], hour: bits[1], dom: bits[2], month: bits[3], dow: bits[4],
+		domStar: fields[2] == "*", dowStar: fields[4] == "*",
+	}, nil
+}
+
+func parseCronField(s string, f cronField) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(s, ",") {
+		rng, step := part, 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			n, err := strconv.Atoi(part[i+1:])
+			if err != nil || n < 1 {
+				return 0, fmt.Errorf("invalid %s step %q", f.name, part)
+			}
+			rng, step = part[:i], n
+		}
+		lo, hi := f.min, f.max
+		if rng != "*" {
+			bounds := strings.SplitN(rng, "-", 2)
+			var err error
+			if lo, err = strconv.Atoi(bounds[0]); err != nil {
+				return 0, fmt.Errorf("invalid %s %q", f.name, part)
+			}
+			hi = lo
+			if len(bounds) == 2 {
+				if hi, err = strconv.Atoi(bounds[1]); err != nil {
+					return 0, fmt.Errorf("invalid %s %q", f.name, part)
+				}
+			} else if step > 1 {
+				hi = f.max
+			}
+		}
+		if lo < f.min || hi > f.max || lo > hi {
+			return 0, fmt.Errorf("%s %q is out of range %d-%d", f.name, part, f.min, f.max)
+		}
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+func (s *schedule) dayMatches(t time.Time) bool {
+	dom := s.dom&(1<<uint(t.Day())) != 0
+	dow := s.dow&(1<<uint(t.Weekday())) != 0
+	if s.domStar || s.dowStar {
+		return dom && dow
+	}
+	return dom || dow
+}
+
+// next returns the first start of the schedule at or after t, which must be a whole minute
+func (s *schedule) next(t time.Time) (time.Time, bool) {
+	limit := t.Add(maxScheduleSearch)
+	for t.Before(limit) {
+		switch {
+		case s.month&(1<<uint(t.Month())) == 0:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+		case !s.dayMatches(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+		case s.hour&(1<<uint(t.Hour())) == 0:
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+		case s.minute&(1<<uint(t.Minute())) == 0:
+			t = t.Add(time.Minute)
+		default:
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// ValidateMaintenanceWindow returns an error when the schedules or the time zone of the window are invalid
+func ValidateMaintenanceWindow(w *addonmgrv1alpha1.MaintenanceWindow) error {
+	_, _, err := parseMaintenanceWindow(w)
+	return err
+}
+
+func parseMaintenanceWindow(w *addonmgrv1alpha1.MaintenanceWindow) ([]*schedule, *time.Location, error) {
+	if len(w.Schedules) == 0 {
+		return nil, nil, fmt.Errorf("maintenance window has no schedules")
+	}
+	if w.Duration.Duration <= 0 {
+		return nil, nil, fmt.Errorf("maintenance window duration must be positive")
+	}
+	loc, err := time.LoadLocation(w.TimeZone)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid maintenance window time zone %q. %v", w.TimeZone, err)
+	}
+	var schedules []*schedule
+	for _, expr := range w.Schedules {
+		s, err := parseSchedule(expr)
+		if err != nil {
+			return nil, nil, err
+		}
+		schedules = append(schedules, s)
+	}
+	return schedules, loc, nil
+}
+
+// MaintenanceWindowOpen returns true when the maintenance window is open at now, otherwise the time it opens next.
+// The next time is zero when the schedules never match.
+func MaintenanceWindowOpen(w *addonmgrv1alpha1.MaintenanceWindow, now time.Time) (bool, time.Time, error) {
+	schedules, loc, err := parseMaintenanceWindow(w)
+	if err != nil {
+		return false, time.Time{}, err
+	}
+	now = now.In(loc)
+
+	// The window is open when a schedule started in the last duration
+	since := ceilMinute(now.Add(-w.Duration.Duration).Add(time.Nanosecond))
+	var next time.Time
+	for _, s := range schedules {
+		if start, ok := s.next(since); ok && !start.After(now) {
+			return true, time.Time{}, nil
+		}
+		if start, ok := s.next(ceilMinute(now)); ok && (next.IsZero() || start.Before(next)) {
+			next = start
+		}
+	}
+	return false, next, nil
+}
+
+func ceilMinute(t time.Time) time.Time {
+	if m := t.Truncate(time.Minute); !m.Equal(t) {
+		return m.Add(time.Minute)
+	}
+	return t
+}
@@ -0,0 +1,104 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package addon
+
+import (
+	"testing"
+	"time"
+
+	"github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	addonmgrv1alpha1 "github.com/keikoproj/addon-manager/api/v1alpha1"
+)
+
+func TestMaintenanceWindowOpen(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	// Saturdays 02:00 to 06:00 and the first of every month at 22:30 for 4 hours
+	w := &addonmgrv1alpha1.MaintenanceWindow{
+		Schedules: []string{"0 2 * * 6", "30 22 1 * *"},
+		Duration:  metav1.Duration{Duration: 4 * time.Hour},
+	}
+	at := func(s string) time.Time {
+		t, err := time.Parse(time.RFC3339, s)
+		g.Expect(err).ToNot(gomega.HaveOccurred())
+		return t
+	}
+
+	tests := []struct {
+		now  string
+		open bool
+		next string
+	}{
+		{"2021-05-08T03:15:00Z", true, ""},
+		{"2021-05-08T02:00:00Z", true, ""},
+		{"2021-05-08T06:00:00Z", false, "2021-05-15T02:00:00Z"},
+		{"2021-05-07T12:00:00Z", false, "2021-05-08T02:00:00Z"},
+		{"2021-05-25T12:00:00Z", false, "2021-05-29T02:00:00Z"},
+		{"2021-05-30T12:00:00Z", false, "2021-06-01T22:30:00Z"},
+		{"2021-06-02T01:59:59Z", true, ""},
+		{"2021-06-02T02:30:00Z", false, "2021-06-05T02:00:00Z"},
+	}
+	for _, tt := range tests {
+		open, next, err := MaintenanceWindowOpen(w, at(tt.now))
+		g.Expect(err).ToNot(gomega.HaveOccurred())
+		g.Expect(open).To(gomega.Equal(tt.open), tt.now)
+		if tt.next != "" {
+			g.Expect(next.Equal(at(tt.next))).To(gomega.BeTrue(), "%s opens at %s", tt.now, next)
+		}
+	}
+
+	// Schedules are in the time zone of the window
+	w = &addonmgrv1alpha1.MaintenanceWindow{
+		Schedules: []string{"0 2 * * *"},
+		Duration:  metav1.Duration{Duration: time.Hour},
+		TimeZone:  "America/New_York",
+	}
+	open, _, err := MaintenanceWindowOpen(w, at("2021-05-08T06:30:00Z"))
+	g.Expect(err).ToNot(gomega.HaveOccurred())
+	g.Expect(open).To(gomega.BeTrue())
+
+	// Schedules that never match never open
+	w = &addonmgrv1alpha1.MaintenanceWindow{Schedules: []string{"0 0 30 2 *"}, Duration: metav1.Duration{Duration: time.Hour}}
+	open, next, err := MaintenanceWindowOpen(w, at("2021-05-08T06:30:00Z"))
+	g.Expect(err).ToNot(gomega.HaveOccurred())
+	g.Expect(open).To(gomega.BeFalse())
+	g.Expect(next.IsZero()).To(gomega.BeTrue())
+}
+
+func TestValidateMaintenanceWindow(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	hour := metav1.Duration{Duration: time.Hour}
+	g.Expect(ValidateMaintenanceWindow(&addonmgrv1alpha1.MaintenanceWindow{Schedules: []string{"*/15 1-5 * 1,6 1-5"}, Duration: hour})).To(gomega.Succeed())
+	g.Expect(ValidateMaintenanceWindow(&addonmgrv1alpha1.MaintenanceWindow{Schedules: []string{"0 2 * * 7"}, Duration: hour})).To(gomega.Succeed())
+
+	tests := []struct {
+		window *addonmgrv1alpha1.MaintenanceWindow
+		err    string
+	}{
+		{&addonmgrv1alpha1.MaintenanceWindow{Duration: hour}, "no schedules"},
+		{&addonmgrv1alpha1.MaintenanceWindow{Schedules: []string{"0 2 * * *"}}, "duration must be positive"},
+		{&addonmgrv1alpha1.MaintenanceWindow{Schedules: []string{"0 2 * *"}, Duration: hour}, "expected 5 fields"},
+		{&addonmgrv1alpha1.MaintenanceWindow{Schedules: []string{"0 24 * * *"}, Duration: hour}, "out of range"},
+		{&addonmgrv1alpha1.MaintenanceWindow{Schedules: []string{"*/0 2 * * *"}, Duration: hour}, "invalid minute step"},
+		{&addonmgrv1alpha1.MaintenanceWindow{Schedules: []string{"0 2 * * mon"}, Duration: hour}, "invalid day of week"},
+		{&addonmgrv1alpha1.MaintenanceWindow{Schedules: []string{"0 2 * * *"}, Duration: hour, TimeZone: "Mars/Olympus"}, "time zone"},
+	}
+	for _, tt := range tests {
+		g.Expect(ValidateMaintenanceWindow(tt.window)).To(gomega.MatchError(gomega.ContainSubstring(tt.err)))
+	}
+}
@@ -0,0 +1,183 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package addon
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+
+	addonmgrv1alpha1 "github.com/keikoproj/addon-manager/api/v1alpha1"
+	"github.com/keikoproj/addon-manager/pkg/common"
+)
+
+const (
+	// DefaultTemplateOffloadThreshold is the encoded addon size templates are offloaded over, a third of the default
+	// etcd request limit leaves room for the status and the managed fields
+	DefaultTemplateOffloadThreshold = 512 * 1024
+	// MaxObjectSize is the default etcd request limit, addons larger than it cannot be saved
+	MaxObjectSize = 1536 * 1024
+	// MaxTemplateSize is the largest template a ConfigMap holds
+	MaxTemplateSize = 1024*1024 - 1024
+
+	// templateKey is the ConfigMap key of offloaded templates and the default key of template references
+	templateKey = "template"
+)
+
+var lifecycleSteps = []addonmgrv1alpha1.LifecycleStep{addonmgrv1alpha1.Prereqs, addonmgrv1alpha1.Install, addonmgrv1alpha1.Upgrade, addonmgrv1alpha1.Validate, addonmgrv1alpha1.Delete}
+
+// TemplateStore offloads the lifecycle workflow templates of addons close to the object size limit to ConfigMaps
+// owned by the addon, so large addons do not fail with opaque etcd errors when they are saved
+type TemplateStore struct {
+	client    kubernetes.Interface
+	threshold int
+}
+
+// NewTemplateStore returns a TemplateStore offloading the templates of addons larger than threshold bytes,
+// templates are never offloaded with a threshold of zero
+func NewTemplateStore(client kubernetes.Interface, threshold int) *TemplateStore {
+	return &TemplateStore{client: client, threshold: threshold}
+}
+
+// TemplateConfigMapName returns the name of the ConfigMap the template of the lifecycle step is offloaded to
+func TemplateConfigMapName(a *addonmgrv1alpha1.Addon, step addonmgrv1alpha1.LifecycleStep) string {
+	return fmt.Sprintf("%s-%s-template", a.Name, step)
+}
+
+// Size returns the size of the encoded addon
+func Size(a *addonmgrv1alpha1.Addon) int {
+	b, err := json.Marshal(a)
+	if err != nil {
+		return 0
+	}
+	return len(b)
+}
+
+// NeedsOffload returns true when the addon is larger than the threshold and still has inline templates
+func (s *TemplateStore) NeedsOffload(a *addonmgrv1alpha1.Addon) bool {
+	if s.threshold <= 0 || Size(a) <= s.threshold {
+		return false
+	}
+	for _, step := range lifecycleSteps {
+		if wt, _ := a.GetWorkflowType(step); wt.Template != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// Resolve inlines the templates the addon references in ConfigMaps and clears the references, so the addon has the
+// spec it was created with and its checksum does not change when templates are offloaded. Inline templates take
+// precedence over references, e.g. when the original spec is applied again.
+func (s *TemplateStore) Resolve(ctx context.Context, a *addonmgrv1alpha1.Addon) error {
+	for _, step := range lifecycleSteps {
+		wt, _ := a.GetWorkflowType(step)
+		ref := wt.TemplateFrom
+		if ref == nil {
+			continue
+		}
+		if wt.Template == "" {
+			cm, err := s.client.CoreV1().ConfigMaps(a.Namespace).Get(ctx, ref.ConfigMap, metav1.GetOptions{})
+			if err != nil {
+				return fmt.Errorf("failed to read the %s template: %v", step, err)
+			}
+			key := ref.Key
+			if key == "" {
+				key = templateKey
+			}
+			template, ok := cm.Data[key]
+			if !ok {
+				return fmt.Errorf("configmap %s/%s has no key %s", a.Namespace, ref.ConfigMap, key)
+			}
+			wt.Template = template
+		}
+		wt.TemplateFrom = nil
+	}
+	return nil
+}
+
+// Offload writes the inline templates of an addon larger than the threshold to ConfigMaps owned by the addon and
+// replaces them with references, it returns true when templates were offloaded. Addons that are still too large to
+// be saved without their templates are reported with their size.
+func (s *TemplateStore) Offload(ctx context.Context, a *addonmgrv1alpha1.Addon) (bool, error) {
+	if !s.NeedsOffload(a) {
+		return false, nil
+	}
+
+	for _, step := range lifecycleSteps {
+		wt, _ := a.GetWorkflowType(step)
+		if wt.Template == "" {
+			continue
+		}
+		if len(wt.Template) > MaxTemplateSize {
+			return false, fmt.Errorf("%s template is %d bytes, larger than the %d bytes a ConfigMap holds", step, len(wt.Template), MaxTemplateSize)
+		}
+		name := TemplateConfigMapName(a, step)
+		if err := s.saveTemplate(ctx, a, name, wt.Template); err != nil {
+			return false, fmt.Errorf("failed to offload the %s template: %v", step, err)
+		}
+		wt.Template = ""
+		wt.TemplateFrom = &addonmgrv1alpha1.TemplateReference{ConfigMap: name}
+	}
+
+	if size := Size(a); size > MaxObjectSize {
+		return false, fmt.Errorf("addon is %d bytes with its templates offloaded, larger than the %d bytes limit", size, MaxObjectSize)
+	}
+	return true, nil
+}
+
+// saveTemplate creates or updates the ConfigMap holding an offloaded template
+func (s *TemplateStore) saveTemplate(ctx context.Context, a *addonmgrv1alpha1.Addon, name, template string) error {
+	cms := s.client.CoreV1().ConfigMaps(a.Namespace)
+	cm, err := cms.Get(ctx, name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		cm = &v1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: a.Namespace,
+				Labels: map[string]string{
+					"app.kubernetes.io/name":       a.Name,
+					"app.kubernetes.io/managed-by": common.AddonGVR().Group,
+				},
+				OwnerReferences: []metav1.OwnerReference{{
+					APIVersion: common.AddonGVR().GroupVersion().String(),
+					Kind:       "Addon",
+					Name:       a.Name,
+					UID:        a.UID,
+				}},
+			},
+			Data: map[string]string{templateKey: template},
+		}
+		_, err = cms.Create(ctx, cm, metav1.CreateOptions{})
+		return err
+	}
+	if err != nil {
+		return err
+	}
+	if cm.Data[templateKey] == template {
+		return nil
+	}
+	if cm.Data == nil {
+		cm.Data = make(map[string]string)
+	}
+	cm.Data[templateKey] = template
+	_, err = cms.Update(ctx, cm, metav1.UpdateOptions{})
+	return err
+}
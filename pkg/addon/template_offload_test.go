@@ -0,0 +1,110 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package addon
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	addonmgrv1alpha1 "github.com/keikoproj/addon-manager/api/v1alpha1"
+)
+
+func TestTemplateStore(t *testing.T) {
+	g := NewGomegaWithT(t)
+	ctx := context.TODO()
+	client := fake.NewSimpleClientset()
+	s := NewTemplateStore(client, 4096)
+
+	install := "kind: Workflow\n# " + strings.Repeat("x", 4096)
+	a := &addonmgrv1alpha1.Addon{
+		ObjectMeta: metav1.ObjectMeta{Name: "large", Namespace: "default", UID: "uid-1"},
+		Spec: addonmgrv1alpha1.AddonSpec{
+			PackageSpec: addonmgrv1alpha1.PackageSpec{PkgName: "large", PkgVersion: "1.0.0"},
+			Lifecycle: addonmgrv1alpha1.LifecycleWorkflowSpec{
+				Install: addonmgrv1alpha1.WorkflowType{Template: install},
+				Delete:  addonmgrv1alpha1.WorkflowType{Template: "kind: Workflow"},
+			},
+		},
+	}
+	checksum := a.CalculateChecksum()
+	g.Expect(s.NeedsOffload(a)).To(BeTrue())
+
+	// Every inline template is offloaded to a ConfigMap owned by the addon
+	offloaded, err := s.Offload(ctx, a)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(offloaded).To(BeTrue())
+	g.Expect(a.Spec.Lifecycle.Install.Template).To(BeEmpty())
+	g.Expect(a.Spec.Lifecycle.Install.TemplateFrom).To(Equal(&addonmgrv1alpha1.TemplateReference{ConfigMap: "large-install-template"}))
+	g.Expect(a.Spec.Lifecycle.Delete.TemplateFrom).To(Equal(&addonmgrv1alpha1.TemplateReference{ConfigMap: "large-delete-template"}))
+	g.Expect(a.Spec.Lifecycle.Prereqs.TemplateFrom).To(BeNil())
+	cm, err := client.CoreV1().ConfigMaps("default").Get(ctx, "large-install-template", metav1.GetOptions{})
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(cm.Data).To(HaveKeyWithValue("template", install))
+	g.Expect(cm.OwnerReferences).To(HaveLen(1))
+	g.Expect(cm.OwnerReferences[0].UID).To(BeEquivalentTo("uid-1"))
+	g.Expect(s.NeedsOffload(a)).To(BeFalse())
+
+	// Resolved addons have the spec they were created with
+	g.Expect(s.Resolve(ctx, a)).To(Succeed())
+	g.Expect(a.Spec.Lifecycle.Install.Template).To(Equal(install))
+	g.Expect(a.Spec.Lifecycle.Install.TemplateFrom).To(BeNil())
+	g.Expect(a.CalculateChecksum()).To(Equal(checksum))
+
+	// Offloading again updates the ConfigMaps of changed templates
+	a.Spec.Lifecycle.Install.Template = install + "\n# v2"
+	offloaded, err = s.Offload(ctx, a)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(offloaded).To(BeTrue())
+	cm, err = client.CoreV1().ConfigMaps("default").Get(ctx, "large-install-template", metav1.GetOptions{})
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(cm.Data["template"]).To(HaveSuffix("# v2"))
+
+	// Inline templates take precedence over references
+	a.Spec.Lifecycle.Delete.Template = "kind: Workflow\n# inline"
+	g.Expect(s.Resolve(ctx, a)).To(Succeed())
+	g.Expect(a.Spec.Lifecycle.Delete.Template).To(Equal("kind: Workflow\n# inline"))
+	g.Expect(a.Spec.Lifecycle.Delete.TemplateFrom).To(BeNil())
+
+	// Missing references are reported
+	a.Spec.Lifecycle.Validate = addonmgrv1alpha1.WorkflowType{TemplateFrom: &addonmgrv1alpha1.TemplateReference{ConfigMap: "large-install-template", Key: "validate"}}
+	g.Expect(s.Resolve(ctx, a)).To(MatchError(ContainSubstring("has no key validate")))
+}
+
+func TestTemplateStoreLimits(t *testing.T) {
+	g := NewGomegaWithT(t)
+	ctx := context.TODO()
+
+	small := &addonmgrv1alpha1.Addon{
+		ObjectMeta: metav1.ObjectMeta{Name: "small", Namespace: "default"},
+		Spec: addonmgrv1alpha1.AddonSpec{
+			Lifecycle: addonmgrv1alpha1.LifecycleWorkflowSpec{Install: addonmgrv1alpha1.WorkflowType{Template: "kind: Workflow"}},
+		},
+	}
+	g.Expect(NewTemplateStore(fake.NewSimpleClientset(), DefaultTemplateOffloadThreshold).NeedsOffload(small)).To(BeFalse())
+
+	// Templates are never offloaded without a threshold
+	huge := small.DeepCopy()
+	huge.Spec.Lifecycle.Install.Template = strings.Repeat("x", MaxTemplateSize+1)
+	g.Expect(NewTemplateStore(fake.NewSimpleClientset(), 0).NeedsOffload(huge)).To(BeFalse())
+
+	// Templates larger than a ConfigMap are rejected with their size
+	_, err := NewTemplateStore(fake.NewSimpleClientset(), DefaultTemplateOffloadThreshold).Offload(ctx, huge)
+	g.Expect(err).To(MatchError(ContainSubstring("install template is")))
+}
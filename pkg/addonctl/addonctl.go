@@ -24,10 +24,18 @@ import (
 	"path/filepath"
 	"regexp"
 	"strings"
+	"time"
 
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/config"
+	"sigs.k8s.io/yaml"
 
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
@@ -35,7 +43,12 @@ import (
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 
 	addonmgrv1alpha1 "github.com/keikoproj/addon-manager/api/v1alpha1"
+	"github.com/keikoproj/addon-manager/pkg/catalog"
 	"github.com/keikoproj/addon-manager/pkg/common"
+	"github.com/keikoproj/addon-manager/pkg/conformance"
+	"github.com/keikoproj/addon-manager/pkg/inventory"
+	"github.com/keikoproj/addon-manager/pkg/plan"
+	"github.com/keikoproj/addon-manager/pkg/summary"
 	"github.com/keikoproj/addon-manager/pkg/version"
 	"github.com/keikoproj/addon-manager/pkg/workflows"
 )
@@ -45,6 +58,19 @@ var clusterName string
 var clusterRegion string
 var debug bool
 var dryRun bool
+var allNamespaces bool
+var inventoryFormat string
+var getFormat string
+var statusFormat string
+var deleteCascade string
+var deleteTimeout time.Duration
+var planFile string
+var planVersion string
+var planFormat string
+var conformanceUpgradeFile string
+var conformanceUpgradeVersion string
+var conformanceTimeout time.Duration
+var conformanceFormat string
 var description string
 var dependencies string
 var install string
@@ -257,9 +283,366 @@ func newRootCommand() *cobra.Command {
 		},
 	})
 
+	inventoryCmd := &cobra.Command{
+		Use:   "inventory",
+		Short: "Print the inventory of installed addons with their versions, checksums and images as JSON or CSV",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			kubeClient := dynamic.NewForConfigOrDie(cfg)
+			ns := addonMgrSystemNamespace
+			if allNamespaces {
+				ns = metav1.NamespaceAll
+			}
+			report, err := listInventory(context.TODO(), kubeClient, ns)
+			if err != nil {
+				return err
+			}
+			return report.Write(os.Stdout, inventoryFormat)
+		},
+	}
+	inventoryCmd.Flags().StringVarP(&inventoryFormat, "output", "o", "json", "Output format, json or csv")
+	inventoryCmd.Flags().BoolVarP(&allNamespaces, "all-namespaces", "A", false, "List addons in all namespaces instead of the addon-manager-system namespace")
+	rootCmd.AddCommand(inventoryCmd)
+
+	getCmd := &cobra.Command{
+		Use:   "get [NAME]",
+		Short: "List addons with their phase, version, checksum and the state of each lifecycle workflow",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			addons, err := getAddons(context.TODO(), dynamic.NewForConfigOrDie(cfg), args)
+			if err != nil {
+				return err
+			}
+			if getFormat == "json" {
+				return prettyPrint(addons)
+			}
+			return summary.WriteTable(os.Stdout, addons, allNamespaces)
+		},
+	}
+	getCmd.Flags().StringVarP(&getFormat, "output", "o", "text", "Output format, text or json")
+	getCmd.Flags().BoolVarP(&allNamespaces, "all-namespaces", "A", false, "List addons in all namespaces instead of the addon-manager-system namespace")
+	rootCmd.AddCommand(getCmd)
+
+	statusCmd := &cobra.Command{
+		Use:   "status [NAME]",
+		Short: "Print the status of addons with the workflow, start time and duration of each lifecycle step",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			addons, err := getAddons(context.TODO(), dynamic.NewForConfigOrDie(cfg), args)
+			if err != nil {
+				return err
+			}
+			if statusFormat == "json" {
+				return prettyPrint(addons)
+			}
+			for i, a := range addons {
+				if i > 0 {
+					fmt.Println()
+				}
+				if err := summary.WriteStatus(os.Stdout, a); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	}
+	statusCmd.Flags().StringVarP(&statusFormat, "output", "o", "text", "Output format, text or json")
+	statusCmd.Flags().BoolVarP(&allNamespaces, "all-namespaces", "A", false, "Print addons in all namespaces instead of the addon-manager-system namespace")
+	rootCmd.AddCommand(statusCmd)
+
+	deleteCmd := &cobra.Command{
+		Use:   "delete NAME",
+		Short: "Delete an addon and, with the cascade mode, its workflows and deployed resources, waiting for its delete workflow",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			result, err := deleteAddon(context.TODO(), dynamic.NewForConfigOrDie(cfg), addonMgrSystemNamespace, args[0], deleteCascade, deleteTimeout, 2*time.Second)
+			if result != nil {
+				fmt.Println(result.String(addonMgrSystemNamespace, args[0]))
+			}
+			return err
+		},
+	}
+	deleteCmd.Flags().StringVar(&deleteCascade, "cascade", cascadeAll,
+		"What is deleted with the addon: all runs the delete workflow removing the deployed resources and deletes the workflows, workflows keeps the deployed resources, none keeps the deployed resources and the workflows")
+	deleteCmd.Flags().DurationVar(&deleteTimeout, "timeout", 5*time.Minute, "How long to wait for the addon manager to delete the addon")
+	rootCmd.AddCommand(deleteCmd)
+
+	rootCmd.AddCommand(&cobra.Command{
+		Use:   "resume NAME",
+		Short: "Submit the failed workflows of an addon again, closing its circuit once its failure budget is exhausted",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := resumeAddon(context.TODO(), dynamic.NewForConfigOrDie(cfg), args[0]); err != nil {
+				return err
+			}
+			fmt.Printf("addon %s/%s resumed\n", addonMgrSystemNamespace, args[0])
+			return nil
+		},
+	})
+
+	planCmd := &cobra.Command{
+		Use:   "plan NAME",
+		Short: "Preview the lifecycle workflows, dependency order and spec diff of an addon change without applying it",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if planFile == "" && planVersion == "" {
+				return errors.New("one of --filename or --to-version is required")
+			}
+			p, err := planAddon(context.TODO(), cfg, args[0])
+			if err != nil {
+				return err
+			}
+			if planFormat == "json" {
+				return prettyPrint(p)
+			}
+			return p.Write(os.Stdout)
+		},
+	}
+	planCmd.Flags().StringVarP(&planFile, "filename", "f", "", "File with the desired addon")
+	planCmd.Flags().StringVar(&planVersion, "to-version", "", "Package version the installed addon is upgraded to")
+	planCmd.Flags().StringVarP(&planFormat, "output", "o", "text", "Output format, text or json")
+	rootCmd.AddCommand(planCmd)
+
+	conformanceCmd := &cobra.Command{
+		Use:   "conformance FILE",
+		Short: "Install, validate, upgrade and delete an addon package in a test cluster and report whether it conforms",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			report, err := runConformance(context.TODO(), cfg, args[0])
+			if err != nil {
+				return err
+			}
+			if err := report.Write(os.Stdout, conformanceFormat); err != nil {
+				return err
+			}
+			if !report.Passed {
+				return fmt.Errorf("package %s:%s failed conformance", report.Package, report.Version)
+			}
+			return nil
+		},
+	}
+	conformanceCmd.Flags().StringVar(&conformanceUpgradeFile, "upgrade-filename", "", "File with the addon the package is upgraded to")
+	conformanceCmd.Flags().StringVar(&conformanceUpgradeVersion, "upgrade-version", "", "Package version the addon is upgraded to, ignored with --upgrade-filename")
+	conformanceCmd.Flags().DurationVar(&conformanceTimeout, "timeout", conformance.DefaultTimeout, "How long each step waits for the addon manager")
+	conformanceCmd.Flags().StringVarP(&conformanceFormat, "output", "o", "text", "Report format, text or json")
+	rootCmd.AddCommand(conformanceCmd)
+
 	return rootCmd
 }
 
+// runConformance runs the conformance suite for the addon in file, the addon is created in the addon-manager-system
+// namespace when it has none
+func runConformance(ctx context.Context, cfg *rest.Config, file string) (*conformance.Report, error) {
+	candidate, err := readAddon(file)
+	if err != nil {
+		return nil, err
+	}
+
+	var upgrade *addonmgrv1alpha1.Addon
+	switch {
+	case conformanceUpgradeFile != "":
+		if upgrade, err = readAddon(conformanceUpgradeFile); err != nil {
+			return nil, err
+		}
+	case conformanceUpgradeVersion != "":
+		upgrade = candidate.DeepCopy()
+		upgrade.Spec.PkgVersion = conformanceUpgradeVersion
+	}
+
+	sch := runtime.NewScheme()
+	if err := addonmgrv1alpha1.AddToScheme(sch); err != nil {
+		return nil, err
+	}
+	c, err := client.New(cfg, client.Options{Scheme: sch})
+	if err != nil {
+		return nil, err
+	}
+	s := conformance.New(c, dynamic.NewForConfigOrDie(cfg), conformance.Options{Timeout: conformanceTimeout})
+	return s.Run(ctx, candidate, upgrade), nil
+}
+
+func readAddon(file string) (*addonmgrv1alpha1.Addon, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	a := &addonmgrv1alpha1.Addon{}
+	if err := yaml.Unmarshal(data, a); err != nil {
+		return nil, fmt.Errorf("unable to parse %s. %v", file, err)
+	}
+	if a.GetNamespace() == "" {
+		a.SetNamespace(addonMgrSystemNamespace)
+	}
+	return a, nil
+}
+
+func listInventory(ctx context.Context, kubeClient dynamic.Interface, ns string) (*inventory.Report, error) {
+	addons, err := listAddons(ctx, kubeClient, ns)
+	if err != nil {
+		return nil, err
+	}
+	return inventory.New(addons, time.Now()), nil
+}
+
+func listAddons(ctx context.Context, kubeClient dynamic.Interface, ns string) ([]addonmgrv1alpha1.Addon, error) {
+	list, err := kubeClient.Resource(common.AddonGVR()).Namespace(ns).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	addons := make([]addonmgrv1alpha1.Addon, len(list.Items))
+	for i := range list.Items {
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(list.Items[i].UnstructuredContent(), &addons[i]); err != nil {
+			return nil, fmt.Errorf("unable to parse addon %s. %v", list.Items[i].GetName(), err)
+		}
+	}
+	return addons, nil
+}
+
+// getAddons returns the summary of the addon named in args, or of all addons when no name is given, in the
+// addon-manager-system namespace unless --all-namespaces is set
+func getAddons(ctx context.Context, kubeClient dynamic.Interface, args []string) ([]summary.Addon, error) {
+	ns := addonMgrSystemNamespace
+	if allNamespaces {
+		ns = metav1.NamespaceAll
+	}
+	addons, err := listAddons(ctx, kubeClient, ns)
+	if err != nil {
+		return nil, err
+	}
+	summaries := summary.New(addons)
+	if len(args) == 0 {
+		return summaries, nil
+	}
+	var named []summary.Addon
+	for _, a := range summaries {
+		if a.Name == args[0] {
+			named = append(named, a)
+		}
+	}
+	if len(named) == 0 {
+		return nil, fmt.Errorf("addon %s not found", args[0])
+	}
+	return named, nil
+}
+
+// resumeAddon sets the resume annotation on the addon, the addon manager removes it once the failed workflows are
+// submitted again
+func resumeAddon(ctx context.Context, kubeClient dynamic.Interface, name string) error {
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]string{addonmgrv1alpha1.ResumeAnnotation: time.Now().UTC().Format(time.RFC3339)},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	_, err = kubeClient.Resource(common.AddonGVR()).Namespace(addonMgrSystemNamespace).Patch(ctx, name, types.MergePatchType, patch, metav1.PatchOptions{})
+	return err
+}
+
+func planAddon(ctx context.Context, cfg *rest.Config, name string) (*plan.Plan, error) {
+	kubeClient := dynamic.NewForConfigOrDie(cfg)
+
+	var current *addonmgrv1alpha1.Addon
+	obj, err := kubeClient.Resource(common.AddonGVR()).Namespace(addonMgrSystemNamespace).Get(ctx, name, metav1.GetOptions{})
+	switch {
+	case err == nil:
+		current = &addonmgrv1alpha1.Addon{}
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.UnstructuredContent(), current); err != nil {
+			return nil, err
+		}
+	case !apierrors.IsNotFound(err):
+		return nil, err
+	case planFile == "":
+		return nil, fmt.Errorf("addon %s not found", name)
+	}
+
+	var desired *addonmgrv1alpha1.Addon
+	if planFile != "" {
+		data, err := ioutil.ReadFile(planFile)
+		if err != nil {
+			return nil, err
+		}
+		desired = &addonmgrv1alpha1.Addon{}
+		if err := yaml.Unmarshal(data, desired); err != nil {
+			return nil, fmt.Errorf("unable to parse %s. %v", planFile, err)
+		}
+		desired.SetName(name)
+		if desired.GetNamespace() == "" {
+			desired.SetNamespace(addonMgrSystemNamespace)
+		}
+	} else {
+		desired = current.DeepCopy()
+	}
+	if planVersion != "" {
+		desired.Spec.PkgVersion = planVersion
+		desired.Spec.PkgChannel = ""
+	}
+
+	list, err := kubeClient.Resource(common.AddonGVR()).Namespace(metav1.NamespaceAll).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	installed := make([]addonmgrv1alpha1.Addon, len(list.Items))
+	for i := range list.Items {
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(list.Items[i].UnstructuredContent(), &installed[i]); err != nil {
+			return nil, fmt.Errorf("unable to parse addon %s. %v", list.Items[i].GetName(), err)
+		}
+	}
+
+	var snapshot *catalog.Snapshot
+	if desired.Spec.Catalog != "" {
+		if snapshot, err = fetchCatalog(ctx, cfg, desired.Namespace, desired.Spec.Catalog); err != nil {
+			return nil, fmt.Errorf("unable to fetch catalog %s. %v", desired.Spec.Catalog, err)
+		}
+	}
+
+	return plan.New(current, desired, installed, snapshot)
+}
+
+// fetchCatalog fetches and parses the index of an AddonCatalog the same way the controller syncs it
+func fetchCatalog(ctx context.Context, cfg *rest.Config, ns, name string) (*catalog.Snapshot, error) {
+	obj, err := dynamic.NewForConfigOrDie(cfg).Resource(common.AddonCatalogGVR()).Namespace(ns).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	var ac addonmgrv1alpha1.AddonCatalog
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.UnstructuredContent(), &ac); err != nil {
+		return nil, err
+	}
+
+	var creds *catalog.Credentials
+	if ac.Spec.SecretRef != "" {
+		secret, err := kubernetes.NewForConfigOrDie(cfg).CoreV1().Secrets(ns).Get(ctx, ac.Spec.SecretRef, metav1.GetOptions{})
+		if err != nil {
+			return nil, err
+		}
+		creds = &catalog.Credentials{
+			Username: string(secret.Data["username"]),
+			Password: string(secret.Data["password"]),
+			Token:    string(secret.Data["token"]),
+		}
+	}
+
+	var tlsMaterial *catalog.TLS
+	if ac.Spec.TLS != nil && ac.Spec.TLS.SecretRef != "" {
+		secret, err := kubernetes.NewForConfigOrDie(cfg).CoreV1().Secrets(ns).Get(ctx, ac.Spec.TLS.SecretRef, metav1.GetOptions{})
+		if err != nil {
+			return nil, err
+		}
+		tlsMaterial = &catalog.TLS{
+			CA:   secret.Data["ca.crt"],
+			Cert: secret.Data["tls.crt"],
+			Key:  secret.Data["tls.key"],
+		}
+	}
+
+	data, err := catalog.NewFetcher().Fetch(ctx, ac.Spec, creds, tlsMaterial)
+	if err != nil {
+		return nil, err
+	}
+	return catalog.ParseIndex(data)
+}
+
 func prettyPrint(v interface{}) (err error) {
 	b, err := json.MarshalIndent(v, "", "  ")
 	if err == nil {
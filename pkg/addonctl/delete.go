@@ -0,0 +1,143 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package addonctl
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/dynamic"
+
+	addonmgrv1alpha1 "github.com/keikoproj/addon-manager/api/v1alpha1"
+	"github.com/keikoproj/addon-manager/pkg/common"
+	"github.com/keikoproj/addon-manager/pkg/workflows"
+)
+
+const (
+	// cascadeAll runs the delete workflow of the addon, which removes its deployed resources, and deletes its workflows
+	cascadeAll = "all"
+	// cascadeWorkflows skips the delete workflow so the deployed resources are kept, the workflows are deleted
+	cascadeWorkflows = "workflows"
+	// cascadeNone skips the delete workflow and orphans the workflows of the addon
+	cascadeNone = "none"
+)
+
+// skipDeletePatch skips the delete workflow of an addon whose deployed resources are kept
+var skipDeletePatch = []byte(`{"spec":{"lifecycle":{"delete":{"skip":true}}}}`)
+
+// deleteResult is the delete workflow the addon manager ran for a deleted addon
+type deleteResult struct {
+	// Workflow is the name of the delete workflow, empty when the addon has none
+	Workflow string
+	// Phase is the last observed phase of the delete workflow
+	Phase string
+}
+
+// String describes the deleted addon and the result of its delete workflow
+func (r *deleteResult) String(ns, name string) string {
+	if r.Workflow == "" || r.Phase == "" {
+		return fmt.Sprintf("addon %s/%s deleted", ns, name)
+	}
+	return fmt.Sprintf("addon %s/%s deleted, delete workflow %s %s", ns, name, r.Workflow, r.Phase)
+}
+
+// deleteAddon deletes the addon with the cascade mode and waits until the addon manager removed it, polling every
+// interval. An error is returned when the delete workflow failed even though the addon was removed.
+func deleteAddon(ctx context.Context, kubeClient dynamic.Interface, ns, name, cascade string, timeout, interval time.Duration) (*deleteResult, error) {
+	addons := kubeClient.Resource(common.AddonGVR()).Namespace(ns)
+	propagation := metav1.DeletePropagationBackground
+	switch cascade {
+	case cascadeAll:
+	case cascadeWorkflows, cascadeNone:
+		if _, err := addons.Patch(ctx, name, types.MergePatchType, skipDeletePatch, metav1.PatchOptions{}); err != nil {
+			return nil, err
+		}
+		if cascade == cascadeNone {
+			propagation = metav1.DeletePropagationOrphan
+		}
+	default:
+		return nil, fmt.Errorf("unknown cascade %q, must be one of all, workflows, none", cascade)
+	}
+	if err := addons.Delete(ctx, name, metav1.DeleteOptions{PropagationPolicy: &propagation}); err != nil {
+		return nil, err
+	}
+
+	result := &deleteResult{}
+	err := wait.PollImmediate(interval, timeout, func() (bool, error) {
+		obj, err := addons.Get(ctx, name, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			return true, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		a := &addonmgrv1alpha1.Addon{}
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.UnstructuredContent(), a); err != nil {
+			return false, fmt.Errorf("unable to parse addon %s. %v", name, err)
+		}
+		if timing := a.GetStepTiming(addonmgrv1alpha1.Delete); timing != nil && timing.Workflow != "" {
+			if timing.Workflow != result.Workflow {
+				result.Workflow, result.Phase = timing.Workflow, ""
+			}
+			if phase := workflowPhase(ctx, kubeClient, a, timing.Workflow); phase != "" {
+				result.Phase = phase
+			}
+		}
+		if a.Status.Lifecycle.Installed == addonmgrv1alpha1.DeleteFailed {
+			return false, fmt.Errorf("addon %s/%s could not be deleted. %s", ns, name, a.Status.Reason)
+		}
+		return false, nil
+	})
+	if err == wait.ErrWaitTimeout {
+		return result, fmt.Errorf("addon %s/%s was not deleted after %s", ns, name, timeout)
+	}
+	if err != nil {
+		return result, err
+	}
+	if result.Phase == "Failed" {
+		return result, fmt.Errorf("addon %s/%s was deleted but its delete workflow %s failed", ns, name, result.Workflow)
+	}
+	return result, nil
+}
+
+// workflowPhase returns the phase of the workflow of the addon, the workflow may run in another namespace under a
+// qualified name. It is empty when the workflow is not found or cannot be listed.
+func workflowPhase(ctx context.Context, kubeClient dynamic.Interface, a *addonmgrv1alpha1.Addon, name string) string {
+	list, err := kubeClient.Resource(common.WorkflowGVR()).Namespace(metav1.NamespaceAll).List(ctx, metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("%s=%s", workflows.AddonLabel, a.GetName()),
+	})
+	if err != nil {
+		return ""
+	}
+	for _, wf := range list.Items {
+		if wf.GetName() != name && !strings.HasPrefix(wf.GetName(), name+"-") {
+			continue
+		}
+		if wf.GetNamespace() != a.GetNamespace() && wf.GetLabels()[workflows.AddonNamespaceLabel] != a.GetNamespace() {
+			continue
+		}
+		phase, _, _ := unstructured.NestedString(wf.Object, "status", "phase")
+		return phase
+	}
+	return ""
+}
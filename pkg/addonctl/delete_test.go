@@ -0,0 +1,117 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package addonctl
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynfake "k8s.io/client-go/dynamic/fake"
+	clienttesting "k8s.io/client-go/testing"
+
+	addonmgrv1alpha1 "github.com/keikoproj/addon-manager/api/v1alpha1"
+	"github.com/keikoproj/addon-manager/pkg/common"
+	"github.com/keikoproj/addon-manager/pkg/workflows"
+)
+
+func newDeleteClient(objects ...runtime.Object) *dynfake.FakeDynamicClient {
+	s := runtime.NewScheme()
+	for kind, gv := range map[string]schema.GroupVersion{"Addon": common.AddonGVR().GroupVersion(), "Workflow": common.WorkflowGVR().GroupVersion()} {
+		s.AddKnownTypeWithName(gv.WithKind(kind), &unstructured.Unstructured{})
+		s.AddKnownTypeWithName(gv.WithKind(kind+"List"), &unstructured.UnstructuredList{})
+	}
+	return dynfake.NewSimpleDynamicClient(s, objects...)
+}
+
+func newDeleteAddon(t *testing.T, a *addonmgrv1alpha1.Addon) *unstructured.Unstructured {
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	obj := &unstructured.Unstructured{Object: content}
+	obj.SetAPIVersion(common.AddonGVR().GroupVersion().String())
+	obj.SetKind("Addon")
+	return obj
+}
+
+func TestDeleteAddon(t *testing.T) {
+	g := NewGomegaWithT(t)
+	ctx := context.TODO()
+
+	a := newDeleteAddon(t, &addonmgrv1alpha1.Addon{ObjectMeta: metav1.ObjectMeta{Name: "metrics-server", Namespace: "addon-manager-system"}})
+	_, err := deleteAddon(ctx, newDeleteClient(a.DeepCopy()), "addon-manager-system", "metrics-server", "resources", time.Second, time.Millisecond)
+	g.Expect(err).To(MatchError(ContainSubstring("unknown cascade")))
+
+	// Deployed resources are kept by skipping the delete workflow
+	dynClient := newDeleteClient(a.DeepCopy())
+	result, err := deleteAddon(ctx, dynClient, "addon-manager-system", "metrics-server", cascadeWorkflows, time.Second, time.Millisecond)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(result.String("addon-manager-system", "metrics-server")).To(Equal("addon addon-manager-system/metrics-server deleted"))
+	actions := dynClient.Actions()
+	g.Expect(actions[0]).To(BeAssignableToTypeOf(clienttesting.PatchActionImpl{}))
+	g.Expect(actions[0].(clienttesting.PatchActionImpl).GetPatch()).To(Equal(skipDeletePatch))
+	g.Expect(actions[1]).To(BeAssignableToTypeOf(clienttesting.DeleteActionImpl{}))
+
+	// All deletes the addon without changing its spec
+	dynClient = newDeleteClient(a.DeepCopy())
+	_, err = deleteAddon(ctx, dynClient, "addon-manager-system", "metrics-server", cascadeAll, time.Second, time.Millisecond)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(dynClient.Actions()[0]).To(BeAssignableToTypeOf(clienttesting.DeleteActionImpl{}))
+}
+
+func TestDeleteAddon_DeleteWorkflowResult(t *testing.T) {
+	g := NewGomegaWithT(t)
+	ctx := context.TODO()
+
+	deleting := &addonmgrv1alpha1.Addon{ObjectMeta: metav1.ObjectMeta{Name: "metrics-server", Namespace: "addon-manager-system"}}
+	deleting.Status.Lifecycle.Installed = addonmgrv1alpha1.Deleting
+	deleting.Status.Timings.Delete = &addonmgrv1alpha1.LifecycleStepTiming{Workflow: "metrics-server-delete-7a5e1c2b-wf"}
+	wf := &unstructured.Unstructured{}
+	wf.SetAPIVersion(common.WorkflowGVR().GroupVersion().String())
+	wf.SetKind("Workflow")
+	wf.SetNamespace("addon-manager-system")
+	wf.SetName("metrics-server-delete-7a5e1c2b-wf")
+	wf.SetLabels(map[string]string{workflows.AddonLabel: "metrics-server"})
+
+	for phase, failed := range map[string]bool{"Succeeded": false, "Failed": true} {
+		g.Expect(unstructured.SetNestedField(wf.Object, phase, "status", "phase")).To(Succeed())
+		dynClient := newDeleteClient(newDeleteAddon(t, deleting), wf.DeepCopy())
+		// The addon is removed once the manager observed its delete workflow complete
+		gets := 0
+		dynClient.PrependReactor("get", "addons", func(action clienttesting.Action) (bool, runtime.Object, error) {
+			if gets++; gets > 1 {
+				return true, nil, apierrors.NewNotFound(common.AddonGVR().GroupResource(), "metrics-server")
+			}
+			return true, newDeleteAddon(t, deleting), nil
+		})
+
+		result, err := deleteAddon(ctx, dynClient, "addon-manager-system", "metrics-server", cascadeAll, time.Second, time.Millisecond)
+		g.Expect(result.Workflow).To(Equal("metrics-server-delete-7a5e1c2b-wf"))
+		g.Expect(result.Phase).To(Equal(phase))
+		g.Expect(result.String("addon-manager-system", "metrics-server")).To(Equal("addon addon-manager-system/metrics-server deleted, delete workflow metrics-server-delete-7a5e1c2b-wf " + phase))
+		if failed {
+			g.Expect(err).To(MatchError(ContainSubstring("delete workflow metrics-server-delete-7a5e1c2b-wf failed")))
+		} else {
+			g.Expect(err).ToNot(HaveOccurred())
+		}
+	}
+}
@@ -0,0 +1,317 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package audit
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/clock"
+	"k8s.io/client-go/kubernetes"
+
+	addonmgrv1alpha1 "github.com/keikoproj/addon-manager/api/v1alpha1"
+	"github.com/keikoproj/addon-manager/pkg/common"
+)
+
+const (
+	// MaxEntries is the number of audit entries kept per addon
+	MaxEntries = 10
+
+	// AddonLabel marks audit ConfigMaps with the name of the addon they keep the history of
+	AddonLabel = "addonmgr.keikoproj.io/audit-for"
+
+	historyKey  = "history"
+	checksumKey = "checksum"
+	fieldsKey   = "fields"
+)
+
+// Entry is a single audited change of an Addon spec
+type Entry struct {
+	Time             metav1.Time `json:"time"`
+	Manager          string      `json:"manager,omitempty"`
+	Operation        string      `json:"operation,omitempty"`
+	PreviousChecksum string      `json:"previousChecksum,omitempty"`
+	Checksum         string      `json:"checksum"`
+	Changed          []string    `json:"changed,omitempty"`
+}
+
+// String returns a compact description of the entry suitable for events
+func (e *Entry) String() string {
+	manager := e.Manager
+	if manager == "" {
+		manager = "unknown"
+	}
+	if e.PreviousChecksum == "" {
+		return fmt.Sprintf("Addon spec %s created by %s", e.Checksum, manager)
+	}
+	return fmt.Sprintf("Addon spec changed %s -> %s by %s, fields: %s", e.PreviousChecksum, e.Checksum, manager, strings.Join(e.Changed, ","))
+}
+
+// Recorder keeps a per addon audit history of spec changes in a ConfigMap. The ConfigMap holds digests of the spec
+// fields and the names of the changed fields only, the spec itself may carry params and is never stored.
+type Recorder struct {
+	client kubernetes.Interface
+	clock  clock.PassiveClock
+}
+
+// NewAuditRecorder returns an audit Recorder backed by ConfigMaps
+func NewAuditRecorder(client kubernetes.Interface) *Recorder {
+	return &Recorder{client: client, clock: clock.RealClock{}}
+}
+
+// SetClock sets the clock entries are timestamped with
+func (r *Recorder) SetClock(c clock.PassiveClock) {
+	r.clock = c
+}
+
+// ConfigMapName returns the name of the audit ConfigMap for the addon
+func ConfigMapName(addon *addonmgrv1alpha1.Addon) string {
+	return fmt.Sprintf("addonmgr-audit-%s", addon.GetName())
+}
+
+// Record compares the addon spec with the last audited spec and appends a new entry when it changed.
+// A nil entry is returned if the spec has not changed since the last record.
+func (r *Recorder) Record(ctx context.Context, addon *addonmgrv1alpha1.Addon) (*Entry, error) {
+	checksum := addon.GetChecksum()
+	cms := r.client.CoreV1().ConfigMaps(addon.GetNamespace())
+
+	cm, err := cms.Get(ctx, ConfigMapName(addon), metav1.GetOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return nil, err
+	}
+
+	var exists = err == nil
+	if !exists {
+		cm = &v1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      ConfigMapName(addon),
+				Namespace: addon.GetNamespace(),
+				Labels: map[string]string{
+					AddonLabel:                     addon.GetName(),
+					"app.kubernetes.io/managed-by": common.AddonGVR().Group,
+				},
+				OwnerReferences: []metav1.OwnerReference{addonOwnerReference(addon)},
+			},
+		}
+	} else if cm.GetLabels()[AddonLabel] != addon.GetName() {
+		return nil, fmt.Errorf("configmap %s/%s is not an audit trail of the addon", cm.GetNamespace(), cm.GetName())
+	}
+	if cm.Data == nil {
+		cm.Data = map[string]string{}
+	}
+
+	if cm.Data[checksumKey] == checksum {
+		return nil, nil
+	}
+
+	spec, err := json.Marshal(addon.Spec)
+	if err != nil {
+		return nil, err
+	}
+	fields, err := fieldDigests(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	entry := &Entry{
+		Time:             metav1.NewTime(r.clock.Now()),
+		PreviousChecksum: cm.Data[checksumKey],
+		Checksum:         checksum,
+	}
+	if raw := cm.Data[fieldsKey]; raw != "" {
+		var previous map[string]string
+		if err := json.Unmarshal([]byte(raw), &previous); err == nil {
+			entry.Changed = changedDigests(previous, fields)
+		}
+	}
+	if mf := LastSpecManager(addon.GetManagedFields()); mf != nil {
+		entry.Manager = mf.Manager
+		entry.Operation = string(mf.Operation)
+		if mf.Time != nil {
+			entry.Time = *mf.Time
+		}
+	}
+
+	var history []Entry
+	if raw := cm.Data[historyKey]; raw != "" {
+		if err := json.Unmarshal([]byte(raw), &history); err != nil {
+			// Start a new history rather than failing on a corrupted one
+			history = nil
+		}
+	}
+	history = append(history, *entry)
+	if len(history) > MaxEntries {
+		history = history[len(history)-MaxEntries:]
+	}
+
+	raw, err := json.Marshal(history)
+	if err != nil {
+		return nil, err
+	}
+	digests, err := json.Marshal(fields)
+	if err != nil {
+		return nil, err
+	}
+	cm.Data[historyKey] = string(raw)
+	cm.Data[checksumKey] = checksum
+	cm.Data[fieldsKey] = string(digests)
+
+	if exists {
+		_, err = cms.Update(ctx, cm, metav1.UpdateOptions{})
+	} else {
+		_, err = cms.Create(ctx, cm, metav1.CreateOptions{})
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return entry, nil
+}
+
+// LastSpecManager returns the most recent managed fields entry that touched the spec
+func LastSpecManager(managedFields []metav1.ManagedFieldsEntry) *metav1.ManagedFieldsEntry {
+	var last *metav1.ManagedFieldsEntry
+	for i := range managedFields {
+		mf := &managedFields[i]
+		if mf.FieldsV1 == nil || !bytes.Contains(mf.FieldsV1.Raw, []byte(`"f:spec"`)) {
+			continue
+		}
+		if last == nil || last.Time == nil || (mf.Time != nil && !mf.Time.Before(last.Time)) {
+			last = mf
+		}
+	}
+	return last
+}
+
+// ChangedFields compares two JSON encoded specs and returns the sorted list of changed fields, two levels deep
+func ChangedFields(previous, current []byte) ([]string, error) {
+	var prev, curr map[string]interface{}
+	if err := json.Unmarshal(previous, &prev); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(current, &curr); err != nil {
+		return nil, err
+	}
+
+	var changed []string
+	for _, key := range unionKeys(prev, curr) {
+		pv, cv := prev[key], curr[key]
+		if reflect.DeepEqual(pv, cv) {
+			continue
+		}
+
+		pm, pok := pv.(map[string]interface{})
+		cm, cok := cv.(map[string]interface{})
+		if !pok || !cok {
+			changed = append(changed, key)
+			continue
+		}
+
+		for _, sub := range unionKeys(pm, cm) {
+			if !reflect.DeepEqual(pm[sub], cm[sub]) {
+				changed = append(changed, key+"."+sub)
+			}
+		}
+	}
+
+	return changed, nil
+}
+
+// fieldDigests returns the digests of the fields of a JSON encoded spec, two levels deep, keyed by the field name.
+// Field values are salted with their name so equal values of different fields do not share a digest.
+func fieldDigests(spec []byte) (map[string]string, error) {
+	var m map[string]interface{}
+	if err := json.Unmarshal(spec, &m); err != nil {
+		return nil, err
+	}
+
+	digests := make(map[string]string)
+	digest := func(name string, v interface{}) error {
+		raw, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		sum := sha256.Sum256(append([]byte(name+"="), raw...))
+		digests[name] = hex.EncodeToString(sum[:])
+		return nil
+	}
+	for key, v := range m {
+		sub, ok := v.(map[string]interface{})
+		if !ok {
+			if err := digest(key, v); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		for k, sv := range sub {
+			if err := digest(key+"."+k, sv); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return digests, nil
+}
+
+// changedDigests returns the sorted names of the fields whose digests differ
+func changedDigests(previous, current map[string]string) []string {
+	var changed []string
+	for name, d := range current {
+		if previous[name] != d {
+			changed = append(changed, name)
+		}
+	}
+	for name := range previous {
+		if _, ok := current[name]; !ok {
+			changed = append(changed, name)
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
+
+func unionKeys(a, b map[string]interface{}) []string {
+	var keys []string
+	var seen = make(map[string]struct{})
+	for _, m := range []map[string]interface{}{a, b} {
+		for k := range m {
+			if _, ok := seen[k]; !ok {
+				seen[k] = struct{}{}
+				keys = append(keys, k)
+			}
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func addonOwnerReference(addon *addonmgrv1alpha1.Addon) metav1.OwnerReference {
+	gvr := common.AddonGVR()
+	return metav1.OwnerReference{
+		APIVersion: gvr.GroupVersion().String(),
+		Kind:       "Addon",
+		Name:       addon.GetName(),
+		UID:        addon.GetUID(),
+	}
+}
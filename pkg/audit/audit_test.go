@@ -0,0 +1,157 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/clock"
+	"k8s.io/client-go/kubernetes/fake"
+
+	addonmgrv1alpha1 "github.com/keikoproj/addon-manager/api/v1alpha1"
+)
+
+func newAuditAddon() *addonmgrv1alpha1.Addon {
+	return &addonmgrv1alpha1.Addon{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "audit-test",
+			Namespace: "default",
+		},
+		Spec: addonmgrv1alpha1.AddonSpec{
+			PackageSpec: addonmgrv1alpha1.PackageSpec{
+				PkgName:    "audit-test",
+				PkgVersion: "1.0.0",
+				PkgType:    addonmgrv1alpha1.CompositePkg,
+			},
+			Params: addonmgrv1alpha1.AddonParams{
+				Namespace: "audit-ns",
+			},
+		},
+	}
+}
+
+func TestRecorder_Record(t *testing.T) {
+	g := NewGomegaWithT(t)
+	ctx := context.TODO()
+	client := fake.NewSimpleClientset()
+	r := NewAuditRecorder(client)
+	fakeClock := clock.NewFakeClock(time.Date(2020, 10, 1, 10, 0, 0, 0, time.UTC))
+	r.SetClock(fakeClock)
+	a := newAuditAddon()
+
+	entry, err := r.Record(ctx, a)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(entry).ToNot(BeNil())
+	g.Expect(entry.Time.Time).To(Equal(fakeClock.Now()))
+	g.Expect(entry.PreviousChecksum).To(BeEmpty())
+	g.Expect(entry.Checksum).To(Equal(a.CalculateChecksum()))
+
+	// No change, nothing recorded
+	entry, err = r.Record(ctx, a)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(entry).To(BeNil())
+
+	previous := a.CalculateChecksum()
+	now := metav1.NewTime(time.Now())
+	a.Spec.PkgVersion = "1.0.1"
+	a.Spec.Params.Data = map[string]addonmgrv1alpha1.FlexString{"foo": "bar"}
+	a.SetManagedFields([]metav1.ManagedFieldsEntry{
+		{Manager: "kubectl", Operation: metav1.ManagedFieldsOperationUpdate, Time: &now, FieldsV1: &metav1.FieldsV1{Raw: []byte(`{"f:spec":{"f:pkgVersion":{}}}`)}},
+		{Manager: "addon-manager", Operation: metav1.ManagedFieldsOperationUpdate, Time: &now, FieldsV1: &metav1.FieldsV1{Raw: []byte(`{"f:status":{}}`)}},
+	})
+
+	entry, err = r.Record(ctx, a)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(entry).ToNot(BeNil())
+	g.Expect(entry.PreviousChecksum).To(Equal(previous))
+	g.Expect(entry.Manager).To(Equal("kubectl"))
+	g.Expect(entry.Changed).To(Equal([]string{"params.data", "pkgVersion"}))
+	g.Expect(entry.String()).To(ContainSubstring("kubectl"))
+
+	cm, err := client.CoreV1().ConfigMaps("default").Get(ctx, ConfigMapName(a), metav1.GetOptions{})
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(cm.OwnerReferences).To(HaveLen(1))
+	g.Expect(cm.Labels).To(HaveKeyWithValue(AddonLabel, "audit-test"))
+
+	// Only digests of the spec are kept, never its values
+	for _, v := range cm.Data {
+		g.Expect(v).ToNot(ContainSubstring("audit-ns"))
+		g.Expect(v).ToNot(ContainSubstring(`"bar"`))
+	}
+
+	var history []Entry
+	g.Expect(json.Unmarshal([]byte(cm.Data[historyKey]), &history)).To(Succeed())
+	g.Expect(history).To(HaveLen(2))
+}
+
+func TestRecorder_RecordForeignConfigMap(t *testing.T) {
+	g := NewGomegaWithT(t)
+	ctx := context.TODO()
+	a := newAuditAddon()
+	client := fake.NewSimpleClientset(&v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: ConfigMapName(a), Namespace: "default"}})
+	r := NewAuditRecorder(client)
+
+	_, err := r.Record(ctx, a)
+	g.Expect(err).To(MatchError(ContainSubstring("is not an audit trail")))
+}
+
+func TestRecorder_RecordMaxEntries(t *testing.T) {
+	g := NewGomegaWithT(t)
+	ctx := context.TODO()
+	client := fake.NewSimpleClientset()
+	r := NewAuditRecorder(client)
+	a := newAuditAddon()
+
+	for i := 0; i < MaxEntries+5; i++ {
+		a.Spec.Params.Data = map[string]addonmgrv1alpha1.FlexString{"i": addonmgrv1alpha1.FlexString(string(rune('a' + i)))}
+		_, err := r.Record(ctx, a)
+		g.Expect(err).ToNot(HaveOccurred())
+	}
+
+	cm, err := client.CoreV1().ConfigMaps("default").Get(ctx, ConfigMapName(a), metav1.GetOptions{})
+	g.Expect(err).ToNot(HaveOccurred())
+
+	var history []Entry
+	g.Expect(json.Unmarshal([]byte(cm.Data[historyKey]), &history)).To(Succeed())
+	g.Expect(history).To(HaveLen(MaxEntries))
+}
+
+func TestChangedDigests(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	previous, err := fieldDigests([]byte(`{"a":"1","b":{"c":1,"d":2},"f":"1"}`))
+	g.Expect(err).ToNot(HaveOccurred())
+	current, err := fieldDigests([]byte(`{"a":"1","b":{"c":1,"d":3},"e":true}`))
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(changedDigests(previous, current)).To(Equal([]string{"b.d", "e", "f"}))
+	g.Expect(current["a"]).ToNot(Equal(previous["f"]))
+}
+
+func TestChangedFields(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	changed, err := ChangedFields([]byte(`{"a":"1","b":{"c":1,"d":2}}`), []byte(`{"a":"1","b":{"c":1,"d":3},"e":true}`))
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(changed).To(Equal([]string{"b.d", "e"}))
+
+	_, err = ChangedFields([]byte(`{`), []byte(`{}`))
+	g.Expect(err).To(HaveOccurred())
+}
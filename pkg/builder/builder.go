@@ -0,0 +1,290 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package builder builds Addon specs in Go, so pipelines generating addons do not assemble raw structs or YAML
+//
+//	a, err := builder.NewAddon().
+//		WithName("event-router").
+//		WithPackage("event-router", "v0.2", addonmgrv1alpha1.CompositePkg).
+//		WithTargetNamespace("event-router").
+//		WithInstallTemplate(install).
+//		Build()
+package builder
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Masterminds/semver/v3"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+
+	addonmgrv1alpha1 "github.com/keikoproj/addon-manager/api/v1alpha1"
+	"github.com/keikoproj/addon-manager/pkg/addon"
+)
+
+// MaxNameLength is the longest addon name, workflow names are derived from it
+const MaxNameLength = 31
+
+// AddonBuilder builds an Addon, the With methods return the builder so calls can be chained
+type AddonBuilder struct {
+	addon *addonmgrv1alpha1.Addon
+}
+
+// NewAddon returns a builder of an empty Addon
+func NewAddon() *AddonBuilder {
+	return &AddonBuilder{
+		addon: &addonmgrv1alpha1.Addon{
+			TypeMeta: metav1.TypeMeta{
+				Kind:       "Addon",
+				APIVersion: addonmgrv1alpha1.GroupVersion.String(),
+			},
+		},
+	}
+}
+
+// WithName sets the addon name
+func (b *AddonBuilder) WithName(name string) *AddonBuilder {
+	b.addon.Name = name
+	return b
+}
+
+// WithNamespace sets the namespace the addon object is created in
+func (b *AddonBuilder) WithNamespace(namespace string) *AddonBuilder {
+	b.addon.Namespace = namespace
+	return b
+}
+
+// WithLabel adds a label to the addon
+func (b *AddonBuilder) WithLabel(key, value string) *AddonBuilder {
+	if b.addon.Labels == nil {
+		b.addon.Labels = map[string]string{}
+	}
+	b.addon.Labels[key] = value
+	return b
+}
+
+// WithAnnotation adds an annotation to the addon
+func (b *AddonBuilder) WithAnnotation(key, value string) *AddonBuilder {
+	if b.addon.Annotations == nil {
+		b.addon.Annotations = map[string]string{}
+	}
+	b.addon.Annotations[key] = value
+	return b
+}
+
+// WithPackage sets the package name, version and type
+func (b *AddonBuilder) WithPackage(name, version string, pkgType addonmgrv1alpha1.PackageType) *AddonBuilder {
+	b.addon.Spec.PkgName = name
+	b.addon.Spec.PkgVersion = version
+	b.addon.Spec.PkgType = pkgType
+	return b
+}
+
+// WithDescription sets the package description
+func (b *AddonBuilder) WithDescription(description string) *AddonBuilder {
+	b.addon.Spec.PkgDescription = description
+	return b
+}
+
+// WithChannel sets the package channel
+func (b *AddonBuilder) WithChannel(channel string) *AddonBuilder {
+	b.addon.Spec.PkgChannel = channel
+	return b
+}
+
+// WithDependency adds a package dependency, constraint is a semver range or * for any version
+func (b *AddonBuilder) WithDependency(pkgName, constraint string) *AddonBuilder {
+	if b.addon.Spec.PkgDeps == nil {
+		b.addon.Spec.PkgDeps = map[string]string{}
+	}
+	b.addon.Spec.PkgDeps[pkgName] = constraint
+	return b
+}
+
+// WithCatalog installs the package from the AddonCatalog named catalog, the version may then be omitted
+func (b *AddonBuilder) WithCatalog(catalog string) *AddonBuilder {
+	b.addon.Spec.Catalog = catalog
+	return b
+}
+
+// WithKubeVersion sets the semver range of Kubernetes versions the package supports
+func (b *AddonBuilder) WithKubeVersion(kubeVersion string) *AddonBuilder {
+	b.addon.Spec.KubeVersion = kubeVersion
+	return b
+}
+
+// WithTargetNamespace sets the namespace the package is installed in
+func (b *AddonBuilder) WithTargetNamespace(namespace string) *AddonBuilder {
+	b.addon.Spec.Params.Namespace = namespace
+	return b
+}
+
+// WithClusterContext sets the cluster name and region passed to the workflows
+func (b *AddonBuilder) WithClusterContext(clusterName, clusterRegion string) *AddonBuilder {
+	b.addon.Spec.Params.Context.ClusterName = clusterName
+	b.addon.Spec.Params.Context.ClusterRegion = clusterRegion
+	return b
+}
+
+// WithParam adds a data parameter injected into the workflows
+func (b *AddonBuilder) WithParam(key, value string) *AddonBuilder {
+	if b.addon.Spec.Params.Data == nil {
+		b.addon.Spec.Params.Data = map[string]addonmgrv1alpha1.FlexString{}
+	}
+	b.addon.Spec.Params.Data[key] = addonmgrv1alpha1.FlexString(value)
+	return b
+}
+
+// WithSelector sets the labels of the resources the addon watches
+func (b *AddonBuilder) WithSelector(matchLabels map[string]string) *AddonBuilder {
+	b.addon.Spec.Selector = metav1.LabelSelector{MatchLabels: matchLabels}
+	return b
+}
+
+// WithStrategy sets how a new version of the addon replaces the installed one
+func (b *AddonBuilder) WithStrategy(strategy addonmgrv1alpha1.StrategyType) *AddonBuilder {
+	b.addon.Spec.Strategy = &addonmgrv1alpha1.InstallStrategy{Type: strategy}
+	return b
+}
+
+// WithMaintenanceWindow defers upgrades until a window opened by one of the cron schedules in UTC is open
+func (b *AddonBuilder) WithMaintenanceWindow(duration time.Duration, schedules ...string) *AddonBuilder {
+	b.addon.Spec.MaintenanceWindow = &addonmgrv1alpha1.MaintenanceWindow{
+		Schedules: schedules,
+		Duration:  metav1.Duration{Duration: duration},
+	}
+	return b
+}
+
+// WithPrereqsTemplate sets the workflow template of the prereqs step
+func (b *AddonBuilder) WithPrereqsTemplate(template string) *AddonBuilder {
+	b.addon.Spec.Lifecycle.Prereqs.Template = template
+	return b
+}
+
+// WithInstallTemplate sets the workflow template of the install step
+func (b *AddonBuilder) WithInstallTemplate(template string) *AddonBuilder {
+	b.addon.Spec.Lifecycle.Install.Template = template
+	return b
+}
+
+// WithDeleteTemplate sets the workflow template of the delete step
+func (b *AddonBuilder) WithDeleteTemplate(template string) *AddonBuilder {
+	b.addon.Spec.Lifecycle.Delete.Template = template
+	return b
+}
+
+// WithValidateTemplate sets the workflow template of the validate step
+func (b *AddonBuilder) WithValidateTemplate(template string) *AddonBuilder {
+	b.addon.Spec.Lifecycle.Validate.Template = template
+	return b
+}
+
+// WithValidateGate sets whether the validate workflow must succeed after install before the addon is Succeeded
+func (b *AddonBuilder) WithValidateGate(enabled bool) *AddonBuilder {
+	b.addon.Spec.Lifecycle.ValidateGate = &enabled
+	return b
+}
+
+// WithWorkflow sets the workflow of a lifecycle step, including its name prefix and roles
+func (b *AddonBuilder) WithWorkflow(step addonmgrv1alpha1.LifecycleStep, wt addonmgrv1alpha1.WorkflowType) *AddonBuilder {
+	switch step {
+	case addonmgrv1alpha1.Prereqs:
+		b.addon.Spec.Lifecycle.Prereqs = wt
+	case addonmgrv1alpha1.Install:
+		b.addon.Spec.Lifecycle.Install = wt
+	case addonmgrv1alpha1.Delete:
+		b.addon.Spec.Lifecycle.Delete = wt
+	case addonmgrv1alpha1.Validate:
+		b.addon.Spec.Lifecycle.Validate = wt
+	case addonmgrv1alpha1.Upgrade:
+		b.addon.Spec.Lifecycle.Upgrade = wt
+	}
+	return b
+}
+
+// Build validates the addon and returns a copy of it, the builder can be reused to build variants
+func (b *AddonBuilder) Build() (*addonmgrv1alpha1.Addon, error) {
+	if err := Validate(b.addon); err != nil {
+		return nil, err
+	}
+	return b.addon.DeepCopy(), nil
+}
+
+// MustBuild is Build and panics when the addon is invalid
+func (b *AddonBuilder) MustBuild() *addonmgrv1alpha1.Addon {
+	a, err := b.Build()
+	if err != nil {
+		panic(err)
+	}
+	return a
+}
+
+// Validate returns an error when the addon spec would be rejected by the addon manager, checks requiring the
+// cluster state such as installed dependencies are left to the manager
+func Validate(a *addonmgrv1alpha1.Addon) error {
+	if a.Name == "" {
+		return fmt.Errorf("addon name is empty")
+	}
+	if len(a.Name) > MaxNameLength {
+		return fmt.Errorf("addon name %s must be less than %d characters", a.Name, MaxNameLength+1)
+	}
+	if errs := validation.IsDNS1123Subdomain(a.Name); len(errs) > 0 {
+		return fmt.Errorf("invalid addon name %s, %s", a.Name, errs[0])
+	}
+
+	if a.Spec.PkgName == "" {
+		return fmt.Errorf("pkgName is empty in addon.spec.pkgName")
+	}
+	if a.Spec.PkgVersion == "" && a.Spec.Catalog == "" {
+		return fmt.Errorf("pkgVersion is empty in addon.spec.pkgVersion and the addon is not installed from a catalog")
+	}
+	switch a.Spec.PkgType {
+	case "", addonmgrv1alpha1.HelmPkg, addonmgrv1alpha1.ShipPkg, addonmgrv1alpha1.KustomizePkg, addonmgrv1alpha1.CnabPkg, addonmgrv1alpha1.CompositePkg:
+	default:
+		return fmt.Errorf("unknown pkgType %q", a.Spec.PkgType)
+	}
+	for name, constraint := range a.Spec.PkgDeps {
+		if name == "" || constraint == "" {
+			return fmt.Errorf("invalid dependency %q:%q, package name and version are required", name, constraint)
+		}
+	}
+	if a.Spec.KubeVersion != "" {
+		if _, err := semver.NewConstraint(a.Spec.KubeVersion); err != nil {
+			return fmt.Errorf("invalid kube version range %q. %v", a.Spec.KubeVersion, err)
+		}
+	}
+
+	if a.Spec.Params.Namespace == "" {
+		return fmt.Errorf("namespace is empty in addon.spec.params.namespace")
+	}
+	if errs := validation.IsDNS1123Label(a.Spec.Params.Namespace); len(errs) > 0 {
+		return fmt.Errorf("invalid namespace %s, %s", a.Spec.Params.Namespace, errs[0])
+	}
+
+	for _, wt := range []addonmgrv1alpha1.WorkflowType{a.Spec.Lifecycle.Prereqs, a.Spec.Lifecycle.Install, a.Spec.Lifecycle.Delete, a.Spec.Lifecycle.Validate} {
+		if len(wt.NamePrefix) > 10 {
+			return fmt.Errorf("workflow namePrefix %s must be at most 10 characters", wt.NamePrefix)
+		}
+	}
+
+	if w := a.Spec.MaintenanceWindow; w != nil {
+		if err := addon.ValidateMaintenanceWindow(w); err != nil {
+			return err
+		}
+	}
+
+	return addon.ValidateWorkflows(a)
+}
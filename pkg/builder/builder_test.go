@@ -0,0 +1,121 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package builder
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+
+	addonmgrv1alpha1 "github.com/keikoproj/addon-manager/api/v1alpha1"
+)
+
+const installTemplate = `
+apiVersion: argoproj.io/v1alpha1
+kind: Workflow
+spec:
+  entrypoint: entry
+  arguments:
+    parameters:
+    - name: image
+      value: nginx
+  templates:
+  - name: entry
+    container:
+      image: "{{workflow.parameters.image}}"
+`
+
+func newBuilder() *AddonBuilder {
+	return NewAddon().
+		WithName("event-router").
+		WithNamespace("addon-manager-system").
+		WithPackage("event-router", "v0.2", addonmgrv1alpha1.CompositePkg).
+		WithTargetNamespace("event-router").
+		WithInstallTemplate(installTemplate)
+}
+
+func TestAddonBuilder_Build(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	b := newBuilder().
+		WithLabel("team", "platform").
+		WithDependency("core/cert-manager", "^1.0").
+		WithParam("replicas", "2").
+		WithClusterContext("cluster-a", "us-west-2").
+		WithKubeVersion(">=1.18").
+		WithValidateGate(true)
+
+	a, err := b.Build()
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(a.APIVersion).To(Equal("addonmgr.keikoproj.io/v1alpha1"))
+	g.Expect(a.Kind).To(Equal("Addon"))
+	g.Expect(a.Name).To(Equal("event-router"))
+	g.Expect(a.Namespace).To(Equal("addon-manager-system"))
+	g.Expect(a.Labels).To(HaveKeyWithValue("team", "platform"))
+	g.Expect(a.Spec.PkgName).To(Equal("event-router"))
+	g.Expect(a.Spec.PkgDeps).To(HaveKeyWithValue("core/cert-manager", "^1.0"))
+	g.Expect(a.Spec.Params.Data).To(HaveKeyWithValue("replicas", addonmgrv1alpha1.FlexString("2")))
+	g.Expect(a.Spec.Params.Context.ClusterName).To(Equal("cluster-a"))
+	g.Expect(a.Spec.Lifecycle.Install.Template).To(Equal(installTemplate))
+	g.Expect(*a.Spec.Lifecycle.ValidateGate).To(BeTrue())
+
+	// Builds are independent copies
+	b.WithParam("replicas", "3")
+	g.Expect(a.Spec.Params.Data).To(HaveKeyWithValue("replicas", addonmgrv1alpha1.FlexString("2")))
+}
+
+func TestAddonBuilder_BuildInvalid(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	tests := []struct {
+		name    string
+		builder *AddonBuilder
+		err     string
+	}{
+		{"no name", newBuilder().WithName(""), "addon name is empty"},
+		{"long name", newBuilder().WithName("a-very-long-addon-name-over-the-limit"), "must be less than 32 characters"},
+		{"bad name", newBuilder().WithName("Event_Router"), "invalid addon name"},
+		{"no package", newBuilder().WithPackage("", "v0.2", addonmgrv1alpha1.CompositePkg), "pkgName is empty"},
+		{"no version", newBuilder().WithPackage("event-router", "", addonmgrv1alpha1.CompositePkg), "pkgVersion is empty"},
+		{"bad type", newBuilder().WithPackage("event-router", "v0.2", "zip"), "unknown pkgType"},
+		{"no namespace", newBuilder().WithTargetNamespace(""), "namespace is empty"},
+		{"bad kube version", newBuilder().WithKubeVersion("not a range"), "invalid kube version range"},
+		{"bad template", newBuilder().WithInstallTemplate("kind: Pod\nspec: {}"), "invalid workflow"},
+		{"param overlap", newBuilder().WithParam("image", "busybox"), "parameter named \"image\""},
+		{"long prefix", newBuilder().WithWorkflow(addonmgrv1alpha1.Delete, addonmgrv1alpha1.WorkflowType{NamePrefix: "delete-workflow"}), "namePrefix"},
+		{"bad window", newBuilder().WithMaintenanceWindow(time.Hour, "0 25 * * *"), "invalid schedule"},
+	}
+
+	for _, tt := range tests {
+		_, err := tt.builder.Build()
+		g.Expect(err).To(MatchError(ContainSubstring(tt.err)), tt.name)
+	}
+
+	g.Expect(func() { newBuilder().WithName("").MustBuild() }).To(Panic())
+}
+
+func TestAddonBuilder_BuildFromCatalog(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	a, err := NewAddon().
+		WithName("event-router").
+		WithPackage("event-router", "", "").
+		WithCatalog("core").
+		WithTargetNamespace("event-router").
+		Build()
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(a.Spec.Catalog).To(Equal("core"))
+}
@@ -0,0 +1,102 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package catalog
+
+import (
+	"context"
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/yaml"
+
+	addonmgrv1alpha1 "github.com/keikoproj/addon-manager/api/v1alpha1"
+)
+
+// CatalogLabel selects the ConfigMaps holding catalog addons
+const CatalogLabel = "addonmgr.keikoproj.io/catalog"
+
+// Catalog reads the addons that can be installed as dependencies from the ConfigMaps labelled with CatalogLabel
+// in a namespace, every key of a ConfigMap is an Addon manifest with default params
+type Catalog struct {
+	client    kubernetes.Interface
+	namespace string
+}
+
+// NewCatalog returns a catalog of the ConfigMaps in namespace
+func NewCatalog(client kubernetes.Interface, namespace string) *Catalog {
+	return &Catalog{client: client, namespace: namespace}
+}
+
+// Snapshot is the content of the catalog when it was loaded
+type Snapshot struct {
+	addons       map[string][]*addonmgrv1alpha1.Addon
+	channels     map[string]map[string]string
+	descriptions map[string]string
+	// deprecations are keyed by package name and by pkgName:pkgVersion
+	deprecations map[string]*addonmgrv1alpha1.Deprecation
+	revision     string
+}
+
+func newSnapshot() *Snapshot {
+	return &Snapshot{
+		addons:       make(map[string][]*addonmgrv1alpha1.Addon),
+		channels:     make(map[string]map[string]string),
+		descriptions: make(map[string]string),
+		deprecations: make(map[string]*addonmgrv1alpha1.Deprecation),
+	}
+}
+
+// Load reads the catalog ConfigMaps
+func (c *Catalog) Load(ctx context.Context) (*Snapshot, error) {
+	list, err := c.client.CoreV1().ConfigMaps(c.namespace).List(ctx, metav1.ListOptions{LabelSelector: CatalogLabel})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list catalog config maps. %v", err)
+	}
+
+	s := newSnapshot()
+	for _, cm := range list.Items {
+		for key, data := range cm.Data {
+			a := &addonmgrv1alpha1.Addon{}
+			if err := yaml.Unmarshal([]byte(data), a); err != nil {
+				return nil, fmt.Errorf("invalid catalog addon %s/%s. %v", cm.Name, key, err)
+			}
+			if a.Name == "" || a.Spec.PkgName == "" || a.Spec.PkgVersion == "" {
+				return nil, fmt.Errorf("invalid catalog addon %s/%s, name, pkgName and pkgVersion are required", cm.Name, key)
+			}
+			s.addons[a.Spec.PkgName] = append(s.addons[a.Spec.PkgName], a)
+		}
+	}
+	return s, nil
+}
+
+// Versions implements deps.Catalog
+func (s *Snapshot) Versions(pkgName string) []addonmgrv1alpha1.PackageSpec {
+	specs := make([]addonmgrv1alpha1.PackageSpec, 0, len(s.addons[pkgName]))
+	for _, a := range s.addons[pkgName] {
+		specs = append(specs, a.GetPackageSpec())
+	}
+	return specs
+}
+
+// Addon returns a copy of the catalog addon of the package version, nil if there is none
+func (s *Snapshot) Addon(pkgName, pkgVersion string) *addonmgrv1alpha1.Addon {
+	for _, a := range s.addons[pkgName] {
+		if a.Spec.PkgVersion == pkgVersion {
+			return a.DeepCopy()
+		}
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package catalog
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	addonmgrv1alpha1 "github.com/keikoproj/addon-manager/api/v1alpha1"
+	"github.com/keikoproj/addon-manager/pkg/deps"
+)
+
+const certManagerAddon = `
+apiVersion: addonmgr.keikoproj.io/v1alpha1
+kind: Addon
+metadata:
+  name: cert-manager
+spec:
+  pkgName: core/cert-manager
+  pkgVersion: %s
+  pkgType: composite
+  params:
+    namespace: cert-manager
+`
+
+func newCatalogConfigMap(name string, labels map[string]string, data map[string]string) *v1.ConfigMap {
+	return &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "addon-catalog", Labels: labels},
+		Data:       data,
+	}
+}
+
+func TestCatalog_Load(t *testing.T) {
+	g := NewGomegaWithT(t)
+	ctx := context.TODO()
+
+	client := fake.NewSimpleClientset(
+		newCatalogConfigMap("cert-manager", map[string]string{CatalogLabel: "true"}, map[string]string{
+			"v1.0.4": fmt.Sprintf(certManagerAddon, "v1.0.4"),
+			"v1.1.0": fmt.Sprintf(certManagerAddon, "v1.1.0"),
+		}),
+		newCatalogConfigMap("unrelated", nil, map[string]string{"key": "not an addon"}),
+	)
+
+	s, err := NewCatalog(client, "addon-catalog").Load(ctx)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(s.Versions("core/cert-manager")).To(HaveLen(2))
+	g.Expect(s.Versions("core/ingress")).To(BeEmpty())
+
+	a := s.Addon("core/cert-manager", "v1.1.0")
+	g.Expect(a).ToNot(BeNil())
+	g.Expect(a.Name).To(Equal("cert-manager"))
+	g.Expect(a.Spec.Params.Namespace).To(Equal("cert-manager"))
+	g.Expect(s.Addon("core/cert-manager", "v2.0.0")).To(BeNil())
+
+	// The snapshot is a dependency catalog
+	plan, err := deps.NewResolver(nil, s).Resolve(addonmgrv1alpha1.PackageSpec{
+		PkgName: "core/ingress", PkgVersion: "1.0.0", PkgDeps: map[string]string{"core/cert-manager": "~1.0"},
+	})
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(plan.Missing()).To(HaveLen(1))
+	g.Expect(plan.Missing()[0].PkgVersion).To(Equal("v1.0.4"))
+}
+
+func TestCatalog_LoadInvalid(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	client := fake.NewSimpleClientset(
+		newCatalogConfigMap("broken", map[string]string{CatalogLabel: "true"}, map[string]string{"addon": "spec: {pkgName: core/x}"}),
+	)
+	_, err := NewCatalog(client, "addon-catalog").Load(context.TODO())
+	g.Expect(err).To(MatchError(ContainSubstring("invalid catalog addon broken/addon")))
+}
@@ -0,0 +1,186 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package catalog
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	addonmgrv1alpha1 "github.com/keikoproj/addon-manager/api/v1alpha1"
+	"github.com/keikoproj/addon-manager/pkg/cosign"
+)
+
+const (
+	defaultRevision = "HEAD"
+	defaultPath     = "index.yaml"
+	maxIndexSize    = 10 << 20
+)
+
+// Credentials authenticate index requests, a token takes precedence over the username and password
+type Credentials struct {
+	Username string
+	Password string
+	Token    string
+}
+
+// TLS is the PEM encoded material of the TLS client of a source, the CA bundle is trusted in addition to the system
+// roots and the certificate and key are presented as client certificate
+type TLS struct {
+	CA   []byte
+	Cert []byte
+	Key  []byte
+}
+
+// Fetcher fetches the index of AddonCatalog sources
+type Fetcher struct {
+	client *http.Client
+}
+
+// NewFetcher returns a Fetcher
+func NewFetcher() *Fetcher {
+	return &Fetcher{client: &http.Client{Timeout: 30 * time.Second}}
+}
+
+// Fetch returns the index of the catalog source, creds and tlsMaterial may be nil
+func (f *Fetcher) Fetch(ctx context.Context, spec addonmgrv1alpha1.AddonCatalogSpec, creds *Credentials, tlsMaterial *TLS) ([]byte, error) {
+	client, transport := f.client, http.RoundTripper(nil)
+	if spec.TLS != nil || tlsMaterial != nil {
+		config, err := tlsConfig(spec.TLS, tlsMaterial)
+		if err != nil {
+			return nil, err
+		}
+		t := http.DefaultTransport.(*http.Transport).Clone()
+		t.TLSClientConfig = config
+		client, transport = &http.Client{Timeout: f.client.Timeout, Transport: t}, t
+	}
+
+	switch spec.Type {
+	case addonmgrv1alpha1.HTTPCatalog:
+		return get(ctx, client, spec.URL, creds)
+	case addonmgrv1alpha1.GitCatalog:
+		return get(ctx, client, RawURL(spec), creds)
+	case addonmgrv1alpha1.OCICatalog:
+		var registryCreds map[string]cosign.Credentials
+		if creds != nil {
+			host, err := cosign.RegistryHost(spec.URL)
+			if err != nil {
+				return nil, err
+			}
+			password := creds.Password
+			if creds.Token != "" {
+				password = creds.Token
+			}
+			registryCreds = map[string]cosign.Credentials{host: {Username: creds.Username, Password: password}}
+		}
+		puller := cosign.NewPuller(registryCreds)
+		if transport != nil {
+			puller.SetTransport(transport)
+		}
+		return puller.Pull(ctx, spec.URL)
+	}
+	return nil, fmt.Errorf("unsupported catalog type %q", spec.Type)
+}
+
+// tlsConfig returns the TLS client configuration of a source
+func tlsConfig(spec *addonmgrv1alpha1.CatalogTLS, material *TLS) (*tls.Config, error) {
+	config := &tls.Config{MinVersion: tls.VersionTLS12}
+	if spec != nil {
+		config.InsecureSkipVerify = spec.InsecureSkipVerify
+	}
+	if material == nil {
+		return config, nil
+	}
+
+	if len(material.CA) > 0 {
+		pool, err := x509.SystemCertPool()
+		if err != nil || pool == nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(material.CA) {
+			return nil, fmt.Errorf("invalid catalog CA bundle, no PEM certificate found")
+		}
+		config.RootCAs = pool
+	}
+	if len(material.Cert) > 0 || len(material.Key) > 0 {
+		cert, err := tls.X509KeyPair(material.Cert, material.Key)
+		if err != nil {
+			return nil, fmt.Errorf("invalid catalog client certificate. %v", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	return config, nil
+}
+
+// RawURL returns the url of the index file of a Git catalog. Files are read from the raw endpoint GitHub, GitLab,
+// Gitea and Bitbucket serve at <repository>/raw/<revision>/<path>, GitHub repositories are read from
+// raw.githubusercontent.com directly so credentials are not dropped by the redirect.
+func RawURL(spec addonmgrv1alpha1.AddonCatalogSpec) string {
+	repo := strings.TrimSuffix(strings.TrimSuffix(spec.URL, "/"), ".git")
+	revision, file := spec.Revision, strings.TrimPrefix(spec.Path, "/")
+	if revision == "" {
+		revision = defaultRevision
+	}
+	if file == "" {
+		file = defaultPath
+	}
+
+	if u, err := url.Parse(repo); err == nil && u.Host == "github.com" {
+		return fmt.Sprintf("https://raw.githubusercontent.com%s/%s/%s", u.Path, revision, file)
+	}
+	return fmt.Sprintf("%s/raw/%s/%s", repo, revision, file)
+}
+
+func get(ctx context.Context, client *http.Client, u string, creds *Credentials) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	if creds != nil && (creds.Token != "" || creds.Username != "") {
+		// Credentials are never sent in plain text
+		if req.URL.Scheme != "https" {
+			return nil, fmt.Errorf("catalog credentials require an https source, %s is not", u)
+		}
+		if creds.Token != "" {
+			req.Header.Set("Authorization", "Bearer "+creds.Token)
+		} else if creds.Username != "" {
+			req.SetBasicAuth(creds.Username, creds.Password)
+		}
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("catalog index request %s failed with status %d", u, resp.StatusCode)
+	}
+	data, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxIndexSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxIndexSize {
+		return nil, fmt.Errorf("catalog index %s is too large, the limit is %d bytes", u, maxIndexSize)
+	}
+	return data, nil
+}
@@ -0,0 +1,113 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package catalog
+
+import (
+	"bytes"
+	"context"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	addonmgrv1alpha1 "github.com/keikoproj/addon-manager/api/v1alpha1"
+)
+
+func TestFetcher_Fetch(t *testing.T) {
+	g := NewGomegaWithT(t)
+	ctx := context.TODO()
+
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/index.yaml", "/org/catalog/raw/v1/catalog/index.yaml":
+			w.Write([]byte(testIndex))
+		case "/large.yaml":
+			w.Write(bytes.Repeat([]byte("#"), maxIndexSize+1))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	f := NewFetcher()
+	f.client = srv.Client()
+	creds := &Credentials{Token: "secret"}
+
+	data, err := f.Fetch(ctx, addonmgrv1alpha1.AddonCatalogSpec{Type: addonmgrv1alpha1.HTTPCatalog, URL: srv.URL + "/index.yaml"}, creds, nil)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(string(data)).To(Equal(testIndex))
+
+	data, err = f.Fetch(ctx, addonmgrv1alpha1.AddonCatalogSpec{Type: addonmgrv1alpha1.GitCatalog, URL: srv.URL + "/org/catalog.git", Revision: "v1", Path: "/catalog/index.yaml"}, creds, nil)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(string(data)).To(Equal(testIndex))
+
+	_, err = f.Fetch(ctx, addonmgrv1alpha1.AddonCatalogSpec{Type: addonmgrv1alpha1.HTTPCatalog, URL: srv.URL + "/index.yaml"}, nil, nil)
+	g.Expect(err).To(MatchError(ContainSubstring("failed with status 401")))
+
+	_, err = f.Fetch(ctx, addonmgrv1alpha1.AddonCatalogSpec{Type: addonmgrv1alpha1.HTTPCatalog, URL: srv.URL + "/large.yaml"}, creds, nil)
+	g.Expect(err).To(MatchError(ContainSubstring("is too large")))
+
+	// Credentials are not sent to plain http sources
+	_, err = f.Fetch(ctx, addonmgrv1alpha1.AddonCatalogSpec{Type: addonmgrv1alpha1.HTTPCatalog, URL: "http://catalog.example.com/index.yaml"}, creds, nil)
+	g.Expect(err).To(MatchError(ContainSubstring("require an https source")))
+
+	_, err = f.Fetch(ctx, addonmgrv1alpha1.AddonCatalogSpec{Type: "S3", URL: "s3://bucket/index.yaml"}, nil, nil)
+	g.Expect(err).To(MatchError(ContainSubstring("unsupported catalog type")))
+}
+
+func TestFetcher_FetchTLS(t *testing.T) {
+	g := NewGomegaWithT(t)
+	ctx := context.TODO()
+
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(testIndex))
+	}))
+	defer srv.Close()
+	ca := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+	spec := addonmgrv1alpha1.AddonCatalogSpec{Type: addonmgrv1alpha1.HTTPCatalog, URL: srv.URL + "/index.yaml"}
+	f := NewFetcher()
+
+	// Servers signed by private CAs are not trusted by default
+	_, err := f.Fetch(ctx, spec, nil, nil)
+	g.Expect(err).To(HaveOccurred())
+
+	data, err := f.Fetch(ctx, spec, nil, &TLS{CA: ca})
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(string(data)).To(Equal(testIndex))
+
+	spec.TLS = &addonmgrv1alpha1.CatalogTLS{InsecureSkipVerify: true}
+	_, err = f.Fetch(ctx, spec, nil, nil)
+	g.Expect(err).ToNot(HaveOccurred())
+
+	_, err = f.Fetch(ctx, spec, nil, &TLS{CA: []byte("not a certificate")})
+	g.Expect(err).To(MatchError(ContainSubstring("invalid catalog CA bundle")))
+	_, err = f.Fetch(ctx, spec, nil, &TLS{Cert: ca})
+	g.Expect(err).To(MatchError(ContainSubstring("invalid catalog client certificate")))
+}
+
+func TestRawURL(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	g.Expect(RawURL(addonmgrv1alpha1.AddonCatalogSpec{URL: "https://github.com/org/catalog.git"})).
+		To(Equal("https://raw.githubusercontent.com/org/catalog/HEAD/index.yaml"))
+	g.Expect(RawURL(addonmgrv1alpha1.AddonCatalogSpec{URL: "https://gitlab.com/org/catalog/", Revision: "main", Path: "addons/index.yaml"})).
+		To(Equal("https://gitlab.com/org/catalog/raw/main/addons/index.yaml"))
+}
@@ -0,0 +1,227 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package catalog
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"path"
+	"sort"
+
+	"github.com/Masterminds/semver/v3"
+	"sigs.k8s.io/yaml"
+
+	addonmgrv1alpha1 "github.com/keikoproj/addon-manager/api/v1alpha1"
+	"github.com/keikoproj/addon-manager/pkg/deps"
+)
+
+// Index is the document listing the packages of an AddonCatalog source
+type Index struct {
+	Packages []IndexPackage `json:"packages"`
+}
+
+// IndexPackage is a package of the index with the addon template of each of its versions
+type IndexPackage struct {
+	Name        string            `json:"name"`
+	Description string            `json:"description,omitempty"`
+	Channels    map[string]string `json:"channels,omitempty"`
+	Versions    []IndexVersion    `json:"versions"`
+	// Deprecation applies to every version of the package
+	Deprecation *addonmgrv1alpha1.Deprecation `json:"deprecation,omitempty"`
+}
+
+// IndexVersion is the addon template of a package version
+type IndexVersion struct {
+	Version     string                        `json:"version"`
+	Template    addonmgrv1alpha1.AddonSpec    `json:"template"`
+	Deprecation *addonmgrv1alpha1.Deprecation `json:"deprecation,omitempty"`
+}
+
+// ParseIndex parses a YAML or JSON index, addons of the templates are named after the last element of the package name.
+// The revision of the snapshot is the sha256 digest of the index.
+func ParseIndex(data []byte) (*Snapshot, error) {
+	var index Index
+	if err := yaml.Unmarshal(data, &index); err != nil {
+		return nil, fmt.Errorf("invalid catalog index. %v", err)
+	}
+
+	s := newSnapshot()
+	for _, pkg := range index.Packages {
+		if pkg.Name == "" {
+			return nil, fmt.Errorf("invalid catalog index, package name is required")
+		}
+		for _, v := range pkg.Versions {
+			if v.Version == "" {
+				return nil, fmt.Errorf("invalid catalog index, package %s has a version without name", pkg.Name)
+			}
+			a := &addonmgrv1alpha1.Addon{Spec: v.Template}
+			a.Name = path.Base(pkg.Name)
+			a.Spec.PkgName = pkg.Name
+			a.Spec.PkgVersion = v.Version
+			if a.Spec.PkgDescription == "" {
+				a.Spec.PkgDescription = pkg.Description
+			}
+			s.addons[pkg.Name] = append(s.addons[pkg.Name], a)
+			if v.Deprecation != nil {
+				s.deprecations[pkg.Name+":"+v.Version] = v.Deprecation
+			}
+		}
+		if pkg.Deprecation != nil {
+			s.deprecations[pkg.Name] = pkg.Deprecation
+		}
+		for channel, version := range pkg.Channels {
+			if s.Addon(pkg.Name, version) == nil {
+				return nil, fmt.Errorf("invalid catalog index, channel %s of package %s points at unknown version %s", channel, pkg.Name, version)
+			}
+		}
+		s.channels[pkg.Name] = pkg.Channels
+		s.descriptions[pkg.Name] = pkg.Description
+	}
+	sum := sha256.Sum256(data)
+	s.revision = "sha256:" + hex.EncodeToString(sum[:])
+	return s, nil
+}
+
+// Revision identifies the index the snapshot was parsed from, it is empty for catalog ConfigMaps
+func (s *Snapshot) Revision() string {
+	return s.revision
+}
+
+// Deprecation returns the deprecation of the package version, or else of the package, nil if neither is deprecated
+func (s *Snapshot) Deprecation(pkgName, pkgVersion string) *addonmgrv1alpha1.Deprecation {
+	if d, ok := s.deprecations[pkgName+":"+pkgVersion]; ok {
+		return d.DeepCopy()
+	}
+	if d, ok := s.deprecations[pkgName]; ok {
+		return d.DeepCopy()
+	}
+	return nil
+}
+
+// Packages summarizes the packages of the snapshot in name order
+func (s *Snapshot) Packages() []addonmgrv1alpha1.CatalogPackage {
+	packages := make([]addonmgrv1alpha1.CatalogPackage, 0, len(s.addons))
+	for name := range s.addons {
+		pkg := addonmgrv1alpha1.CatalogPackage{Name: name, Description: s.descriptions[name], Channels: s.channels[name], Deprecation: s.deprecations[name]}
+		for _, p := range s.sorted(name) {
+			pkg.Versions = append(pkg.Versions, p.PkgVersion)
+		}
+		packages = append(packages, pkg)
+	}
+	sort.Slice(packages, func(i, j int) bool { return packages[i].Name < packages[j].Name })
+	return packages
+}
+
+// Render returns the spec of an addon installed from the catalog. The type, description, dependencies, supported
+// Kubernetes versions and lifecycle come from the template of the package version, the template params, selector
+// and secrets are defaults. Addons following a channel get the channel version, others default to the latest
+// version and are upgraded within their auto update policy.
+func (s *Snapshot) Render(a *addonmgrv1alpha1.Addon) (*addonmgrv1alpha1.AddonSpec, error) {
+	version := a.Spec.PkgVersion
+	switch {
+	case a.Spec.PkgChannel != "":
+		version = s.channels[a.Spec.PkgName][a.Spec.PkgChannel]
+		if version == "" {
+			return nil, fmt.Errorf("channel %s of package %s not found in catalog", a.Spec.PkgChannel, a.Spec.PkgName)
+		}
+	case version == "":
+		if sorted := s.sorted(a.Spec.PkgName); len(sorted) > 0 {
+			version = sorted[0].PkgVersion
+		}
+	case a.Spec.AutoUpdate != nil:
+		var err error
+		if version, err = s.update(a.Spec.PkgName, version, a.Spec.AutoUpdate); err != nil {
+			return nil, err
+		}
+	}
+
+	t := s.Addon(a.Spec.PkgName, version)
+	if t == nil {
+		return nil, fmt.Errorf("package %s:%s not found in catalog", a.Spec.PkgName, version)
+	}
+
+	spec := a.Spec.DeepCopy()
+	spec.PkgVersion = t.Spec.PkgVersion
+	spec.PkgType = t.Spec.PkgType
+	spec.PkgDescription = t.Spec.PkgDescription
+	spec.PkgDeps = t.Spec.PkgDeps
+	spec.Lifecycle = t.Spec.Lifecycle
+	spec.KubeVersion = t.Spec.KubeVersion
+
+	if spec.Params.Namespace == "" {
+		spec.Params.Namespace = t.Spec.Params.Namespace
+	}
+	if spec.Params.Context.IsEmpty() {
+		spec.Params.Context = t.Spec.Params.Context
+	}
+	for k, v := range t.Spec.Params.Data {
+		if _, ok := spec.Params.Data[k]; !ok {
+			if spec.Params.Data == nil {
+				spec.Params.Data = make(map[string]addonmgrv1alpha1.FlexString)
+			}
+			spec.Params.Data[k] = v
+		}
+	}
+	if len(spec.Selector.MatchLabels) == 0 && len(spec.Selector.MatchExpressions) == 0 {
+		spec.Selector = t.Spec.Selector
+	}
+	if len(spec.Secrets) == 0 {
+		spec.Secrets = t.Spec.Secrets
+	}
+
+	return spec, nil
+}
+
+// update returns the highest version of the package the installed version can be upgraded to within the policy,
+// versions that are not semver are not upgraded
+func (s *Snapshot) update(pkgName, installed string, policy *addonmgrv1alpha1.AutoUpdatePolicy) (string, error) {
+	current, err := semver.NewVersion(installed)
+	if err != nil {
+		return installed, nil
+	}
+
+	var allowed string
+	switch policy.Policy {
+	case addonmgrv1alpha1.PatchUpdates:
+		allowed = fmt.Sprintf(">=%s, <%d.%d.0", current, current.Major(), current.Minor()+1)
+	case addonmgrv1alpha1.MinorUpdates:
+		allowed = fmt.Sprintf(">=%s, <%d.0.0", current, current.Major()+1)
+	default:
+		allowed = fmt.Sprintf(">=%s", current)
+	}
+	if policy.Ceiling != "" {
+		if _, err := semver.NewConstraint(policy.Ceiling); err != nil {
+			return "", fmt.Errorf("invalid auto update ceiling %q. %v", policy.Ceiling, err)
+		}
+	}
+
+	for _, p := range s.sorted(pkgName) {
+		if deps.Matches(allowed, p.PkgVersion) && (policy.Ceiling == "" || deps.Matches(policy.Ceiling, p.PkgVersion)) {
+			return p.PkgVersion, nil
+		}
+	}
+	return installed, nil
+}
+
+// sorted returns the package versions of the snapshot, highest first
+func (s *Snapshot) sorted(pkgName string) []deps.Package {
+	var packages []deps.Package
+	for _, spec := range s.Versions(pkgName) {
+		packages = append(packages, deps.Package{PackageSpec: spec})
+	}
+	deps.SortVersions(packages)
+	return packages
+}
@@ -0,0 +1,192 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package catalog
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+
+	addonmgrv1alpha1 "github.com/keikoproj/addon-manager/api/v1alpha1"
+)
+
+const testIndex = `
+packages:
+- name: core/cert-manager
+  description: Certificate management
+  channels:
+    stable: 1.0.4
+  versions:
+  - version: 1.0.4
+    template:
+      pkgType: composite
+      params:
+        namespace: cert-manager
+        data:
+          replicas: "1"
+          webhook: "true"
+      lifecycle:
+        install:
+          template: install-1.0.4
+  - version: 1.1.0
+    template:
+      pkgType: composite
+      pkgDeps:
+        core/crds: "*"
+      params:
+        namespace: cert-manager
+        data:
+          replicas: "2"
+      lifecycle:
+        install:
+          template: install-1.1.0
+`
+
+func TestParseIndex(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	s, err := ParseIndex([]byte(testIndex))
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(s.Packages()).To(Equal([]addonmgrv1alpha1.CatalogPackage{{
+		Name:        "core/cert-manager",
+		Description: "Certificate management",
+		Versions:    []string{"1.1.0", "1.0.4"},
+		Channels:    map[string]string{"stable": "1.0.4"},
+	}}))
+
+	g.Expect(s.Revision()).To(HavePrefix("sha256:"))
+	other, err := ParseIndex([]byte(testIndex + "\n"))
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(other.Revision()).ToNot(Equal(s.Revision()))
+
+	a := s.Addon("core/cert-manager", "1.0.4")
+	g.Expect(a.Name).To(Equal("cert-manager"))
+	g.Expect(a.Spec.PkgDescription).To(Equal("Certificate management"))
+
+	_, err = ParseIndex([]byte(`{"packages":[{"name":"core/x","channels":{"stable":"2.0.0"},"versions":[{"version":"1.0.0"}]}]}`))
+	g.Expect(err).To(MatchError(ContainSubstring("points at unknown version 2.0.0")))
+	_, err = ParseIndex([]byte(`{"packages":[{"versions":[]}]}`))
+	g.Expect(err).To(MatchError(ContainSubstring("package name is required")))
+}
+
+func TestSnapshot_Render(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	s, err := ParseIndex([]byte(testIndex))
+	g.Expect(err).ToNot(HaveOccurred())
+
+	a := &addonmgrv1alpha1.Addon{Spec: addonmgrv1alpha1.AddonSpec{
+		PackageSpec: addonmgrv1alpha1.PackageSpec{PkgName: "core/cert-manager"},
+		Catalog:     "addons",
+		Params:      addonmgrv1alpha1.AddonParams{Data: map[string]addonmgrv1alpha1.FlexString{"replicas": "3"}},
+	}}
+
+	// Latest version by default, addon params override template params
+	spec, err := s.Render(a)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(spec.PkgVersion).To(Equal("1.1.0"))
+	g.Expect(spec.PkgDeps).To(HaveKey("core/crds"))
+	g.Expect(spec.Lifecycle.Install.Template).To(Equal("install-1.1.0"))
+	g.Expect(spec.Params.Namespace).To(Equal("cert-manager"))
+	g.Expect(spec.Params.Data).To(Equal(map[string]addonmgrv1alpha1.FlexString{"replicas": "3"}))
+
+	// Rendering is idempotent
+	a.Spec = *spec
+	again, err := s.Render(a)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(again).To(Equal(spec))
+
+	// Channels move the version
+	a.Spec.PkgChannel = "stable"
+	spec, err = s.Render(a)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(spec.PkgVersion).To(Equal("1.0.4"))
+	g.Expect(spec.PkgDeps).To(BeEmpty())
+	g.Expect(spec.Params.Data).To(Equal(map[string]addonmgrv1alpha1.FlexString{"replicas": "3", "webhook": "true"}))
+
+	a.Spec.PkgChannel = "beta"
+	_, err = s.Render(a)
+	g.Expect(err).To(MatchError(ContainSubstring("channel beta of package core/cert-manager not found")))
+
+	a.Spec.PkgChannel, a.Spec.PkgVersion = "", "2.0.0"
+	_, err = s.Render(a)
+	g.Expect(err).To(MatchError(ContainSubstring("core/cert-manager:2.0.0 not found")))
+}
+
+func TestSnapshot_RenderAutoUpdate(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	var index = "packages:\n- name: core/ingress\n  versions:\n"
+	for _, v := range []string{"1.23.1", "1.23.4", "1.24.0", "1.25.0", "2.0.0", "2.1.0-rc.1"} {
+		index += "  - version: " + v + "\n    template:\n      pkgType: composite\n"
+	}
+	s, err := ParseIndex([]byte(index))
+	g.Expect(err).ToNot(HaveOccurred())
+
+	render := func(policy *addonmgrv1alpha1.AutoUpdatePolicy) string {
+		a := &addonmgrv1alpha1.Addon{Spec: addonmgrv1alpha1.AddonSpec{
+			PackageSpec: addonmgrv1alpha1.PackageSpec{PkgName: "core/ingress", PkgVersion: "1.23.1"},
+			Catalog:     "addons",
+			AutoUpdate:  policy,
+		}}
+		spec, err := s.Render(a)
+		g.Expect(err).ToNot(HaveOccurred())
+		return spec.PkgVersion
+	}
+
+	g.Expect(render(nil)).To(Equal("1.23.1"))
+	g.Expect(render(&addonmgrv1alpha1.AutoUpdatePolicy{Policy: addonmgrv1alpha1.PatchUpdates})).To(Equal("1.23.4"))
+	g.Expect(render(&addonmgrv1alpha1.AutoUpdatePolicy{Policy: addonmgrv1alpha1.MinorUpdates})).To(Equal("1.25.0"))
+	g.Expect(render(&addonmgrv1alpha1.AutoUpdatePolicy{Ceiling: "<1.25"})).To(Equal("1.24.0"))
+	g.Expect(render(&addonmgrv1alpha1.AutoUpdatePolicy{})).To(Equal("2.0.0"))
+
+	a := &addonmgrv1alpha1.Addon{Spec: addonmgrv1alpha1.AddonSpec{
+		PackageSpec: addonmgrv1alpha1.PackageSpec{PkgName: "core/ingress", PkgVersion: "1.23.1"},
+		AutoUpdate:  &addonmgrv1alpha1.AutoUpdatePolicy{Ceiling: "not a constraint"},
+	}}
+	_, err = s.Render(a)
+	g.Expect(err).To(MatchError(ContainSubstring("invalid auto update ceiling")))
+}
+
+func TestSnapshot_Deprecation(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	s, err := ParseIndex([]byte(`
+packages:
+- name: core/legacy
+  deprecation:
+    message: replaced by core/modern
+  versions:
+  - version: 1.0.0
+- name: core/modern
+  versions:
+  - version: 1.0.0
+    deprecation:
+      endOfLife: "2021-01-01T00:00:00Z"
+  - version: 2.0.0
+`))
+	g.Expect(err).ToNot(HaveOccurred())
+
+	g.Expect(s.Deprecation("core/legacy", "1.0.0").Message).To(Equal("replaced by core/modern"))
+	g.Expect(s.Deprecation("core/modern", "2.0.0")).To(BeNil())
+	d := s.Deprecation("core/modern", "1.0.0")
+	g.Expect(d).ToNot(BeNil())
+	g.Expect(d.IsEndOfLife(time.Date(2020, 12, 31, 0, 0, 0, 0, time.UTC))).To(BeFalse())
+	g.Expect(d.IsEndOfLife(time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC))).To(BeTrue())
+
+	g.Expect(s.Packages()[0].Deprecation).ToNot(BeNil())
+	g.Expect(s.Packages()[1].Deprecation).To(BeNil())
+}
@@ -0,0 +1,53 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package catalog
+
+import (
+	"sync"
+
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// Store holds the snapshots of the synced AddonCatalogs
+type Store struct {
+	sync.RWMutex
+	snapshots map[types.NamespacedName]*Snapshot
+}
+
+// NewStore returns an empty Store
+func NewStore() *Store {
+	return &Store{snapshots: make(map[types.NamespacedName]*Snapshot)}
+}
+
+// Set replaces the snapshot of a catalog
+func (s *Store) Set(catalog types.NamespacedName, snapshot *Snapshot) {
+	s.Lock()
+	defer s.Unlock()
+	s.snapshots[catalog] = snapshot
+}
+
+// Delete removes the snapshot of a catalog
+func (s *Store) Delete(catalog types.NamespacedName) {
+	s.Lock()
+	defer s.Unlock()
+	delete(s.snapshots, catalog)
+}
+
+// Get returns the snapshot of a catalog, nil until the catalog is synced
+func (s *Store) Get(catalog types.NamespacedName) *Snapshot {
+	s.RLock()
+	defer s.RUnlock()
+	return s.snapshots[catalog]
+}
@@ -0,0 +1,92 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package versioned is a client-go style clientset for the addon manager API, so tools can use typed Addons
+// instead of unstructured objects
+package versioned
+
+import (
+	"fmt"
+
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/util/flowcontrol"
+
+	addonmgrv1alpha1 "github.com/keikoproj/addon-manager/pkg/client/clientset/versioned/typed/addonmgr/v1alpha1"
+)
+
+// Interface is the addon manager clientset
+type Interface interface {
+	Discovery() discovery.DiscoveryInterface
+	AddonmgrV1alpha1() addonmgrv1alpha1.AddonmgrV1alpha1Interface
+}
+
+// Clientset contains the clients of the addon manager API groups
+type Clientset struct {
+	*discovery.DiscoveryClient
+	addonmgrV1alpha1 *addonmgrv1alpha1.AddonmgrV1alpha1Client
+}
+
+// AddonmgrV1alpha1 returns the client of the addonmgr.keikoproj.io/v1alpha1 group
+func (c *Clientset) AddonmgrV1alpha1() addonmgrv1alpha1.AddonmgrV1alpha1Interface {
+	return c.addonmgrV1alpha1
+}
+
+// Discovery returns the discovery client
+func (c *Clientset) Discovery() discovery.DiscoveryInterface {
+	if c == nil {
+		return nil
+	}
+	return c.DiscoveryClient
+}
+
+// NewForConfig returns a clientset for the config
+func NewForConfig(c *rest.Config) (*Clientset, error) {
+	configShallowCopy := *c
+	if configShallowCopy.RateLimiter == nil && configShallowCopy.QPS > 0 {
+		if configShallowCopy.Burst <= 0 {
+			return nil, fmt.Errorf("burst is required to be greater than 0 when RateLimiter is not set and QPS is set to greater than 0")
+		}
+		configShallowCopy.RateLimiter = flowcontrol.NewTokenBucketRateLimiter(configShallowCopy.QPS, configShallowCopy.Burst)
+	}
+
+	var cs Clientset
+	var err error
+	cs.addonmgrV1alpha1, err = addonmgrv1alpha1.NewForConfig(&configShallowCopy)
+	if err != nil {
+		return nil, err
+	}
+	cs.DiscoveryClient, err = discovery.NewDiscoveryClientForConfig(&configShallowCopy)
+	if err != nil {
+		return nil, err
+	}
+	return &cs, nil
+}
+
+// NewForConfigOrDie returns a clientset for the config and panics on errors
+func NewForConfigOrDie(c *rest.Config) *Clientset {
+	cs, err := NewForConfig(c)
+	if err != nil {
+		panic(err)
+	}
+	return cs
+}
+
+// New returns a clientset using the REST client
+func New(c rest.Interface) *Clientset {
+	var cs Clientset
+	cs.addonmgrV1alpha1 = addonmgrv1alpha1.New(c)
+	cs.DiscoveryClient = discovery.NewDiscoveryClient(c)
+	return &cs
+}
@@ -0,0 +1,187 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package v1alpha1
+
+import (
+	"context"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/rest"
+
+	addonmgrv1alpha1 "github.com/keikoproj/addon-manager/api/v1alpha1"
+	"github.com/keikoproj/addon-manager/pkg/client/clientset/versioned/scheme"
+)
+
+// AddonsGetter returns the client of the addons in a namespace
+type AddonsGetter interface {
+	Addons(namespace string) AddonInterface
+}
+
+// AddonInterface has the methods to work with Addon resources
+type AddonInterface interface {
+	Create(ctx context.Context, addon *addonmgrv1alpha1.Addon, opts metav1.CreateOptions) (*addonmgrv1alpha1.Addon, error)
+	Update(ctx context.Context, addon *addonmgrv1alpha1.Addon, opts metav1.UpdateOptions) (*addonmgrv1alpha1.Addon, error)
+	UpdateStatus(ctx context.Context, addon *addonmgrv1alpha1.Addon, opts metav1.UpdateOptions) (*addonmgrv1alpha1.Addon, error)
+	Delete(ctx context.Context, name string, opts metav1.DeleteOptions) error
+	DeleteCollection(ctx context.Context, opts metav1.DeleteOptions, listOpts metav1.ListOptions) error
+	Get(ctx context.Context, name string, opts metav1.GetOptions) (*addonmgrv1alpha1.Addon, error)
+	List(ctx context.Context, opts metav1.ListOptions) (*addonmgrv1alpha1.AddonList, error)
+	Watch(ctx context.Context, opts metav1.ListOptions) (watch.Interface, error)
+	Patch(ctx context.Context, name string, pt types.PatchType, data []byte, opts metav1.PatchOptions, subresources ...string) (*addonmgrv1alpha1.Addon, error)
+}
+
+type addons struct {
+	client rest.Interface
+	ns     string
+}
+
+func newAddons(c *AddonmgrV1alpha1Client, namespace string) *addons {
+	return &addons{
+		client: c.RESTClient(),
+		ns:     namespace,
+	}
+}
+
+// Get returns the addon with name
+func (c *addons) Get(ctx context.Context, name string, options metav1.GetOptions) (*addonmgrv1alpha1.Addon, error) {
+	result := &addonmgrv1alpha1.Addon{}
+	err := c.client.Get().
+		Namespace(c.ns).
+		Resource("addons").
+		Name(name).
+		VersionedParams(&options, scheme.ParameterCodec).
+		Do(ctx).
+		Into(result)
+	return result, err
+}
+
+// List returns the addons matching the label and field selectors
+func (c *addons) List(ctx context.Context, opts metav1.ListOptions) (*addonmgrv1alpha1.AddonList, error) {
+	var timeout time.Duration
+	if opts.TimeoutSeconds != nil {
+		timeout = time.Duration(*opts.TimeoutSeconds) * time.Second
+	}
+	result := &addonmgrv1alpha1.AddonList{}
+	err := c.client.Get().
+		Namespace(c.ns).
+		Resource("addons").
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Timeout(timeout).
+		Do(ctx).
+		Into(result)
+	return result, err
+}
+
+// Watch watches the addons matching the label and field selectors
+func (c *addons) Watch(ctx context.Context, opts metav1.ListOptions) (watch.Interface, error) {
+	var timeout time.Duration
+	if opts.TimeoutSeconds != nil {
+		timeout = time.Duration(*opts.TimeoutSeconds) * time.Second
+	}
+	opts.Watch = true
+	return c.client.Get().
+		Namespace(c.ns).
+		Resource("addons").
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Timeout(timeout).
+		Watch(ctx)
+}
+
+// Create creates the addon
+func (c *addons) Create(ctx context.Context, addon *addonmgrv1alpha1.Addon, opts metav1.CreateOptions) (*addonmgrv1alpha1.Addon, error) {
+	result := &addonmgrv1alpha1.Addon{}
+	err := c.client.Post().
+		Namespace(c.ns).
+		Resource("addons").
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Body(addon).
+		Do(ctx).
+		Into(result)
+	return result, err
+}
+
+// Update replaces the addon
+func (c *addons) Update(ctx context.Context, addon *addonmgrv1alpha1.Addon, opts metav1.UpdateOptions) (*addonmgrv1alpha1.Addon, error) {
+	result := &addonmgrv1alpha1.Addon{}
+	err := c.client.Put().
+		Namespace(c.ns).
+		Resource("addons").
+		Name(addon.Name).
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Body(addon).
+		Do(ctx).
+		Into(result)
+	return result, err
+}
+
+// UpdateStatus replaces the status of the addon
+func (c *addons) UpdateStatus(ctx context.Context, addon *addonmgrv1alpha1.Addon, opts metav1.UpdateOptions) (*addonmgrv1alpha1.Addon, error) {
+	result := &addonmgrv1alpha1.Addon{}
+	err := c.client.Put().
+		Namespace(c.ns).
+		Resource("addons").
+		Name(addon.Name).
+		SubResource("status").
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Body(addon).
+		Do(ctx).
+		Into(result)
+	return result, err
+}
+
+// Delete deletes the addon with name
+func (c *addons) Delete(ctx context.Context, name string, opts metav1.DeleteOptions) error {
+	return c.client.Delete().
+		Namespace(c.ns).
+		Resource("addons").
+		Name(name).
+		Body(&opts).
+		Do(ctx).
+		Error()
+}
+
+// DeleteCollection deletes the addons matching the list options
+func (c *addons) DeleteCollection(ctx context.Context, opts metav1.DeleteOptions, listOpts metav1.ListOptions) error {
+	var timeout time.Duration
+	if listOpts.TimeoutSeconds != nil {
+		timeout = time.Duration(*listOpts.TimeoutSeconds) * time.Second
+	}
+	return c.client.Delete().
+		Namespace(c.ns).
+		Resource("addons").
+		VersionedParams(&listOpts, scheme.ParameterCodec).
+		Timeout(timeout).
+		Body(&opts).
+		Do(ctx).
+		Error()
+}
+
+// Patch patches the addon with name or one of its subresources
+func (c *addons) Patch(ctx context.Context, name string, pt types.PatchType, data []byte, opts metav1.PatchOptions, subresources ...string) (*addonmgrv1alpha1.Addon, error) {
+	result := &addonmgrv1alpha1.Addon{}
+	err := c.client.Patch(pt).
+		Namespace(c.ns).
+		Resource("addons").
+		Name(name).
+		SubResource(subresources...).
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Body(data).
+		Do(ctx).
+		Into(result)
+	return result, err
+}
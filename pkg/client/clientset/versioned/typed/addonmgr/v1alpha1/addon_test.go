@@ -0,0 +1,97 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package v1alpha1
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/rest"
+
+	addonmgrv1alpha1 "github.com/keikoproj/addon-manager/api/v1alpha1"
+)
+
+func TestAddons(t *testing.T) {
+	g := NewGomegaWithT(t)
+	ctx := context.TODO()
+
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path == "/apis/addonmgr.keikoproj.io/v1alpha1/namespaces/addon-manager-system/addons" && r.Method == http.MethodGet {
+			_ = json.NewEncoder(w).Encode(addonmgrv1alpha1.AddonList{
+				TypeMeta: metav1.TypeMeta{Kind: "AddonList", APIVersion: addonmgrv1alpha1.GroupVersion.String()},
+				Items:    []addonmgrv1alpha1.Addon{*newAddon()},
+			})
+			return
+		}
+		a := newAddon()
+		a.Status.Lifecycle.Installed = addonmgrv1alpha1.Succeeded
+		_ = json.NewEncoder(w).Encode(a)
+	}))
+	defer server.Close()
+
+	client := NewForConfigOrDie(&rest.Config{Host: server.URL}).Addons("addon-manager-system")
+
+	a, err := client.Get(ctx, "my-addon", metav1.GetOptions{})
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(a.Spec.PkgName).To(Equal("my-addon"))
+	g.Expect(a.Status.Lifecycle.Installed).To(Equal(addonmgrv1alpha1.Succeeded))
+
+	list, err := client.List(ctx, metav1.ListOptions{})
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(list.Items).To(HaveLen(1))
+
+	_, err = client.Create(ctx, newAddon(), metav1.CreateOptions{})
+	g.Expect(err).ToNot(HaveOccurred())
+	_, err = client.UpdateStatus(ctx, newAddon(), metav1.UpdateOptions{})
+	g.Expect(err).ToNot(HaveOccurred())
+	_, err = client.Patch(ctx, "my-addon", types.MergePatchType, []byte(`{"spec":{"pkgVersion":"1.0.1"}}`), metav1.PatchOptions{})
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(client.Delete(ctx, "my-addon", metav1.DeleteOptions{})).To(Succeed())
+
+	g.Expect(requests).To(Equal([]string{
+		"GET /apis/addonmgr.keikoproj.io/v1alpha1/namespaces/addon-manager-system/addons/my-addon",
+		"GET /apis/addonmgr.keikoproj.io/v1alpha1/namespaces/addon-manager-system/addons",
+		"POST /apis/addonmgr.keikoproj.io/v1alpha1/namespaces/addon-manager-system/addons",
+		"PUT /apis/addonmgr.keikoproj.io/v1alpha1/namespaces/addon-manager-system/addons/my-addon/status",
+		"PATCH /apis/addonmgr.keikoproj.io/v1alpha1/namespaces/addon-manager-system/addons/my-addon",
+		"DELETE /apis/addonmgr.keikoproj.io/v1alpha1/namespaces/addon-manager-system/addons/my-addon",
+	}))
+}
+
+func newAddon() *addonmgrv1alpha1.Addon {
+	return &addonmgrv1alpha1.Addon{
+		TypeMeta: metav1.TypeMeta{Kind: "Addon", APIVersion: addonmgrv1alpha1.GroupVersion.String()},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "my-addon",
+			Namespace: "addon-manager-system",
+		},
+		Spec: addonmgrv1alpha1.AddonSpec{
+			PackageSpec: addonmgrv1alpha1.PackageSpec{
+				PkgName:    "my-addon",
+				PkgVersion: "1.0.0",
+				PkgType:    addonmgrv1alpha1.CompositePkg,
+			},
+		},
+	}
+}
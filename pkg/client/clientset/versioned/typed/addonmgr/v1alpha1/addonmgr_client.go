@@ -0,0 +1,82 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package v1alpha1 has the typed clients of the addonmgr.keikoproj.io/v1alpha1 API group
+package v1alpha1
+
+import (
+	"k8s.io/client-go/rest"
+
+	addonmgrv1alpha1 "github.com/keikoproj/addon-manager/api/v1alpha1"
+	"github.com/keikoproj/addon-manager/pkg/client/clientset/versioned/scheme"
+)
+
+// AddonmgrV1alpha1Interface has the clients of the group resources
+type AddonmgrV1alpha1Interface interface {
+	RESTClient() rest.Interface
+	AddonsGetter
+}
+
+// AddonmgrV1alpha1Client is the client of the addonmgr.keikoproj.io/v1alpha1 group
+type AddonmgrV1alpha1Client struct {
+	restClient rest.Interface
+}
+
+// Addons returns the client of the addons in namespace
+func (c *AddonmgrV1alpha1Client) Addons(namespace string) AddonInterface {
+	return newAddons(c, namespace)
+}
+
+// NewForConfig returns a client for the config
+func NewForConfig(c *rest.Config) (*AddonmgrV1alpha1Client, error) {
+	config := *c
+	setConfigDefaults(&config)
+	client, err := rest.RESTClientFor(&config)
+	if err != nil {
+		return nil, err
+	}
+	return &AddonmgrV1alpha1Client{client}, nil
+}
+
+// NewForConfigOrDie returns a client for the config and panics on errors
+func NewForConfigOrDie(c *rest.Config) *AddonmgrV1alpha1Client {
+	client, err := NewForConfig(c)
+	if err != nil {
+		panic(err)
+	}
+	return client
+}
+
+// New returns a client using the REST client
+func New(c rest.Interface) *AddonmgrV1alpha1Client {
+	return &AddonmgrV1alpha1Client{c}
+}
+
+func setConfigDefaults(config *rest.Config) {
+	gv := addonmgrv1alpha1.GroupVersion
+	config.GroupVersion = &gv
+	config.APIPath = "/apis"
+	config.NegotiatedSerializer = scheme.Codecs.WithoutConversion()
+	if config.UserAgent == "" {
+		config.UserAgent = rest.DefaultKubernetesUserAgent()
+	}
+}
+
+// RESTClient returns the REST client of the group
+func (c *AddonmgrV1alpha1Client) RESTClient() rest.Interface {
+	if c == nil {
+		return nil
+	}
+	return c.restClient
+}
@@ -0,0 +1,79 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package v1alpha1 has the informers of the addonmgr.keikoproj.io/v1alpha1 API group
+package v1alpha1
+
+import (
+	"context"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/tools/cache"
+
+	addonmgrv1alpha1 "github.com/keikoproj/addon-manager/api/v1alpha1"
+	"github.com/keikoproj/addon-manager/pkg/client/clientset/versioned"
+	listers "github.com/keikoproj/addon-manager/pkg/client/listers/addonmgr/v1alpha1"
+)
+
+// AddonInformer gives access to a shared informer and lister of addons
+type AddonInformer interface {
+	Informer() cache.SharedIndexInformer
+	Lister() listers.AddonLister
+}
+
+type addonInformer struct {
+	informer cache.SharedIndexInformer
+}
+
+// NewAddonInformer returns an informer of the addons in namespace, all namespaces when empty. Addons are
+// indexed by namespace.
+func NewAddonInformer(client versioned.Interface, namespace string, resyncPeriod time.Duration) AddonInformer {
+	return NewFilteredAddonInformer(client, namespace, resyncPeriod, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc}, nil)
+}
+
+// NewFilteredAddonInformer returns an informer of the addons in namespace with the indexers, tweakListOptions
+// sets the label or field selectors of the list and watch calls
+func NewFilteredAddonInformer(client versioned.Interface, namespace string, resyncPeriod time.Duration, indexers cache.Indexers, tweakListOptions func(*metav1.ListOptions)) AddonInformer {
+	informer := cache.NewSharedIndexInformer(
+		&cache.ListWatch{
+			ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+				if tweakListOptions != nil {
+					tweakListOptions(&options)
+				}
+				return client.AddonmgrV1alpha1().Addons(namespace).List(context.TODO(), options)
+			},
+			WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+				if tweakListOptions != nil {
+					tweakListOptions(&options)
+				}
+				return client.AddonmgrV1alpha1().Addons(namespace).Watch(context.TODO(), options)
+			},
+		},
+		&addonmgrv1alpha1.Addon{},
+		resyncPeriod,
+		indexers,
+	)
+	return &addonInformer{informer: informer}
+}
+
+func (f *addonInformer) Informer() cache.SharedIndexInformer {
+	return f.informer
+}
+
+func (f *addonInformer) Lister() listers.AddonLister {
+	return listers.NewAddonLister(f.informer.GetIndexer())
+}
@@ -0,0 +1,83 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package v1alpha1 has the listers of the addonmgr.keikoproj.io/v1alpha1 API group, they read from informer caches
+package v1alpha1
+
+import (
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/tools/cache"
+
+	addonmgrv1alpha1 "github.com/keikoproj/addon-manager/api/v1alpha1"
+)
+
+// AddonLister lists addons from an indexer, the returned objects are shared and must not be modified
+type AddonLister interface {
+	// List lists the addons in all namespaces matching the selector
+	List(selector labels.Selector) ([]*addonmgrv1alpha1.Addon, error)
+	// Addons returns a lister of the addons in namespace
+	Addons(namespace string) AddonNamespaceLister
+}
+
+type addonLister struct {
+	indexer cache.Indexer
+}
+
+// NewAddonLister returns a lister of the addons in indexer
+func NewAddonLister(indexer cache.Indexer) AddonLister {
+	return &addonLister{indexer: indexer}
+}
+
+func (s *addonLister) List(selector labels.Selector) (ret []*addonmgrv1alpha1.Addon, err error) {
+	err = cache.ListAll(s.indexer, selector, func(m interface{}) {
+		ret = append(ret, m.(*addonmgrv1alpha1.Addon))
+	})
+	return ret, err
+}
+
+func (s *addonLister) Addons(namespace string) AddonNamespaceLister {
+	return addonNamespaceLister{indexer: s.indexer, namespace: namespace}
+}
+
+// AddonNamespaceLister lists and gets the addons of a namespace
+type AddonNamespaceLister interface {
+	// List lists the addons in the namespace matching the selector
+	List(selector labels.Selector) ([]*addonmgrv1alpha1.Addon, error)
+	// Get returns the addon with name in the namespace
+	Get(name string) (*addonmgrv1alpha1.Addon, error)
+}
+
+type addonNamespaceLister struct {
+	indexer   cache.Indexer
+	namespace string
+}
+
+func (s addonNamespaceLister) List(selector labels.Selector) (ret []*addonmgrv1alpha1.Addon, err error) {
+	err = cache.ListAllByNamespace(s.indexer, s.namespace, selector, func(m interface{}) {
+		ret = append(ret, m.(*addonmgrv1alpha1.Addon))
+	})
+	return ret, err
+}
+
+func (s addonNamespaceLister) Get(name string) (*addonmgrv1alpha1.Addon, error) {
+	obj, exists, err := s.indexer.GetByKey(s.namespace + "/" + name)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, errors.NewNotFound(addonmgrv1alpha1.GroupVersion.WithResource("addons").GroupResource(), name)
+	}
+	return obj.(*addonmgrv1alpha1.Addon), nil
+}
@@ -0,0 +1,60 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package v1alpha1
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/tools/cache"
+
+	addonmgrv1alpha1 "github.com/keikoproj/addon-manager/api/v1alpha1"
+)
+
+func TestAddonLister(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	for _, a := range []*addonmgrv1alpha1.Addon{
+		{ObjectMeta: metav1.ObjectMeta{Name: "a", Namespace: "one", Labels: map[string]string{"team": "x"}}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "b", Namespace: "one"}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "a", Namespace: "two", Labels: map[string]string{"team": "x"}}},
+	} {
+		g.Expect(indexer.Add(a)).To(Succeed())
+	}
+	lister := NewAddonLister(indexer)
+
+	all, err := lister.List(labels.Everything())
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(all).To(HaveLen(3))
+
+	team, err := lister.List(labels.SelectorFromSet(labels.Set{"team": "x"}))
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(team).To(HaveLen(2))
+
+	one, err := lister.Addons("one").List(labels.Everything())
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(one).To(HaveLen(2))
+
+	a, err := lister.Addons("two").Get("a")
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(a.Namespace).To(Equal("two"))
+
+	_, err = lister.Addons("two").Get("b")
+	g.Expect(errors.IsNotFound(err)).To(BeTrue())
+}
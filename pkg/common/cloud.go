@@ -0,0 +1,60 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package common
+
+import "fmt"
+
+// CloudProvider selects the workload identity annotation workflow roles map to
+type CloudProvider string
+
+const (
+	// AWSProvider maps roles to IAM roles for service accounts
+	AWSProvider CloudProvider = "aws"
+	// GCPProvider maps roles to GKE Workload Identity Google service accounts
+	GCPProvider CloudProvider = "gcp"
+	// AzureProvider maps roles to Azure Workload Identity managed identity client IDs
+	AzureProvider CloudProvider = "azure"
+)
+
+const (
+	// AWSRoleAnnotation is read by the EKS pod identity webhook to give pods of the service account credentials of the IAM role
+	AWSRoleAnnotation = "eks.amazonaws.com/role-arn"
+	// GCPServiceAccountAnnotation binds a Kubernetes service account to a Google service account
+	GCPServiceAccountAnnotation = "iam.gke.io/gcp-service-account"
+	// AzureClientIDAnnotation sets the managed identity client ID of a service account
+	AzureClientIDAnnotation = "azure.workload.identity/client-id"
+	// AzureUseLabel opts pods into Azure Workload Identity token injection
+	AzureUseLabel = "azure.workload.identity/use"
+)
+
+// ParseCloudProvider returns the CloudProvider named s
+func ParseCloudProvider(s string) (CloudProvider, error) {
+	switch p := CloudProvider(s); p {
+	case AWSProvider, GCPProvider, AzureProvider:
+		return p, nil
+	}
+	return "", fmt.Errorf("unknown cloud provider %q, must be one of aws, gcp, azure", s)
+}
+
+// ServiceAccountAnnotation returns the service account annotation a role is set in, AWS is the default
+func (p CloudProvider) ServiceAccountAnnotation() string {
+	switch p {
+	case GCPProvider:
+		return GCPServiceAccountAnnotation
+	case AzureProvider:
+		return AzureClientIDAnnotation
+	}
+	return AWSRoleAnnotation
+}
@@ -39,13 +39,20 @@ func RemoveString(slice []string, s string) (result []string) {
 
 // GetCurretTimestamp -- get current timestamp in millisecond
 func GetCurretTimestamp() int64 {
-	return time.Now().UnixNano() / int64(time.Millisecond)
+	return TimestampOf(time.Now())
+}
+
+// TimestampOf returns the timestamp of t in milliseconds
+func TimestampOf(t time.Time) int64 {
+	return t.UnixNano() / int64(time.Millisecond)
 }
 
 // IsExpired --- check if reached ttl time
 func IsExpired(startTime int64, ttlTime int64) bool {
-	if GetCurretTimestamp()-startTime >= ttlTime {
-		return true
-	}
-	return false
+	return IsExpiredAt(time.Now(), startTime, ttlTime)
+}
+
+// IsExpiredAt returns true when the ttl in milliseconds since startTime passed at now
+func IsExpiredAt(now time.Time, startTime int64, ttlTime int64) bool {
+	return TimestampOf(now)-startTime >= ttlTime
 }
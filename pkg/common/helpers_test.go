@@ -16,6 +16,9 @@ package common
 import (
 	"reflect"
 	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/clock"
 )
 
 func TestContainsString(t *testing.T) {
@@ -59,3 +62,33 @@ func TestRemoveStringNotPresent(t *testing.T) {
 		t.Errorf("common.RemoveString = %v, want %v", got, expected)
 	}
 }
+
+func TestParseCloudProvider(t *testing.T) {
+	p, err := ParseCloudProvider("gcp")
+	if err != nil || p.ServiceAccountAnnotation() != GCPServiceAccountAnnotation {
+		t.Errorf("common.ParseCloudProvider(gcp) = %v, %v", p, err)
+	}
+	p, err = ParseCloudProvider("azure")
+	if err != nil || p.ServiceAccountAnnotation() != AzureClientIDAnnotation {
+		t.Errorf("common.ParseCloudProvider(azure) = %v, %v", p, err)
+	}
+	if _, err := ParseCloudProvider("openstack"); err == nil {
+		t.Errorf("common.ParseCloudProvider(openstack) expected error")
+	}
+	if got := CloudProvider("").ServiceAccountAnnotation(); got != AWSRoleAnnotation {
+		t.Errorf("default ServiceAccountAnnotation = %v, want %v", got, AWSRoleAnnotation)
+	}
+}
+
+func TestIsExpiredAt(t *testing.T) {
+	c := clock.NewFakeClock(time.Now())
+	start := TimestampOf(c.Now())
+	c.Step(time.Minute)
+	if IsExpiredAt(c.Now(), start, int64(2*time.Minute/time.Millisecond)) {
+		t.Errorf("common.IsExpiredAt expired after 1m of a 2m ttl")
+	}
+	c.Step(time.Minute)
+	if !IsExpiredAt(c.Now(), start, int64(2*time.Minute/time.Millisecond)) {
+		t.Errorf("common.IsExpiredAt not expired after 2m of a 2m ttl")
+	}
+}
@@ -28,6 +28,15 @@ func AddonGVR() schema.GroupVersionResource {
 	}
 }
 
+// AddonCatalogGVR returns the schema representation of the addon catalog resource
+func AddonCatalogGVR() schema.GroupVersionResource {
+	return schema.GroupVersionResource{
+		Group:    "addonmgr.keikoproj.io",
+		Version:  "v1alpha1",
+		Resource: "addoncatalogs",
+	}
+}
+
 // CRDGVR returns the schema representation for customresourcedefinitions
 func CRDGVR() schema.GroupVersionResource {
 	return schema.GroupVersionResource{
@@ -46,6 +55,24 @@ func SecretGVR() schema.GroupVersionResource {
 	}
 }
 
+// ClusterGVR returns the schema representation of the Cluster API cluster resource
+func ClusterGVR() schema.GroupVersionResource {
+	return schema.GroupVersionResource{
+		Group:    "cluster.x-k8s.io",
+		Version:  "v1alpha3",
+		Resource: "clusters",
+	}
+}
+
+// SealedSecretGVR returns the schema representation of the bitnami sealed secret resource
+func SealedSecretGVR() schema.GroupVersionResource {
+	return schema.GroupVersionResource{
+		Group:    "bitnami.com",
+		Version:  "v1alpha1",
+		Resource: "sealedsecrets",
+	}
+}
+
 // WorkflowGVR returns the schema representation of the workflow resource
 func WorkflowGVR() schema.GroupVersionResource {
 	return schema.GroupVersionResource{
@@ -55,6 +82,96 @@ func WorkflowGVR() schema.GroupVersionResource {
 	}
 }
 
+// ApplicationGVR returns the schema representation of the Argo CD application resource
+func ApplicationGVR() schema.GroupVersionResource {
+	return schema.GroupVersionResource{
+		Group:    "argoproj.io",
+		Version:  "v1alpha1",
+		Resource: "applications",
+	}
+}
+
+// HelmRepositoryGVR returns the schema representation of the Flux helm repository resource
+func HelmRepositoryGVR() schema.GroupVersionResource {
+	return schema.GroupVersionResource{
+		Group:    "source.toolkit.fluxcd.io",
+		Version:  "v1beta1",
+		Resource: "helmrepositories",
+	}
+}
+
+// GitRepositoryGVR returns the schema representation of the Flux git repository resource
+func GitRepositoryGVR() schema.GroupVersionResource {
+	return schema.GroupVersionResource{
+		Group:    "source.toolkit.fluxcd.io",
+		Version:  "v1beta1",
+		Resource: "gitrepositories",
+	}
+}
+
+// HelmReleaseGVR returns the schema representation of the Flux helm release resource
+func HelmReleaseGVR() schema.GroupVersionResource {
+	return schema.GroupVersionResource{
+		Group:    "helm.toolkit.fluxcd.io",
+		Version:  "v2beta1",
+		Resource: "helmreleases",
+	}
+}
+
+// KustomizationGVR returns the schema representation of the Flux kustomization resource
+func KustomizationGVR() schema.GroupVersionResource {
+	return schema.GroupVersionResource{
+		Group:    "kustomize.toolkit.fluxcd.io",
+		Version:  "v1beta1",
+		Resource: "kustomizations",
+	}
+}
+
+// ManagedClusterGVR returns the schema representation of the Open Cluster Management managed cluster resource
+func ManagedClusterGVR() schema.GroupVersionResource {
+	return schema.GroupVersionResource{
+		Group:    "cluster.open-cluster-management.io",
+		Version:  "v1",
+		Resource: "managedclusters",
+	}
+}
+
+// ManifestWorkGVR returns the schema representation of the Open Cluster Management manifest work resource
+func ManifestWorkGVR() schema.GroupVersionResource {
+	return schema.GroupVersionResource{
+		Group:    "work.open-cluster-management.io",
+		Version:  "v1",
+		Resource: "manifestworks",
+	}
+}
+
+// ClusterPropagationPolicyGVR returns the schema representation of the Karmada cluster propagation policy resource
+func ClusterPropagationPolicyGVR() schema.GroupVersionResource {
+	return schema.GroupVersionResource{
+		Group:    "policy.karmada.io",
+		Version:  "v1alpha1",
+		Resource: "clusterpropagationpolicies",
+	}
+}
+
+// ResourceBindingGVR returns the schema representation of the Karmada resource binding resource
+func ResourceBindingGVR() schema.GroupVersionResource {
+	return schema.GroupVersionResource{
+		Group:    "work.karmada.io",
+		Version:  "v1alpha2",
+		Resource: "resourcebindings",
+	}
+}
+
+// ClusterResourceBindingGVR returns the schema representation of the Karmada cluster resource binding resource
+func ClusterResourceBindingGVR() schema.GroupVersionResource {
+	return schema.GroupVersionResource{
+		Group:    "work.karmada.io",
+		Version:  "v1alpha2",
+		Resource: "clusterresourcebindings",
+	}
+}
+
 // WorkflowType return an unstructured workflow type object
 func WorkflowType() *unstructured.Unstructured {
 	wf := &unstructured.Unstructured{}
@@ -0,0 +1,350 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package conformance checks an addon package installs, validates, upgrades and deletes cleanly in a test cluster
+// run by the addon manager, and reports the result so vendors can publish it
+package conformance
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/dynamic"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	addonmgrv1alpha1 "github.com/keikoproj/addon-manager/api/v1alpha1"
+	"github.com/keikoproj/addon-manager/pkg/builder"
+	"github.com/keikoproj/addon-manager/pkg/common"
+	"github.com/keikoproj/addon-manager/pkg/workflows"
+)
+
+// Steps of the suite, in the order they run
+const (
+	StepSpec     = "spec"
+	StepInstall  = "install"
+	StepValidate = "validate"
+	StepUpgrade  = "upgrade"
+	StepDelete   = "delete"
+)
+
+// Result of a step
+type Result string
+
+const (
+	// Passed steps completed successfully
+	Passed Result = "Passed"
+	// Failed steps did not complete successfully
+	Failed Result = "Failed"
+	// Skipped steps do not apply to the package or follow a failed step
+	Skipped Result = "Skipped"
+)
+
+const (
+	// DefaultTimeout is how long each step waits for the addon manager
+	DefaultTimeout = 10 * time.Minute
+	// DefaultPollInterval is how often the addon and workflows are polled
+	DefaultPollInterval = 5 * time.Second
+)
+
+// Options of the suite
+type Options struct {
+	// Timeout of each step, defaults to DefaultTimeout
+	Timeout time.Duration
+	// PollInterval defaults to DefaultPollInterval
+	PollInterval time.Duration
+}
+
+// StepResult is the outcome of a step
+type StepResult struct {
+	Name     string `json:"name"`
+	Version  string `json:"version,omitempty"`
+	Result   Result `json:"result"`
+	Message  string `json:"message,omitempty"`
+	Duration string `json:"duration,omitempty"`
+}
+
+// Report is the outcome of a conformance run
+type Report struct {
+	Package        string       `json:"package"`
+	Version        string       `json:"version"`
+	UpgradeVersion string       `json:"upgradeVersion,omitempty"`
+	Passed         bool         `json:"passed"`
+	StartTime      metav1.Time  `json:"startTime"`
+	CompletionTime metav1.Time  `json:"completionTime"`
+	Steps          []StepResult `json:"steps"`
+}
+
+type step struct {
+	name    string
+	version string
+	run     func() (Result, error)
+}
+
+// Suite runs the conformance steps against a cluster with the addon manager and Argo installed
+type Suite struct {
+	client    client.Client
+	dynClient dynamic.Interface
+	opts      Options
+}
+
+// New returns a suite creating addons with c and submitting validate workflows with dynClient
+func New(c client.Client, dynClient dynamic.Interface, opts Options) *Suite {
+	if opts.Timeout <= 0 {
+		opts.Timeout = DefaultTimeout
+	}
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = DefaultPollInterval
+	}
+	return &Suite{client: c, dynClient: dynClient, opts: opts}
+}
+
+// Run installs candidate, runs its validate workflow, upgrades it to upgrade when not nil and validates again,
+// then deletes it. Steps following a failure are skipped, the addon is deleted whenever it was created.
+func (s *Suite) Run(ctx context.Context, candidate, upgrade *addonmgrv1alpha1.Addon) *Report {
+	report := &Report{
+		Package:   candidate.Spec.PkgName,
+		Version:   candidate.Spec.PkgVersion,
+		StartTime: metav1.Now(),
+	}
+	if upgrade != nil {
+		report.UpgradeVersion = upgrade.Spec.PkgVersion
+	}
+
+	a := candidate.DeepCopy()
+	failed := !report.step(StepSpec, a.Spec.PkgVersion, func() (Result, error) {
+		if err := builder.Validate(a); err != nil {
+			return Failed, err
+		}
+		if upgrade != nil {
+			if err := builder.Validate(upgrade); err != nil {
+				return Failed, fmt.Errorf("invalid upgrade. %v", err)
+			}
+		}
+		return Passed, nil
+	})
+
+	created := false
+	steps := []step{
+		{StepInstall, a.Spec.PkgVersion, func() (Result, error) {
+			if err := s.client.Create(ctx, a); err != nil {
+				return Failed, err
+			}
+			created = true
+			return Passed, s.waitForInstall(ctx, a)
+		}},
+		{StepValidate, a.Spec.PkgVersion, func() (Result, error) {
+			return s.validate(ctx, a)
+		}},
+	}
+	if upgrade != nil {
+		steps = append(steps, []step{
+			{StepUpgrade, upgrade.Spec.PkgVersion, func() (Result, error) {
+				return Passed, s.upgrade(ctx, a, upgrade)
+			}},
+			{StepValidate, upgrade.Spec.PkgVersion, func() (Result, error) {
+				return s.validate(ctx, a)
+			}},
+		}...)
+	}
+	for _, st := range steps {
+		if failed {
+			report.skip(st.name, st.version)
+			continue
+		}
+		failed = !report.step(st.name, st.version, st.run)
+	}
+
+	if created {
+		report.step(StepDelete, "", func() (Result, error) {
+			return Passed, s.delete(ctx, a)
+		})
+	} else {
+		report.skip(StepDelete, "")
+	}
+
+	report.Passed = true
+	for _, st := range report.Steps {
+		if st.Result == Failed {
+			report.Passed = false
+		}
+	}
+	report.CompletionTime = metav1.Now()
+	return report
+}
+
+// step runs fn and records its result, it returns false when the step failed
+func (r *Report) step(name, version string, fn func() (Result, error)) bool {
+	start := time.Now()
+	result, err := fn()
+	st := StepResult{Name: name, Version: version, Result: result, Duration: time.Since(start).Round(time.Millisecond).String()}
+	if err != nil {
+		st.Result, st.Message = Failed, err.Error()
+	}
+	r.Steps = append(r.Steps, st)
+	return st.Result != Failed
+}
+
+func (r *Report) skip(name, version string) {
+	r.Steps = append(r.Steps, StepResult{Name: name, Version: version, Result: Skipped})
+}
+
+// waitForInstall waits until the manager installed the current spec of the addon
+func (s *Suite) waitForInstall(ctx context.Context, a *addonmgrv1alpha1.Addon) error {
+	key := types.NamespacedName{Namespace: a.Namespace, Name: a.Name}
+	err := wait.PollImmediate(s.opts.PollInterval, s.opts.Timeout, func() (bool, error) {
+		if err := s.client.Get(ctx, key, a); err != nil {
+			return false, err
+		}
+		if a.Status.Checksum != a.CalculateStatusChecksum() {
+			return false, nil
+		}
+		switch a.Status.Lifecycle.Installed {
+		case addonmgrv1alpha1.Succeeded:
+			return true, nil
+		case addonmgrv1alpha1.Failed:
+			return false, fmt.Errorf("install failed. %s", a.Status.Reason)
+		}
+		return false, nil
+	})
+	if err == wait.ErrWaitTimeout {
+		return fmt.Errorf("addon %s phase is %q after %s", key, a.Status.Lifecycle.Installed, s.opts.Timeout)
+	}
+	return err
+}
+
+// validate submits the validate workflow of the addon and waits until it completes
+func (s *Suite) validate(ctx context.Context, a *addonmgrv1alpha1.Addon) (Result, error) {
+	wf, err := workflows.RenderWorkflow(a, addonmgrv1alpha1.Validate)
+	if err != nil {
+		return Failed, err
+	}
+	if wf == nil {
+		return Skipped, nil
+	}
+	// Do not collide with the validate workflow the manager runs for blue-green installs
+	wf.SetName(wf.GetName() + "-conformance")
+
+	workflowClient := s.dynClient.Resource(common.WorkflowGVR()).Namespace(wf.GetNamespace())
+	if _, err := workflowClient.Create(ctx, wf, metav1.CreateOptions{}); err != nil {
+		return Failed, fmt.Errorf("unable to submit validate workflow. %v", err)
+	}
+	defer workflowClient.Delete(ctx, wf.GetName(), metav1.DeleteOptions{})
+
+	var phase string
+	err = wait.PollImmediate(s.opts.PollInterval, s.opts.Timeout, func() (bool, error) {
+		obj, err := workflowClient.Get(ctx, wf.GetName(), metav1.GetOptions{})
+		if err != nil {
+			return false, err
+		}
+		phase, _, _ = unstructured.NestedString(obj.Object, "status", "phase")
+		switch phase {
+		case "Succeeded":
+			return true, nil
+		case "Failed", "Error":
+			message, _, _ := unstructured.NestedString(obj.Object, "status", "message")
+			return false, fmt.Errorf("validate workflow %s %s. %s", wf.GetName(), phase, message)
+		}
+		return false, nil
+	})
+	if err == wait.ErrWaitTimeout {
+		return Failed, fmt.Errorf("validate workflow %s phase is %q after %s", wf.GetName(), phase, s.opts.Timeout)
+	}
+	if err != nil {
+		return Failed, err
+	}
+	return Passed, nil
+}
+
+// upgrade replaces the spec of the addon with the upgrade spec and waits until it is installed
+func (s *Suite) upgrade(ctx context.Context, a, upgrade *addonmgrv1alpha1.Addon) error {
+	if err := s.client.Get(ctx, types.NamespacedName{Namespace: a.Namespace, Name: a.Name}, a); err != nil {
+		return err
+	}
+	upgrade.Spec.DeepCopyInto(&a.Spec)
+	if err := s.client.Update(ctx, a); err != nil {
+		return err
+	}
+	return s.waitForInstall(ctx, a)
+}
+
+// delete deletes the addon and waits until the manager removed it
+func (s *Suite) delete(ctx context.Context, a *addonmgrv1alpha1.Addon) error {
+	if err := s.client.Delete(ctx, a); err != nil && !apierrors.IsNotFound(err) {
+		return err
+	}
+	key := types.NamespacedName{Namespace: a.Namespace, Name: a.Name}
+	err := wait.PollImmediate(s.opts.PollInterval, s.opts.Timeout, func() (bool, error) {
+		err := s.client.Get(ctx, key, a)
+		if apierrors.IsNotFound(err) {
+			return true, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		if a.Status.Lifecycle.Installed == addonmgrv1alpha1.DeleteFailed {
+			return false, fmt.Errorf("delete failed. %s", a.Status.Reason)
+		}
+		return false, nil
+	})
+	if err == wait.ErrWaitTimeout {
+		return fmt.Errorf("addon %s was not deleted after %s", key, s.opts.Timeout)
+	}
+	return err
+}
+
+// WriteJSON writes the indented JSON report
+func (r *Report) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+// WriteText writes a table of the steps followed by the overall result
+func (r *Report) WriteText(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "STEP\tVERSION\tRESULT\tDURATION\tMESSAGE\n")
+	for _, st := range r.Steps {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", st.Name, st.Version, st.Result, st.Duration, st.Message)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	result := Passed
+	if !r.Passed {
+		result = Failed
+	}
+	_, err := fmt.Fprintf(w, "\n%s:%s conformance %s\n", r.Package, r.Version, result)
+	return err
+}
+
+// Write writes the report in format, text or json
+func (r *Report) Write(w io.Writer, format string) error {
+	switch format {
+	case "", "text":
+		return r.WriteText(w)
+	case "json":
+		return r.WriteJSON(w)
+	default:
+		return fmt.Errorf("unknown report format %s", format)
+	}
+}
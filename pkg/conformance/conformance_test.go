@@ -0,0 +1,145 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package conformance
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	dynfake "k8s.io/client-go/dynamic/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	runtimefake "sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	addonmgrv1alpha1 "github.com/keikoproj/addon-manager/api/v1alpha1"
+	"github.com/keikoproj/addon-manager/pkg/builder"
+	"github.com/keikoproj/addon-manager/pkg/testenv"
+)
+
+const workflowTemplate = `
+apiVersion: argoproj.io/v1alpha1
+kind: Workflow
+spec:
+  entrypoint: entry
+  templates:
+  - name: entry
+    container:
+      image: busybox
+`
+
+func newCandidate(version string) *addonmgrv1alpha1.Addon {
+	return builder.NewAddon().
+		WithName("event-router").
+		WithNamespace(testenv.ManagedNamespace).
+		WithPackage("event-router", version, addonmgrv1alpha1.CompositePkg).
+		WithTargetNamespace("event-router").
+		WithInstallTemplate(workflowTemplate).
+		WithValidateTemplate(workflowTemplate).
+		MustBuild()
+}
+
+// runManager stands in for the addon manager, it installs the spec of every addon with the phase of install
+func runManager(ctx context.Context, c client.Client, install addonmgrv1alpha1.ApplicationAssemblyPhase) {
+	wait.UntilWithContext(ctx, func(ctx context.Context) {
+		var addons addonmgrv1alpha1.AddonList
+		if err := c.List(ctx, &addons); err != nil {
+			return
+		}
+		for i := range addons.Items {
+			a := &addons.Items[i]
+			if checksum := a.CalculateChecksum(); a.Status.Checksum != checksum {
+				a.Status.Checksum = checksum
+				a.Status.Lifecycle.Installed = install
+				a.Status.Reason = "scripted"
+				_ = c.Update(ctx, a)
+			}
+		}
+	}, 10*time.Millisecond)
+}
+
+func newSuite(ctx context.Context, install addonmgrv1alpha1.ApplicationAssemblyPhase) (*Suite, *testenv.WorkflowDriver) {
+	sch := runtime.NewScheme()
+	_ = addonmgrv1alpha1.AddToScheme(sch)
+	c := runtimefake.NewFakeClientWithScheme(sch)
+	dyn := dynfake.NewSimpleDynamicClient(runtime.NewScheme())
+	driver := testenv.NewWorkflowDriver(dyn, testenv.ManagedNamespace)
+
+	go runManager(ctx, c, install)
+	go driver.Run(ctx)
+
+	return New(c, dyn, Options{Timeout: 5 * time.Second, PollInterval: 10 * time.Millisecond}), driver
+}
+
+func results(r *Report) []string {
+	var res []string
+	for _, st := range r.Steps {
+		res = append(res, st.Name+":"+string(st.Result))
+	}
+	return res
+}
+
+func TestSuite_Run(t *testing.T) {
+	g := NewGomegaWithT(t)
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+
+	s, _ := newSuite(ctx, addonmgrv1alpha1.Succeeded)
+	report := s.Run(ctx, newCandidate("v0.1"), newCandidate("v0.2"))
+	g.Expect(report.Passed).To(BeTrue(), "%+v", report.Steps)
+	g.Expect(report.UpgradeVersion).To(Equal("v0.2"))
+	g.Expect(results(report)).To(Equal([]string{
+		"spec:Passed", "install:Passed", "validate:Passed", "upgrade:Passed", "validate:Passed", "delete:Passed",
+	}))
+
+	var out bytes.Buffer
+	g.Expect(report.Write(&out, "text")).To(Succeed())
+	g.Expect(out.String()).To(ContainSubstring("event-router:v0.1 conformance Passed"))
+	out.Reset()
+	g.Expect(report.Write(&out, "json")).To(Succeed())
+	g.Expect(out.String()).To(ContainSubstring(`"passed": true`))
+	g.Expect(report.Write(&out, "xml")).ToNot(Succeed())
+}
+
+func TestSuite_RunFailures(t *testing.T) {
+	g := NewGomegaWithT(t)
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+
+	// A failed install skips the remaining steps and still deletes the addon
+	s, _ := newSuite(ctx, addonmgrv1alpha1.Failed)
+	report := s.Run(ctx, newCandidate("v0.1"), newCandidate("v0.2"))
+	g.Expect(report.Passed).To(BeFalse())
+	g.Expect(results(report)).To(Equal([]string{
+		"spec:Passed", "install:Failed", "validate:Skipped", "upgrade:Skipped", "validate:Skipped", "delete:Passed",
+	}))
+	g.Expect(report.Steps[1].Message).To(ContainSubstring("scripted"))
+
+	// A failed validate workflow fails the package
+	s, driver := newSuite(ctx, addonmgrv1alpha1.Succeeded)
+	driver.SetPhase("event-router", addonmgrv1alpha1.Validate, testenv.WorkflowFailed)
+	report = s.Run(ctx, newCandidate("v0.1"), nil)
+	g.Expect(report.Passed).To(BeFalse())
+	g.Expect(results(report)).To(Equal([]string{"spec:Passed", "install:Passed", "validate:Failed", "delete:Passed"}))
+
+	// Invalid specs are not installed
+	invalid := newCandidate("v0.1")
+	invalid.Spec.Params.Namespace = ""
+	report = s.Run(ctx, invalid, nil)
+	g.Expect(results(report)).To(Equal([]string{"spec:Failed", "install:Skipped", "validate:Skipped", "delete:Skipped"}))
+}
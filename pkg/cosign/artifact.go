@@ -0,0 +1,65 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cosign
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+)
+
+// Puller pulls the content of OCI artifacts holding a single file, e.g. pushed with oras
+type Puller struct {
+	credentials map[string]Credentials
+	transport   http.RoundTripper
+}
+
+// NewPuller returns a Puller, credentials are optional registry credentials keyed by registry host
+func NewPuller(credentials map[string]Credentials) *Puller {
+	return &Puller{credentials: credentials}
+}
+
+// SetTransport sets the transport of registry requests, e.g. with a custom TLS configuration
+func (p *Puller) SetTransport(t http.RoundTripper) {
+	p.transport = t
+}
+
+// Pull returns the content of the single layer of the artifact, verified against its digest
+func (p *Puller) Pull(ctx context.Context, artifact string) ([]byte, error) {
+	ref, err := name.ParseReference(artifact)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := remoteOptions(ctx, p.credentials)
+	if p.transport != nil {
+		opts = append(opts, remote.WithTransport(p.transport))
+	}
+	img, err := remote.Image(ref, opts...)
+	if err != nil {
+		return nil, err
+	}
+	layers, err := img.Layers()
+	if err != nil {
+		return nil, err
+	}
+	if len(layers) != 1 {
+		return nil, fmt.Errorf("artifact %s has %d layers, expected 1", artifact, len(layers))
+	}
+	return readLayer(layers[0])
+}
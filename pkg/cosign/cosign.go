@@ -0,0 +1,248 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package cosign
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+	"k8s.io/apimachinery/pkg/util/clock"
+)
+
+const (
+	// SignatureAnnotation is the layer annotation holding the base64 signature of the payload
+	SignatureAnnotation = "dev.cosignproject.cosign/signature"
+	defaultCacheTTL     = 10 * time.Minute
+	maxBlobSize         = 1 << 20
+)
+
+type simpleSigning struct {
+	Critical struct {
+		Image struct {
+			DockerManifestDigest string `json:"docker-manifest-digest"`
+		} `json:"image"`
+		Type string `json:"type"`
+	} `json:"critical"`
+}
+
+// Verifier verifies cosign signatures of container images against a set of public keys
+type Verifier struct {
+	sync.Mutex
+	keys        []crypto.PublicKey
+	credentials map[string]Credentials
+	verified    map[string]time.Time
+	ttl         time.Duration
+	clock       clock.PassiveClock
+}
+
+// NewVerifier returns a Verifier for the PEM encoded ECDSA or Ed25519 public keys.
+// credentials are optional registry credentials keyed by registry host.
+func NewVerifier(pemKeys [][]byte, credentials map[string]Credentials) (*Verifier, error) {
+	v := &Verifier{
+		credentials: credentials,
+		verified:    make(map[string]time.Time),
+		ttl:         defaultCacheTTL,
+		clock:       clock.RealClock{},
+	}
+
+	keys, err := ParsePublicKeys(pemKeys)
+	if err != nil {
+		return nil, err
+	}
+	v.keys = keys
+
+	return v, nil
+}
+
+// ParsePublicKeys parses PEM encoded ECDSA or Ed25519 public keys, files may hold several keys
+func ParsePublicKeys(pemKeys [][]byte) ([]crypto.PublicKey, error) {
+	var keys []crypto.PublicKey
+	for _, data := range pemKeys {
+		for {
+			var block *pem.Block
+			block, data = pem.Decode(data)
+			if block == nil {
+				break
+			}
+			key, err := x509.ParsePKIXPublicKey(block.Bytes)
+			if err != nil {
+				return nil, fmt.Errorf("invalid cosign public key. %v", err)
+			}
+			switch key.(type) {
+			case *ecdsa.PublicKey, ed25519.PublicKey:
+				keys = append(keys, key)
+			default:
+				return nil, fmt.Errorf("unsupported cosign public key type %T", key)
+			}
+		}
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("no cosign public keys")
+	}
+	return keys, nil
+}
+
+// Verify checks that image has a cosign signature made by one of the keys and returns the image pinned to the
+// verified digest, tags may be moved after the verification. Successful verifications are cached by digest.
+func (v *Verifier) Verify(ctx context.Context, image string) (string, error) {
+	pinned, err := v.verify(ctx, image)
+	if err != nil {
+		return "", fmt.Errorf("image %s failed signature verification. %v", image, err)
+	}
+	return pinned, nil
+}
+
+func (v *Verifier) verify(ctx context.Context, image string) (string, error) {
+	ref, err := name.ParseReference(image)
+	if err != nil {
+		return "", err
+	}
+
+	opts := remoteOptions(ctx, v.credentials)
+	digest, err := resolve(ref, opts)
+	if err != nil {
+		return "", err
+	}
+	pinned := ref.Context().Digest(digest.String())
+
+	v.Lock()
+	at, ok := v.verified[pinned.String()]
+	v.Unlock()
+	if ok && v.clock.Since(at) < v.ttl {
+		return pinned.String(), nil
+	}
+
+	if err := v.verifySignatures(ref.Context(), digest, opts); err != nil {
+		return "", err
+	}
+
+	v.Lock()
+	v.verified[pinned.String()] = v.clock.Now()
+	v.Unlock()
+	return pinned.String(), nil
+}
+
+// resolve returns the digest of the manifest the reference points to
+func resolve(ref name.Reference, opts []remote.Option) (v1.Hash, error) {
+	if d, ok := ref.(name.Digest); ok {
+		return v1.NewHash(d.DigestStr())
+	}
+	desc, err := remote.Head(ref, opts...)
+	if err != nil {
+		// Some registries only return the digest on GET
+		d, getErr := remote.Get(ref, opts...)
+		if getErr != nil {
+			return v1.Hash{}, getErr
+		}
+		return d.Digest, nil
+	}
+	return desc.Digest, nil
+}
+
+// verifySignatures checks the signatures cosign stores in the image repository under sha256-<hex>.sig
+func (v *Verifier) verifySignatures(repo name.Repository, digest v1.Hash, opts []remote.Option) error {
+	sigs, err := remote.Image(repo.Tag(fmt.Sprintf("%s-%s.sig", digest.Algorithm, digest.Hex)), opts...)
+	if err != nil {
+		var te *transport.Error
+		if errors.As(err, &te) && te.StatusCode == http.StatusNotFound {
+			return errors.New("no signatures found")
+		}
+		return err
+	}
+
+	manifest, err := sigs.Manifest()
+	if err != nil {
+		return err
+	}
+
+	for _, desc := range manifest.Layers {
+		sig, err := base64.StdEncoding.DecodeString(desc.Annotations[SignatureAnnotation])
+		if err != nil || len(sig) == 0 {
+			continue
+		}
+
+		layer, err := sigs.LayerByDigest(desc.Digest)
+		if err != nil {
+			return err
+		}
+		payload, err := readLayer(layer)
+		if err != nil {
+			return err
+		}
+
+		if !v.signedByKey(payload, sig) {
+			continue
+		}
+
+		var ss simpleSigning
+		if err := json.Unmarshal(payload, &ss); err != nil {
+			continue
+		}
+		if ss.Critical.Image.DockerManifestDigest == digest.String() {
+			return nil
+		}
+	}
+
+	return errors.New("no valid signature from a trusted key")
+}
+
+// readLayer returns the content of a layer, it is verified against the layer digest while reading
+func readLayer(layer v1.Layer) ([]byte, error) {
+	rc, err := layer.Compressed()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return ioutil.ReadAll(io.LimitReader(rc, maxBlobSize))
+}
+
+func (v *Verifier) signedByKey(payload, sig []byte) bool {
+	return SignedByKey(v.keys, payload, sig)
+}
+
+// SignedByKey reports whether sig is a cosign signature of payload made by one of the keys
+func SignedByKey(keys []crypto.PublicKey, payload, sig []byte) bool {
+	hash := sha256.Sum256(payload)
+	for _, key := range keys {
+		switch k := key.(type) {
+		case *ecdsa.PublicKey:
+			if ecdsa.VerifyASN1(k, hash[:], sig) {
+				return true
+			}
+		case ed25519.PublicKey:
+			if ed25519.Verify(k, payload, sig) {
+				return true
+			}
+		}
+	}
+	return false
+}
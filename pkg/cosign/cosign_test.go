@@ -0,0 +1,202 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package cosign
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	. "github.com/onsi/gomega"
+)
+
+// payloadLayer is an uncompressed layer holding a single file
+type payloadLayer []byte
+
+func (l payloadLayer) Digest() (v1.Hash, error) {
+	h, _, err := v1.SHA256(bytes.NewReader(l))
+	return h, err
+}
+func (l payloadLayer) DiffID() (v1.Hash, error) { return l.Digest() }
+func (l payloadLayer) Compressed() (io.ReadCloser, error) {
+	return ioutil.NopCloser(bytes.NewReader(l)), nil
+}
+func (l payloadLayer) Uncompressed() (io.ReadCloser, error) { return l.Compressed() }
+func (l payloadLayer) Size() (int64, error)                 { return int64(len(l)), nil }
+func (l payloadLayer) MediaType() (types.MediaType, error) {
+	return "application/vnd.dev.cosign.simplesigning.v1+json", nil
+}
+
+// pushImage pushes a random image to the registry and signs it with key, it returns the image digest
+func pushImage(g *GomegaWithT, key *ecdsa.PrivateKey, image string) v1.Hash {
+	ref, err := name.ParseReference(image)
+	g.Expect(err).ToNot(HaveOccurred())
+	img, err := random.Image(64, 1)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(remote.Write(ref, img)).To(Succeed())
+	digest, err := img.Digest()
+	g.Expect(err).ToNot(HaveOccurred())
+	if key == nil {
+		return digest
+	}
+
+	payload := []byte(fmt.Sprintf(`{"critical":{"identity":{"docker-reference":"%s"},"image":{"docker-manifest-digest":"%s"},"type":"cosign container image signature"},"optional":null}`, ref.Context().Name(), digest))
+	hash := sha256.Sum256(payload)
+	sig, err := ecdsa.SignASN1(rand.Reader, key, hash[:])
+	g.Expect(err).ToNot(HaveOccurred())
+	sigs, err := mutate.Append(mutate.MediaType(empty.Image, types.OCIManifestSchema1), mutate.Addendum{
+		Layer:       payloadLayer(payload),
+		Annotations: map[string]string{SignatureAnnotation: base64.StdEncoding.EncodeToString(sig)},
+	})
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(remote.Write(ref.Context().Tag(fmt.Sprintf("%s-%s.sig", digest.Algorithm, digest.Hex)), sigs)).To(Succeed())
+	return digest
+}
+
+// newRegistry starts an in-memory registry, it counts the requests for signatures
+func newRegistry(sigRequests *int32) (*httptest.Server, string) {
+	reg := registry.New()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, ".sig") {
+			atomic.AddInt32(sigRequests, 1)
+		}
+		reg.ServeHTTP(w, r)
+	}))
+	return srv, strings.TrimPrefix(srv.URL, "http://")
+}
+
+func publicKeyPEM(key *ecdsa.PrivateKey) []byte {
+	der, _ := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	return pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+}
+
+func TestRegistryHost(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	tests := map[string]string{
+		"alpine":                       name.DefaultRegistry,
+		"argoproj/argoexec:v2.12.0":    name.DefaultRegistry,
+		"localhost:5000/tools/kubectl": "localhost:5000",
+		"quay.io/org/img@sha256:" + strings.Repeat("a", 64): "quay.io",
+	}
+	for image, expected := range tests {
+		host, err := RegistryHost(image)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(host).To(Equal(expected), image)
+	}
+}
+
+func TestParseDockerConfig(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	creds, err := ParseDockerConfig([]byte(`{"auths":{"https://index.docker.io/v1/":{"auth":"dXNlcjpwYXNz"},"quay.io":{"username":"bot","password":"token"}}}`))
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(creds).To(Equal(map[string]Credentials{
+		name.DefaultRegistry: {Username: "user", Password: "pass"},
+		"quay.io":            {Username: "bot", Password: "token"},
+	}))
+}
+
+func TestVerifier_Verify(t *testing.T) {
+	g := NewGomegaWithT(t)
+	ctx := context.TODO()
+
+	trusted, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	untrusted, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+	var sigRequests int32
+	srv, host := newRegistry(&sigRequests)
+	defer srv.Close()
+	digest := pushImage(g, trusted, host+"/tools/kubectl:v1")
+	pushImage(g, untrusted, host+"/tools/helm:v3")
+	pushImage(g, nil, host+"/tools/unsigned:v1")
+
+	v, err := NewVerifier([][]byte{publicKeyPEM(trusted)}, nil)
+	g.Expect(err).ToNot(HaveOccurred())
+
+	// Verified images are pinned to their digest
+	pinned, err := v.Verify(ctx, host+"/tools/kubectl:v1")
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(pinned).To(Equal(host + "/tools/kubectl@" + digest.String()))
+	_, err = v.Verify(ctx, host+"/tools/helm:v3")
+	g.Expect(err).To(MatchError(ContainSubstring("no valid signature")))
+	_, err = v.Verify(ctx, host+"/tools/unsigned:v1")
+	g.Expect(err).To(MatchError(ContainSubstring("no signatures found")))
+
+	// Successful verifications are cached by digest
+	requests := atomic.LoadInt32(&sigRequests)
+	pinned, err = v.Verify(ctx, host+"/tools/kubectl@"+digest.String())
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(pinned).To(Equal(host + "/tools/kubectl@" + digest.String()))
+	g.Expect(atomic.LoadInt32(&sigRequests)).To(Equal(requests))
+
+	// A tag moved to an unsigned image fails verification
+	pushImage(g, nil, host+"/tools/kubectl:v1")
+	_, err = v.Verify(ctx, host+"/tools/kubectl:v1")
+	g.Expect(err).To(MatchError(ContainSubstring("no signatures found")))
+}
+
+func TestPuller_Pull(t *testing.T) {
+	g := NewGomegaWithT(t)
+	ctx := context.TODO()
+
+	var sigRequests int32
+	srv, host := newRegistry(&sigRequests)
+	defer srv.Close()
+
+	index := payloadLayer("packages: []")
+	artifact, err := mutate.Append(mutate.MediaType(empty.Image, types.OCIManifestSchema1), mutate.Addendum{Layer: index})
+	g.Expect(err).ToNot(HaveOccurred())
+	ref, _ := name.ParseReference(host + "/addons/catalog:v1")
+	g.Expect(remote.Write(ref, artifact)).To(Succeed())
+	ref, _ = name.ParseReference(host + "/addons/catalog:empty")
+	g.Expect(remote.Write(ref, empty.Image)).To(Succeed())
+
+	p := NewPuller(nil)
+	content, err := p.Pull(ctx, host+"/addons/catalog:v1")
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(content).To(Equal([]byte(index)))
+
+	_, err = p.Pull(ctx, host+"/addons/catalog:empty")
+	g.Expect(err).To(MatchError(ContainSubstring("has 0 layers")))
+}
+
+func TestNewVerifier_InvalidKeys(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	_, err := NewVerifier([][]byte{[]byte("not a key")}, nil)
+	g.Expect(err).To(HaveOccurred())
+}
@@ -0,0 +1,97 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package cosign
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+)
+
+// Credentials are registry basic auth credentials
+type Credentials struct {
+	Username string
+	Password string
+}
+
+// ParseDockerConfig returns the registry credentials of a docker config.json
+func ParseDockerConfig(data []byte) (map[string]Credentials, error) {
+	var config struct {
+		Auths map[string]struct {
+			Auth     string `json:"auth"`
+			Username string `json:"username"`
+			Password string `json:"password"`
+		} `json:"auths"`
+	}
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, err
+	}
+
+	creds := make(map[string]Credentials, len(config.Auths))
+	for host, a := range config.Auths {
+		c := Credentials{Username: a.Username, Password: a.Password}
+		if a.Auth != "" {
+			decoded, err := base64.StdEncoding.DecodeString(a.Auth)
+			if err != nil {
+				return nil, fmt.Errorf("invalid auth for registry %s. %v", host, err)
+			}
+			parts := strings.SplitN(string(decoded), ":", 2)
+			if len(parts) == 2 {
+				c = Credentials{Username: parts[0], Password: parts[1]}
+			}
+		}
+
+		// Keys may be urls, e.g. https://index.docker.io/v1/
+		if u, err := url.Parse(host); err == nil && u.Host != "" {
+			host = u.Host
+		}
+		if reg, err := name.NewRegistry(host); err == nil {
+			host = reg.RegistryStr()
+		}
+		creds[host] = c
+	}
+	return creds, nil
+}
+
+// RegistryHost returns the registry of the image the credentials of a registry are keyed by, docker hub images are
+// expanded like the docker cli does
+func RegistryHost(image string) (string, error) {
+	ref, err := name.ParseReference(image)
+	if err != nil {
+		return "", err
+	}
+	return ref.Context().RegistryStr(), nil
+}
+
+// keychain resolves the credentials of a registry, registries without credentials are accessed anonymously
+type keychain map[string]Credentials
+
+func (k keychain) Resolve(target authn.Resource) (authn.Authenticator, error) {
+	if c, ok := k[target.RegistryStr()]; ok {
+		return &authn.Basic{Username: c.Username, Password: c.Password}, nil
+	}
+	return authn.Anonymous, nil
+}
+
+// remoteOptions returns the options of registry requests made with ctx
+func remoteOptions(ctx context.Context, credentials map[string]Credentials) []remote.Option {
+	return []remote.Option{remote.WithContext(ctx), remote.WithAuthFromKeychain(keychain(credentials))}
+}
@@ -0,0 +1,367 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package deps
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+
+	addonmgrv1alpha1 "github.com/keikoproj/addon-manager/api/v1alpha1"
+)
+
+// maxDepth bounds the length of dependency chains
+const maxDepth = 256
+
+// constraintVersion matches the versions of a constraint
+var constraintVersion = regexp.MustCompile(`\d+(\.\d+){0,2}`)
+
+// Package is a package version installed by an addon or available in the catalog
+type Package struct {
+	addonmgrv1alpha1.PackageSpec
+	// Addon is the namespace/name of the addon installing the package, empty for catalog packages
+	Addon string
+	// Phase is the install phase of the addon
+	Phase addonmgrv1alpha1.ApplicationAssemblyPhase
+}
+
+// Installed returns true when an addon installs the package
+func (p Package) Installed() bool {
+	return p.Addon != ""
+}
+
+func (p Package) String() string {
+	return p.PkgName + ":" + p.PkgVersion
+}
+
+// Catalog lists the package versions that can be installed
+type Catalog interface {
+	Versions(pkgName string) []addonmgrv1alpha1.PackageSpec
+}
+
+// Requirement is a dependency constraint declared by a package
+type Requirement struct {
+	// From is the pkgName:pkgVersion of the package declaring the dependency
+	From       string
+	Constraint string
+}
+
+// NotFoundError is returned when no installed or catalog version satisfies a dependency
+type NotFoundError struct {
+	PkgName    string
+	Constraint string
+}
+
+func (e *NotFoundError) Error() string {
+	return fmt.Sprintf("unable to resolve required dependency %s:%s", e.PkgName, e.Constraint)
+}
+
+// ConflictError is returned when the selected version of a package does not satisfy all of its requirements
+type ConflictError struct {
+	PkgName      string
+	Selected     string
+	Requirements []Requirement
+}
+
+func (e *ConflictError) Error() string {
+	reqs := make([]string, 0, len(e.Requirements))
+	for _, r := range e.Requirements {
+		reqs = append(reqs, fmt.Sprintf("%s requires %s", r.From, r.Constraint))
+	}
+	return fmt.Sprintf("conflicting requirements for %s:%s, %s", e.PkgName, e.Selected, strings.Join(reqs, ", "))
+}
+
+// CycleError is returned when packages depend on each other
+type CycleError struct {
+	Path []string
+}
+
+func (e *CycleError) Error() string {
+	return fmt.Sprintf("circular dependency was found in %s", strings.Join(e.Path, " -> "))
+}
+
+// Plan lists the packages a package depends on, each package follows its own dependencies
+type Plan struct {
+	Packages []Package
+}
+
+// Missing returns the packages of the plan that are not installed yet
+func (p *Plan) Missing() []Package {
+	var missing []Package
+	for _, pkg := range p.Packages {
+		if !pkg.Installed() {
+			missing = append(missing, pkg)
+		}
+	}
+	return missing
+}
+
+// Resolver resolves dependencies preferring the highest installed version, the catalog is used for packages
+// without a matching installed version
+type Resolver struct {
+	installed map[string][]Package
+	catalog   Catalog
+}
+
+// NewResolver returns a resolver of the installed packages, catalog may be nil
+func NewResolver(installed []Package, catalog Catalog) *Resolver {
+	r := &Resolver{installed: make(map[string][]Package), catalog: catalog}
+	for _, p := range installed {
+		r.installed[p.PkgName] = append(r.installed[p.PkgName], p)
+	}
+	return r
+}
+
+// Installed returns the packages installed by the addons
+func Installed(addons []addonmgrv1alpha1.Addon) []Package {
+	packages := make([]Package, 0, len(addons))
+	for _, a := range addons {
+		packages = append(packages, Package{
+			PackageSpec: a.GetPackageSpec(),
+			Addon:       a.Namespace + "/" + a.Name,
+			Phase:       a.Status.Lifecycle.Installed,
+		})
+	}
+	return packages
+}
+
+// Find returns the highest installed version of the package satisfying the constraint
+func Find(installed []Package, pkgName, constraint string) (Package, bool) {
+	var candidates []Package
+	for _, p := range installed {
+		if p.PkgName == pkgName && Matches(constraint, p.PkgVersion) {
+			candidates = append(candidates, p)
+		}
+	}
+	if len(candidates) == 0 {
+		return Package{}, false
+	}
+	SortVersions(candidates)
+	return candidates[0], true
+}
+
+// Dependents returns the installed packages that depend on pkg, transitively, in reverse dependency order: every
+// package is listed before the packages it depends on. A dependency still satisfied by another installed version of
+// the package does not make a package a dependent.
+func Dependents(pkg Package, installed []Package) []Package {
+	installed = append([]Package{}, installed...)
+	sort.SliceStable(installed, func(i, j int) bool { return installed[i].Addon < installed[j].Addon })
+
+	var dependents []Package
+	removed := map[string]bool{pkg.Addon: true}
+	var visit func(p Package)
+	visit = func(p Package) {
+		for _, d := range installed {
+			if removed[d.Addon] || !dependsOn(d, p, installed, removed) {
+				continue
+			}
+			removed[d.Addon] = true
+			visit(d)
+			dependents = append(dependents, d)
+		}
+	}
+	visit(pkg)
+	return dependents
+}
+
+// dependsOn returns true when d requires p and no other installed package that is not removed satisfies the requirement
+func dependsOn(d, p Package, installed []Package, removed map[string]bool) bool {
+	for name, constraint := range d.PkgDeps {
+		if strings.TrimSpace(name) != p.PkgName || !Matches(constraint, p.PkgVersion) {
+			continue
+		}
+		for _, other := range installed {
+			if other.PkgName == p.PkgName && !removed[other.Addon] && other.Addon != p.Addon && Matches(constraint, other.PkgVersion) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// Matches returns true when version satisfies the constraint, * matches any version and versions that are
+// not semver only match themselves
+func Matches(constraint, version string) bool {
+	constraint = strings.TrimSpace(constraint)
+	if constraint == "*" || constraint == version {
+		return true
+	}
+	c, err := semver.NewConstraint(constraint)
+	if err != nil {
+		return false
+	}
+	v, err := semver.NewVersion(version)
+	if err != nil {
+		return false
+	}
+	return c.Check(v)
+}
+
+// Compatible returns true when a version satisfies both constraints. The versions tried are the known versions, the
+// versions of the constraints and the versions next to them, which covers the bounds of comparisons and ranges.
+func Compatible(a, b string, versions []string) bool {
+	a, b = strings.TrimSpace(a), strings.TrimSpace(b)
+	if a == b || a == "*" || b == "*" {
+		return true
+	}
+	// Versions that are not semver only match themselves
+	if _, err := semver.NewConstraint(a); err != nil {
+		return Matches(b, a)
+	}
+	if _, err := semver.NewConstraint(b); err != nil {
+		return Matches(a, b)
+	}
+
+	candidates := append([]string{"0.0.0"}, versions...)
+	for _, s := range constraintVersion.FindAllString(a+" "+b, -1) {
+		v, err := semver.NewVersion(s)
+		if err != nil {
+			continue
+		}
+		candidates = append(candidates, v.String(), v.IncPatch().String(), previousVersion(v))
+	}
+	for _, v := range candidates {
+		if Matches(a, v) && Matches(b, v) {
+			return true
+		}
+	}
+	return false
+}
+
+// previousVersion returns a version right below v
+func previousVersion(v *semver.Version) string {
+	const max = 999999
+	switch {
+	case v.Patch() > 0:
+		return fmt.Sprintf("%d.%d.%d", v.Major(), v.Minor(), v.Patch()-1)
+	case v.Minor() > 0:
+		return fmt.Sprintf("%d.%d.%d", v.Major(), v.Minor()-1, max)
+	case v.Major() > 0:
+		return fmt.Sprintf("%d.%d.%d", v.Major()-1, max, max)
+	}
+	return v.String()
+}
+
+type resolution struct {
+	selected     map[string]Package
+	requirements map[string][]Requirement
+	visiting     []string
+	plan         *Plan
+}
+
+// Resolve selects a version of every package the root package depends on, transitively. Versions are selected
+// greedily in dependency name order, a version selected for an earlier requirement must satisfy later ones.
+func (r *Resolver) Resolve(root addonmgrv1alpha1.PackageSpec) (*Plan, error) {
+	res := &resolution{
+		selected:     map[string]Package{root.PkgName: {PackageSpec: root}},
+		requirements: map[string][]Requirement{},
+		plan:         &Plan{},
+	}
+	if err := r.resolve(res, Package{PackageSpec: root}); err != nil {
+		return nil, err
+	}
+	return res.plan, nil
+}
+
+func (r *Resolver) resolve(res *resolution, pkg Package) error {
+	if len(res.visiting) >= maxDepth {
+		return fmt.Errorf("dependency chain of %s exceeds %d packages", pkg, maxDepth)
+	}
+	res.visiting = append(res.visiting, pkg.PkgName)
+	defer func() { res.visiting = res.visiting[:len(res.visiting)-1] }()
+
+	names := make([]string, 0, len(pkg.PkgDeps))
+	for name := range pkg.PkgDeps {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		constraint := strings.TrimSpace(pkg.PkgDeps[name])
+		name = strings.TrimSpace(name)
+		if name == pkg.PkgName {
+			return fmt.Errorf("invalid package dependency, addon cannot depend on it's own package name %s:%s", name, constraint)
+		}
+
+		for i, visiting := range res.visiting {
+			if visiting == name {
+				return &CycleError{Path: append(append([]string{}, res.visiting[i:]...), name)}
+			}
+		}
+
+		reqs := append(res.requirements[name], Requirement{From: pkg.String(), Constraint: constraint})
+		res.requirements[name] = reqs
+		if selected, ok := res.selected[name]; ok {
+			if !Matches(constraint, selected.PkgVersion) {
+				return &ConflictError{PkgName: name, Selected: selected.PkgVersion, Requirements: reqs}
+			}
+			continue
+		}
+
+		dep, ok := r.selectVersion(name, reqs)
+		if !ok {
+			return &NotFoundError{PkgName: name, Constraint: constraint}
+		}
+		res.selected[name] = dep
+		if err := r.resolve(res, dep); err != nil {
+			return err
+		}
+		res.plan.Packages = append(res.plan.Packages, dep)
+	}
+	return nil
+}
+
+// selectVersion returns the highest installed version satisfying the requirements, or else the highest catalog version
+func (r *Resolver) selectVersion(name string, reqs []Requirement) (Package, bool) {
+	candidates := append([]Package{}, r.installed[name]...)
+	SortVersions(candidates)
+	var catalog []Package
+	if r.catalog != nil {
+		for _, spec := range r.catalog.Versions(name) {
+			catalog = append(catalog, Package{PackageSpec: spec})
+		}
+		SortVersions(catalog)
+	}
+
+	for _, p := range append(candidates, catalog...) {
+		matches := true
+		for _, req := range reqs {
+			matches = matches && Matches(req.Constraint, p.PkgVersion)
+		}
+		if matches {
+			return p, true
+		}
+	}
+	return Package{}, false
+}
+
+// SortVersions sorts packages by descending semver, versions that are not semver follow in name order
+func SortVersions(packages []Package) {
+	sort.SliceStable(packages, func(i, j int) bool {
+		vi, erri := semver.NewVersion(packages[i].PkgVersion)
+		vj, errj := semver.NewVersion(packages[j].PkgVersion)
+		switch {
+		case erri == nil && errj == nil:
+			return vi.GreaterThan(vj)
+		case erri == nil || errj == nil:
+			return erri == nil
+		}
+		return packages[i].PkgVersion < packages[j].PkgVersion
+	})
+}
@@ -0,0 +1,157 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package deps
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	addonmgrv1alpha1 "github.com/keikoproj/addon-manager/api/v1alpha1"
+)
+
+type staticCatalog map[string][]addonmgrv1alpha1.PackageSpec
+
+func (c staticCatalog) Versions(pkgName string) []addonmgrv1alpha1.PackageSpec {
+	return c[pkgName]
+}
+
+func installed(name, version string, phase addonmgrv1alpha1.ApplicationAssemblyPhase, pkgDeps map[string]string) Package {
+	return Package{
+		PackageSpec: addonmgrv1alpha1.PackageSpec{PkgName: name, PkgVersion: version, PkgDeps: pkgDeps},
+		Addon:       "addon-manager-system/" + name,
+		Phase:       phase,
+	}
+}
+
+func root(pkgDeps map[string]string) addonmgrv1alpha1.PackageSpec {
+	return addonmgrv1alpha1.PackageSpec{PkgName: "test/addon", PkgVersion: "1.0.0", PkgDeps: pkgDeps}
+}
+
+func TestResolver_Resolve(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	r := NewResolver([]Package{
+		installed("core/cert-manager", "v1.0.4", addonmgrv1alpha1.Succeeded, nil),
+		installed("core/cert-manager", "v1.1.0", addonmgrv1alpha1.Succeeded, nil),
+		installed("core/ingress", "2.3.0", addonmgrv1alpha1.Pending, map[string]string{"core/cert-manager": "~1.0"}),
+	}, staticCatalog{
+		"core/metrics": {{PkgName: "core/metrics", PkgVersion: "0.3.6"}, {PkgName: "core/metrics", PkgVersion: "0.4.1"}},
+	})
+
+	// The highest installed version satisfying all requirements is selected, dependencies come first
+	plan, err := r.Resolve(root(map[string]string{"core/ingress": ">=2.0.0", "core/metrics": "^0.4"}))
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(plan.Packages).To(HaveLen(3))
+	g.Expect(plan.Packages[0].String()).To(Equal("core/cert-manager:v1.0.4"))
+	g.Expect(plan.Packages[1].String()).To(Equal("core/ingress:2.3.0"))
+	g.Expect(plan.Packages[2].String()).To(Equal("core/metrics:0.4.1"))
+	g.Expect(plan.Missing()).To(HaveLen(1))
+	g.Expect(plan.Missing()[0].Installed()).To(BeFalse())
+
+	plan, err = r.Resolve(root(map[string]string{"core/cert-manager": "*"}))
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(plan.Packages[0].PkgVersion).To(Equal("v1.1.0"))
+
+	_, err = r.Resolve(root(map[string]string{"core/metrics": ">=1.0.0"}))
+	g.Expect(err).To(Equal(&NotFoundError{PkgName: "core/metrics", Constraint: ">=1.0.0"}))
+}
+
+func TestResolver_Conflict(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	r := NewResolver([]Package{
+		installed("core/cert-manager", "v1.0.4", addonmgrv1alpha1.Succeeded, nil),
+		installed("core/cert-manager", "v1.1.0", addonmgrv1alpha1.Succeeded, nil),
+		installed("core/ingress", "2.3.0", addonmgrv1alpha1.Succeeded, map[string]string{"core/cert-manager": "~1.0"}),
+	}, nil)
+
+	_, err := r.Resolve(root(map[string]string{"core/cert-manager": ">=1.1.0", "core/ingress": "*"}))
+	g.Expect(err).To(BeAssignableToTypeOf(&ConflictError{}))
+	conflict := err.(*ConflictError)
+	g.Expect(conflict.Selected).To(Equal("v1.1.0"))
+	g.Expect(conflict.Requirements).To(Equal([]Requirement{
+		{From: "test/addon:1.0.0", Constraint: ">=1.1.0"},
+		{From: "core/ingress:2.3.0", Constraint: "~1.0"},
+	}))
+	g.Expect(err.Error()).To(ContainSubstring("core/ingress:2.3.0 requires ~1.0"))
+}
+
+func TestResolver_Cycle(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	r := NewResolver([]Package{
+		installed("core/A", "1.0.0", addonmgrv1alpha1.Succeeded, map[string]string{"core/B": "*"}),
+		installed("core/B", "1.0.0", addonmgrv1alpha1.Succeeded, map[string]string{"core/A": "*"}),
+		installed("core/C", "1.0.0", addonmgrv1alpha1.Succeeded, map[string]string{"test/addon": "*"}),
+	}, nil)
+
+	_, err := r.Resolve(root(map[string]string{"core/A": "*"}))
+	g.Expect(err).To(Equal(&CycleError{Path: []string{"core/A", "core/B", "core/A"}}))
+
+	_, err = r.Resolve(root(map[string]string{"core/C": "*"}))
+	g.Expect(err).To(Equal(&CycleError{Path: []string{"test/addon", "core/C", "test/addon"}}))
+
+	_, err = r.Resolve(root(map[string]string{"test/addon": "*"}))
+	g.Expect(err).To(MatchError(ContainSubstring("cannot depend on it's own package")))
+}
+
+func TestDependents(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	certManager := installed("core/cert-manager", "1.0.4", addonmgrv1alpha1.Succeeded, nil)
+	pkgs := []Package{
+		certManager,
+		installed("core/ingress", "2.0.0", addonmgrv1alpha1.Succeeded, map[string]string{"core/cert-manager": "~1.0"}),
+		installed("core/dashboard", "1.0.0", addonmgrv1alpha1.Pending, map[string]string{"core/ingress": "*", "core/cert-manager": "*"}),
+		installed("core/metrics", "1.0.0", addonmgrv1alpha1.Succeeded, map[string]string{"core/cert-manager": ">=2.0.0"}),
+	}
+
+	var names []string
+	for _, p := range Dependents(certManager, pkgs) {
+		names = append(names, p.PkgName)
+	}
+	g.Expect(names).To(Equal([]string{"core/dashboard", "core/ingress"}))
+
+	// Another installed version still satisfies the dependents
+	upgrade := installed("core/cert-manager", "1.0.5", addonmgrv1alpha1.Succeeded, nil)
+	upgrade.Addon = "addon-manager-system/cert-manager-upgrade"
+	g.Expect(Dependents(certManager, append(pkgs, upgrade))).To(BeEmpty())
+}
+
+func TestMatches(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	g.Expect(Matches("*", "anything")).To(BeTrue())
+	g.Expect(Matches("v1.0.0", "1.0.0")).To(BeTrue())
+	g.Expect(Matches(">=1.2.0, <2.0.0", "v1.4.2")).To(BeTrue())
+	g.Expect(Matches(">=1.2.0, <2.0.0", "2.0.0")).To(BeFalse())
+	g.Expect(Matches("latest", "latest")).To(BeTrue())
+	g.Expect(Matches("~1.0", "latest")).To(BeFalse())
+}
+
+func TestCompatible(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	g.Expect(Compatible("*", "<1.0", nil)).To(BeTrue())
+	g.Expect(Compatible("^1.2", ">=1.5", nil)).To(BeTrue())
+	g.Expect(Compatible(">=1.0, <3.0", "~2.5", nil)).To(BeTrue())
+	g.Expect(Compatible("<2", "<1", nil)).To(BeTrue())
+	g.Expect(Compatible("<2.0.0", ">=2.0.0", nil)).To(BeFalse())
+	g.Expect(Compatible("~1.2", "~1.3", nil)).To(BeFalse())
+	g.Expect(Compatible("^1.0", "^2.0", []string{"1.4.0", "2.1.0"})).To(BeFalse())
+	g.Expect(Compatible("latest", "latest", nil)).To(BeTrue())
+	g.Expect(Compatible("latest", "~1.0", nil)).To(BeFalse())
+}
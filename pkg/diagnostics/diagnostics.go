@@ -0,0 +1,151 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package diagnostics
+
+import (
+	"context"
+	"encoding/json"
+	"expvar"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"sync"
+	"time"
+
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+// SourceFunc returns a JSON serializable snapshot of internal state
+type SourceFunc func() interface{}
+
+// Server serves pprof, expvar and a diagnostics dump on a dedicated address
+type Server struct {
+	sync.RWMutex
+	addr    string
+	sources map[string]SourceFunc
+}
+
+// NewServer returns a diagnostics Server listening on addr
+func NewServer(addr string) *Server {
+	return &Server{
+		addr:    addr,
+		sources: make(map[string]SourceFunc),
+	}
+}
+
+// Register adds a named source to the diagnostics dump
+func (s *Server) Register(name string, fn SourceFunc) {
+	s.Lock()
+	defer s.Unlock()
+	s.sources[name] = fn
+}
+
+// Handler returns the http handler serving all diagnostics endpoints
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.Handle("/debug/vars", expvar.Handler())
+	mux.HandleFunc("/debug/diagnostics", s.serveDiagnostics)
+	return mux
+}
+
+// Start runs the server until stop is closed, implements manager.Runnable
+func (s *Server) Start(stop <-chan struct{}) error {
+	srv := &http.Server{Addr: s.addr, Handler: s.Handler()}
+
+	errCh := make(chan error, 1)
+	go func() {
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			errCh <- err
+		}
+	}()
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-stop:
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		return srv.Shutdown(ctx)
+	}
+}
+
+// NeedLeaderElection is false so diagnostics are available on every replica
+func (s *Server) NeedLeaderElection() bool {
+	return false
+}
+
+// Dump returns the diagnostics snapshot served on /debug/diagnostics
+func (s *Server) Dump() map[string]interface{} {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+
+	dump := map[string]interface{}{
+		"runtime": map[string]interface{}{
+			"goroutines":  runtime.NumGoroutine(),
+			"heapAlloc":   mem.HeapAlloc,
+			"heapObjects": mem.HeapObjects,
+			"sys":         mem.Sys,
+			"numGC":       mem.NumGC,
+		},
+		"queueDepth": queueDepths(),
+	}
+
+	s.RLock()
+	defer s.RUnlock()
+	for name, fn := range s.sources {
+		dump[name] = fn()
+	}
+
+	return dump
+}
+
+func (s *Server) serveDiagnostics(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(s.Dump()); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// queueDepths reads the controller workqueue depths from the controller-runtime metrics registry
+func queueDepths() map[string]float64 {
+	depths := make(map[string]float64)
+
+	families, err := metrics.Registry.Gather()
+	if err != nil {
+		return depths
+	}
+
+	for _, mf := range families {
+		if mf.GetName() != "workqueue_depth" {
+			continue
+		}
+		for _, m := range mf.GetMetric() {
+			for _, l := range m.GetLabel() {
+				if l.GetName() == "name" {
+					depths[l.GetValue()] = m.GetGauge().GetValue()
+				}
+			}
+		}
+	}
+
+	return depths
+}
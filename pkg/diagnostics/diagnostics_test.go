@@ -0,0 +1,59 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package diagnostics
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestServer_Handler(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	s := NewServer("")
+	s.Register("addons", func() interface{} {
+		return map[string]int{"cachedVersions": 3}
+	})
+
+	srv := httptest.NewServer(s.Handler())
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/debug/diagnostics")
+	g.Expect(err).ToNot(HaveOccurred())
+	defer resp.Body.Close()
+	g.Expect(resp.StatusCode).To(Equal(http.StatusOK))
+
+	var dump map[string]interface{}
+	g.Expect(json.NewDecoder(resp.Body).Decode(&dump)).To(Succeed())
+	g.Expect(dump).To(HaveKey("runtime"))
+	g.Expect(dump).To(HaveKey("queueDepth"))
+	g.Expect(dump["addons"]).To(HaveKeyWithValue("cachedVersions", float64(3)))
+
+	for _, path := range []string{"/debug/pprof/", "/debug/vars"} {
+		resp, err := http.Get(srv.URL + path)
+		g.Expect(err).ToNot(HaveOccurred())
+		resp.Body.Close()
+		g.Expect(resp.StatusCode).To(Equal(http.StatusOK), path)
+	}
+}
+
+func TestServer_NeedLeaderElection(t *testing.T) {
+	g := NewGomegaWithT(t)
+	g.Expect(NewServer(":0").NeedLeaderElection()).To(BeFalse())
+}
@@ -0,0 +1,200 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package faults injects failures into the workflow lifecycle and API writes for chaos testing, so the retry and
+// status handling of the controller can be exercised end to end. It must not be enabled in production.
+package faults
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// EnvVar is the environment variable faults are configured with when no flag is given
+const EnvVar = "ADDON_MANAGER_FAULTS"
+
+// ErrInjected is the cause of injected workflow submit errors
+var ErrInjected = errors.New("injected fault")
+
+// Config holds the probabilities, from 0 to 1, of each injected fault
+type Config struct {
+	// Submit fails workflow submissions
+	Submit float64
+	// Flap reports completed workflows as running
+	Flap float64
+	// Conflict fails updates and patches with a conflict
+	Conflict float64
+	// Seed seeds the random source, the current time when 0
+	Seed int64
+}
+
+// ParseConfig parses a comma separated list of submit, flap and conflict probabilities and the seed,
+// e.g. submit=0.1,conflict=0.2,seed=42
+func ParseConfig(s string) (Config, error) {
+	var c Config
+	for _, kv := range strings.Split(s, ",") {
+		kv = strings.TrimSpace(kv)
+		if kv == "" {
+			continue
+		}
+		parts := strings.SplitN(kv, "=", 2)
+		if len(parts) != 2 {
+			return c, fmt.Errorf("fault %q must be set as name=value", kv)
+		}
+		name, value := strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1])
+		if name == "seed" {
+			seed, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return c, fmt.Errorf("invalid fault seed %q. %v", value, err)
+			}
+			c.Seed = seed
+			continue
+		}
+
+		p, err := strconv.ParseFloat(value, 64)
+		if err != nil || p < 0 || p > 1 {
+			return c, fmt.Errorf("fault %s probability %q must be between 0 and 1", name, value)
+		}
+		switch name {
+		case "submit":
+			c.Submit = p
+		case "flap":
+			c.Flap = p
+		case "conflict":
+			c.Conflict = p
+		default:
+			return c, fmt.Errorf("unknown fault %s, must be one of submit, flap, conflict or seed", name)
+		}
+	}
+	return c, nil
+}
+
+// Enabled returns true when any fault may be injected
+func (c Config) Enabled() bool {
+	return c.Submit > 0 || c.Flap > 0 || c.Conflict > 0
+}
+
+// Injector decides when faults are injected, a nil Injector injects none. It is safe for concurrent use.
+type Injector struct {
+	config Config
+
+	mu   sync.Mutex
+	rand *rand.Rand
+}
+
+// New returns an injector for the config
+func New(c Config) *Injector {
+	seed := c.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	return &Injector{config: c, rand: rand.New(rand.NewSource(seed))}
+}
+
+func (i *Injector) inject(p float64) bool {
+	if p <= 0 {
+		return false
+	}
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.rand.Float64() < p
+}
+
+// SubmitError returns an error to fail the submission of the workflow with
+func (i *Injector) SubmitError(name string) error {
+	if i != nil && i.inject(i.config.Submit) {
+		return fmt.Errorf("workflow %s was not submitted. %w", name, ErrInjected)
+	}
+	return nil
+}
+
+// FlapStatus returns true when the completed workflow is reported as running
+func (i *Injector) FlapStatus(name string) bool {
+	return i != nil && i.inject(i.config.Flap)
+}
+
+// conflict returns a conflict error for the object
+func (i *Injector) conflict(obj runtime.Object) error {
+	if i == nil || !i.inject(i.config.Conflict) {
+		return nil
+	}
+	var name string
+	if m, err := meta.Accessor(obj); err == nil {
+		name = m.GetName()
+	}
+	gvk := obj.GetObjectKind().GroupVersionKind()
+	return apierrors.NewConflict(schema.GroupResource{Group: gvk.Group, Resource: strings.ToLower(gvk.Kind)}, name, ErrInjected)
+}
+
+// Client wraps c so its updates and patches, including of status, fail with conflicts
+func (i *Injector) Client(c client.Client) client.Client {
+	if i == nil || i.config.Conflict <= 0 {
+		return c
+	}
+	return &faultClient{Client: c, faults: i}
+}
+
+type faultClient struct {
+	client.Client
+	faults *Injector
+}
+
+func (c *faultClient) Update(ctx context.Context, obj runtime.Object, opts ...client.UpdateOption) error {
+	if err := c.faults.conflict(obj); err != nil {
+		return err
+	}
+	return c.Client.Update(ctx, obj, opts...)
+}
+
+func (c *faultClient) Patch(ctx context.Context, obj runtime.Object, patch client.Patch, opts ...client.PatchOption) error {
+	if err := c.faults.conflict(obj); err != nil {
+		return err
+	}
+	return c.Client.Patch(ctx, obj, patch, opts...)
+}
+
+func (c *faultClient) Status() client.StatusWriter {
+	return &faultStatusWriter{StatusWriter: c.Client.Status(), faults: c.faults}
+}
+
+type faultStatusWriter struct {
+	client.StatusWriter
+	faults *Injector
+}
+
+func (w *faultStatusWriter) Update(ctx context.Context, obj runtime.Object, opts ...client.UpdateOption) error {
+	if err := w.faults.conflict(obj); err != nil {
+		return err
+	}
+	return w.StatusWriter.Update(ctx, obj, opts...)
+}
+
+func (w *faultStatusWriter) Patch(ctx context.Context, obj runtime.Object, patch client.Patch, opts ...client.PatchOption) error {
+	if err := w.faults.conflict(obj); err != nil {
+		return err
+	}
+	return w.StatusWriter.Patch(ctx, obj, patch, opts...)
+}
@@ -0,0 +1,91 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package faults
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	runtimefake "sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestParseConfig(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	c, err := ParseConfig("submit=0.1, flap=1,conflict=0,seed=42")
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(c).To(Equal(Config{Submit: 0.1, Flap: 1, Seed: 42}))
+	g.Expect(c.Enabled()).To(BeTrue())
+
+	c, err = ParseConfig("")
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(c.Enabled()).To(BeFalse())
+
+	for _, invalid := range []string{"submit", "submit=2", "flap=-1", "seed=x", "latency=0.1"} {
+		_, err = ParseConfig(invalid)
+		g.Expect(err).To(HaveOccurred(), invalid)
+	}
+}
+
+func TestInjector(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	var none *Injector
+	g.Expect(none.SubmitError("wf")).To(Succeed())
+	g.Expect(none.FlapStatus("wf")).To(BeFalse())
+
+	always := New(Config{Submit: 1, Flap: 1})
+	g.Expect(errors.Is(always.SubmitError("wf"), ErrInjected)).To(BeTrue())
+	g.Expect(always.FlapStatus("wf")).To(BeTrue())
+
+	never := New(Config{Conflict: 0})
+	g.Expect(never.SubmitError("wf")).To(Succeed())
+	g.Expect(never.FlapStatus("wf")).To(BeFalse())
+
+	// The same seed injects the same faults
+	a, b := New(Config{Submit: 0.5, Seed: 7}), New(Config{Submit: 0.5, Seed: 7})
+	for i := 0; i < 20; i++ {
+		g.Expect(a.SubmitError("wf") == nil).To(Equal(b.SubmitError("wf") == nil))
+	}
+}
+
+func TestInjector_Client(t *testing.T) {
+	g := NewGomegaWithT(t)
+	ctx := context.TODO()
+	sch := runtime.NewScheme()
+	g.Expect(clientgoscheme.AddToScheme(sch)).To(Succeed())
+	cm := &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "faults", Namespace: "default"}}
+	c := runtimefake.NewFakeClientWithScheme(sch, cm)
+
+	g.Expect(New(Config{}).Client(c)).To(BeIdenticalTo(c))
+
+	fc := New(Config{Conflict: 1}).Client(c)
+	g.Expect(apierrors.IsConflict(fc.Update(ctx, cm))).To(BeTrue())
+	g.Expect(apierrors.IsConflict(fc.Patch(ctx, cm, client.MergeFrom(cm.DeepCopy())))).To(BeTrue())
+	g.Expect(apierrors.IsConflict(fc.Status().Update(ctx, cm))).To(BeTrue())
+	g.Expect(apierrors.IsConflict(fc.Status().Patch(ctx, cm, client.MergeFrom(cm.DeepCopy())))).To(BeTrue())
+
+	// Reads and creates are not affected
+	g.Expect(fc.Get(ctx, client.ObjectKey{Namespace: "default", Name: "faults"}, &v1.ConfigMap{})).To(Succeed())
+	g.Expect(fc.Create(ctx, &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "other", Namespace: "default"}})).To(Succeed())
+}
@@ -0,0 +1,386 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package fleet
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"sort"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/clock"
+	"k8s.io/client-go/dynamic"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	addonmgrv1alpha1 "github.com/keikoproj/addon-manager/api/v1alpha1"
+	"github.com/keikoproj/addon-manager/pkg/common"
+)
+
+const (
+	// FleetLabel is set on member addons to the name of the addon with the cluster selector
+	FleetLabel = "addonmgr.keikoproj.io/fleet"
+	// ClusterLabel is set on member addons to the name of the cluster they install the addon in
+	ClusterLabel = "addonmgr.keikoproj.io/cluster"
+	// KubeconfigKey is the key of the kubeconfig in the Cluster API <cluster>-kubeconfig Secret
+	KubeconfigKey = "value"
+
+	// member names must be valid addon names, which are less than 32 characters
+	maxPrefixLength = 22
+)
+
+// IsFleet returns true when the addon is installed in the clusters matching its cluster selector
+func IsFleet(addon *addonmgrv1alpha1.Addon) bool {
+	return addon.Spec.Target != nil && addon.Spec.Target.ClusterSelector != nil
+}
+
+// MemberName returns the name of the member addon installing addon in cluster
+func MemberName(addon *addonmgrv1alpha1.Addon, cluster string) string {
+	prefix := addon.GetName()
+	if len(prefix) > maxPrefixLength {
+		prefix = prefix[:maxPrefixLength]
+	}
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(cluster))
+	return fmt.Sprintf("%s-%08x", prefix, h.Sum32())
+}
+
+// Syncer keeps one member addon per cluster matching the cluster selector of a fleet addon
+type Syncer struct {
+	client    client.Client
+	dynClient dynamic.Interface
+	scheme    *runtime.Scheme
+	hooks     bool
+	clock     clock.PassiveClock
+}
+
+// NewSyncer returns a Syncer managing member addons with client and listing clusters with dynClient
+func NewSyncer(client client.Client, dynClient dynamic.Interface, scheme *runtime.Scheme) *Syncer {
+	return &Syncer{client: client, dynClient: dynClient, scheme: scheme, clock: clock.RealClock{}}
+}
+
+// SetClock sets the clock rollout soak times are measured with
+func (s *Syncer) SetClock(c clock.PassiveClock) {
+	s.clock = c
+}
+
+type memberPlan struct {
+	cluster string
+	desired *addonmgrv1alpha1.Addon
+	current *addonmgrv1alpha1.Addon
+}
+
+func (m memberPlan) updated() bool {
+	return m.current != nil && equality.Semantic.DeepEqual(m.current.Spec, m.desired.Spec)
+}
+
+// installed returns the install phase of a member addon, members are pending until they processed their spec
+func installed(member *addonmgrv1alpha1.Addon) addonmgrv1alpha1.ApplicationAssemblyPhase {
+	if member.Status.Checksum != member.CalculateStatusChecksum() {
+		return addonmgrv1alpha1.Pending
+	}
+	return member.Status.Lifecycle.Installed
+}
+
+// Sync creates or updates the member addons of the selected clusters, removes members of clusters that are
+// no longer selected and returns the status of each selected cluster sorted by cluster name.
+// With a rollout strategy members are updated in waves and the rollout status of the addon is updated.
+func (s *Syncer) Sync(ctx context.Context, addon *addonmgrv1alpha1.Addon) ([]addonmgrv1alpha1.ClusterStatus, error) {
+	existing, err := s.members(ctx, addon)
+	if err != nil {
+		return nil, err
+	}
+
+	all, err := s.listClusters(ctx, addon)
+	if err != nil {
+		return nil, err
+	}
+	clusters, err := selectClusters(addon, all, existing)
+	if err != nil {
+		return nil, err
+	}
+
+	byName := make(map[string]*unstructured.Unstructured, len(all))
+	for i := range all {
+		byName[all[i].GetName()] = &all[i]
+	}
+
+	plans := make([]memberPlan, 0, len(clusters))
+	var updated, failed, pending int
+	for _, cluster := range clusters {
+		desired, err := s.member(addon, byName[cluster])
+		if err != nil {
+			return nil, err
+		}
+		plan := memberPlan{cluster: cluster, desired: desired, current: existing[desired.Name]}
+		delete(existing, desired.Name)
+		plans = append(plans, plan)
+
+		if !plan.updated() {
+			continue
+		}
+		updated++
+		switch installed(plan.current) {
+		case addonmgrv1alpha1.Succeeded:
+		case addonmgrv1alpha1.Failed, addonmgrv1alpha1.DeleteFailed:
+			failed++
+		default:
+			pending++
+		}
+	}
+
+	allowed := len(plans)
+	if addon.Spec.Target.Rollout != nil {
+		allowed = s.rollout(addon, len(plans), updated, failed, pending)
+	} else {
+		addon.Status.Rollout = nil
+	}
+
+	statuses := make([]addonmgrv1alpha1.ClusterStatus, 0, len(plans))
+	for _, plan := range plans {
+		current := plan.current
+		if !plan.updated() && updated < allowed {
+			if current == nil {
+				if err := s.client.Create(ctx, plan.desired); err != nil {
+					return nil, fmt.Errorf("failed to create member addon %s for cluster %s. %v", plan.desired.Name, plan.cluster, err)
+				}
+				current = plan.desired
+			} else {
+				current.Spec = plan.desired.Spec
+				if err := s.client.Update(ctx, current); err != nil {
+					return nil, fmt.Errorf("failed to update member addon %s for cluster %s. %v", current.Name, plan.cluster, err)
+				}
+			}
+			updated++
+		}
+
+		status := addonmgrv1alpha1.ClusterStatus{
+			Cluster: plan.cluster,
+			Addon:   plan.desired.Name,
+		}
+		if current != nil {
+			status.Installed = installed(current)
+			status.Reason = current.Status.Reason
+		}
+		status.Outdated = current == nil || !equality.Semantic.DeepEqual(current.Spec, plan.desired.Spec)
+		statuses = append(statuses, status)
+	}
+
+	// Members of clusters that are no longer selected run their delete workflows before they are removed
+	for _, member := range existing {
+		if err := s.client.Delete(ctx, member); client.IgnoreNotFound(err) != nil {
+			return nil, fmt.Errorf("failed to delete member addon %s. %v", member.Name, err)
+		}
+	}
+
+	if s.hooks {
+		if err := s.updateClusterFinalizers(ctx, addon, all, clusters, existing); err != nil {
+			return nil, err
+		}
+	}
+
+	return statuses, nil
+}
+
+// members returns the member addons of a fleet addon by name
+func (s *Syncer) members(ctx context.Context, addon *addonmgrv1alpha1.Addon) (map[string]*addonmgrv1alpha1.Addon, error) {
+	var members addonmgrv1alpha1.AddonList
+	if err := s.client.List(ctx, &members, client.InNamespace(addon.Namespace), client.MatchingLabels{FleetLabel: addon.Name}); err != nil {
+		return nil, err
+	}
+	existing := make(map[string]*addonmgrv1alpha1.Addon, len(members.Items))
+	for i := range members.Items {
+		existing[members.Items[i].Name] = &members.Items[i]
+	}
+	return existing, nil
+}
+
+// rollout updates the rollout status and returns the number of clusters that may run the current addon spec,
+// the next wave starts once every updated cluster completed and soaked
+func (s *Syncer) rollout(addon *addonmgrv1alpha1.Addon, total, updated, failed, pending int) int {
+	strategy := addon.Spec.Target.Rollout
+	if addon.Status.Rollout == nil {
+		addon.Status.Rollout = &addonmgrv1alpha1.RolloutStatus{}
+	}
+	status := addon.Status.Rollout
+	status.Clusters = int32(total)
+	status.Updated = int32(updated)
+	status.Halted = failed > int(strategy.MaxFailures)
+
+	switch {
+	case status.Halted:
+		return updated
+	case updated >= total:
+		status.SoakStartTime = nil
+		return total
+	case updated == 0:
+		return waveSize(total, strategy.CanaryPercent)
+	case pending > 0:
+		status.SoakStartTime = nil
+		return updated
+	}
+
+	now := s.clock.Now()
+	if status.SoakStartTime == nil {
+		status.SoakStartTime = &metav1.Time{Time: now}
+	}
+	if strategy.SoakTime != nil && now.Sub(status.SoakStartTime.Time) < strategy.SoakTime.Duration {
+		return updated
+	}
+	status.SoakStartTime = nil
+
+	next := updated + waveSize(total, strategy.WavePercent)
+	if next > total {
+		next = total
+	}
+	return next
+}
+
+// waveSize returns the number of clusters in a wave of percent of total clusters, at least one
+func waveSize(total int, percent int32) int {
+	if percent <= 0 || percent >= 100 {
+		return total
+	}
+	size := (total*int(percent) + 99) / 100
+	if size < 1 {
+		size = 1
+	}
+	return size
+}
+
+func (s *Syncer) listClusters(ctx context.Context, addon *addonmgrv1alpha1.Addon) ([]unstructured.Unstructured, error) {
+	list, err := s.dynClient.Resource(common.ClusterGVR()).Namespace(addon.Namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list clusters. %v", err)
+	}
+	return list.Items, nil
+}
+
+// selectClusters returns the sorted names of the clusters matching the cluster selector that are not being deleted,
+// clusters without a member addon are only selected once they are ready
+func selectClusters(addon *addonmgrv1alpha1.Addon, all []unstructured.Unstructured, members map[string]*addonmgrv1alpha1.Addon) ([]string, error) {
+	selector, err := metav1.LabelSelectorAsSelector(addon.Spec.Target.ClusterSelector)
+	if err != nil {
+		return nil, fmt.Errorf("cluster selector is invalid. %v", err)
+	}
+
+	clusters := make([]string, 0, len(all))
+	for i := range all {
+		item := &all[i]
+		if item.GetDeletionTimestamp() != nil || !selector.Matches(labels.Set(item.GetLabels())) {
+			continue
+		}
+		if _, ok := members[MemberName(addon, item.GetName())]; ok || ClusterReady(item) {
+			clusters = append(clusters, item.GetName())
+		}
+	}
+	sort.Strings(clusters)
+	return clusters, nil
+}
+
+// member returns the addon installing the fleet addon in cluster through the Cluster API kubeconfig Secret,
+// the overrides matching the cluster are merged into its params
+func (s *Syncer) member(addon *addonmgrv1alpha1.Addon, item *unstructured.Unstructured) (*addonmgrv1alpha1.Addon, error) {
+	cluster := item.GetName()
+	member := &addonmgrv1alpha1.Addon{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      MemberName(addon, cluster),
+			Namespace: addon.Namespace,
+			Labels: map[string]string{
+				FleetLabel:   addon.Name,
+				ClusterLabel: cluster,
+			},
+		},
+		Spec: *addon.Spec.DeepCopy(),
+	}
+	member.Spec.Target = &addonmgrv1alpha1.AddonTarget{
+		ClusterRef: &addonmgrv1alpha1.ClusterReference{
+			Name: fmt.Sprintf("%s-kubeconfig", cluster),
+			Key:  KubeconfigKey,
+		},
+	}
+	if err := applyOverrides(&member.Spec.Params, addon.Spec.Target.Overrides, item); err != nil {
+		return nil, err
+	}
+	if err := controllerutil.SetControllerReference(addon, member, s.scheme); err != nil {
+		return nil, err
+	}
+	return member, nil
+}
+
+// applyOverrides merges the overrides matching the cluster into params in order
+func applyOverrides(params *addonmgrv1alpha1.AddonParams, overrides []addonmgrv1alpha1.ClusterOverride, cluster *unstructured.Unstructured) error {
+	for i, o := range overrides {
+		matches := common.ContainsString(o.Clusters, cluster.GetName())
+		if !matches && o.ClusterSelector != nil {
+			selector, err := metav1.LabelSelectorAsSelector(o.ClusterSelector)
+			if err != nil {
+				return fmt.Errorf("cluster selector of override %d is invalid. %v", i, err)
+			}
+			matches = selector.Matches(labels.Set(cluster.GetLabels()))
+		}
+		if !matches {
+			continue
+		}
+
+		params.Context = params.Context.Merge(o.Context)
+		params.Data = mergeParams(params.Data, o.Data)
+	}
+	return nil
+}
+
+func mergeParams(params, override map[string]addonmgrv1alpha1.FlexString) map[string]addonmgrv1alpha1.FlexString {
+	if len(override) == 0 {
+		return params
+	}
+	if params == nil {
+		params = make(map[string]addonmgrv1alpha1.FlexString, len(override))
+	}
+	for k, v := range override {
+		params[k] = v
+	}
+	return params
+}
+
+// Phase returns the install phase of a fleet from the status of its clusters, it is pending until every
+// cluster completed with the current addon spec and failed when any cluster failed or the rollout halted
+func Phase(addon *addonmgrv1alpha1.Addon, statuses []addonmgrv1alpha1.ClusterStatus) addonmgrv1alpha1.ApplicationAssemblyPhase {
+	if rollout := addon.Status.Rollout; rollout != nil && rollout.Halted {
+		return addonmgrv1alpha1.Failed
+	}
+	if len(statuses) == 0 {
+		return addonmgrv1alpha1.Pending
+	}
+
+	phase := addonmgrv1alpha1.Succeeded
+	for _, s := range statuses {
+		if s.Outdated {
+			return addonmgrv1alpha1.Pending
+		}
+		switch s.Installed {
+		case addonmgrv1alpha1.Succeeded:
+		case addonmgrv1alpha1.Failed, addonmgrv1alpha1.DeleteFailed:
+			phase = addonmgrv1alpha1.Failed
+		default:
+			return addonmgrv1alpha1.Pending
+		}
+	}
+	return phase
+}
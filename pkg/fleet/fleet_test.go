@@ -0,0 +1,339 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package fleet
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/clock"
+	dynfake "k8s.io/client-go/dynamic/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	runtimefake "sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	addonmgrv1alpha1 "github.com/keikoproj/addon-manager/api/v1alpha1"
+	"github.com/keikoproj/addon-manager/pkg/common"
+)
+
+func newCluster(name string, labels map[string]string) *unstructured.Unstructured {
+	c := &unstructured.Unstructured{}
+	c.SetGroupVersionKind(common.ClusterGVR().GroupVersion().WithKind("Cluster"))
+	c.SetNamespace("fleet")
+	c.SetName(name)
+	c.SetLabels(labels)
+	_ = unstructured.SetNestedSlice(c.Object, []interface{}{
+		map[string]interface{}{"type": "Ready", "status": "True"},
+	}, "status", "conditions")
+	return c
+}
+
+func newClusterScheme() *runtime.Scheme {
+	s := runtime.NewScheme()
+	gv := common.ClusterGVR().GroupVersion()
+	s.AddKnownTypeWithName(gv.WithKind("Cluster"), &unstructured.Unstructured{})
+	s.AddKnownTypeWithName(gv.WithKind("ClusterList"), &unstructured.UnstructuredList{})
+	return s
+}
+
+func TestSyncer_Sync(t *testing.T) {
+	g := NewGomegaWithT(t)
+	ctx := context.TODO()
+
+	s := runtime.NewScheme()
+	g.Expect(addonmgrv1alpha1.AddToScheme(s)).To(Succeed())
+	c := runtimefake.NewFakeClientWithScheme(s)
+	dynClient := dynfake.NewSimpleDynamicClient(newClusterScheme(),
+		newCluster("edge-1", map[string]string{"tier": "edge"}),
+		newCluster("edge-2", map[string]string{"tier": "edge"}),
+		newCluster("core-1", map[string]string{"tier": "core"}),
+	)
+
+	a := &addonmgrv1alpha1.Addon{
+		ObjectMeta: metav1.ObjectMeta{Name: "fleet-addon", Namespace: "fleet", UID: "1234"},
+		Spec: addonmgrv1alpha1.AddonSpec{
+			PackageSpec: addonmgrv1alpha1.PackageSpec{PkgName: "fleet-addon", PkgVersion: "1.0.0", PkgType: addonmgrv1alpha1.CompositePkg},
+			Params:      addonmgrv1alpha1.AddonParams{Namespace: "fleet-ns"},
+			Target: &addonmgrv1alpha1.AddonTarget{
+				ClusterSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"tier": "edge"}},
+			},
+		},
+	}
+	g.Expect(IsFleet(a)).To(BeTrue())
+
+	statuses, err := NewSyncer(c, dynClient, s).Sync(ctx, a)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(statuses).To(HaveLen(2))
+	g.Expect(statuses[0].Cluster).To(Equal("edge-1"))
+	g.Expect(statuses[0].Addon).To(Equal(MemberName(a, "edge-1")))
+	g.Expect(Phase(a, statuses)).To(Equal(addonmgrv1alpha1.Pending))
+
+	member := &addonmgrv1alpha1.Addon{}
+	g.Expect(c.Get(ctx, types.NamespacedName{Namespace: "fleet", Name: MemberName(a, "edge-1")}, member)).To(Succeed())
+	g.Expect(len(member.Name)).To(BeNumerically("<", 32))
+	g.Expect(member.Labels).To(HaveKeyWithValue(ClusterLabel, "edge-1"))
+	g.Expect(member.OwnerReferences).To(HaveLen(1))
+	g.Expect(IsFleet(member)).To(BeFalse())
+	g.Expect(member.Spec.Target.ClusterRef).To(Equal(&addonmgrv1alpha1.ClusterReference{Name: "edge-1-kubeconfig", Key: KubeconfigKey}))
+	g.Expect(member.Spec.Params.Namespace).To(Equal("fleet-ns"))
+
+	// Member status is reported and spec changes are propagated
+	complete(g, c, member, addonmgrv1alpha1.Succeeded)
+	statuses, err = NewSyncer(c, dynClient, s).Sync(ctx, a)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(statuses[0].Installed).To(Equal(addonmgrv1alpha1.Succeeded))
+
+	a.Spec.PkgVersion = "1.0.1"
+	statuses, err = NewSyncer(c, dynClient, s).Sync(ctx, a)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(statuses[0].Installed).To(Equal(addonmgrv1alpha1.Pending))
+	g.Expect(c.Get(ctx, types.NamespacedName{Namespace: "fleet", Name: MemberName(a, "edge-1")}, member)).To(Succeed())
+	g.Expect(member.Spec.PkgVersion).To(Equal("1.0.1"))
+
+	// Members of clusters that are no longer selected are removed
+	a.Spec.Target.ClusterSelector = &metav1.LabelSelector{MatchLabels: map[string]string{"tier": "core"}}
+	statuses, err = NewSyncer(c, dynClient, s).Sync(ctx, a)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(statuses).To(HaveLen(1))
+
+	var members addonmgrv1alpha1.AddonList
+	g.Expect(c.List(ctx, &members, client.MatchingLabels{FleetLabel: a.Name})).To(Succeed())
+	g.Expect(members.Items).To(HaveLen(1))
+	g.Expect(members.Items[0].Labels).To(HaveKeyWithValue(ClusterLabel, "core-1"))
+}
+
+func TestSyncer_Overrides(t *testing.T) {
+	g := NewGomegaWithT(t)
+	ctx := context.TODO()
+
+	s := runtime.NewScheme()
+	g.Expect(addonmgrv1alpha1.AddToScheme(s)).To(Succeed())
+	c := runtimefake.NewFakeClientWithScheme(s)
+	dynClient := dynfake.NewSimpleDynamicClient(newClusterScheme(),
+		newCluster("edge-1", map[string]string{"tier": "edge"}),
+		newCluster("edge-2", map[string]string{"tier": "edge", "size": "large"}),
+	)
+
+	a := &addonmgrv1alpha1.Addon{
+		ObjectMeta: metav1.ObjectMeta{Name: "fleet-addon", Namespace: "fleet", UID: "1234"},
+		Spec: addonmgrv1alpha1.AddonSpec{
+			PackageSpec: addonmgrv1alpha1.PackageSpec{PkgName: "fleet-addon", PkgVersion: "1.0.0", PkgType: addonmgrv1alpha1.CompositePkg},
+			Params: addonmgrv1alpha1.AddonParams{
+				Namespace: "fleet-ns",
+				Context:   addonmgrv1alpha1.ClusterContext{ClusterRegion: "us-east-1"},
+				Data:      map[string]addonmgrv1alpha1.FlexString{"replicas": "1", "logLevel": "info"},
+			},
+			Target: &addonmgrv1alpha1.AddonTarget{
+				ClusterSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"tier": "edge"}},
+				Overrides: []addonmgrv1alpha1.ClusterOverride{
+					{
+						Clusters: []string{"edge-1"},
+						Context:  addonmgrv1alpha1.ClusterContext{ClusterRegion: "us-west-2"},
+						Data:     map[string]addonmgrv1alpha1.FlexString{"vpcId": "vpc-1"},
+					},
+					{
+						ClusterSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"size": "large"}},
+						Data:            map[string]addonmgrv1alpha1.FlexString{"replicas": "3"},
+					},
+				},
+			},
+		},
+	}
+
+	_, err := NewSyncer(c, dynClient, s).Sync(ctx, a)
+	g.Expect(err).ToNot(HaveOccurred())
+
+	member := &addonmgrv1alpha1.Addon{}
+	g.Expect(c.Get(ctx, types.NamespacedName{Namespace: "fleet", Name: MemberName(a, "edge-1")}, member)).To(Succeed())
+	g.Expect(member.Spec.Params.Context.ClusterRegion).To(Equal("us-west-2"))
+	g.Expect(member.Spec.Params.Data).To(Equal(map[string]addonmgrv1alpha1.FlexString{"replicas": "1", "logLevel": "info", "vpcId": "vpc-1"}))
+	g.Expect(member.Spec.Target.Overrides).To(BeEmpty())
+
+	member = &addonmgrv1alpha1.Addon{}
+	g.Expect(c.Get(ctx, types.NamespacedName{Namespace: "fleet", Name: MemberName(a, "edge-2")}, member)).To(Succeed())
+	g.Expect(member.Spec.Params.Context.ClusterRegion).To(Equal("us-east-1"))
+	g.Expect(member.Spec.Params.Data).To(Equal(map[string]addonmgrv1alpha1.FlexString{"replicas": "3", "logLevel": "info"}))
+
+	// The fleet addon params are not changed
+	g.Expect(a.Spec.Params.Data).To(HaveLen(2))
+}
+
+func complete(g *GomegaWithT, c client.Client, member *addonmgrv1alpha1.Addon, phase addonmgrv1alpha1.ApplicationAssemblyPhase) {
+	member.Status.Checksum = member.CalculateChecksum()
+	member.Status.Lifecycle.Installed = phase
+	g.Expect(c.Update(context.TODO(), member)).To(Succeed())
+}
+
+func TestSyncer_Rollout(t *testing.T) {
+	g := NewGomegaWithT(t)
+	ctx := context.TODO()
+
+	s := runtime.NewScheme()
+	g.Expect(addonmgrv1alpha1.AddToScheme(s)).To(Succeed())
+	c := runtimefake.NewFakeClientWithScheme(s)
+	var clusters []runtime.Object
+	for _, name := range []string{"c0", "c1", "c2", "c3", "c4", "c5", "c6", "c7", "c8", "c9"} {
+		clusters = append(clusters, newCluster(name, map[string]string{"tier": "edge"}))
+	}
+	dynClient := dynfake.NewSimpleDynamicClient(newClusterScheme(), clusters...)
+
+	fakeClock := clock.NewFakeClock(time.Now())
+	syncer := NewSyncer(c, dynClient, s)
+	syncer.SetClock(fakeClock)
+
+	a := &addonmgrv1alpha1.Addon{
+		ObjectMeta: metav1.ObjectMeta{Name: "rollout-addon", Namespace: "fleet", UID: "1234"},
+		Spec: addonmgrv1alpha1.AddonSpec{
+			PackageSpec: addonmgrv1alpha1.PackageSpec{PkgName: "rollout-addon", PkgVersion: "1.0.0", PkgType: addonmgrv1alpha1.CompositePkg},
+			Params:      addonmgrv1alpha1.AddonParams{Namespace: "fleet-ns"},
+			Target: &addonmgrv1alpha1.AddonTarget{
+				ClusterSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"tier": "edge"}},
+				Rollout: &addonmgrv1alpha1.RolloutStrategy{
+					CanaryPercent: 10,
+					WavePercent:   50,
+					SoakTime:      &metav1.Duration{Duration: time.Hour},
+				},
+			},
+		},
+	}
+	sync := func() []addonmgrv1alpha1.ClusterStatus {
+		statuses, err := syncer.Sync(ctx, a)
+		g.Expect(err).ToNot(HaveOccurred())
+		return statuses
+	}
+	completeAll := func(phase addonmgrv1alpha1.ApplicationAssemblyPhase) {
+		var members addonmgrv1alpha1.AddonList
+		g.Expect(c.List(ctx, &members)).To(Succeed())
+		for i := range members.Items {
+			complete(g, c, &members.Items[i], phase)
+		}
+	}
+
+	// Canary wave
+	statuses := sync()
+	g.Expect(statuses[0].Outdated).To(BeFalse())
+	g.Expect(statuses[1].Outdated).To(BeTrue())
+	g.Expect(Phase(a, statuses)).To(Equal(addonmgrv1alpha1.Pending))
+
+	// The next wave waits for the canary to succeed and soak
+	sync()
+	g.Expect(a.Status.Rollout.Updated).To(Equal(int32(1)))
+	g.Expect(a.Status.Rollout.SoakStartTime).To(BeNil())
+	completeAll(addonmgrv1alpha1.Succeeded)
+	sync()
+	g.Expect(a.Status.Rollout.SoakStartTime).ToNot(BeNil())
+	fakeClock.Step(time.Hour)
+	statuses = sync()
+	g.Expect(statuses[5].Outdated).To(BeFalse())
+	g.Expect(statuses[6].Outdated).To(BeTrue())
+
+	completeAll(addonmgrv1alpha1.Succeeded)
+	sync()
+	fakeClock.Step(time.Hour)
+	statuses = sync()
+	g.Expect(statuses[9].Outdated).To(BeFalse())
+	completeAll(addonmgrv1alpha1.Succeeded)
+	statuses = sync()
+	g.Expect(a.Status.Rollout.Updated).To(Equal(int32(10)))
+	g.Expect(Phase(a, statuses)).To(Equal(addonmgrv1alpha1.Succeeded))
+
+	// A failed canary halts the rollout of a new version
+	a.Spec.PkgVersion = "1.0.1"
+	sync()
+	completeAll(addonmgrv1alpha1.Failed)
+	fakeClock.Step(time.Hour)
+	statuses = sync()
+	g.Expect(a.Status.Rollout.Halted).To(BeTrue())
+	g.Expect(a.Status.Rollout.Updated).To(Equal(int32(1)))
+	g.Expect(statuses[1].Outdated).To(BeTrue())
+	g.Expect(Phase(a, statuses)).To(Equal(addonmgrv1alpha1.Failed))
+}
+
+func TestPhase(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	a := &addonmgrv1alpha1.Addon{}
+	g.Expect(Phase(a, nil)).To(Equal(addonmgrv1alpha1.Pending))
+	g.Expect(Phase(a, []addonmgrv1alpha1.ClusterStatus{{Installed: addonmgrv1alpha1.Succeeded}, {Installed: addonmgrv1alpha1.Succeeded}})).To(Equal(addonmgrv1alpha1.Succeeded))
+	g.Expect(Phase(a, []addonmgrv1alpha1.ClusterStatus{{Installed: addonmgrv1alpha1.Failed}, {Installed: addonmgrv1alpha1.Succeeded}})).To(Equal(addonmgrv1alpha1.Failed))
+	g.Expect(Phase(a, []addonmgrv1alpha1.ClusterStatus{{Installed: addonmgrv1alpha1.Failed}, {Installed: ""}})).To(Equal(addonmgrv1alpha1.Pending))
+	g.Expect(Phase(a, []addonmgrv1alpha1.ClusterStatus{{Installed: addonmgrv1alpha1.Succeeded, Outdated: true}})).To(Equal(addonmgrv1alpha1.Pending))
+}
+
+func TestWaveSize(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	g.Expect(waveSize(10, 0)).To(Equal(10))
+	g.Expect(waveSize(10, 25)).To(Equal(3))
+	g.Expect(waveSize(3, 1)).To(Equal(1))
+	g.Expect(waveSize(3, 100)).To(Equal(3))
+}
+
+func TestSyncer_LifecycleHooks(t *testing.T) {
+	g := NewGomegaWithT(t)
+	ctx := context.TODO()
+
+	s := runtime.NewScheme()
+	g.Expect(addonmgrv1alpha1.AddToScheme(s)).To(Succeed())
+	c := runtimefake.NewFakeClientWithScheme(s)
+	provisioning := newCluster("edge-2", map[string]string{"tier": "edge"})
+	_ = unstructured.SetNestedSlice(provisioning.Object, []interface{}{
+		map[string]interface{}{"type": "Ready", "status": "False"},
+	}, "status", "conditions")
+	dynClient := dynfake.NewSimpleDynamicClient(newClusterScheme(), newCluster("edge-1", map[string]string{"tier": "edge"}), provisioning)
+	syncer := NewSyncer(c, dynClient, s)
+	syncer.SetLifecycleHooks(true)
+
+	a := &addonmgrv1alpha1.Addon{
+		ObjectMeta: metav1.ObjectMeta{Name: "hooks-addon", Namespace: "fleet", UID: "1234"},
+		Spec: addonmgrv1alpha1.AddonSpec{
+			PackageSpec: addonmgrv1alpha1.PackageSpec{PkgName: "hooks-addon", PkgVersion: "1.0.0", PkgType: addonmgrv1alpha1.CompositePkg},
+			Target: &addonmgrv1alpha1.AddonTarget{
+				ClusterSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"tier": "edge"}},
+			},
+		},
+	}
+
+	// Clusters are selected once they are ready
+	statuses, err := syncer.Sync(ctx, a)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(statuses).To(HaveLen(1))
+	g.Expect(statuses[0].Cluster).To(Equal("edge-1"))
+
+	cluster, err := dynClient.Resource(common.ClusterGVR()).Namespace("fleet").Get(ctx, "edge-1", metav1.GetOptions{})
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(cluster.GetFinalizers()).To(ConsistOf(ClusterFinalizer(a)))
+
+	// Members being deleted hold the cluster
+	done, err := syncer.Release(ctx, a)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(done).To(BeFalse())
+	cluster, err = dynClient.Resource(common.ClusterGVR()).Namespace("fleet").Get(ctx, "edge-1", metav1.GetOptions{})
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(cluster.GetFinalizers()).To(ConsistOf(ClusterFinalizer(a)))
+
+	// The cluster is released once the member addon is gone, the fake client deletes it right away
+	done, err = syncer.Release(ctx, a)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(done).To(BeTrue())
+	cluster, err = dynClient.Resource(common.ClusterGVR()).Namespace("fleet").Get(ctx, "edge-1", metav1.GetOptions{})
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(cluster.GetFinalizers()).To(BeEmpty())
+}
@@ -0,0 +1,119 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package fleet
+
+import (
+	"context"
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	addonmgrv1alpha1 "github.com/keikoproj/addon-manager/api/v1alpha1"
+	"github.com/keikoproj/addon-manager/pkg/common"
+)
+
+// ClusterFinalizerPrefix prefixes the finalizer a fleet addon sets on the clusters it installed the addon in
+const ClusterFinalizerPrefix = "addonmgr.keikoproj.io/"
+
+// SetLifecycleHooks makes the Syncer hold a finalizer on selected clusters so member addons can run their
+// delete workflows with the cluster kubeconfig before the Cluster object is removed
+func (s *Syncer) SetLifecycleHooks(enabled bool) {
+	s.hooks = enabled
+}
+
+// ClusterFinalizer returns the finalizer of addon on its selected clusters
+func ClusterFinalizer(addon *addonmgrv1alpha1.Addon) string {
+	return ClusterFinalizerPrefix + addon.GetName()
+}
+
+// ClusterReady returns true when the Cluster API cluster has a Ready condition, or is provisioned for
+// clusters that do not report conditions
+func ClusterReady(cluster *unstructured.Unstructured) bool {
+	conditions, _, _ := unstructured.NestedSlice(cluster.Object, "status", "conditions")
+	for _, c := range conditions {
+		condition, ok := c.(map[string]interface{})
+		if ok && condition["type"] == "Ready" {
+			return condition["status"] == "True"
+		}
+	}
+	phase, _, _ := unstructured.NestedString(cluster.Object, "status", "phase")
+	return phase == "Provisioned"
+}
+
+// Release deletes the member addons of a fleet addon and removes its finalizer from the clusters once they are
+// gone, it returns false while member addons are still running their delete workflows
+func (s *Syncer) Release(ctx context.Context, addon *addonmgrv1alpha1.Addon) (bool, error) {
+	existing, err := s.members(ctx, addon)
+	if err != nil {
+		return false, err
+	}
+	for _, member := range existing {
+		if err := s.client.Delete(ctx, member); client.IgnoreNotFound(err) != nil {
+			return false, fmt.Errorf("failed to delete member addon %s. %v", member.Name, err)
+		}
+	}
+
+	if !s.hooks {
+		return true, nil
+	}
+	all, err := s.listClusters(ctx, addon)
+	if err != nil {
+		return false, err
+	}
+	if err := s.updateClusterFinalizers(ctx, addon, all, nil, existing); err != nil {
+		return false, err
+	}
+	for _, member := range existing {
+		if member.Status.Lifecycle.Installed != addonmgrv1alpha1.DeleteFailed {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// updateClusterFinalizers holds the addon finalizer on selected clusters and on clusters with member addons
+// that are being deleted, members that failed to delete no longer hold the cluster
+func (s *Syncer) updateClusterFinalizers(ctx context.Context, addon *addonmgrv1alpha1.Addon, all []unstructured.Unstructured, selected []string, deleting map[string]*addonmgrv1alpha1.Addon) error {
+	hold := make(map[string]bool, len(selected)+len(deleting))
+	for _, cluster := range selected {
+		hold[cluster] = true
+	}
+	for _, member := range deleting {
+		if member.Status.Lifecycle.Installed != addonmgrv1alpha1.DeleteFailed {
+			hold[member.Labels[ClusterLabel]] = true
+		}
+	}
+
+	finalizer := ClusterFinalizer(addon)
+	for i := range all {
+		cluster := &all[i]
+		finalizers := cluster.GetFinalizers()
+		has := common.ContainsString(finalizers, finalizer)
+		switch {
+		case hold[cluster.GetName()] && !has && cluster.GetDeletionTimestamp() == nil:
+			cluster.SetFinalizers(append(finalizers, finalizer))
+		case !hold[cluster.GetName()] && has:
+			cluster.SetFinalizers(common.RemoveString(finalizers, finalizer))
+		default:
+			continue
+		}
+		if _, err := s.dynClient.Resource(common.ClusterGVR()).Namespace(cluster.GetNamespace()).Update(ctx, cluster, metav1.UpdateOptions{}); err != nil {
+			return fmt.Errorf("failed to update finalizers of cluster %s. %v", cluster.GetName(), err)
+		}
+	}
+	return nil
+}
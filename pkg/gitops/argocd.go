@@ -0,0 +1,138 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gitops
+
+import (
+	"context"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/dynamic"
+
+	addonmgrv1alpha1 "github.com/keikoproj/addon-manager/api/v1alpha1"
+	"github.com/keikoproj/addon-manager/pkg/common"
+)
+
+const (
+	// ArgoCDResourcesFinalizer makes Argo CD delete the resources of an application before the application
+	ArgoCDResourcesFinalizer = "resources-finalizer.argocd.argoproj.io"
+	// LocalServer is the Argo CD destination of the cluster Argo CD runs in
+	LocalServer = "https://kubernetes.default.svc"
+)
+
+// ArgoCD renders an Argo CD Application per addon with automated sync
+type ArgoCD struct {
+	dynClient dynamic.Interface
+	namespace string
+	project   string
+}
+
+// NewArgoCD returns a Generator creating applications of project in the Argo CD namespace
+func NewArgoCD(dynClient dynamic.Interface, namespace, project string) *ArgoCD {
+	return &ArgoCD{dynClient: dynClient, namespace: namespace, project: project}
+}
+
+// Application returns the Argo CD Application of the addon, remote addons are synced to the API server of
+// their cluster which has to be registered in Argo CD
+func (a *ArgoCD) Application(addon *addonmgrv1alpha1.Addon) (*unstructured.Unstructured, error) {
+	src, err := source(addon)
+	if err != nil {
+		return nil, err
+	}
+
+	appSource := map[string]interface{}{
+		"repoURL":        src.RepoURL,
+		"targetRevision": revision(addon),
+	}
+	if src.Path != "" {
+		appSource["path"] = src.Path
+	}
+	if src.Chart != "" || addon.Spec.PkgType == addonmgrv1alpha1.HelmPkg {
+		if src.Chart != "" {
+			appSource["chart"] = src.Chart
+		}
+		names, params := values(addon)
+		parameters := make([]interface{}, 0, len(names))
+		for _, name := range names {
+			parameters = append(parameters, map[string]interface{}{"name": name, "value": params[name]})
+		}
+		appSource["helm"] = map[string]interface{}{"releaseName": addon.Name, "parameters": parameters}
+	}
+
+	server := LocalServer
+	if addon.Status.Cluster != "" {
+		server = addon.Status.Cluster
+	}
+
+	app := &unstructured.Unstructured{Object: map[string]interface{}{
+		"spec": map[string]interface{}{
+			"project": a.project,
+			"source":  appSource,
+			"destination": map[string]interface{}{
+				"server":    server,
+				"namespace": addon.Spec.Params.Namespace,
+			},
+			"syncPolicy": map[string]interface{}{
+				"automated":   map[string]interface{}{"prune": true, "selfHeal": true},
+				"syncOptions": []interface{}{"CreateNamespace=true"},
+			},
+		},
+	}}
+	app.SetGroupVersionKind(common.ApplicationGVR().GroupVersion().WithKind("Application"))
+	app.SetNamespace(a.namespace)
+	app.SetName(ObjectName(addon))
+	app.SetLabels(labels(addon))
+	app.SetFinalizers([]string{ArgoCDResourcesFinalizer})
+	return app, nil
+}
+
+// Apply implements Generator
+func (a *ArgoCD) Apply(ctx context.Context, addon *addonmgrv1alpha1.Addon) (addonmgrv1alpha1.ApplicationAssemblyPhase, string, error) {
+	app, err := a.Application(addon)
+	if err != nil {
+		return addonmgrv1alpha1.Failed, "", err
+	}
+	current, err := apply(ctx, a.dynClient, common.ApplicationGVR(), app)
+	if err != nil {
+		return addonmgrv1alpha1.Failed, "", err
+	}
+	phase, reason := ApplicationPhase(current)
+	return phase, reason, nil
+}
+
+// Delete implements Generator, Argo CD deletes the application resources before the application
+func (a *ArgoCD) Delete(ctx context.Context, addon *addonmgrv1alpha1.Addon) (bool, error) {
+	return remove(ctx, a.dynClient, common.ApplicationGVR(), a.namespace, ObjectName(addon))
+}
+
+// ApplicationPhase maps the sync and health status of an application to an addon phase, the addon succeeded
+// when the application is synced and healthy and failed when the sync failed or the application is degraded
+func ApplicationPhase(app *unstructured.Unstructured) (addonmgrv1alpha1.ApplicationAssemblyPhase, string) {
+	operation, _, _ := unstructured.NestedString(app.Object, "status", "operationState", "phase")
+	if operation == "Failed" || operation == "Error" {
+		message, _, _ := unstructured.NestedString(app.Object, "status", "operationState", "message")
+		return addonmgrv1alpha1.Failed, message
+	}
+
+	health, _, _ := unstructured.NestedString(app.Object, "status", "health", "status")
+	sync, _, _ := unstructured.NestedString(app.Object, "status", "sync", "status")
+	switch {
+	case health == "Degraded":
+		message, _, _ := unstructured.NestedString(app.Object, "status", "health", "message")
+		return addonmgrv1alpha1.Failed, message
+	case health == "Healthy" && sync == "Synced":
+		return addonmgrv1alpha1.Succeeded, ""
+	}
+	return addonmgrv1alpha1.Pending, ""
+}
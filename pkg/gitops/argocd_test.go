@@ -0,0 +1,122 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gitops
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynfake "k8s.io/client-go/dynamic/fake"
+
+	addonmgrv1alpha1 "github.com/keikoproj/addon-manager/api/v1alpha1"
+	"github.com/keikoproj/addon-manager/pkg/common"
+)
+
+func newGitOpsScheme(gvr schema.GroupVersionResource, kind string) *runtime.Scheme {
+	s := runtime.NewScheme()
+	s.AddKnownTypeWithName(gvr.GroupVersion().WithKind(kind), &unstructured.Unstructured{})
+	s.AddKnownTypeWithName(gvr.GroupVersion().WithKind(kind+"List"), &unstructured.UnstructuredList{})
+	return s
+}
+
+func newGitOpsAddon() *addonmgrv1alpha1.Addon {
+	return &addonmgrv1alpha1.Addon{
+		ObjectMeta: metav1.ObjectMeta{Name: "metrics-server", Namespace: "addon-manager-system"},
+		Spec: addonmgrv1alpha1.AddonSpec{
+			PackageSpec: addonmgrv1alpha1.PackageSpec{PkgName: "metrics-server", PkgVersion: "5.3.2", PkgType: addonmgrv1alpha1.HelmPkg},
+			Params: addonmgrv1alpha1.AddonParams{
+				Namespace: "metrics",
+				Data:      map[string]addonmgrv1alpha1.FlexString{"replicas": "2"},
+			},
+			Source: &addonmgrv1alpha1.AddonSource{RepoURL: "https://charts.bitnami.com/bitnami", Chart: "metrics-server"},
+		},
+	}
+}
+
+func TestArgoCD_Apply(t *testing.T) {
+	g := NewGomegaWithT(t)
+	ctx := context.TODO()
+
+	dynClient := dynfake.NewSimpleDynamicClient(newGitOpsScheme(common.ApplicationGVR(), "Application"))
+	argo := NewArgoCD(dynClient, "argocd", "default")
+	a := newGitOpsAddon()
+
+	phase, _, err := argo.Apply(ctx, a)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(phase).To(Equal(addonmgrv1alpha1.Pending))
+
+	app, err := dynClient.Resource(common.ApplicationGVR()).Namespace("argocd").Get(ctx, "addon-manager-system-metrics-server", metav1.GetOptions{})
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(app.GetFinalizers()).To(ConsistOf(ArgoCDResourcesFinalizer))
+	revision, _, _ := unstructured.NestedString(app.Object, "spec", "source", "targetRevision")
+	g.Expect(revision).To(Equal("5.3.2"))
+	server, _, _ := unstructured.NestedString(app.Object, "spec", "destination", "server")
+	g.Expect(server).To(Equal(LocalServer))
+	params, _, _ := unstructured.NestedSlice(app.Object, "spec", "source", "helm", "parameters")
+	g.Expect(params).To(ConsistOf(
+		map[string]interface{}{"name": "namespace", "value": "metrics"},
+		map[string]interface{}{"name": "replicas", "value": "2"},
+	))
+
+	// Health is mapped back to the addon phase
+	g.Expect(unstructured.SetNestedField(app.Object, "Healthy", "status", "health", "status")).To(Succeed())
+	g.Expect(unstructured.SetNestedField(app.Object, "Synced", "status", "sync", "status")).To(Succeed())
+	_, err = dynClient.Resource(common.ApplicationGVR()).Namespace("argocd").Update(ctx, app, metav1.UpdateOptions{})
+	g.Expect(err).ToNot(HaveOccurred())
+
+	a.Status.Cluster = "https://edge.example.com:6443"
+	phase, _, err = argo.Apply(ctx, a)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(phase).To(Equal(addonmgrv1alpha1.Succeeded))
+	app, err = dynClient.Resource(common.ApplicationGVR()).Namespace("argocd").Get(ctx, "addon-manager-system-metrics-server", metav1.GetOptions{})
+	g.Expect(err).ToNot(HaveOccurred())
+	server, _, _ = unstructured.NestedString(app.Object, "spec", "destination", "server")
+	g.Expect(server).To(Equal("https://edge.example.com:6443"))
+
+	done, err := argo.Delete(ctx, a)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(done).To(BeFalse())
+	done, err = argo.Delete(ctx, a)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(done).To(BeTrue())
+
+	a.Spec.Source = nil
+	_, _, err = argo.Apply(ctx, a)
+	g.Expect(err).To(MatchError(ContainSubstring("no source repository")))
+}
+
+func TestApplicationPhase(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	app := &unstructured.Unstructured{Object: map[string]interface{}{}}
+	phase, _ := ApplicationPhase(app)
+	g.Expect(phase).To(Equal(addonmgrv1alpha1.Pending))
+
+	g.Expect(unstructured.SetNestedField(app.Object, "Degraded", "status", "health", "status")).To(Succeed())
+	g.Expect(unstructured.SetNestedField(app.Object, "Deployment exceeded its progress deadline", "status", "health", "message")).To(Succeed())
+	phase, reason := ApplicationPhase(app)
+	g.Expect(phase).To(Equal(addonmgrv1alpha1.Failed))
+	g.Expect(reason).To(ContainSubstring("progress deadline"))
+
+	g.Expect(unstructured.SetNestedField(app.Object, "Failed", "status", "operationState", "phase")).To(Succeed())
+	g.Expect(unstructured.SetNestedField(app.Object, "one or more objects failed to apply", "status", "operationState", "message")).To(Succeed())
+	_, reason = ApplicationPhase(app)
+	g.Expect(reason).To(Equal("one or more objects failed to apply"))
+}
@@ -0,0 +1,198 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gitops
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+
+	addonmgrv1alpha1 "github.com/keikoproj/addon-manager/api/v1alpha1"
+	"github.com/keikoproj/addon-manager/pkg/common"
+)
+
+var commitSHA = regexp.MustCompile(`^[0-9a-f]{40}$`)
+
+// Flux renders a HelmRelease for Helm addons and a Kustomization for other addons, with the source they are
+// installed from, in the addon namespace. Dependencies are still ordered by the manager which only applies an
+// addon once its dependencies succeeded.
+type Flux struct {
+	dynClient dynamic.Interface
+	interval  time.Duration
+}
+
+// NewFlux returns a Generator creating Flux objects reconciled every interval
+func NewFlux(dynClient dynamic.Interface, interval time.Duration) *Flux {
+	return &Flux{dynClient: dynClient, interval: interval}
+}
+
+type fluxObject struct {
+	gvr schema.GroupVersionResource
+	obj *unstructured.Unstructured
+}
+
+// isHelm returns true for addons installed with a HelmRelease
+func isHelm(addon *addonmgrv1alpha1.Addon) bool {
+	return addon.Spec.PkgType == addonmgrv1alpha1.HelmPkg || (addon.Spec.Source != nil && addon.Spec.Source.Chart != "")
+}
+
+// objects returns the source and the HelmRelease or Kustomization of the addon, remote addons are applied
+// with the kubeconfig Secret of their cluster
+func (f *Flux) objects(addon *addonmgrv1alpha1.Addon) ([]fluxObject, error) {
+	src, err := source(addon)
+	if err != nil {
+		return nil, err
+	}
+	interval := f.interval.String()
+
+	sourceGVR, sourceKind := common.GitRepositoryGVR(), "GitRepository"
+	sourceSpec := map[string]interface{}{"url": src.RepoURL, "interval": interval}
+	if isHelm(addon) {
+		sourceGVR, sourceKind = common.HelmRepositoryGVR(), "HelmRepository"
+	} else if rev := revision(addon); commitSHA.MatchString(rev) {
+		sourceSpec["ref"] = map[string]interface{}{"commit": rev}
+	} else {
+		sourceSpec["ref"] = map[string]interface{}{"tag": rev}
+	}
+	sourceRef := map[string]interface{}{"kind": sourceKind, "name": addon.Name}
+
+	names, params := values(addon)
+	spec := map[string]interface{}{
+		"interval":        interval,
+		"targetNamespace": addon.Spec.Params.Namespace,
+	}
+	if ref := addon.Spec.Target; ref != nil && ref.ClusterRef != nil {
+		spec["kubeConfig"] = map[string]interface{}{"secretRef": map[string]interface{}{"name": ref.ClusterRef.Name}}
+	}
+
+	gvr, kind := common.KustomizationGVR(), "Kustomization"
+	if isHelm(addon) {
+		gvr, kind = common.HelmReleaseGVR(), "HelmRelease"
+		chart := src.Chart
+		if chart == "" {
+			chart = src.Path
+		}
+		spec["chart"] = map[string]interface{}{
+			"spec": map[string]interface{}{"chart": chart, "version": revision(addon), "sourceRef": sourceRef},
+		}
+		spec["install"] = map[string]interface{}{"createNamespace": true}
+		helmValues := map[string]interface{}{}
+		for _, name := range names {
+			setValue(helmValues, strings.Split(name, "."), params[name])
+		}
+		spec["values"] = helmValues
+	} else {
+		spec["sourceRef"] = sourceRef
+		spec["path"] = src.Path
+		spec["prune"] = true
+		substitute := make(map[string]interface{}, len(names))
+		for _, name := range names {
+			substitute[name] = params[name]
+		}
+		spec["postBuild"] = map[string]interface{}{"substitute": substitute}
+	}
+
+	return []fluxObject{
+		{gvr: sourceGVR, obj: newFluxObject(addon, sourceGVR, sourceKind, sourceSpec)},
+		{gvr: gvr, obj: newFluxObject(addon, gvr, kind, spec)},
+	}, nil
+}
+
+func newFluxObject(addon *addonmgrv1alpha1.Addon, gvr schema.GroupVersionResource, kind string, spec map[string]interface{}) *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{Object: map[string]interface{}{"spec": spec}}
+	obj.SetGroupVersionKind(gvr.GroupVersion().WithKind(kind))
+	obj.SetNamespace(addon.Namespace)
+	obj.SetName(addon.Name)
+	obj.SetLabels(labels(addon))
+	return obj
+}
+
+// setValue sets a dotted Helm value name in nested maps
+func setValue(values map[string]interface{}, path []string, value string) {
+	for _, key := range path[:len(path)-1] {
+		next, ok := values[key].(map[string]interface{})
+		if !ok {
+			next = map[string]interface{}{}
+			values[key] = next
+		}
+		values = next
+	}
+	values[path[len(path)-1]] = value
+}
+
+// Apply implements Generator, the addon phase is the least complete phase of the source and the release
+func (f *Flux) Apply(ctx context.Context, addon *addonmgrv1alpha1.Addon) (addonmgrv1alpha1.ApplicationAssemblyPhase, string, error) {
+	objects, err := f.objects(addon)
+	if err != nil {
+		return addonmgrv1alpha1.Failed, "", err
+	}
+
+	phase, reason := addonmgrv1alpha1.Succeeded, ""
+	for _, o := range objects {
+		current, err := apply(ctx, f.dynClient, o.gvr, o.obj)
+		if err != nil {
+			return addonmgrv1alpha1.Failed, "", err
+		}
+		p, r := FluxPhase(current)
+		switch {
+		case p == addonmgrv1alpha1.Failed:
+			return p, r, nil
+		case p == addonmgrv1alpha1.Pending:
+			phase, reason = p, r
+		}
+	}
+	return phase, reason, nil
+}
+
+// Delete implements Generator, the release or kustomization is removed first so Flux can uninstall it from its source
+func (f *Flux) Delete(ctx context.Context, addon *addonmgrv1alpha1.Addon) (bool, error) {
+	gvrs := []schema.GroupVersionResource{common.KustomizationGVR(), common.GitRepositoryGVR()}
+	if isHelm(addon) {
+		gvrs = []schema.GroupVersionResource{common.HelmReleaseGVR(), common.HelmRepositoryGVR()}
+	}
+	for _, gvr := range gvrs {
+		done, err := remove(ctx, f.dynClient, gvr, addon.Namespace, addon.Name)
+		if err != nil || !done {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// FluxPhase maps the Ready condition of a Flux object to an addon phase, objects that are not ready are pending
+// while Flux is progressing and failed otherwise
+func FluxPhase(obj *unstructured.Unstructured) (addonmgrv1alpha1.ApplicationAssemblyPhase, string) {
+	conditions, _, _ := unstructured.NestedSlice(obj.Object, "status", "conditions")
+	for _, c := range conditions {
+		condition, ok := c.(map[string]interface{})
+		if !ok || condition["type"] != "Ready" {
+			continue
+		}
+		message, _ := condition["message"].(string)
+		switch {
+		case condition["status"] == "True":
+			return addonmgrv1alpha1.Succeeded, ""
+		case condition["status"] == "False" && condition["reason"] != "Progressing":
+			return addonmgrv1alpha1.Failed, message
+		}
+		return addonmgrv1alpha1.Pending, message
+	}
+	return addonmgrv1alpha1.Pending, ""
+}
@@ -0,0 +1,171 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gitops
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynfake "k8s.io/client-go/dynamic/fake"
+
+	addonmgrv1alpha1 "github.com/keikoproj/addon-manager/api/v1alpha1"
+	"github.com/keikoproj/addon-manager/pkg/common"
+)
+
+func newFluxScheme() *runtime.Scheme {
+	s := runtime.NewScheme()
+	for kind, gvr := range map[string]schema.GroupVersionResource{
+		"HelmRepository": common.HelmRepositoryGVR(),
+		"GitRepository":  common.GitRepositoryGVR(),
+		"HelmRelease":    common.HelmReleaseGVR(),
+		"Kustomization":  common.KustomizationGVR(),
+	} {
+		s.AddKnownTypeWithName(gvr.GroupVersion().WithKind(kind), &unstructured.Unstructured{})
+		s.AddKnownTypeWithName(gvr.GroupVersion().WithKind(kind+"List"), &unstructured.UnstructuredList{})
+	}
+	return s
+}
+
+func setReady(obj *unstructured.Unstructured, status, reason, message string) {
+	condition := map[string]interface{}{"type": "Ready", "status": status, "reason": reason, "message": message}
+	_ = unstructured.SetNestedSlice(obj.Object, []interface{}{condition}, "status", "conditions")
+}
+
+func TestFlux_ApplyHelmRelease(t *testing.T) {
+	g := NewGomegaWithT(t)
+	ctx := context.TODO()
+
+	dynClient := dynfake.NewSimpleDynamicClient(newFluxScheme())
+	flux := NewFlux(dynClient, 5*time.Minute)
+	a := newGitOpsAddon()
+	a.Spec.Params.Data["image.tag"] = "v0.5.0"
+
+	phase, _, err := flux.Apply(ctx, a)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(phase).To(Equal(addonmgrv1alpha1.Pending))
+
+	repo, err := dynClient.Resource(common.HelmRepositoryGVR()).Namespace(a.Namespace).Get(ctx, a.Name, metav1.GetOptions{})
+	g.Expect(err).ToNot(HaveOccurred())
+	url, _, _ := unstructured.NestedString(repo.Object, "spec", "url")
+	g.Expect(url).To(Equal("https://charts.bitnami.com/bitnami"))
+
+	release, err := dynClient.Resource(common.HelmReleaseGVR()).Namespace(a.Namespace).Get(ctx, a.Name, metav1.GetOptions{})
+	g.Expect(err).ToNot(HaveOccurred())
+	version, _, _ := unstructured.NestedString(release.Object, "spec", "chart", "spec", "version")
+	g.Expect(version).To(Equal("5.3.2"))
+	tag, _, _ := unstructured.NestedString(release.Object, "spec", "values", "image", "tag")
+	g.Expect(tag).To(Equal("v0.5.0"))
+	_, found, _ := unstructured.NestedMap(release.Object, "spec", "kubeConfig")
+	g.Expect(found).To(BeFalse())
+
+	// The addon succeeds once both the source and the release are ready
+	setReady(release, "True", "ReconciliationSucceeded", "")
+	_, err = dynClient.Resource(common.HelmReleaseGVR()).Namespace(a.Namespace).Update(ctx, release, metav1.UpdateOptions{})
+	g.Expect(err).ToNot(HaveOccurred())
+	phase, _, err = flux.Apply(ctx, a)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(phase).To(Equal(addonmgrv1alpha1.Pending))
+
+	repo, err = dynClient.Resource(common.HelmRepositoryGVR()).Namespace(a.Namespace).Get(ctx, a.Name, metav1.GetOptions{})
+	g.Expect(err).ToNot(HaveOccurred())
+	setReady(repo, "False", "IndexationFailed", "failed to fetch index")
+	_, err = dynClient.Resource(common.HelmRepositoryGVR()).Namespace(a.Namespace).Update(ctx, repo, metav1.UpdateOptions{})
+	g.Expect(err).ToNot(HaveOccurred())
+	phase, reason, err := flux.Apply(ctx, a)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(phase).To(Equal(addonmgrv1alpha1.Failed))
+	g.Expect(reason).To(Equal("failed to fetch index"))
+
+	setReady(repo, "True", "IndexationSucceed", "")
+	_, err = dynClient.Resource(common.HelmRepositoryGVR()).Namespace(a.Namespace).Update(ctx, repo, metav1.UpdateOptions{})
+	g.Expect(err).ToNot(HaveOccurred())
+	phase, _, err = flux.Apply(ctx, a)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(phase).To(Equal(addonmgrv1alpha1.Succeeded))
+
+	// The release is removed before its repository
+	done, err := flux.Delete(ctx, a)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(done).To(BeFalse())
+	_, err = dynClient.Resource(common.HelmRepositoryGVR()).Namespace(a.Namespace).Get(ctx, a.Name, metav1.GetOptions{})
+	g.Expect(err).ToNot(HaveOccurred())
+	done, err = flux.Delete(ctx, a)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(done).To(BeFalse())
+	done, err = flux.Delete(ctx, a)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(done).To(BeTrue())
+}
+
+func TestFlux_ApplyKustomization(t *testing.T) {
+	g := NewGomegaWithT(t)
+	ctx := context.TODO()
+
+	dynClient := dynfake.NewSimpleDynamicClient(newFluxScheme())
+	flux := NewFlux(dynClient, time.Minute)
+	a := newGitOpsAddon()
+	a.Spec.PkgType = addonmgrv1alpha1.KustomizePkg
+	a.Spec.Source = &addonmgrv1alpha1.AddonSource{RepoURL: "https://github.com/example/addons", Path: "metrics-server/overlays/prod"}
+	a.Spec.Target = &addonmgrv1alpha1.AddonTarget{ClusterRef: &addonmgrv1alpha1.ClusterReference{Name: "edge-kubeconfig"}}
+
+	_, _, err := flux.Apply(ctx, a)
+	g.Expect(err).ToNot(HaveOccurred())
+
+	repo, err := dynClient.Resource(common.GitRepositoryGVR()).Namespace(a.Namespace).Get(ctx, a.Name, metav1.GetOptions{})
+	g.Expect(err).ToNot(HaveOccurred())
+	tag, _, _ := unstructured.NestedString(repo.Object, "spec", "ref", "tag")
+	g.Expect(tag).To(Equal("5.3.2"))
+
+	ks, err := dynClient.Resource(common.KustomizationGVR()).Namespace(a.Namespace).Get(ctx, a.Name, metav1.GetOptions{})
+	g.Expect(err).ToNot(HaveOccurred())
+	path, _, _ := unstructured.NestedString(ks.Object, "spec", "path")
+	g.Expect(path).To(Equal("metrics-server/overlays/prod"))
+	secret, _, _ := unstructured.NestedString(ks.Object, "spec", "kubeConfig", "secretRef", "name")
+	g.Expect(secret).To(Equal("edge-kubeconfig"))
+	replicas, _, _ := unstructured.NestedString(ks.Object, "spec", "postBuild", "substitute", "replicas")
+	g.Expect(replicas).To(Equal("2"))
+
+	a.Spec.Source.TargetRevision = "0123456789abcdef0123456789abcdef01234567"
+	_, _, err = flux.Apply(ctx, a)
+	g.Expect(err).ToNot(HaveOccurred())
+	repo, err = dynClient.Resource(common.GitRepositoryGVR()).Namespace(a.Namespace).Get(ctx, a.Name, metav1.GetOptions{})
+	g.Expect(err).ToNot(HaveOccurred())
+	commit, _, _ := unstructured.NestedString(repo.Object, "spec", "ref", "commit")
+	g.Expect(commit).To(Equal(a.Spec.Source.TargetRevision))
+}
+
+func TestFluxPhase(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	obj := &unstructured.Unstructured{Object: map[string]interface{}{}}
+	phase, _ := FluxPhase(obj)
+	g.Expect(phase).To(Equal(addonmgrv1alpha1.Pending))
+
+	setReady(obj, "Unknown", "Progressing", "reconciliation in progress")
+	phase, reason := FluxPhase(obj)
+	g.Expect(phase).To(Equal(addonmgrv1alpha1.Pending))
+	g.Expect(reason).To(Equal("reconciliation in progress"))
+
+	setReady(obj, "False", "InstallFailed", "Helm install failed")
+	phase, reason = FluxPhase(obj)
+	g.Expect(phase).To(Equal(addonmgrv1alpha1.Failed))
+	g.Expect(reason).To(Equal("Helm install failed"))
+}
@@ -0,0 +1,119 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gitops
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+
+	addonmgrv1alpha1 "github.com/keikoproj/addon-manager/api/v1alpha1"
+	"github.com/keikoproj/addon-manager/pkg/common"
+)
+
+// Generator renders addons as objects of a GitOps controller which installs them instead of lifecycle workflows
+type Generator interface {
+	// Apply creates or updates the objects of the addon and returns the install phase and reason from their status
+	Apply(ctx context.Context, addon *addonmgrv1alpha1.Addon) (addonmgrv1alpha1.ApplicationAssemblyPhase, string, error)
+	// Delete removes the objects of the addon, it returns true once they are gone
+	Delete(ctx context.Context, addon *addonmgrv1alpha1.Addon) (bool, error)
+}
+
+// ClusterReporter is implemented by generators installing an addon in several clusters
+type ClusterReporter interface {
+	// Clusters returns the install status of the addon in each of its clusters
+	Clusters(ctx context.Context, addon *addonmgrv1alpha1.Addon) ([]addonmgrv1alpha1.ClusterStatus, error)
+}
+
+// ObjectName returns the name of the GitOps objects of an addon, they may live outside of the addon namespace
+func ObjectName(addon *addonmgrv1alpha1.Addon) string {
+	return fmt.Sprintf("%s-%s", addon.Namespace, addon.Name)
+}
+
+func labels(addon *addonmgrv1alpha1.Addon) map[string]string {
+	return map[string]string{
+		"app.kubernetes.io/managed-by": common.AddonGVR().Group,
+		"app.kubernetes.io/name":       addon.Name,
+	}
+}
+
+func source(addon *addonmgrv1alpha1.Addon) (*addonmgrv1alpha1.AddonSource, error) {
+	if addon.Spec.Source == nil || addon.Spec.Source.RepoURL == "" {
+		return nil, fmt.Errorf("addon %s has no source repository", addon.Name)
+	}
+	return addon.Spec.Source, nil
+}
+
+func revision(addon *addonmgrv1alpha1.Addon) string {
+	if addon.Spec.Source.TargetRevision != "" {
+		return addon.Spec.Source.TargetRevision
+	}
+	return addon.Spec.PkgVersion
+}
+
+// values returns the addon parameters that are set, sorted by name
+func values(addon *addonmgrv1alpha1.Addon) ([]string, map[string]string) {
+	params := addon.GetAllAddonParameters()
+	names := make([]string, 0, len(params))
+	for name, value := range params {
+		if value == "" {
+			delete(params, name)
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, params
+}
+
+// apply creates the object or replaces the spec of the existing object and returns the current object
+func apply(ctx context.Context, dynClient dynamic.Interface, gvr schema.GroupVersionResource, obj *unstructured.Unstructured) (*unstructured.Unstructured, error) {
+	resource := dynClient.Resource(gvr).Namespace(obj.GetNamespace())
+	current, err := resource.Get(ctx, obj.GetName(), metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return resource.Create(ctx, obj, metav1.CreateOptions{})
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	current.Object["spec"] = obj.Object["spec"]
+	current.SetLabels(obj.GetLabels())
+	return resource.Update(ctx, current, metav1.UpdateOptions{})
+}
+
+// remove deletes the object and returns true once it is gone
+func remove(ctx context.Context, dynClient dynamic.Interface, gvr schema.GroupVersionResource, namespace, name string) (bool, error) {
+	resource := dynClient.Resource(gvr).Namespace(namespace)
+	current, err := resource.Get(ctx, name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if current.GetDeletionTimestamp() == nil {
+		if err := resource.Delete(ctx, name, metav1.DeleteOptions{}); err != nil && !apierrors.IsNotFound(err) {
+			return false, err
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,288 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gitops
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+
+	addonmgrv1alpha1 "github.com/keikoproj/addon-manager/api/v1alpha1"
+	"github.com/keikoproj/addon-manager/pkg/common"
+)
+
+// Karmada creates the artifacts of the addon workflows as resource templates in a Karmada control plane with a
+// ClusterPropagationPolicy placing them in the member clusters named by spec.target.clusterRef or selected by
+// spec.target.clusterSelector.
+type Karmada struct {
+	dynClient dynamic.Interface
+	mapper    meta.RESTMapper
+}
+
+// NewKarmada returns a Generator creating resource templates in the Karmada API server the dynamic client is connected to
+func NewKarmada(dynClient dynamic.Interface, mapper meta.RESTMapper) *Karmada {
+	return &Karmada{dynClient: dynClient, mapper: mapper}
+}
+
+type template struct {
+	gvr        schema.GroupVersionResource
+	obj        *unstructured.Unstructured
+	namespaced bool
+}
+
+// templates returns the resource templates of the addon, namespaced objects default to the addon params namespace
+func (k *Karmada) templates(addon *addonmgrv1alpha1.Addon) ([]template, error) {
+	objects, err := artifacts(addon)
+	if err != nil {
+		return nil, err
+	}
+
+	templates := make([]template, 0, len(objects))
+	for _, obj := range objects {
+		gvk := obj.GroupVersionKind()
+		mapping, err := k.mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+		if err != nil {
+			return nil, fmt.Errorf("unknown artifact kind %s. %v", gvk, err)
+		}
+		namespaced := mapping.Scope.Name() == meta.RESTScopeNameNamespace
+		if namespaced && obj.GetNamespace() == "" {
+			obj.SetNamespace(addon.Spec.Params.Namespace)
+		}
+		annotate(obj, addon)
+		templates = append(templates, template{gvr: mapping.Resource, obj: obj, namespaced: namespaced})
+	}
+	return templates, nil
+}
+
+// PropagationPolicy returns the ClusterPropagationPolicy placing the resource templates in the target clusters
+func PropagationPolicy(addon *addonmgrv1alpha1.Addon, objects []*unstructured.Unstructured) (*unstructured.Unstructured, error) {
+	selectors := make([]interface{}, 0, len(objects))
+	for _, obj := range objects {
+		selector := map[string]interface{}{"apiVersion": obj.GetAPIVersion(), "kind": obj.GetKind(), "name": obj.GetName()}
+		if obj.GetNamespace() != "" {
+			selector["namespace"] = obj.GetNamespace()
+		}
+		selectors = append(selectors, selector)
+	}
+
+	affinity := map[string]interface{}{}
+	if ref := addon.Spec.Target.ClusterRef; ref != nil {
+		affinity["clusterNames"] = []interface{}{ref.Name}
+	} else {
+		selector, err := runtime.DefaultUnstructuredConverter.ToUnstructured(addon.Spec.Target.ClusterSelector)
+		if err != nil {
+			return nil, err
+		}
+		affinity["labelSelector"] = selector
+	}
+
+	policy := &unstructured.Unstructured{Object: map[string]interface{}{
+		"spec": map[string]interface{}{
+			"resourceSelectors": selectors,
+			"placement":         map[string]interface{}{"clusterAffinity": affinity},
+		},
+	}}
+	policy.SetGroupVersionKind(common.ClusterPropagationPolicyGVR().GroupVersion().WithKind("ClusterPropagationPolicy"))
+	policy.SetName(ObjectName(addon))
+	policy.SetLabels(labels(addon))
+	annotate(policy, addon)
+	return policy, nil
+}
+
+// applyTemplate creates the resource template or replaces the existing one
+func (k *Karmada) applyTemplate(ctx context.Context, t template) error {
+	resource := k.dynClient.Resource(t.gvr).Namespace(t.obj.GetNamespace())
+	current, err := resource.Get(ctx, t.obj.GetName(), metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		_, err = resource.Create(ctx, t.obj, metav1.CreateOptions{})
+		return err
+	}
+	if err != nil {
+		return err
+	}
+	t.obj.SetResourceVersion(current.GetResourceVersion())
+	_, err = resource.Update(ctx, t.obj, metav1.UpdateOptions{})
+	return err
+}
+
+// Apply implements Generator, the addon succeeds once every resource template is fully applied to its clusters
+func (k *Karmada) Apply(ctx context.Context, addon *addonmgrv1alpha1.Addon) (addonmgrv1alpha1.ApplicationAssemblyPhase, string, error) {
+	templates, err := k.templates(addon)
+	if err != nil {
+		return addonmgrv1alpha1.Failed, "", err
+	}
+
+	objects := make([]*unstructured.Unstructured, 0, len(templates))
+	for _, t := range templates {
+		objects = append(objects, t.obj)
+	}
+	policy, err := PropagationPolicy(addon, objects)
+	if err != nil {
+		return addonmgrv1alpha1.Failed, "", err
+	}
+	// The policy is created first so the templates are not left unplaced
+	if _, err := apply(ctx, k.dynClient, common.ClusterPropagationPolicyGVR(), policy); err != nil {
+		return addonmgrv1alpha1.Failed, "", err
+	}
+
+	phase, reason := addonmgrv1alpha1.Succeeded, ""
+	for _, t := range templates {
+		if err := k.applyTemplate(ctx, t); err != nil {
+			return addonmgrv1alpha1.Failed, "", err
+		}
+
+		binding, err := k.binding(ctx, t)
+		if apierrors.IsNotFound(err) {
+			if phase == addonmgrv1alpha1.Succeeded {
+				phase, reason = addonmgrv1alpha1.Pending, ""
+			}
+			continue
+		}
+		if err != nil {
+			return addonmgrv1alpha1.Failed, "", err
+		}
+
+		p, r := BindingPhase(binding)
+		switch {
+		case p == addonmgrv1alpha1.Failed && phase != addonmgrv1alpha1.Failed:
+			phase, reason = p, fmt.Sprintf("%s %s: %s", t.obj.GetKind(), t.obj.GetName(), r)
+		case p == addonmgrv1alpha1.Pending && phase == addonmgrv1alpha1.Succeeded:
+			phase, reason = p, r
+		}
+	}
+	return phase, reason, nil
+}
+
+// binding returns the ResourceBinding or ClusterResourceBinding of a resource template
+func (k *Karmada) binding(ctx context.Context, t template) (*unstructured.Unstructured, error) {
+	if t.namespaced {
+		return k.dynClient.Resource(common.ResourceBindingGVR()).Namespace(t.obj.GetNamespace()).Get(ctx, BindingName(t.obj), metav1.GetOptions{})
+	}
+	return k.dynClient.Resource(common.ClusterResourceBindingGVR()).Get(ctx, BindingName(t.obj), metav1.GetOptions{})
+}
+
+// Clusters implements ClusterReporter with the status the bindings aggregate from each member cluster, a cluster
+// succeeds once all templates are applied and healthy in it
+func (k *Karmada) Clusters(ctx context.Context, addon *addonmgrv1alpha1.Addon) ([]addonmgrv1alpha1.ClusterStatus, error) {
+	templates, err := k.templates(addon)
+	if err != nil {
+		return nil, err
+	}
+
+	byCluster := map[string]*addonmgrv1alpha1.ClusterStatus{}
+	for _, t := range templates {
+		binding, err := k.binding(ctx, t)
+		if apierrors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		items, _, _ := unstructured.NestedSlice(binding.Object, "status", "aggregatedStatus")
+		for _, item := range items {
+			item, ok := item.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			name, _ := item["clusterName"].(string)
+			status, ok := byCluster[name]
+			if !ok {
+				status = &addonmgrv1alpha1.ClusterStatus{Cluster: name, Installed: addonmgrv1alpha1.Succeeded}
+				byCluster[name] = status
+			}
+
+			phase, reason := addonmgrv1alpha1.Succeeded, ""
+			message, _ := item["appliedMessage"].(string)
+			switch {
+			case item["applied"] != true && message != "":
+				phase, reason = addonmgrv1alpha1.Failed, message
+			case item["health"] == "Unhealthy":
+				phase, reason = addonmgrv1alpha1.Failed, fmt.Sprintf("%s %s is unhealthy", t.obj.GetKind(), t.obj.GetName())
+			case item["applied"] != true:
+				phase = addonmgrv1alpha1.Pending
+			}
+			if phase == addonmgrv1alpha1.Failed && status.Installed != addonmgrv1alpha1.Failed ||
+				phase == addonmgrv1alpha1.Pending && status.Installed == addonmgrv1alpha1.Succeeded {
+				status.Installed, status.Reason = phase, reason
+			}
+		}
+	}
+
+	statuses := make([]addonmgrv1alpha1.ClusterStatus, 0, len(byCluster))
+	for _, status := range byCluster {
+		statuses = append(statuses, *status)
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Cluster < statuses[j].Cluster })
+	return statuses, nil
+}
+
+// Delete implements Generator, Karmada removes the objects from the member clusters with their resource templates
+func (k *Karmada) Delete(ctx context.Context, addon *addonmgrv1alpha1.Addon) (bool, error) {
+	templates, err := k.templates(addon)
+	if err != nil {
+		return false, err
+	}
+
+	done := true
+	for i := len(templates) - 1; i >= 0; i-- {
+		t := templates[i]
+		removed, err := remove(ctx, k.dynClient, t.gvr, t.obj.GetNamespace(), t.obj.GetName())
+		if err != nil {
+			return false, err
+		}
+		done = done && removed
+	}
+	if !done {
+		return false, nil
+	}
+	return remove(ctx, k.dynClient, common.ClusterPropagationPolicyGVR(), "", ObjectName(addon))
+}
+
+// BindingName returns the name of the binding Karmada creates for a resource template
+func BindingName(obj *unstructured.Unstructured) string {
+	return strings.ToLower(obj.GetName() + "-" + obj.GetKind())
+}
+
+// BindingPhase maps the conditions of a ResourceBinding or ClusterResourceBinding to an addon phase
+func BindingPhase(binding *unstructured.Unstructured) (addonmgrv1alpha1.ApplicationAssemblyPhase, string) {
+	phase, reason := addonmgrv1alpha1.Pending, ""
+	conditions, _, _ := unstructured.NestedSlice(binding.Object, "status", "conditions")
+	for _, c := range conditions {
+		condition, ok := c.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		message, _ := condition["message"].(string)
+		switch {
+		case condition["type"] == "Scheduled" && condition["status"] == "False":
+			return addonmgrv1alpha1.Failed, message
+		case condition["type"] == "FullyApplied" && condition["status"] == "True":
+			phase, reason = addonmgrv1alpha1.Succeeded, ""
+		case condition["type"] == "FullyApplied":
+			reason = message
+		}
+	}
+	return phase, reason
+}
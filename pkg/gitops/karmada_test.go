@@ -0,0 +1,150 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gitops
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynfake "k8s.io/client-go/dynamic/fake"
+
+	addonmgrv1alpha1 "github.com/keikoproj/addon-manager/api/v1alpha1"
+	"github.com/keikoproj/addon-manager/pkg/common"
+)
+
+var (
+	configMapGVK   = schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}
+	clusterRoleGVK = schema.GroupVersionKind{Group: "rbac.authorization.k8s.io", Version: "v1", Kind: "ClusterRole"}
+)
+
+func newKarmadaScheme() *runtime.Scheme {
+	s := runtime.NewScheme()
+	for _, gvk := range []schema.GroupVersionKind{
+		configMapGVK,
+		clusterRoleGVK,
+		common.ClusterPropagationPolicyGVR().GroupVersion().WithKind("ClusterPropagationPolicy"),
+		common.ResourceBindingGVR().GroupVersion().WithKind("ResourceBinding"),
+		common.ClusterResourceBindingGVR().GroupVersion().WithKind("ClusterResourceBinding"),
+	} {
+		s.AddKnownTypeWithName(gvk, &unstructured.Unstructured{})
+		s.AddKnownTypeWithName(gvk.GroupVersion().WithKind(gvk.Kind+"List"), &unstructured.UnstructuredList{})
+	}
+	return s
+}
+
+func newKarmadaMapper() meta.RESTMapper {
+	mapper := meta.NewDefaultRESTMapper(nil)
+	mapper.Add(configMapGVK, meta.RESTScopeNamespace)
+	mapper.Add(clusterRoleGVK, meta.RESTScopeRoot)
+	return mapper
+}
+
+func newBinding(gvr schema.GroupVersionResource, kind, namespace, name string, conditions ...interface{}) *unstructured.Unstructured {
+	b := &unstructured.Unstructured{Object: map[string]interface{}{"status": map[string]interface{}{"conditions": conditions}}}
+	b.SetGroupVersionKind(gvr.GroupVersion().WithKind(kind))
+	b.SetNamespace(namespace)
+	b.SetName(name)
+	return b
+}
+
+func TestKarmada_Apply(t *testing.T) {
+	g := NewGomegaWithT(t)
+	ctx := context.TODO()
+
+	dynClient := dynfake.NewSimpleDynamicClient(newKarmadaScheme())
+	karmada := NewKarmada(dynClient, newKarmadaMapper())
+	a := newHubAddon()
+
+	phase, _, err := karmada.Apply(ctx, a)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(phase).To(Equal(addonmgrv1alpha1.Pending))
+
+	cm, err := dynClient.Resource(schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}).Namespace("event-router").Get(ctx, "event-router-cm", metav1.GetOptions{})
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(cm.GetAnnotations()).To(HaveKeyWithValue(AddonAnnotation, "addon-manager-system/event-router"))
+
+	policy, err := dynClient.Resource(common.ClusterPropagationPolicyGVR()).Get(ctx, ObjectName(a), metav1.GetOptions{})
+	g.Expect(err).ToNot(HaveOccurred())
+	selectors, _, _ := unstructured.NestedSlice(policy.Object, "spec", "resourceSelectors")
+	g.Expect(selectors).To(ConsistOf(
+		map[string]interface{}{"apiVersion": "v1", "kind": "ConfigMap", "name": "event-router-cm", "namespace": "event-router"},
+		map[string]interface{}{"apiVersion": "rbac.authorization.k8s.io/v1", "kind": "ClusterRole", "name": "event-router-cr"},
+	))
+	env, _, _ := unstructured.NestedString(policy.Object, "spec", "placement", "clusterAffinity", "labelSelector", "matchLabels", "env")
+	g.Expect(env).To(Equal("prod"))
+
+	// The addon succeeds once all bindings are fully applied
+	applied := map[string]interface{}{"type": "FullyApplied", "status": "True"}
+	cmBinding := newBinding(common.ResourceBindingGVR(), "ResourceBinding", "event-router", "event-router-cm-configmap", applied)
+	g.Expect(unstructured.SetNestedSlice(cmBinding.Object, []interface{}{
+		map[string]interface{}{"clusterName": "member-1", "applied": true, "health": "Healthy"},
+		map[string]interface{}{"clusterName": "member-2", "applied": true, "health": "Healthy"},
+	}, "status", "aggregatedStatus")).To(Succeed())
+	_, err = dynClient.Resource(common.ResourceBindingGVR()).Namespace("event-router").Create(ctx, cmBinding, metav1.CreateOptions{})
+	g.Expect(err).ToNot(HaveOccurred())
+	crBinding := newBinding(common.ClusterResourceBindingGVR(), "ClusterResourceBinding", "", "event-router-cr-clusterrole", applied)
+	g.Expect(unstructured.SetNestedSlice(crBinding.Object, []interface{}{
+		map[string]interface{}{"clusterName": "member-1", "applied": true},
+		map[string]interface{}{"clusterName": "member-2", "applied": false, "appliedMessage": "clusterroles is forbidden"},
+	}, "status", "aggregatedStatus")).To(Succeed())
+	_, err = dynClient.Resource(common.ClusterResourceBindingGVR()).Create(ctx, crBinding, metav1.CreateOptions{})
+	g.Expect(err).ToNot(HaveOccurred())
+	phase, _, err = karmada.Apply(ctx, a)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(phase).To(Equal(addonmgrv1alpha1.Succeeded))
+
+	// Each member cluster reports the worst status of the templates
+	statuses, err := karmada.Clusters(ctx, a)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(statuses).To(Equal([]addonmgrv1alpha1.ClusterStatus{
+		{Cluster: "member-1", Installed: addonmgrv1alpha1.Succeeded},
+		{Cluster: "member-2", Installed: addonmgrv1alpha1.Failed, Reason: "clusterroles is forbidden"},
+	}))
+
+	// Templates are removed before the policy
+	done, err := karmada.Delete(ctx, a)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(done).To(BeFalse())
+	_, err = dynClient.Resource(common.ClusterPropagationPolicyGVR()).Get(ctx, ObjectName(a), metav1.GetOptions{})
+	g.Expect(err).ToNot(HaveOccurred())
+	done, err = karmada.Delete(ctx, a)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(done).To(BeFalse())
+	done, err = karmada.Delete(ctx, a)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(done).To(BeTrue())
+}
+
+func TestBindingPhase(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	phase, _ := BindingPhase(newBinding(common.ResourceBindingGVR(), "ResourceBinding", "ns", "b",
+		map[string]interface{}{"type": "Scheduled", "status": "True"},
+		map[string]interface{}{"type": "FullyApplied", "status": "False", "message": "Failed to apply all works"},
+	))
+	g.Expect(phase).To(Equal(addonmgrv1alpha1.Pending))
+
+	phase, reason := BindingPhase(newBinding(common.ResourceBindingGVR(), "ResourceBinding", "ns", "b",
+		map[string]interface{}{"type": "Scheduled", "status": "False", "message": "0/3 clusters are available"},
+	))
+	g.Expect(phase).To(Equal(addonmgrv1alpha1.Failed))
+	g.Expect(reason).To(Equal("0/3 clusters are available"))
+}
@@ -0,0 +1,229 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gitops
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/dynamic"
+
+	addonmgrv1alpha1 "github.com/keikoproj/addon-manager/api/v1alpha1"
+	"github.com/keikoproj/addon-manager/pkg/common"
+	"github.com/keikoproj/addon-manager/pkg/workflows"
+)
+
+// AddonAnnotation is the namespace/name of the addon a hub object was generated from
+const AddonAnnotation = "addonmgr.keikoproj.io/addon"
+
+// OCM wraps the artifacts of the addon workflows in a ManifestWork per target managed cluster of an Open Cluster
+// Management hub. spec.target.clusterRef names a managed cluster and spec.target.clusterSelector selects them by label.
+type OCM struct {
+	dynClient dynamic.Interface
+}
+
+// NewOCM returns a Generator creating ManifestWorks in the hub the dynamic client is connected to
+func NewOCM(dynClient dynamic.Interface) *OCM {
+	return &OCM{dynClient: dynClient}
+}
+
+func addonKey(addon *addonmgrv1alpha1.Addon) string {
+	return fmt.Sprintf("%s/%s", addon.Namespace, addon.Name)
+}
+
+func annotate(obj *unstructured.Unstructured, addon *addonmgrv1alpha1.Addon) {
+	annotations := obj.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[AddonAnnotation] = addonKey(addon)
+	obj.SetAnnotations(annotations)
+}
+
+// artifacts returns the workflow artifacts of an addon targeting clusters
+func artifacts(addon *addonmgrv1alpha1.Addon) ([]*unstructured.Unstructured, error) {
+	if addon.Spec.Target == nil || (addon.Spec.Target.ClusterRef == nil && addon.Spec.Target.ClusterSelector == nil) {
+		return nil, fmt.Errorf("addon %s has no target clusters", addon.Name)
+	}
+	objects, err := workflows.Artifacts(addon)
+	if err != nil {
+		return nil, err
+	}
+	if len(objects) == 0 {
+		return nil, fmt.Errorf("addon %s has no workflow artifacts to propagate", addon.Name)
+	}
+	return objects, nil
+}
+
+// clusters returns the sorted names of the managed clusters targeted by the addon
+func (o *OCM) clusters(ctx context.Context, addon *addonmgrv1alpha1.Addon) ([]string, error) {
+	if ref := addon.Spec.Target.ClusterRef; ref != nil {
+		return []string{ref.Name}, nil
+	}
+	selector, err := metav1.LabelSelectorAsSelector(addon.Spec.Target.ClusterSelector)
+	if err != nil {
+		return nil, err
+	}
+	list, err := o.dynClient.Resource(common.ManagedClusterGVR()).List(ctx, metav1.ListOptions{LabelSelector: selector.String()})
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(list.Items))
+	for _, c := range list.Items {
+		names = append(names, c.GetName())
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// ManifestWork returns the ManifestWork delivering the objects to a managed cluster, it lives in the cluster namespace
+func ManifestWork(addon *addonmgrv1alpha1.Addon, cluster string, objects []*unstructured.Unstructured) *unstructured.Unstructured {
+	manifests := make([]interface{}, 0, len(objects))
+	for _, obj := range objects {
+		manifests = append(manifests, obj.Object)
+	}
+
+	work := &unstructured.Unstructured{Object: map[string]interface{}{
+		"spec": map[string]interface{}{
+			"workload": map[string]interface{}{"manifests": manifests},
+		},
+	}}
+	work.SetGroupVersionKind(common.ManifestWorkGVR().GroupVersion().WithKind("ManifestWork"))
+	work.SetNamespace(cluster)
+	work.SetName(ObjectName(addon))
+	work.SetLabels(labels(addon))
+	annotate(work, addon)
+	return work
+}
+
+// works returns the ManifestWorks generated from the addon in all cluster namespaces
+func (o *OCM) works(ctx context.Context, addon *addonmgrv1alpha1.Addon) ([]unstructured.Unstructured, error) {
+	list, err := o.dynClient.Resource(common.ManifestWorkGVR()).List(ctx, metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("app.kubernetes.io/name=%s", addon.Name),
+	})
+	if err != nil {
+		return nil, err
+	}
+	var works []unstructured.Unstructured
+	for _, w := range list.Items {
+		if w.GetAnnotations()[AddonAnnotation] == addonKey(addon) {
+			works = append(works, w)
+		}
+	}
+	return works, nil
+}
+
+// Apply implements Generator, the addon fails when the work of any cluster fails and succeeds once all are available.
+// Works of clusters that are no longer targeted are removed.
+func (o *OCM) Apply(ctx context.Context, addon *addonmgrv1alpha1.Addon) (addonmgrv1alpha1.ApplicationAssemblyPhase, string, error) {
+	objects, err := artifacts(addon)
+	if err != nil {
+		return addonmgrv1alpha1.Failed, "", err
+	}
+	clusters, err := o.clusters(ctx, addon)
+	if err != nil {
+		return addonmgrv1alpha1.Failed, "", err
+	}
+
+	phase, reason := addonmgrv1alpha1.Succeeded, ""
+	if len(clusters) == 0 {
+		phase, reason = addonmgrv1alpha1.Pending, "no managed clusters match the addon target"
+	}
+	for _, cluster := range clusters {
+		current, err := apply(ctx, o.dynClient, common.ManifestWorkGVR(), ManifestWork(addon, cluster, objects))
+		if err != nil {
+			return addonmgrv1alpha1.Failed, "", err
+		}
+		p, r := ManifestWorkPhase(current)
+		switch {
+		case p == addonmgrv1alpha1.Failed && phase != addonmgrv1alpha1.Failed:
+			phase, reason = p, fmt.Sprintf("%s: %s", cluster, r)
+		case p == addonmgrv1alpha1.Pending && phase == addonmgrv1alpha1.Succeeded:
+			phase, reason = p, fmt.Sprintf("%s: %s", cluster, r)
+		}
+	}
+
+	works, err := o.works(ctx, addon)
+	if err != nil {
+		return addonmgrv1alpha1.Failed, "", err
+	}
+	for _, w := range works {
+		if !common.ContainsString(clusters, w.GetNamespace()) {
+			if _, err := remove(ctx, o.dynClient, common.ManifestWorkGVR(), w.GetNamespace(), w.GetName()); err != nil {
+				return addonmgrv1alpha1.Failed, "", err
+			}
+		}
+	}
+	return phase, reason, nil
+}
+
+// Delete implements Generator, the work agents remove the objects from the managed clusters with the works
+func (o *OCM) Delete(ctx context.Context, addon *addonmgrv1alpha1.Addon) (bool, error) {
+	works, err := o.works(ctx, addon)
+	if err != nil {
+		return false, err
+	}
+	for _, w := range works {
+		if _, err := remove(ctx, o.dynClient, common.ManifestWorkGVR(), w.GetNamespace(), w.GetName()); err != nil {
+			return false, err
+		}
+	}
+	return len(works) == 0, nil
+}
+
+// Clusters implements ClusterReporter with the phase of the work in each managed cluster
+func (o *OCM) Clusters(ctx context.Context, addon *addonmgrv1alpha1.Addon) ([]addonmgrv1alpha1.ClusterStatus, error) {
+	works, err := o.works(ctx, addon)
+	if err != nil {
+		return nil, err
+	}
+	statuses := make([]addonmgrv1alpha1.ClusterStatus, 0, len(works))
+	for i := range works {
+		phase, reason := ManifestWorkPhase(&works[i])
+		statuses = append(statuses, addonmgrv1alpha1.ClusterStatus{Cluster: works[i].GetNamespace(), Installed: phase, Reason: reason})
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Cluster < statuses[j].Cluster })
+	return statuses, nil
+}
+
+// ManifestWorkPhase maps the conditions of a ManifestWork to an addon phase
+func ManifestWorkPhase(work *unstructured.Unstructured) (addonmgrv1alpha1.ApplicationAssemblyPhase, string) {
+	conditions := map[string]map[string]interface{}{}
+	list, _, _ := unstructured.NestedSlice(work.Object, "status", "conditions")
+	for _, c := range list {
+		if condition, ok := c.(map[string]interface{}); ok {
+			if t, ok := condition["type"].(string); ok {
+				conditions[t] = condition
+			}
+		}
+	}
+
+	message := func(t string) string {
+		m, _ := conditions[t]["message"].(string)
+		return m
+	}
+	switch {
+	case conditions["Degraded"]["status"] == "True":
+		return addonmgrv1alpha1.Failed, message("Degraded")
+	case conditions["Applied"]["status"] == "False":
+		return addonmgrv1alpha1.Failed, message("Applied")
+	case conditions["Applied"]["status"] == "True" && conditions["Available"]["status"] == "True":
+		return addonmgrv1alpha1.Succeeded, ""
+	}
+	return addonmgrv1alpha1.Pending, message("Available")
+}
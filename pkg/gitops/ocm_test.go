@@ -0,0 +1,180 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gitops
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	dynfake "k8s.io/client-go/dynamic/fake"
+
+	addonmgrv1alpha1 "github.com/keikoproj/addon-manager/api/v1alpha1"
+	"github.com/keikoproj/addon-manager/pkg/common"
+)
+
+const hubInstallTemplate = `
+apiVersion: argoproj.io/v1alpha1
+kind: Workflow
+spec:
+  entrypoint: entry
+  templates:
+  - name: entry
+    resource:
+      action: apply
+      manifest: |
+        apiVersion: v1
+        kind: ConfigMap
+        metadata:
+          name: event-router-cm
+          namespace: "{{workflow.parameters.namespace}}"
+        data:
+          sink: stdout
+        ---
+        apiVersion: rbac.authorization.k8s.io/v1
+        kind: ClusterRole
+        metadata:
+          name: event-router-cr
+        rules:
+        - apiGroups: [""]
+          resources: ["events"]
+          verbs: ["get", "watch", "list"]
+`
+
+func newHubAddon() *addonmgrv1alpha1.Addon {
+	return &addonmgrv1alpha1.Addon{
+		ObjectMeta: metav1.ObjectMeta{Name: "event-router", Namespace: "addon-manager-system"},
+		Spec: addonmgrv1alpha1.AddonSpec{
+			PackageSpec: addonmgrv1alpha1.PackageSpec{PkgName: "event-router", PkgVersion: "0.2.0", PkgType: addonmgrv1alpha1.CompositePkg},
+			Params:      addonmgrv1alpha1.AddonParams{Namespace: "event-router"},
+			Lifecycle: addonmgrv1alpha1.LifecycleWorkflowSpec{
+				Install: addonmgrv1alpha1.WorkflowType{Template: hubInstallTemplate},
+			},
+			Target: &addonmgrv1alpha1.AddonTarget{
+				ClusterSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"env": "prod"}},
+			},
+		},
+	}
+}
+
+func newManagedCluster(name string, labels map[string]string) *unstructured.Unstructured {
+	c := &unstructured.Unstructured{}
+	c.SetGroupVersionKind(common.ManagedClusterGVR().GroupVersion().WithKind("ManagedCluster"))
+	c.SetName(name)
+	c.SetLabels(labels)
+	return c
+}
+
+func newOCMScheme() *runtime.Scheme {
+	s := newGitOpsScheme(common.ManifestWorkGVR(), "ManifestWork")
+	s.AddKnownTypeWithName(common.ManagedClusterGVR().GroupVersion().WithKind("ManagedCluster"), &unstructured.Unstructured{})
+	s.AddKnownTypeWithName(common.ManagedClusterGVR().GroupVersion().WithKind("ManagedClusterList"), &unstructured.UnstructuredList{})
+	return s
+}
+
+func TestOCM_Apply(t *testing.T) {
+	g := NewGomegaWithT(t)
+	ctx := context.TODO()
+
+	dynClient := dynfake.NewSimpleDynamicClient(newOCMScheme(),
+		newManagedCluster("prod-1", map[string]string{"env": "prod"}),
+		newManagedCluster("prod-2", map[string]string{"env": "prod"}),
+		newManagedCluster("dev-1", map[string]string{"env": "dev"}),
+	)
+	ocm := NewOCM(dynClient)
+	a := newHubAddon()
+
+	phase, reason, err := ocm.Apply(ctx, a)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(phase).To(Equal(addonmgrv1alpha1.Pending))
+	g.Expect(reason).To(HavePrefix("prod-1"))
+
+	works := dynClient.Resource(common.ManifestWorkGVR())
+	_, err = works.Namespace("dev-1").Get(ctx, ObjectName(a), metav1.GetOptions{})
+	g.Expect(err).To(HaveOccurred())
+	work, err := works.Namespace("prod-2").Get(ctx, ObjectName(a), metav1.GetOptions{})
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(work.GetAnnotations()).To(HaveKeyWithValue(AddonAnnotation, "addon-manager-system/event-router"))
+	manifests, _, _ := unstructured.NestedSlice(work.Object, "spec", "workload", "manifests")
+	g.Expect(manifests).To(HaveLen(2))
+	namespace, _, _ := unstructured.NestedString(manifests[0].(map[string]interface{}), "metadata", "namespace")
+	g.Expect(namespace).To(Equal("event-router"))
+
+	// The addon succeeds once the works of all clusters are available
+	for _, cluster := range []string{"prod-1", "prod-2"} {
+		work, err := works.Namespace(cluster).Get(ctx, ObjectName(a), metav1.GetOptions{})
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(unstructured.SetNestedSlice(work.Object, []interface{}{
+			map[string]interface{}{"type": "Applied", "status": "True"},
+			map[string]interface{}{"type": "Available", "status": "True"},
+		}, "status", "conditions")).To(Succeed())
+		_, err = works.Namespace(cluster).Update(ctx, work, metav1.UpdateOptions{})
+		g.Expect(err).ToNot(HaveOccurred())
+	}
+	phase, _, err = ocm.Apply(ctx, a)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(phase).To(Equal(addonmgrv1alpha1.Succeeded))
+	statuses, err := ocm.Clusters(ctx, a)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(statuses).To(Equal([]addonmgrv1alpha1.ClusterStatus{
+		{Cluster: "prod-1", Installed: addonmgrv1alpha1.Succeeded},
+		{Cluster: "prod-2", Installed: addonmgrv1alpha1.Succeeded},
+	}))
+
+	// Works of clusters that are no longer targeted are removed
+	a.Spec.Target = &addonmgrv1alpha1.AddonTarget{ClusterRef: &addonmgrv1alpha1.ClusterReference{Name: "prod-1"}}
+	phase, _, err = ocm.Apply(ctx, a)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(phase).To(Equal(addonmgrv1alpha1.Succeeded))
+	_, err = works.Namespace("prod-2").Get(ctx, ObjectName(a), metav1.GetOptions{})
+	g.Expect(err).To(HaveOccurred())
+
+	done, err := ocm.Delete(ctx, a)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(done).To(BeFalse())
+	done, err = ocm.Delete(ctx, a)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(done).To(BeTrue())
+
+	a.Spec.Target = nil
+	_, _, err = ocm.Apply(ctx, a)
+	g.Expect(err).To(MatchError(ContainSubstring("no target clusters")))
+}
+
+func TestManifestWorkPhase(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	work := &unstructured.Unstructured{Object: map[string]interface{}{}}
+	phase, _ := ManifestWorkPhase(work)
+	g.Expect(phase).To(Equal(addonmgrv1alpha1.Pending))
+
+	g.Expect(unstructured.SetNestedSlice(work.Object, []interface{}{
+		map[string]interface{}{"type": "Applied", "status": "False", "message": "Failed to apply manifest: ConfigMap"},
+	}, "status", "conditions")).To(Succeed())
+	phase, reason := ManifestWorkPhase(work)
+	g.Expect(phase).To(Equal(addonmgrv1alpha1.Failed))
+	g.Expect(reason).To(ContainSubstring("Failed to apply"))
+
+	g.Expect(unstructured.SetNestedSlice(work.Object, []interface{}{
+		map[string]interface{}{"type": "Applied", "status": "True"},
+		map[string]interface{}{"type": "Degraded", "status": "True", "message": "deployment unavailable"},
+	}, "status", "conditions")).To(Succeed())
+	phase, reason = ManifestWorkPhase(work)
+	g.Expect(phase).To(Equal(addonmgrv1alpha1.Failed))
+	g.Expect(reason).To(Equal("deployment unavailable"))
+}
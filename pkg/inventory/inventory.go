@@ -0,0 +1,238 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package inventory
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	addonmgrv1alpha1 "github.com/keikoproj/addon-manager/api/v1alpha1"
+)
+
+// podSpecPaths are the paths of the pod spec in the workload kinds addons are observed with
+var podSpecPaths = map[string][]string{
+	"Pod":         {"spec"},
+	"Deployment":  {"spec", "template", "spec"},
+	"DaemonSet":   {"spec", "template", "spec"},
+	"StatefulSet": {"spec", "template", "spec"},
+	"ReplicaSet":  {"spec", "template", "spec"},
+	"Job":         {"spec", "template", "spec"},
+	"CronJob":     {"spec", "jobTemplate", "spec", "template", "spec"},
+}
+
+// csvHeader are the columns of the CSV inventory, images are separated by spaces
+var csvHeader = []string{"namespace", "name", "package", "version", "type", "channel", "checksum", "installed", "installTime", "cluster", "images"}
+
+// Entry is an installed addon
+type Entry struct {
+	Namespace   string                                    `json:"namespace"`
+	Name        string                                    `json:"name"`
+	Package     string                                    `json:"package"`
+	Version     string                                    `json:"version"`
+	Type        addonmgrv1alpha1.PackageType              `json:"type,omitempty"`
+	Channel     string                                    `json:"channel,omitempty"`
+	Checksum    string                                    `json:"checksum"`
+	Installed   addonmgrv1alpha1.ApplicationAssemblyPhase `json:"installed"`
+	InstallTime *metav1.Time                              `json:"installTime,omitempty"`
+	Cluster     string                                    `json:"cluster,omitempty"`
+	Images      []string                                  `json:"images"`
+}
+
+// Report is the inventory of the addons of a cluster
+type Report struct {
+	GeneratedAt metav1.Time `json:"generatedAt"`
+	Addons      []Entry     `json:"addons"`
+}
+
+// New returns the inventory of addons sorted by namespace and name
+func New(addons []addonmgrv1alpha1.Addon, now time.Time) *Report {
+	report := &Report{GeneratedAt: metav1.NewTime(now), Addons: make([]Entry, 0, len(addons))}
+	for i := range addons {
+		report.Addons = append(report.Addons, NewEntry(&addons[i]))
+	}
+	sort.Slice(report.Addons, func(i, j int) bool {
+		if report.Addons[i].Namespace != report.Addons[j].Namespace {
+			return report.Addons[i].Namespace < report.Addons[j].Namespace
+		}
+		return report.Addons[i].Name < report.Addons[j].Name
+	})
+	return report
+}
+
+// NewEntry returns the inventory entry of an addon, the install time is the completion of the last successful install
+func NewEntry(a *addonmgrv1alpha1.Addon) Entry {
+	e := Entry{
+		Namespace: a.Namespace,
+		Name:      a.Name,
+		Package:   a.Spec.PkgName,
+		Version:   a.Spec.PkgVersion,
+		Type:      a.Spec.PkgType,
+		Channel:   a.Spec.PkgChannel,
+		Checksum:  a.Status.Checksum,
+		Installed: a.Status.Lifecycle.Installed,
+		Cluster:   a.Status.Cluster,
+		Images:    a.Status.Images,
+	}
+	if e.Images == nil {
+		e.Images = []string{}
+	}
+	if t := a.Status.Timings.Install; t != nil && a.Status.Lifecycle.Installed == addonmgrv1alpha1.Succeeded {
+		e.InstallTime = t.CompletionTime
+	}
+	return e
+}
+
+// List returns the inventory of the addons listed from reader
+func List(ctx context.Context, reader client.Reader, opts ...client.ListOption) (*Report, error) {
+	var addons addonmgrv1alpha1.AddonList
+	if err := reader.List(ctx, &addons, opts...); err != nil {
+		return nil, err
+	}
+	return New(addons.Items, time.Now()), nil
+}
+
+// WriteJSON writes the indented JSON report
+func (r *Report) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+// WriteCSV writes the report as CSV with a header row
+func (r *Report) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(csvHeader); err != nil {
+		return err
+	}
+	for _, e := range r.Addons {
+		var installTime string
+		if e.InstallTime != nil {
+			installTime = e.InstallTime.UTC().Format(time.RFC3339)
+		}
+		row := []string{e.Namespace, e.Name, e.Package, e.Version, string(e.Type), e.Channel, e.Checksum,
+			string(e.Installed), installTime, e.Cluster, strings.Join(e.Images, " ")}
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// Write writes the report in format, json or csv
+func (r *Report) Write(w io.Writer, format string) error {
+	switch format {
+	case "", "json":
+		return r.WriteJSON(w)
+	case "csv":
+		return r.WriteCSV(w)
+	default:
+		return fmt.Errorf("unknown inventory format %s", format)
+	}
+}
+
+// Handler serves the inventory of the addons listed from reader, the namespace query parameter limits it to a
+// namespace and format=csv returns CSV instead of JSON
+func Handler(reader client.Reader) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		format := req.URL.Query().Get("format")
+		var opts []client.ListOption
+		if ns := req.URL.Query().Get("namespace"); ns != "" {
+			opts = append(opts, client.InNamespace(ns))
+		}
+
+		report, err := List(req.Context(), reader, opts...)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		switch format {
+		case "", "json":
+			w.Header().Set("Content-Type", "application/json")
+		case "csv":
+			w.Header().Set("Content-Type", "text/csv")
+		default:
+			http.Error(w, fmt.Sprintf("unknown inventory format %s", format), http.StatusBadRequest)
+			return
+		}
+		if err := report.Write(w, format); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	})
+}
+
+// Images returns the sorted container and init container images of a workload of kind, other kinds have none
+func Images(kind string, obj runtime.Object) ([]string, error) {
+	path, ok := podSpecPaths[kind]
+	if !ok {
+		return nil, nil
+	}
+
+	var content map[string]interface{}
+	if u, ok := obj.(*unstructured.Unstructured); ok {
+		content = u.Object
+	} else {
+		var err error
+		if content, err = runtime.DefaultUnstructuredConverter.ToUnstructured(obj); err != nil {
+			return nil, err
+		}
+	}
+
+	var images []string
+	for _, field := range []string{"initContainers", "containers"} {
+		containers, _, err := unstructured.NestedSlice(content, append(append([]string{}, path...), field)...)
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range containers {
+			if container, ok := c.(map[string]interface{}); ok {
+				if image, ok := container["image"].(string); ok && image != "" {
+					images = append(images, image)
+				}
+			}
+		}
+	}
+	return Unique(images), nil
+}
+
+// Unique returns the sorted distinct images
+func Unique(images []string) []string {
+	if len(images) == 0 {
+		return nil
+	}
+	seen := make(map[string]bool, len(images))
+	unique := make([]string, 0, len(images))
+	for _, image := range images {
+		if !seen[image] {
+			seen[image] = true
+			unique = append(unique, image)
+		}
+	}
+	sort.Strings(unique)
+	return unique
+}
@@ -0,0 +1,118 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package inventory
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	runtimefake "sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	addonmgrv1alpha1 "github.com/keikoproj/addon-manager/api/v1alpha1"
+)
+
+func newInventoryAddon(ns, name string) addonmgrv1alpha1.Addon {
+	completed := metav1.NewTime(time.Date(2020, 10, 1, 12, 0, 0, 0, time.UTC))
+	a := addonmgrv1alpha1.Addon{ObjectMeta: metav1.ObjectMeta{Namespace: ns, Name: name}}
+	a.Spec.PkgName = "core/" + name
+	a.Spec.PkgVersion = "v1.0.0"
+	a.Spec.PkgType = addonmgrv1alpha1.HelmPkg
+	a.Status.Checksum = "abc123"
+	a.Status.Lifecycle.Installed = addonmgrv1alpha1.Succeeded
+	a.Status.Timings.Install = &addonmgrv1alpha1.LifecycleStepTiming{CompletionTime: &completed}
+	a.Status.Images = []string{"quay.io/" + name + ":v1.0.0"}
+	return a
+}
+
+func TestImages(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	d := &appsv1.Deployment{}
+	d.Spec.Template.Spec.InitContainers = []v1.Container{{Name: "init", Image: "busybox:1.32"}}
+	d.Spec.Template.Spec.Containers = []v1.Container{
+		{Name: "app", Image: "nginx:1.19"},
+		{Name: "sidecar", Image: "busybox:1.32"},
+	}
+	images, err := Images("Deployment", d)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(images).To(Equal([]string{"busybox:1.32", "nginx:1.19"}))
+
+	cron := &unstructured.Unstructured{Object: map[string]interface{}{}}
+	g.Expect(unstructured.SetNestedSlice(cron.Object, []interface{}{
+		map[string]interface{}{"name": "job", "image": "alpine:3.12"},
+	}, "spec", "jobTemplate", "spec", "template", "spec", "containers")).To(Succeed())
+	images, err = Images("CronJob", cron)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(images).To(Equal([]string{"alpine:3.12"}))
+
+	images, err = Images("Service", &v1.Service{})
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(images).To(BeEmpty())
+}
+
+func TestReport_Write(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	failed := newInventoryAddon("default", "broken")
+	failed.Status.Lifecycle.Installed = addonmgrv1alpha1.Failed
+	failed.Status.Images = nil
+
+	report := New([]addonmgrv1alpha1.Addon{newInventoryAddon("default", "nginx"), failed}, time.Now())
+	g.Expect(report.Addons).To(HaveLen(2))
+	g.Expect(report.Addons[0].Name).To(Equal("broken"))
+	g.Expect(report.Addons[0].InstallTime).To(BeNil())
+	g.Expect(report.Addons[0].Images).To(BeEmpty())
+	g.Expect(report.Addons[1].InstallTime).ToNot(BeNil())
+
+	var buf bytes.Buffer
+	g.Expect(report.Write(&buf, "csv")).To(Succeed())
+	g.Expect(buf.String()).To(Equal("namespace,name,package,version,type,channel,checksum,installed,installTime,cluster,images\n" +
+		"default,broken,core/broken,v1.0.0,helm,,abc123,Failed,,,\n" +
+		"default,nginx,core/nginx,v1.0.0,helm,,abc123,Succeeded,2020-10-01T12:00:00Z,,quay.io/nginx:v1.0.0\n"))
+
+	buf.Reset()
+	g.Expect(report.Write(&buf, "json")).To(Succeed())
+	g.Expect(buf.String()).To(ContainSubstring(`"images": [`))
+
+	g.Expect(report.Write(&buf, "xml")).To(HaveOccurred())
+}
+
+func TestHandler(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	s := runtime.NewScheme()
+	g.Expect(addonmgrv1alpha1.AddToScheme(s)).To(Succeed())
+	a, b := newInventoryAddon("default", "nginx"), newInventoryAddon("other", "dns")
+	h := Handler(runtimefake.NewFakeClientWithScheme(s, &a, &b))
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/inventory?namespace=other&format=csv", nil))
+	g.Expect(rec.Code).To(Equal(200))
+	g.Expect(rec.Header().Get("Content-Type")).To(Equal("text/csv"))
+	g.Expect(rec.Body.String()).To(ContainSubstring("other,dns,core/dns"))
+	g.Expect(rec.Body.String()).ToNot(ContainSubstring("nginx"))
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/inventory?format=yaml", nil))
+	g.Expect(rec.Code).To(Equal(400))
+}
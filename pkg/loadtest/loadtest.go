@@ -0,0 +1,411 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package loadtest simulates addon installs at scale against a fake Argo backend, so the reconcile throughput,
+// queue depth and memory of the workflow lifecycle can be compared between releases.
+package loadtest
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	kruntime "k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
+	dynfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	runtimefake "sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	addonmgrv1alpha1 "github.com/keikoproj/addon-manager/api/v1alpha1"
+	"github.com/keikoproj/addon-manager/pkg/common"
+	"github.com/keikoproj/addon-manager/pkg/workflows"
+)
+
+const (
+	namespace = "addon-manager-system"
+	// requeueInterval is how often pending addons are reconciled when no workflow change is seen
+	requeueInterval = time.Second
+	// sampleInterval is how often the queue depth and memory are sampled
+	sampleInterval = 50 * time.Millisecond
+)
+
+// Config of a simulation
+type Config struct {
+	// Addons is the number of addons installed
+	Addons int
+	// Workers is the number of addons reconciled in parallel
+	Workers int
+	// WorkflowDuration is how long fake workflows run before they succeed
+	WorkflowDuration time.Duration
+	// TemplateSize is the number of bytes embedded in every install workflow template
+	TemplateSize int
+}
+
+// Result of a simulation
+type Result struct {
+	Addons        int
+	Installed     int
+	Failed        int
+	Reconciles    int64
+	Duration      time.Duration
+	MaxQueueDepth int
+	// PeakHeap is the highest sampled heap in use, in bytes
+	PeakHeap uint64
+	// Allocated is the number of bytes allocated during the simulation
+	Allocated uint64
+}
+
+// Throughput returns the number of reconciles per second
+func (r *Result) Throughput() float64 {
+	if r.Duration <= 0 {
+		return 0
+	}
+	return float64(r.Reconciles) / r.Duration.Seconds()
+}
+
+// Write writes the result as text
+func (r *Result) Write(w io.Writer) error {
+	_, err := fmt.Fprintf(w, "addons:          %d (%d installed, %d failed)\n"+
+		"duration:        %s\n"+
+		"reconciles:      %d (%.1f/s)\n"+
+		"max queue depth: %d\n"+
+		"peak heap:       %.1f MiB\n"+
+		"allocated:       %.1f MiB\n",
+		r.Addons, r.Installed, r.Failed, r.Duration.Round(time.Millisecond), r.Reconciles, r.Throughput(),
+		r.MaxQueueDepth, float64(r.PeakHeap)/(1<<20), float64(r.Allocated)/(1<<20))
+	return err
+}
+
+// Run installs cfg.Addons synthesized addons with cfg.Workers workers through the workflow lifecycle, workflows are
+// stored stripped like in the manager cache and succeed after cfg.WorkflowDuration. Run returns once every addon
+// is installed or failed, or ctx is done.
+func Run(ctx context.Context, cfg Config) (*Result, error) {
+	if cfg.Addons <= 0 {
+		return nil, fmt.Errorf("at least one addon must be simulated")
+	}
+	if cfg.Workers <= 0 {
+		cfg.Workers = 1
+	}
+
+	scheme, err := newScheme()
+	if err != nil {
+		return nil, err
+	}
+	addons := synthesize(cfg)
+	objs := make([]kruntime.Object, len(addons))
+	for i := range addons {
+		objs[i] = addons[i]
+	}
+
+	queue := workqueue.NewDelayingQueue()
+	b := &backend{
+		Client:    runtimefake.NewFakeClientWithScheme(scheme, objs...),
+		workflows: cache.NewIndexer(cache.MetaNamespaceKeyFunc, workflows.Indexers),
+		duration:  cfg.WorkflowDuration,
+		started:   make(map[string]time.Time),
+		// Workflow changes reconcile their addon like the manager watch
+		changed: func(wf *unstructured.Unstructured) {
+			queue.Add(types.NamespacedName{Namespace: wf.GetNamespace(), Name: wf.GetLabels()[workflows.AddonLabel]})
+		},
+	}
+	stop := make(chan struct{})
+	defer close(stop)
+	go b.run(stop)
+
+	var before runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+
+	result := &Result{Addons: len(addons)}
+	s := &simulation{
+		backend:  b,
+		dyn:      dynfake.NewSimpleDynamicClient(scheme),
+		scheme:   scheme,
+		queue:    queue,
+		addons:   make(map[types.NamespacedName]*addonmgrv1alpha1.Addon, len(addons)),
+		result:   result,
+		finished: make(chan struct{}),
+	}
+	start := time.Now()
+	for _, a := range addons {
+		key := types.NamespacedName{Namespace: a.Namespace, Name: a.Name}
+		s.addons[key] = a
+		queue.Add(key)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < cfg.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for s.next(ctx) {
+			}
+		}()
+	}
+
+	ticker := time.NewTicker(sampleInterval)
+	defer ticker.Stop()
+	var stats runtime.MemStats
+wait:
+	for {
+		select {
+		case <-s.finished:
+			break wait
+		case <-ctx.Done():
+			break wait
+		case <-ticker.C:
+			if depth := queue.Len(); depth > result.MaxQueueDepth {
+				result.MaxQueueDepth = depth
+			}
+			runtime.ReadMemStats(&stats)
+			if stats.HeapInuse > result.PeakHeap {
+				result.PeakHeap = stats.HeapInuse
+			}
+		}
+	}
+	result.Duration = time.Since(start)
+	queue.ShutDown()
+	wg.Wait()
+
+	runtime.ReadMemStats(&stats)
+	result.Allocated = stats.TotalAlloc - before.TotalAlloc
+	result.Reconciles = atomic.LoadInt64(&s.reconciles)
+	return result, ctx.Err()
+}
+
+type simulation struct {
+	*backend
+	dyn        dynamic.Interface
+	scheme     *kruntime.Scheme
+	queue      workqueue.DelayingInterface
+	addons     map[types.NamespacedName]*addonmgrv1alpha1.Addon
+	reconciles int64
+
+	mu       sync.Mutex
+	result   *Result
+	finished chan struct{}
+}
+
+// next reconciles the next queued addon, it returns false once the queue is shut down
+func (s *simulation) next(ctx context.Context) bool {
+	item, shutdown := s.queue.Get()
+	if shutdown {
+		return false
+	}
+	defer s.queue.Done(item)
+
+	s.mu.Lock()
+	a, ok := s.addons[item.(types.NamespacedName)]
+	s.mu.Unlock()
+	if !ok {
+		return true
+	}
+	atomic.AddInt64(&s.reconciles, 1)
+
+	wfl := workflows.NewWorkflowLifecycle(s.backend, s.dyn, a.DeepCopy(), discardRecorder{}, s.scheme,
+		workflows.WithWorkflowCache(s.workflows, namespace))
+	phase, err := wfl.Install(ctx, &a.Spec.Lifecycle.Install, a.GetFormattedWorkflowName(addonmgrv1alpha1.Install))
+	switch {
+	case err != nil || phase == addonmgrv1alpha1.Failed:
+		s.complete(a, false)
+	case phase == addonmgrv1alpha1.Succeeded:
+		s.complete(a, true)
+	default:
+		s.queue.AddAfter(item, requeueInterval)
+	}
+	return true
+}
+
+// complete records the install result of an addon once
+func (s *simulation) complete(a *addonmgrv1alpha1.Addon, installed bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := types.NamespacedName{Namespace: a.Namespace, Name: a.Name}
+	if _, ok := s.addons[key]; !ok {
+		return
+	}
+	delete(s.addons, key)
+	if installed {
+		s.result.Installed++
+	} else {
+		s.result.Failed++
+	}
+	if s.result.Installed+s.result.Failed == s.result.Addons {
+		close(s.finished)
+	}
+}
+
+// backend is a fake Argo backend storing workflows in a cache like the manager workflow informer, created
+// workflows run for duration and succeed
+type backend struct {
+	client.Client
+	workflows cache.Indexer
+	duration  time.Duration
+	changed   func(*unstructured.Unstructured)
+
+	mu      sync.Mutex
+	started map[string]time.Time
+}
+
+// Create starts workflows, other objects are created by the fake client
+func (b *backend) Create(ctx context.Context, obj kruntime.Object, opts ...client.CreateOption) error {
+	wf, ok := obj.(*unstructured.Unstructured)
+	if !ok || wf.GetKind() != "Workflow" {
+		return b.Client.Create(ctx, obj, opts...)
+	}
+
+	key, err := cache.MetaNamespaceKeyFunc(wf)
+	if err != nil {
+		return err
+	}
+	if _, exists, _ := b.workflows.GetByKey(key); exists {
+		return apierrors.NewAlreadyExists(common.WorkflowGVR().GroupResource(), wf.GetName())
+	}
+	wf.Object["status"] = map[string]interface{}{
+		"phase":     "Running",
+		"startedAt": time.Now().UTC().Format(time.RFC3339),
+	}
+	cached := wf.DeepCopy()
+	workflows.StripWorkflow(cached)
+
+	b.mu.Lock()
+	b.started[key] = time.Now()
+	b.mu.Unlock()
+	return b.workflows.Add(cached)
+}
+
+// run completes running workflows after the workflow duration until stop is closed
+func (b *backend) run(stop <-chan struct{}) {
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+
+		var completed []string
+		b.mu.Lock()
+		for key, started := range b.started {
+			if time.Since(started) >= b.duration {
+				completed = append(completed, key)
+				delete(b.started, key)
+			}
+		}
+		b.mu.Unlock()
+
+		for _, key := range completed {
+			obj, exists, _ := b.workflows.GetByKey(key)
+			if !exists {
+				continue
+			}
+			wf := obj.(*unstructured.Unstructured).DeepCopy()
+			_ = unstructured.SetNestedField(wf.Object, "Succeeded", "status", "phase")
+			if err := b.workflows.Update(wf); err == nil {
+				b.changed(wf)
+			}
+		}
+	}
+}
+
+func newScheme() (*kruntime.Scheme, error) {
+	scheme := kruntime.NewScheme()
+	if err := addonmgrv1alpha1.AddToScheme(scheme); err != nil {
+		return nil, err
+	}
+	gv := common.WorkflowGVR().GroupVersion()
+	scheme.AddKnownTypeWithName(gv.WithKind("Workflow"), &unstructured.Unstructured{})
+	scheme.AddKnownTypeWithName(gv.WithKind("WorkflowList"), &unstructured.UnstructuredList{})
+	metav1.AddToGroupVersion(scheme, gv)
+	return scheme, nil
+}
+
+// synthesize returns the addons of a simulation, their install workflow templates embed cfg.TemplateSize bytes
+func synthesize(cfg Config) []*addonmgrv1alpha1.Addon {
+	template := fmt.Sprintf(installTemplate, strings.Repeat("x", cfg.TemplateSize))
+	addons := make([]*addonmgrv1alpha1.Addon, cfg.Addons)
+	for i := range addons {
+		name := fmt.Sprintf("loadtest-%d", i)
+		addons[i] = &addonmgrv1alpha1.Addon{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:       name,
+				Namespace:  namespace,
+				UID:        types.UID(name),
+				Generation: 1,
+			},
+			Spec: addonmgrv1alpha1.AddonSpec{
+				PackageSpec: addonmgrv1alpha1.PackageSpec{
+					PkgName:    name,
+					PkgVersion: "1.0.0",
+					PkgType:    addonmgrv1alpha1.CompositePkg,
+				},
+				Params: addonmgrv1alpha1.AddonParams{
+					Namespace: name,
+				},
+				Lifecycle: addonmgrv1alpha1.LifecycleWorkflowSpec{
+					Install: addonmgrv1alpha1.WorkflowType{Template: template},
+				},
+			},
+		}
+		// The reconcile records the checksum before running workflows
+		addons[i].Status.Checksum = addons[i].CalculateChecksum()
+	}
+	return addons
+}
+
+const installTemplate = `
+apiVersion: argoproj.io/v1alpha1
+kind: Workflow
+spec:
+  entrypoint: entry
+  templates:
+  - name: entry
+    steps:
+    - - name: install
+        template: submit
+  - name: submit
+    resource:
+      action: apply
+      manifest: |
+        apiVersion: v1
+        kind: ConfigMap
+        metadata:
+          name: payload
+          namespace: "{{workflow.parameters.namespace}}"
+        data:
+          payload: "%s"
+`
+
+// discardRecorder drops the events of simulated addons
+type discardRecorder struct{}
+
+func (discardRecorder) Event(kruntime.Object, string, string, string) {}
+
+func (discardRecorder) Eventf(kruntime.Object, string, string, string, ...interface{}) {}
+
+func (discardRecorder) AnnotatedEventf(kruntime.Object, map[string]string, string, string, string, ...interface{}) {
+}
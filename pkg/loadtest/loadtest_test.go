@@ -0,0 +1,46 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package loadtest
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestRun(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	result, err := Run(ctx, Config{Addons: 20, Workers: 4, WorkflowDuration: 20 * time.Millisecond, TemplateSize: 1024})
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(result.Installed).To(Equal(20))
+	g.Expect(result.Failed).To(BeZero())
+	// Every addon submits its workflow and is reconciled again once it succeeded
+	g.Expect(result.Reconciles).To(BeNumerically(">=", 40))
+	g.Expect(result.Throughput()).To(BeNumerically(">", 0))
+
+	var out bytes.Buffer
+	g.Expect(result.Write(&out)).To(Succeed())
+	g.Expect(out.String()).To(ContainSubstring("20 installed, 0 failed"))
+
+	_, err = Run(ctx, Config{})
+	g.Expect(err).To(HaveOccurred())
+}
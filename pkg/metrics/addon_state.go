@@ -0,0 +1,160 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	addonmgrv1alpha1 "github.com/keikoproj/addon-manager/api/v1alpha1"
+)
+
+const listTimeout = 10 * time.Second
+
+var addonPhases = []addonmgrv1alpha1.ApplicationAssemblyPhase{
+	addonmgrv1alpha1.Pending,
+	addonmgrv1alpha1.Succeeded,
+	addonmgrv1alpha1.Failed,
+	addonmgrv1alpha1.Deleting,
+	addonmgrv1alpha1.DeleteFailed,
+}
+
+var (
+	descAddonInfo = prometheus.NewDesc("kube_addon_info",
+		"Information about addon.",
+		[]string{"namespace", "addon", "package", "version", "type", "channel"}, nil)
+	descAddonCreated = prometheus.NewDesc("kube_addon_created",
+		"Unix creation timestamp.",
+		[]string{"namespace", "addon"}, nil)
+	descAddonStatusPhase = prometheus.NewDesc("kube_addon_status_phase",
+		"The addon install phase.",
+		[]string{"namespace", "addon", "phase"}, nil)
+	descAddonStatusPrereqsPhase = prometheus.NewDesc("kube_addon_status_prereqs_phase",
+		"The addon prereqs phase.",
+		[]string{"namespace", "addon", "phase"}, nil)
+	descAddonStatusStartTime = prometheus.NewDesc("kube_addon_status_start_time",
+		"Start time in unix timestamp of the last addon install.",
+		[]string{"namespace", "addon"}, nil)
+	descAddonSpecDependencies = prometheus.NewDesc("kube_addon_spec_dependencies",
+		"Number of package dependencies declared by the addon.",
+		[]string{"namespace", "addon"}, nil)
+	descAddonStatusResources = prometheus.NewDesc("kube_addon_status_resources",
+		"Number of resources observed by the addon.",
+		[]string{"namespace", "addon"}, nil)
+	descAddonStatusClusters = prometheus.NewDesc("kube_addon_status_clusters",
+		"Number of clusters targeted by a multi-cluster addon by install phase.",
+		[]string{"namespace", "addon", "phase"}, nil)
+	descAddonDependencyConflict = prometheus.NewDesc("kube_addon_dependency_conflict",
+		"Addons requiring versions of a dependency incompatible with the addon.",
+		[]string{"namespace", "addon", "dependency", "required", "conflicting_addon", "conflicting_required"}, nil)
+)
+
+// AddonStateCollector exports Addon spec and status fields as kube-state-metrics style kube_addon_* metrics
+type AddonStateCollector struct {
+	reader client.Reader
+	log    logr.Logger
+}
+
+// NewAddonStateCollector returns a collector listing addons from reader on every scrape,
+// reader should be the cache backed manager client
+func NewAddonStateCollector(reader client.Reader, log logr.Logger) *AddonStateCollector {
+	return &AddonStateCollector{reader: reader, log: log}
+}
+
+// Describe implements prometheus.Collector
+func (c *AddonStateCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- descAddonInfo
+	ch <- descAddonCreated
+	ch <- descAddonStatusPhase
+	ch <- descAddonStatusPrereqsPhase
+	ch <- descAddonStatusStartTime
+	ch <- descAddonSpecDependencies
+	ch <- descAddonStatusResources
+	ch <- descAddonStatusClusters
+	ch <- descAddonDependencyConflict
+}
+
+// Collect implements prometheus.Collector
+func (c *AddonStateCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), listTimeout)
+	defer cancel()
+
+	var addons addonmgrv1alpha1.AddonList
+	if err := c.reader.List(ctx, &addons); err != nil {
+		c.log.Error(err, "failed to list addons for state metrics")
+		return
+	}
+
+	for i := range addons.Items {
+		collectAddon(ch, &addons.Items[i])
+	}
+}
+
+func collectAddon(ch chan<- prometheus.Metric, a *addonmgrv1alpha1.Addon) {
+	ns, name := a.GetNamespace(), a.GetName()
+
+	ch <- prometheus.MustNewConstMetric(descAddonInfo, prometheus.GaugeValue, 1,
+		ns, name, a.Spec.PkgName, a.Spec.PkgVersion, string(a.Spec.PkgType), a.Spec.PkgChannel)
+
+	if created := a.GetCreationTimestamp(); !created.IsZero() {
+		ch <- prometheus.MustNewConstMetric(descAddonCreated, prometheus.GaugeValue,
+			float64(created.Unix()), ns, name)
+	}
+
+	for _, phase := range addonPhases {
+		ch <- prometheus.MustNewConstMetric(descAddonStatusPhase, prometheus.GaugeValue,
+			boolFloat(a.Status.Lifecycle.Installed == phase), ns, name, string(phase))
+		ch <- prometheus.MustNewConstMetric(descAddonStatusPrereqsPhase, prometheus.GaugeValue,
+			boolFloat(a.Status.Lifecycle.Prereqs == phase), ns, name, string(phase))
+	}
+
+	// StartTime is stored in milliseconds
+	if a.Status.StartTime > 0 {
+		ch <- prometheus.MustNewConstMetric(descAddonStatusStartTime, prometheus.GaugeValue,
+			float64(a.Status.StartTime)/1000, ns, name)
+	}
+
+	ch <- prometheus.MustNewConstMetric(descAddonSpecDependencies, prometheus.GaugeValue,
+		float64(len(a.Spec.PkgDeps)), ns, name)
+	ch <- prometheus.MustNewConstMetric(descAddonStatusResources, prometheus.GaugeValue,
+		float64(len(a.Status.Resources)), ns, name)
+
+	for _, d := range a.Status.Dependencies {
+		for _, c := range d.Conflicts {
+			ch <- prometheus.MustNewConstMetric(descAddonDependencyConflict, prometheus.GaugeValue, 1,
+				ns, name, d.Name, d.Required, c.Addon, c.Required)
+		}
+	}
+
+	if s := a.Status.Summary; s != nil {
+		ch <- prometheus.MustNewConstMetric(descAddonStatusClusters, prometheus.GaugeValue,
+			float64(s.Succeeded), ns, name, string(addonmgrv1alpha1.Succeeded))
+		ch <- prometheus.MustNewConstMetric(descAddonStatusClusters, prometheus.GaugeValue,
+			float64(s.Failed), ns, name, string(addonmgrv1alpha1.Failed))
+		ch <- prometheus.MustNewConstMetric(descAddonStatusClusters, prometheus.GaugeValue,
+			float64(s.Pending), ns, name, string(addonmgrv1alpha1.Pending))
+	}
+}
+
+func boolFloat(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,85 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package metrics
+
+import (
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	runtimefake "sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	addonmgrv1alpha1 "github.com/keikoproj/addon-manager/api/v1alpha1"
+)
+
+func TestAddonStateCollector(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	sch := runtime.NewScheme()
+	g.Expect(addonmgrv1alpha1.AddToScheme(sch)).To(Succeed())
+
+	a := &addonmgrv1alpha1.Addon{}
+	a.SetName("event-router")
+	a.SetNamespace("addon-manager-system")
+	a.Spec.PkgName = "addon-event-router"
+	a.Spec.PkgVersion = "v0.2"
+	a.Spec.PkgType = addonmgrv1alpha1.CompositePkg
+	a.Spec.PkgDeps = map[string]string{"core/A": "*"}
+	a.Status.Lifecycle.Installed = addonmgrv1alpha1.Succeeded
+	a.Status.StartTime = 1600000000000
+	a.Status.Dependencies = []addonmgrv1alpha1.DependencyStatus{{
+		Name:      "core/A",
+		Required:  "~1.0",
+		Conflicts: []addonmgrv1alpha1.DependencyConflict{{Addon: "addon-manager-system/legacy", Required: "~0.9"}},
+	}}
+	a.Status.Summary = addonmgrv1alpha1.NewClusterSummary([]addonmgrv1alpha1.ClusterStatus{
+		{Cluster: "prod-1", Installed: addonmgrv1alpha1.Succeeded},
+		{Cluster: "prod-2", Installed: addonmgrv1alpha1.Failed},
+	})
+
+	c := NewAddonStateCollector(runtimefake.NewFakeClientWithScheme(sch, a), ctrl.Log)
+
+	expected := `
+# HELP kube_addon_dependency_conflict Addons requiring versions of a dependency incompatible with the addon.
+# TYPE kube_addon_dependency_conflict gauge
+kube_addon_dependency_conflict{addon="event-router",conflicting_addon="addon-manager-system/legacy",conflicting_required="~0.9",dependency="core/A",namespace="addon-manager-system",required="~1.0"} 1
+# HELP kube_addon_info Information about addon.
+# TYPE kube_addon_info gauge
+kube_addon_info{addon="event-router",channel="",namespace="addon-manager-system",package="addon-event-router",type="composite",version="v0.2"} 1
+# HELP kube_addon_spec_dependencies Number of package dependencies declared by the addon.
+# TYPE kube_addon_spec_dependencies gauge
+kube_addon_spec_dependencies{addon="event-router",namespace="addon-manager-system"} 1
+# HELP kube_addon_status_clusters Number of clusters targeted by a multi-cluster addon by install phase.
+# TYPE kube_addon_status_clusters gauge
+kube_addon_status_clusters{addon="event-router",namespace="addon-manager-system",phase="Failed"} 1
+kube_addon_status_clusters{addon="event-router",namespace="addon-manager-system",phase="Pending"} 0
+kube_addon_status_clusters{addon="event-router",namespace="addon-manager-system",phase="Succeeded"} 1
+# HELP kube_addon_status_phase The addon install phase.
+# TYPE kube_addon_status_phase gauge
+kube_addon_status_phase{addon="event-router",namespace="addon-manager-system",phase="Delete Failed"} 0
+kube_addon_status_phase{addon="event-router",namespace="addon-manager-system",phase="Deleting"} 0
+kube_addon_status_phase{addon="event-router",namespace="addon-manager-system",phase="Failed"} 0
+kube_addon_status_phase{addon="event-router",namespace="addon-manager-system",phase="Pending"} 0
+kube_addon_status_phase{addon="event-router",namespace="addon-manager-system",phase="Succeeded"} 1
+# HELP kube_addon_status_start_time Start time in unix timestamp of the last addon install.
+# TYPE kube_addon_status_start_time gauge
+kube_addon_status_start_time{addon="event-router",namespace="addon-manager-system"} 1.6e+09
+`
+	g.Expect(testutil.CollectAndCompare(c, strings.NewReader(expected),
+		"kube_addon_dependency_conflict", "kube_addon_info", "kube_addon_spec_dependencies", "kube_addon_status_clusters", "kube_addon_status_phase", "kube_addon_status_start_time")).To(Succeed())
+}
@@ -0,0 +1,171 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package metrics
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	addonmgrv1alpha1 "github.com/keikoproj/addon-manager/api/v1alpha1"
+	"github.com/keikoproj/addon-manager/pkg/addon"
+)
+
+const metricsNamespace = "addonmgr"
+
+// Aggregation controls the granularity of per-addon metric labels
+type Aggregation string
+
+const (
+	// AggregateByAddon labels per-addon metrics with namespace, name and package
+	AggregateByAddon Aggregation = "addon"
+	// AggregateByPackage drops the addon namespace and name labels
+	AggregateByPackage Aggregation = "package"
+	// AggregateByNamespace drops the addon name and package labels
+	AggregateByNamespace Aggregation = "namespace"
+)
+
+// addonLabels are the labels of per-addon metrics, values dropped by the aggregation are left empty
+var addonLabels = []string{"namespace", "name", "package"}
+
+var aggregation = AggregateByAddon
+
+var (
+	dependencyEdges = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "dependency_edges",
+		Help:      "Number of declared dependencies between addons.",
+	})
+
+	dependencyUnsatisfied = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "dependency_unsatisfied",
+		Help:      "Number of declared dependencies that are missing or not yet Succeeded.",
+	})
+
+	dependencyLongestChain = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "dependency_longest_chain",
+		Help:      "Number of edges in the longest addon dependency chain.",
+	})
+
+	packageInstallResults = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "package_install_results_total",
+		Help:      "Number of completed addon installs by package and result.",
+	}, []string{"package", "result"})
+
+	addonPhaseTransitions = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "addon_phase_transitions_total",
+		Help:      "Number of addon install phase transitions by phase.",
+	}, append(addonLabels, "phase"))
+
+	workflowDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "workflow_duration_seconds",
+		Help:      "Duration of completed addon lifecycle workflows by lifecycle step and phase.",
+		Buckets:   []float64{10, 30, 60, 120, 300, 600, 1200, 1800, 3600},
+	}, []string{"step", "phase"})
+
+	reconcileErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "reconcile_errors_total",
+		Help:      "Number of addon reconciles that returned an error.",
+	}, addonLabels)
+
+	dependencyWait = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "dependency_wait_seconds",
+		Help:      "Time addons waited for their dependencies to be installed.",
+		Buckets:   []float64{10, 30, 60, 300, 600, 1800, 3600, 7200},
+	})
+)
+
+func init() {
+	metrics.Registry.MustRegister(
+		dependencyEdges,
+		dependencyUnsatisfied,
+		dependencyLongestChain,
+		packageInstallResults,
+		addonPhaseTransitions,
+		workflowDuration,
+		reconcileErrors,
+		dependencyWait,
+	)
+}
+
+// SetAggregation sets the label aggregation used by per-addon metrics
+func SetAggregation(a Aggregation) error {
+	switch a {
+	case AggregateByAddon, AggregateByPackage, AggregateByNamespace:
+		aggregation = a
+		return nil
+	default:
+		return fmt.Errorf("unsupported metrics aggregation %q", a)
+	}
+}
+
+// addonLabelValues returns the label values of an addon for the configured aggregation
+func addonLabelValues(addon *addonmgrv1alpha1.Addon) []string {
+	switch aggregation {
+	case AggregateByPackage:
+		return []string{"", "", addon.Spec.PkgName}
+	case AggregateByNamespace:
+		return []string{addon.GetNamespace(), "", ""}
+	default:
+		return []string{addon.GetNamespace(), addon.GetName(), addon.Spec.PkgName}
+	}
+}
+
+// RecordDependencyGraph sets the dependency graph gauges from the given stats
+func RecordDependencyGraph(stats addon.DependencyGraphStats) {
+	dependencyEdges.Set(float64(stats.Edges))
+	dependencyUnsatisfied.Set(float64(stats.Unsatisfied))
+	dependencyLongestChain.Set(float64(stats.LongestChain))
+}
+
+// RecordInstallResult counts a completed install for the package, only Succeeded and Failed phases are recorded
+func RecordInstallResult(pkgName string, phase addonmgrv1alpha1.ApplicationAssemblyPhase) {
+	switch phase {
+	case addonmgrv1alpha1.Succeeded:
+		packageInstallResults.WithLabelValues(pkgName, "succeeded").Inc()
+	case addonmgrv1alpha1.Failed:
+		packageInstallResults.WithLabelValues(pkgName, "failed").Inc()
+	}
+}
+
+// RecordPhaseTransition counts the addon entering its current install phase
+func RecordPhaseTransition(addon *addonmgrv1alpha1.Addon) {
+	labels := append(addonLabelValues(addon), string(addon.Status.Lifecycle.Installed))
+	addonPhaseTransitions.WithLabelValues(labels...).Inc()
+}
+
+// RecordWorkflowDuration observes the duration of a completed lifecycle workflow
+func RecordWorkflowDuration(step addonmgrv1alpha1.LifecycleStep, phase addonmgrv1alpha1.ApplicationAssemblyPhase, d time.Duration) {
+	workflowDuration.WithLabelValues(string(step), string(phase)).Observe(d.Seconds())
+}
+
+// RecordReconcileError counts a reconcile of the addon that returned an error
+func RecordReconcileError(addon *addonmgrv1alpha1.Addon) {
+	reconcileErrors.WithLabelValues(addonLabelValues(addon)...).Inc()
+}
+
+// RecordDependencyWait observes the time an addon waited for its dependencies
+func RecordDependencyWait(d time.Duration) {
+	dependencyWait.Observe(d.Seconds())
+}
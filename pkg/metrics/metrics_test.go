@@ -0,0 +1,88 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package metrics
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	addonmgrv1alpha1 "github.com/keikoproj/addon-manager/api/v1alpha1"
+	"github.com/keikoproj/addon-manager/pkg/addon"
+)
+
+func TestRecordDependencyGraph(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	RecordDependencyGraph(addon.DependencyGraphStats{Edges: 5, Unsatisfied: 2, LongestChain: 3})
+
+	g.Expect(testutil.ToFloat64(dependencyEdges)).To(Equal(float64(5)))
+	g.Expect(testutil.ToFloat64(dependencyUnsatisfied)).To(Equal(float64(2)))
+	g.Expect(testutil.ToFloat64(dependencyLongestChain)).To(Equal(float64(3)))
+}
+
+func TestRecordInstallResult(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	RecordInstallResult("test/pkg", addonmgrv1alpha1.Succeeded)
+	RecordInstallResult("test/pkg", addonmgrv1alpha1.Succeeded)
+	RecordInstallResult("test/pkg", addonmgrv1alpha1.Failed)
+	RecordInstallResult("test/pkg", addonmgrv1alpha1.Pending)
+
+	g.Expect(testutil.ToFloat64(packageInstallResults.WithLabelValues("test/pkg", "succeeded"))).To(Equal(float64(2)))
+	g.Expect(testutil.ToFloat64(packageInstallResults.WithLabelValues("test/pkg", "failed"))).To(Equal(float64(1)))
+}
+
+func TestRecordPhaseTransitionAggregation(t *testing.T) {
+	g := NewGomegaWithT(t)
+	defer func() { _ = SetAggregation(AggregateByAddon) }()
+
+	a := &addonmgrv1alpha1.Addon{}
+	a.SetName("agg-addon")
+	a.SetNamespace("agg-ns")
+	a.Spec.PkgName = "agg/pkg"
+	a.Status.Lifecycle.Installed = addonmgrv1alpha1.Succeeded
+
+	RecordPhaseTransition(a)
+	g.Expect(testutil.ToFloat64(addonPhaseTransitions.WithLabelValues("agg-ns", "agg-addon", "agg/pkg", "Succeeded"))).To(Equal(float64(1)))
+
+	g.Expect(SetAggregation(AggregateByPackage)).To(Succeed())
+	RecordPhaseTransition(a)
+	g.Expect(testutil.ToFloat64(addonPhaseTransitions.WithLabelValues("", "", "agg/pkg", "Succeeded"))).To(Equal(float64(1)))
+
+	g.Expect(SetAggregation(AggregateByNamespace)).To(Succeed())
+	RecordPhaseTransition(a)
+	g.Expect(testutil.ToFloat64(addonPhaseTransitions.WithLabelValues("agg-ns", "", "", "Succeeded"))).To(Equal(float64(1)))
+
+	g.Expect(SetAggregation("cluster")).NotTo(Succeed())
+}
+
+func TestRecordLifecycleMetrics(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	RecordWorkflowDuration(addonmgrv1alpha1.Install, addonmgrv1alpha1.Succeeded, 90*time.Second)
+	RecordDependencyWait(2 * time.Minute)
+	g.Expect(testutil.CollectAndCount(workflowDuration)).To(BeNumerically(">=", 1))
+	g.Expect(testutil.CollectAndCount(dependencyWait)).To(Equal(1))
+
+	a := &addonmgrv1alpha1.Addon{}
+	a.SetName("error-addon")
+	a.SetNamespace("error-ns")
+	a.Spec.PkgName = "error/pkg"
+	RecordReconcileError(a)
+	g.Expect(testutil.ToFloat64(reconcileErrors.WithLabelValues("error-ns", "error-addon", "error/pkg"))).To(Equal(float64(1)))
+}
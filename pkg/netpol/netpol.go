@@ -0,0 +1,125 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package netpol
+
+import (
+	"context"
+
+	v1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/kubernetes"
+
+	addonmgrv1alpha1 "github.com/keikoproj/addon-manager/api/v1alpha1"
+)
+
+// AddonLabel marks generated network policies with the <namespace>.<name> of the addon they were generated for
+const AddonLabel = "addonmgr.keikoproj.io/netpol-for"
+
+// dnsEgress allows pods to resolve names once egress is denied
+var dnsEgress = func() networkingv1.NetworkPolicyEgressRule {
+	udp, tcp := v1.ProtocolUDP, v1.ProtocolTCP
+	port := intstr.FromInt(53)
+	return networkingv1.NetworkPolicyEgressRule{
+		Ports: []networkingv1.NetworkPolicyPort{{Protocol: &udp, Port: &port}, {Protocol: &tcp, Port: &port}},
+	}
+}()
+
+// Generator creates the baseline network policies of addon target namespaces
+type Generator struct {
+	client kubernetes.Interface
+}
+
+// NewGenerator returns a Generator
+func NewGenerator(client kubernetes.Interface) *Generator {
+	return &Generator{client: client}
+}
+
+// Policies returns the default deny policy and the policy allowing the traffic declared in the addon spec
+func Policies(addon *addonmgrv1alpha1.Addon) []*networkingv1.NetworkPolicy {
+	spec := addon.Spec.NetworkPolicy
+	if spec == nil {
+		return nil
+	}
+
+	meta := func(suffix string) metav1.ObjectMeta {
+		return metav1.ObjectMeta{
+			Name:      addon.Name + suffix,
+			Namespace: addon.Spec.Params.Namespace,
+			Labels:    map[string]string{AddonLabel: addon.Namespace + "." + addon.Name},
+		}
+	}
+	policyTypes := []networkingv1.PolicyType{networkingv1.PolicyTypeIngress, networkingv1.PolicyTypeEgress}
+
+	return []*networkingv1.NetworkPolicy{
+		{
+			ObjectMeta: meta("-default-deny"),
+			Spec:       networkingv1.NetworkPolicySpec{PolicyTypes: policyTypes},
+		},
+		{
+			ObjectMeta: meta("-allow"),
+			Spec: networkingv1.NetworkPolicySpec{
+				PolicyTypes: policyTypes,
+				Ingress:     spec.Ingress,
+				Egress:      append([]networkingv1.NetworkPolicyEgressRule{dnsEgress}, spec.Egress...),
+			},
+		},
+	}
+}
+
+// Apply creates or updates the network policies of the addon, policies are removed when the addon no longer declares them
+func (g *Generator) Apply(ctx context.Context, addon *addonmgrv1alpha1.Addon) error {
+	policies := Policies(addon)
+	if policies == nil {
+		return g.Cleanup(ctx, addon)
+	}
+
+	for _, policy := range policies {
+		client := g.client.NetworkingV1().NetworkPolicies(policy.Namespace)
+		existing, err := client.Get(ctx, policy.Name, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			_, err = client.Create(ctx, policy, metav1.CreateOptions{})
+		} else if err == nil {
+			existing.Labels, existing.Spec = policy.Labels, policy.Spec
+			_, err = client.Update(ctx, existing, metav1.UpdateOptions{})
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Cleanup deletes the network policies generated for the addon
+func (g *Generator) Cleanup(ctx context.Context, addon *addonmgrv1alpha1.Addon) error {
+	if addon.Spec.Params.Namespace == "" {
+		return nil
+	}
+	selector := metav1.ListOptions{LabelSelector: AddonLabel + "=" + addon.Namespace + "." + addon.Name}
+	client := g.client.NetworkingV1().NetworkPolicies(addon.Spec.Params.Namespace)
+	policies, err := client.List(ctx, selector)
+	if err != nil {
+		return err
+	}
+	for _, policy := range policies.Items {
+		if err := client.Delete(ctx, policy.Name, metav1.DeleteOptions{}); err != nil && !apierrors.IsNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
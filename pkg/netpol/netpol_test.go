@@ -0,0 +1,90 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package netpol
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	addonmgrv1alpha1 "github.com/keikoproj/addon-manager/api/v1alpha1"
+)
+
+func newNetpolAddon() *addonmgrv1alpha1.Addon {
+	return &addonmgrv1alpha1.Addon{
+		ObjectMeta: metav1.ObjectMeta{Name: "netpol-test", Namespace: "addon-manager-system"},
+		Spec: addonmgrv1alpha1.AddonSpec{
+			Params: addonmgrv1alpha1.AddonParams{Namespace: "app-ns"},
+			NetworkPolicy: &addonmgrv1alpha1.AddonNetworkPolicySpec{
+				Ingress: []networkingv1.NetworkPolicyIngressRule{{
+					From: []networkingv1.NetworkPolicyPeer{{NamespaceSelector: &metav1.LabelSelector{
+						MatchLabels: map[string]string{"name": "monitoring"},
+					}}},
+				}},
+			},
+		},
+	}
+}
+
+func TestPolicies(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	a := newNetpolAddon()
+	policies := Policies(a)
+	g.Expect(policies).To(HaveLen(2))
+
+	deny := policies[0]
+	g.Expect(deny.Name).To(Equal("netpol-test-default-deny"))
+	g.Expect(deny.Namespace).To(Equal("app-ns"))
+	g.Expect(deny.Spec.Ingress).To(BeEmpty())
+	g.Expect(deny.Spec.Egress).To(BeEmpty())
+	g.Expect(deny.Spec.PolicyTypes).To(ConsistOf(networkingv1.PolicyTypeIngress, networkingv1.PolicyTypeEgress))
+
+	allow := policies[1]
+	g.Expect(allow.Spec.Ingress).To(Equal(a.Spec.NetworkPolicy.Ingress))
+	g.Expect(allow.Spec.Egress).To(Equal([]networkingv1.NetworkPolicyEgressRule{dnsEgress}))
+
+	a.Spec.NetworkPolicy = nil
+	g.Expect(Policies(a)).To(BeNil())
+}
+
+func TestGenerator_ApplyAndCleanup(t *testing.T) {
+	g := NewGomegaWithT(t)
+	ctx := context.TODO()
+	client := fake.NewSimpleClientset()
+	gen := NewGenerator(client)
+	a := newNetpolAddon()
+
+	g.Expect(gen.Apply(ctx, a)).To(Succeed())
+	list, err := client.NetworkingV1().NetworkPolicies("app-ns").List(ctx, metav1.ListOptions{})
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(list.Items).To(HaveLen(2))
+
+	// Applying again updates in place
+	a.Spec.NetworkPolicy.Ingress = nil
+	g.Expect(gen.Apply(ctx, a)).To(Succeed())
+	allow, err := client.NetworkingV1().NetworkPolicies("app-ns").Get(ctx, "netpol-test-allow", metav1.GetOptions{})
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(allow.Spec.Ingress).To(BeEmpty())
+
+	g.Expect(gen.Cleanup(ctx, a)).To(Succeed())
+	list, err = client.NetworkingV1().NetworkPolicies("app-ns").List(ctx, metav1.ListOptions{})
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(list.Items).To(BeEmpty())
+}
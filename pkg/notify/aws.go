@@ -0,0 +1,157 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package notify
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/client"
+	"github.com/aws/aws-sdk-go/service/eventbridge"
+	"github.com/aws/aws-sdk-go/service/eventbridge/eventbridgeiface"
+	"github.com/aws/aws-sdk-go/service/sns"
+	"github.com/aws/aws-sdk-go/service/sns/snsiface"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/aws/aws-sdk-go/service/sqs/sqsiface"
+	"k8s.io/apimachinery/pkg/util/clock"
+)
+
+const (
+	// EventSource is the source used for published cloud events
+	EventSource = "addonmgr.keikoproj.io"
+	// EventDetailType is the EventBridge detail-type of addon lifecycle events
+	EventDetailType = "Addon Lifecycle Event"
+)
+
+// noRetries disables the retries of the SDK, publishers retry with their own backoff
+var noRetries = aws.NewConfig().WithMaxRetries(0)
+
+type awsPublisher struct {
+	service string
+	publish func(ctx context.Context, ev Event, detail []byte) error
+	retries int
+	backoff time.Duration
+	clock   clock.Clock
+}
+
+// NewSNSNotifier returns a Notifier publishing lifecycle events as JSON messages to an SNS topic
+func NewSNSNotifier(p client.ConfigProvider, topicARN string) Notifier {
+	return newSNSPublisher(sns.New(p, noRetries), topicARN)
+}
+
+func newSNSPublisher(api snsiface.SNSAPI, topicARN string) *awsPublisher {
+	return newAWSPublisher("sns", func(ctx context.Context, ev Event, detail []byte) error {
+		_, err := api.PublishWithContext(ctx, &sns.PublishInput{
+			TopicArn: aws.String(topicARN),
+			Subject:  aws.String(truncate(summary(ev), 100)),
+			Message:  aws.String(string(detail)),
+			MessageAttributes: map[string]*sns.MessageAttributeValue{
+				"phase":   {DataType: aws.String("String"), StringValue: aws.String(string(ev.Phase))},
+				"package": {DataType: aws.String("String"), StringValue: aws.String(ev.Package)},
+			},
+		})
+		return err
+	})
+}
+
+// NewSQSNotifier returns a Notifier sending lifecycle events as JSON messages to an SQS queue. Events of an addon
+// share a message group on FIFO queues so they are delivered in order.
+func NewSQSNotifier(p client.ConfigProvider, queueURL string) Notifier {
+	return newSQSPublisher(sqs.New(p, noRetries), queueURL)
+}
+
+func newSQSPublisher(api sqsiface.SQSAPI, queueURL string) *awsPublisher {
+	return newAWSPublisher("sqs", func(ctx context.Context, ev Event, detail []byte) error {
+		input := &sqs.SendMessageInput{
+			QueueUrl:    aws.String(queueURL),
+			MessageBody: aws.String(string(detail)),
+			MessageAttributes: map[string]*sqs.MessageAttributeValue{
+				"phase":   {DataType: aws.String("String"), StringValue: aws.String(string(ev.Phase))},
+				"package": {DataType: aws.String("String"), StringValue: aws.String(ev.Package)},
+			},
+		}
+		if strings.HasSuffix(queueURL, ".fifo") {
+			sum := sha256.Sum256(detail)
+			input.MessageGroupId = aws.String(ev.Namespace + "/" + ev.Addon)
+			input.MessageDeduplicationId = aws.String(hex.EncodeToString(sum[:]))
+		}
+		_, err := api.SendMessageWithContext(ctx, input)
+		return err
+	})
+}
+
+// NewEventBridgeNotifier returns a Notifier publishing lifecycle events to an EventBridge bus
+func NewEventBridgeNotifier(p client.ConfigProvider, busName string) Notifier {
+	return newEventBridgePublisher(eventbridge.New(p, noRetries), busName)
+}
+
+func newEventBridgePublisher(api eventbridgeiface.EventBridgeAPI, busName string) *awsPublisher {
+	return newAWSPublisher("events", func(ctx context.Context, ev Event, detail []byte) error {
+		out, err := api.PutEventsWithContext(ctx, &eventbridge.PutEventsInput{
+			Entries: []*eventbridge.PutEventsRequestEntry{{
+				Source:       aws.String(EventSource),
+				DetailType:   aws.String(EventDetailType),
+				Detail:       aws.String(string(detail)),
+				EventBusName: aws.String(busName),
+			}},
+		})
+		if err != nil {
+			return err
+		}
+		if aws.Int64Value(out.FailedEntryCount) > 0 && len(out.Entries) > 0 {
+			return fmt.Errorf("event rejected %s: %s", aws.StringValue(out.Entries[0].ErrorCode), aws.StringValue(out.Entries[0].ErrorMessage))
+		}
+		return nil
+	})
+}
+
+func newAWSPublisher(service string, publish func(ctx context.Context, ev Event, detail []byte) error) *awsPublisher {
+	return &awsPublisher{
+		service: service,
+		publish: publish,
+		retries: defaultRetries,
+		backoff: defaultBackoff,
+		clock:   clock.RealClock{},
+	}
+}
+
+func (p *awsPublisher) Notify(ctx context.Context, ev Event) error {
+	detail, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+
+	return retry(ctx, p.clock, p.retries, p.backoff, func() error {
+		ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+		defer cancel()
+		if err := p.publish(ctx, ev, detail); err != nil {
+			return fmt.Errorf("%s publish failed. %v", p.service, err)
+		}
+		return nil
+	})
+}
+
+func truncate(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+	return s[:max]
+}
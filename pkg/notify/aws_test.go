@@ -0,0 +1,140 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/eventbridge"
+	"github.com/aws/aws-sdk-go/service/eventbridge/eventbridgeiface"
+	"github.com/aws/aws-sdk-go/service/sns"
+	"github.com/aws/aws-sdk-go/service/sns/snsiface"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/aws/aws-sdk-go/service/sqs/sqsiface"
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/util/clock"
+)
+
+type fakeSNS struct {
+	snsiface.SNSAPI
+	input *sns.PublishInput
+}
+
+func (f *fakeSNS) PublishWithContext(_ aws.Context, input *sns.PublishInput, _ ...request.Option) (*sns.PublishOutput, error) {
+	f.input = input
+	return &sns.PublishOutput{}, nil
+}
+
+type fakeSQS struct {
+	sqsiface.SQSAPI
+	inputs []*sqs.SendMessageInput
+	errs   []error
+}
+
+func (f *fakeSQS) SendMessageWithContext(_ aws.Context, input *sqs.SendMessageInput, _ ...request.Option) (*sqs.SendMessageOutput, error) {
+	f.inputs = append(f.inputs, input)
+	if len(f.errs) > 0 {
+		err := f.errs[0]
+		f.errs = f.errs[1:]
+		return nil, err
+	}
+	return &sqs.SendMessageOutput{}, nil
+}
+
+type fakeEventBridge struct {
+	eventbridgeiface.EventBridgeAPI
+	input  *eventbridge.PutEventsInput
+	failed int64
+}
+
+func (f *fakeEventBridge) PutEventsWithContext(_ aws.Context, input *eventbridge.PutEventsInput, _ ...request.Option) (*eventbridge.PutEventsOutput, error) {
+	f.input = input
+	return &eventbridge.PutEventsOutput{
+		FailedEntryCount: aws.Int64(f.failed),
+		Entries:          []*eventbridge.PutEventsResultEntry{{ErrorCode: aws.String("Denied"), ErrorMessage: aws.String("nope")}},
+	}, nil
+}
+
+func TestSNSNotifier_Notify(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	api := &fakeSNS{}
+	n := newSNSPublisher(api, "arn:aws:sns:us-west-2:123456789012:addons")
+
+	g.Expect(n.Notify(context.TODO(), newTestEvent())).To(Succeed())
+	g.Expect(aws.StringValue(api.input.TopicArn)).To(Equal("arn:aws:sns:us-west-2:123456789012:addons"))
+	g.Expect(aws.StringValue(api.input.MessageAttributes["phase"].StringValue)).To(Equal("Failed"))
+
+	var ev Event
+	g.Expect(json.Unmarshal([]byte(aws.StringValue(api.input.Message)), &ev)).To(Succeed())
+	g.Expect(ev.Addon).To(Equal("notify-test"))
+}
+
+func TestSQSNotifier_Notify(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	api := &fakeSQS{}
+	g.Expect(newSQSPublisher(api, "https://sqs.us-west-2.amazonaws.com/123456789012/addons").Notify(context.TODO(), newTestEvent())).To(Succeed())
+	g.Expect(api.inputs).To(HaveLen(1))
+	g.Expect(aws.StringValue(api.inputs[0].MessageAttributes["package"].StringValue)).To(Equal("notify-test"))
+	g.Expect(api.inputs[0].MessageGroupId).To(BeNil())
+
+	// Events of an addon are ordered on FIFO queues
+	api = &fakeSQS{}
+	g.Expect(newSQSPublisher(api, "https://sqs.us-west-2.amazonaws.com/123456789012/addons.fifo").Notify(context.TODO(), newTestEvent())).To(Succeed())
+	g.Expect(aws.StringValue(api.inputs[0].MessageGroupId)).To(Equal("default/notify-test"))
+	g.Expect(aws.StringValue(api.inputs[0].MessageDeduplicationId)).ToNot(BeEmpty())
+}
+
+func TestAWSPublisher_Retry(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	api := &fakeSQS{errs: []error{errors.New("throttled"), errors.New("throttled")}}
+	fakeClock := clock.NewFakeClock(time.Now())
+	n := newSQSPublisher(api, "https://sqs.us-west-2.amazonaws.com/123456789012/addons")
+	n.clock = fakeClock
+
+	done := make(chan error, 1)
+	go func() { done <- n.Notify(context.TODO(), newTestEvent()) }()
+	// Retries back off exponentially on the publisher clock
+	for _, backoff := range []time.Duration{defaultBackoff, 2 * defaultBackoff} {
+		g.Eventually(fakeClock.HasWaiters).Should(BeTrue())
+		fakeClock.Step(backoff)
+	}
+	g.Expect(<-done).To(Succeed())
+	g.Expect(api.inputs).To(HaveLen(3))
+}
+
+func TestEventBridgeNotifier_Notify(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	api := &fakeEventBridge{}
+	n := newEventBridgePublisher(api, "addons-bus")
+	n.retries = 1
+
+	g.Expect(n.Notify(context.TODO(), newTestEvent())).To(Succeed())
+	g.Expect(api.input.Entries).To(HaveLen(1))
+	g.Expect(aws.StringValue(api.input.Entries[0].Source)).To(Equal(EventSource))
+	g.Expect(aws.StringValue(api.input.Entries[0].EventBusName)).To(Equal("addons-bus"))
+
+	api.failed = 1
+	g.Expect(n.Notify(context.TODO(), newTestEvent())).To(MatchError(ContainSubstring("event rejected Denied: nope")))
+}
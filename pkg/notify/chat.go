@@ -0,0 +1,227 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/clock"
+
+	addonmgrv1alpha1 "github.com/keikoproj/addon-manager/api/v1alpha1"
+)
+
+const (
+	// SlackWebhookAnnotation is an addon annotation holding a Slack incoming webhook url for that addon
+	SlackWebhookAnnotation = "addonmgr.keikoproj.io/slack-webhook-url"
+	// TeamsWebhookAnnotation is an addon annotation holding a Microsoft Teams incoming webhook url for that addon
+	TeamsWebhookAnnotation = "addonmgr.keikoproj.io/teams-webhook-url"
+)
+
+type chatFormatter func(ev Event, link string) interface{}
+
+type chatNotifier struct {
+	url        string
+	annotation string
+	// allowedHosts are the hosts webhook urls of addon annotations may point to
+	allowedHosts []string
+	logsURL      string
+	format       chatFormatter
+	retries      int
+	backoff      time.Duration
+	clock        clock.Clock
+	client       *http.Client
+}
+
+// NewSlackNotifier returns a Notifier posting success and failure messages to a Slack incoming webhook.
+// The url may be empty, in which case only addons with the SlackWebhookAnnotation are notified.
+// Annotation urls must be https urls of one of allowedHosts, addon annotations are ignored when there are none.
+// logsURL is the base url of the Argo UI used to link to the workflow logs.
+func NewSlackNotifier(url, logsURL string, allowedHosts []string) Notifier {
+	return &chatNotifier{
+		url:          url,
+		annotation:   SlackWebhookAnnotation,
+		allowedHosts: allowedHosts,
+		logsURL:      logsURL,
+		format:       slackMessage,
+		retries:      defaultRetries,
+		backoff:      defaultBackoff,
+		clock:        clock.RealClock{},
+		client:       &http.Client{Timeout: defaultTimeout},
+	}
+}
+
+// NewTeamsNotifier returns a Notifier posting success and failure messages to a Microsoft Teams incoming webhook.
+// The url may be empty, in which case only addons with the TeamsWebhookAnnotation are notified.
+// Annotation urls must be https urls of one of allowedHosts, addon annotations are ignored when there are none.
+// logsURL is the base url of the Argo UI used to link to the workflow logs.
+func NewTeamsNotifier(url, logsURL string, allowedHosts []string) Notifier {
+	return &chatNotifier{
+		url:          url,
+		annotation:   TeamsWebhookAnnotation,
+		allowedHosts: allowedHosts,
+		logsURL:      logsURL,
+		format:       teamsMessage,
+		retries:      defaultRetries,
+		backoff:      defaultBackoff,
+		clock:        clock.RealClock{},
+		client:       &http.Client{Timeout: defaultTimeout},
+	}
+}
+
+func (c *chatNotifier) Notify(ctx context.Context, ev Event) error {
+	// Chat channels only care about the outcome
+	if !isOutcome(ev.Phase) {
+		return nil
+	}
+
+	var urls []string
+	if c.url != "" {
+		urls = append(urls, c.url)
+	}
+	var annotationErr error
+	if webhook := strings.TrimSpace(ev.Annotations[c.annotation]); webhook != "" && webhook != c.url && len(c.allowedHosts) > 0 {
+		if err := c.allowed(webhook); err != nil {
+			annotationErr = err
+		} else {
+			urls = append(urls, webhook)
+		}
+	}
+	if len(urls) == 0 {
+		return annotationErr
+	}
+
+	body, err := json.Marshal(c.format(ev, WorkflowLink(c.logsURL, ev)))
+	if err != nil {
+		return err
+	}
+
+	// Every url is notified, a failing webhook does not keep the others from receiving the event
+	var failed []string
+	if annotationErr != nil {
+		failed = append(failed, annotationErr.Error())
+	}
+	for _, webhook := range urls {
+		err := retry(ctx, c.clock, c.retries, c.backoff, func() error {
+			return postJSON(ctx, c.client, webhook, body, nil)
+		})
+		if err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %v", redactURL(webhook), err))
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("chat notification failed %v", failed)
+	}
+	return nil
+}
+
+// allowed returns an error unless the webhook url of an addon annotation is an https url of an allowed host
+func (c *chatNotifier) allowed(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("invalid %s annotation. %v", c.annotation, err)
+	}
+	if u.Scheme != "https" {
+		return fmt.Errorf("%s annotation must be an https url", c.annotation)
+	}
+	for _, host := range c.allowedHosts {
+		if strings.EqualFold(u.Host, host) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%s annotation host %s is not an allowed webhook host", c.annotation, u.Host)
+}
+
+// redactURL returns the scheme and host of a webhook url, the path of incoming webhooks is a secret
+func redactURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "webhook"
+	}
+	return u.Scheme + "://" + u.Host
+}
+
+// WorkflowLink returns the Argo UI link to the event workflow, or an empty string if unknown
+func WorkflowLink(logsURL string, ev Event) string {
+	if logsURL == "" || ev.Workflow == "" {
+		return ""
+	}
+	namespace := ev.WorkflowNamespace
+	if namespace == "" {
+		namespace = ev.Namespace
+	}
+	return fmt.Sprintf("%s/workflows/%s/%s", strings.TrimSuffix(logsURL, "/"), namespace, ev.Workflow)
+}
+
+func isOutcome(phase addonmgrv1alpha1.ApplicationAssemblyPhase) bool {
+	return phase == addonmgrv1alpha1.Succeeded || phase == addonmgrv1alpha1.Failed || phase == addonmgrv1alpha1.DeleteFailed
+}
+
+func summary(ev Event) string {
+	msg := fmt.Sprintf("Addon %s/%s (%s:%s) %s", ev.Namespace, ev.Addon, ev.Package, ev.Version, ev.Phase)
+	if ev.Duration > 0 {
+		msg = fmt.Sprintf("%s after %.0fs", msg, ev.Duration)
+	}
+	return msg
+}
+
+func slackMessage(ev Event, link string) interface{} {
+	icon := ":white_check_mark:"
+	if ev.Phase != addonmgrv1alpha1.Succeeded {
+		icon = ":x:"
+	}
+
+	text := fmt.Sprintf("%s %s", icon, summary(ev))
+	if ev.Reason != "" {
+		text = fmt.Sprintf("%s\n>%s", text, ev.Reason)
+	}
+	if link != "" {
+		text = fmt.Sprintf("%s\n<%s|Workflow logs>", text, link)
+	}
+
+	return map[string]interface{}{"text": text}
+}
+
+func teamsMessage(ev Event, link string) interface{} {
+	color := "2EB886"
+	if ev.Phase != addonmgrv1alpha1.Succeeded {
+		color = "D00000"
+	}
+
+	card := map[string]interface{}{
+		"@type":      "MessageCard",
+		"@context":   "http://schema.org/extensions",
+		"summary":    summary(ev),
+		"title":      summary(ev),
+		"themeColor": color,
+		"text":       ev.Reason,
+	}
+	if link != "" {
+		card["potentialAction"] = []interface{}{
+			map[string]interface{}{
+				"@type":   "OpenUri",
+				"name":    "Workflow logs",
+				"targets": []interface{}{map[string]interface{}{"os": "default", "uri": link}},
+			},
+		}
+	}
+
+	return card
+}
@@ -0,0 +1,181 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/util/clock"
+
+	addonmgrv1alpha1 "github.com/keikoproj/addon-manager/api/v1alpha1"
+)
+
+func chatHandler(g *GomegaWithT, received *[]map[string]interface{}) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		var msg map[string]interface{}
+		g.Expect(json.Unmarshal(body, &msg)).To(Succeed())
+		*received = append(*received, msg)
+	})
+}
+
+func newChatServer(g *GomegaWithT, received *[]map[string]interface{}) *httptest.Server {
+	return httptest.NewServer(chatHandler(g, received))
+}
+
+// newAnnotationServer returns an https server for webhook urls of addon annotations
+func newAnnotationServer(g *GomegaWithT, received *[]map[string]interface{}) *httptest.Server {
+	return httptest.NewTLSServer(chatHandler(g, received))
+}
+
+func allowedHosts(srv *httptest.Server) []string {
+	u, _ := url.Parse(srv.URL)
+	return []string{u.Host}
+}
+
+func TestSlackNotifier_Notify(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	var global, perAddon []map[string]interface{}
+	globalSrv := newChatServer(g, &global)
+	defer globalSrv.Close()
+	addonSrv := newAnnotationServer(g, &perAddon)
+	defer addonSrv.Close()
+
+	ev := newTestEvent()
+	ev.Workflow = "notify-test-install-abc-wf"
+	ev.Annotations = map[string]string{SlackWebhookAnnotation: addonSrv.URL}
+
+	n := NewSlackNotifier(globalSrv.URL, "https://argo.example.com/", allowedHosts(addonSrv))
+	n.(*chatNotifier).client = addonSrv.Client()
+	g.Expect(n.Notify(context.TODO(), ev)).To(Succeed())
+	g.Expect(global).To(HaveLen(1))
+	g.Expect(perAddon).To(HaveLen(1))
+	g.Expect(global[0]["text"]).To(ContainSubstring(":x: Addon default/notify-test"))
+	g.Expect(global[0]["text"]).To(ContainSubstring("<https://argo.example.com/workflows/default/notify-test-install-abc-wf|Workflow logs>"))
+
+	// Pending transitions are not sent to chat
+	ev.Phase = addonmgrv1alpha1.Pending
+	g.Expect(n.Notify(context.TODO(), ev)).To(Succeed())
+	g.Expect(global).To(HaveLen(1))
+}
+
+func TestChatNotifier_AnnotationHosts(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	var global, perAddon []map[string]interface{}
+	globalSrv := newChatServer(g, &global)
+	defer globalSrv.Close()
+	addonSrv := newAnnotationServer(g, &perAddon)
+	defer addonSrv.Close()
+
+	ev := newTestEvent()
+	ev.Annotations = map[string]string{SlackWebhookAnnotation: addonSrv.URL}
+
+	// Annotations are ignored without allowed hosts
+	n := NewSlackNotifier(globalSrv.URL, "", nil)
+	n.(*chatNotifier).client = addonSrv.Client()
+	g.Expect(n.Notify(context.TODO(), ev)).To(Succeed())
+	g.Expect(global).To(HaveLen(1))
+	g.Expect(perAddon).To(BeEmpty())
+
+	// Hosts that are not allowed are refused, the global webhook is still notified
+	n = NewSlackNotifier(globalSrv.URL, "", []string{"hooks.slack.com"})
+	n.(*chatNotifier).client = addonSrv.Client()
+	g.Expect(n.Notify(context.TODO(), ev)).To(MatchError(ContainSubstring("is not an allowed webhook host")))
+	g.Expect(global).To(HaveLen(2))
+	g.Expect(perAddon).To(BeEmpty())
+
+	// Plain http urls are refused even for allowed hosts
+	plainSrv := newChatServer(g, &perAddon)
+	defer plainSrv.Close()
+	ev.Annotations = map[string]string{SlackWebhookAnnotation: plainSrv.URL}
+	g.Expect(NewSlackNotifier("", "", allowedHosts(plainSrv)).Notify(context.TODO(), ev)).To(MatchError(ContainSubstring("must be an https url")))
+	g.Expect(perAddon).To(BeEmpty())
+}
+
+func TestChatNotifier_NotifyAll(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	failingSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer failingSrv.Close()
+	var perAddon []map[string]interface{}
+	addonSrv := newAnnotationServer(g, &perAddon)
+	defer addonSrv.Close()
+
+	ev := newTestEvent()
+	ev.Annotations = map[string]string{SlackWebhookAnnotation: addonSrv.URL}
+
+	fakeClock := clock.NewFakeClock(time.Now())
+	n := NewSlackNotifier(failingSrv.URL, "", allowedHosts(addonSrv)).(*chatNotifier)
+	n.client = addonSrv.Client()
+	n.clock = fakeClock
+	done := make(chan error, 1)
+	go func() { done <- n.Notify(context.TODO(), ev) }()
+	for _, backoff := range []time.Duration{defaultBackoff, 2 * defaultBackoff} {
+		g.Eventually(fakeClock.HasWaiters).Should(BeTrue())
+		fakeClock.Step(backoff)
+	}
+
+	// The failing global webhook is reported, the addon webhook is still notified
+	err := <-done
+	g.Expect(err).To(MatchError(ContainSubstring(failingSrv.URL)))
+	g.Expect(err.Error()).ToNot(ContainSubstring(addonSrv.URL))
+	g.Expect(perAddon).To(HaveLen(1))
+}
+
+func TestTeamsNotifier_Notify(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	var received []map[string]interface{}
+	srv := newAnnotationServer(g, &received)
+	defer srv.Close()
+
+	ev := newTestEvent()
+	ev.Phase = addonmgrv1alpha1.Succeeded
+	ev.Workflow = "notify-test-install-abc-wf"
+
+	// No global url and no annotation, nothing is sent
+	g.Expect(NewTeamsNotifier("", "https://argo", allowedHosts(srv)).Notify(context.TODO(), ev)).To(Succeed())
+	g.Expect(received).To(BeEmpty())
+
+	ev.Annotations = map[string]string{TeamsWebhookAnnotation: srv.URL}
+	n := NewTeamsNotifier("", "https://argo", allowedHosts(srv))
+	n.(*chatNotifier).client = srv.Client()
+	g.Expect(n.Notify(context.TODO(), ev)).To(Succeed())
+	g.Expect(received).To(HaveLen(1))
+	g.Expect(received[0]["@type"]).To(Equal("MessageCard"))
+	g.Expect(received[0]["themeColor"]).To(Equal("2EB886"))
+	g.Expect(received[0]["potentialAction"]).To(HaveLen(1))
+}
+
+func TestWorkflowLink(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	g.Expect(WorkflowLink("", Event{Workflow: "wf"})).To(BeEmpty())
+	g.Expect(WorkflowLink("https://argo", Event{})).To(BeEmpty())
+	g.Expect(WorkflowLink("https://argo", Event{Namespace: "ns", Workflow: "wf"})).To(Equal("https://argo/workflows/ns/wf"))
+	g.Expect(WorkflowLink("https://argo", Event{Namespace: "ns", WorkflowNamespace: "addon-workflows", Workflow: "wf"})).To(Equal("https://argo/workflows/addon-workflows/wf"))
+}
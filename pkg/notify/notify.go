@@ -0,0 +1,89 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package notify
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	addonmgrv1alpha1 "github.com/keikoproj/addon-manager/api/v1alpha1"
+)
+
+// Event describes an addon lifecycle phase transition
+type Event struct {
+	Addon         string                                    `json:"addon"`
+	Namespace     string                                    `json:"namespace"`
+	Package       string                                    `json:"package"`
+	Version       string                                    `json:"version"`
+	Phase         addonmgrv1alpha1.ApplicationAssemblyPhase `json:"phase"`
+	PreviousPhase addonmgrv1alpha1.ApplicationAssemblyPhase `json:"previousPhase,omitempty"`
+	Duration      float64                                   `json:"durationSeconds,omitempty"`
+	Reason        string                                    `json:"reason,omitempty"`
+	Workflow      string                                    `json:"workflow,omitempty"`
+	// WorkflowNamespace is the namespace the workflow ran in when it differs from the addon namespace
+	WorkflowNamespace string    `json:"workflowNamespace,omitempty"`
+	Time              time.Time `json:"time"`
+	// Annotations of the addon, used for per-addon notification settings
+	Annotations map[string]string `json:"-"`
+}
+
+// NewEvent returns an Event for the addon transition from the previous phase, start is a timestamp in milliseconds
+// and workflow is the name of the lifecycle workflow that ran last, empty when no workflow ran. The event is
+// timestamped with now.
+func NewEvent(addon *addonmgrv1alpha1.Addon, previous addonmgrv1alpha1.ApplicationAssemblyPhase, start int64, workflow string, now time.Time) Event {
+	ev := Event{
+		Addon:         addon.GetName(),
+		Namespace:     addon.GetNamespace(),
+		Package:       addon.Spec.PkgName,
+		Version:       addon.Spec.PkgVersion,
+		Phase:         addon.Status.Lifecycle.Installed,
+		PreviousPhase: previous,
+		Reason:        addon.Status.Reason,
+		Workflow:      workflow,
+		Time:          now,
+		Annotations:   addon.GetAnnotations(),
+	}
+	if start > 0 {
+		ev.Duration = now.Sub(time.Unix(0, start*int64(time.Millisecond))).Seconds()
+	}
+	return ev
+}
+
+// Notifier is implemented by integrations that are informed of addon lifecycle events
+type Notifier interface {
+	Notify(context.Context, Event) error
+}
+
+type multiNotifier []Notifier
+
+// NewMultiNotifier returns a Notifier that fans out events to all notifiers
+func NewMultiNotifier(notifiers ...Notifier) Notifier {
+	return multiNotifier(notifiers)
+}
+
+func (m multiNotifier) Notify(ctx context.Context, ev Event) error {
+	var errs []string
+	for _, n := range m {
+		if err := n.Notify(ctx, ev); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("failed to notify: %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
@@ -0,0 +1,160 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/clock"
+)
+
+const (
+	// SignatureHeader carries the hex encoded HMAC-SHA256 of the timestamp and the request body
+	SignatureHeader = "X-Addonmgr-Signature"
+	// TimestampHeader carries the unix time in seconds the request was signed at
+	TimestampHeader = "X-Addonmgr-Timestamp"
+
+	defaultRetries = 3
+	defaultBackoff = time.Second
+	defaultTimeout = 10 * time.Second
+)
+
+type webhookNotifier struct {
+	urls    []string
+	secret  []byte
+	retries int
+	backoff time.Duration
+	clock   clock.Clock
+	client  *http.Client
+}
+
+// NewWebhookNotifier returns a Notifier that POSTs events as JSON to each url, signing the body when secret is set.
+// Receivers verify signed requests with Verify and reject old timestamps so captured deliveries can not be replayed.
+func NewWebhookNotifier(urls []string, secret string) Notifier {
+	return &webhookNotifier{
+		urls:    urls,
+		secret:  []byte(secret),
+		retries: defaultRetries,
+		backoff: defaultBackoff,
+		clock:   clock.RealClock{},
+		client:  &http.Client{Timeout: defaultTimeout},
+	}
+}
+
+func (w *webhookNotifier) Notify(ctx context.Context, ev Event) error {
+	body, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+
+	var failed []string
+	for _, url := range w.urls {
+		if err := w.post(ctx, url, body); err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %v", url, err))
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("webhook delivery failed %v", failed)
+	}
+	return nil
+}
+
+// Sign returns the hex encoded HMAC-SHA256 signature of the timestamp header value, a dot and body
+func Sign(secret []byte, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify returns an error unless signature is the signature of timestamp and body and timestamp is within
+// tolerance of now. Receivers should also discard deliveries they already processed within tolerance.
+func Verify(secret []byte, timestamp string, body []byte, signature string, now time.Time, tolerance time.Duration) error {
+	if !hmac.Equal([]byte(signature), []byte(Sign(secret, timestamp, body))) {
+		return fmt.Errorf("invalid signature")
+	}
+	sec, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid timestamp %q", timestamp)
+	}
+	if age := now.Sub(time.Unix(sec, 0)); age > tolerance || age < -tolerance {
+		return fmt.Errorf("timestamp %s is outside of the tolerance of %s", timestamp, tolerance)
+	}
+	return nil
+}
+
+func (w *webhookNotifier) post(ctx context.Context, url string, body []byte) error {
+	return retry(ctx, w.clock, w.retries, w.backoff, func() error {
+		// Every attempt is signed with its own timestamp
+		var headers = map[string]string{}
+		if len(w.secret) > 0 {
+			timestamp := strconv.FormatInt(w.clock.Now().Unix(), 10)
+			headers[TimestampHeader] = timestamp
+			headers[SignatureHeader] = Sign(w.secret, timestamp, body)
+		}
+		return postJSON(ctx, w.client, url, body, headers)
+	})
+}
+
+// postJSON sends a single JSON POST request and expects a 2xx response
+func postJSON(ctx context.Context, client *http.Client, url string, body []byte, headers map[string]string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected response status %s", resp.Status)
+	}
+	return nil
+}
+
+// retry runs fn up to attempts times with exponential backoff measured by c
+func retry(ctx context.Context, c clock.Clock, attempts int, backoff time.Duration, fn func() error) error {
+	var err error
+	for i := 0; i < attempts; i++ {
+		if err = fn(); err == nil {
+			return nil
+		}
+		if i == attempts-1 {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-c.After(backoff << uint(i)):
+		}
+	}
+	return err
+}
@@ -0,0 +1,137 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/clock"
+
+	addonmgrv1alpha1 "github.com/keikoproj/addon-manager/api/v1alpha1"
+)
+
+func newTestEvent() Event {
+	a := &addonmgrv1alpha1.Addon{
+		ObjectMeta: metav1.ObjectMeta{Name: "notify-test", Namespace: "default"},
+		Spec: addonmgrv1alpha1.AddonSpec{
+			PackageSpec: addonmgrv1alpha1.PackageSpec{PkgName: "notify-test", PkgVersion: "1.0.0"},
+		},
+		Status: addonmgrv1alpha1.AddonStatus{
+			Lifecycle: addonmgrv1alpha1.AddonStatusLifecycle{Installed: addonmgrv1alpha1.Failed},
+			Reason:    "install failed",
+		},
+	}
+	now := time.Date(2020, 10, 1, 10, 0, 0, 0, time.UTC)
+	return NewEvent(a, addonmgrv1alpha1.Pending, now.Add(-time.Minute).UnixNano()/int64(time.Millisecond), "notify-test-delete-abc-wf", now)
+}
+
+func TestNewEvent(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	ev := newTestEvent()
+	g.Expect(ev.Addon).To(Equal("notify-test"))
+	g.Expect(ev.Phase).To(Equal(addonmgrv1alpha1.Failed))
+	g.Expect(ev.PreviousPhase).To(Equal(addonmgrv1alpha1.Pending))
+	g.Expect(ev.Reason).To(Equal("install failed"))
+	g.Expect(ev.Workflow).To(Equal("notify-test-delete-abc-wf"))
+	g.Expect(ev.Duration).To(BeNumerically("==", 60))
+	g.Expect(ev.Time).To(Equal(time.Date(2020, 10, 1, 10, 0, 0, 0, time.UTC)))
+}
+
+func TestWebhookNotifier_Notify(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	var received Event
+	var signature string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		signature = r.Header.Get(SignatureHeader)
+		timestamp := r.Header.Get(TimestampHeader)
+		g.Expect(signature).To(Equal(Sign([]byte("secret"), timestamp, body)))
+		g.Expect(Verify([]byte("secret"), timestamp, body, signature, time.Now(), time.Minute)).To(Succeed())
+
+		// Replays after the tolerance and changed timestamps are refused
+		g.Expect(Verify([]byte("secret"), timestamp, body, signature, time.Now().Add(time.Hour), time.Minute)).To(MatchError(ContainSubstring("outside of the tolerance")))
+		g.Expect(Verify([]byte("secret"), "1", body, signature, time.Now(), time.Minute)).To(MatchError("invalid signature"))
+		g.Expect(json.Unmarshal(body, &received)).To(Succeed())
+	}))
+	defer srv.Close()
+
+	n := NewWebhookNotifier([]string{srv.URL}, "secret")
+	g.Expect(n.Notify(context.TODO(), newTestEvent())).To(Succeed())
+	g.Expect(received.Addon).To(Equal("notify-test"))
+	g.Expect(signature).To(HavePrefix("sha256="))
+}
+
+func TestWebhookNotifier_Retry(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer srv.Close()
+
+	fakeClock := clock.NewFakeClock(time.Now())
+	n := NewWebhookNotifier([]string{srv.URL}, "").(*webhookNotifier)
+	n.clock = fakeClock
+	notify := func() error {
+		done := make(chan error, 1)
+		go func() { done <- n.Notify(context.TODO(), newTestEvent()) }()
+		// Retries back off exponentially from the default backoff
+		for _, backoff := range []time.Duration{defaultBackoff, 2 * defaultBackoff} {
+			g.Eventually(fakeClock.HasWaiters).Should(BeTrue())
+			fakeClock.Step(backoff)
+		}
+		return <-done
+	}
+
+	g.Expect(notify()).To(Succeed())
+	g.Expect(atomic.LoadInt32(&calls)).To(Equal(int32(3)))
+
+	atomic.StoreInt32(&calls, -10)
+	g.Expect(notify()).To(HaveOccurred())
+}
+
+type countingNotifier struct {
+	count int
+	err   error
+}
+
+func (c *countingNotifier) Notify(context.Context, Event) error {
+	c.count++
+	return c.err
+}
+
+func TestMultiNotifier(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	a, b := &countingNotifier{}, &countingNotifier{err: context.Canceled}
+	err := NewMultiNotifier(a, b).Notify(context.TODO(), newTestEvent())
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(a.count).To(Equal(1))
+	g.Expect(b.count).To(Equal(1))
+}
@@ -0,0 +1,223 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package plan
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"sigs.k8s.io/yaml"
+
+	addonmgrv1alpha1 "github.com/keikoproj/addon-manager/api/v1alpha1"
+	"github.com/keikoproj/addon-manager/pkg/audit"
+	"github.com/keikoproj/addon-manager/pkg/catalog"
+	"github.com/keikoproj/addon-manager/pkg/deps"
+)
+
+// Action is what happens to an addon when a plan is applied
+type Action string
+
+const (
+	// Create installs a new addon
+	Create Action = "create"
+	// Update reinstalls the addon with the new spec
+	Update Action = "update"
+	// Wait is a dependency that is installing, the addon waits for it
+	Wait Action = "wait"
+	// NoChange is an addon whose spec is unchanged
+	NoChange Action = "none"
+)
+
+// Workflow is a lifecycle workflow a step submits
+type Workflow struct {
+	Step addonmgrv1alpha1.LifecycleStep `json:"step"`
+	Name string                         `json:"name"`
+}
+
+// Step is an addon of the plan with the workflows that run for it
+type Step struct {
+	// Addon is the namespace/name of the addon, empty for dependencies created from the catalog
+	Addon     string     `json:"addon,omitempty"`
+	Package   string     `json:"package"`
+	Action    Action     `json:"action"`
+	Workflows []Workflow `json:"workflows,omitempty"`
+	Reason    string     `json:"reason,omitempty"`
+}
+
+// Plan previews a spec change, steps are in the order they run: dependencies before the addon
+type Plan struct {
+	Steps []Step `json:"steps"`
+	// Changed are the changed spec fields
+	Changed []string `json:"changed,omitempty"`
+	// Diff is a line diff of the current and the desired spec
+	Diff string `json:"diff,omitempty"`
+	// Broken are the dependent addons whose constraints exclude the new version
+	Broken []string `json:"broken,omitempty"`
+}
+
+// New returns the plan of changing the addon from current to desired, current is nil for new addons. Addons with a
+// catalog are rendered from snapshot first, installed are the addons of the cluster used to order dependencies.
+func New(current, desired *addonmgrv1alpha1.Addon, installed []addonmgrv1alpha1.Addon, snapshot *catalog.Snapshot) (*Plan, error) {
+	desired = desired.DeepCopy()
+	if desired.Spec.Catalog != "" && snapshot != nil {
+		spec, err := snapshot.Render(desired)
+		if err != nil {
+			return nil, err
+		}
+		desired.Spec = *spec
+	}
+
+	var others []addonmgrv1alpha1.Addon
+	for _, a := range installed {
+		if a.Namespace != desired.Namespace || a.Name != desired.Name {
+			others = append(others, a)
+		}
+	}
+	var cat deps.Catalog
+	if snapshot != nil {
+		cat = snapshot
+	}
+
+	p := &Plan{}
+	step := Step{Addon: desired.Namespace + "/" + desired.Name, Package: desired.Spec.PkgName + ":" + desired.Spec.PkgVersion}
+	switch {
+	case current == nil:
+		step.Action = Create
+	case current.CalculateChecksum() == desired.CalculateChecksum():
+		step.Action = NoChange
+		p.Steps = append(p.Steps, step)
+		return p, nil
+	default:
+		step.Action = Update
+		if err := p.diff(current, desired); err != nil {
+			return nil, err
+		}
+	}
+
+	resolved, err := deps.NewResolver(deps.Installed(others), cat).Resolve(desired.GetPackageSpec())
+	if err != nil {
+		return nil, err
+	}
+	for _, pkg := range resolved.Packages {
+		switch {
+		case !pkg.Installed():
+			reason := "not installed, the addon waits until it is"
+			if desired.Spec.InstallDependencies == addonmgrv1alpha1.InstallDependenciesIfNotPresent {
+				reason = "not installed, created from the catalog"
+			}
+			p.Steps = append(p.Steps, Step{Package: pkg.String(), Action: Create, Reason: reason})
+		case pkg.Phase != addonmgrv1alpha1.Succeeded:
+			p.Steps = append(p.Steps, Step{Addon: pkg.Addon, Package: pkg.String(), Action: Wait, Reason: fmt.Sprintf("install is %s", pkg.Phase)})
+		}
+	}
+
+	step.Workflows = workflows(desired, current != nil)
+	if desired.IsBlueGreen() {
+		step.Reason = "installed in the inactive slot, the active slot is deleted once validated"
+	}
+	p.Steps = append(p.Steps, step)
+
+	if current != nil && current.Spec.PkgVersion != desired.Spec.PkgVersion {
+		pkg := deps.Package{PackageSpec: current.GetPackageSpec(), Addon: current.Namespace + "/" + current.Name}
+		for _, d := range deps.Dependents(pkg, deps.Installed(others)) {
+			constraint, ok := d.PkgDeps[current.Spec.PkgName]
+			if ok && !deps.Matches(constraint, desired.Spec.PkgVersion) {
+				p.Broken = append(p.Broken, fmt.Sprintf("%s requires %s:%s", d.Addon, current.Spec.PkgName, constraint))
+			}
+		}
+	}
+
+	return p, nil
+}
+
+// workflows returns the lifecycle workflows the controller submits for the addon in order, updates of installed addons
+// run the upgrade workflow in place of the install workflow when the addon has one
+func workflows(a *addonmgrv1alpha1.Addon, update bool) []Workflow {
+	install := addonmgrv1alpha1.Install
+	if update && a.Spec.Lifecycle.Upgrade.Template != "" && !a.IsBlueGreen() {
+		install = addonmgrv1alpha1.Upgrade
+	}
+	steps := []addonmgrv1alpha1.LifecycleStep{addonmgrv1alpha1.Prereqs, install}
+	if a.IsBlueGreen() {
+		steps = append(steps, addonmgrv1alpha1.Validate)
+	}
+
+	var wfs []Workflow
+	for _, s := range steps {
+		if wt, err := a.GetWorkflowType(s); err != nil || wt.Template == "" || wt.Skip {
+			continue
+		}
+		wfs = append(wfs, Workflow{Step: s, Name: a.GetFormattedWorkflowName(s)})
+	}
+	return wfs
+}
+
+// diff sets the changed fields and the line diff of the specs
+func (p *Plan) diff(current, desired *addonmgrv1alpha1.Addon) error {
+	prev, err := json.Marshal(current.Spec)
+	if err != nil {
+		return err
+	}
+	curr, err := json.Marshal(desired.Spec)
+	if err != nil {
+		return err
+	}
+	if p.Changed, err = audit.ChangedFields(prev, curr); err != nil {
+		return err
+	}
+
+	prevYAML, err := yaml.JSONToYAML(prev)
+	if err != nil {
+		return err
+	}
+	currYAML, err := yaml.JSONToYAML(curr)
+	if err != nil {
+		return err
+	}
+	p.Diff = Diff(string(prevYAML), string(currYAML))
+	return nil
+}
+
+// Write prints the plan as text
+func (p *Plan) Write(w io.Writer) error {
+	var b strings.Builder
+	for i, s := range p.Steps {
+		name := s.Addon
+		if name == "" {
+			name = s.Package
+		}
+		fmt.Fprintf(&b, "%d. %s %s (%s)", i+1, s.Action, name, s.Package)
+		if s.Reason != "" {
+			fmt.Fprintf(&b, ": %s", s.Reason)
+		}
+		b.WriteString("\n")
+		for _, wf := range s.Workflows {
+			fmt.Fprintf(&b, "   - %s workflow %s\n", wf.Step, wf.Name)
+		}
+	}
+	for _, broken := range p.Broken {
+		fmt.Fprintf(&b, "WARNING: %s\n", broken)
+	}
+	if len(p.Changed) > 0 {
+		fmt.Fprintf(&b, "\nChanged: %s\n", strings.Join(p.Changed, ", "))
+	}
+	if p.Diff != "" {
+		fmt.Fprintf(&b, "\n%s", p.Diff)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
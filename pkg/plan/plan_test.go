@@ -0,0 +1,171 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package plan
+
+import (
+	"bytes"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	addonmgrv1alpha1 "github.com/keikoproj/addon-manager/api/v1alpha1"
+	"github.com/keikoproj/addon-manager/pkg/catalog"
+)
+
+const planIndex = `
+packages:
+- name: core/crds
+  versions:
+  - version: 1.0.0
+    template:
+      pkgType: composite
+      lifecycle:
+        install:
+          template: install-crds
+- name: core/cert-manager
+  versions:
+  - version: 1.0.4
+    template:
+      pkgType: composite
+      lifecycle:
+        install:
+          template: install-1.0.4
+  - version: 1.1.0
+    template:
+      pkgType: composite
+      pkgDeps:
+        core/crds: "*"
+      lifecycle:
+        prereqs:
+          template: prereqs-1.1.0
+        install:
+          template: install-1.1.0
+`
+
+func newPlanAddon(name, pkgName, pkgVersion string, phase addonmgrv1alpha1.ApplicationAssemblyPhase) addonmgrv1alpha1.Addon {
+	a := addonmgrv1alpha1.Addon{ObjectMeta: metav1.ObjectMeta{Namespace: "addon-manager-system", Name: name}}
+	a.Spec.PkgName = pkgName
+	a.Spec.PkgVersion = pkgVersion
+	a.Spec.PkgType = addonmgrv1alpha1.CompositePkg
+	a.Status.Lifecycle.Installed = phase
+	return a
+}
+
+func TestNew_CatalogUpgrade(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	snapshot, err := catalog.ParseIndex([]byte(planIndex))
+	g.Expect(err).ToNot(HaveOccurred())
+
+	current := newPlanAddon("cert-manager", "core/cert-manager", "1.0.4", addonmgrv1alpha1.Succeeded)
+	current.Spec.Catalog = "platform"
+	current.Spec.Lifecycle.Install.Template = "install-1.0.4"
+
+	dependent := newPlanAddon("issuer", "core/issuer", "1.0.0", addonmgrv1alpha1.Succeeded)
+	dependent.Spec.PkgDeps = map[string]string{"core/cert-manager": "~1.0.0"}
+
+	desired := current.DeepCopy()
+	desired.Spec.PkgVersion = "1.1.0"
+
+	p, err := New(&current, desired, []addonmgrv1alpha1.Addon{current, dependent}, snapshot)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(p.Steps).To(HaveLen(2))
+
+	g.Expect(p.Steps[0].Action).To(Equal(Create))
+	g.Expect(p.Steps[0].Package).To(Equal("core/crds:1.0.0"))
+
+	g.Expect(p.Steps[1].Action).To(Equal(Update))
+	g.Expect(p.Steps[1].Addon).To(Equal("addon-manager-system/cert-manager"))
+	g.Expect(p.Steps[1].Workflows).To(HaveLen(2))
+	g.Expect(p.Steps[1].Workflows[0].Step).To(Equal(addonmgrv1alpha1.Prereqs))
+	g.Expect(p.Steps[1].Workflows[1].Name).To(HavePrefix("cert-manager-install-"))
+
+	g.Expect(p.Changed).To(ContainElement("pkgVersion"))
+	g.Expect(p.Diff).To(ContainSubstring("- pkgVersion: 1.0.4\n+ pkgVersion: 1.1.0\n"))
+	g.Expect(p.Broken).To(Equal([]string{"addon-manager-system/issuer requires core/cert-manager:~1.0.0"}))
+
+	var buf bytes.Buffer
+	g.Expect(p.Write(&buf)).To(Succeed())
+	g.Expect(buf.String()).To(ContainSubstring("2. update addon-manager-system/cert-manager (core/cert-manager:1.1.0)"))
+}
+
+func TestNew(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	dep := newPlanAddon("crds", "core/crds", "1.0.0", addonmgrv1alpha1.Pending)
+	desired := newPlanAddon("cert-manager", "core/cert-manager", "1.1.0", "")
+	desired.Spec.PkgDeps = map[string]string{"core/crds": "*"}
+	desired.Spec.Lifecycle.Install.Template = "install"
+
+	p, err := New(nil, &desired, []addonmgrv1alpha1.Addon{dep}, nil)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(p.Steps).To(HaveLen(2))
+	g.Expect(p.Steps[0].Action).To(Equal(Wait))
+	g.Expect(p.Steps[0].Addon).To(Equal("addon-manager-system/crds"))
+	g.Expect(p.Steps[1].Action).To(Equal(Create))
+	g.Expect(p.Diff).To(BeEmpty())
+
+	p, err = New(&desired, &desired, nil, nil)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(p.Steps).To(Equal([]Step{{Addon: "addon-manager-system/cert-manager", Package: "core/cert-manager:1.1.0", Action: NoChange}}))
+
+	_, err = New(nil, &desired, nil, nil)
+	g.Expect(err).To(HaveOccurred())
+}
+
+func TestNew_Upgrade(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	current := newPlanAddon("cert-manager", "core/cert-manager", "1.0.4", addonmgrv1alpha1.Succeeded)
+	current.Spec.Lifecycle.Install.Template = "install"
+	current.Spec.Lifecycle.Upgrade.Template = "upgrade"
+
+	desired := current.DeepCopy()
+	desired.Spec.PkgVersion = "1.1.0"
+
+	p, err := New(nil, desired, nil, nil)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(p.Steps[0].Workflows).To(HaveLen(1))
+	g.Expect(p.Steps[0].Workflows[0].Step).To(Equal(addonmgrv1alpha1.Install))
+
+	p, err = New(&current, desired, nil, nil)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(p.Steps[0].Workflows).To(HaveLen(1))
+	g.Expect(p.Steps[0].Workflows[0].Step).To(Equal(addonmgrv1alpha1.Upgrade))
+	g.Expect(p.Steps[0].Workflows[0].Name).To(HavePrefix("cert-manager-upgrade-"))
+}
+
+func TestNew_SkippedSteps(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	desired := newPlanAddon("cert-manager", "core/cert-manager", "1.1.0", "")
+	desired.Spec.Lifecycle.Prereqs = addonmgrv1alpha1.WorkflowType{Template: "prereqs", Skip: true}
+	desired.Spec.Lifecycle.Install.Template = "install"
+
+	p, err := New(nil, &desired, nil, nil)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(p.Steps[0].Workflows).To(HaveLen(1))
+	g.Expect(p.Steps[0].Workflows[0].Step).To(Equal(addonmgrv1alpha1.Install))
+	g.Expect(desired.SkippedSteps()).To(Equal([]addonmgrv1alpha1.LifecycleStep{addonmgrv1alpha1.Prereqs}))
+}
+
+func TestDiff(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	g.Expect(Diff("a\nb\n", "a\nb\n")).To(BeEmpty())
+	g.Expect(Diff("a\nb\nc\n", "a\nx\nc\n")).To(Equal("  a\n- b\n+ x\n  c\n"))
+	g.Expect(Diff("1\n2\n3\n4\n5\n6\n7\n8\n", "1\n2\n3\n4\n5\n6\n7\n9\n")).To(Equal("...\n  5\n  6\n  7\n- 8\n+ 9\n"))
+}
@@ -0,0 +1,195 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package profile
+
+import (
+	"context"
+	"fmt"
+	"path"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	addonmgrv1alpha1 "github.com/keikoproj/addon-manager/api/v1alpha1"
+)
+
+const (
+	// ProfileLabel is set on addons to the name of the AddonProfile they were generated from
+	ProfileLabel = "addonmgr.keikoproj.io/profile"
+	// ChecksumAnnotation is the checksum of the spec generated from the profile, the addon controller completes the
+	// spec from the catalog so addons are only updated when the generated spec changes
+	ChecksumAnnotation = "addonmgr.keikoproj.io/profile-checksum"
+)
+
+// Syncer creates, updates and deletes the addons of profiles
+type Syncer struct {
+	client client.Client
+	scheme *runtime.Scheme
+}
+
+// NewSyncer returns a syncer of profile addons
+func NewSyncer(client client.Client, scheme *runtime.Scheme) *Syncer {
+	return &Syncer{client: client, scheme: scheme}
+}
+
+// AddonName returns the name of the addon of a profile entry
+func AddonName(a addonmgrv1alpha1.ProfileAddon) string {
+	if a.Name != "" {
+		return a.Name
+	}
+	return path.Base(a.PkgName)
+}
+
+// Expand returns the addons of the profile, owned by it
+func Expand(p *addonmgrv1alpha1.AddonProfile, scheme *runtime.Scheme) ([]*addonmgrv1alpha1.Addon, error) {
+	addons := make([]*addonmgrv1alpha1.Addon, 0, len(p.Spec.Addons))
+	names := map[string]bool{}
+	for _, entry := range p.Spec.Addons {
+		name := AddonName(entry)
+		if names[name] {
+			return nil, fmt.Errorf("profile has more than one addon named %s", name)
+		}
+		names[name] = true
+
+		a := &addonmgrv1alpha1.Addon{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: p.Namespace,
+				Labels:    map[string]string{ProfileLabel: p.Name},
+			},
+			Spec: addonmgrv1alpha1.AddonSpec{
+				PackageSpec: addonmgrv1alpha1.PackageSpec{
+					PkgName:    entry.PkgName,
+					PkgVersion: entry.PkgVersion,
+					PkgChannel: entry.PkgChannel,
+				},
+				Catalog: p.Spec.Catalog,
+				Params:  overlay(p.Spec.Params, entry.Params),
+			},
+		}
+		a.Annotations = map[string]string{ChecksumAnnotation: a.CalculateChecksum()}
+		if err := controllerutil.SetControllerReference(p, a, scheme); err != nil {
+			return nil, err
+		}
+		addons = append(addons, a)
+	}
+	return addons, nil
+}
+
+// overlay returns the profile params with the params of an addon on top, data parameters are merged
+func overlay(defaults, params addonmgrv1alpha1.AddonParams) addonmgrv1alpha1.AddonParams {
+	merged := *defaults.DeepCopy()
+	if params.Namespace != "" {
+		merged.Namespace = params.Namespace
+	}
+	merged.Context = merged.Context.Merge(params.Context)
+	merged.Data = merge(merged.Data, params.Data)
+	if params.Sops != nil {
+		merged.Sops = params.Sops.DeepCopy()
+	}
+	return merged
+}
+
+func merge(params, override map[string]addonmgrv1alpha1.FlexString) map[string]addonmgrv1alpha1.FlexString {
+	if len(override) == 0 {
+		return params
+	}
+	if params == nil {
+		params = make(map[string]addonmgrv1alpha1.FlexString, len(override))
+	}
+	for k, v := range override {
+		params[k] = v
+	}
+	return params
+}
+
+// Sync applies the addons of the profile and deletes the addons removed from it, it returns the status of the
+// profile addons in spec order
+func (s *Syncer) Sync(ctx context.Context, p *addonmgrv1alpha1.AddonProfile) ([]addonmgrv1alpha1.ProfileAddonStatus, error) {
+	desired, err := Expand(p, s.scheme)
+	if err != nil {
+		return nil, err
+	}
+
+	var list addonmgrv1alpha1.AddonList
+	if err := s.client.List(ctx, &list, client.InNamespace(p.Namespace), client.MatchingLabels{ProfileLabel: p.Name}); err != nil {
+		return nil, err
+	}
+	existing := make(map[string]*addonmgrv1alpha1.Addon, len(list.Items))
+	for i := range list.Items {
+		if metav1.IsControlledBy(&list.Items[i], p) {
+			existing[list.Items[i].Name] = &list.Items[i]
+		}
+	}
+
+	statuses := make([]addonmgrv1alpha1.ProfileAddonStatus, 0, len(desired))
+	for _, a := range desired {
+		current, err := s.apply(ctx, p, a, existing[a.Name])
+		if err != nil {
+			return nil, err
+		}
+		delete(existing, a.Name)
+		statuses = append(statuses, addonmgrv1alpha1.ProfileAddonStatus{
+			Name:       current.Name,
+			PkgVersion: current.Spec.PkgVersion,
+			Installed:  current.Status.Lifecycle.Installed,
+			Reason:     current.Status.Reason,
+		})
+	}
+
+	// Addons removed from the profile run their delete workflows
+	for _, a := range existing {
+		if err := s.client.Delete(ctx, a); client.IgnoreNotFound(err) != nil {
+			return nil, fmt.Errorf("failed to delete addon %s. %v", a.Name, err)
+		}
+	}
+
+	return statuses, nil
+}
+
+// apply creates the addon or updates it when the generated spec changed, it returns the current addon
+func (s *Syncer) apply(ctx context.Context, p *addonmgrv1alpha1.AddonProfile, desired, current *addonmgrv1alpha1.Addon) (*addonmgrv1alpha1.Addon, error) {
+	if current == nil {
+		err := s.client.Create(ctx, desired)
+		if err == nil {
+			return desired, nil
+		}
+		// Addons that exist but are not owned by the profile are not taken over
+		var other addonmgrv1alpha1.Addon
+		if getErr := s.client.Get(ctx, client.ObjectKey{Namespace: desired.Namespace, Name: desired.Name}, &other); getErr == nil && !metav1.IsControlledBy(&other, p) {
+			return nil, fmt.Errorf("addon %s already exists and is not managed by the profile", desired.Name)
+		}
+		return nil, fmt.Errorf("failed to create addon %s. %v", desired.Name, err)
+	}
+
+	if current.Annotations[ChecksumAnnotation] == desired.Annotations[ChecksumAnnotation] {
+		return current, nil
+	}
+	// Unpinned addons keep the version they were installed with
+	if desired.Spec.PkgVersion == "" && desired.Spec.PkgName == current.Spec.PkgName {
+		desired.Spec.PkgVersion = current.Spec.PkgVersion
+	}
+	current.Spec = desired.Spec
+	if current.Annotations == nil {
+		current.Annotations = map[string]string{}
+	}
+	current.Annotations[ChecksumAnnotation] = desired.Annotations[ChecksumAnnotation]
+	if err := s.client.Update(ctx, current); err != nil {
+		return nil, fmt.Errorf("failed to update addon %s. %v", current.Name, err)
+	}
+	return current, nil
+}
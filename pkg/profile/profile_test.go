@@ -0,0 +1,151 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package profile
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	runtimefake "sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	addonmgrv1alpha1 "github.com/keikoproj/addon-manager/api/v1alpha1"
+)
+
+func newProfile() *addonmgrv1alpha1.AddonProfile {
+	return &addonmgrv1alpha1.AddonProfile{
+		ObjectMeta: metav1.ObjectMeta{Name: "baseline", Namespace: "addon-manager-system", UID: "profile-uid"},
+		Spec: addonmgrv1alpha1.AddonProfileSpec{
+			Catalog: "platform",
+			Params: addonmgrv1alpha1.AddonParams{
+				Namespace: "platform",
+				Context:   addonmgrv1alpha1.ClusterContext{ClusterName: "prod"},
+				Data:      map[string]addonmgrv1alpha1.FlexString{"replicas": "2", "tier": "prod"},
+			},
+			Addons: []addonmgrv1alpha1.ProfileAddon{
+				{PkgName: "core/cert-manager", PkgVersion: "1.0.4"},
+				{
+					Name:       "dns",
+					PkgName:    "core/external-dns",
+					PkgChannel: "stable",
+					Params: addonmgrv1alpha1.AddonParams{
+						Namespace: "external-dns",
+						Data:      map[string]addonmgrv1alpha1.FlexString{"replicas": "1"},
+					},
+				},
+			},
+		},
+	}
+}
+
+func TestExpand(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	s := runtime.NewScheme()
+	g.Expect(addonmgrv1alpha1.AddToScheme(s)).To(Succeed())
+
+	p := newProfile()
+	addons, err := Expand(p, s)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(addons).To(HaveLen(2))
+
+	g.Expect(addons[0].Name).To(Equal("cert-manager"))
+	g.Expect(addons[0].Spec.Catalog).To(Equal("platform"))
+	g.Expect(addons[0].Spec.PkgVersion).To(Equal("1.0.4"))
+	g.Expect(addons[0].Spec.Params.Namespace).To(Equal("platform"))
+	g.Expect(addons[0].Labels).To(HaveKeyWithValue(ProfileLabel, "baseline"))
+	g.Expect(metav1.IsControlledBy(addons[0], p)).To(BeTrue())
+
+	g.Expect(addons[1].Name).To(Equal("dns"))
+	g.Expect(addons[1].Spec.PkgChannel).To(Equal("stable"))
+	g.Expect(addons[1].Spec.Params.Namespace).To(Equal("external-dns"))
+	g.Expect(addons[1].Spec.Params.Context.ClusterName).To(Equal("prod"))
+	g.Expect(addons[1].Spec.Params.Data).To(Equal(map[string]addonmgrv1alpha1.FlexString{"replicas": "1", "tier": "prod"}))
+
+	// Overlays must not leak into the profile defaults
+	g.Expect(p.Spec.Params.Data).To(HaveKeyWithValue("replicas", addonmgrv1alpha1.FlexString("2")))
+
+	p.Spec.Addons = append(p.Spec.Addons, addonmgrv1alpha1.ProfileAddon{PkgName: "other/cert-manager"})
+	_, err = Expand(p, s)
+	g.Expect(err).To(HaveOccurred())
+}
+
+func TestSyncer_Sync(t *testing.T) {
+	g := NewGomegaWithT(t)
+	ctx := context.TODO()
+
+	s := runtime.NewScheme()
+	g.Expect(addonmgrv1alpha1.AddToScheme(s)).To(Succeed())
+
+	p := newProfile()
+	c := runtimefake.NewFakeClientWithScheme(s, p)
+	syncer := NewSyncer(c, s)
+
+	statuses, err := syncer.Sync(ctx, p)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(statuses).To(HaveLen(2))
+	g.Expect(statuses[0].Name).To(Equal("cert-manager"))
+
+	// The addon controller resolves the channel version, it is kept while the profile entry is unchanged
+	dns := &addonmgrv1alpha1.Addon{}
+	key := types.NamespacedName{Namespace: p.Namespace, Name: "dns"}
+	g.Expect(c.Get(ctx, key, dns)).To(Succeed())
+	dns.Spec.PkgVersion = "0.7.4"
+	g.Expect(c.Update(ctx, dns)).To(Succeed())
+
+	_, err = syncer.Sync(ctx, p)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(c.Get(ctx, key, dns)).To(Succeed())
+	g.Expect(dns.Spec.PkgVersion).To(Equal("0.7.4"))
+
+	// Changed entries are updated, unpinned versions are preserved
+	p.Spec.Addons[1].Params.Data["replicas"] = "3"
+	_, err = syncer.Sync(ctx, p)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(c.Get(ctx, key, dns)).To(Succeed())
+	g.Expect(dns.Spec.PkgVersion).To(Equal("0.7.4"))
+	g.Expect(dns.Spec.Params.Data).To(HaveKeyWithValue("replicas", addonmgrv1alpha1.FlexString("3")))
+
+	// Removed entries are deleted
+	p.Spec.Addons = p.Spec.Addons[:1]
+	statuses, err = syncer.Sync(ctx, p)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(statuses).To(HaveLen(1))
+
+	var list addonmgrv1alpha1.AddonList
+	g.Expect(c.List(ctx, &list, client.InNamespace(p.Namespace))).To(Succeed())
+	g.Expect(list.Items).To(HaveLen(1))
+	g.Expect(list.Items[0].Name).To(Equal("cert-manager"))
+}
+
+func TestSyncer_SyncUnmanaged(t *testing.T) {
+	g := NewGomegaWithT(t)
+	ctx := context.TODO()
+
+	s := runtime.NewScheme()
+	g.Expect(addonmgrv1alpha1.AddToScheme(s)).To(Succeed())
+
+	p := newProfile()
+	existing := &addonmgrv1alpha1.Addon{ObjectMeta: metav1.ObjectMeta{Name: "cert-manager", Namespace: p.Namespace}}
+	c := runtimefake.NewFakeClientWithScheme(s, p, existing)
+
+	_, err := NewSyncer(c, s).Sync(ctx, p)
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(err.Error()).To(ContainSubstring("not managed by the profile"))
+}
@@ -0,0 +1,81 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package provenance
+
+import (
+	"crypto"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	addonmgrv1alpha1 "github.com/keikoproj/addon-manager/api/v1alpha1"
+	"github.com/keikoproj/addon-manager/pkg/cosign"
+)
+
+// SignatureAnnotation holds the base64 encoded detached signature of the addon package, e.g. the output of
+// cosign sign-blob --key cosign.key over the Payload of the addon
+const SignatureAnnotation = "addonmgr.keikoproj.io/signature"
+
+// pkg is the signed content of an addon package, install parameters are not part of it
+type pkg struct {
+	Package   addonmgrv1alpha1.PackageSpec           `json:"package"`
+	Lifecycle addonmgrv1alpha1.LifecycleWorkflowSpec `json:"lifecycle"`
+	Overrides addonmgrv1alpha1.AddonOverridesSpec    `json:"overrides"`
+}
+
+// Payload returns the canonical JSON of the addon package: package spec, lifecycle templates and overrides
+func Payload(addon *addonmgrv1alpha1.Addon) ([]byte, error) {
+	return json.Marshal(pkg{
+		Package:   addon.Spec.PackageSpec,
+		Lifecycle: addon.Spec.Lifecycle,
+		Overrides: addon.Spec.Overrides,
+	})
+}
+
+// Verifier verifies addon package signatures against a set of trusted public keys
+type Verifier struct {
+	keys []crypto.PublicKey
+}
+
+// NewVerifier returns a Verifier for the PEM encoded ECDSA or Ed25519 public keys
+func NewVerifier(pemKeys [][]byte) (*Verifier, error) {
+	keys, err := cosign.ParsePublicKeys(pemKeys)
+	if err != nil {
+		return nil, err
+	}
+	return &Verifier{keys: keys}, nil
+}
+
+// Verify checks that the addon package is signed by one of the trusted keys
+func (v *Verifier) Verify(addon *addonmgrv1alpha1.Addon) error {
+	encoded, ok := addon.GetAnnotations()[SignatureAnnotation]
+	if !ok {
+		return fmt.Errorf("addon package %s:%s is not signed, missing %s annotation", addon.Spec.PkgName, addon.Spec.PkgVersion, SignatureAnnotation)
+	}
+	sig, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return fmt.Errorf("invalid %s annotation. %v", SignatureAnnotation, err)
+	}
+
+	payload, err := Payload(addon)
+	if err != nil {
+		return err
+	}
+	if !cosign.SignedByKey(v.keys, payload, sig) {
+		return errors.New("addon package signature is not valid for any trusted key")
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package provenance
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	addonmgrv1alpha1 "github.com/keikoproj/addon-manager/api/v1alpha1"
+)
+
+func newSignedAddon(g *GomegaWithT, key *ecdsa.PrivateKey) *addonmgrv1alpha1.Addon {
+	a := &addonmgrv1alpha1.Addon{
+		ObjectMeta: metav1.ObjectMeta{Name: "signed", Namespace: "default"},
+		Spec: addonmgrv1alpha1.AddonSpec{
+			PackageSpec: addonmgrv1alpha1.PackageSpec{PkgName: "signed", PkgVersion: "1.0.0", PkgType: addonmgrv1alpha1.CompositePkg},
+			Params:      addonmgrv1alpha1.AddonParams{Namespace: "signed-ns"},
+		},
+	}
+	a.Spec.Lifecycle.Install.Template = "apiVersion: argoproj.io/v1alpha1\nkind: Workflow\n"
+
+	payload, err := Payload(a)
+	g.Expect(err).ToNot(HaveOccurred())
+	hash := sha256.Sum256(payload)
+	sig, err := ecdsa.SignASN1(rand.Reader, key, hash[:])
+	g.Expect(err).ToNot(HaveOccurred())
+	a.SetAnnotations(map[string]string{SignatureAnnotation: base64.StdEncoding.EncodeToString(sig)})
+	return a
+}
+
+func TestVerifier_Verify(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	g.Expect(err).ToNot(HaveOccurred())
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	g.Expect(err).ToNot(HaveOccurred())
+	v, err := NewVerifier([][]byte{pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})})
+	g.Expect(err).ToNot(HaveOccurred())
+
+	a := newSignedAddon(g, key)
+	g.Expect(v.Verify(a)).To(Succeed())
+
+	// Params are not part of the package
+	a.Spec.Params.Namespace = "other-ns"
+	g.Expect(v.Verify(a)).To(Succeed())
+
+	a.Spec.Lifecycle.Install.Template += "spec: {}\n"
+	g.Expect(v.Verify(a)).To(MatchError(ContainSubstring("not valid")))
+
+	a.SetAnnotations(nil)
+	g.Expect(v.Verify(a)).To(MatchError(ContainSubstring("not signed")))
+
+	_, err = NewVerifier(nil)
+	g.Expect(err).To(HaveOccurred())
+}
@@ -0,0 +1,57 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rbac
+
+import (
+	"context"
+	"fmt"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// ScopeEnforcer rejects workflow service accounts with cluster-admin permissions
+type ScopeEnforcer struct {
+	client kubernetes.Interface
+}
+
+// NewScopeEnforcer returns a ScopeEnforcer
+func NewScopeEnforcer(client kubernetes.Interface) *ScopeEnforcer {
+	return &ScopeEnforcer{client: client}
+}
+
+// Check returns an error when the service account may perform every verb on every resource cluster wide
+func (e *ScopeEnforcer) Check(ctx context.Context, namespace, name string) error {
+	review, err := e.client.AuthorizationV1().SubjectAccessReviews().Create(ctx, &authorizationv1.SubjectAccessReview{
+		Spec: authorizationv1.SubjectAccessReviewSpec{
+			User:   fmt.Sprintf("system:serviceaccount:%s:%s", namespace, name),
+			Groups: []string{"system:serviceaccounts", "system:serviceaccounts:" + namespace, "system:authenticated"},
+			ResourceAttributes: &authorizationv1.ResourceAttributes{
+				Verb:     "*",
+				Group:    "*",
+				Resource: "*",
+			},
+		},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		return fmt.Errorf("could not review permissions of service account %s/%s. %v", namespace, name, err)
+	}
+
+	if review.Status.Allowed {
+		return fmt.Errorf("service account %s/%s has cluster-admin permissions, use a namespace scoped service account or mark the addon clusterScoped", namespace, name)
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rbac
+
+import (
+	"context"
+	"fmt"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/kubernetes"
+
+	addonmgrv1alpha1 "github.com/keikoproj/addon-manager/api/v1alpha1"
+	"github.com/keikoproj/addon-manager/pkg/common"
+)
+
+// Provisioner provisions the workflow service account of an addon and removes what is not garbage collected with it
+type Provisioner interface {
+	Provision(ctx context.Context, addon *addonmgrv1alpha1.Addon, role string, objects []*unstructured.Unstructured) (string, error)
+	Cleanup(ctx context.Context, addon *addonmgrv1alpha1.Addon) error
+}
+
+// Identity provisions a dedicated workflow service account per addon bound to a shared cluster role,
+// so workflow pods assume the addon role through IRSA instead of kube2iam pod annotations
+type Identity struct {
+	client      kubernetes.Interface
+	clusterRole string
+	provider    common.CloudProvider
+}
+
+// NewIdentity returns an Identity binding addon service accounts to clusterRole, which must be set explicitly. The
+// addon manager must hold the permissions of clusterRole or be allowed to bind it.
+func NewIdentity(client kubernetes.Interface, clusterRole string) *Identity {
+	return &Identity{client: client, clusterRole: clusterRole}
+}
+
+// SetCloudProvider selects the workload identity annotation of the service accounts, AWS by default
+func (i *Identity) SetCloudProvider(p common.CloudProvider) {
+	i.provider = p
+}
+
+// Provision creates or updates the addon workflow service account annotated with role
+func (i *Identity) Provision(ctx context.Context, addon *addonmgrv1alpha1.Addon, role string, _ []*unstructured.Unstructured) (string, error) {
+	if i.clusterRole == "" {
+		return "", fmt.Errorf("no cluster role is set for the workflow service account of addon %s", addon.Name)
+	}
+
+	sa := newServiceAccount(addon, i.provider.ServiceAccountAnnotation(), role)
+	if err := applyServiceAccount(ctx, i.client, sa, i.provider.ServiceAccountAnnotation()); err != nil {
+		return "", err
+	}
+
+	if err := applyClusterRoleBinding(ctx, i.client, &rbacv1.ClusterRoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: roleName(addon), Labels: addonLabels(addon)},
+		RoleRef:    rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "ClusterRole", Name: i.clusterRole},
+		Subjects:   []rbacv1.Subject{{Kind: rbacv1.ServiceAccountKind, Name: sa.Name, Namespace: sa.Namespace}},
+	}); err != nil {
+		return "", err
+	}
+
+	return sa.Name, nil
+}
+
+// Cleanup deletes the cluster role binding of the addon service account
+func (i *Identity) Cleanup(ctx context.Context, addon *addonmgrv1alpha1.Addon) error {
+	return ignoreNotFound(i.client.RbacV1().ClusterRoleBindings().Delete(ctx, roleName(addon), metav1.DeleteOptions{}))
+}
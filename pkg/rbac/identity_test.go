@@ -0,0 +1,91 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rbac
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/keikoproj/addon-manager/pkg/common"
+)
+
+func TestIdentity_ProvisionAndCleanup(t *testing.T) {
+	g := NewGomegaWithT(t)
+	ctx := context.TODO()
+	client := fake.NewSimpleClientset()
+	id := NewIdentity(client, "addon-manager-addon-workflow-cr")
+	a := newRBACAddon()
+	role := "arn:aws:iam::123456789012:role/rbac-test"
+
+	name, err := id.Provision(ctx, a, role, nil)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(name).To(Equal("rbac-test-workflow"))
+
+	sa, err := client.CoreV1().ServiceAccounts("addon-manager-system").Get(ctx, name, metav1.GetOptions{})
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(sa.Annotations).To(HaveKeyWithValue(common.AWSRoleAnnotation, role))
+	g.Expect(sa.OwnerReferences).To(HaveLen(1))
+
+	binding, err := client.RbacV1().ClusterRoleBindings().Get(ctx, "addonmgr:addon-manager-system:rbac-test", metav1.GetOptions{})
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(binding.RoleRef.Name).To(Equal("addon-manager-addon-workflow-cr"))
+
+	// A step without a role removes the annotation
+	_, err = id.Provision(ctx, a, "", nil)
+	g.Expect(err).ToNot(HaveOccurred())
+	sa, err = client.CoreV1().ServiceAccounts("addon-manager-system").Get(ctx, name, metav1.GetOptions{})
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(sa.Annotations).ToNot(HaveKey(common.AWSRoleAnnotation))
+
+	g.Expect(id.Cleanup(ctx, a)).To(Succeed())
+	_, err = client.RbacV1().ClusterRoleBindings().Get(ctx, binding.Name, metav1.GetOptions{})
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(id.Cleanup(ctx, a)).To(Succeed())
+}
+
+func TestIdentity_ProvisionWithoutClusterRole(t *testing.T) {
+	g := NewGomegaWithT(t)
+	ctx := context.TODO()
+	client := fake.NewSimpleClientset()
+	id := NewIdentity(client, "")
+
+	_, err := id.Provision(ctx, newRBACAddon(), "", nil)
+	g.Expect(err).To(MatchError(ContainSubstring("no cluster role")))
+
+	bindings, err := client.RbacV1().ClusterRoleBindings().List(ctx, metav1.ListOptions{})
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(bindings.Items).To(BeEmpty())
+}
+
+func TestIdentity_GCPProvider(t *testing.T) {
+	g := NewGomegaWithT(t)
+	ctx := context.TODO()
+	client := fake.NewSimpleClientset()
+	id := NewIdentity(client, "addon-manager-addon-workflow-cr")
+	id.SetCloudProvider(common.GCPProvider)
+	a := newRBACAddon()
+
+	name, err := id.Provision(ctx, a, "addon@project.iam.gserviceaccount.com", nil)
+	g.Expect(err).ToNot(HaveOccurred())
+
+	sa, err := client.CoreV1().ServiceAccounts("addon-manager-system").Get(ctx, name, metav1.GetOptions{})
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(sa.Annotations).To(HaveKeyWithValue(common.GCPServiceAccountAnnotation, "addon@project.iam.gserviceaccount.com"))
+	g.Expect(sa.Annotations).ToNot(HaveKey(common.AWSRoleAnnotation))
+}
@@ -0,0 +1,465 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rbac
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	"github.com/jinzhu/inflection"
+	v1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/yaml"
+
+	addonmgrv1alpha1 "github.com/keikoproj/addon-manager/api/v1alpha1"
+	"github.com/keikoproj/addon-manager/pkg/common"
+)
+
+// AddonLabel marks generated RBAC objects with the <namespace>.<name> of the addon they were generated for
+const AddonLabel = "addonmgr.keikoproj.io/rbac-for"
+
+var (
+	resourceVerbs = []string{"get", "list", "watch", "create", "update", "patch", "delete"}
+
+	// executorRules are needed by the argo executor in the workflow pods
+	executorRules = []rbacv1.PolicyRule{
+		{APIGroups: []string{""}, Resources: []string{"pods"}, Verbs: []string{"get", "watch", "patch"}},
+		{APIGroups: []string{""}, Resources: []string{"pods/log"}, Verbs: []string{"get", "watch"}},
+	}
+)
+
+// Generator provisions a least privilege workflow service account per addon from the kinds in its artifacts
+type Generator struct {
+	client   kubernetes.Interface
+	mapper   meta.RESTMapper
+	provider common.CloudProvider
+	// grantable are the rules Roles and ClusterRoles in artifacts may grant
+	grantable []rbacv1.PolicyRule
+}
+
+// NewGenerator returns a Generator, mapper resolves artifact kinds to resources
+func NewGenerator(client kubernetes.Interface, mapper meta.RESTMapper) *Generator {
+	return &Generator{client: client, mapper: mapper}
+}
+
+// SetCloudProvider selects the workload identity annotation of the service accounts, AWS by default
+func (g *Generator) SetCloudProvider(p common.CloudProvider) {
+	g.provider = p
+}
+
+// SetGrantableRules sets the rules Roles and ClusterRoles in addon artifacts may grant, the service account must hold
+// them to create the roles. Generation fails for artifacts with roles granting other rules, by default any rule.
+func (g *Generator) SetGrantableRules(rules []rbacv1.PolicyRule) {
+	g.grantable = rules
+}
+
+// LoadGrantableRules reads a YAML list of policy rules from file
+func LoadGrantableRules(file string) ([]rbacv1.PolicyRule, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var rules []rbacv1.PolicyRule
+	if err := yaml.Unmarshal(data, &rules); err != nil {
+		return nil, fmt.Errorf("invalid grantable rules %s. %v", file, err)
+	}
+	return rules, nil
+}
+
+// ServiceAccountName returns the name of the generated workflow service account in the addon namespace
+func ServiceAccountName(addon *addonmgrv1alpha1.Addon) string {
+	return addon.Name + "-workflow"
+}
+
+func roleName(addon *addonmgrv1alpha1.Addon) string {
+	return fmt.Sprintf("addonmgr:%s:%s", addon.Namespace, addon.Name)
+}
+
+func addonLabels(addon *addonmgrv1alpha1.Addon) map[string]string {
+	return map[string]string{AddonLabel: addon.Namespace + "." + addon.Name}
+}
+
+// Rules returns the rules needed to apply the objects, cluster scoped rules and namespaced rules keyed by namespace.
+// Namespaced objects without a namespace are applied to defaultNamespace. Roles and ClusterRoles in the objects fail
+// with an error unless all of their rules are grantable.
+func (g *Generator) Rules(objects []*unstructured.Unstructured, defaultNamespace string) ([]rbacv1.PolicyRule, map[string][]rbacv1.PolicyRule, error) {
+	cluster := map[schema.GroupResource]bool{}
+	namespaced := map[string]map[schema.GroupResource]bool{}
+	var clusterExtra []rbacv1.PolicyRule
+	namespacedExtra := map[string][]rbacv1.PolicyRule{}
+
+	for _, obj := range objects {
+		gvk := obj.GroupVersionKind()
+		if gvk.Kind == "" {
+			continue
+		}
+
+		gr, clusterScoped := g.resourceFor(gvk)
+		ns := obj.GetNamespace()
+		if ns == "" {
+			ns = defaultNamespace
+		}
+
+		if clusterScoped {
+			cluster[gr] = true
+		} else {
+			if namespaced[ns] == nil {
+				namespaced[ns] = map[schema.GroupResource]bool{}
+			}
+			namespaced[ns][gr] = true
+		}
+
+		// Creating roles requires holding their permissions, only the grantable rules are held
+		if gvk.Group == rbacv1.GroupName && (gvk.Kind == "Role" || gvk.Kind == "ClusterRole") {
+			var role rbacv1.Role
+			if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.UnstructuredContent(), &role); err != nil {
+				return nil, nil, fmt.Errorf("invalid %s %s. %v", gvk.Kind, obj.GetName(), err)
+			}
+			for _, rule := range role.Rules {
+				if !covers(g.grantable, rule) {
+					return nil, nil, fmt.Errorf("%s %s grants %s which is not a grantable rule", gvk.Kind, obj.GetName(), rule.String())
+				}
+			}
+			if gvk.Kind == "ClusterRole" {
+				clusterExtra = append(clusterExtra, role.Rules...)
+			} else {
+				namespacedExtra[ns] = append(namespacedExtra[ns], role.Rules...)
+			}
+		}
+	}
+
+	clusterRules := append(toRules(cluster), clusterExtra...)
+	namespacedRules := make(map[string][]rbacv1.PolicyRule, len(namespaced))
+	for ns, resources := range namespaced {
+		namespacedRules[ns] = append(toRules(resources), namespacedExtra[ns]...)
+	}
+
+	return clusterRules, namespacedRules, nil
+}
+
+// covers returns true when every verb on every resource, resource name and non-resource URL of rule is granted by
+// one of the allowed rules
+func covers(allowed []rbacv1.PolicyRule, rule rbacv1.PolicyRule) bool {
+	for _, verb := range rule.Verbs {
+		for _, url := range rule.NonResourceURLs {
+			if !coveredBy(allowed, func(a rbacv1.PolicyRule) bool {
+				return matches(a.Verbs, verb) && matchesURL(a.NonResourceURLs, url)
+			}) {
+				return false
+			}
+		}
+		for _, group := range rule.APIGroups {
+			for _, resource := range rule.Resources {
+				if !coveredBy(allowed, func(a rbacv1.PolicyRule) bool {
+					return matches(a.Verbs, verb) && matches(a.APIGroups, group) && matches(a.Resources, resource) &&
+						(len(a.ResourceNames) == 0 || (len(rule.ResourceNames) > 0 && containsAll(a.ResourceNames, rule.ResourceNames)))
+				}) {
+					return false
+				}
+			}
+		}
+	}
+	return true
+}
+
+func coveredBy(allowed []rbacv1.PolicyRule, fn func(rbacv1.PolicyRule) bool) bool {
+	for _, a := range allowed {
+		if fn(a) {
+			return true
+		}
+	}
+	return false
+}
+
+func matches(values []string, value string) bool {
+	for _, v := range values {
+		if v == rbacv1.ResourceAll || v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func matchesURL(urls []string, url string) bool {
+	for _, u := range urls {
+		if u == url || u == rbacv1.NonResourceAll || (strings.HasSuffix(u, "*") && strings.HasPrefix(url, strings.TrimSuffix(u, "*"))) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsAll(values, subset []string) bool {
+	for _, v := range subset {
+		if !common.ContainsString(values, v) {
+			return false
+		}
+	}
+	return true
+}
+
+// resourceFor maps a kind to its resource, kinds unknown to the mapper, e.g. CRs of CRDs in the same artifact, are pluralized
+func (g *Generator) resourceFor(gvk schema.GroupVersionKind) (schema.GroupResource, bool) {
+	if g.mapper != nil {
+		if mapping, err := g.mapper.RESTMapping(gvk.GroupKind(), gvk.Version); err == nil {
+			return mapping.Resource.GroupResource(), mapping.Scope.Name() == meta.RESTScopeNameRoot
+		}
+	}
+	return schema.GroupResource{Group: gvk.Group, Resource: inflection.Plural(strings.ToLower(gvk.Kind))}, false
+}
+
+// toRules returns one sorted rule per api group
+func toRules(resources map[schema.GroupResource]bool) []rbacv1.PolicyRule {
+	byGroup := map[string][]string{}
+	for gr := range resources {
+		byGroup[gr.Group] = append(byGroup[gr.Group], gr.Resource)
+	}
+
+	var groups []string
+	for group := range byGroup {
+		groups = append(groups, group)
+	}
+	sort.Strings(groups)
+
+	var rules []rbacv1.PolicyRule
+	for _, group := range groups {
+		sort.Strings(byGroup[group])
+		rules = append(rules, rbacv1.PolicyRule{
+			APIGroups: []string{group},
+			Resources: byGroup[group],
+			Verbs:     resourceVerbs,
+		})
+	}
+	return rules
+}
+
+// Provision creates or updates the addon workflow service account with the roles needed to apply the objects
+func (g *Generator) Provision(ctx context.Context, addon *addonmgrv1alpha1.Addon, role string, objects []*unstructured.Unstructured) (string, error) {
+	clusterRules, namespacedRules, err := g.Rules(objects, addon.Spec.Params.Namespace)
+	if err != nil {
+		return "", err
+	}
+
+	saName := ServiceAccountName(addon)
+	owner := metav1.NewControllerRef(addon, addonmgrv1alpha1.GroupVersion.WithKind("Addon"))
+	subjects := []rbacv1.Subject{{Kind: rbacv1.ServiceAccountKind, Name: saName, Namespace: addon.Namespace}}
+
+	annotation := g.provider.ServiceAccountAnnotation()
+	if err := applyServiceAccount(ctx, g.client, newServiceAccount(addon, annotation, role), annotation); err != nil {
+		return "", err
+	}
+
+	// Executor permissions in the workflow namespace
+	executorName := saName + "-executor"
+	if err := g.applyRole(ctx, &rbacv1.Role{
+		ObjectMeta: metav1.ObjectMeta{Name: executorName, Namespace: addon.Namespace, Labels: addonLabels(addon), OwnerReferences: []metav1.OwnerReference{*owner}},
+		Rules:      executorRules,
+	}, subjects); err != nil {
+		return "", err
+	}
+
+	if len(clusterRules) > 0 {
+		if err := g.applyClusterRole(ctx, &rbacv1.ClusterRole{
+			ObjectMeta: metav1.ObjectMeta{Name: roleName(addon), Labels: addonLabels(addon)},
+			Rules:      clusterRules,
+		}, subjects); err != nil {
+			return "", err
+		}
+	}
+
+	for ns, rules := range namespacedRules {
+		if err := g.ensureNamespace(ctx, ns); err != nil {
+			return "", err
+		}
+		if err := g.applyRole(ctx, &rbacv1.Role{
+			ObjectMeta: metav1.ObjectMeta{Name: roleName(addon), Namespace: ns, Labels: addonLabels(addon)},
+			Rules:      rules,
+		}, subjects); err != nil {
+			return "", err
+		}
+	}
+
+	return saName, nil
+}
+
+// Cleanup deletes the generated roles that are not garbage collected with the addon
+func (g *Generator) Cleanup(ctx context.Context, addon *addonmgrv1alpha1.Addon) error {
+	selector := metav1.ListOptions{LabelSelector: AddonLabel + "=" + addon.Namespace + "." + addon.Name}
+	rbacClient := g.client.RbacV1()
+
+	if err := ignoreNotFound(rbacClient.ClusterRoleBindings().DeleteCollection(ctx, metav1.DeleteOptions{}, selector)); err != nil {
+		return err
+	}
+	if err := ignoreNotFound(rbacClient.ClusterRoles().DeleteCollection(ctx, metav1.DeleteOptions{}, selector)); err != nil {
+		return err
+	}
+
+	bindings, err := rbacClient.RoleBindings(metav1.NamespaceAll).List(ctx, selector)
+	if err != nil {
+		return err
+	}
+	for _, b := range bindings.Items {
+		if err := ignoreNotFound(rbacClient.RoleBindings(b.Namespace).Delete(ctx, b.Name, metav1.DeleteOptions{})); err != nil {
+			return err
+		}
+	}
+
+	roles, err := rbacClient.Roles(metav1.NamespaceAll).List(ctx, selector)
+	if err != nil {
+		return err
+	}
+	for _, r := range roles.Items {
+		if err := ignoreNotFound(rbacClient.Roles(r.Namespace).Delete(ctx, r.Name, metav1.DeleteOptions{})); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (g *Generator) ensureNamespace(ctx context.Context, name string) error {
+	_, err := g.client.CoreV1().Namespaces().Get(ctx, name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		_, err = g.client.CoreV1().Namespaces().Create(ctx, &v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name}}, metav1.CreateOptions{})
+		if apierrors.IsAlreadyExists(err) {
+			return nil
+		}
+	}
+	return err
+}
+
+// newServiceAccount returns the addon workflow service account owned by the addon, role is set in annotation when not empty
+func newServiceAccount(addon *addonmgrv1alpha1.Addon, annotation, role string) *v1.ServiceAccount {
+	owner := metav1.NewControllerRef(addon, addonmgrv1alpha1.GroupVersion.WithKind("Addon"))
+	sa := &v1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{
+		Name:            ServiceAccountName(addon),
+		Namespace:       addon.Namespace,
+		Labels:          addonLabels(addon),
+		OwnerReferences: []metav1.OwnerReference{*owner},
+	}}
+	if role != "" {
+		sa.Annotations = map[string]string{annotation: role}
+	}
+	return sa
+}
+
+// applyServiceAccount creates or updates sa, the role annotation is removed when sa does not set it
+func applyServiceAccount(ctx context.Context, c kubernetes.Interface, sa *v1.ServiceAccount, annotation string) error {
+	client := c.CoreV1().ServiceAccounts(sa.Namespace)
+	existing, err := client.Get(ctx, sa.Name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		_, err = client.Create(ctx, sa, metav1.CreateOptions{})
+		return err
+	}
+	if err != nil {
+		return err
+	}
+	existing.Labels = sa.Labels
+	existing.OwnerReferences = sa.OwnerReferences
+	if role, ok := sa.Annotations[annotation]; ok {
+		if existing.Annotations == nil {
+			existing.Annotations = map[string]string{}
+		}
+		existing.Annotations[annotation] = role
+	} else {
+		delete(existing.Annotations, annotation)
+	}
+	_, err = client.Update(ctx, existing, metav1.UpdateOptions{})
+	return err
+}
+
+func (g *Generator) applyRole(ctx context.Context, role *rbacv1.Role, subjects []rbacv1.Subject) error {
+	client := g.client.RbacV1().Roles(role.Namespace)
+	existing, err := client.Get(ctx, role.Name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		_, err = client.Create(ctx, role, metav1.CreateOptions{})
+	} else if err == nil {
+		existing.Labels, existing.Rules = role.Labels, role.Rules
+		_, err = client.Update(ctx, existing, metav1.UpdateOptions{})
+	}
+	if err != nil {
+		return err
+	}
+
+	binding := &rbacv1.RoleBinding{
+		ObjectMeta: role.ObjectMeta,
+		RoleRef:    rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "Role", Name: role.Name},
+		Subjects:   subjects,
+	}
+	bindings := g.client.RbacV1().RoleBindings(role.Namespace)
+	existingBinding, err := bindings.Get(ctx, binding.Name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		_, err = bindings.Create(ctx, binding, metav1.CreateOptions{})
+		return err
+	}
+	if err != nil {
+		return err
+	}
+	existingBinding.Labels, existingBinding.Subjects = binding.Labels, binding.Subjects
+	_, err = bindings.Update(ctx, existingBinding, metav1.UpdateOptions{})
+	return err
+}
+
+func (g *Generator) applyClusterRole(ctx context.Context, role *rbacv1.ClusterRole, subjects []rbacv1.Subject) error {
+	client := g.client.RbacV1().ClusterRoles()
+	existing, err := client.Get(ctx, role.Name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		_, err = client.Create(ctx, role, metav1.CreateOptions{})
+	} else if err == nil {
+		existing.Labels, existing.Rules = role.Labels, role.Rules
+		_, err = client.Update(ctx, existing, metav1.UpdateOptions{})
+	}
+	if err != nil {
+		return err
+	}
+
+	return applyClusterRoleBinding(ctx, g.client, &rbacv1.ClusterRoleBinding{
+		ObjectMeta: role.ObjectMeta,
+		RoleRef:    rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "ClusterRole", Name: role.Name},
+		Subjects:   subjects,
+	})
+}
+
+func applyClusterRoleBinding(ctx context.Context, c kubernetes.Interface, binding *rbacv1.ClusterRoleBinding) error {
+	bindings := c.RbacV1().ClusterRoleBindings()
+	existingBinding, err := bindings.Get(ctx, binding.Name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		_, err = bindings.Create(ctx, binding, metav1.CreateOptions{})
+		return err
+	}
+	if err != nil {
+		return err
+	}
+	existingBinding.Labels, existingBinding.Subjects = binding.Labels, binding.Subjects
+	_, err = bindings.Update(ctx, existingBinding, metav1.UpdateOptions{})
+	return err
+}
+
+func ignoreNotFound(err error) error {
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	return err
+}
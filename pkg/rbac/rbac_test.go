@@ -0,0 +1,145 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rbac
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/fake"
+	"sigs.k8s.io/yaml"
+
+	addonmgrv1alpha1 "github.com/keikoproj/addon-manager/api/v1alpha1"
+)
+
+func newTestMapper() meta.RESTMapper {
+	mapper := meta.NewDefaultRESTMapper(nil)
+	mapper.Add(schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}, meta.RESTScopeNamespace)
+	mapper.Add(schema.GroupVersionKind{Version: "v1", Kind: "Namespace"}, meta.RESTScopeRoot)
+	mapper.Add(schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"}, meta.RESTScopeNamespace)
+	mapper.Add(schema.GroupVersionKind{Group: "rbac.authorization.k8s.io", Version: "v1", Kind: "ClusterRole"}, meta.RESTScopeRoot)
+	return mapper
+}
+
+func newTestObjects(g *WithT) []*unstructured.Unstructured {
+	var objects []*unstructured.Unstructured
+	for _, doc := range []string{
+		"apiVersion: v1\nkind: Namespace\nmetadata:\n  name: app-ns",
+		"apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: app",
+		"apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: app\n  namespace: other-ns",
+		"apiVersion: example.com/v1\nkind: Widget\nmetadata:\n  name: app",
+		"apiVersion: rbac.authorization.k8s.io/v1\nkind: ClusterRole\nmetadata:\n  name: app\nrules:\n- apiGroups: ['']\n  resources: [nodes]\n  verbs: [get]",
+	} {
+		obj := &unstructured.Unstructured{}
+		g.Expect(yaml.Unmarshal([]byte(doc), &obj.Object)).To(Succeed())
+		objects = append(objects, obj)
+	}
+	return objects
+}
+
+func newRBACAddon() *addonmgrv1alpha1.Addon {
+	return &addonmgrv1alpha1.Addon{
+		ObjectMeta: metav1.ObjectMeta{Name: "rbac-test", Namespace: "addon-manager-system"},
+		Spec: addonmgrv1alpha1.AddonSpec{
+			Params: addonmgrv1alpha1.AddonParams{Namespace: "app-ns"},
+		},
+	}
+}
+
+func TestGenerator_Rules(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	gen := NewGenerator(fake.NewSimpleClientset(), newTestMapper())
+	// Roles in artifacts may only grant the grantable rules
+	_, _, err := gen.Rules(newTestObjects(g), "app-ns")
+	g.Expect(err).To(MatchError(ContainSubstring("ClusterRole app grants")))
+
+	gen.SetGrantableRules([]rbacv1.PolicyRule{{APIGroups: []string{""}, Resources: []string{"nodes", "pods"}, Verbs: []string{"get", "list"}}})
+	cluster, namespaced, err := gen.Rules(newTestObjects(g), "app-ns")
+	g.Expect(err).ToNot(HaveOccurred())
+
+	g.Expect(cluster).To(Equal([]rbacv1.PolicyRule{
+		{APIGroups: []string{""}, Resources: []string{"namespaces"}, Verbs: resourceVerbs},
+		{APIGroups: []string{"rbac.authorization.k8s.io"}, Resources: []string{"clusterroles"}, Verbs: resourceVerbs},
+		{APIGroups: []string{""}, Resources: []string{"nodes"}, Verbs: []string{"get"}},
+	}))
+	g.Expect(namespaced).To(Equal(map[string][]rbacv1.PolicyRule{
+		"app-ns": {
+			{APIGroups: []string{"apps"}, Resources: []string{"deployments"}, Verbs: resourceVerbs},
+			{APIGroups: []string{"example.com"}, Resources: []string{"widgets"}, Verbs: resourceVerbs},
+		},
+		"other-ns": {
+			{APIGroups: []string{""}, Resources: []string{"configmaps"}, Verbs: resourceVerbs},
+		},
+	}))
+}
+
+func TestGenerator_ProvisionAndCleanup(t *testing.T) {
+	g := NewGomegaWithT(t)
+	ctx := context.TODO()
+	client := fake.NewSimpleClientset()
+	gen := NewGenerator(client, newTestMapper())
+	gen.SetGrantableRules([]rbacv1.PolicyRule{{APIGroups: []string{""}, Resources: []string{"nodes"}, Verbs: []string{"get"}}})
+	a := newRBACAddon()
+
+	sa, err := gen.Provision(ctx, a, "", newTestObjects(g))
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(sa).To(Equal("rbac-test-workflow"))
+
+	_, err = client.CoreV1().ServiceAccounts("addon-manager-system").Get(ctx, sa, metav1.GetOptions{})
+	g.Expect(err).ToNot(HaveOccurred())
+	_, err = client.RbacV1().RoleBindings("addon-manager-system").Get(ctx, "rbac-test-workflow-executor", metav1.GetOptions{})
+	g.Expect(err).ToNot(HaveOccurred())
+	binding, err := client.RbacV1().ClusterRoleBindings().Get(ctx, "addonmgr:addon-manager-system:rbac-test", metav1.GetOptions{})
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(binding.Subjects).To(ConsistOf(rbacv1.Subject{Kind: "ServiceAccount", Name: sa, Namespace: "addon-manager-system"}))
+	_, err = client.RbacV1().Roles("other-ns").Get(ctx, "addonmgr:addon-manager-system:rbac-test", metav1.GetOptions{})
+	g.Expect(err).ToNot(HaveOccurred())
+	_, err = client.CoreV1().Namespaces().Get(ctx, "other-ns", metav1.GetOptions{})
+	g.Expect(err).ToNot(HaveOccurred())
+
+	// Provisioning again updates in place
+	_, err = gen.Provision(ctx, a, "", newTestObjects(g)[:2])
+	g.Expect(err).ToNot(HaveOccurred())
+
+	g.Expect(gen.Cleanup(ctx, a)).To(Succeed())
+	_, err = client.RbacV1().Roles("other-ns").Get(ctx, "addonmgr:addon-manager-system:rbac-test", metav1.GetOptions{})
+	g.Expect(err).To(HaveOccurred())
+}
+
+func TestCovers(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	allowed := []rbacv1.PolicyRule{
+		{APIGroups: []string{"", "apps"}, Resources: []string{"pods", "deployments"}, Verbs: []string{"get", "list", "watch"}},
+		{APIGroups: []string{""}, Resources: []string{"configmaps"}, ResourceNames: []string{"app-config"}, Verbs: []string{"*"}},
+		{NonResourceURLs: []string{"/metrics", "/healthz/*"}, Verbs: []string{"get"}},
+	}
+	g.Expect(covers(allowed, rbacv1.PolicyRule{APIGroups: []string{"apps"}, Resources: []string{"deployments"}, Verbs: []string{"get", "watch"}})).To(BeTrue())
+	g.Expect(covers(allowed, rbacv1.PolicyRule{APIGroups: []string{""}, Resources: []string{"configmaps"}, ResourceNames: []string{"app-config"}, Verbs: []string{"update"}})).To(BeTrue())
+	g.Expect(covers(allowed, rbacv1.PolicyRule{NonResourceURLs: []string{"/healthz/ready"}, Verbs: []string{"get"}})).To(BeTrue())
+
+	g.Expect(covers(allowed, rbacv1.PolicyRule{APIGroups: []string{"apps"}, Resources: []string{"deployments"}, Verbs: []string{"delete"}})).To(BeFalse())
+	g.Expect(covers(allowed, rbacv1.PolicyRule{APIGroups: []string{""}, Resources: []string{"configmaps"}, Verbs: []string{"get"}})).To(BeFalse())
+	g.Expect(covers(allowed, rbacv1.PolicyRule{APIGroups: []string{"*"}, Resources: []string{"*"}, Verbs: []string{"*"}})).To(BeFalse())
+	g.Expect(covers(allowed, rbacv1.PolicyRule{NonResourceURLs: []string{"/debug"}, Verbs: []string{"get"}})).To(BeFalse())
+	g.Expect(covers(nil, rbacv1.PolicyRule{APIGroups: []string{""}, Resources: []string{"pods"}, Verbs: []string{"get"}})).To(BeFalse())
+}
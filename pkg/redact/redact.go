@@ -0,0 +1,196 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package redact
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+)
+
+// Mask replaces secret values
+const Mask = "******"
+
+// minLength is the shortest value that is masked, shorter values would mask unrelated text
+const minLength = 4
+
+// Redactor masks known secret values, a nil Redactor masks nothing
+type Redactor struct {
+	mu       sync.RWMutex
+	values   map[string]struct{}
+	replacer *strings.Replacer
+}
+
+// New returns an empty Redactor
+func New() *Redactor {
+	return &Redactor{values: map[string]struct{}{}}
+}
+
+// Add registers secret values to mask, including their base64 encoding
+func (r *Redactor) Add(values ...string) {
+	if r == nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, v := range values {
+		if len(v) < minLength {
+			continue
+		}
+		for _, s := range []string{v, base64.StdEncoding.EncodeToString([]byte(v))} {
+			if _, ok := r.values[s]; !ok {
+				r.values[s] = struct{}{}
+				r.replacer = nil
+			}
+		}
+	}
+}
+
+// String returns s with every registered value masked
+func (r *Redactor) String(s string) string {
+	if r == nil || s == "" {
+		return s
+	}
+
+	r.mu.RLock()
+	replacer := r.replacer
+	r.mu.RUnlock()
+	if replacer == nil {
+		replacer = r.buildReplacer()
+	}
+	if replacer == nil {
+		return s
+	}
+	return replacer.Replace(s)
+}
+
+// Error returns err with its message masked
+func (r *Redactor) Error(err error) error {
+	if err == nil {
+		return nil
+	}
+	if msg := r.String(err.Error()); msg != err.Error() {
+		return errors.New(msg)
+	}
+	return err
+}
+
+func (r *Redactor) buildReplacer() *strings.Replacer {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.replacer != nil || len(r.values) == 0 {
+		return r.replacer
+	}
+
+	// Longest values first so a value containing another is masked whole
+	values := make([]string, 0, len(r.values))
+	for v := range r.values {
+		values = append(values, v)
+	}
+	sort.Slice(values, func(i, j int) bool {
+		if len(values[i]) != len(values[j]) {
+			return len(values[i]) > len(values[j])
+		}
+		return values[i] < values[j]
+	})
+
+	pairs := make([]string, 0, 2*len(values))
+	for _, v := range values {
+		pairs = append(pairs, v, Mask)
+	}
+	r.replacer = strings.NewReplacer(pairs...)
+	return r.replacer
+}
+
+func (r *Redactor) keysAndValues(kv []interface{}) []interface{} {
+	out := make([]interface{}, len(kv))
+	for i, v := range kv {
+		switch val := v.(type) {
+		case string:
+			out[i] = r.String(val)
+		case error:
+			out[i] = r.Error(val)
+		case fmt.Stringer:
+			out[i] = r.String(val.String())
+		default:
+			out[i] = v
+		}
+	}
+	return out
+}
+
+type logger struct {
+	log logr.Logger
+	r   *Redactor
+}
+
+// NewLogger returns a logger masking secret values in messages, errors and string values
+func NewLogger(log logr.Logger, r *Redactor) logr.Logger {
+	return &logger{log: log, r: r}
+}
+
+func (l *logger) Enabled() bool {
+	return l.log.Enabled()
+}
+
+func (l *logger) Info(msg string, keysAndValues ...interface{}) {
+	l.log.Info(l.r.String(msg), l.r.keysAndValues(keysAndValues)...)
+}
+
+func (l *logger) Error(err error, msg string, keysAndValues ...interface{}) {
+	l.log.Error(l.r.Error(err), l.r.String(msg), l.r.keysAndValues(keysAndValues)...)
+}
+
+func (l *logger) V(level int) logr.Logger {
+	return &logger{log: l.log.V(level), r: l.r}
+}
+
+func (l *logger) WithValues(keysAndValues ...interface{}) logr.Logger {
+	return &logger{log: l.log.WithValues(l.r.keysAndValues(keysAndValues)...), r: l.r}
+}
+
+func (l *logger) WithName(name string) logr.Logger {
+	return &logger{log: l.log.WithName(name), r: l.r}
+}
+
+type eventRecorder struct {
+	recorder record.EventRecorder
+	r        *Redactor
+}
+
+// NewEventRecorder returns an event recorder masking secret values in event messages
+func NewEventRecorder(recorder record.EventRecorder, r *Redactor) record.EventRecorder {
+	return &eventRecorder{recorder: recorder, r: r}
+}
+
+func (e *eventRecorder) Event(object runtime.Object, eventtype, reason, message string) {
+	e.recorder.Event(object, eventtype, reason, e.r.String(message))
+}
+
+func (e *eventRecorder) Eventf(object runtime.Object, eventtype, reason, messageFmt string, args ...interface{}) {
+	e.recorder.Event(object, eventtype, reason, e.r.String(fmt.Sprintf(messageFmt, args...)))
+}
+
+func (e *eventRecorder) AnnotatedEventf(object runtime.Object, annotations map[string]string, eventtype, reason, messageFmt string, args ...interface{}) {
+	e.recorder.AnnotatedEventf(object, annotations, eventtype, reason, "%s", e.r.String(fmt.Sprintf(messageFmt, args...)))
+}
@@ -0,0 +1,92 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package redact
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/go-logr/logr"
+	. "github.com/onsi/gomega"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+type captureLogger struct {
+	lines *[]string
+}
+
+func (c captureLogger) Enabled() bool { return true }
+func (c captureLogger) Info(msg string, kv ...interface{}) {
+	*c.lines = append(*c.lines, fmt.Sprint(append([]interface{}{msg}, kv...)...))
+}
+func (c captureLogger) Error(err error, msg string, kv ...interface{}) {
+	*c.lines = append(*c.lines, fmt.Sprint(append([]interface{}{err, msg}, kv...)...))
+}
+func (c captureLogger) V(int) logr.Logger                     { return c }
+func (c captureLogger) WithValues(...interface{}) logr.Logger { return c }
+func (c captureLogger) WithName(string) logr.Logger           { return c }
+
+func TestRedactor_String(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	r := New()
+	g.Expect(r.String("password hunter2pass")).To(Equal("password hunter2pass"))
+
+	r.Add("hunter2pass", "hunter2", "abc")
+	g.Expect(r.String("password hunter2pass")).To(Equal("password " + Mask))
+	g.Expect(r.String("token aHVudGVyMnBhc3M=")).To(Equal("token " + Mask))
+	g.Expect(r.String("abc is too short to mask")).To(Equal("abc is too short to mask"))
+
+	err := errors.New("login failed for hunter2")
+	g.Expect(r.Error(err).Error()).To(Equal("login failed for " + Mask))
+	g.Expect(r.Error(nil)).To(BeNil())
+
+	var none *Redactor
+	none.Add("hunter2")
+	g.Expect(none.String("hunter2")).To(Equal("hunter2"))
+}
+
+func TestNewLogger(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	r := New()
+	r.Add("s3cr3t-value")
+	var lines []string
+	log := NewLogger(captureLogger{lines: &lines}, r)
+
+	log.WithName("addon").V(1).Info("fetched s3cr3t-value", "value", "s3cr3t-value", "count", 1)
+	log.Error(errors.New("bad s3cr3t-value"), "failed")
+	g.Expect(lines).To(HaveLen(2))
+	for _, line := range lines {
+		g.Expect(line).ToNot(ContainSubstring("s3cr3t-value"))
+		g.Expect(line).To(ContainSubstring(Mask))
+	}
+}
+
+func TestNewEventRecorder(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	r := New()
+	r.Add("s3cr3t-value")
+	fake := record.NewFakeRecorder(2)
+	recorder := NewEventRecorder(fake, r)
+
+	recorder.Event(&v1.Pod{}, "Warning", "Failed", "could not use s3cr3t-value")
+	recorder.Eventf(&v1.Pod{}, "Warning", "Failed", "could not use %s", "s3cr3t-value")
+	g.Expect(<-fake.Events).To(Equal("Warning Failed could not use " + Mask))
+	g.Expect(<-fake.Events).To(Equal("Warning Failed could not use " + Mask))
+}
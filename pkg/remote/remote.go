@@ -0,0 +1,130 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package remote
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+
+	addonmgrv1alpha1 "github.com/keikoproj/addon-manager/api/v1alpha1"
+)
+
+// DefaultKubeconfigKey is the Secret key read when the cluster reference does not set one
+const DefaultKubeconfigKey = "kubeconfig"
+
+// Cluster holds the clients of a remote cluster
+type Cluster struct {
+	// Host is the API server of the cluster
+	Host      string
+	Client    client.Client
+	Dynamic   dynamic.Interface
+	Clientset kubernetes.Interface
+}
+
+type cachedCluster struct {
+	resourceVersion string
+	cluster         *Cluster
+}
+
+// Resolver builds clients for the remote cluster targeted by an addon from its kubeconfig Secret,
+// clients are reused until the Secret changes
+type Resolver struct {
+	client kubernetes.Interface
+	scheme *runtime.Scheme
+
+	mu       sync.Mutex
+	clusters map[string]cachedCluster
+}
+
+// NewResolver returns a Resolver reading kubeconfig Secrets with client
+func NewResolver(client kubernetes.Interface, scheme *runtime.Scheme) *Resolver {
+	return &Resolver{
+		client:   client,
+		scheme:   scheme,
+		clusters: make(map[string]cachedCluster),
+	}
+}
+
+// Resolve returns the remote cluster targeted by the addon, or nil when the addon targets the local cluster
+func (r *Resolver) Resolve(ctx context.Context, addon *addonmgrv1alpha1.Addon) (*Cluster, error) {
+	if addon.Spec.Target == nil || addon.Spec.Target.ClusterRef == nil {
+		return nil, nil
+	}
+	ref := addon.Spec.Target.ClusterRef
+
+	key := ref.Key
+	if key == "" {
+		key = DefaultKubeconfigKey
+	}
+
+	secret, err := r.client.CoreV1().Secrets(addon.Namespace).Get(ctx, ref.Name, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get cluster secret %s/%s. %v", addon.Namespace, ref.Name, err)
+	}
+
+	cacheKey := fmt.Sprintf("%s/%s/%s", addon.Namespace, ref.Name, key)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if cached, ok := r.clusters[cacheKey]; ok && cached.resourceVersion == secret.ResourceVersion {
+		return cached.cluster, nil
+	}
+
+	kubeconfig, ok := secret.Data[key]
+	if !ok {
+		return nil, fmt.Errorf("cluster secret %s/%s has no %s key", addon.Namespace, ref.Name, key)
+	}
+	cfg, err := clientcmd.RESTConfigFromKubeConfig(kubeconfig)
+	if err != nil {
+		return nil, fmt.Errorf("invalid kubeconfig in cluster secret %s/%s. %v", addon.Namespace, ref.Name, err)
+	}
+
+	cluster, err := newCluster(cfg, r.scheme)
+	if err != nil {
+		return nil, err
+	}
+	r.clusters[cacheKey] = cachedCluster{resourceVersion: secret.ResourceVersion, cluster: cluster}
+	return cluster, nil
+}
+
+func newCluster(cfg *rest.Config, scheme *runtime.Scheme) (*Cluster, error) {
+	// Discover the remote API lazily so an unreachable cluster surfaces as a workflow error
+	mapper, err := apiutil.NewDynamicRESTMapper(cfg, apiutil.WithLazyDiscovery)
+	if err != nil {
+		return nil, err
+	}
+	c, err := client.New(cfg, client.Options{Scheme: scheme, Mapper: mapper})
+	if err != nil {
+		return nil, err
+	}
+	dynClient, err := dynamic.NewForConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	clientset, err := kubernetes.NewForConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &Cluster{Host: cfg.Host, Client: c, Dynamic: dynClient, Clientset: clientset}, nil
+}
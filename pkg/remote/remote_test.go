@@ -0,0 +1,86 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package remote
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+
+	addonmgrv1alpha1 "github.com/keikoproj/addon-manager/api/v1alpha1"
+)
+
+const testKubeconfig = `apiVersion: v1
+kind: Config
+clusters:
+- name: edge
+  cluster:
+    server: https://edge.example.com:6443
+contexts:
+- name: edge
+  context:
+    cluster: edge
+    user: edge
+current-context: edge
+users:
+- name: edge
+  user:
+    token: abc
+`
+
+func TestResolver_Resolve(t *testing.T) {
+	g := NewGomegaWithT(t)
+	ctx := context.TODO()
+
+	secret := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "edge", Namespace: "addon-manager-system", ResourceVersion: "1"},
+		Data:       map[string][]byte{DefaultKubeconfigKey: []byte(testKubeconfig), "other": []byte("not a kubeconfig")},
+	}
+	r := NewResolver(fake.NewSimpleClientset(secret), runtime.NewScheme())
+
+	a := &addonmgrv1alpha1.Addon{ObjectMeta: metav1.ObjectMeta{Name: "remote", Namespace: "addon-manager-system"}}
+	cluster, err := r.Resolve(ctx, a)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(cluster).To(BeNil())
+
+	a.Spec.Target = &addonmgrv1alpha1.AddonTarget{ClusterRef: &addonmgrv1alpha1.ClusterReference{Name: "edge"}}
+	cluster, err = r.Resolve(ctx, a)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(cluster.Host).To(Equal("https://edge.example.com:6443"))
+	g.Expect(cluster.Client).ToNot(BeNil())
+	g.Expect(cluster.Dynamic).ToNot(BeNil())
+
+	// Clients are reused while the secret is unchanged
+	again, err := r.Resolve(ctx, a)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(again).To(BeIdenticalTo(cluster))
+
+	a.Spec.Target.ClusterRef.Key = "other"
+	_, err = r.Resolve(ctx, a)
+	g.Expect(err).To(MatchError(ContainSubstring("invalid kubeconfig")))
+
+	a.Spec.Target.ClusterRef.Key = "missing"
+	_, err = r.Resolve(ctx, a)
+	g.Expect(err).To(MatchError(ContainSubstring("has no missing key")))
+
+	a.Spec.Target.ClusterRef.Name = "unknown"
+	_, err = r.Resolve(ctx, a)
+	g.Expect(err).To(MatchError(ContainSubstring("failed to get cluster secret")))
+}
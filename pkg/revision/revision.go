@@ -0,0 +1,172 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package revision
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
+
+	addonmgrv1alpha1 "github.com/keikoproj/addon-manager/api/v1alpha1"
+	"github.com/keikoproj/addon-manager/pkg/common"
+)
+
+const (
+	// MaxRevisions is the number of applied spec revisions kept per addon
+	MaxRevisions = 10
+	// RollbackAnnotation requests a rollback of the addon spec to a revision number, or to the last applied spec
+	// that differs from the current spec with the value previous
+	RollbackAnnotation = "addonmgr.keikoproj.io/rollback-to"
+	// Previous is the RollbackAnnotation value rolling back to the last applied spec
+	Previous = "previous"
+
+	addonLabel    = "addonmgr.keikoproj.io/addon"
+	checksumLabel = "addonmgr.keikoproj.io/checksum"
+)
+
+// History keeps the applied specs of an addon as ControllerRevisions owned by the addon
+type History struct {
+	client kubernetes.Interface
+}
+
+// NewHistory returns a History backed by ControllerRevisions
+func NewHistory(client kubernetes.Interface) *History {
+	return &History{client: client}
+}
+
+// Record saves the spec of an applied addon as the latest revision and prunes revisions over MaxRevisions.
+// Applying the spec of an older revision again makes it the latest revision.
+func (h *History) Record(ctx context.Context, addon *addonmgrv1alpha1.Addon) (*appsv1.ControllerRevision, error) {
+	revisions, err := h.List(ctx, addon)
+	if err != nil {
+		return nil, err
+	}
+
+	checksum := addon.CalculateChecksum()
+	var next int64 = 1
+	if len(revisions) > 0 {
+		latest := revisions[len(revisions)-1]
+		if latest.Labels[checksumLabel] == checksum {
+			return &latest, nil
+		}
+		next = latest.Revision + 1
+	}
+
+	crs := h.client.AppsV1().ControllerRevisions(addon.Namespace)
+	var recorded *appsv1.ControllerRevision
+	for i := range revisions {
+		if revisions[i].Labels[checksumLabel] == checksum {
+			revisions[i].Revision = next
+			if recorded, err = crs.Update(ctx, &revisions[i], metav1.UpdateOptions{}); err != nil {
+				return nil, err
+			}
+			revisions = append(revisions[:i], revisions[i+1:]...)
+			break
+		}
+	}
+
+	if recorded == nil {
+		spec, err := json.Marshal(addon.Spec)
+		if err != nil {
+			return nil, err
+		}
+		cr := &appsv1.ControllerRevision{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      fmt.Sprintf("%s-%s", addon.Name, checksum),
+				Namespace: addon.Namespace,
+				Labels: map[string]string{
+					addonLabel:                     addon.Name,
+					checksumLabel:                  checksum,
+					"app.kubernetes.io/managed-by": common.AddonGVR().Group,
+				},
+				OwnerReferences: []metav1.OwnerReference{{
+					APIVersion: common.AddonGVR().GroupVersion().String(),
+					Kind:       "Addon",
+					Name:       addon.Name,
+					UID:        addon.UID,
+				}},
+			},
+			Data:     runtime.RawExtension{Raw: spec},
+			Revision: next,
+		}
+		if recorded, err = crs.Create(ctx, cr, metav1.CreateOptions{}); err != nil {
+			return nil, err
+		}
+	}
+
+	// The recorded revision was removed from revisions
+	for len(revisions) >= MaxRevisions {
+		if err := crs.Delete(ctx, revisions[0].Name, metav1.DeleteOptions{}); err != nil {
+			return nil, err
+		}
+		revisions = revisions[1:]
+	}
+
+	return recorded, nil
+}
+
+// List returns the revisions of the addon, oldest first
+func (h *History) List(ctx context.Context, addon *addonmgrv1alpha1.Addon) ([]appsv1.ControllerRevision, error) {
+	list, err := h.client.AppsV1().ControllerRevisions(addon.Namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("%s=%s", addonLabel, addon.Name),
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(list.Items, func(i, j int) bool { return list.Items[i].Revision < list.Items[j].Revision })
+	return list.Items, nil
+}
+
+// Find returns the spec of the revision the rollback target names, a revision number or Previous
+func (h *History) Find(ctx context.Context, addon *addonmgrv1alpha1.Addon, target string) (int64, *addonmgrv1alpha1.AddonSpec, error) {
+	revisions, err := h.List(ctx, addon)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	checksum := addon.CalculateChecksum()
+	for i := len(revisions) - 1; i >= 0; i-- {
+		cr := revisions[i]
+		if target == Previous && cr.Labels[checksumLabel] == checksum {
+			continue
+		}
+		if target != Previous && strconv.FormatInt(cr.Revision, 10) != target {
+			continue
+		}
+
+		spec := &addonmgrv1alpha1.AddonSpec{}
+		if err := json.Unmarshal(cr.Data.Raw, spec); err != nil {
+			return 0, nil, fmt.Errorf("invalid revision %d. %v", cr.Revision, err)
+		}
+		return cr.Revision, spec, nil
+	}
+	return 0, nil, &NotFoundError{Target: target}
+}
+
+// NotFoundError is returned when the addon has no revision matching a rollback target
+type NotFoundError struct {
+	Target string
+}
+
+func (e *NotFoundError) Error() string {
+	return fmt.Sprintf("revision %s not found", e.Target)
+}
@@ -0,0 +1,96 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package revision
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	addonmgrv1alpha1 "github.com/keikoproj/addon-manager/api/v1alpha1"
+)
+
+func newRevisionAddon(version string) *addonmgrv1alpha1.Addon {
+	return &addonmgrv1alpha1.Addon{
+		ObjectMeta: metav1.ObjectMeta{Name: "ingress", Namespace: "addon-manager-system", UID: "uid"},
+		Spec: addonmgrv1alpha1.AddonSpec{
+			PackageSpec: addonmgrv1alpha1.PackageSpec{PkgName: "core/ingress", PkgVersion: version, PkgType: addonmgrv1alpha1.CompositePkg},
+			Params:      addonmgrv1alpha1.AddonParams{Namespace: "ingress"},
+		},
+	}
+}
+
+func TestHistory_Record(t *testing.T) {
+	g := NewGomegaWithT(t)
+	ctx := context.TODO()
+	h := NewHistory(fake.NewSimpleClientset())
+
+	for i, version := range []string{"1.0.0", "1.1.0", "1.1.0", "1.0.0"} {
+		_, err := h.Record(ctx, newRevisionAddon(version))
+		g.Expect(err).ToNot(HaveOccurred(), "record %d", i)
+	}
+
+	// Applying 1.0.0 again moves it to the latest revision
+	revisions, err := h.List(ctx, newRevisionAddon(""))
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(revisions).To(HaveLen(2))
+	g.Expect(revisions[0].Revision).To(BeEquivalentTo(2))
+	g.Expect(revisions[1].Revision).To(BeEquivalentTo(3))
+	g.Expect(revisions[1].Name).To(Equal("ingress-" + newRevisionAddon("1.0.0").CalculateChecksum()))
+	g.Expect(revisions[1].OwnerReferences).To(HaveLen(1))
+
+	for i := 0; i < MaxRevisions+3; i++ {
+		_, err := h.Record(ctx, newRevisionAddon(fmt.Sprintf("2.0.%d", i)))
+		g.Expect(err).ToNot(HaveOccurred())
+	}
+	revisions, err = h.List(ctx, newRevisionAddon(""))
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(revisions).To(HaveLen(MaxRevisions))
+	g.Expect(revisions[MaxRevisions-1].Revision).To(BeEquivalentTo(MaxRevisions + 6))
+}
+
+func TestHistory_Find(t *testing.T) {
+	g := NewGomegaWithT(t)
+	ctx := context.TODO()
+	h := NewHistory(fake.NewSimpleClientset())
+
+	for _, version := range []string{"1.0.0", "1.1.0"} {
+		_, err := h.Record(ctx, newRevisionAddon(version))
+		g.Expect(err).ToNot(HaveOccurred())
+	}
+
+	// Previous skips the revision of the current spec
+	number, spec, err := h.Find(ctx, newRevisionAddon("1.1.0"), Previous)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(number).To(BeEquivalentTo(1))
+	g.Expect(spec.PkgVersion).To(Equal("1.0.0"))
+
+	// The last applied spec when the current spec was not applied
+	number, spec, err = h.Find(ctx, newRevisionAddon("1.2.0"), Previous)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(number).To(BeEquivalentTo(2))
+	g.Expect(spec.PkgVersion).To(Equal("1.1.0"))
+
+	_, spec, err = h.Find(ctx, newRevisionAddon("1.1.0"), "1")
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(spec.PkgVersion).To(Equal("1.0.0"))
+
+	_, _, err = h.Find(ctx, newRevisionAddon("1.1.0"), "7")
+	g.Expect(err).To(Equal(&NotFoundError{Target: "7"}))
+}
@@ -0,0 +1,87 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package secrets
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"sigs.k8s.io/yaml"
+
+	addonmgrv1alpha1 "github.com/keikoproj/addon-manager/api/v1alpha1"
+)
+
+// SourceRule allows the addons of a namespace to read external secrets into target namespaces. Patterns ending
+// with * match prefixes, * alone matches everything.
+type SourceRule struct {
+	// Namespace of the addons the rule applies to
+	Namespace string `json:"namespace"`
+	// Vault secret paths the addons may read
+	Vault []string `json:"vault,omitempty"`
+	// AWSSecretsManager secret IDs or ARNs the addons may read
+	AWSSecretsManager []string `json:"awsSecretsManager,omitempty"`
+	// TargetNamespaces the secrets may be created in
+	TargetNamespaces []string `json:"targetNamespaces,omitempty"`
+}
+
+// SourcePolicy is the list of rules external secret sources must be allowed by, an empty policy allows none
+type SourcePolicy []SourceRule
+
+// LoadSourcePolicy reads a YAML list of source rules from file
+func LoadSourcePolicy(file string) (SourcePolicy, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var policy SourcePolicy
+	if err := yaml.Unmarshal(data, &policy); err != nil {
+		return nil, fmt.Errorf("invalid secret source policy %s. %v", file, err)
+	}
+	return policy, nil
+}
+
+// Allowed returns an error unless a rule for the namespace of the addon allows reading src into its target namespace
+func (p SourcePolicy) Allowed(addon *addonmgrv1alpha1.Addon, src *addonmgrv1alpha1.SecretSource) error {
+	target := addon.Spec.Params.Namespace
+	for _, rule := range p {
+		if !matchPattern(rule.Namespace, addon.Namespace) || !matchAny(rule.TargetNamespaces, target) {
+			continue
+		}
+		if src.Vault != nil && matchAny(rule.Vault, src.Vault.Path) {
+			return nil
+		}
+		if src.AWSSecretsManager != nil && matchAny(rule.AWSSecretsManager, src.AWSSecretsManager.SecretID) {
+			return nil
+		}
+	}
+	return fmt.Errorf("source is not allowed for addons in namespace %s with target namespace %s by the secret source policy", addon.Namespace, target)
+}
+
+func matchAny(patterns []string, value string) bool {
+	for _, pattern := range patterns {
+		if matchPattern(pattern, value) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchPattern(pattern, value string) bool {
+	if strings.HasSuffix(pattern, "*") {
+		return strings.HasPrefix(value, strings.TrimSuffix(pattern, "*"))
+	}
+	return pattern == value
+}
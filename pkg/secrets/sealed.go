@@ -0,0 +1,139 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package secrets
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/dynamic"
+
+	addonmgrv1alpha1 "github.com/keikoproj/addon-manager/api/v1alpha1"
+	"github.com/keikoproj/addon-manager/pkg/common"
+)
+
+// partOfLabel is set on workflow artifacts to the name of the addon that applied them
+const partOfLabel = "app.kubernetes.io/part-of"
+
+// SetDynamicClient enables SealedSecret sources and waiting for SealedSecret artifacts to be unsealed
+func (m *Materializer) SetDynamicClient(dynClient dynamic.Interface) {
+	m.dynClient = dynClient
+}
+
+// applySealed creates or updates the SealedSecret the sealed-secrets controller unseals into the addon secret
+func (m *Materializer) applySealed(ctx context.Context, addon *addonmgrv1alpha1.Addon, name string, src *addonmgrv1alpha1.SealedSecretSource) error {
+	if m.dynClient == nil {
+		return fmt.Errorf("sealed secrets are not configured")
+	}
+
+	encrypted := map[string]interface{}{}
+	for k, v := range src.EncryptedData {
+		encrypted[k] = v
+	}
+
+	sealed := &unstructured.Unstructured{}
+	sealed.SetAPIVersion(common.SealedSecretGVR().GroupVersion().String())
+	sealed.SetKind("SealedSecret")
+	sealed.SetName(name)
+	sealed.SetNamespace(addon.Spec.Params.Namespace)
+	sealed.SetLabels(managedLabels(addon))
+	sealed.SetAnnotations(map[string]string{SourceAnnotation: "sealedSecret"})
+	if err := unstructured.SetNestedMap(sealed.Object, encrypted, "spec", "encryptedData"); err != nil {
+		return err
+	}
+
+	client := m.dynClient.Resource(common.SealedSecretGVR()).Namespace(addon.Spec.Params.Namespace)
+	existing, err := client.Get(ctx, name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		_, err = client.Create(ctx, sealed, metav1.CreateOptions{})
+		return err
+	}
+	if err != nil {
+		return err
+	}
+
+	// Never take over sealed secrets that were created by someone else
+	if !managedBy(existing.GetLabels(), addon) {
+		return fmt.Errorf("sealed secret %s/%s exists and is not managed by addon %s", existing.GetNamespace(), name, addon.Name)
+	}
+	labels := existing.GetLabels()
+	labels[AddonNamespaceLabel] = addon.Namespace
+	existing.SetLabels(labels)
+	if err := unstructured.SetNestedMap(existing.Object, encrypted, "spec", "encryptedData"); err != nil {
+		return err
+	}
+	_, err = client.Update(ctx, existing, metav1.UpdateOptions{})
+	return err
+}
+
+// PendingUnseal returns the names of the addon SealedSecrets, from secret sources or workflow artifacts,
+// that have not been unsealed into a secret yet. An error is returned when the controller failed to unseal one.
+func (m *Materializer) PendingUnseal(ctx context.Context, addon *addonmgrv1alpha1.Addon) ([]string, error) {
+	if m.dynClient == nil || addon.Spec.Params.Namespace == "" {
+		return nil, nil
+	}
+
+	client := m.dynClient.Resource(common.SealedSecretGVR()).Namespace(addon.Spec.Params.Namespace)
+	sealed := map[string]unstructured.Unstructured{}
+	for _, selector := range []string{AddonLabel + "=" + addon.Name, partOfLabel + "=" + addon.Name} {
+		list, err := client.List(ctx, metav1.ListOptions{LabelSelector: selector})
+		if apierrors.IsNotFound(err) {
+			// SealedSecret CRD is not installed
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range list.Items {
+			sealed[item.GetName()] = item
+		}
+	}
+
+	var pending []string
+	for name, item := range sealed {
+		if reason, failed := unsealFailed(&item); failed {
+			return nil, fmt.Errorf("sealed secret %s/%s could not be unsealed. %s", item.GetNamespace(), name, reason)
+		}
+
+		_, err := m.client.CoreV1().Secrets(addon.Spec.Params.Namespace).Get(ctx, name, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			pending = append(pending, name)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	sort.Strings(pending)
+	return pending, nil
+}
+
+// unsealFailed returns the message of a false Synced condition set by the sealed-secrets controller
+func unsealFailed(sealed *unstructured.Unstructured) (string, bool) {
+	conditions, _, _ := unstructured.NestedSlice(sealed.Object, "status", "conditions")
+	for _, c := range conditions {
+		condition, ok := c.(map[string]interface{})
+		if !ok || condition["type"] != "Synced" || condition["status"] != "False" {
+			continue
+		}
+		msg, _ := condition["message"].(string)
+		return msg, true
+	}
+	return "", false
+}
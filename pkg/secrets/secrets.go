@@ -0,0 +1,414 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package secrets
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/clock"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+
+	addonmgrv1alpha1 "github.com/keikoproj/addon-manager/api/v1alpha1"
+	"github.com/keikoproj/addon-manager/pkg/common"
+	"github.com/keikoproj/addon-manager/pkg/redact"
+)
+
+const (
+	// AddonLabel marks secrets materialized from an external store with the addon that owns them
+	AddonLabel = "addonmgr.keikoproj.io/addon"
+	// AddonNamespaceLabel is the namespace of the addon that owns a materialized secret
+	AddonNamespaceLabel = "addonmgr.keikoproj.io/addon-namespace"
+	// SourceAnnotation records the external store a materialized secret was fetched from
+	SourceAnnotation = "addonmgr.keikoproj.io/secret-source"
+	// DefaultRefreshInterval is how often unchanged secrets are fetched again from their stores
+	DefaultRefreshInterval = time.Hour
+)
+
+// Store fetches secret data from an external secret store
+type Store interface {
+	Fetch(ctx context.Context, src *addonmgrv1alpha1.SecretSource) (map[string][]byte, error)
+}
+
+// Materializer creates the Kubernetes secrets of an addon from external secret stores
+type Materializer struct {
+	sync.Mutex
+	client         kubernetes.Interface
+	vault          Store
+	secretsManager Store
+	redactor       *redact.Redactor
+	dynClient      dynamic.Interface
+	policy         SourcePolicy
+	refresh        time.Duration
+	clock          clock.PassiveClock
+	// fetched holds the data of the external secrets of an addon, keyed by addon and secrets checksum
+	fetched map[string]*fetched
+}
+
+// NewMaterializer returns a Materializer, stores that are not configured may be nil
+func NewMaterializer(client kubernetes.Interface, vault, secretsManager Store) *Materializer {
+	return &Materializer{
+		client:         client,
+		vault:          vault,
+		secretsManager: secretsManager,
+		refresh:        DefaultRefreshInterval,
+		clock:          clock.RealClock{},
+		fetched:        make(map[string]*fetched),
+	}
+}
+
+// SetSourcePolicy sets the rules external secret sources must be allowed by, by default no source is allowed
+func (m *Materializer) SetSourcePolicy(p SourcePolicy) {
+	m.policy = p
+}
+
+// SetRefreshInterval sets how often unchanged secrets are fetched again from their stores
+func (m *Materializer) SetRefreshInterval(d time.Duration) {
+	m.refresh = d
+}
+
+// SetRedactor registers fetched secret values with r so they are masked in logs, events and status
+func (m *Materializer) SetRedactor(r *redact.Redactor) {
+	m.redactor = r
+}
+
+// Digest fetches the addon secrets with a Vault or AWS Secrets Manager source and returns the digest of their data,
+// empty when there are none. Stores are only read again when the secrets of the spec changed or the refresh interval
+// passed.
+func (m *Materializer) Digest(ctx context.Context, addon *addonmgrv1alpha1.Addon) (string, error) {
+	f, err := m.fetch(ctx, addon)
+	if err != nil {
+		return "", err
+	}
+	return f.digest, nil
+}
+
+// Materialize creates or updates every addon secret with an external source in the addon namespace, secrets no
+// longer in the spec are deleted. Secrets are only written again when their fetched data or the spec changed.
+func (m *Materializer) Materialize(ctx context.Context, addon *addonmgrv1alpha1.Addon) error {
+	f, err := m.fetch(ctx, addon)
+	if err != nil {
+		return err
+	}
+	if f.applied {
+		return nil
+	}
+
+	var names []string
+	for _, secret := range addon.Spec.Secrets {
+		if secret.From == nil {
+			continue
+		}
+		names = append(names, secret.Name)
+
+		if secret.From.SealedSecret != nil {
+			if err := m.applySealed(ctx, addon, secret.Name, secret.From.SealedSecret); err != nil {
+				return fmt.Errorf("sealed secret %s could not be created. %v", secret.Name, err)
+			}
+			continue
+		}
+
+		source := f.sources[secret.Name]
+		if err := m.apply(ctx, addon, secret.Name, source, f.data[secret.Name]); err != nil {
+			return fmt.Errorf("secret %s could not be created. %v", secret.Name, err)
+		}
+	}
+
+	if err := m.deleteManaged(ctx, addon, names); err != nil {
+		return err
+	}
+
+	m.Lock()
+	f.applied = true
+	m.Unlock()
+	return nil
+}
+
+// fetched is the data of the external secrets of an addon spec
+type fetched struct {
+	at      time.Time
+	data    map[string]map[string][]byte
+	sources map[string]string
+	digest  string
+	// applied is true once the data was written to the addon namespace
+	applied bool
+}
+
+// fetch returns the data of the external secrets of the addon, it is read from the stores when the secrets of the
+// spec changed or the refresh interval passed
+func (m *Materializer) fetch(ctx context.Context, addon *addonmgrv1alpha1.Addon) (*fetched, error) {
+	key := materializedKey(addon)
+	m.Lock()
+	f, ok := m.fetched[key]
+	m.Unlock()
+	if ok && m.clock.Since(f.at) < m.refresh {
+		return f, nil
+	}
+
+	f = &fetched{at: m.clock.Now(), data: map[string]map[string][]byte{}, sources: map[string]string{}}
+	for _, secret := range addon.Spec.Secrets {
+		if secret.From == nil {
+			continue
+		}
+		if secret.From.SealedSecret != nil {
+			if secret.From.Vault != nil || secret.From.AWSSecretsManager != nil {
+				return nil, fmt.Errorf("secret %s: only one of vault, awsSecretsManager or sealedSecret may be set", secret.Name)
+			}
+			continue
+		}
+
+		store, source, err := m.storeFor(secret.From)
+		if err != nil {
+			return nil, fmt.Errorf("secret %s: %v", secret.Name, err)
+		}
+		if err := m.policy.Allowed(addon, secret.From); err != nil {
+			return nil, fmt.Errorf("secret %s: %s %v", secret.Name, source, err)
+		}
+
+		data, err := store.Fetch(ctx, secret.From)
+		if err != nil {
+			return nil, fmt.Errorf("secret %s could not be fetched from %s. %v", secret.Name, source, err)
+		}
+		for _, v := range data {
+			m.redactor.Add(string(v))
+		}
+		f.data[secret.Name] = data
+		f.sources[secret.Name] = source
+	}
+	f.digest = dataDigest(f.data)
+
+	m.Lock()
+	defer m.Unlock()
+	// Secrets are written again when their data changed
+	if prev, ok := m.fetched[key]; ok && f.digest == prev.digest {
+		f.applied = prev.applied
+	}
+	m.forget(addon)
+	m.fetched[key] = f
+	return f, nil
+}
+
+// forget drops the fetched data of every spec of the addon, the lock must be held
+func (m *Materializer) forget(addon *addonmgrv1alpha1.Addon) {
+	prefix := addon.Namespace + "/" + addon.Name + "/"
+	for key := range m.fetched {
+		if strings.HasPrefix(key, prefix) {
+			delete(m.fetched, key)
+		}
+	}
+}
+
+// dataDigest returns the digest of the fetched data, empty when there is none
+func dataDigest(data map[string]map[string][]byte) string {
+	if len(data) == 0 {
+		return ""
+	}
+	h := sha256.New()
+	for _, name := range sortedKeys(data) {
+		fmt.Fprintf(h, "%d:%s", len(name), name)
+		values := data[name]
+		keys := make([]string, 0, len(values))
+		for k := range values {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			fmt.Fprintf(h, "%d:%s%d:%s", len(k), k, len(values[k]), values[k])
+		}
+	}
+	return fmt.Sprintf("sha256:%x", h.Sum(nil))
+}
+
+func sortedKeys(data map[string]map[string][]byte) []string {
+	keys := make([]string, 0, len(data))
+	for k := range data {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Cleanup deletes the secrets and sealed secrets materialized for the addon
+func (m *Materializer) Cleanup(ctx context.Context, addon *addonmgrv1alpha1.Addon) error {
+	if err := m.deleteManaged(ctx, addon, nil); err != nil {
+		return err
+	}
+	m.Lock()
+	defer m.Unlock()
+	m.forget(addon)
+	return nil
+}
+
+// deleteManaged deletes the secrets and sealed secrets materialized for the addon except those named keep
+func (m *Materializer) deleteManaged(ctx context.Context, addon *addonmgrv1alpha1.Addon, keep []string) error {
+	ns := addon.Spec.Params.Namespace
+	if ns == "" {
+		return nil
+	}
+	selector := metav1.ListOptions{LabelSelector: AddonLabel + "=" + addon.Name}
+
+	secrets, err := m.client.CoreV1().Secrets(ns).List(ctx, selector)
+	if err != nil {
+		return err
+	}
+	for _, secret := range secrets.Items {
+		if !managedBy(secret.Labels, addon) || common.ContainsString(keep, secret.Name) {
+			continue
+		}
+		if err := m.client.CoreV1().Secrets(ns).Delete(ctx, secret.Name, metav1.DeleteOptions{}); err != nil && !apierrors.IsNotFound(err) {
+			return fmt.Errorf("secret %s/%s could not be deleted. %v", ns, secret.Name, err)
+		}
+	}
+
+	if m.dynClient == nil {
+		return nil
+	}
+	client := m.dynClient.Resource(common.SealedSecretGVR()).Namespace(ns)
+	sealed, err := client.List(ctx, selector)
+	if apierrors.IsNotFound(err) {
+		// SealedSecret CRD is not installed
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	for _, item := range sealed.Items {
+		if !managedBy(item.GetLabels(), addon) || common.ContainsString(keep, item.GetName()) {
+			continue
+		}
+		if err := client.Delete(ctx, item.GetName(), metav1.DeleteOptions{}); err != nil && !apierrors.IsNotFound(err) {
+			return fmt.Errorf("sealed secret %s/%s could not be deleted. %v", ns, item.GetName(), err)
+		}
+	}
+	return nil
+}
+
+// managedBy returns true when the labels mark an object materialized for the addon, objects materialized before
+// the namespace label was added are matched by addon name
+func managedBy(labels map[string]string, addon *addonmgrv1alpha1.Addon) bool {
+	ns, ok := labels[AddonNamespaceLabel]
+	return labels[AddonLabel] == addon.Name && (!ok || ns == addon.Namespace)
+}
+
+// managedLabels returns the labels of objects materialized for the addon
+func managedLabels(addon *addonmgrv1alpha1.Addon) map[string]string {
+	return map[string]string{AddonLabel: addon.Name, AddonNamespaceLabel: addon.Namespace}
+}
+
+// materializedKey identifies the secrets of the addon spec
+func materializedKey(addon *addonmgrv1alpha1.Addon) string {
+	spec, _ := json.Marshal(struct {
+		Namespace string
+		Secrets   []addonmgrv1alpha1.SecretCmdSpec
+	}{addon.Spec.Params.Namespace, addon.Spec.Secrets})
+	return fmt.Sprintf("%s/%s/%x", addon.Namespace, addon.Name, sha256.Sum256(spec))
+}
+
+func (m *Materializer) storeFor(src *addonmgrv1alpha1.SecretSource) (Store, string, error) {
+	switch {
+	case src.Vault != nil && src.AWSSecretsManager != nil:
+		return nil, "", fmt.Errorf("only one of vault or awsSecretsManager may be set")
+	case src.Vault != nil:
+		if m.vault == nil {
+			return nil, "", fmt.Errorf("vault is not configured")
+		}
+		return m.vault, "vault:" + src.Vault.Path, nil
+	case src.AWSSecretsManager != nil:
+		if m.secretsManager == nil {
+			return nil, "", fmt.Errorf("aws secrets manager is not configured")
+		}
+		return m.secretsManager, "awsSecretsManager:" + src.AWSSecretsManager.SecretID, nil
+	default:
+		return nil, "", fmt.Errorf("secret source has no store")
+	}
+}
+
+func (m *Materializer) apply(ctx context.Context, addon *addonmgrv1alpha1.Addon, name, source string, data map[string][]byte) error {
+	secrets := m.client.CoreV1().Secrets(addon.Spec.Params.Namespace)
+
+	existing, err := secrets.Get(ctx, name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		_, err = secrets.Create(ctx, &v1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        name,
+				Namespace:   addon.Spec.Params.Namespace,
+				Labels:      managedLabels(addon),
+				Annotations: map[string]string{SourceAnnotation: source},
+			},
+			Type: v1.SecretTypeOpaque,
+			Data: data,
+		}, metav1.CreateOptions{})
+		return err
+	}
+	if err != nil {
+		return err
+	}
+
+	// Never take over secrets that were created by someone else
+	if !managedBy(existing.Labels, addon) {
+		return fmt.Errorf("secret %s/%s exists and is not managed by addon %s", existing.Namespace, name, addon.Name)
+	}
+
+	if reflect.DeepEqual(existing.Data, data) && existing.Labels[AddonNamespaceLabel] == addon.Namespace && existing.Annotations[SourceAnnotation] == source {
+		return nil
+	}
+	existing.Data = data
+	existing.Labels[AddonNamespaceLabel] = addon.Namespace
+	if existing.Annotations == nil {
+		existing.Annotations = map[string]string{}
+	}
+	existing.Annotations[SourceAnnotation] = source
+	_, err = secrets.Update(ctx, existing, metav1.UpdateOptions{})
+	return err
+}
+
+// selectKeys returns the requested keys of the fetched values, or all values when no keys are requested
+func selectKeys(values map[string]interface{}, keys []string) (map[string][]byte, error) {
+	if len(keys) == 0 {
+		for k := range values {
+			keys = append(keys, k)
+		}
+	}
+
+	data := make(map[string][]byte, len(keys))
+	for _, k := range keys {
+		v, ok := values[k]
+		if !ok {
+			return nil, fmt.Errorf("key %q not found", k)
+		}
+		switch val := v.(type) {
+		case string:
+			data[k] = []byte(val)
+		default:
+			b, err := json.Marshal(val)
+			if err != nil {
+				return nil, err
+			}
+			data[k] = b
+		}
+	}
+
+	return data, nil
+}
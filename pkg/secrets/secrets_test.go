@@ -0,0 +1,390 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	. "github.com/onsi/gomega"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/clock"
+	dynfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+
+	addonmgrv1alpha1 "github.com/keikoproj/addon-manager/api/v1alpha1"
+	"github.com/keikoproj/addon-manager/pkg/common"
+)
+
+type staticStore map[string][]byte
+
+func (s staticStore) Fetch(context.Context, *addonmgrv1alpha1.SecretSource) (map[string][]byte, error) {
+	return s, nil
+}
+
+func newSecretsAddon() *addonmgrv1alpha1.Addon {
+	return &addonmgrv1alpha1.Addon{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "secrets-test",
+			Namespace: "default",
+		},
+		Spec: addonmgrv1alpha1.AddonSpec{
+			Params: addonmgrv1alpha1.AddonParams{
+				Namespace: "secrets-ns",
+			},
+			Secrets: []addonmgrv1alpha1.SecretCmdSpec{
+				{Name: "existing"},
+				{Name: "from-vault", From: &addonmgrv1alpha1.SecretSource{
+					Vault: &addonmgrv1alpha1.VaultSecretSource{Path: "secret/data/app"},
+				}},
+			},
+		},
+	}
+}
+
+var testPolicy = SourcePolicy{
+	{Namespace: "default", TargetNamespaces: []string{"secrets-ns"}, Vault: []string{"secret/data/*"}, AWSSecretsManager: []string{"app"}},
+}
+
+func newTestMaterializer(client *fake.Clientset, vault, secretsManager Store) *Materializer {
+	m := NewMaterializer(client, vault, secretsManager)
+	m.SetSourcePolicy(testPolicy)
+	return m
+}
+
+func TestMaterializer_Materialize(t *testing.T) {
+	g := NewGomegaWithT(t)
+	ctx := context.TODO()
+	client := fake.NewSimpleClientset()
+	a := newSecretsAddon()
+
+	m := newTestMaterializer(client, staticStore{"password": []byte("s3cret")}, nil)
+	g.Expect(m.Materialize(ctx, a)).To(Succeed())
+
+	secret, err := client.CoreV1().Secrets("secrets-ns").Get(ctx, "from-vault", metav1.GetOptions{})
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(secret.Data).To(HaveKeyWithValue("password", []byte("s3cret")))
+	g.Expect(secret.Labels).To(HaveKeyWithValue(AddonLabel, "secrets-test"))
+	g.Expect(secret.Labels).To(HaveKeyWithValue(AddonNamespaceLabel, "default"))
+	g.Expect(secret.Annotations).To(HaveKeyWithValue(SourceAnnotation, "vault:secret/data/app"))
+
+	// Updates secrets it manages
+	m = newTestMaterializer(client, staticStore{"password": []byte("rotated")}, nil)
+	g.Expect(m.Materialize(ctx, a)).To(Succeed())
+	secret, _ = client.CoreV1().Secrets("secrets-ns").Get(ctx, "from-vault", metav1.GetOptions{})
+	g.Expect(secret.Data).To(HaveKeyWithValue("password", []byte("rotated")))
+
+	// Unconfigured stores fail
+	a.Spec.Secrets[1].From = &addonmgrv1alpha1.SecretSource{
+		AWSSecretsManager: &addonmgrv1alpha1.AWSSecretsManagerSource{SecretID: "app"},
+	}
+	g.Expect(m.Materialize(ctx, a)).To(HaveOccurred())
+}
+
+func TestMaterializer_DoesNotTakeOverSecrets(t *testing.T) {
+	g := NewGomegaWithT(t)
+	client := fake.NewSimpleClientset(&v1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "from-vault", Namespace: "secrets-ns"}})
+
+	m := newTestMaterializer(client, staticStore{"password": []byte("s3cret")}, nil)
+	g.Expect(m.Materialize(context.TODO(), newSecretsAddon())).To(MatchError(ContainSubstring("not managed by addon")))
+
+	// Secrets of an addon with the same name in another namespace are not taken over either
+	client = fake.NewSimpleClientset(&v1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "from-vault", Namespace: "secrets-ns",
+		Labels: map[string]string{AddonLabel: "secrets-test", AddonNamespaceLabel: "other"}}})
+	m = newTestMaterializer(client, staticStore{"password": []byte("s3cret")}, nil)
+	g.Expect(m.Materialize(context.TODO(), newSecretsAddon())).To(MatchError(ContainSubstring("not managed by addon")))
+}
+
+func TestMaterializer_SourcePolicy(t *testing.T) {
+	g := NewGomegaWithT(t)
+	ctx := context.TODO()
+	client := fake.NewSimpleClientset()
+	store := staticStore{"password": []byte("s3cret")}
+
+	// No source is allowed without a policy
+	g.Expect(NewMaterializer(client, store, nil).Materialize(ctx, newSecretsAddon())).To(MatchError(ContainSubstring("not allowed")))
+
+	m := newTestMaterializer(client, store, nil)
+	a := newSecretsAddon()
+	a.Spec.Secrets[1].From.Vault.Path = "kv/data/other"
+	g.Expect(m.Materialize(ctx, a)).To(MatchError(ContainSubstring("not allowed")))
+
+	a = newSecretsAddon()
+	a.Spec.Params.Namespace = "kube-system"
+	g.Expect(m.Materialize(ctx, a)).To(MatchError(ContainSubstring("not allowed")))
+
+	a = newSecretsAddon()
+	a.Namespace = "team-a"
+	g.Expect(m.Materialize(ctx, a)).To(MatchError(ContainSubstring("not allowed")))
+
+	_, err := client.CoreV1().Secrets("secrets-ns").Get(ctx, "from-vault", metav1.GetOptions{})
+	g.Expect(err).To(HaveOccurred())
+}
+
+type countingStore struct {
+	staticStore
+	fetches int
+}
+
+func (s *countingStore) Fetch(ctx context.Context, src *addonmgrv1alpha1.SecretSource) (map[string][]byte, error) {
+	s.fetches++
+	return s.staticStore.Fetch(ctx, src)
+}
+
+func TestMaterializer_Refresh(t *testing.T) {
+	g := NewGomegaWithT(t)
+	ctx := context.TODO()
+	client := fake.NewSimpleClientset()
+	store := &countingStore{staticStore: staticStore{"password": []byte("s3cret")}}
+	now := clock.NewFakeClock(time.Date(2020, 6, 1, 10, 0, 0, 0, time.UTC))
+
+	m := newTestMaterializer(client, store, nil)
+	m.clock = now
+	m.SetRefreshInterval(10 * time.Minute)
+	a := newSecretsAddon()
+	g.Expect(m.Materialize(ctx, a)).To(Succeed())
+	g.Expect(m.Materialize(ctx, a)).To(Succeed())
+	g.Expect(store.fetches).To(Equal(1))
+
+	// A changed source is fetched right away
+	a.Spec.Secrets[1].From.Vault.Keys = []string{"password"}
+	g.Expect(m.Materialize(ctx, a)).To(Succeed())
+	g.Expect(store.fetches).To(Equal(2))
+
+	// Unchanged sources are fetched again after the refresh interval
+	now.Step(5 * time.Minute)
+	g.Expect(m.Materialize(ctx, a)).To(Succeed())
+	g.Expect(store.fetches).To(Equal(2))
+	now.Step(5 * time.Minute)
+	g.Expect(m.Materialize(ctx, a)).To(Succeed())
+	g.Expect(store.fetches).To(Equal(3))
+}
+
+func TestMaterializer_Digest(t *testing.T) {
+	g := NewGomegaWithT(t)
+	ctx := context.TODO()
+	client := fake.NewSimpleClientset()
+	store := &countingStore{staticStore: staticStore{"password": []byte("s3cret")}}
+	now := clock.NewFakeClock(time.Date(2020, 6, 1, 10, 0, 0, 0, time.UTC))
+
+	m := newTestMaterializer(client, store, nil)
+	m.clock = now
+	a := newSecretsAddon()
+	digest, err := m.Digest(ctx, a)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(digest).To(HavePrefix("sha256:"))
+
+	// The data fetched for the digest is materialized without fetching it again
+	g.Expect(m.Materialize(ctx, a)).To(Succeed())
+	g.Expect(store.fetches).To(Equal(1))
+	client.ClearActions()
+	g.Expect(m.Materialize(ctx, a)).To(Succeed())
+	g.Expect(client.Actions()).To(BeEmpty())
+
+	// Rotated secrets change the digest once they are fetched again
+	store.staticStore = staticStore{"password": []byte("rotated")}
+	now.Step(DefaultRefreshInterval)
+	rotated, err := m.Digest(ctx, a)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(rotated).ToNot(Equal(digest))
+	g.Expect(m.Materialize(ctx, a)).To(Succeed())
+	secret, err := client.CoreV1().Secrets("secrets-ns").Get(ctx, "from-vault", metav1.GetOptions{})
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(secret.Data).To(HaveKeyWithValue("password", []byte("rotated")))
+
+	// Addons without external secrets have no digest
+	a.Spec.Secrets = a.Spec.Secrets[:1]
+	g.Expect(m.Digest(ctx, a)).To(BeEmpty())
+}
+
+func TestMaterializer_Cleanup(t *testing.T) {
+	g := NewGomegaWithT(t)
+	ctx := context.TODO()
+	unmanaged := &v1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "other", Namespace: "secrets-ns"}}
+	client := fake.NewSimpleClientset(unmanaged)
+
+	m := newTestMaterializer(client, staticStore{"password": []byte("s3cret")}, nil)
+	a := newSecretsAddon()
+	a.Spec.Secrets = append(a.Spec.Secrets, addonmgrv1alpha1.SecretCmdSpec{Name: "removed", From: &addonmgrv1alpha1.SecretSource{
+		Vault: &addonmgrv1alpha1.VaultSecretSource{Path: "secret/data/removed"},
+	}})
+	g.Expect(m.Materialize(ctx, a)).To(Succeed())
+
+	// Secrets removed from the spec are deleted
+	a.Spec.Secrets = a.Spec.Secrets[:2]
+	g.Expect(m.Materialize(ctx, a)).To(Succeed())
+	list, err := client.CoreV1().Secrets("secrets-ns").List(ctx, metav1.ListOptions{})
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(list.Items).To(HaveLen(2))
+
+	g.Expect(m.Cleanup(ctx, a)).To(Succeed())
+	list, err = client.CoreV1().Secrets("secrets-ns").List(ctx, metav1.ListOptions{})
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(list.Items).To(ConsistOf(*unmanaged))
+}
+
+func TestVaultStore_Fetch(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	dir, err := ioutil.TempDir("", "secrets")
+	g.Expect(err).ToNot(HaveOccurred())
+	defer os.RemoveAll(dir)
+	tokenFile := filepath.Join(dir, "token")
+	g.Expect(ioutil.WriteFile(tokenFile, []byte("sa-jwt"), 0600)).To(Succeed())
+
+	var logins int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/auth/kubernetes/login":
+			logins++
+			var body map[string]string
+			g.Expect(json.NewDecoder(r.Body).Decode(&body)).To(Succeed())
+			g.Expect(body).To(Equal(map[string]string{"role": "addons", "jwt": "sa-jwt"}))
+			fmt.Fprint(w, `{"auth":{"client_token":"vault-token","lease_duration":3600}}`)
+		case "/v1/secret/data/app":
+			g.Expect(r.Header.Get("X-Vault-Token")).To(Equal("vault-token"))
+			fmt.Fprint(w, `{"data":{"data":{"user":"admin","password":"s3cret","port":5432},"metadata":{"version":3}}}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	v := NewVaultStore(srv.URL, "addons", "kubernetes").(*vaultStore)
+	v.tokenFile = tokenFile
+	src := &addonmgrv1alpha1.SecretSource{Vault: &addonmgrv1alpha1.VaultSecretSource{Path: "secret/data/app"}}
+
+	data, err := v.Fetch(context.TODO(), src)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(data).To(Equal(map[string][]byte{"user": []byte("admin"), "password": []byte("s3cret"), "port": []byte("5432")}))
+
+	src.Vault.Keys = []string{"password"}
+	data, err = v.Fetch(context.TODO(), src)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(data).To(HaveLen(1))
+	g.Expect(logins).To(Equal(1))
+
+	src.Vault.Keys = []string{"missing"}
+	_, err = v.Fetch(context.TODO(), src)
+	g.Expect(err).To(HaveOccurred())
+}
+
+func TestSecretsManagerStore_Fetch(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	secretString := `{"user":"admin","password":"s3cret"}`
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		g.Expect(r.Header.Get("X-Amz-Target")).To(Equal("secretsmanager.GetSecretValue"))
+		g.Expect(r.Header.Get("Authorization")).To(ContainSubstring("AKID/"))
+		g.Expect(r.Header.Get("Authorization")).To(ContainSubstring("/us-west-2/secretsmanager/aws4_request"))
+		body, _ := json.Marshal(map[string]string{"SecretString": secretString})
+		w.Write(body)
+	}))
+	defer srv.Close()
+
+	sess := session.Must(session.NewSession(&aws.Config{
+		Region:      aws.String("us-west-2"),
+		Endpoint:    aws.String(srv.URL),
+		Credentials: credentials.NewStaticCredentials("AKID", "secret", ""),
+	}))
+	s := NewSecretsManagerStore(sess)
+	src := &addonmgrv1alpha1.SecretSource{AWSSecretsManager: &addonmgrv1alpha1.AWSSecretsManagerSource{SecretID: "app", Keys: []string{"password"}}}
+
+	data, err := s.Fetch(context.TODO(), src)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(data).To(Equal(map[string][]byte{"password": []byte("s3cret")}))
+
+	// Plain strings are stored under the configured key
+	secretString = "plain-token"
+	src.AWSSecretsManager.Key = "token"
+	data, err = s.Fetch(context.TODO(), src)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(data).To(Equal(map[string][]byte{"token": []byte("plain-token")}))
+}
+
+func newSealedSecretScheme() *runtime.Scheme {
+	s := runtime.NewScheme()
+	gv := common.SealedSecretGVR().GroupVersion()
+	s.AddKnownTypeWithName(gv.WithKind("SealedSecret"), &unstructured.Unstructured{})
+	s.AddKnownTypeWithName(gv.WithKind("SealedSecretList"), &unstructured.UnstructuredList{})
+	return s
+}
+
+func TestMaterializer_SealedSecrets(t *testing.T) {
+	g := NewGomegaWithT(t)
+	ctx := context.TODO()
+
+	// Unsealing of a SealedSecret artifact applied by the prereqs workflow failed
+	artifact := &unstructured.Unstructured{}
+	artifact.SetAPIVersion("bitnami.com/v1alpha1")
+	artifact.SetKind("SealedSecret")
+	artifact.SetName("from-artifact")
+	artifact.SetNamespace("secrets-ns")
+	artifact.SetLabels(map[string]string{"app.kubernetes.io/part-of": "secrets-test"})
+
+	client := fake.NewSimpleClientset()
+	dynClient := dynfake.NewSimpleDynamicClient(newSealedSecretScheme(), artifact)
+	m := newTestMaterializer(client, nil, nil)
+	m.SetDynamicClient(dynClient)
+
+	a := newSecretsAddon()
+	a.Spec.Secrets = []addonmgrv1alpha1.SecretCmdSpec{
+		{Name: "sealed", From: &addonmgrv1alpha1.SecretSource{
+			SealedSecret: &addonmgrv1alpha1.SealedSecretSource{EncryptedData: map[string]string{"password": "AgBy3i4OJSWK"}},
+		}},
+	}
+	g.Expect(m.Materialize(ctx, a)).To(Succeed())
+
+	sealed, err := dynClient.Resource(common.SealedSecretGVR()).Namespace("secrets-ns").Get(ctx, "sealed", metav1.GetOptions{})
+	g.Expect(err).ToNot(HaveOccurred())
+	data, _, _ := unstructured.NestedStringMap(sealed.Object, "spec", "encryptedData")
+	g.Expect(data).To(Equal(map[string]string{"password": "AgBy3i4OJSWK"}))
+
+	pending, err := m.PendingUnseal(ctx, a)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(pending).To(Equal([]string{"from-artifact", "sealed"}))
+
+	// Unsealed by the sealed-secrets controller
+	_, err = client.CoreV1().Secrets("secrets-ns").Create(ctx, &v1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "sealed", Namespace: "secrets-ns"}}, metav1.CreateOptions{})
+	g.Expect(err).ToNot(HaveOccurred())
+	pending, err = m.PendingUnseal(ctx, a)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(pending).To(Equal([]string{"from-artifact"}))
+
+	g.Expect(unstructured.SetNestedSlice(artifact.Object, []interface{}{
+		map[string]interface{}{"type": "Synced", "status": "False", "message": "no key could decrypt secret"},
+	}, "status", "conditions")).To(Succeed())
+	_, err = dynClient.Resource(common.SealedSecretGVR()).Namespace("secrets-ns").Update(ctx, artifact, metav1.UpdateOptions{})
+	g.Expect(err).ToNot(HaveOccurred())
+	_, err = m.PendingUnseal(ctx, a)
+	g.Expect(err).To(MatchError(ContainSubstring("no key could decrypt secret")))
+
+	// Updating a sealed secret that is not managed by the addon is refused
+	a.Spec.Secrets[0].Name = "from-artifact"
+	g.Expect(m.Materialize(ctx, a)).To(MatchError(ContainSubstring("not managed by addon")))
+}
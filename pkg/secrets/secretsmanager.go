@@ -0,0 +1,67 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/client"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+	"github.com/aws/aws-sdk-go/service/secretsmanager/secretsmanageriface"
+
+	addonmgrv1alpha1 "github.com/keikoproj/addon-manager/api/v1alpha1"
+)
+
+const defaultSecretKey = "value"
+
+type secretsManagerStore struct {
+	api secretsmanageriface.SecretsManagerAPI
+}
+
+// NewSecretsManagerStore returns a Store reading secrets from AWS Secrets Manager with the credentials and region
+// of the session
+func NewSecretsManagerStore(p client.ConfigProvider) Store {
+	return &secretsManagerStore{api: secretsmanager.New(p)}
+}
+
+func (s *secretsManagerStore) Fetch(ctx context.Context, src *addonmgrv1alpha1.SecretSource) (map[string][]byte, error) {
+	ref := src.AWSSecretsManager
+
+	input := &secretsmanager.GetSecretValueInput{SecretId: aws.String(ref.SecretID)}
+	if ref.VersionStage != "" {
+		input.VersionStage = aws.String(ref.VersionStage)
+	}
+	value, err := s.api.GetSecretValueWithContext(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+
+	key := ref.Key
+	if key == "" {
+		key = defaultSecretKey
+	}
+
+	if value.SecretBinary != nil {
+		return map[string][]byte{key: value.SecretBinary}, nil
+	}
+
+	// JSON object secrets are split into keys, anything else is stored as is
+	var values map[string]interface{}
+	if err := json.Unmarshal([]byte(aws.StringValue(value.SecretString)), &values); err == nil {
+		return selectKeys(values, ref.Keys)
+	}
+	return map[string][]byte{key: []byte(aws.StringValue(value.SecretString))}, nil
+}
@@ -0,0 +1,142 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package secrets
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	addonmgrv1alpha1 "github.com/keikoproj/addon-manager/api/v1alpha1"
+)
+
+const (
+	defaultTimeout          = 10 * time.Second
+	serviceAccountTokenFile = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+)
+
+type vaultStore struct {
+	sync.Mutex
+	addr      string
+	role      string
+	authPath  string
+	tokenFile string
+	client    *http.Client
+	token     string
+	expires   time.Time
+}
+
+// NewVaultStore returns a Store reading Vault KV secrets. When role is set the controller logs in with
+// its service account token using the Kubernetes auth method mounted at authPath, otherwise VAULT_TOKEN is used.
+func NewVaultStore(addr, role, authPath string) Store {
+	return &vaultStore{
+		addr:      strings.TrimSuffix(addr, "/"),
+		role:      role,
+		authPath:  strings.Trim(authPath, "/"),
+		tokenFile: serviceAccountTokenFile,
+		client:    &http.Client{Timeout: defaultTimeout},
+	}
+}
+
+func (v *vaultStore) Fetch(ctx context.Context, src *addonmgrv1alpha1.SecretSource) (map[string][]byte, error) {
+	token, err := v.login(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var out struct {
+		Data map[string]interface{} `json:"data"`
+	}
+	if err := v.do(ctx, http.MethodGet, "/v1/"+strings.TrimPrefix(src.Vault.Path, "/"), token, nil, &out); err != nil {
+		return nil, err
+	}
+
+	values := out.Data
+	// KV version 2 nests the secret under data with its metadata alongside
+	if nested, ok := values["data"].(map[string]interface{}); ok {
+		if _, ok := values["metadata"]; ok {
+			values = nested
+		}
+	}
+
+	return selectKeys(values, src.Vault.Keys)
+}
+
+func (v *vaultStore) login(ctx context.Context) (string, error) {
+	if v.role == "" {
+		token := os.Getenv("VAULT_TOKEN")
+		if token == "" {
+			return "", fmt.Errorf("VAULT_TOKEN must be set when no vault role is configured")
+		}
+		return token, nil
+	}
+
+	v.Lock()
+	defer v.Unlock()
+
+	// Renew a minute ahead of expiry
+	if v.token != "" && time.Now().Add(time.Minute).Before(v.expires) {
+		return v.token, nil
+	}
+
+	jwt, err := ioutil.ReadFile(v.tokenFile)
+	if err != nil {
+		return "", err
+	}
+
+	body, _ := json.Marshal(map[string]string{"role": v.role, "jwt": strings.TrimSpace(string(jwt))})
+	var out struct {
+		Auth struct {
+			ClientToken   string `json:"client_token"`
+			LeaseDuration int    `json:"lease_duration"`
+		} `json:"auth"`
+	}
+	if err := v.do(ctx, http.MethodPost, "/v1/auth/"+v.authPath+"/login", "", body, &out); err != nil {
+		return "", fmt.Errorf("vault login failed. %v", err)
+	}
+
+	v.token = out.Auth.ClientToken
+	v.expires = time.Now().Add(time.Duration(out.Auth.LeaseDuration) * time.Second)
+	return v.token, nil
+}
+
+func (v *vaultStore) do(ctx context.Context, method, path, token string, body []byte, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, method, v.addr+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	if token != "" {
+		req.Header.Set("X-Vault-Token", token)
+	}
+
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("vault returned status %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+
+	return json.NewDecoder(resp.Body).Decode(out)
+}
@@ -0,0 +1,87 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package shard splits namespaces between manager replicas by hash so each replica reconciles a deterministic subset.
+package shard
+
+import (
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+)
+
+// Shard is the subset of namespaces reconciled by a manager replica, a nil Shard owns every namespace
+type Shard struct {
+	count uint32
+	index uint32
+}
+
+// New returns shard index of count shards
+func New(count, index int) (*Shard, error) {
+	if count < 1 {
+		return nil, fmt.Errorf("shard count %d must be at least 1", count)
+	}
+	if index < 0 || index >= count {
+		return nil, fmt.Errorf("shard index %d must be between 0 and %d", index, count-1)
+	}
+	return &Shard{count: uint32(count), index: uint32(index)}, nil
+}
+
+// Ordinal returns the ordinal suffix of a StatefulSet pod name, e.g. 2 for addon-manager-2
+func Ordinal(podName string) (int, error) {
+	i := strings.LastIndex(podName, "-")
+	ordinal, err := strconv.Atoi(podName[i+1:])
+	if i < 0 || err != nil || ordinal < 0 {
+		return 0, fmt.Errorf("pod name %q has no ordinal suffix", podName)
+	}
+	return ordinal, nil
+}
+
+// Owns returns true when the namespace belongs to the shard
+func (s *Shard) Owns(namespace string) bool {
+	if s == nil || s.count <= 1 {
+		return true
+	}
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(namespace))
+	return h.Sum32()%s.count == s.index
+}
+
+// Predicate filters out the events of objects in namespaces of other shards
+func (s *Shard) Predicate() predicate.Predicate {
+	return predicate.NewPredicateFuncs(func(meta metav1.Object, _ runtime.Object) bool {
+		return s.Owns(meta.GetNamespace())
+	})
+}
+
+// LeaderElectionID returns the leader election id of the shard, replicas of each shard elect their own leader
+func (s *Shard) LeaderElectionID(id string) string {
+	if s == nil || s.count <= 1 {
+		return id
+	}
+	return fmt.Sprintf("%s-shard-%d", id, s.index)
+}
+
+// String returns the shard as index/count
+func (s *Shard) String() string {
+	if s == nil {
+		return "0/1"
+	}
+	return fmt.Sprintf("%d/%d", s.index, s.count)
+}
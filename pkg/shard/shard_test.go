@@ -0,0 +1,103 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package shard
+
+import (
+	"fmt"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+)
+
+func TestShard_Owns(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	var shards []*Shard
+	for i := 0; i < 3; i++ {
+		s, err := New(3, i)
+		g.Expect(err).ToNot(HaveOccurred())
+		shards = append(shards, s)
+	}
+
+	// Every namespace is owned by exactly one shard
+	owned := make([]int, len(shards))
+	for n := 0; n < 300; n++ {
+		ns := fmt.Sprintf("namespace-%d", n)
+		var owners int
+		for i, s := range shards {
+			if s.Owns(ns) {
+				owners++
+				owned[i]++
+			}
+		}
+		g.Expect(owners).To(Equal(1), ns)
+	}
+	for _, n := range owned {
+		g.Expect(n).To(BeNumerically(">", 50))
+	}
+
+	var unsharded *Shard
+	g.Expect(unsharded.Owns("any")).To(BeTrue())
+	g.Expect(unsharded.LeaderElectionID("addonmgr.keikoproj.io")).To(Equal("addonmgr.keikoproj.io"))
+	g.Expect(shards[2].LeaderElectionID("addonmgr.keikoproj.io")).To(Equal("addonmgr.keikoproj.io-shard-2"))
+	g.Expect(shards[1].String()).To(Equal("1/3"))
+
+	_, err := New(0, 0)
+	g.Expect(err).To(HaveOccurred())
+	_, err = New(3, 3)
+	g.Expect(err).To(HaveOccurred())
+}
+
+func TestShard_Predicate(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	s, err := New(2, 0)
+	g.Expect(err).ToNot(HaveOccurred())
+
+	var owned, other string
+	for n := 0; owned == "" || other == ""; n++ {
+		ns := fmt.Sprintf("namespace-%d", n)
+		if s.Owns(ns) {
+			owned = ns
+		} else {
+			other = ns
+		}
+	}
+
+	p := s.Predicate()
+	cm := &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "cm", Namespace: owned}}
+	g.Expect(p.Create(event.CreateEvent{Meta: cm, Object: cm})).To(BeTrue())
+	cm.Namespace = other
+	g.Expect(p.Create(event.CreateEvent{Meta: cm, Object: cm})).To(BeFalse())
+
+	var unsharded *Shard
+	g.Expect(unsharded.Predicate().Create(event.CreateEvent{Meta: cm, Object: cm})).To(BeTrue())
+}
+
+func TestOrdinal(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	ordinal, err := Ordinal("addon-manager-2")
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(ordinal).To(Equal(2))
+
+	for _, name := range []string{"addon-manager", "addon-manager-", "3", "addon-manager-7d9f8b-x2x4z"} {
+		_, err := Ordinal(name)
+		g.Expect(err).To(HaveOccurred(), name)
+	}
+}
@@ -0,0 +1,78 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package sops
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/client"
+	awskms "github.com/aws/aws-sdk-go/service/kms"
+	"github.com/aws/aws-sdk-go/service/kms/kmsiface"
+)
+
+type kmsClient interface {
+	Decrypt(ctx context.Context, key KMSKey) ([]byte, error)
+}
+
+type awsKMS struct {
+	sync.Mutex
+	provider client.ConfigProvider
+	// clients holds a KMS client for each region keys were seen in
+	clients map[string]kmsiface.KMSAPI
+}
+
+func newKMSClient(p client.ConfigProvider) kmsClient {
+	return &awsKMS{provider: p, clients: make(map[string]kmsiface.KMSAPI)}
+}
+
+func (k *awsKMS) client(region string) kmsiface.KMSAPI {
+	k.Lock()
+	defer k.Unlock()
+
+	c, ok := k.clients[region]
+	if !ok {
+		c = awskms.New(k.provider, aws.NewConfig().WithRegion(region))
+		k.clients[region] = c
+	}
+	return c
+}
+
+// Decrypt decrypts the data key with the KMS key in the region of its arn
+func (k *awsKMS) Decrypt(ctx context.Context, key KMSKey) ([]byte, error) {
+	// arn:aws:kms:<region>:<account>:key/<id>
+	parts := strings.Split(key.ARN, ":")
+	if len(parts) < 6 {
+		return nil, fmt.Errorf("invalid kms key arn %q", key.ARN)
+	}
+
+	blob, err := base64.StdEncoding.DecodeString(strings.TrimSpace(key.Enc))
+	if err != nil {
+		return nil, fmt.Errorf("invalid encrypted data key for %s. %v", key.ARN, err)
+	}
+	input := &awskms.DecryptInput{CiphertextBlob: blob, KeyId: aws.String(key.ARN)}
+	if len(key.Context) > 0 {
+		input.EncryptionContext = aws.StringMap(key.Context)
+	}
+
+	out, err := k.client(parts[3]).DecryptWithContext(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+	return out.Plaintext, nil
+}
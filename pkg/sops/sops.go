@@ -0,0 +1,200 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package sops
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"sync"
+	"time"
+
+	"filippo.io/age"
+	"filippo.io/age/armor"
+	"github.com/aws/aws-sdk-go/aws/client"
+	"go.mozilla.org/sops/v3"
+	"go.mozilla.org/sops/v3/aes"
+	"go.mozilla.org/sops/v3/keyservice"
+	sopsyaml "go.mozilla.org/sops/v3/stores/yaml"
+	"google.golang.org/grpc"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// KMSKey is the data key encrypted with an AWS KMS key
+type KMSKey struct {
+	ARN     string
+	Enc     string
+	Context map[string]string
+}
+
+type cacheEntry struct {
+	digest string
+	values map[string]string
+}
+
+// Decryptor decrypts sops documents with AWS KMS or age identities
+type Decryptor struct {
+	sync.RWMutex
+	kms           kmsClient
+	ageIdentities []age.Identity
+	// cache holds the values last decrypted for an addon with the digest of their document
+	cache map[types.NamespacedName]cacheEntry
+}
+
+// NewDecryptor returns a Decryptor using AWS KMS with the credentials of the session and the given age secret keys
+func NewDecryptor(p client.ConfigProvider, ageKeys []string) (*Decryptor, error) {
+	d := &Decryptor{kms: newKMSClient(p), cache: make(map[types.NamespacedName]cacheEntry)}
+	for _, k := range ageKeys {
+		id, err := age.ParseX25519Identity(k)
+		if err != nil {
+			return nil, fmt.Errorf("invalid age identity. %v", err)
+		}
+		d.ageIdentities = append(d.ageIdentities, id)
+	}
+	return d, nil
+}
+
+// ParseAgeKeys returns the age secret keys in an age key file, comments and blank lines are ignored
+func ParseAgeKeys(keyFile []byte) []string {
+	var keys []string
+	for _, line := range strings.Split(string(keyFile), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		keys = append(keys, line)
+	}
+	return keys
+}
+
+// DecryptCached returns the values of the document of the addon, the document is only decrypted again when its
+// digest changed
+func (d *Decryptor) DecryptCached(ctx context.Context, addon types.NamespacedName, digest string, doc []byte) (map[string]string, error) {
+	d.RLock()
+	e, ok := d.cache[addon]
+	d.RUnlock()
+	if ok && e.digest == digest {
+		return e.values, nil
+	}
+
+	values, err := d.Decrypt(ctx, doc)
+	if err != nil {
+		return nil, err
+	}
+	d.Lock()
+	defer d.Unlock()
+	d.cache[addon] = cacheEntry{digest: digest, values: values}
+	return values, nil
+}
+
+// Forget drops the values cached for a deleted addon
+func (d *Decryptor) Forget(addon types.NamespacedName) {
+	d.Lock()
+	defer d.Unlock()
+	delete(d.cache, addon)
+}
+
+// Decrypt decrypts a sops encrypted YAML or JSON document of top level scalar values.
+// The document MAC is verified before any value is returned.
+func (d *Decryptor) Decrypt(ctx context.Context, doc []byte) (map[string]string, error) {
+	store := &sopsyaml.Store{}
+	tree, err := store.LoadEncryptedFile(doc)
+	if err == sops.MetadataNotFound {
+		return nil, fmt.Errorf("document is not sops encrypted")
+	}
+	if err != nil {
+		return nil, err
+	}
+	if tree.Metadata.MasterKeyCount() == 0 {
+		return nil, fmt.Errorf("document has no kms or age keys")
+	}
+
+	key, err := tree.Metadata.GetDataKeyWithKeyServices([]keyservice.KeyServiceClient{&keyService{ctx: ctx, d: d}})
+	if err != nil {
+		return nil, fmt.Errorf("data key could not be decrypted. %v", err)
+	}
+
+	cipher := aes.NewCipher()
+	mac, err := tree.Decrypt(key, cipher)
+	if err != nil {
+		return nil, err
+	}
+	expected, err := cipher.Decrypt(tree.Metadata.MessageAuthenticationCode, key, tree.Metadata.LastModified.Format(time.RFC3339))
+	if err != nil {
+		return nil, fmt.Errorf("document mac could not be decrypted. %v", err)
+	}
+	if expected != mac {
+		return nil, fmt.Errorf("document mac mismatch, the document was modified")
+	}
+
+	values := make(map[string]string)
+	for _, branch := range tree.Branches {
+		for _, item := range branch {
+			name, ok := item.Key.(string)
+			if !ok {
+				// Comments
+				continue
+			}
+			switch v := item.Value.(type) {
+			case sops.TreeBranch, []interface{}:
+				return nil, fmt.Errorf("value of %s must be a scalar", name)
+			case nil:
+				values[name] = ""
+			default:
+				values[name] = fmt.Sprint(v)
+			}
+		}
+	}
+	return values, nil
+}
+
+// keyService decrypts data keys with the age identities and the KMS client of the decryptor
+type keyService struct {
+	ctx context.Context
+	d   *Decryptor
+}
+
+func (s *keyService) Decrypt(_ context.Context, req *keyservice.DecryptRequest, _ ...grpc.CallOption) (*keyservice.DecryptResponse, error) {
+	var plain []byte
+	var err error
+	switch k := req.Key.KeyType.(type) {
+	case *keyservice.Key_AgeKey:
+		plain, err = s.decryptAge(req.Ciphertext)
+	case *keyservice.Key_KmsKey:
+		plain, err = s.d.kms.Decrypt(s.ctx, KMSKey{ARN: k.KmsKey.Arn, Enc: string(req.Ciphertext), Context: k.KmsKey.Context})
+	default:
+		err = fmt.Errorf("only kms and age keys are supported")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &keyservice.DecryptResponse{Plaintext: plain}, nil
+}
+
+func (s *keyService) Encrypt(context.Context, *keyservice.EncryptRequest, ...grpc.CallOption) (*keyservice.EncryptResponse, error) {
+	return nil, fmt.Errorf("encryption is not supported")
+}
+
+func (s *keyService) decryptAge(ciphertext []byte) ([]byte, error) {
+	if len(s.d.ageIdentities) == 0 {
+		return nil, fmt.Errorf("no age identity configured")
+	}
+	r, err := age.Decrypt(armor.NewReader(bytes.NewReader(ciphertext)), s.d.ageIdentities...)
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.ReadAll(r)
+}
@@ -0,0 +1,180 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package sops
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"filippo.io/age"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	. "github.com/onsi/gomega"
+	"go.mozilla.org/sops/v3"
+	"go.mozilla.org/sops/v3/aes"
+	sopsage "go.mozilla.org/sops/v3/age"
+	"go.mozilla.org/sops/v3/keys"
+	"go.mozilla.org/sops/v3/kms"
+	sopsyaml "go.mozilla.org/sops/v3/stores/yaml"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// newSession returns an AWS session with static credentials sending requests to endpoint
+func newSession(endpoint string) *session.Session {
+	return session.Must(session.NewSession(&aws.Config{
+		Region:      aws.String("us-west-2"),
+		Endpoint:    aws.String(endpoint),
+		Credentials: credentials.NewStaticCredentials("AKID", "secret", ""),
+	}))
+}
+
+// newDocument returns a sops document encrypting values with dataKey, debug_unencrypted is left in plain text
+func newDocument(g *WithT, dataKey []byte, key keys.MasterKey) []byte {
+	tree := sops.Tree{
+		Branches: sops.TreeBranches{{
+			{Key: "user", Value: "admin"},
+			{Key: "password", Value: "s3cret"},
+			{Key: "debug_unencrypted", Value: true},
+		}},
+		Metadata: sops.Metadata{
+			KeyGroups:         []sops.KeyGroup{{key}},
+			UnencryptedSuffix: "_unencrypted",
+			LastModified:      time.Date(2020, 10, 1, 10, 0, 0, 0, time.UTC),
+			Version:           "3.7.3",
+		},
+	}
+	cipher := aes.NewCipher()
+	mac, err := tree.Encrypt(dataKey, cipher)
+	g.Expect(err).ToNot(HaveOccurred())
+	tree.Metadata.MessageAuthenticationCode, err = cipher.Encrypt(mac, dataKey, tree.Metadata.LastModified.Format(time.RFC3339))
+	g.Expect(err).ToNot(HaveOccurred())
+
+	doc, err := (&sopsyaml.Store{}).EmitEncryptedFile(tree)
+	g.Expect(err).ToNot(HaveOccurred())
+	return doc
+}
+
+func newDataKey() []byte {
+	dataKey := make([]byte, 32)
+	_, _ = rand.Read(dataKey)
+	return dataKey
+}
+
+func TestDecryptor_DecryptAge(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	identity, err := age.GenerateX25519Identity()
+	g.Expect(err).ToNot(HaveOccurred())
+	dataKey := newDataKey()
+	key := &sopsage.MasterKey{Recipient: identity.Recipient().String()}
+	g.Expect(key.Encrypt(dataKey)).To(Succeed())
+
+	d, err := NewDecryptor(newSession(""), ParseAgeKeys([]byte("# created: 2020-10-01\n"+identity.String()+"\n")))
+	g.Expect(err).ToNot(HaveOccurred())
+	doc := newDocument(g, dataKey, key)
+	values, err := d.Decrypt(context.TODO(), doc)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(values).To(Equal(map[string]string{"user": "admin", "password": "s3cret", "debug_unencrypted": "true"}))
+
+	// Tampering with an unencrypted value fails the mac
+	_, err = d.Decrypt(context.TODO(), bytes.Replace(doc, []byte("debug_unencrypted: true"), []byte("debug_unencrypted: false"), 1))
+	g.Expect(err).To(MatchError(ContainSubstring("mac mismatch")))
+
+	// Other identities can not decrypt
+	other, err := age.GenerateX25519Identity()
+	g.Expect(err).ToNot(HaveOccurred())
+	d, err = NewDecryptor(newSession(""), []string{other.String()})
+	g.Expect(err).ToNot(HaveOccurred())
+	_, err = d.Decrypt(context.TODO(), doc)
+	g.Expect(err).To(MatchError(ContainSubstring("data key could not be decrypted")))
+
+	_, err = NewDecryptor(newSession(""), []string{"AGE-SECRET-KEY-1INVALID"})
+	g.Expect(err).To(HaveOccurred())
+}
+
+func TestDecryptor_DecryptKMS(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	dataKey := newDataKey()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		g.Expect(r.Header.Get("X-Amz-Target")).To(Equal("TrentService.Decrypt"))
+		g.Expect(r.Header.Get("Authorization")).To(ContainSubstring("/us-west-2/kms/aws4_request"))
+		var input map[string]interface{}
+		g.Expect(json.NewDecoder(r.Body).Decode(&input)).To(Succeed())
+		g.Expect(input["CiphertextBlob"]).To(Equal("YmxvYg=="))
+		g.Expect(input["EncryptionContext"]).To(Equal(map[string]interface{}{"app": "addons"}))
+		fmt.Fprintf(w, `{"Plaintext":"%s"}`, base64.StdEncoding.EncodeToString(dataKey))
+	}))
+	defer srv.Close()
+
+	d := &Decryptor{kms: newKMSClient(newSession(srv.URL))}
+	app := "addons"
+	key := &kms.MasterKey{
+		Arn:               "arn:aws:kms:us-west-2:123456789012:key/abc",
+		EncryptedKey:      "YmxvYg==",
+		EncryptionContext: map[string]*string{"app": &app},
+		CreationDate:      time.Now().UTC(),
+	}
+
+	values, err := d.Decrypt(context.TODO(), newDocument(g, dataKey, key))
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(values).To(HaveKeyWithValue("password", "s3cret"))
+}
+
+func TestDecryptor_DecryptCached(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	identity, err := age.GenerateX25519Identity()
+	g.Expect(err).ToNot(HaveOccurred())
+	dataKey := newDataKey()
+	key := &sopsage.MasterKey{Recipient: identity.Recipient().String()}
+	g.Expect(key.Encrypt(dataKey)).To(Succeed())
+	doc := newDocument(g, dataKey, key)
+
+	d, err := NewDecryptor(newSession(""), []string{identity.String()})
+	g.Expect(err).ToNot(HaveOccurred())
+	addon := types.NamespacedName{Namespace: "default", Name: "sops"}
+	values, err := d.DecryptCached(context.TODO(), addon, "abc", doc)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(values).To(HaveKeyWithValue("password", "s3cret"))
+
+	// The document is not decrypted again while its digest is unchanged
+	values, err = d.DecryptCached(context.TODO(), addon, "abc", []byte("not: encrypted\n"))
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(values).To(HaveKeyWithValue("password", "s3cret"))
+
+	_, err = d.DecryptCached(context.TODO(), addon, "def", []byte("not: encrypted\n"))
+	g.Expect(err).To(HaveOccurred())
+
+	d.Forget(addon)
+	_, err = d.DecryptCached(context.TODO(), addon, "abc", []byte("not: encrypted\n"))
+	g.Expect(err).To(HaveOccurred())
+}
+
+func TestDecryptor_NotEncrypted(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	_, err := (&Decryptor{}).Decrypt(context.TODO(), []byte("password: s3cret\n"))
+	g.Expect(err).To(MatchError(ContainSubstring("not sops encrypted")))
+}
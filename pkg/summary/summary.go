@@ -0,0 +1,211 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package summary
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	addonmgrv1alpha1 "github.com/keikoproj/addon-manager/api/v1alpha1"
+)
+
+// StepState is the state of the workflow of a lifecycle step
+type StepState string
+
+const (
+	// NotRun is a step whose workflow was not submitted
+	NotRun StepState = "-"
+	// Running is a step whose workflow was submitted and has not completed
+	Running StepState = "Running"
+	// Succeeded is a step whose workflow succeeded
+	Succeeded StepState = "Succeeded"
+	// Failed is a step whose workflow failed
+	Failed StepState = "Failed"
+	// Skipped is a step skipped by its spec flag
+	Skipped StepState = "Skipped"
+)
+
+// steps are the lifecycle steps in the order they run
+var steps = []addonmgrv1alpha1.LifecycleStep{
+	addonmgrv1alpha1.Prereqs,
+	addonmgrv1alpha1.Install,
+	addonmgrv1alpha1.Upgrade,
+	addonmgrv1alpha1.Validate,
+	addonmgrv1alpha1.Delete,
+}
+
+// Step is the last workflow of a lifecycle step
+type Step struct {
+	Name           addonmgrv1alpha1.LifecycleStep `json:"name"`
+	State          StepState                      `json:"state"`
+	Workflow       string                         `json:"workflow,omitempty"`
+	StartTime      *metav1.Time                   `json:"startTime,omitempty"`
+	CompletionTime *metav1.Time                   `json:"completionTime,omitempty"`
+	Duration       *metav1.Duration               `json:"duration,omitempty"`
+}
+
+// Addon is the phase, version, checksum and lifecycle workflows of an addon
+type Addon struct {
+	Namespace string                                    `json:"namespace"`
+	Name      string                                    `json:"name"`
+	Package   string                                    `json:"package"`
+	Version   string                                    `json:"version"`
+	Phase     addonmgrv1alpha1.ApplicationAssemblyPhase `json:"phase"`
+	Checksum  string                                    `json:"checksum"`
+	Reason    string                                    `json:"reason,omitempty"`
+	Progress  *addonmgrv1alpha1.WorkflowProgress        `json:"progress,omitempty"`
+	Steps     []Step                                    `json:"steps"`
+}
+
+// New returns the summaries of addons sorted by namespace and name
+func New(addons []addonmgrv1alpha1.Addon) []Addon {
+	summaries := make([]Addon, 0, len(addons))
+	for i := range addons {
+		summaries = append(summaries, NewAddon(&addons[i]))
+	}
+	sort.Slice(summaries, func(i, j int) bool {
+		if summaries[i].Namespace != summaries[j].Namespace {
+			return summaries[i].Namespace < summaries[j].Namespace
+		}
+		return summaries[i].Name < summaries[j].Name
+	})
+	return summaries
+}
+
+// NewAddon returns the summary of an addon from its status
+func NewAddon(a *addonmgrv1alpha1.Addon) Addon {
+	s := Addon{
+		Namespace: a.GetNamespace(),
+		Name:      a.GetName(),
+		Package:   a.Spec.PkgName,
+		Version:   a.Spec.PkgVersion,
+		Phase:     a.Status.Lifecycle.Installed,
+		Checksum:  a.Status.Checksum,
+		Reason:    a.Status.Reason,
+		Progress:  a.Status.Progress,
+	}
+	for _, step := range steps {
+		st := Step{Name: step, State: stepState(a, step)}
+		if timing := a.GetStepTiming(step); timing != nil {
+			st.Workflow = timing.Workflow
+			st.StartTime = timing.StartTime
+			st.CompletionTime = timing.CompletionTime
+			st.Duration = timing.Duration
+		}
+		s.Steps = append(s.Steps, st)
+	}
+	return s
+}
+
+// stepState returns the state of the last workflow of the step recorded in the status
+func stepState(a *addonmgrv1alpha1.Addon, step addonmgrv1alpha1.LifecycleStep) StepState {
+	for _, skipped := range a.Status.Lifecycle.Skipped {
+		if skipped == step {
+			return Skipped
+		}
+	}
+	if a.Status.Lifecycle.FailedStep == step {
+		return Failed
+	}
+	timing := a.GetStepTiming(step)
+	switch {
+	case timing == nil || timing.StartTime == nil:
+		return NotRun
+	case timing.CompletionTime == nil:
+		return Running
+	}
+	return Succeeded
+}
+
+// Step returns the summary of the lifecycle step
+func (s Addon) Step(step addonmgrv1alpha1.LifecycleStep) Step {
+	for _, st := range s.Steps {
+		if st.Name == step {
+			return st
+		}
+	}
+	return Step{Name: step, State: NotRun}
+}
+
+// installStep returns the upgrade step when it started after the install step, the install column shows the step
+// that installed the current spec
+func (s Addon) installStep() Step {
+	install, upgrade := s.Step(addonmgrv1alpha1.Install), s.Step(addonmgrv1alpha1.Upgrade)
+	if upgrade.StartTime != nil && (install.StartTime == nil || install.StartTime.Before(upgrade.StartTime)) {
+		return upgrade
+	}
+	return install
+}
+
+// WriteTable writes a row per addon with the state of the prereqs, install, validate and delete workflows, the
+// namespace column is included with allNamespaces
+func WriteTable(w io.Writer, addons []Addon, allNamespaces bool) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	if allNamespaces {
+		fmt.Fprintf(tw, "NAMESPACE\t")
+	}
+	fmt.Fprintf(tw, "NAME\tVERSION\tPHASE\tCHECKSUM\tPREREQS\tINSTALL\tVALIDATE\tDELETE\n")
+	for _, a := range addons {
+		if allNamespaces {
+			fmt.Fprintf(tw, "%s\t", a.Namespace)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", a.Name, a.Version, orNone(string(a.Phase)), orNone(a.Checksum),
+			a.Step(addonmgrv1alpha1.Prereqs).State, a.installStep().State,
+			a.Step(addonmgrv1alpha1.Validate).State, a.Step(addonmgrv1alpha1.Delete).State)
+	}
+	return tw.Flush()
+}
+
+// WriteStatus writes the details of an addon followed by a table of its lifecycle workflows
+func WriteStatus(w io.Writer, a Addon) error {
+	fmt.Fprintf(w, "Name:       %s\n", a.Name)
+	fmt.Fprintf(w, "Namespace:  %s\n", a.Namespace)
+	fmt.Fprintf(w, "Package:    %s:%s\n", a.Package, a.Version)
+	fmt.Fprintf(w, "Phase:      %s\n", orNone(string(a.Phase)))
+	fmt.Fprintf(w, "Checksum:   %s\n", orNone(a.Checksum))
+	if a.Reason != "" {
+		fmt.Fprintf(w, "Reason:     %s\n", a.Reason)
+	}
+	if a.Progress != nil && a.Progress.Workflow != "" {
+		fmt.Fprintf(w, "Progress:   %s %s %s\n", a.Progress.Workflow, a.Progress.Progress, a.Progress.CurrentTemplate)
+	}
+	fmt.Fprintf(w, "Workflows:\n")
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "  STEP\tSTATE\tWORKFLOW\tSTARTED\tDURATION\n")
+	for _, st := range a.Steps {
+		started, duration := "-", "-"
+		if st.StartTime != nil {
+			started = st.StartTime.UTC().Format(time.RFC3339)
+		}
+		if st.Duration != nil {
+			duration = st.Duration.Duration.String()
+		}
+		fmt.Fprintf(tw, "  %s\t%s\t%s\t%s\t%s\n", st.Name, st.State, orNone(st.Workflow), started, duration)
+	}
+	return tw.Flush()
+}
+
+func orNone(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
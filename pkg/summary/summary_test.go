@@ -0,0 +1,118 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package summary
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	addonmgrv1alpha1 "github.com/keikoproj/addon-manager/api/v1alpha1"
+)
+
+func testAddons() []addonmgrv1alpha1.Addon {
+	start := metav1.NewTime(time.Date(2020, 6, 1, 10, 0, 0, 0, time.UTC))
+	upgradeStart := metav1.NewTime(start.Add(time.Hour))
+	end := metav1.NewTime(start.Add(90 * time.Second))
+	return []addonmgrv1alpha1.Addon{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "metrics-server", Namespace: "addon-manager-system"},
+			Spec:       addonmgrv1alpha1.AddonSpec{PackageSpec: addonmgrv1alpha1.PackageSpec{PkgName: "metrics-server", PkgVersion: "0.3.6"}},
+			Status: addonmgrv1alpha1.AddonStatus{
+				Checksum: "7a5e1c2b",
+				Reason:   "validate workflow failed",
+				Lifecycle: addonmgrv1alpha1.AddonStatusLifecycle{
+					Installed:  addonmgrv1alpha1.Failed,
+					FailedStep: addonmgrv1alpha1.Validate,
+					Skipped:    []addonmgrv1alpha1.LifecycleStep{addonmgrv1alpha1.Prereqs},
+				},
+				Timings: addonmgrv1alpha1.AddonStatusTimings{
+					Install:  &addonmgrv1alpha1.LifecycleStepTiming{Workflow: "metrics-server-install-7a5e1c2b-wf", StartTime: &start, CompletionTime: &end, Duration: &metav1.Duration{Duration: 90 * time.Second}},
+					Validate: &addonmgrv1alpha1.LifecycleStepTiming{Workflow: "metrics-server-validate-7a5e1c2b-wf", StartTime: &end, CompletionTime: &end},
+				},
+			},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "external-dns", Namespace: "addon-manager-system"},
+			Spec:       addonmgrv1alpha1.AddonSpec{PackageSpec: addonmgrv1alpha1.PackageSpec{PkgName: "external-dns", PkgVersion: "1.2.0"}},
+			Status: addonmgrv1alpha1.AddonStatus{
+				Checksum:  "3f00ab12",
+				Lifecycle: addonmgrv1alpha1.AddonStatusLifecycle{Prereqs: addonmgrv1alpha1.Succeeded, Installed: addonmgrv1alpha1.Pending},
+				Progress:  &addonmgrv1alpha1.WorkflowProgress{Workflow: "external-dns-upgrade-3f00ab12-wf", Progress: "1/3", CurrentTemplate: "apply"},
+				Timings: addonmgrv1alpha1.AddonStatusTimings{
+					Prereqs: &addonmgrv1alpha1.LifecycleStepTiming{Workflow: "external-dns-prereqs-3f00ab12-wf", StartTime: &start, CompletionTime: &end, Duration: &metav1.Duration{Duration: 90 * time.Second}},
+					Install: &addonmgrv1alpha1.LifecycleStepTiming{Workflow: "external-dns-install-11aa22bb-wf", StartTime: &start, CompletionTime: &end},
+					Upgrade: &addonmgrv1alpha1.LifecycleStepTiming{Workflow: "external-dns-upgrade-3f00ab12-wf", StartTime: &upgradeStart},
+				},
+			},
+		},
+	}
+}
+
+func TestNew(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	addons := New(testAddons())
+	g.Expect(addons).To(HaveLen(2))
+	g.Expect(addons[0].Name).To(Equal("external-dns"))
+
+	s := addons[1]
+	g.Expect(s.Phase).To(Equal(addonmgrv1alpha1.Failed))
+	g.Expect(s.Step(addonmgrv1alpha1.Prereqs).State).To(Equal(Skipped))
+	g.Expect(s.Step(addonmgrv1alpha1.Install).State).To(Equal(Succeeded))
+	g.Expect(s.Step(addonmgrv1alpha1.Install).Workflow).To(Equal("metrics-server-install-7a5e1c2b-wf"))
+	g.Expect(s.Step(addonmgrv1alpha1.Validate).State).To(Equal(Failed))
+	g.Expect(s.Step(addonmgrv1alpha1.Delete).State).To(Equal(NotRun))
+
+	// The upgrade started after the install, the install column shows the running upgrade
+	g.Expect(addons[0].installStep().Name).To(Equal(addonmgrv1alpha1.Upgrade))
+	g.Expect(addons[0].installStep().State).To(Equal(Running))
+	g.Expect(s.installStep().Name).To(Equal(addonmgrv1alpha1.Install))
+}
+
+func TestWriteTable(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	var buf bytes.Buffer
+	g.Expect(WriteTable(&buf, New(testAddons()), true)).To(Succeed())
+	g.Expect(buf.String()).To(Equal(
+		"NAMESPACE             NAME            VERSION  PHASE    CHECKSUM  PREREQS    INSTALL    VALIDATE  DELETE\n" +
+			"addon-manager-system  external-dns    1.2.0    Pending  3f00ab12  Succeeded  Running    -         -\n" +
+			"addon-manager-system  metrics-server  0.3.6    Failed   7a5e1c2b  Skipped    Succeeded  Failed    -\n"))
+}
+
+func TestWriteStatus(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	var buf bytes.Buffer
+	g.Expect(WriteStatus(&buf, New(testAddons())[1])).To(Succeed())
+	g.Expect(buf.String()).To(Equal(
+		"Name:       metrics-server\n" +
+			"Namespace:  addon-manager-system\n" +
+			"Package:    metrics-server:0.3.6\n" +
+			"Phase:      Failed\n" +
+			"Checksum:   7a5e1c2b\n" +
+			"Reason:     validate workflow failed\n" +
+			"Workflows:\n" +
+			"  STEP      STATE      WORKFLOW                             STARTED               DURATION\n" +
+			"  prereqs   Skipped    -                                    -                     -\n" +
+			"  install   Succeeded  metrics-server-install-7a5e1c2b-wf   2020-06-01T10:00:00Z  1m30s\n" +
+			"  upgrade   -          -                                    -                     -\n" +
+			"  validate  Failed     metrics-server-validate-7a5e1c2b-wf  2020-06-01T10:01:30Z  -\n" +
+			"  delete    -          -                                    -                     -\n"))
+}
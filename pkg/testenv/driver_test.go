@@ -0,0 +1,76 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package testenv
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	dynfake "k8s.io/client-go/dynamic/fake"
+
+	addonmgrv1alpha1 "github.com/keikoproj/addon-manager/api/v1alpha1"
+	"github.com/keikoproj/addon-manager/pkg/common"
+	"github.com/keikoproj/addon-manager/pkg/workflows"
+)
+
+func newWorkflow(name, addon string) *unstructured.Unstructured {
+	wf := &unstructured.Unstructured{}
+	wf.SetAPIVersion("argoproj.io/v1alpha1")
+	wf.SetKind("Workflow")
+	wf.SetNamespace(ManagedNamespace)
+	wf.SetName(name)
+	if addon != "" {
+		wf.SetLabels(map[string]string{workflows.AddonLabel: addon})
+	}
+	return wf
+}
+
+func TestWorkflowDriver_Sync(t *testing.T) {
+	g := NewGomegaWithT(t)
+	ctx := context.TODO()
+	dyn := dynfake.NewSimpleDynamicClient(runtime.NewScheme(),
+		newWorkflow("my-addon-prereqs-abc-wf", "my-addon"),
+		newWorkflow("my-addon-install-abc-wf", "my-addon"),
+		newWorkflow("other-install-abc-wf", "other"),
+		newWorkflow("unlabelled", ""),
+	)
+	d := NewWorkflowDriver(dyn, ManagedNamespace)
+	d.SetPhase("my-addon", addonmgrv1alpha1.Install, WorkflowRunning)
+	d.SetPhase("other", addonmgrv1alpha1.Install, WorkflowFailed)
+	g.Expect(d.Sync(ctx)).To(Succeed())
+
+	phase := func(name string) string {
+		wf, err := dyn.Resource(common.WorkflowGVR()).Namespace(ManagedNamespace).Get(ctx, name, metav1.GetOptions{})
+		g.Expect(err).ToNot(HaveOccurred())
+		p, _, _ := unstructured.NestedString(wf.Object, "status", "phase")
+		g.Expect(wf.GetLabels()[workflows.WfPhaseLabelKey]).To(Equal(p))
+		return p
+	}
+	g.Expect(phase("my-addon-prereqs-abc-wf")).To(Equal(WorkflowSucceeded))
+	g.Expect(phase("my-addon-install-abc-wf")).To(Equal(WorkflowRunning))
+	g.Expect(phase("other-install-abc-wf")).To(Equal(WorkflowFailed))
+	g.Expect(phase("unlabelled")).To(Equal(WorkflowSucceeded))
+
+	// Running workflows complete once their phase is changed, completed workflows are left alone
+	d.SetPhase("my-addon", addonmgrv1alpha1.Install, WorkflowSucceeded)
+	d.SetPhase("other", addonmgrv1alpha1.Install, WorkflowSucceeded)
+	g.Expect(d.Sync(ctx)).To(Succeed())
+	g.Expect(phase("my-addon-install-abc-wf")).To(Equal(WorkflowSucceeded))
+	g.Expect(phase("other-install-abc-wf")).To(Equal(WorkflowFailed))
+}
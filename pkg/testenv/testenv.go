@@ -0,0 +1,206 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package testenv runs an envtest API server with the Addon and Argo Workflow CRDs registered and a fake Argo
+// workflow controller, so addon controllers and addon packages can be tested without a cluster. The envtest
+// binaries are located by KUBEBUILDER_ASSETS as for envtest.
+package testenv
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sruntime "k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/dynamic"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/envtest"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+
+	addonmgrv1alpha1 "github.com/keikoproj/addon-manager/api/v1alpha1"
+)
+
+// ManagedNamespace is the namespace the addon manager submits workflows in
+const ManagedNamespace = "addon-manager-system"
+
+// PollInterval is how often WaitForPhase checks the addon
+const PollInterval = 250 * time.Millisecond
+
+// Environment is a running test API server with clients for it
+type Environment struct {
+	Config  *rest.Config
+	Scheme  *k8sruntime.Scheme
+	Client  client.Client
+	Dynamic dynamic.Interface
+	// Driver completes the workflows submitted in ManagedNamespace
+	Driver *WorkflowDriver
+
+	env     *envtest.Environment
+	cancel  context.CancelFunc
+	stopMgr chan struct{}
+	wg      sync.WaitGroup
+}
+
+// CRDDirectory returns the directory of the addon manager and Argo Workflow CRDs
+func CRDDirectory() string {
+	_, file, _, _ := runtime.Caller(0)
+	return filepath.Join(filepath.Dir(file), "..", "..", "config", "crd", "bases")
+}
+
+// Start starts the API server with the CRDs in CRDDirectory and crdPaths, and the workflow driver
+func Start(crdPaths ...string) (*Environment, error) {
+	e := &Environment{
+		env: &envtest.Environment{CRDDirectoryPaths: append([]string{CRDDirectory()}, crdPaths...)},
+	}
+
+	cfg, err := e.env.Start()
+	if err != nil {
+		return nil, fmt.Errorf("failed to start test environment. %v", err)
+	}
+	e.Config = cfg
+
+	if err := e.init(); err != nil {
+		_ = e.env.Stop()
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	e.cancel = cancel
+	e.wg.Add(1)
+	go func() {
+		defer e.wg.Done()
+		e.Driver.Run(ctx)
+	}()
+	return e, nil
+}
+
+func (e *Environment) init() error {
+	e.Scheme = k8sruntime.NewScheme()
+	if err := clientgoscheme.AddToScheme(e.Scheme); err != nil {
+		return err
+	}
+	if err := addonmgrv1alpha1.AddToScheme(e.Scheme); err != nil {
+		return err
+	}
+
+	var err error
+	if e.Client, err = client.New(e.Config, client.Options{Scheme: e.Scheme}); err != nil {
+		return err
+	}
+	if e.Dynamic, err = dynamic.NewForConfig(e.Config); err != nil {
+		return err
+	}
+	e.Driver = NewWorkflowDriver(e.Dynamic, ManagedNamespace)
+
+	ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: ManagedNamespace}}
+	if err := e.Client.Create(context.TODO(), ns); err != nil && !apierrors.IsAlreadyExists(err) {
+		return fmt.Errorf("failed to create namespace %s. %v", ManagedNamespace, err)
+	}
+	return nil
+}
+
+// StartManager starts a manager for the environment after setting up its controllers with setup, it is stopped
+// with the environment
+func (e *Environment) StartManager(setup ...func(manager.Manager) error) (manager.Manager, error) {
+	if e.stopMgr != nil {
+		return nil, fmt.Errorf("manager already started")
+	}
+	mgr, err := ctrl.NewManager(e.Config, ctrl.Options{
+		Scheme:             e.Scheme,
+		MetricsBindAddress: "0",
+		LeaderElection:     false,
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range setup {
+		if err := s(mgr); err != nil {
+			return nil, err
+		}
+	}
+
+	e.stopMgr = make(chan struct{})
+	e.wg.Add(1)
+	go func(stop <-chan struct{}) {
+		defer e.wg.Done()
+		if err := mgr.Start(stop); err != nil {
+			ctrl.Log.WithName("testenv").Error(err, "manager stopped")
+		}
+	}(e.stopMgr)
+	return mgr, nil
+}
+
+// Stop stops the manager, the workflow driver and the API server
+func (e *Environment) Stop() error {
+	if e.stopMgr != nil {
+		close(e.stopMgr)
+	}
+	if e.cancel != nil {
+		e.cancel()
+	}
+	e.wg.Wait()
+	return e.env.Stop()
+}
+
+// CreateAddon creates the addon, in the default namespace when it has none
+func (e *Environment) CreateAddon(ctx context.Context, a *addonmgrv1alpha1.Addon) error {
+	if a.Namespace == "" {
+		a.Namespace = metav1.NamespaceDefault
+	}
+	return e.Client.Create(ctx, a)
+}
+
+// DeleteAddon deletes the addon and waits up to timeout until it is gone
+func (e *Environment) DeleteAddon(ctx context.Context, a *addonmgrv1alpha1.Addon, timeout time.Duration) error {
+	if err := e.Client.Delete(ctx, a); err != nil && !apierrors.IsNotFound(err) {
+		return err
+	}
+	key := types.NamespacedName{Namespace: a.Namespace, Name: a.Name}
+	return wait.PollImmediate(PollInterval, timeout, func() (bool, error) {
+		err := e.Client.Get(ctx, key, &addonmgrv1alpha1.Addon{})
+		if apierrors.IsNotFound(err) {
+			return true, nil
+		}
+		return false, err
+	})
+}
+
+// WaitForPhase waits up to timeout until the addon install phase is phase and returns the addon
+func (e *Environment) WaitForPhase(ctx context.Context, key types.NamespacedName, phase addonmgrv1alpha1.ApplicationAssemblyPhase, timeout time.Duration) (*addonmgrv1alpha1.Addon, error) {
+	a := &addonmgrv1alpha1.Addon{}
+	err := wait.PollImmediate(PollInterval, timeout, func() (bool, error) {
+		if err := e.Client.Get(ctx, key, a); err != nil {
+			if apierrors.IsNotFound(err) {
+				return false, nil
+			}
+			return false, err
+		}
+		return a.Status.Lifecycle.Installed == phase, nil
+	})
+	if err == wait.ErrWaitTimeout {
+		return a, fmt.Errorf("addon %s phase is %q, expected %q", key, a.Status.Lifecycle.Installed, phase)
+	}
+	return a, err
+}
@@ -0,0 +1,101 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package webhook serves the admission webhooks of the addon manager
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	crwebhook "sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	addonmgrv1alpha1 "github.com/keikoproj/addon-manager/api/v1alpha1"
+	"github.com/keikoproj/addon-manager/pkg/addon"
+)
+
+// ValidateAddonPath is the path the Addon validating webhook is served at
+const ValidateAddonPath = "/validate-addonmgr-keikoproj-io-v1alpha1-addon"
+
+// +kubebuilder:webhook:verbs=create;update,path=/validate-addonmgr-keikoproj-io-v1alpha1-addon,mutating=false,failurePolicy=fail,groups=addonmgr.keikoproj.io,resources=addons,versions=v1alpha1,name=vaddon.addonmgr.keikoproj.io
+
+// AddonValidator rejects addons the manager cannot install, e.g. addons with malformed workflow templates, at
+// admission instead of when their workflows are parsed
+type AddonValidator struct {
+	decoder *admission.Decoder
+}
+
+// NewAddonValidator returns an AddonValidator decoding addons with the scheme
+func NewAddonValidator(scheme *runtime.Scheme) (*AddonValidator, error) {
+	decoder, err := admission.NewDecoder(scheme)
+	if err != nil {
+		return nil, err
+	}
+	return &AddonValidator{decoder: decoder}, nil
+}
+
+// Register serves the admission webhooks on the webhook server of the manager
+func Register(mgr manager.Manager) error {
+	v, err := NewAddonValidator(mgr.GetScheme())
+	if err != nil {
+		return err
+	}
+	mgr.GetWebhookServer().Register(ValidateAddonPath, &crwebhook.Admission{Handler: v})
+	return nil
+}
+
+// Handle validates created addons and addons whose spec is updated. Updates leaving the spec unchanged, e.g. of the
+// finalizers, are allowed so addons created before the webhook can still be deleted.
+func (v *AddonValidator) Handle(ctx context.Context, req admission.Request) admission.Response {
+	if req.Operation != admissionv1beta1.Create && req.Operation != admissionv1beta1.Update {
+		return admission.Allowed("")
+	}
+
+	var raw map[string]interface{}
+	if err := json.Unmarshal(req.Object.Raw, &raw); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+	if _, ok := raw["spec"]; !ok {
+		return admission.Denied(fmt.Sprintf("addon %s/%s is invalid: missing spec", req.Namespace, req.Name))
+	}
+
+	a := &addonmgrv1alpha1.Addon{}
+	if err := v.decoder.Decode(req, a); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+	if !a.DeletionTimestamp.IsZero() {
+		return admission.Allowed("")
+	}
+	if req.Operation == admissionv1beta1.Update {
+		old := &addonmgrv1alpha1.Addon{}
+		if err := v.decoder.DecodeRaw(req.OldObject, old); err != nil {
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+		if equality.Semantic.DeepEqual(old.Spec, a.Spec) {
+			return admission.Allowed("")
+		}
+	}
+
+	if err := addon.ValidateSpec(a); err != nil {
+		return admission.Denied(fmt.Sprintf("addon %s/%s is invalid: %v", a.Namespace, a.Name, err))
+	}
+	return admission.Allowed("")
+}
@@ -0,0 +1,94 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	addonmgrv1alpha1 "github.com/keikoproj/addon-manager/api/v1alpha1"
+)
+
+const wfTemplate = `apiVersion: argoproj.io/v1alpha1
+kind: Workflow
+spec:
+  entrypoint: entry
+`
+
+func request(op admissionv1beta1.Operation, obj interface{}, old interface{}) admission.Request {
+	req := admission.Request{AdmissionRequest: admissionv1beta1.AdmissionRequest{Operation: op, Namespace: "default", Name: "my-addon"}}
+	req.Object.Raw, _ = json.Marshal(obj)
+	if old != nil {
+		req.OldObject.Raw, _ = json.Marshal(old)
+	}
+	return req
+}
+
+func TestAddonValidator(t *testing.T) {
+	g := NewGomegaWithT(t)
+	scheme := runtime.NewScheme()
+	g.Expect(addonmgrv1alpha1.AddToScheme(scheme)).To(Succeed())
+	v, err := NewAddonValidator(scheme)
+	g.Expect(err).ToNot(HaveOccurred())
+
+	valid := &addonmgrv1alpha1.Addon{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "addonmgr.keikoproj.io/v1alpha1", Kind: "Addon"},
+		ObjectMeta: metav1.ObjectMeta{Name: "my-addon", Namespace: "default"},
+		Spec: addonmgrv1alpha1.AddonSpec{
+			PackageSpec: addonmgrv1alpha1.PackageSpec{PkgName: "my/addon", PkgVersion: "1.0.0"},
+			Params:      addonmgrv1alpha1.AddonParams{Namespace: "my-addon"},
+			Lifecycle:   addonmgrv1alpha1.LifecycleWorkflowSpec{Install: addonmgrv1alpha1.WorkflowType{Template: wfTemplate}},
+		},
+	}
+	g.Expect(v.Handle(context.TODO(), request(admissionv1beta1.Create, valid, nil)).Allowed).To(BeTrue())
+
+	// Malformed workflow templates
+	malformed := valid.DeepCopy()
+	malformed.Spec.Lifecycle.Install.Template = "kind: [Workflow"
+	resp := v.Handle(context.TODO(), request(admissionv1beta1.Create, malformed, nil))
+	g.Expect(resp.Allowed).To(BeFalse())
+	g.Expect(string(resp.Result.Reason)).To(ContainSubstring("invalid workflow template"))
+
+	// Empty namespace params
+	noNamespace := valid.DeepCopy()
+	noNamespace.Spec.Params.Namespace = ""
+	resp = v.Handle(context.TODO(), request(admissionv1beta1.Create, noNamespace, nil))
+	g.Expect(resp.Allowed).To(BeFalse())
+	g.Expect(string(resp.Result.Reason)).To(ContainSubstring("namespace is empty"))
+
+	// Invalid package versions
+	badVersion := valid.DeepCopy()
+	badVersion.Spec.PkgVersion = "not a version"
+	g.Expect(v.Handle(context.TODO(), request(admissionv1beta1.Create, badVersion, nil)).Allowed).To(BeFalse())
+
+	// Missing spec
+	noSpec := map[string]interface{}{"apiVersion": "addonmgr.keikoproj.io/v1alpha1", "kind": "Addon", "metadata": map[string]interface{}{"name": "my-addon"}}
+	resp = v.Handle(context.TODO(), request(admissionv1beta1.Create, noSpec, nil))
+	g.Expect(resp.Allowed).To(BeFalse())
+	g.Expect(string(resp.Result.Reason)).To(ContainSubstring("missing spec"))
+
+	// Invalid addons admitted before the webhook can still be updated without spec changes, e.g. to be deleted
+	finalized := noNamespace.DeepCopy()
+	finalized.Finalizers = []string{"delete.addonmgr.keikoproj.io"}
+	g.Expect(v.Handle(context.TODO(), request(admissionv1beta1.Update, finalized, noNamespace)).Allowed).To(BeTrue())
+	g.Expect(v.Handle(context.TODO(), request(admissionv1beta1.Update, malformed, valid)).Allowed).To(BeFalse())
+}
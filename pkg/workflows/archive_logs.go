@@ -0,0 +1,91 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package workflows
+
+import (
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// LogArchive copies the logs of the workflow pods to an Argo artifact repository, e.g. an S3 or GCS bucket, so the logs
+// of lifecycle workflows survive the deletion of the workflows after their TTL
+type LogArchive struct {
+	// Enabled archives the logs of the workflows of addons not setting spec.lifecycle.archiveLogs
+	Enabled bool
+	// ConfigMap is the artifact repositories ConfigMap in the workflow namespace and Key the repository in it, the
+	// default artifact repository of the Argo workflow controller is used when empty
+	ConfigMap string
+	Key       string
+}
+
+// ParseLogArchive returns the log archive in the artifact repository given as configmap or configmap/key
+func ParseLogArchive(enabled bool, repository string) (LogArchive, error) {
+	a := LogArchive{Enabled: enabled}
+	if repository == "" {
+		return a, nil
+	}
+	parts := strings.Split(repository, "/")
+	if len(parts) > 2 || parts[0] == "" || (len(parts) == 2 && parts[1] == "") {
+		return a, fmt.Errorf("invalid artifact repository %q, expected configmap or configmap/key", repository)
+	}
+	a.ConfigMap = parts[0]
+	if len(parts) == 2 {
+		a.Key = parts[1]
+	}
+	return a, nil
+}
+
+// WithLogArchive archives the logs of the workflows of the addon as configured by a
+func WithLogArchive(a LogArchive) Option {
+	return func(w *workflowLifecycle) {
+		w.logArchive = a
+	}
+}
+
+// archiveLogs returns true when the logs of the workflows of the addon are archived
+func (w *workflowLifecycle) archiveLogs() bool {
+	if archive := w.addon.Spec.Lifecycle.ArchiveLogs; archive != nil {
+		return *archive
+	}
+	return w.logArchive.Enabled
+}
+
+// injectArchiveLogs enables archiving the logs of the workflow in the configured artifact repository, workflows
+// configuring the archive themselves are left alone
+func (w *workflowLifecycle) injectArchiveLogs(wf *unstructured.Unstructured) error {
+	if !w.archiveLogs() {
+		return nil
+	}
+	if _, found, err := unstructured.NestedFieldNoCopy(wf.Object, "spec", "archiveLogs"); err != nil || found {
+		return err
+	}
+
+	if err := unstructured.SetNestedField(wf.Object, true, "spec", "archiveLogs"); err != nil {
+		return err
+	}
+	if w.logArchive.ConfigMap == "" {
+		return nil
+	}
+	if _, found, err := unstructured.NestedFieldNoCopy(wf.Object, "spec", "artifactRepositoryRef"); err != nil || found {
+		return err
+	}
+	ref := map[string]interface{}{"configMap": w.logArchive.ConfigMap}
+	if w.logArchive.Key != "" {
+		ref["key"] = w.logArchive.Key
+	}
+	return unstructured.SetNestedMap(wf.Object, ref, "spec", "artifactRepositoryRef")
+}
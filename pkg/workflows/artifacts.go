@@ -0,0 +1,90 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package workflows
+
+import (
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	addonmgrv1alpha1 "github.com/keikoproj/addon-manager/api/v1alpha1"
+)
+
+// Artifacts returns the objects the prereqs and install workflows of the addon apply, with the labels added to
+// submitted workflows and the workflow parameter references replaced by their values. Integrations delivering
+// the objects without running workflows use them.
+func Artifacts(addon *addonmgrv1alpha1.Addon) ([]*unstructured.Unstructured, error) {
+	w := &workflowLifecycle{addon: addon}
+	for _, step := range []addonmgrv1alpha1.LifecycleStep{addonmgrv1alpha1.Prereqs, addonmgrv1alpha1.Install} {
+		wt, err := addon.GetWorkflowType(step)
+		if err != nil {
+			return nil, err
+		}
+		if wt.Template == "" {
+			continue
+		}
+
+		wf := &unstructured.Unstructured{}
+		if err := w.parse(wt, wf, string(step)); err != nil {
+			return nil, fmt.Errorf("invalid %s workflow. %v", step, err)
+		}
+		if err := w.configureWorkflowArtifacts(wf, wt); err != nil {
+			return nil, err
+		}
+	}
+
+	// The workflow parameters are the addon params
+	wf := &unstructured.Unstructured{Object: map[string]interface{}{"spec": map[string]interface{}{}}}
+	if !w.configureGlobalWFParameters(addon, wf) {
+		return nil, fmt.Errorf("invalid workflow parameter")
+	}
+	replacer := paramReplacer(wf)
+	for _, obj := range w.objects {
+		obj.Object = substitute(obj.Object, replacer).(map[string]interface{})
+	}
+	return w.objects, nil
+}
+
+// paramReplacer replaces the references to the global parameters of the workflow with their values
+func paramReplacer(wf *unstructured.Unstructured) *strings.Replacer {
+	params, _, _ := unstructured.NestedSlice(wf.Object, "spec", "arguments", "parameters")
+	var replacements []string
+	for _, p := range params {
+		p := p.(map[string]interface{})
+		replacements = append(replacements, fmt.Sprintf("{{workflow.parameters.%s}}", p["name"]), fmt.Sprintf("%v", p["value"]))
+	}
+	return strings.NewReplacer(replacements...)
+}
+
+// substitute replaces the parameter references in all string fields and keys
+func substitute(obj interface{}, replacer *strings.Replacer) interface{} {
+	switch v := obj.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for k, val := range v {
+			out[replacer.Replace(k)] = substitute(val, replacer)
+		}
+		return out
+	case []interface{}:
+		for i, val := range v {
+			v[i] = substitute(val, replacer)
+		}
+		return v
+	case string:
+		return replacer.Replace(v)
+	}
+	return obj
+}
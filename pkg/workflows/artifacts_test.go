@@ -0,0 +1,53 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package workflows
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/keikoproj/addon-manager/api/v1alpha1"
+)
+
+func TestArtifacts(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	addon := &v1alpha1.Addon{
+		ObjectMeta: metav1.ObjectMeta{Name: "event-router", Namespace: "default"},
+		Spec: v1alpha1.AddonSpec{
+			PackageSpec: v1alpha1.PackageSpec{PkgName: "event-router", PkgVersion: "0.2.0"},
+			Params:      v1alpha1.AddonParams{Namespace: "event-router-ns"},
+			Lifecycle: v1alpha1.LifecycleWorkflowSpec{
+				Prereqs: v1alpha1.WorkflowType{Template: wfPrereqsTemplate},
+				Install: v1alpha1.WorkflowType{Template: wfSpecTemplate},
+			},
+		},
+	}
+
+	objects, err := Artifacts(addon)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(objects).To(HaveLen(6))
+	g.Expect(objects[0].GetKind()).To(Equal("Namespace"))
+	g.Expect(objects[0].GetName()).To(Equal("event-router-ns"))
+	g.Expect(objects[5].GetKind()).To(Equal("Deployment"))
+	g.Expect(objects[5].GetNamespace()).To(Equal("event-router-ns"))
+	g.Expect(objects[5].GetLabels()).To(HaveKeyWithValue("app.kubernetes.io/version", "0.2.0"))
+
+	addon.Spec.Lifecycle.Install.Template = wfInvalidTemplate
+	_, err = Artifacts(addon)
+	g.Expect(err).To(HaveOccurred())
+}
@@ -0,0 +1,301 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package workflows
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/keikoproj/addon-manager/pkg/common"
+)
+
+const (
+	// ChecksumLabel is the checksum of the addon spec a workflow was submitted for
+	ChecksumLabel = "addonmgr.keikoproj.io/checksum"
+	// AddonIndex indexes cached workflows by the namespace/name of their addon
+	AddonIndex = "addon"
+	// ChecksumIndex indexes cached workflows by the namespace/name/checksum of the addon spec they were submitted for
+	ChecksumIndex = "addon-checksum"
+	// listPageSize is the number of workflows requested per list call when workflows are not cached
+	listPageSize = 500
+)
+
+// Indexers are the workflow informer indexes addon workflows are looked up with
+var Indexers = cache.Indexers{
+	AddonIndex:    addonIndexFunc,
+	ChecksumIndex: checksumIndexFunc,
+}
+
+// lastAppliedAnnotation holds the whole object applied with kubectl apply
+const lastAppliedAnnotation = "kubectl.kubernetes.io/last-applied-configuration"
+
+// NewWorkflowInformer returns an informer with the Indexers caching the workflows in namespace stripped by
+// StripWorkflow, the lifecycle only reads the metadata and status of cached workflows
+func NewWorkflowInformer(client dynamic.Interface, namespace string, resync time.Duration) cache.SharedIndexInformer {
+	resource := client.Resource(common.WorkflowGVR()).Namespace(namespace)
+	lw := &cache.ListWatch{
+		ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+			list, err := resource.List(context.TODO(), options)
+			if err != nil {
+				return nil, err
+			}
+			for i := range list.Items {
+				StripWorkflow(&list.Items[i])
+			}
+			return list, nil
+		},
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			w, err := resource.Watch(context.TODO(), options)
+			if err != nil {
+				return nil, err
+			}
+			return watch.Filter(w, func(e watch.Event) (watch.Event, bool) {
+				if wf, ok := e.Object.(*unstructured.Unstructured); ok {
+					StripWorkflow(wf)
+				}
+				return e, true
+			}), nil
+		},
+	}
+
+	indexers := cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc}
+	for name, fn := range Indexers {
+		indexers[name] = fn
+	}
+	return cache.NewSharedIndexInformer(lw, &unstructured.Unstructured{}, resync, indexers)
+}
+
+// StripWorkflow removes the templates and artifact data of a workflow, which can be large, keeping its metadata
+// and the phase and progress of its status
+func StripWorkflow(wf *unstructured.Unstructured) {
+	delete(wf.Object, "spec")
+	wf.SetManagedFields(nil)
+	if annotations := wf.GetAnnotations(); annotations[lastAppliedAnnotation] != "" {
+		delete(annotations, lastAppliedAnnotation)
+		wf.SetAnnotations(annotations)
+	}
+
+	status, ok := wf.Object["status"].(map[string]interface{})
+	if !ok {
+		return
+	}
+	delete(status, "storedTemplates")
+	delete(status, "storedWorkflowTemplateSpec")
+	delete(status, "outputs")
+	nodes, _ := status["nodes"].(map[string]interface{})
+	for _, n := range nodes {
+		if node, ok := n.(map[string]interface{}); ok {
+			delete(node, "inputs")
+			delete(node, "outputs")
+		}
+	}
+}
+
+// WithWorkflowCache reads the workflows of addons in namespace from an informer cache with the Indexers instead of
+// the API server. Workflows in other namespaces and in remote clusters are read from the API server.
+func WithWorkflowCache(indexer cache.Indexer, namespace string) Option {
+	return func(w *workflowLifecycle) {
+		w.indexer = indexer
+		w.indexerNamespace = namespace
+	}
+}
+
+func addonIndexFunc(obj interface{}) ([]string, error) {
+	wf, err := meta(obj)
+	if err != nil {
+		return nil, err
+	}
+	if addon := workflowAddon(wf); addon != "" {
+		return []string{workflowAddonNamespace(wf) + "/" + addon}, nil
+	}
+	return nil, nil
+}
+
+func checksumIndexFunc(obj interface{}) ([]string, error) {
+	wf, err := meta(obj)
+	if err != nil {
+		return nil, err
+	}
+	addon, checksum := workflowAddon(wf), workflowChecksum(wf)
+	if addon != "" && checksum != "" {
+		return []string{workflowAddonNamespace(wf) + "/" + addon + "/" + checksum}, nil
+	}
+	return nil, nil
+}
+
+func meta(obj interface{}) (metav1.Object, error) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	wf, ok := obj.(metav1.Object)
+	if !ok {
+		return nil, fmt.Errorf("unexpected workflow type %T in cache", obj)
+	}
+	return wf, nil
+}
+
+// workflowAddon returns the name of the addon of a workflow from its addon label, workflows submitted before they
+// were labelled are matched by their controller reference
+func workflowAddon(wf metav1.Object) string {
+	if addon := wf.GetLabels()[AddonLabel]; addon != "" {
+		return addon
+	}
+	if ref := metav1.GetControllerOf(wf); ref != nil && ref.Kind == "Addon" {
+		return ref.Name
+	}
+	return ""
+}
+
+// workflowChecksum returns the addon checksum of a workflow from its checksum label, or else from the workflow
+// name formatted as <name>-<step>-<checksum>-wf
+func workflowChecksum(wf metav1.Object) string {
+	if checksum := wf.GetLabels()[ChecksumLabel]; checksum != "" {
+		return checksum
+	}
+	parts := strings.Split(wf.GetName(), "-")
+	if len(parts) < 3 || parts[len(parts)-1] != "wf" {
+		return ""
+	}
+	return parts[len(parts)-2]
+}
+
+// labelWorkflow labels a workflow with its addon and the checksum of the addon spec
+func (w *workflowLifecycle) labelWorkflow(wf metav1.Object) {
+	labels := wf.GetLabels()
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	for k, v := range w.addonSelector() {
+		labels[k] = v
+	}
+	labels[ChecksumLabel] = w.addon.GetChecksum()
+	wf.SetLabels(labels)
+}
+
+// cachedWorkflows returns the indexer of the workflows in namespace, nil when they are not cached
+func (w *workflowLifecycle) cachedWorkflows(namespace string) cache.Indexer {
+	if w.indexer == nil || w.remote || namespace != w.indexerNamespace {
+		return nil
+	}
+	return w.indexer
+}
+
+// getWorkflow returns the workflow, nil when it does not exist. Cached workflows are returned without a copy and
+// must not be modified.
+func (w *workflowLifecycle) getWorkflow(ctx context.Context, name types.NamespacedName) (*unstructured.Unstructured, error) {
+	if indexer := w.cachedWorkflows(name.Namespace); indexer != nil {
+		obj, exists, err := indexer.GetByKey(name.String())
+		if err != nil || !exists {
+			return nil, err
+		}
+		wf, ok := obj.(*unstructured.Unstructured)
+		if !ok {
+			return nil, fmt.Errorf("unexpected workflow type %T in cache", obj)
+		}
+		return wf, nil
+	}
+
+	found := &unstructured.Unstructured{}
+	found.SetGroupVersionKind(schema.GroupVersionKind{
+		Kind:    "Workflow",
+		Group:   "argoproj.io",
+		Version: "v1alpha1",
+	})
+	err := w.Get(ctx, name, found)
+	if err != nil && apierrors.IsNotFound(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	return found, nil
+}
+
+// addonWorkflows returns the workflows of the addon, only those submitted for checksum when it is set. Cached
+// workflows are looked up by index and returned without a copy, they must not be modified.
+func (w *workflowLifecycle) addonWorkflows(ctx context.Context, checksum string) ([]*unstructured.Unstructured, error) {
+	ns, name := w.workflowNamespace(), w.addon.GetName()
+	if indexer := w.cachedWorkflows(ns); indexer != nil {
+		index, key := AddonIndex, w.addon.GetNamespace()+"/"+name
+		if checksum != "" {
+			index, key = ChecksumIndex, key+"/"+checksum
+		}
+		objs, err := indexer.ByIndex(index, key)
+		if err != nil {
+			return nil, err
+		}
+		items := make([]*unstructured.Unstructured, 0, len(objs))
+		for _, obj := range objs {
+			if wf, ok := obj.(*unstructured.Unstructured); ok {
+				items = append(items, wf)
+			}
+		}
+		return items, nil
+	}
+
+	var items []*unstructured.Unstructured
+	selector := w.addonSelector()
+	if checksum != "" {
+		selector[ChecksumLabel] = checksum
+	}
+	err := w.listWorkflows(ctx, ns, selector.String(), func(wf *unstructured.Unstructured) {
+		items = append(items, wf)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Workflows submitted before they were labelled are matched by owner and name
+	err = w.listWorkflows(ctx, ns, "!"+AddonLabel, func(wf *unstructured.Unstructured) {
+		if workflowAddon(wf) == name && (checksum == "" || workflowChecksum(wf) == checksum) {
+			items = append(items, wf)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// listWorkflows calls visit for the workflows in namespace matching the label selector, they are listed in pages of
+// listPageSize so namespaces with many workflows do not need a single large response
+func (w *workflowLifecycle) listWorkflows(ctx context.Context, namespace, selector string, visit func(*unstructured.Unstructured)) error {
+	opts := metav1.ListOptions{LabelSelector: selector, Limit: listPageSize}
+	for {
+		list, err := w.dynClient.Resource(common.WorkflowGVR()).Namespace(namespace).List(ctx, opts)
+		if err != nil {
+			return err
+		}
+		for i := range list.Items {
+			visit(&list.Items[i])
+		}
+		if list.GetContinue() == "" {
+			return nil
+		}
+		opts.Continue = list.GetContinue()
+	}
+}
@@ -0,0 +1,65 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package workflows
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// ParseCommonParams returns the ConfigMap holding the common workflow parameters given as namespace/name
+func ParseCommonParams(ref string) (types.NamespacedName, error) {
+	if ref == "" {
+		return types.NamespacedName{}, nil
+	}
+	parts := strings.Split(ref, "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return types.NamespacedName{}, fmt.Errorf("invalid common params configmap %q, expected namespace/name", ref)
+	}
+	return types.NamespacedName{Namespace: parts[0], Name: parts[1]}, nil
+}
+
+// WithCommonParams adds the cluster wide global workflow parameters shared by every addon, e.g. the DNS zone or the
+// VPC ID. Parameters of the workflow or the addon with the same name take precedence.
+func WithCommonParams(params map[string]string) Option {
+	return func(w *workflowLifecycle) {
+		w.commonParams = params
+	}
+}
+
+// appendCommonParams appends the common parameters that are not in params, in name order
+func (w *workflowLifecycle) appendCommonParams(params []interface{}) []interface{} {
+	set := make(map[string]bool, len(params))
+	for _, p := range params {
+		if p, ok := p.(map[string]interface{}); ok {
+			set[fmt.Sprintf("%v", p["name"])] = true
+		}
+	}
+
+	var names []string
+	for name := range w.commonParams {
+		if !set[name] {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		params = append(params, param(name, w.commonParams[name]))
+	}
+	return params
+}
@@ -0,0 +1,78 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package workflows
+
+import (
+	"sort"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	addonmgrv1alpha1 "github.com/keikoproj/addon-manager/api/v1alpha1"
+)
+
+const (
+	// ClusterNameEnv is the environment variable of workflow containers set to the cluster name of the addon context
+	ClusterNameEnv = "CLUSTER_NAME"
+	// ClusterRegionEnv is the environment variable of workflow containers set to the cluster region of the addon context
+	ClusterRegionEnv = "CLUSTER_REGION"
+)
+
+// contextEnv returns the environment variables of the cluster context, additional configs are named with ParamEnvName
+func contextEnv(ctx addonmgrv1alpha1.ClusterContext) []interface{} {
+	env := make([]interface{}, 0, len(ctx.AdditionalConfigs)+2)
+	if name := ctx.GetClusterName(); name != "" {
+		env = append(env, map[string]interface{}{"name": ClusterNameEnv, "value": name})
+	}
+	if region := ctx.GetRegion(); region != "" {
+		env = append(env, map[string]interface{}{"name": ClusterRegionEnv, "value": region})
+	}
+	names := make([]string, 0, len(ctx.AdditionalConfigs))
+	for name := range ctx.AdditionalConfigs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		value, _ := ctx.GetConfig(name)
+		env = append(env, map[string]interface{}{"name": ParamEnvName(name), "value": value})
+	}
+	return env
+}
+
+// injectContextEnv exposes the addon cluster context to every workflow container as environment variables in
+// addition to the global parameters, variables the containers define are kept
+func (w *workflowLifecycle) injectContextEnv(wf *unstructured.Unstructured) error {
+	env := contextEnv(w.addon.Spec.Params.Context)
+	if len(env) == 0 {
+		return nil
+	}
+
+	templates, _, err := unstructured.NestedFieldNoCopy(wf.Object, "spec", "templates")
+	if err != nil {
+		return err
+	}
+	list, _ := templates.([]interface{})
+	for _, t := range list {
+		template, ok := t.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		for _, key := range []string{"container", "script"} {
+			if container, ok := template[key].(map[string]interface{}); ok {
+				addEnv(container, env)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,151 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package fakes provides in-memory implementations of the workflows interfaces for unit tests that run without a
+// cluster
+package fakes
+
+import (
+	"context"
+	"sync"
+
+	addonmgrv1alpha1 "github.com/keikoproj/addon-manager/api/v1alpha1"
+	"github.com/keikoproj/addon-manager/pkg/workflows"
+)
+
+var _ workflows.AddonLifecycle = &AddonLifecycle{}
+
+// Result is the outcome of an Install call
+type Result struct {
+	Phase addonmgrv1alpha1.ApplicationAssemblyPhase
+	Err   error
+}
+
+// InstallCall records the arguments of an Install call
+type InstallCall struct {
+	Workflow *addonmgrv1alpha1.WorkflowType
+	Name     string
+}
+
+// AddonLifecycle is an in-memory AddonLifecycle recording its calls. Install returns the results scripted for the
+// workflow name in order, repeating the last one, and Succeeded for workflows without results. It is safe for
+// concurrent use.
+type AddonLifecycle struct {
+	mu           sync.Mutex
+	results      map[string][]Result
+	deleteErrors map[string]error
+	installs     []InstallCall
+	deletes      []string
+	collects     []string
+}
+
+// NewAddonLifecycle returns a fake AddonLifecycle without scripted results
+func NewAddonLifecycle() *AddonLifecycle {
+	return &AddonLifecycle{
+		results:      make(map[string][]Result),
+		deleteErrors: make(map[string]error),
+	}
+}
+
+// SetInstall scripts the results of the next Install calls for the workflow name
+func (f *AddonLifecycle) SetInstall(name string, results ...Result) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.results[name] = results
+}
+
+// SetPhases scripts the phases of the next Install calls for the workflow name
+func (f *AddonLifecycle) SetPhases(name string, phases ...addonmgrv1alpha1.ApplicationAssemblyPhase) {
+	results := make([]Result, 0, len(phases))
+	for _, phase := range phases {
+		results = append(results, Result{Phase: phase})
+	}
+	f.SetInstall(name, results...)
+}
+
+// SetDeleteError makes Delete of the workflow name fail with err, a nil err clears it
+func (f *AddonLifecycle) SetDeleteError(name string, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err == nil {
+		delete(f.deleteErrors, name)
+		return
+	}
+	f.deleteErrors[name] = err
+}
+
+// Install implements workflows.AddonLifecycle
+func (f *AddonLifecycle) Install(_ context.Context, wt *addonmgrv1alpha1.WorkflowType, name string) (addonmgrv1alpha1.ApplicationAssemblyPhase, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.installs = append(f.installs, InstallCall{Workflow: wt.DeepCopy(), Name: name})
+
+	results := f.results[name]
+	if len(results) == 0 {
+		return addonmgrv1alpha1.Succeeded, nil
+	}
+	res := results[0]
+	if len(results) > 1 {
+		f.results[name] = results[1:]
+	}
+	return res.Phase, res.Err
+}
+
+// Delete implements workflows.AddonLifecycle
+func (f *AddonLifecycle) Delete(_ context.Context, name string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.deletes = append(f.deletes, name)
+	return f.deleteErrors[name]
+}
+
+// Collect implements workflows.AddonLifecycle, every collected workflow is reported as deleted
+func (f *AddonLifecycle) Collect(_ context.Context, name string) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.collects = append(f.collects, name)
+	return true, nil
+}
+
+// Installs returns the Install calls in order
+func (f *AddonLifecycle) Installs() []InstallCall {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]InstallCall(nil), f.installs...)
+}
+
+// Deletes returns the workflow names of the Delete calls in order
+func (f *AddonLifecycle) Deletes() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.deletes...)
+}
+
+// Collects returns the workflow names of the Collect calls in order
+func (f *AddonLifecycle) Collects() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.collects...)
+}
+
+// Reset clears the recorded calls and scripted results
+func (f *AddonLifecycle) Reset() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.results = make(map[string][]Result)
+	f.deleteErrors = make(map[string]error)
+	f.installs = nil
+	f.deletes = nil
+	f.collects = nil
+}
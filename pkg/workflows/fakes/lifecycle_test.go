@@ -0,0 +1,72 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package fakes
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	addonmgrv1alpha1 "github.com/keikoproj/addon-manager/api/v1alpha1"
+)
+
+func TestAddonLifecycle(t *testing.T) {
+	g := NewGomegaWithT(t)
+	ctx := context.TODO()
+	f := NewAddonLifecycle()
+	wt := &addonmgrv1alpha1.WorkflowType{Template: "kind: Workflow"}
+
+	// Unscripted workflows succeed
+	phase, err := f.Install(ctx, wt, "other-install-wf")
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(phase).To(Equal(addonmgrv1alpha1.Succeeded))
+
+	f.SetPhases("addon-install-wf", addonmgrv1alpha1.Pending, addonmgrv1alpha1.Succeeded)
+	for _, expected := range []addonmgrv1alpha1.ApplicationAssemblyPhase{addonmgrv1alpha1.Pending, addonmgrv1alpha1.Succeeded, addonmgrv1alpha1.Succeeded} {
+		phase, err = f.Install(ctx, wt, "addon-install-wf")
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(phase).To(Equal(expected))
+	}
+
+	f.SetInstall("addon-prereqs-wf", Result{Phase: addonmgrv1alpha1.Failed, Err: errors.New("boom")})
+	phase, err = f.Install(ctx, wt, "addon-prereqs-wf")
+	g.Expect(err).To(MatchError("boom"))
+	g.Expect(phase).To(Equal(addonmgrv1alpha1.Failed))
+
+	// Calls are recorded with a copy of the workflow
+	wt.Template = "changed"
+	installs := f.Installs()
+	g.Expect(installs).To(HaveLen(5))
+	g.Expect(installs[1].Name).To(Equal("addon-install-wf"))
+	g.Expect(installs[1].Workflow.Template).To(Equal("kind: Workflow"))
+
+	f.SetDeleteError("addon-delete-wf", errors.New("denied"))
+	g.Expect(f.Delete(ctx, "addon-delete-wf")).To(MatchError("denied"))
+	f.SetDeleteError("addon-delete-wf", nil)
+	g.Expect(f.Delete(ctx, "addon-delete-wf")).To(Succeed())
+	g.Expect(f.Deletes()).To(Equal([]string{"addon-delete-wf", "addon-delete-wf"}))
+
+	collected, err := f.Collect(ctx, "addon-install-wf")
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(collected).To(BeTrue())
+	g.Expect(f.Collects()).To(Equal([]string{"addon-install-wf"}))
+
+	f.Reset()
+	g.Expect(f.Installs()).To(BeEmpty())
+	g.Expect(f.Deletes()).To(BeEmpty())
+	g.Expect(f.Collects()).To(BeEmpty())
+}
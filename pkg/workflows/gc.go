@@ -0,0 +1,74 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package workflows
+
+import (
+	"context"
+	"fmt"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/keikoproj/addon-manager/pkg/common"
+)
+
+// GCPolicy is when the succeeded lifecycle workflows of an addon are deleted
+type GCPolicy string
+
+const (
+	// GCAfterTTL leaves succeeded workflows to the Argo TTL, 72 hours unless the template sets ttlSecondsAfterFinished
+	GCAfterTTL GCPolicy = "ttl"
+	// GCOnStatusRecorded deletes succeeded workflows once their result is recorded in the addon status, for clusters
+	// where workflows are a quota limited resource. Failed workflows are left to the TTL for troubleshooting.
+	GCOnStatusRecorded GCPolicy = "status-recorded"
+)
+
+// ParseGCPolicy returns the workflow garbage collection policy, the TTL policy when s is empty
+func ParseGCPolicy(s string) (GCPolicy, error) {
+	switch p := GCPolicy(s); p {
+	case "":
+		return GCAfterTTL, nil
+	case GCAfterTTL, GCOnStatusRecorded:
+		return p, nil
+	}
+	return "", fmt.Errorf("invalid workflow gc policy %q, expected %s or %s", s, GCAfterTTL, GCOnStatusRecorded)
+}
+
+// Collect deletes the workflow when it succeeded, it returns true when the workflow was deleted. Workflows that are
+// already gone, e.g. deleted by an earlier reconcile, are not looked up in the API server again while cached.
+func (w *workflowLifecycle) Collect(ctx context.Context, name string) (bool, error) {
+	if w.simulator != nil {
+		return false, nil
+	}
+
+	name = QualifyName(w.addon, w.namespace, name)
+	wf, err := w.getWorkflow(ctx, types.NamespacedName{Namespace: w.workflowNamespace(), Name: name})
+	if err != nil || wf == nil {
+		return false, err
+	}
+	if phase, _, _ := unstructured.NestedString(wf.Object, "status", "phase"); phase != "Succeeded" {
+		return false, nil
+	}
+
+	err = w.dynClient.Resource(common.WorkflowGVR()).Namespace(w.workflowNamespace()).Delete(ctx, name, metav1.DeleteOptions{})
+	if apierrors.IsNotFound(err) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	return true, nil
+}
@@ -0,0 +1,81 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package workflows
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	dynfake "k8s.io/client-go/dynamic/fake"
+	runtimefake "sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/keikoproj/addon-manager/api/v1alpha1"
+	"github.com/keikoproj/addon-manager/pkg/common"
+)
+
+func gcWorkflow(name, phase string) *unstructured.Unstructured {
+	wf := &unstructured.Unstructured{}
+	wf.SetAPIVersion("argoproj.io/v1alpha1")
+	wf.SetKind("Workflow")
+	wf.SetNamespace("default")
+	wf.SetName(name)
+	_ = unstructured.SetNestedField(wf.Object, phase, "status", "phase")
+	return wf
+}
+
+func TestParseGCPolicy(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	p, err := ParseGCPolicy("")
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(p).To(Equal(GCAfterTTL))
+	p, err = ParseGCPolicy("status-recorded")
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(p).To(Equal(GCOnStatusRecorded))
+	_, err = ParseGCPolicy("never")
+	g.Expect(err).To(HaveOccurred())
+}
+
+func TestWorkflowLifecycle_Collect(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	a := &v1alpha1.Addon{ObjectMeta: metav1.ObjectMeta{Name: "foo", Namespace: "default"}}
+	succeeded, failed := gcWorkflow("foo-install-wf", "Succeeded"), gcWorkflow("foo-prereqs-wf", "Failed")
+	c := runtimefake.NewFakeClientWithScheme(runtime.NewScheme(), succeeded.DeepCopy(), failed.DeepCopy())
+	dc := dynfake.NewSimpleDynamicClient(runtime.NewScheme(), succeeded.DeepCopy(), failed.DeepCopy())
+	wfl := NewWorkflowLifecycle(c, dc, a, rcdr, sch).(*workflowLifecycle)
+
+	// Succeeded workflows are deleted
+	collected, err := wfl.Collect(ctx, "foo-install-wf")
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(collected).To(BeTrue())
+	_, err = dc.Resource(common.WorkflowGVR()).Namespace("default").Get(ctx, "foo-install-wf", metav1.GetOptions{})
+	g.Expect(err).To(HaveOccurred())
+
+	// Failed workflows are left to the TTL
+	collected, err = wfl.Collect(ctx, "foo-prereqs-wf")
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(collected).To(BeFalse())
+	_, err = dc.Resource(common.WorkflowGVR()).Namespace("default").Get(ctx, "foo-prereqs-wf", metav1.GetOptions{})
+	g.Expect(err).ToNot(HaveOccurred())
+
+	// Missing workflows are ignored
+	collected, err = wfl.Collect(ctx, "foo-validate-wf")
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(collected).To(BeFalse())
+}
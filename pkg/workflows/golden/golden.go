@@ -0,0 +1,81 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package golden snapshot tests the workflows the manager renders for addon specs against golden files, so addon
+// package repos notice when their spec or a manager upgrade changes the submitted workflows. Golden files are
+// written instead of compared when the UPDATE_GOLDEN environment variable is set.
+package golden
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"sigs.k8s.io/yaml"
+
+	addonmgrv1alpha1 "github.com/keikoproj/addon-manager/api/v1alpha1"
+	"github.com/keikoproj/addon-manager/pkg/plan"
+	"github.com/keikoproj/addon-manager/pkg/workflows"
+)
+
+// UpdateEnv is the environment variable that makes Compare write golden files
+const UpdateEnv = "UPDATE_GOLDEN"
+
+// Render returns the YAML of the workflow the manager submits for the lifecycle step of the addon, empty when the
+// step has no workflow
+func Render(addon *addonmgrv1alpha1.Addon, step addonmgrv1alpha1.LifecycleStep, opts ...workflows.Option) ([]byte, error) {
+	wf, err := workflows.RenderWorkflow(addon, step, opts...)
+	if err != nil || wf == nil {
+		return nil, err
+	}
+	return yaml.Marshal(wf.Object)
+}
+
+// Compare returns an error with a diff when actual differs from the golden file at path. The golden file is
+// written with actual when UpdateEnv is set.
+func Compare(path string, actual []byte) error {
+	if os.Getenv(UpdateEnv) != "" {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return err
+		}
+		return ioutil.WriteFile(path, actual, 0644)
+	}
+
+	expected, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return fmt.Errorf("golden file %s does not exist, set %s=1 to create it", path, UpdateEnv)
+	} else if err != nil {
+		return err
+	}
+	if !bytes.Equal(expected, actual) {
+		return fmt.Errorf("golden file %s does not match, set %s=1 to update it\n%s", path, UpdateEnv, plan.Diff(string(expected), string(actual)))
+	}
+	return nil
+}
+
+// AssertWorkflow fails the test when the workflow rendered for the lifecycle step of the addon differs from the
+// golden file at path
+func AssertWorkflow(t testing.TB, path string, addon *addonmgrv1alpha1.Addon, step addonmgrv1alpha1.LifecycleStep, opts ...workflows.Option) {
+	t.Helper()
+	actual, err := Render(addon, step, opts...)
+	if err != nil {
+		t.Fatalf("failed to render %s workflow. %v", step, err)
+	}
+	if err := Compare(path, actual); err != nil {
+		t.Error(err)
+	}
+}
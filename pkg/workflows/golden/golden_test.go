@@ -0,0 +1,88 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package golden
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	addonmgrv1alpha1 "github.com/keikoproj/addon-manager/api/v1alpha1"
+)
+
+const installTemplate = `
+apiVersion: argoproj.io/v1alpha1
+kind: Workflow
+spec:
+  entrypoint: entry
+  templates:
+  - name: entry
+    resource:
+      action: apply
+      manifest: |
+        apiVersion: v1
+        kind: ConfigMap
+        metadata:
+          name: golden
+          namespace: "{{workflow.parameters.namespace}}"
+`
+
+func newGoldenAddon() *addonmgrv1alpha1.Addon {
+	return &addonmgrv1alpha1.Addon{
+		ObjectMeta: metav1.ObjectMeta{Name: "golden", Namespace: "default"},
+		Spec: addonmgrv1alpha1.AddonSpec{
+			PackageSpec: addonmgrv1alpha1.PackageSpec{PkgName: "golden", PkgVersion: "1.0.0"},
+			Params:      addonmgrv1alpha1.AddonParams{Namespace: "golden-ns"},
+			Lifecycle: addonmgrv1alpha1.LifecycleWorkflowSpec{
+				Install: addonmgrv1alpha1.WorkflowType{Template: installTemplate},
+			},
+		},
+	}
+}
+
+func TestCompare(t *testing.T) {
+	g := NewGomegaWithT(t)
+	path := filepath.Join(t.TempDir(), "testdata", "install.yaml")
+	a := newGoldenAddon()
+
+	actual, err := Render(a, addonmgrv1alpha1.Install)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(string(actual)).To(ContainSubstring("name: golden-install-"))
+	g.Expect(Compare(path, actual)).To(MatchError(ContainSubstring("does not exist")))
+
+	os.Setenv(UpdateEnv, "1")
+	err = Compare(path, actual)
+	os.Unsetenv(UpdateEnv)
+	g.Expect(err).ToNot(HaveOccurred())
+	written, err := ioutil.ReadFile(path)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(written).To(Equal(actual))
+
+	AssertWorkflow(t, path, a, addonmgrv1alpha1.Install)
+
+	// Spec changes show up as a diff
+	a.Spec.PkgVersion = "1.0.1"
+	changed, err := Render(a, addonmgrv1alpha1.Install)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(Compare(path, changed)).To(MatchError(ContainSubstring("+       value: 1.0.1")))
+
+	empty, err := Render(a, addonmgrv1alpha1.Delete)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(empty).To(BeEmpty())
+}
@@ -0,0 +1,239 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package workflows
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/dynamic"
+
+	addonmgrv1alpha1 "github.com/keikoproj/addon-manager/api/v1alpha1"
+	"github.com/keikoproj/addon-manager/pkg/common"
+)
+
+// HelmImage is the image of the generated workflows installing Helm charts
+var HelmImage = "alpine/helm:3.4.2"
+
+// helmValuesPath is where the values of the chart are mounted in the generated workflows
+const helmValuesPath = "/tmp/values.yaml"
+
+// IsHelmChart returns true for helm packages installing a chart of a Helm repository without an install workflow,
+// their install and delete workflows are generated
+func IsHelmChart(addon *addonmgrv1alpha1.Addon) bool {
+	return addon.Spec.PkgType == addonmgrv1alpha1.HelmPkg && addon.Spec.Source != nil && addon.Spec.Source.Chart != "" &&
+		addon.Spec.Lifecycle.Install.Template == ""
+}
+
+// LifecycleWorkflow returns the workflow of the lifecycle step of the addon, the generated install and delete
+// workflows of helm charts
+func LifecycleWorkflow(addon *addonmgrv1alpha1.Addon, step addonmgrv1alpha1.LifecycleStep) (*addonmgrv1alpha1.WorkflowType, error) {
+	wt, err := addon.GetWorkflowType(step)
+	if err != nil || !IsHelmChart(addon) || wt.Template != "" || (step != addonmgrv1alpha1.Install && step != addonmgrv1alpha1.Delete) {
+		return wt, err
+	}
+
+	template, err := helmWorkflow(addon, step)
+	if err != nil {
+		return nil, err
+	}
+	generated := wt.DeepCopy()
+	generated.Template = template
+	return generated, nil
+}
+
+// helmRelease returns the release name and namespace of the chart of the addon
+func helmRelease(addon *addonmgrv1alpha1.Addon) (string, string) {
+	namespace := addon.Spec.Params.Namespace
+	if namespace == "" {
+		namespace = addon.GetNamespace()
+	}
+	return addon.GetName(), namespace
+}
+
+// helmChartVersion returns the version of the chart, the package version unless the source sets the target revision
+func helmChartVersion(addon *addonmgrv1alpha1.Addon) string {
+	if addon.Spec.Source.TargetRevision != "" {
+		return addon.Spec.Source.TargetRevision
+	}
+	return addon.Spec.PkgVersion
+}
+
+// helmWorkflow returns the workflow upgrading or uninstalling the release of the chart of the addon
+func helmWorkflow(addon *addonmgrv1alpha1.Addon, step addonmgrv1alpha1.LifecycleStep) (string, error) {
+	release, namespace := helmRelease(addon)
+	template := map[string]interface{}{"name": "helm"}
+	if step == addonmgrv1alpha1.Delete {
+		template["container"] = map[string]interface{}{
+			"image":   HelmImage,
+			"command": []interface{}{"helm"},
+			"args":    []interface{}{"uninstall", release, "--namespace", namespace},
+		}
+	} else {
+		values, err := helmValues(addon)
+		if err != nil {
+			return "", err
+		}
+		// Failed upgrades are rolled back so the previous release keeps running
+		template["container"] = map[string]interface{}{
+			"image":   HelmImage,
+			"command": []interface{}{"helm"},
+			"args": []interface{}{"upgrade", release, addon.Spec.Source.Chart, "--install", "--atomic",
+				"--repo", addon.Spec.Source.RepoURL, "--version", helmChartVersion(addon),
+				"--namespace", namespace, "--create-namespace", "--values", helmValuesPath},
+		}
+		template["inputs"] = map[string]interface{}{
+			"artifacts": []interface{}{
+				map[string]interface{}{"name": "values", "path": helmValuesPath, "raw": map[string]interface{}{"data": values}},
+			},
+		}
+	}
+
+	wf := map[string]interface{}{
+		"apiVersion": "argoproj.io/v1alpha1",
+		"kind":       "Workflow",
+		"metadata":   map[string]interface{}{"generateName": fmt.Sprintf("%s-%s-", addon.GetName(), step)},
+		"spec": map[string]interface{}{
+			"entrypoint":         "helm",
+			"serviceAccountName": "addon-manager-workflow-installer-sa",
+			"templates":          []interface{}{template},
+		},
+	}
+	data, err := yaml.Marshal(wf)
+	if err != nil {
+		return "", fmt.Errorf("unable to marshall %s workflow of chart %s. %v", step, addon.Spec.Source.Chart, err)
+	}
+	return string(data), nil
+}
+
+// helmValues returns the values of the source with the addon params set over them, dotted param names set nested values
+func helmValues(addon *addonmgrv1alpha1.Addon) (string, error) {
+	values := map[string]interface{}{}
+	if err := yaml.Unmarshal([]byte(addon.Spec.Source.Values), &values); err != nil {
+		return "", fmt.Errorf("invalid values of chart %s. %v", addon.Spec.Source.Chart, err)
+	}
+	if values == nil {
+		values = map[string]interface{}{}
+	}
+
+	params := addon.GetAllAddonParameters()
+	names := make([]string, 0, len(params))
+	for name, value := range params {
+		if value != "" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		path := strings.Split(name, ".")
+		m := values
+		for _, key := range path[:len(path)-1] {
+			next, ok := m[key].(map[string]interface{})
+			if !ok {
+				next = map[string]interface{}{}
+				m[key] = next
+			}
+			m = next
+		}
+		m[path[len(path)-1]] = params[name]
+	}
+
+	data, err := yaml.Marshal(values)
+	if err != nil {
+		return "", fmt.Errorf("unable to marshall values of chart %s. %v", addon.Spec.Source.Chart, err)
+	}
+	return string(data), nil
+}
+
+// HelmReleaseStatus returns the state of the latest revision of the release of the chart of the addon from the
+// release Secrets of Helm, nil when the release does not exist
+func HelmReleaseStatus(ctx context.Context, dynClient dynamic.Interface, addon *addonmgrv1alpha1.Addon) (*addonmgrv1alpha1.HelmReleaseStatus, error) {
+	release, namespace := helmRelease(addon)
+	list, err := dynClient.Resource(common.SecretGVR()).Namespace(namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("owner=helm,name=%s", release),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var status *addonmgrv1alpha1.HelmReleaseStatus
+	for i := range list.Items {
+		labels := list.Items[i].GetLabels()
+		revision, err := strconv.ParseInt(labels["version"], 10, 64)
+		if err != nil || (status != nil && revision <= status.Revision) {
+			continue
+		}
+		status = &addonmgrv1alpha1.HelmReleaseStatus{
+			Name:      release,
+			Namespace: namespace,
+			Revision:  revision,
+			Status:    labels["status"],
+		}
+		// Rolled back upgrades are revisions of the previous chart version
+		if chart, err := helmReleaseChart(&list.Items[i]); err == nil {
+			status.Chart, status.Version = chart.Name, chart.Version
+		} else {
+			status.Chart = addon.Spec.Source.Chart
+		}
+	}
+	return status, nil
+}
+
+// helmChartMetadata is the chart metadata of a Helm release
+type helmChartMetadata struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// helmReleaseChart returns the chart metadata of the release stored in a release Secret, Helm stores releases as
+// base64 encoded gzipped JSON
+func helmReleaseChart(secret *unstructured.Unstructured) (*helmChartMetadata, error) {
+	data, _, err := unstructured.NestedString(secret.Object, "data", "release")
+	if err != nil {
+		return nil, err
+	}
+	decoded, err := base64.StdEncoding.DecodeString(data)
+	if err != nil {
+		return nil, err
+	}
+	if decoded, err = base64.StdEncoding.DecodeString(string(decoded)); err != nil {
+		return nil, err
+	}
+	r, err := gzip.NewReader(bytes.NewReader(decoded))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	var release struct {
+		Chart struct {
+			Metadata helmChartMetadata `json:"metadata"`
+		} `json:"chart"`
+	}
+	if err := json.NewDecoder(r).Decode(&release); err != nil {
+		return nil, err
+	}
+	return &release.Chart.Metadata, nil
+}
@@ -0,0 +1,148 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package workflows
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"gopkg.in/yaml.v3"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynfake "k8s.io/client-go/dynamic/fake"
+
+	"github.com/keikoproj/addon-manager/api/v1alpha1"
+)
+
+func newHelmAddon() *v1alpha1.Addon {
+	return &v1alpha1.Addon{
+		ObjectMeta: metav1.ObjectMeta{Name: "metrics-server", Namespace: "addon-manager-system"},
+		Spec: v1alpha1.AddonSpec{
+			PackageSpec: v1alpha1.PackageSpec{PkgName: "metrics-server", PkgVersion: "2.11.4", PkgType: v1alpha1.HelmPkg},
+			Params: v1alpha1.AddonParams{
+				Namespace: "kube-system",
+				Data:      map[string]v1alpha1.FlexString{"args.kubelet-insecure-tls": "true"},
+			},
+			Source: &v1alpha1.AddonSource{
+				RepoURL: "https://charts.helm.sh/stable",
+				Chart:   "metrics-server",
+				Values:  "replicas: 2\nargs:\n  logtostderr: true\n",
+			},
+		},
+	}
+}
+
+func TestLifecycleWorkflow_HelmChart(t *testing.T) {
+	g := NewGomegaWithT(t)
+	a := newHelmAddon()
+
+	wt, err := LifecycleWorkflow(a, v1alpha1.Install)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(a.Spec.Lifecycle.Install.Template).To(BeEmpty())
+
+	var wf map[string]interface{}
+	g.Expect(yaml.Unmarshal([]byte(wt.Template), &wf)).To(Succeed())
+	templates, _, _ := unstructured.NestedSlice(wf, "spec", "templates")
+	g.Expect(templates).To(HaveLen(1))
+	args, _, _ := unstructured.NestedSlice(templates[0].(map[string]interface{}), "container", "args")
+	g.Expect(args).To(ContainElement("--atomic"))
+	g.Expect(args[:4]).To(Equal([]interface{}{"upgrade", "metrics-server", "metrics-server", "--install"}))
+	g.Expect(args).To(ContainElement("2.11.4"))
+	g.Expect(args).To(ContainElement("kube-system"))
+
+	artifacts, _, _ := unstructured.NestedSlice(templates[0].(map[string]interface{}), "inputs", "artifacts")
+	data, _, _ := unstructured.NestedString(artifacts[0].(map[string]interface{}), "raw", "data")
+	var values map[string]interface{}
+	g.Expect(yaml.Unmarshal([]byte(data), &values)).To(Succeed())
+	g.Expect(values).To(HaveKeyWithValue("replicas", 2))
+	g.Expect(values).To(HaveKeyWithValue("namespace", "kube-system"))
+	g.Expect(values["args"]).To(Equal(map[string]interface{}{"logtostderr": true, "kubelet-insecure-tls": "true"}))
+
+	wt, err = LifecycleWorkflow(a, v1alpha1.Delete)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(wt.Template).To(ContainSubstring("uninstall"))
+
+	// Rendered workflows are the generated ones
+	rendered, err := RenderWorkflow(a, v1alpha1.Install)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(rendered).ToNot(BeNil())
+
+	// Prereqs are not generated and packages with an install workflow are installed with it
+	wt, err = LifecycleWorkflow(a, v1alpha1.Prereqs)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(wt.Template).To(BeEmpty())
+	a.Spec.Lifecycle.Install.Template = wfSpecTemplate
+	wt, err = LifecycleWorkflow(a, v1alpha1.Install)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(wt.Template).To(Equal(wfSpecTemplate))
+
+	a = newHelmAddon()
+	a.Spec.Source.Values = "replicas: ["
+	_, err = LifecycleWorkflow(a, v1alpha1.Install)
+	g.Expect(err).To(HaveOccurred())
+}
+
+func TestHelmReleaseStatus(t *testing.T) {
+	g := NewGomegaWithT(t)
+	ctx := context.TODO()
+	a := newHelmAddon()
+
+	newReleaseSecret := func(revision, status, chartVersion string) *unstructured.Unstructured {
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		_, _ = zw.Write([]byte(`{"chart":{"metadata":{"name":"metrics-server","version":"` + chartVersion + `"}}}`))
+		g.Expect(zw.Close()).To(Succeed())
+		release := base64.StdEncoding.EncodeToString([]byte(base64.StdEncoding.EncodeToString(buf.Bytes())))
+
+		secret := &unstructured.Unstructured{Object: map[string]interface{}{"data": map[string]interface{}{"release": release}}}
+		secret.SetAPIVersion("v1")
+		secret.SetKind("Secret")
+		secret.SetNamespace("kube-system")
+		secret.SetName("sh.helm.release.v1.metrics-server.v" + revision)
+		secret.SetLabels(map[string]string{"owner": "helm", "name": "metrics-server", "version": revision, "status": status})
+		return secret
+	}
+
+	s := runtime.NewScheme()
+	s.AddKnownTypeWithName(schema.GroupVersionKind{Version: "v1", Kind: "Secret"}, &unstructured.Unstructured{})
+	s.AddKnownTypeWithName(schema.GroupVersionKind{Version: "v1", Kind: "SecretList"}, &unstructured.UnstructuredList{})
+	dyn := dynfake.NewSimpleDynamicClient(s)
+
+	status, err := HelmReleaseStatus(ctx, dyn, a)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(status).To(BeNil())
+
+	dyn = dynfake.NewSimpleDynamicClient(s,
+		newReleaseSecret("1", "superseded", "2.11.2"),
+		newReleaseSecret("2", "superseded", "2.11.4"),
+		newReleaseSecret("3", "deployed", "2.11.2"),
+	)
+	status, err = HelmReleaseStatus(ctx, dyn, a)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(status).To(Equal(&v1alpha1.HelmReleaseStatus{
+		Name:      "metrics-server",
+		Namespace: "kube-system",
+		Chart:     "metrics-server",
+		Version:   "2.11.2",
+		Revision:  3,
+		Status:    "deployed",
+	}))
+}
@@ -0,0 +1,111 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package workflows
+
+import (
+	"context"
+	"fmt"
+	"hash/adler32"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
+
+	addonmgrv1alpha1 "github.com/keikoproj/addon-manager/api/v1alpha1"
+	"github.com/keikoproj/addon-manager/pkg/common"
+)
+
+// AddonNamespaceLabel is the namespace of the addon of a workflow running outside of the addon namespace
+const AddonNamespaceLabel = "addonmgr.keikoproj.io/addon-namespace"
+
+// WithWorkflowNamespace runs the workflows of the addon in namespace instead of the addon namespace, so workflow
+// pods do not count against the quotas of tenants. Owner references can not point across namespaces, the workflows
+// are labelled with their addon instead and must be deleted with DeleteAddonWorkflows.
+func WithWorkflowNamespace(namespace string) Option {
+	return func(w *workflowLifecycle) {
+		w.namespace = namespace
+	}
+}
+
+// workflowNamespace returns the namespace the workflows of the addon run in
+func (w *workflowLifecycle) workflowNamespace() string {
+	if w.namespace != "" {
+		return w.namespace
+	}
+	return w.addon.GetNamespace()
+}
+
+// crossNamespace returns true when the workflows of the addon run outside of the addon namespace
+func (w *workflowLifecycle) crossNamespace() bool {
+	return w.workflowNamespace() != w.addon.GetNamespace()
+}
+
+// QualifyName returns the name of a workflow of the addon running in namespace. Names of workflows running outside
+// of the addon namespace are suffixed with a hash of the addon namespace, so the workflows of addons with the same
+// name in different namespaces do not collide.
+func QualifyName(addon *addonmgrv1alpha1.Addon, namespace, name string) string {
+	if namespace == "" || namespace == addon.GetNamespace() {
+		return name
+	}
+	return fmt.Sprintf("%s-%x", name, adler32.Checksum([]byte(addon.GetNamespace())))
+}
+
+// AddonOf returns the addon of a workflow from its labels or its controller reference
+func AddonOf(wf metav1.Object) (types.NamespacedName, bool) {
+	name := workflowAddon(wf)
+	if name == "" {
+		return types.NamespacedName{}, false
+	}
+	return types.NamespacedName{Namespace: workflowAddonNamespace(wf), Name: name}, true
+}
+
+// workflowAddonNamespace returns the namespace of the addon of a workflow, workflows without the addon namespace
+// label run in the addon namespace
+func workflowAddonNamespace(wf metav1.Object) string {
+	if ns := wf.GetLabels()[AddonNamespaceLabel]; ns != "" {
+		return ns
+	}
+	return wf.GetNamespace()
+}
+
+// addonSelector returns the label selector of the workflows of the addon
+func (w *workflowLifecycle) addonSelector() labels.Set {
+	selector := labels.Set{AddonLabel: w.addon.GetName()}
+	if w.crossNamespace() {
+		selector[AddonNamespaceLabel] = w.addon.GetNamespace()
+	}
+	return selector
+}
+
+// DeleteAddonWorkflows deletes the workflows and the params Secret of the addon running in namespace outside of the
+// addon namespace, they are not garbage collected with the addon
+func DeleteAddonWorkflows(ctx context.Context, dynClient dynamic.Interface, addon *addonmgrv1alpha1.Addon, namespace string) error {
+	if namespace == "" || namespace == addon.GetNamespace() {
+		return nil
+	}
+	w := &workflowLifecycle{addon: addon, namespace: namespace}
+	err := dynClient.Resource(common.WorkflowGVR()).Namespace(namespace).DeleteCollection(ctx, metav1.DeleteOptions{},
+		metav1.ListOptions{LabelSelector: w.addonSelector().String()})
+	if err != nil {
+		return err
+	}
+	err = dynClient.Resource(common.SecretGVR()).Namespace(namespace).Delete(ctx, QualifyName(addon, namespace, SecretParamsName(addon)), metav1.DeleteOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	return err
+}
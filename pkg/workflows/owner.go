@@ -0,0 +1,49 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package workflows
+
+import (
+	"fmt"
+)
+
+// OwnerMode is how the workflows and params Secrets of an addon in the addon namespace reference the addon
+type OwnerMode string
+
+const (
+	// ControllerOwner sets the addon as controller of its workflows, they are garbage collected with the addon in
+	// the foreground and can not be adopted by other controllers
+	ControllerOwner OwnerMode = "controller"
+	// LooseOwner adds an owner reference to the addon that does not block its deletion, the workflows are garbage
+	// collected with the addon in the background and may be controlled by other controllers
+	LooseOwner OwnerMode = "owner"
+)
+
+// ParseOwnerMode returns the owner mode, the controller mode when s is empty
+func ParseOwnerMode(s string) (OwnerMode, error) {
+	switch m := OwnerMode(s); m {
+	case "":
+		return ControllerOwner, nil
+	case ControllerOwner, LooseOwner:
+		return m, nil
+	}
+	return "", fmt.Errorf("invalid workflow owner mode %q, expected %s or %s", s, ControllerOwner, LooseOwner)
+}
+
+// WithOwnerMode references the addon from its workflows as set by m
+func WithOwnerMode(m OwnerMode) Option {
+	return func(w *workflowLifecycle) {
+		w.ownerMode = m
+	}
+}
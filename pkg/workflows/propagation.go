@@ -0,0 +1,132 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package workflows
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// PropagationPolicy selects the addon labels and annotations copied onto its workflows, workflow pods and every
+// artifact, e.g. cost-center or team tags for chargeback. Keys are matched as path.Match patterns so
+// compliance.example.com/* selects every key of a prefix. Addon values take precedence over the values of the
+// artifacts but not over the labels set by the manager, changes of addon metadata are propagated with the next
+// workflow of the addon.
+type PropagationPolicy struct {
+	Labels      []string
+	Annotations []string
+}
+
+// ParsePropagationPolicy returns the policy of the comma separated label and annotation key patterns
+func ParsePropagationPolicy(labels, annotations string) (PropagationPolicy, error) {
+	var p PropagationPolicy
+	var err error
+	if p.Labels, err = parseKeyPatterns(labels); err != nil {
+		return p, err
+	}
+	if p.Annotations, err = parseKeyPatterns(annotations); err != nil {
+		return p, err
+	}
+	return p, nil
+}
+
+func parseKeyPatterns(s string) ([]string, error) {
+	var patterns []string
+	for _, pattern := range strings.Split(s, ",") {
+		pattern = strings.TrimSpace(pattern)
+		if pattern == "" {
+			continue
+		}
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid key pattern %q. %v", pattern, err)
+		}
+		patterns = append(patterns, pattern)
+	}
+	return patterns, nil
+}
+
+// Empty returns true when the policy propagates nothing
+func (p PropagationPolicy) Empty() bool {
+	return len(p.Labels) == 0 && len(p.Annotations) == 0
+}
+
+// WithPropagationPolicy copies the addon labels and annotations selected by p onto its workflows and artifacts
+func WithPropagationPolicy(p PropagationPolicy) Option {
+	return func(w *workflowLifecycle) {
+		w.propagation = p
+	}
+}
+
+// propagate copies the selected metadata of the addon onto obj
+func (w *workflowLifecycle) propagate(obj metav1.Object) {
+	if labels := selectKeys(w.addon.GetLabels(), w.propagation.Labels); len(labels) > 0 {
+		obj.SetLabels(mergeKeys(obj.GetLabels(), labels))
+	}
+	if annotations := selectKeys(w.addon.GetAnnotations(), w.propagation.Annotations); len(annotations) > 0 {
+		obj.SetAnnotations(mergeKeys(obj.GetAnnotations(), annotations))
+	}
+}
+
+// injectPropagatedMetadata copies the selected metadata of the addon onto the workflow pods
+func (w *workflowLifecycle) injectPropagatedMetadata(wf *unstructured.Unstructured) error {
+	fields := []struct {
+		name     string
+		selected map[string]string
+	}{
+		{"labels", selectKeys(w.addon.GetLabels(), w.propagation.Labels)},
+		{"annotations", selectKeys(w.addon.GetAnnotations(), w.propagation.Annotations)},
+	}
+	for _, f := range fields {
+		if len(f.selected) == 0 {
+			continue
+		}
+		existing, _, err := unstructured.NestedStringMap(wf.Object, "spec", "podMetadata", f.name)
+		if err != nil {
+			return err
+		}
+		if err := unstructured.SetNestedStringMap(wf.Object, mergeKeys(existing, f.selected), "spec", "podMetadata", f.name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// selectKeys returns the entries of m with a key matching one of the patterns
+func selectKeys(m map[string]string, patterns []string) map[string]string {
+	selected := map[string]string{}
+	for k, v := range m {
+		for _, pattern := range patterns {
+			if ok, _ := path.Match(pattern, k); ok {
+				selected[k] = v
+				break
+			}
+		}
+	}
+	return selected
+}
+
+func mergeKeys(dst, src map[string]string) map[string]string {
+	if dst == nil {
+		dst = map[string]string{}
+	}
+	for k, v := range src {
+		dst[k] = v
+	}
+	return dst
+}
@@ -0,0 +1,184 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package workflows
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/jinzhu/inflection"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+
+	addonmgrv1alpha1 "github.com/keikoproj/addon-manager/api/v1alpha1"
+	"github.com/keikoproj/addon-manager/pkg/common"
+)
+
+// unprunedKinds are never pruned, deleting them deletes every object they contain
+var unprunedKinds = map[schema.GroupKind]bool{
+	{Kind: "Namespace"}: true,
+	{Group: "apiextensions.k8s.io", Kind: "CustomResourceDefinition"}: true,
+}
+
+// ResourceReferences returns the references to the objects the artifacts of the addon apply
+func ResourceReferences(addon *addonmgrv1alpha1.Addon) ([]addonmgrv1alpha1.ResourceReference, error) {
+	objects, err := Artifacts(addon)
+	if err != nil {
+		return nil, err
+	}
+
+	refs := make([]addonmgrv1alpha1.ResourceReference, 0, len(objects))
+	for _, obj := range objects {
+		digest, err := objectDigest(obj)
+		if err != nil {
+			return nil, err
+		}
+		gvk := obj.GroupVersionKind()
+		refs = append(refs, addonmgrv1alpha1.ResourceReference{
+			Group:     gvk.Group,
+			Version:   gvk.Version,
+			Kind:      gvk.Kind,
+			Namespace: obj.GetNamespace(),
+			Name:      obj.GetName(),
+			Digest:    digest,
+		})
+	}
+	return refs, nil
+}
+
+// objectDigest returns the digest of the object without the version label, every object of an addon has a new version
+// label on upgrades
+func objectDigest(obj *unstructured.Unstructured) (string, error) {
+	// Artifacts decoded from yaml can not be deep copied, the copy is decoded from json
+	data, err := json.Marshal(obj.Object)
+	if err != nil {
+		return "", fmt.Errorf("unable to marshall resource %s. %v", obj.GetName(), err)
+	}
+	var content map[string]interface{}
+	if err := json.Unmarshal(data, &content); err != nil {
+		return "", fmt.Errorf("unable to unmarshall resource %s. %v", obj.GetName(), err)
+	}
+	unstructured.RemoveNestedField(content, "metadata", "labels", "app.kubernetes.io/version")
+	if data, err = json.Marshal(content); err != nil {
+		return "", fmt.Errorf("unable to marshall resource %s. %v", obj.GetName(), err)
+	}
+	return addonmgrv1alpha1.SourceDigest(string(data)), nil
+}
+
+// resourceKey identifies the object of a reference across api versions
+func resourceKey(ref addonmgrv1alpha1.ResourceReference) string {
+	return strings.Join([]string{ref.Group, ref.Kind, ref.Namespace, ref.Name}, "/")
+}
+
+// resourceName returns the kind and the name of the object of a reference
+func resourceName(ref addonmgrv1alpha1.ResourceReference) string {
+	if ref.Namespace == "" {
+		return fmt.Sprintf("%s/%s", ref.Kind, ref.Name)
+	}
+	return fmt.Sprintf("%s/%s/%s", ref.Kind, ref.Namespace, ref.Name)
+}
+
+// DiffResources returns the objects added, removed and changed by upgrading from the previously installed resources to
+// the current ones. Objects recorded without a digest are not reported as changed.
+func DiffResources(previous, current *addonmgrv1alpha1.InstalledResources) *addonmgrv1alpha1.UpgradeDiff {
+	diff := &addonmgrv1alpha1.UpgradeDiff{FromChecksum: previous.Checksum, ToChecksum: current.Checksum}
+
+	before := make(map[string]addonmgrv1alpha1.ResourceReference, len(previous.Resources))
+	for _, ref := range previous.Resources {
+		before[resourceKey(ref)] = ref
+	}
+	after := make(map[string]bool, len(current.Resources))
+	for _, ref := range current.Resources {
+		after[resourceKey(ref)] = true
+		prev, found := before[resourceKey(ref)]
+		if !found {
+			diff.Added = append(diff.Added, resourceName(ref))
+		} else if prev.Digest != "" && ref.Digest != "" && (prev.Digest != ref.Digest || prev.Version != ref.Version) {
+			diff.Changed = append(diff.Changed, resourceName(ref))
+		}
+	}
+	for _, ref := range previous.Resources {
+		if !after[resourceKey(ref)] {
+			diff.Removed = append(diff.Removed, resourceName(ref))
+		}
+	}
+	return diff
+}
+
+// RemovedResources returns the previous references missing from current, objects changing api version are kept
+func RemovedResources(previous, current []addonmgrv1alpha1.ResourceReference) []addonmgrv1alpha1.ResourceReference {
+	kept := make(map[string]bool, len(current))
+	for _, ref := range current {
+		kept[resourceKey(ref)] = true
+	}
+
+	var removed []addonmgrv1alpha1.ResourceReference
+	for _, ref := range previous {
+		if !kept[resourceKey(ref)] && !unprunedKinds[schema.GroupKind{Group: ref.Group, Kind: ref.Kind}] {
+			removed = append(removed, ref)
+		}
+	}
+	return removed
+}
+
+// Prune deletes the referenced objects still managed by the addon, objects without a namespace are looked up as
+// cluster scoped objects and in namespace, the namespace the workflows of the addon apply them in
+func Prune(ctx context.Context, dynClient dynamic.Interface, addon *addonmgrv1alpha1.Addon, namespace string, refs []addonmgrv1alpha1.ResourceReference) ([]string, error) {
+	propagation := metav1.DeletePropagationBackground
+	var pruned []string
+	for _, ref := range refs {
+		gvr := schema.GroupVersionResource{
+			Group:    ref.Group,
+			Version:  ref.Version,
+			Resource: inflection.Plural(strings.ToLower(ref.Kind)),
+		}
+		namespaces := []string{ref.Namespace}
+		if ref.Namespace == "" {
+			namespaces = append(namespaces, namespace)
+		}
+
+		for _, ns := range namespaces {
+			obj, err := dynClient.Resource(gvr).Namespace(ns).Get(ctx, ref.Name, metav1.GetOptions{})
+			if apierrors.IsNotFound(err) {
+				continue
+			} else if err != nil {
+				return pruned, fmt.Errorf("failed to get %s %s. %v", ref.Kind, ref.Name, err)
+			}
+			// Objects adopted by other addons or edited to no longer be managed by the addon are left alone
+			if !managedBy(obj, addon) {
+				break
+			}
+
+			err = dynClient.Resource(gvr).Namespace(ns).Delete(ctx, ref.Name, metav1.DeleteOptions{PropagationPolicy: &propagation})
+			if err != nil && !apierrors.IsNotFound(err) {
+				return pruned, fmt.Errorf("failed to delete %s %s. %v", ref.Kind, ref.Name, err)
+			}
+			pruned = append(pruned, resourceName(ref))
+			break
+		}
+	}
+	return pruned, nil
+}
+
+// managedBy returns true when the object has the default labels of the addon resources
+func managedBy(obj *unstructured.Unstructured, addon *addonmgrv1alpha1.Addon) bool {
+	labels := obj.GetLabels()
+	return labels["app.kubernetes.io/managed-by"] == common.AddonGVR().Group && labels["app.kubernetes.io/name"] == addon.GetName()
+}
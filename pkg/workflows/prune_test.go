@@ -0,0 +1,138 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package workflows
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynfake "k8s.io/client-go/dynamic/fake"
+
+	"github.com/keikoproj/addon-manager/api/v1alpha1"
+	"github.com/keikoproj/addon-manager/pkg/common"
+)
+
+func TestRemovedResources(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	previous := []v1alpha1.ResourceReference{
+		{Kind: "Namespace", Version: "v1", Name: "event-router-ns"},
+		{Kind: "ConfigMap", Version: "v1", Namespace: "event-router-ns", Name: "old-config"},
+		{Group: "extensions", Kind: "Deployment", Version: "v1beta1", Namespace: "event-router-ns", Name: "event-router"},
+	}
+	current := []v1alpha1.ResourceReference{
+		{Group: "extensions", Kind: "Deployment", Version: "v1", Namespace: "event-router-ns", Name: "event-router"},
+	}
+
+	g.Expect(RemovedResources(previous, current)).To(Equal([]v1alpha1.ResourceReference{previous[1]}))
+	g.Expect(RemovedResources(current, current)).To(BeEmpty())
+}
+
+func TestDiffResources(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	previous := &v1alpha1.InstalledResources{Checksum: "old", Resources: []v1alpha1.ResourceReference{
+		{Kind: "ConfigMap", Version: "v1", Namespace: "event-router-ns", Name: "old-config", Digest: "a"},
+		{Kind: "ConfigMap", Version: "v1", Namespace: "event-router-ns", Name: "config", Digest: "b"},
+		{Kind: "ClusterRole", Group: "rbac.authorization.k8s.io", Version: "v1", Name: "event-router", Digest: "c"},
+		{Kind: "ServiceAccount", Version: "v1", Namespace: "event-router-ns", Name: "event-router"},
+	}}
+	current := &v1alpha1.InstalledResources{Checksum: "new", Resources: []v1alpha1.ResourceReference{
+		{Kind: "ConfigMap", Version: "v1", Namespace: "event-router-ns", Name: "config", Digest: "d"},
+		{Kind: "ClusterRole", Group: "rbac.authorization.k8s.io", Version: "v1", Name: "event-router", Digest: "c"},
+		{Kind: "ServiceAccount", Version: "v1", Namespace: "event-router-ns", Name: "event-router", Digest: "e"},
+		{Kind: "Service", Version: "v1", Namespace: "event-router-ns", Name: "event-router", Digest: "f"},
+	}}
+
+	diff := DiffResources(previous, current)
+	g.Expect(diff.FromChecksum).To(Equal("old"))
+	g.Expect(diff.ToChecksum).To(Equal("new"))
+	g.Expect(diff.Added).To(Equal([]string{"Service/event-router-ns/event-router"}))
+	g.Expect(diff.Removed).To(Equal([]string{"ConfigMap/event-router-ns/old-config"}))
+	g.Expect(diff.Changed).To(Equal([]string{"ConfigMap/event-router-ns/config"}))
+}
+
+func TestResourceReferences_Digest(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	addon := &v1alpha1.Addon{
+		ObjectMeta: metav1.ObjectMeta{Name: "event-router", Namespace: "default"},
+		Spec: v1alpha1.AddonSpec{
+			PackageSpec: v1alpha1.PackageSpec{PkgName: "event-router", PkgVersion: "0.2.0"},
+			Params:      v1alpha1.AddonParams{Namespace: "event-router-ns"},
+			Lifecycle: v1alpha1.LifecycleWorkflowSpec{
+				Install: v1alpha1.WorkflowType{Template: wfSpecTemplate},
+			},
+		},
+	}
+	before, err := ResourceReferences(addon)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(before).ToNot(BeEmpty())
+	g.Expect(before[0].Digest).ToNot(BeEmpty())
+
+	// Version bumps alone do not change the objects
+	addon.Spec.PkgVersion = "0.3.0"
+	after, err := ResourceReferences(addon)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(after).To(Equal(before))
+}
+
+func TestPrune(t *testing.T) {
+	g := NewGomegaWithT(t)
+	ctx := context.TODO()
+
+	a := &v1alpha1.Addon{ObjectMeta: metav1.ObjectMeta{Name: "event-router", Namespace: "default"}}
+	newConfigMap := func(namespace, name, addon string) *unstructured.Unstructured {
+		cm := &unstructured.Unstructured{Object: map[string]interface{}{}}
+		cm.SetAPIVersion("v1")
+		cm.SetKind("ConfigMap")
+		cm.SetNamespace(namespace)
+		cm.SetName(name)
+		cm.SetLabels(map[string]string{"app.kubernetes.io/managed-by": common.AddonGVR().Group, "app.kubernetes.io/name": addon})
+		return cm
+	}
+
+	s := runtime.NewScheme()
+	s.AddKnownTypeWithName(schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}, &unstructured.Unstructured{})
+	s.AddKnownTypeWithName(schema.GroupVersionKind{Version: "v1", Kind: "ConfigMapList"}, &unstructured.UnstructuredList{})
+	dyn := dynfake.NewSimpleDynamicClient(s,
+		newConfigMap("event-router-ns", "old-config", "event-router"),
+		newConfigMap("default", "defaulted-config", "event-router"),
+		newConfigMap("event-router-ns", "adopted-config", "other-addon"),
+	)
+
+	pruned, err := Prune(ctx, dyn, a, "default", []v1alpha1.ResourceReference{
+		{Kind: "ConfigMap", Version: "v1", Namespace: "event-router-ns", Name: "old-config"},
+		{Kind: "ConfigMap", Version: "v1", Name: "defaulted-config"},
+		{Kind: "ConfigMap", Version: "v1", Namespace: "event-router-ns", Name: "adopted-config"},
+		{Kind: "ConfigMap", Version: "v1", Namespace: "event-router-ns", Name: "deleted-config"},
+	})
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(pruned).To(Equal([]string{"ConfigMap/event-router-ns/old-config", "ConfigMap/defaulted-config"}))
+
+	gvr := schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}
+	_, err = dyn.Resource(gvr).Namespace("event-router-ns").Get(ctx, "old-config", metav1.GetOptions{})
+	g.Expect(apierrors.IsNotFound(err)).To(BeTrue())
+	_, err = dyn.Resource(gvr).Namespace("default").Get(ctx, "defaulted-config", metav1.GetOptions{})
+	g.Expect(apierrors.IsNotFound(err)).To(BeTrue())
+	_, err = dyn.Resource(gvr).Namespace("event-router-ns").Get(ctx, "adopted-config", metav1.GetOptions{})
+	g.Expect(err).ToNot(HaveOccurred())
+}
@@ -0,0 +1,57 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package workflows
+
+import (
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	addonmgrv1alpha1 "github.com/keikoproj/addon-manager/api/v1alpha1"
+)
+
+// RenderWorkflow returns the workflow the manager submits for the lifecycle step of the addon, nil when the step
+// has no workflow. Options apply as for NewWorkflowLifecycle. The owner reference, the provisioned service account
+// and the secret holding sensitive parameters are set at submission and are not rendered.
+func RenderWorkflow(addon *addonmgrv1alpha1.Addon, step addonmgrv1alpha1.LifecycleStep, opts ...Option) (*unstructured.Unstructured, error) {
+	wt, err := LifecycleWorkflow(addon, step)
+	if err != nil {
+		return nil, err
+	}
+	if wt.Template == "" {
+		return nil, nil
+	}
+
+	w := &workflowLifecycle{addon: addon}
+	for _, opt := range opts {
+		opt(w)
+	}
+
+	wf, err := w.prepare(wt, addon.WorkflowName(step, addon.GetChecksum()))
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s workflow. %v", step, err)
+	}
+	if err := w.inject(wf, wt); err != nil {
+		return nil, err
+	}
+	wf.SetGroupVersionKind(schema.GroupVersionKind{
+		Kind:    "Workflow",
+		Group:   "argoproj.io",
+		Version: "v1alpha1",
+	})
+	w.labelWorkflow(wf)
+	return wf, nil
+}
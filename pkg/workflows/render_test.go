@@ -0,0 +1,72 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package workflows
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/keikoproj/addon-manager/api/v1alpha1"
+)
+
+func TestRenderWorkflow(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	a := &v1alpha1.Addon{
+		ObjectMeta: metav1.ObjectMeta{Name: "event-router", Namespace: "default"},
+		Spec: v1alpha1.AddonSpec{
+			PackageSpec: v1alpha1.PackageSpec{PkgName: "event-router", PkgVersion: "0.2.0"},
+			Params: v1alpha1.AddonParams{
+				Namespace: "event-router-ns",
+				Data:      map[string]v1alpha1.FlexString{"b": "2", "a": "1", "c": "3"},
+			},
+			Lifecycle: v1alpha1.LifecycleWorkflowSpec{
+				Install: v1alpha1.WorkflowType{Template: wfSpecTemplate},
+			},
+		},
+	}
+
+	wf, err := RenderWorkflow(a, v1alpha1.Install)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(wf.GetName()).To(Equal(a.WorkflowName(v1alpha1.Install, a.CalculateChecksum())))
+	g.Expect(wf.GetKind()).To(Equal("Workflow"))
+	g.Expect(wf.GetLabels()).To(HaveKeyWithValue(AddonLabel, "event-router"))
+	g.Expect(wf.GetLabels()).To(HaveKeyWithValue(WfInstanceIdLabelKey, WfInstanceId))
+	ttl, _, _ := unstructured.NestedInt64(wf.Object, "spec", "ttlSecondsAfterFinished")
+	g.Expect(ttl).To(BeNumerically(">", 0))
+
+	// Data parameters are rendered in name order
+	params, _, _ := unstructured.NestedSlice(wf.Object, "spec", "arguments", "parameters")
+	var names []string
+	for _, p := range params[len(params)-3:] {
+		names = append(names, p.(map[string]interface{})["name"].(string))
+	}
+	g.Expect(names).To(Equal([]string{"a", "b", "c"}))
+
+	again, err := RenderWorkflow(a, v1alpha1.Install)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(again).To(Equal(wf))
+
+	wf, err = RenderWorkflow(a, v1alpha1.Delete)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(wf).To(BeNil())
+
+	a.Spec.Lifecycle.Install.Template = wfInvalidTemplate
+	_, err = RenderWorkflow(a, v1alpha1.Install)
+	g.Expect(err).To(HaveOccurred())
+}
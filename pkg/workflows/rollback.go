@@ -0,0 +1,37 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package workflows
+
+import (
+	"fmt"
+
+	addonmgrv1alpha1 "github.com/keikoproj/addon-manager/api/v1alpha1"
+)
+
+// RevisionAddon returns a copy of the addon with the spec of a previous revision, its workflows render as they did
+// for the revision with the metadata of the addon
+func RevisionAddon(addon *addonmgrv1alpha1.Addon, spec *addonmgrv1alpha1.AddonSpec) *addonmgrv1alpha1.Addon {
+	a := addon.DeepCopy()
+	spec.DeepCopyInto(&a.Spec)
+	a.Status.Checksum = a.CalculateStatusChecksum()
+	return a
+}
+
+// RollbackWorkflowName returns the name of the workflow installing a previous revision of the addon again after the
+// install of the spec with the failed checksum failed. It differs from the name of the install workflow of the
+// revision, which may still exist.
+func RollbackWorkflowName(addon *addonmgrv1alpha1.Addon, failedChecksum string) string {
+	return fmt.Sprintf("%s-rollback-%s-wf", addon.GetName(), failedChecksum)
+}
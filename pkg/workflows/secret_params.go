@@ -0,0 +1,142 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package workflows
+
+import (
+	"context"
+	"encoding/json"
+	"regexp"
+	"sort"
+
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	addonmgrv1alpha1 "github.com/keikoproj/addon-manager/api/v1alpha1"
+)
+
+var invalidEnvChars = regexp.MustCompile(`[^A-Za-z0-9_]`)
+
+// WithSecretParams passes sensitive parameters to workflow containers as environment variables read from a Secret,
+// so they are not stored in plain text in the Workflow global parameters. Each parameter is available as an
+// environment variable named after it with characters other than letters, digits and underscores replaced by underscores.
+func WithSecretParams(params map[string]string) Option {
+	return func(w *workflowLifecycle) {
+		w.secretParams = params
+	}
+}
+
+// SecretParamsName returns the name of the Secret holding the sensitive workflow parameters of the addon
+func SecretParamsName(addon *addonmgrv1alpha1.Addon) string {
+	return addon.Name + "-workflow-params"
+}
+
+// ParamEnvName returns the environment variable a sensitive parameter is exposed as
+func ParamEnvName(param string) string {
+	return invalidEnvChars.ReplaceAllString(param, "_")
+}
+
+// applySecretParams stores the sensitive parameters in the addon params Secret and references it from every container
+func (w *workflowLifecycle) applySecretParams(ctx context.Context, wf *unstructured.Unstructured) error {
+	if len(w.secretParams) == 0 {
+		return nil
+	}
+
+	secret := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      QualifyName(w.addon, w.namespace, SecretParamsName(w.addon)),
+			Namespace: wf.GetNamespace(),
+			Labels:    map[string]string{"app.kubernetes.io/managed-by": "addonmgr.keikoproj.io"},
+		},
+		Type: v1.SecretTypeOpaque,
+		Data: map[string][]byte{},
+	}
+	for name, value := range w.secretParams {
+		secret.Data[name] = []byte(value)
+	}
+	if err := w.setOwner(secret); err != nil {
+		return err
+	}
+	err := w.Create(ctx, secret)
+	if apierrors.IsAlreadyExists(err) {
+		// Replace the data without reading the secret, secrets are not cached by the manager
+		var patch []byte
+		patch, err = json.Marshal([]map[string]interface{}{{"op": "add", "path": "/data", "value": secret.Data}})
+		if err != nil {
+			return err
+		}
+		err = w.Patch(ctx, secret, client.RawPatch(types.JSONPatchType, patch))
+	}
+	if err != nil {
+		return err
+	}
+
+	env := make([]interface{}, 0, len(w.secretParams))
+	for _, name := range sortedKeys(w.secretParams) {
+		env = append(env, map[string]interface{}{
+			"name": ParamEnvName(name),
+			"valueFrom": map[string]interface{}{
+				"secretKeyRef": map[string]interface{}{"name": secret.Name, "key": name},
+			},
+		})
+	}
+
+	templates, _, err := unstructured.NestedFieldNoCopy(wf.Object, "spec", "templates")
+	if err != nil {
+		return err
+	}
+	list, _ := templates.([]interface{})
+	for _, t := range list {
+		template, ok := t.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		for _, key := range []string{"container", "script"} {
+			if container, ok := template[key].(map[string]interface{}); ok {
+				addEnv(container, env)
+			}
+		}
+	}
+	return nil
+}
+
+// addEnv appends env to the container, variables the container already defines are kept
+func addEnv(container map[string]interface{}, env []interface{}) {
+	existing, _ := container["env"].([]interface{})
+	defined := map[interface{}]bool{}
+	for _, e := range existing {
+		if m, ok := e.(map[string]interface{}); ok {
+			defined[m["name"]] = true
+		}
+	}
+	for _, e := range env {
+		if !defined[e.(map[string]interface{})["name"]] {
+			existing = append(existing, e)
+		}
+	}
+	container["env"] = existing
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
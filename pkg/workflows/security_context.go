@@ -0,0 +1,165 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package workflows
+
+import (
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/keikoproj/addon-manager/pkg/common"
+)
+
+// SecurityContextDefaults are injected into workflow pods and artifact pod templates that do not set them
+type SecurityContextDefaults struct {
+	// RunAsNonRoot requires pod containers to run as a non-root user
+	RunAsNonRoot bool
+	// SeccompProfile is the seccomp profile type of pods, e.g. RuntimeDefault
+	SeccompProfile string
+	// DropCapabilities are the Linux capabilities dropped from containers, e.g. ALL
+	DropCapabilities []string
+	// DisallowPrivilegeEscalation sets allowPrivilegeEscalation false on containers
+	DisallowPrivilegeEscalation bool
+}
+
+// RestrictedSecurityContext satisfies the restricted Pod Security Standard
+var RestrictedSecurityContext = SecurityContextDefaults{
+	RunAsNonRoot:                true,
+	SeccompProfile:              "RuntimeDefault",
+	DropCapabilities:            []string{"ALL"},
+	DisallowPrivilegeEscalation: true,
+}
+
+// securityContextKinds are the artifact kinds whose pod templates get security context defaults
+var securityContextKinds = []string{"Deployment", "DaemonSet"}
+
+// WithSecurityContextDefaults injects security context defaults into workflow and artifact pods
+func WithSecurityContextDefaults(d SecurityContextDefaults) Option {
+	return func(w *workflowLifecycle) {
+		w.securityContext = &d
+	}
+}
+
+// injectSecurityContext sets the defaults on the workflow pods and on the containers of every template
+func (w *workflowLifecycle) injectSecurityContext(wf *unstructured.Unstructured) error {
+	if w.securityContext == nil {
+		return nil
+	}
+
+	spec, ok := wf.Object["spec"].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	if err := w.securityContext.injectPod(spec); err != nil {
+		return err
+	}
+
+	templates, _ := spec["templates"].([]interface{})
+	for _, t := range templates {
+		template, ok := t.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		for _, key := range []string{"container", "script"} {
+			if container, ok := template[key].(map[string]interface{}); ok {
+				if err := w.securityContext.injectContainer(container); err != nil {
+					return err
+				}
+			}
+		}
+		for _, key := range []string{"initContainers", "sidecars"} {
+			if err := w.securityContext.injectContainers(template, key); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// injectArtifactSecurityContext sets the defaults on the pod template of workload artifacts
+func (w *workflowLifecycle) injectArtifactSecurityContext(resource *unstructured.Unstructured) error {
+	if w.securityContext == nil || !common.ContainsString(securityContextKinds, resource.GetKind()) {
+		return nil
+	}
+
+	podSpec, found, err := unstructured.NestedFieldNoCopy(resource.Object, "spec", "template", "spec")
+	if err != nil || !found {
+		return err
+	}
+	spec, ok := podSpec.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+
+	if err := w.securityContext.injectPod(spec); err != nil {
+		return err
+	}
+	for _, key := range []string{"containers", "initContainers"} {
+		if err := w.securityContext.injectContainers(spec, key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *SecurityContextDefaults) injectPod(spec map[string]interface{}) error {
+	if d.RunAsNonRoot {
+		if err := setDefault(spec, true, "securityContext", "runAsNonRoot"); err != nil {
+			return err
+		}
+	}
+	if d.SeccompProfile != "" {
+		if err := setDefault(spec, d.SeccompProfile, "securityContext", "seccompProfile", "type"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *SecurityContextDefaults) injectContainers(obj map[string]interface{}, key string) error {
+	containers, _ := obj[key].([]interface{})
+	for _, c := range containers {
+		if container, ok := c.(map[string]interface{}); ok {
+			if err := d.injectContainer(container); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (d *SecurityContextDefaults) injectContainer(container map[string]interface{}) error {
+	if d.DisallowPrivilegeEscalation {
+		if err := setDefault(container, false, "securityContext", "allowPrivilegeEscalation"); err != nil {
+			return err
+		}
+	}
+	if len(d.DropCapabilities) > 0 {
+		var drop []interface{}
+		for _, c := range d.DropCapabilities {
+			drop = append(drop, c)
+		}
+		if err := setDefault(container, drop, "securityContext", "capabilities", "drop"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setDefault sets the nested field to value unless it is already set
+func setDefault(obj map[string]interface{}, value interface{}, fields ...string) error {
+	if _, found, err := unstructured.NestedFieldNoCopy(obj, fields...); err != nil || found {
+		return err
+	}
+	return unstructured.SetNestedField(obj, value, fields...)
+}
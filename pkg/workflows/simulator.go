@@ -0,0 +1,175 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package workflows
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/clock"
+
+	addonmgrv1alpha1 "github.com/keikoproj/addon-manager/api/v1alpha1"
+)
+
+// Simulator runs workflows without Argo, the objects a workflow would apply are applied directly when it is
+// submitted and it succeeds once delay has passed. The objects of the prereqs and install workflows are deleted by
+// the delete workflow. It is meant for iterating on addon specs in development clusters.
+type Simulator struct {
+	delay time.Duration
+	clock clock.PassiveClock
+
+	mu      sync.Mutex
+	started map[string]time.Time
+}
+
+// NewSimulator returns a simulator completing workflows after delay
+func NewSimulator(delay time.Duration) *Simulator {
+	return &Simulator{
+		delay:   delay,
+		clock:   clock.RealClock{},
+		started: make(map[string]time.Time),
+	}
+}
+
+// SetClock sets the clock the workflow delay is measured with
+func (s *Simulator) SetClock(c clock.PassiveClock) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.clock = c
+}
+
+func (s *Simulator) now() time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.clock.Now()
+}
+
+// Delay returns how long simulated workflows run
+func (s *Simulator) Delay() time.Duration {
+	return s.delay
+}
+
+// start returns when the workflow was submitted and whether it is submitted now
+func (s *Simulator) start(key string) (time.Time, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if t, ok := s.started[key]; ok {
+		return t, false
+	}
+	t := s.clock.Now()
+	s.started[key] = t
+	return t, true
+}
+
+func (s *Simulator) forget(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.started, key)
+}
+
+// WithSimulator runs workflows with the simulator instead of submitting them to Argo
+func WithSimulator(s *Simulator) Option {
+	return func(w *workflowLifecycle) {
+		w.simulator = s
+	}
+}
+
+// simulate applies the objects of the parsed workflow the first time it is seen and reports it running until the
+// simulator delay has passed
+func (w *workflowLifecycle) simulate(ctx context.Context, wp *unstructured.Unstructured) (addonmgrv1alpha1.ApplicationAssemblyPhase, error) {
+	key := types.NamespacedName{Namespace: wp.GetNamespace(), Name: wp.GetName()}.String()
+	started, submitted := w.simulator.start(key)
+	if submitted {
+		if err := w.simulateObjects(ctx, wp); err != nil {
+			// Run the workflow again on the next reconcile
+			w.simulator.forget(key)
+			return addonmgrv1alpha1.Failed, err
+		}
+		w.recorder.Event(w.addon, "Normal", "Created", fmt.Sprintf("Simulated Workflow %s/%s", wp.GetName(), wp.GetNamespace()))
+	}
+
+	if w.simulator.now().Sub(started) < w.simulator.delay {
+		w.addon.Status.Progress = &addonmgrv1alpha1.WorkflowProgress{Workflow: wp.GetName()}
+		return addonmgrv1alpha1.Pending, nil
+	}
+	w.addon.Status.Progress = nil
+	return addonmgrv1alpha1.Succeeded, nil
+}
+
+// simulateObjects deletes the addon artifacts for the delete workflow and applies the workflow objects otherwise
+func (w *workflowLifecycle) simulateObjects(ctx context.Context, wp *unstructured.Unstructured) error {
+	if wp.GetName() == w.addon.WorkflowName(addonmgrv1alpha1.Delete, w.addon.GetChecksum()) {
+		objects, err := Artifacts(w.addon)
+		if err != nil {
+			return err
+		}
+		for _, obj := range objects {
+			w.defaultNamespace(obj, wp)
+			if err := w.Client.Delete(ctx, obj); err != nil && !apierrors.IsNotFound(err) {
+				return fmt.Errorf("failed to delete %s %s. %v", obj.GetKind(), obj.GetName(), err)
+			}
+		}
+		return nil
+	}
+
+	replacer := paramReplacer(wp)
+	for _, obj := range w.objects {
+		obj.Object = substitute(obj.Object, replacer).(map[string]interface{})
+		w.defaultNamespace(obj, wp)
+		if err := w.apply(ctx, obj); err != nil {
+			return fmt.Errorf("failed to apply %s %s. %v", obj.GetKind(), obj.GetName(), err)
+		}
+	}
+	return nil
+}
+
+// defaultNamespace places objects without a namespace in the workflow namespace like Argo resource templates,
+// the API server ignores it for cluster scoped objects
+func (w *workflowLifecycle) defaultNamespace(obj, wp *unstructured.Unstructured) {
+	if obj.GetNamespace() == "" {
+		obj.SetNamespace(wp.GetNamespace())
+	}
+}
+
+// apply creates the object or replaces the existing one
+func (w *workflowLifecycle) apply(ctx context.Context, obj *unstructured.Unstructured) error {
+	// Artifacts are parsed from YAML, their integer values are not valid JSON values until converted
+	raw, err := json.Marshal(obj.Object)
+	if err != nil {
+		return err
+	}
+	obj = &unstructured.Unstructured{}
+	if err := obj.UnmarshalJSON(raw); err != nil {
+		return err
+	}
+
+	err = w.Client.Create(ctx, obj)
+	if !apierrors.IsAlreadyExists(err) {
+		return err
+	}
+	existing := &unstructured.Unstructured{}
+	existing.SetGroupVersionKind(obj.GroupVersionKind())
+	if err := w.Client.Get(ctx, types.NamespacedName{Namespace: obj.GetNamespace(), Name: obj.GetName()}, existing); err != nil {
+		return err
+	}
+	obj.SetResourceVersion(existing.GetResourceVersion())
+	return w.Client.Update(ctx, obj)
+}
@@ -0,0 +1,89 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package workflows
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/clock"
+	runtimefake "sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/keikoproj/addon-manager/api/v1alpha1"
+)
+
+func TestWorkflowLifecycle_Simulate(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	a := &v1alpha1.Addon{
+		ObjectMeta: metav1.ObjectMeta{Name: "event-router", Namespace: "default"},
+		Spec: v1alpha1.AddonSpec{
+			PackageSpec: v1alpha1.PackageSpec{PkgName: "event-router", PkgVersion: "0.2.0"},
+			Params:      v1alpha1.AddonParams{Namespace: "event-router-ns"},
+			Lifecycle: v1alpha1.LifecycleWorkflowSpec{
+				Prereqs: v1alpha1.WorkflowType{Template: wfPrereqsTemplate},
+				Install: v1alpha1.WorkflowType{Template: wfSpecTemplate},
+				Delete:  v1alpha1.WorkflowType{Template: wfSpecTemplate},
+			},
+		},
+	}
+	a.Status.Checksum = a.CalculateChecksum()
+
+	fakeClock := clock.NewFakeClock(time.Now())
+	s := NewSimulator(time.Minute)
+	s.SetClock(fakeClock)
+	c := runtimefake.NewFakeClientWithScheme(runtime.NewScheme())
+	wfl := NewWorkflowLifecycle(c, nil, a, rcdr, sch, WithSimulator(s))
+
+	deployment := func() error {
+		obj := &unstructured.Unstructured{}
+		obj.SetAPIVersion("apps/v1")
+		obj.SetKind("Deployment")
+		return c.Get(ctx, types.NamespacedName{Namespace: "event-router-ns", Name: "event-router"}, obj)
+	}
+
+	// The objects are applied when the workflow is submitted, it runs until the delay has passed
+	install := a.GetFormattedWorkflowName(v1alpha1.Install)
+	phase, err := wfl.Install(ctx, &a.Spec.Lifecycle.Install, install)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(phase).To(Equal(v1alpha1.Pending))
+	g.Expect(a.Status.Progress.Workflow).To(Equal(install))
+	g.Expect(deployment()).To(Succeed())
+
+	fakeClock.Step(time.Minute)
+	phase, err = wfl.Install(ctx, &a.Spec.Lifecycle.Install, install)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(phase).To(Equal(v1alpha1.Succeeded))
+	g.Expect(a.Status.Progress).To(BeNil())
+
+	// Existing objects are replaced
+	g.Expect(wfl.Delete(ctx, install)).To(Succeed())
+	phase, err = wfl.Install(ctx, &a.Spec.Lifecycle.Install, install)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(phase).To(Equal(v1alpha1.Pending))
+
+	// The delete workflow removes the prereqs and install objects
+	fakeClock.Step(time.Minute)
+	phase, err = wfl.Install(ctx, &a.Spec.Lifecycle.Delete, a.GetFormattedWorkflowName(v1alpha1.Delete))
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(phase).To(Equal(v1alpha1.Pending))
+	g.Expect(apierrors.IsNotFound(deployment())).To(BeTrue())
+}
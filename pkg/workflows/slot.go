@@ -0,0 +1,71 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package workflows
+
+import (
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// SlotLabel is the blue-green slot of the workloads of an addon
+const SlotLabel = "addonmgr.keikoproj.io/slot"
+
+// slotKinds are the workload kinds installed once per slot, other artifacts are shared by both slots
+var slotKinds = map[string][]string{
+	"Deployment":  {"spec", "template"},
+	"DaemonSet":   {"spec", "template"},
+	"StatefulSet": {"spec", "template"},
+	"ReplicaSet":  {"spec", "template"},
+	"Job":         {"spec", "template"},
+	"CronJob":     {"spec", "jobTemplate", "spec", "template"},
+}
+
+// WithSlot installs the workloads of the addon artifacts in a blue-green slot, their names are suffixed with the slot
+// so both versions run side by side. The slot is passed to workflows as the slot global parameter.
+func WithSlot(slot string) Option {
+	return func(w *workflowLifecycle) {
+		w.slot = slot
+	}
+}
+
+// SlotName returns the name of a workload in the slot
+func SlotName(name, slot string) string {
+	return fmt.Sprintf("%s-%s", name, slot)
+}
+
+// addSlotToResource renames the workload and adds the slot label to it, its selector and its pod template
+func (w *workflowLifecycle) addSlotToResource(resource *unstructured.Unstructured) error {
+	template, ok := slotKinds[resource.GetKind()]
+	if w.slot == "" || !ok {
+		return nil
+	}
+
+	resource.SetName(SlotName(resource.GetName(), w.slot))
+	labels := resource.GetLabels()
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	labels[SlotLabel] = w.slot
+	resource.SetLabels(labels)
+
+	// Job selectors are generated from the pod template
+	if _, found, _ := unstructured.NestedMap(resource.Object, "spec", "selector", "matchLabels"); found {
+		if err := unstructured.SetNestedField(resource.Object, w.slot, "spec", "selector", "matchLabels", SlotLabel); err != nil {
+			return err
+		}
+	}
+	return unstructured.SetNestedField(resource.Object, w.slot, append(template, "metadata", "labels", SlotLabel)...)
+}
@@ -19,7 +19,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"reflect"
+	"sort"
 	"strings"
 	"time"
 
@@ -31,6 +31,7 @@ import (
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/tools/cache"
 	"k8s.io/client-go/tools/record"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
@@ -41,62 +42,209 @@ import (
 
 const (
 	WfInstanceIdLabelKey           = "workflows.argoproj.io/controller-instanceid"
+	WfPhaseLabelKey                = "workflows.argoproj.io/phase"
 	WfInstanceId                   = "addon-manager-workflow-controller"
 	WfDefaultActiveDeadlineSeconds = 300
+	AddonLabel                     = "addonmgr.keikoproj.io/addon"
 )
 
 // AddonLifecycle represents the following workflows
 type AddonLifecycle interface {
 	Install(context.Context, *addonmgrv1alpha1.WorkflowType, string) (addonmgrv1alpha1.ApplicationAssemblyPhase, error)
 	Delete(context.Context, string) error
+	Collect(context.Context, string) (bool, error)
 }
 
 type workflowLifecycle struct {
 	client.Client
-	dynClient dynamic.Interface
-	addon     *addonmgrv1alpha1.Addon
-	recorder  record.EventRecorder
-	scheme    *runtime.Scheme
+	dynClient        dynamic.Interface
+	addon            *addonmgrv1alpha1.Addon
+	recorder         record.EventRecorder
+	scheme           *runtime.Scheme
+	params           map[string]string
+	commonParams     map[string]string
+	verifier         ImageVerifier
+	provisioner      ServiceAccountProvisioner
+	securityContext  *SecurityContextDefaults
+	cloudProvider    common.CloudProvider
+	saChecker        ServiceAccountChecker
+	secretParams     map[string]string
+	remote           bool
+	slot             string
+	objects          []*unstructured.Unstructured
+	indexer          cache.Indexer
+	indexerNamespace string
+	namespace        string
+	propagation      PropagationPolicy
+	logArchive       LogArchive
+	ownerMode        OwnerMode
+	simulator        *Simulator
+	faults           FaultInjector
+}
+
+// ImageVerifier verifies the container images of a workflow before it is submitted, it returns the verified image
+// pinned to its digest
+type ImageVerifier interface {
+	Verify(ctx context.Context, image string) (string, error)
+}
+
+// ServiceAccountProvisioner provisions the service account workflows run as from the objects the addon applies,
+// role is the IAM role of the workflow step the service account is annotated with
+type ServiceAccountProvisioner interface {
+	Provision(ctx context.Context, addon *addonmgrv1alpha1.Addon, role string, objects []*unstructured.Unstructured) (string, error)
+}
+
+// ServiceAccountChecker rejects service accounts workflows are not allowed to run as
+type ServiceAccountChecker interface {
+	Check(ctx context.Context, namespace, name string) error
+}
+
+// FaultInjector injects workflow submit errors and status flapping for chaos testing
+type FaultInjector interface {
+	SubmitError(name string) error
+	FlapStatus(name string) bool
+}
+
+// Option configures optional workflow lifecycle settings
+type Option func(*workflowLifecycle)
+
+// WithParams adds global workflow parameters that are not stored in the addon spec, e.g. decrypted secrets.
+// They take precedence over addon data parameters with the same name.
+func WithParams(params map[string]string) Option {
+	return func(w *workflowLifecycle) {
+		w.params = params
+	}
+}
+
+// WithImageVerifier blocks workflows with container images that fail verification
+func WithImageVerifier(v ImageVerifier) Option {
+	return func(w *workflowLifecycle) {
+		w.verifier = v
+	}
+}
+
+// WithServiceAccountProvisioner runs workflows as a generated service account scoped to the addon artifacts
+func WithServiceAccountProvisioner(p ServiceAccountProvisioner) Option {
+	return func(w *workflowLifecycle) {
+		w.provisioner = p
+	}
+}
+
+// WithServiceAccountChecker blocks workflows of addons that are not cluster scoped when their service account fails the check
+func WithServiceAccountChecker(c ServiceAccountChecker) Option {
+	return func(w *workflowLifecycle) {
+		w.saChecker = c
+	}
+}
+
+// WithCloudProvider selects how workflow roles are applied to workflows and artifacts, kube2iam annotations are used for AWS
+func WithCloudProvider(p common.CloudProvider) Option {
+	return func(w *workflowLifecycle) {
+		w.cloudProvider = p
+	}
+}
+
+// WithRemoteCluster submits workflows to a remote cluster, they are labelled with the addon instead of being owned by it
+func WithRemoteCluster(c client.Client, dynClient dynamic.Interface) Option {
+	return func(w *workflowLifecycle) {
+		w.Client = c
+		w.dynClient = dynClient
+		w.remote = true
+	}
+}
+
+// WithFaultInjector fails workflow submissions and reports completed workflows as running when the injector says so
+func WithFaultInjector(f FaultInjector) Option {
+	return func(w *workflowLifecycle) {
+		w.faults = f
+	}
 }
 
 // NewWorkflowLifecycle returns a AddonLifecycle object
-func NewWorkflowLifecycle(client client.Client, dynClient dynamic.Interface, addon *addonmgrv1alpha1.Addon, recorder record.EventRecorder, scheme *runtime.Scheme) AddonLifecycle {
-	return &workflowLifecycle{
+func NewWorkflowLifecycle(client client.Client, dynClient dynamic.Interface, addon *addonmgrv1alpha1.Addon, recorder record.EventRecorder, scheme *runtime.Scheme, opts ...Option) AddonLifecycle {
+	w := &workflowLifecycle{
 		Client:    client,
 		dynClient: dynClient,
 		addon:     addon,
 		recorder:  recorder,
 		scheme:    scheme,
 	}
+	for _, opt := range opts {
+		opt(w)
+	}
+	return w
 }
 
 func (w *workflowLifecycle) Install(ctx context.Context, wt *addonmgrv1alpha1.WorkflowType, name string) (addonmgrv1alpha1.ApplicationAssemblyPhase, error) {
+	wp, err := w.prepare(wt, name)
+	if err != nil {
+		return addonmgrv1alpha1.Failed, err
+	}
+
+	if w.simulator != nil {
+		return w.simulate(ctx, wp)
+	}
+
+	if err := w.inject(wp, wt); err != nil {
+		return addonmgrv1alpha1.Failed, err
+	}
+
+	return w.submit(ctx, wp, wt)
+}
+
+// prepare parses the workflow template and adds the global parameters and the labels of the artifacts
+func (w *workflowLifecycle) prepare(wt *addonmgrv1alpha1.WorkflowType, name string) (*unstructured.Unstructured, error) {
 	wp := &unstructured.Unstructured{}
 	err := w.parse(wt, wp, name)
 	if err != nil {
-		return addonmgrv1alpha1.Failed, fmt.Errorf("invalid workflow. %v", err)
+		return nil, fmt.Errorf("invalid workflow. %v", err)
 	}
 
 	if !w.configureGlobalWFParameters(w.addon, wp) {
-		return addonmgrv1alpha1.Failed, errors.New("invalid workflow parameter")
+		return nil, errors.New("invalid workflow parameter")
 	}
 
+	// Only the objects of this workflow are collected
+	w.objects = nil
 	err = w.configureWorkflowArtifacts(wp, wt)
 	if err != nil {
-		return addonmgrv1alpha1.Failed, err
+		return nil, err
 	}
+	return wp, nil
+}
 
+// inject adds the manager defaults to the prepared workflow
+func (w *workflowLifecycle) inject(wp *unstructured.Unstructured, wt *addonmgrv1alpha1.WorkflowType) error {
 	if err := w.injectTTLs(wp); err != nil {
-		return addonmgrv1alpha1.Failed, err
+		return err
 	}
 
 	if err := w.injectActiveDeadlineSeconds(wp); err != nil {
-		return addonmgrv1alpha1.Failed, err
+		return err
 	}
 
-	w.injectInstanceId(wp)
+	if err := w.injectSecurityContext(wp); err != nil {
+		return err
+	}
 
-	return w.submit(ctx, wp)
+	if err := w.injectAzureIdentityLabel(wp, wt); err != nil {
+		return err
+	}
+
+	if err := w.injectPropagatedMetadata(wp); err != nil {
+		return err
+	}
+
+	if err := w.injectContextEnv(wp); err != nil {
+		return err
+	}
+
+	if err := w.injectArchiveLogs(wp); err != nil {
+		return err
+	}
+
+	w.injectInstanceId(wp)
+	return nil
 }
 
 // Appends addon.spec.params to workflow.spec.arguments.parameters
@@ -112,68 +260,55 @@ func (w *workflowLifecycle) configureGlobalWFParameters(addon *addonmgrv1alpha1.
 		arguments["parameters"] = make([]interface{}, 0)
 	}
 
-	wfParams := arguments["parameters"].([]interface{})
-	if wfParams == nil {
-		arguments["parameters"] = make([]interface{}, 0)
-	}
+	existing, _ := arguments["parameters"].([]interface{})
 
-	// get addon params
-	namespaceParam := addon.Spec.Params.Namespace
 	contextParams := addon.Spec.Params.Context
 	dataParams := addon.Spec.Params.Data
-	pkgParams := addon.Spec.PackageSpec
+	fields := specParams(addon)
 
-	namespaceMap := make(map[string]interface{})
-	namespaceMap["name"] = "namespace"
-	namespaceMap["value"] = namespaceParam
+	wfParams := make([]interface{}, 0, len(existing)+len(fields)+1+len(contextParams.AdditionalConfigs)+len(dataParams)+len(w.params))
+	wfParams = append(wfParams, existing...)
+	wfParams = append(wfParams, fields[0])
+	if w.slot != "" {
+		wfParams = append(wfParams, param("slot", w.slot))
+	}
+	wfParams = append(wfParams, fields[1:]...)
 
-	wfParams = append(wfParams, namespaceMap)
+	// Parameters from maps are added in name order so rendered workflows are stable
+	var names []string
 
-	// Copy pkgParams into global workflow variables
-	refPkg := reflect.ValueOf(pkgParams)
-	for i := 0; i < refPkg.Type().NumField(); i++ {
-		pkgParamMap := make(map[string]interface{})
-		kind := refPkg.Field(i).Kind()
-		if kind == reflect.String {
-			tag := refPkg.Type().Field(i).Tag
-			jsonTag := strings.Split(tag.Get("json"), ",")[0]
-			value := refPkg.Field(i).String()
-			pkgParamMap["name"] = jsonTag
-			pkgParamMap["value"] = value
-			wfParams = append(wfParams, pkgParamMap)
-		}
+	// Copy AdditionalConfigs from Context to global workflow variables
+	for name := range contextParams.AdditionalConfigs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		wfParams = append(wfParams, param(name, string(contextParams.AdditionalConfigs[name])))
 	}
 
-	// Copy general Context string params to global workflow variables (clusterName and clusterRegion currently)
-	cp := reflect.ValueOf(contextParams)
-	for i := 0; i < cp.Type().NumField(); i++ {
-		contextMap := make(map[string]interface{})
-		kind := cp.Field(i).Kind()
-		if kind == reflect.String {
-			fieldName := cp.Type().Field(i).Name
-			tag := cp.Type().Field(i).Tag
-			jsonTag := strings.Split(tag.Get("json"), ",")[0]
-			contextMap["name"] = jsonTag
-			contextMap["value"] = cp.FieldByName(fieldName).String()
-			wfParams = append(wfParams, contextMap)
+	// Copy stringParams to global workflow variables
+	names = names[:0]
+	for name := range dataParams {
+		if _, ok := w.params[name]; ok {
+			continue
 		}
+		names = append(names, name)
 	}
-
-	// Copy AdditionalConfigs from Context to global workflow variables
-	for name, value := range contextParams.AdditionalConfigs {
-		addParam := make(map[string]interface{})
-		addParam["name"] = name
-		addParam["value"] = string(value)
-		wfParams = append(wfParams, addParam)
+	sort.Strings(names)
+	for _, name := range names {
+		wfParams = append(wfParams, param(name, string(dataParams[name])))
 	}
 
-	// Copy stringParams to global workflow variables
-	for name, value := range dataParams {
-		addParam := make(map[string]interface{})
-		addParam["name"] = name
-		addParam["value"] = string(value)
-		wfParams = append(wfParams, addParam)
+	// Copy additional params, these override data params
+	names = names[:0]
+	for name := range w.params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		wfParams = append(wfParams, param(name, w.params[name]))
 	}
+	wfParams = w.appendCommonParams(wfParams)
 
 	err := unstructured.SetNestedSlice(wf.UnstructuredContent(), wfParams, "spec", "arguments", "parameters")
 	if err != nil {
@@ -183,37 +318,45 @@ func (w *workflowLifecycle) configureGlobalWFParameters(addon *addonmgrv1alpha1.
 	return true
 }
 
+// specParams returns the addon spec fields every workflow gets as global parameters, the namespace first followed by
+// the package fields and the cluster context. Parameters are named after the json field names.
+func specParams(addon *addonmgrv1alpha1.Addon) []interface{} {
+	pkg := addon.Spec.PackageSpec
+	return []interface{}{
+		param("namespace", addon.Spec.Params.Namespace),
+		param("pkgChannel", pkg.PkgChannel),
+		param("pkgName", pkg.PkgName),
+		param("pkgVersion", pkg.PkgVersion),
+		param("pkgType", string(pkg.PkgType)),
+		param("pkgDescription", pkg.PkgDescription),
+		param("clusterName", addon.Spec.GetClusterName()),
+		param("clusterRegion", addon.Spec.GetRegion()),
+	}
+}
+
+func param(name, value string) map[string]interface{} {
+	return map[string]interface{}{"name": name, "value": value}
+}
+
 func (w *workflowLifecycle) Delete(ctx context.Context, name string) error {
-	err := w.dynClient.Resource(common.WorkflowGVR()).Namespace(w.addon.Namespace).Delete(ctx, name, metav1.DeleteOptions{})
+	name = QualifyName(w.addon, w.namespace, name)
+	if w.simulator != nil {
+		w.simulator.forget(types.NamespacedName{Namespace: w.workflowNamespace(), Name: name}.String())
+		return nil
+	}
+	err := w.dynClient.Resource(common.WorkflowGVR()).Namespace(w.workflowNamespace()).Delete(ctx, name, metav1.DeleteOptions{})
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
-func (w *workflowLifecycle) findWorkflowByName(ctx context.Context, name types.NamespacedName) (*unstructured.Unstructured, error) {
-	found := &unstructured.Unstructured{}
-	found.SetGroupVersionKind(schema.GroupVersionKind{
-		Kind:    "Workflow",
-		Group:   "argoproj.io",
-		Version: "v1alpha1",
-	})
-	err := w.Get(ctx, name, found)
-	if err != nil && apierrors.IsNotFound(err) {
-		return nil, nil
-	} else if err != nil {
-		return nil, err
-	}
-
-	return found, nil
-}
-
-func (w *workflowLifecycle) submit(ctx context.Context, wp *unstructured.Unstructured) (addonmgrv1alpha1.ApplicationAssemblyPhase, error) {
+func (w *workflowLifecycle) submit(ctx context.Context, wp *unstructured.Unstructured, wt *addonmgrv1alpha1.WorkflowType) (addonmgrv1alpha1.ApplicationAssemblyPhase, error) {
 	var wfv1 *unstructured.Unstructured
 	var err error
 
 	// Check if the Workflow already exists
-	wfv1, err = w.findWorkflowByName(ctx, types.NamespacedName{Name: wp.GetName(), Namespace: wp.GetNamespace()})
+	wfv1, err = w.getWorkflow(ctx, types.NamespacedName{Name: wp.GetName(), Namespace: wp.GetNamespace()})
 	if err != nil {
 		return addonmgrv1alpha1.Failed, err
 	}
@@ -230,6 +373,22 @@ func (w *workflowLifecycle) submit(ctx context.Context, wp *unstructured.Unstruc
 	}
 
 	if wfv1 == nil {
+		if err := w.verifyImages(ctx, wp); err != nil {
+			return addonmgrv1alpha1.Failed, err
+		}
+
+		if err := w.provisionServiceAccount(ctx, wp, wt.Role); err != nil {
+			return addonmgrv1alpha1.Failed, fmt.Errorf("failed to provision workflow service account. %v", err)
+		}
+
+		if err := w.checkServiceAccount(ctx, wp); err != nil {
+			return addonmgrv1alpha1.Failed, err
+		}
+
+		if err := w.applySecretParams(ctx, wp); err != nil {
+			return addonmgrv1alpha1.Failed, fmt.Errorf("failed to store sensitive workflow parameters. %v", err)
+		}
+
 		// Create the Workflow
 		wfv1 = &unstructured.Unstructured{}
 
@@ -245,38 +404,211 @@ func (w *workflowLifecycle) submit(ctx context.Context, wp *unstructured.Unstruc
 		})
 		wfv1.SetNamespace(wp.GetNamespace())
 		wfv1.SetName(wp.GetName())
+		w.propagate(wfv1)
 		// Set the owner references for workflow
-		if err := controllerutil.SetControllerReference(w.addon, wfv1, w.scheme); err != nil {
+		if err := w.setOwner(wfv1); err != nil {
 			return addonmgrv1alpha1.Failed, err
 		}
+		w.labelWorkflow(wfv1)
+
+		if w.faults != nil {
+			if err := w.faults.SubmitError(wfv1.GetName()); err != nil {
+				return addonmgrv1alpha1.Failed, err
+			}
+		}
 
 		err = w.Create(ctx, wfv1)
-		if err != nil {
+		if apierrors.IsAlreadyExists(err) {
+			// The workflow was created by a previous reconcile and is not in the cache yet
+			return addonmgrv1alpha1.Pending, nil
+		} else if err != nil {
 			return addonmgrv1alpha1.Failed, err
 		}
 		// Record an event for created workflow
 		w.recorder.Event(w.addon, "Normal", "Created", fmt.Sprintf("Created Workflow %s/%s", wp.GetName(), wp.GetNamespace()))
+		w.addon.Status.Progress = &addonmgrv1alpha1.WorkflowProgress{Workflow: wp.GetName()}
 
 		return addonmgrv1alpha1.Pending, nil
 	}
 
-	workflow, err := w.dynClient.Resource(common.WorkflowGVR()).Namespace(wfv1.GetNamespace()).Get(ctx, wfv1.GetName(), metav1.GetOptions{})
-	if err != nil {
-		return addonmgrv1alpha1.Failed, fmt.Errorf("could not find workflow %s/%s. %v", wfv1.GetNamespace(), wfv1.GetName(), err)
-	}
-
 	// validate workflow status
 	var phase = addonmgrv1alpha1.Pending
-	status, ok := workflow.UnstructuredContent()["status"].(map[string]interface{})
+	status, ok := wfv1.UnstructuredContent()["status"].(map[string]interface{})
 	if ok && status["phase"] == "Succeeded" {
 		phase = addonmgrv1alpha1.Succeeded
 	} else if ok && status["phase"] == "Failed" {
 		phase = addonmgrv1alpha1.Failed
 	}
+	if phase != addonmgrv1alpha1.Pending && w.faults != nil && w.faults.FlapStatus(wfv1.GetName()) {
+		phase = addonmgrv1alpha1.Pending
+	}
+
+	// Report progress while the workflow is running
+	if phase == addonmgrv1alpha1.Pending {
+		w.addon.Status.Progress = GetWorkflowProgress(wfv1)
+	} else {
+		w.addon.Status.Progress = nil
+	}
 
 	return phase, nil
 }
 
+// setOwner references the addon from obj as set by the owner mode, owner references can not point across clusters or namespaces
+// so objects in a remote cluster or outside of the addon namespace are labelled with the addon instead
+func (w *workflowLifecycle) setOwner(obj metav1.Object) error {
+	if !w.remote && !w.crossNamespace() {
+		if w.ownerMode == LooseOwner {
+			return controllerutil.SetOwnerReference(w.addon, obj, w.scheme)
+		}
+		return controllerutil.SetControllerReference(w.addon, obj, w.scheme)
+	}
+	labels := obj.GetLabels()
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	for k, v := range w.addonSelector() {
+		labels[k] = v
+	}
+	obj.SetLabels(labels)
+	return nil
+}
+
+// verifyImages verifies every container image referenced by the workflow templates and pins the images to the
+// verified digests so that moving a tag after the verification does not change what runs
+func (w *workflowLifecycle) verifyImages(ctx context.Context, wf *unstructured.Unstructured) error {
+	if w.verifier == nil {
+		return nil
+	}
+
+	templates, _, _ := unstructured.NestedSlice(wf.UnstructuredContent(), "spec", "templates")
+	pinned := make(map[string]string)
+	for _, image := range workflowImages(templates) {
+		err := fmt.Errorf("image %s is templated and can not be verified", image)
+		if !strings.Contains(image, "{{") {
+			pinned[image], err = w.verifier.Verify(ctx, image)
+		}
+		if err != nil {
+			w.recorder.Event(w.addon, "Warning", "Failed", fmt.Sprintf("Workflow %s/%s blocked. %v", wf.GetNamespace(), wf.GetName(), err))
+			return err
+		}
+	}
+	replaceImages(templates, pinned)
+	return unstructured.SetNestedSlice(wf.Object, templates, "spec", "templates")
+}
+
+// provisionServiceAccount sets the workflow service account to one allowed to apply the objects of every lifecycle step,
+// steps are combined so e.g. a delete workflow can remove what the install workflow applied
+func (w *workflowLifecycle) provisionServiceAccount(ctx context.Context, wf *unstructured.Unstructured, role string) error {
+	if w.provisioner == nil {
+		return nil
+	}
+
+	objects, err := w.lifecycleObjects()
+	if err != nil {
+		return err
+	}
+
+	sa, err := w.provisioner.Provision(ctx, w.addon, role, objects)
+	if err != nil {
+		return err
+	}
+	return unstructured.SetNestedField(wf.Object, sa, "spec", "serviceAccountName")
+}
+
+// checkServiceAccount verifies the workflow service account unless the addon is cluster scoped
+func (w *workflowLifecycle) checkServiceAccount(ctx context.Context, wf *unstructured.Unstructured) error {
+	if w.saChecker == nil || w.addon.Spec.ClusterScoped {
+		return nil
+	}
+
+	sa, _, _ := unstructured.NestedString(wf.Object, "spec", "serviceAccountName")
+	if sa == "" {
+		sa = "default"
+	}
+	if err := w.saChecker.Check(ctx, wf.GetNamespace(), sa); err != nil {
+		w.recorder.Event(w.addon, "Warning", "Failed", fmt.Sprintf("Workflow %s/%s blocked. %v", wf.GetNamespace(), wf.GetName(), err))
+		return err
+	}
+	return nil
+}
+
+// lifecycleObjects returns the artifact and resource manifest objects of all lifecycle workflows
+func (w *workflowLifecycle) lifecycleObjects() ([]*unstructured.Unstructured, error) {
+	w.objects = nil
+	for _, step := range []addonmgrv1alpha1.LifecycleStep{addonmgrv1alpha1.Prereqs, addonmgrv1alpha1.Install, addonmgrv1alpha1.Upgrade, addonmgrv1alpha1.Validate, addonmgrv1alpha1.Delete} {
+		wt, err := w.addon.GetWorkflowType(step)
+		if err != nil {
+			return nil, err
+		}
+		if wt.Template == "" {
+			continue
+		}
+
+		wf := &unstructured.Unstructured{}
+		if err := w.parse(wt, wf, string(step)); err != nil {
+			return nil, err
+		}
+		if err := w.configureWorkflowArtifacts(wf, wt); err != nil {
+			return nil, err
+		}
+	}
+	return w.objects, nil
+}
+
+// workflowImages returns the sorted unique image fields of the templates, including init and sidecar containers
+func workflowImages(obj interface{}) []string {
+	var images []string
+	var walk func(interface{})
+	walk = func(o interface{}) {
+		switch v := o.(type) {
+		case map[string]interface{}:
+			for k, val := range v {
+				// Resource manifests are deployed objects, not lifecycle tooling
+				if k == "resource" {
+					continue
+				}
+				if img, ok := val.(string); ok && k == "image" {
+					if !common.ContainsString(images, img) {
+						images = append(images, img)
+					}
+					continue
+				}
+				walk(val)
+			}
+		case []interface{}:
+			for _, val := range v {
+				walk(val)
+			}
+		}
+	}
+	walk(obj)
+	sort.Strings(images)
+	return images
+}
+
+// replaceImages replaces the container images of the workflow templates found by workflowImages
+func replaceImages(obj interface{}, images map[string]string) {
+	switch v := obj.(type) {
+	case map[string]interface{}:
+		for k, val := range v {
+			if k == "resource" {
+				continue
+			}
+			if img, ok := val.(string); ok && k == "image" {
+				if replaced, ok := images[img]; ok {
+					v[k] = replaced
+				}
+				continue
+			}
+			replaceImages(val, images)
+		}
+	case []interface{}:
+		for _, val := range v {
+			replaceImages(val, images)
+		}
+	}
+}
+
 func (w *workflowLifecycle) parse(wt *addonmgrv1alpha1.WorkflowType, wf *unstructured.Unstructured, name string) error {
 	var data map[string]interface{}
 
@@ -302,8 +634,8 @@ func (w *workflowLifecycle) parse(wt *addonmgrv1alpha1.WorkflowType, wf *unstruc
 		Version: "v1alpha1",
 	})
 
-	wf.SetNamespace(w.addon.GetNamespace())
-	wf.SetName(name)
+	wf.SetNamespace(w.workflowNamespace())
+	wf.SetName(QualifyName(w.addon, w.namespace, name))
 
 	if _, foundSpec, err := unstructured.NestedFieldNoCopy(wf.Object, "spec"); err != nil || !foundSpec {
 		return errors.New("invalid workflow, missing spec")
@@ -422,12 +754,28 @@ func (w *workflowLifecycle) processArtifact(obj string, resource *unstructured.U
 	}
 
 	resource.SetUnstructuredContent(data)
+	w.objects = append(w.objects, resource)
+
+	// Copy the addon labels and annotations selected by the propagation policy
+	w.propagate(resource)
 
 	// Add the default labels to the resource
 	w.addDefaultLabelsToResource(resource)
 
+	// Suffix the workloads of blue-green addons with their slot
+	if err := w.addSlotToResource(resource); err != nil {
+		return "", err
+	}
+
 	// Add the provided role annotation to the resource
-	w.addRoleAnnotationToResource(resource, wt)
+	if err := w.addRoleAnnotationToResource(resource, wt); err != nil {
+		return "", err
+	}
+
+	// Add the security context defaults to workload pod templates
+	if err := w.injectArtifactSecurityContext(resource); err != nil {
+		return "", err
+	}
 
 	appendData, err := yaml.Marshal(resource.UnstructuredContent())
 	if err != nil {
@@ -453,66 +801,129 @@ func (w *workflowLifecycle) addDefaultLabelsToResource(resource *unstructured.Un
 	resource.SetLabels(labels)
 }
 
-func (w *workflowLifecycle) addRoleAnnotationToResource(resource *unstructured.Unstructured, wt *addonmgrv1alpha1.WorkflowType) {
+func (w *workflowLifecycle) addRoleAnnotationToResource(resource *unstructured.Unstructured, wt *addonmgrv1alpha1.WorkflowType) error {
 	annotations := resource.GetAnnotations()
 	if annotations == nil {
 		annotations = map[string]string{}
 	}
 
-	if wt.Role != "" {
-		// TODO change this role name to a config value
-		annotations["iam.amazonaws.com/role"] = wt.Role
+	// Provisioned workflow service accounts carry the role instead of kube2iam annotations
+	if wt.Role != "" && w.provisioner == nil {
+		switch w.cloudProvider {
+		case common.GCPProvider:
+			// Workload Identity binds service accounts, not pods
+			if resource.GetKind() == "ServiceAccount" {
+				annotations[common.GCPServiceAccountAnnotation] = wt.Role
+			}
+		case common.AzureProvider:
+			// Workload Identity reads the client ID from service accounts and injects tokens into labeled pods
+			if resource.GetKind() == "ServiceAccount" {
+				annotations[common.AzureClientIDAnnotation] = wt.Role
+			}
+			if err := addAzureIdentityLabel(resource); err != nil {
+				return err
+			}
+		default:
+			// TODO change this role name to a config value
+			annotations["iam.amazonaws.com/role"] = wt.Role
+		}
 	}
 
 	resource.SetAnnotations(annotations)
+	return nil
+}
+
+// addAzureIdentityLabel opts the pods of the resource into Azure Workload Identity
+func addAzureIdentityLabel(resource *unstructured.Unstructured) error {
+	var fields []string
+	switch resource.GetKind() {
+	case "Pod":
+		fields = []string{"metadata", "labels"}
+	case "Deployment", "DaemonSet", "StatefulSet", "ReplicaSet", "Job":
+		fields = []string{"spec", "template", "metadata", "labels"}
+	case "CronJob":
+		fields = []string{"spec", "jobTemplate", "spec", "template", "metadata", "labels"}
+	default:
+		return nil
+	}
+	return unstructured.SetNestedField(resource.Object, "true", append(fields, common.AzureUseLabel)...)
+}
+
+// injectAzureIdentityLabel opts the workflow pods into Azure Workload Identity when the step has a role
+func (w *workflowLifecycle) injectAzureIdentityLabel(wf *unstructured.Unstructured, wt *addonmgrv1alpha1.WorkflowType) error {
+	if w.cloudProvider != common.AzureProvider || wt.Role == "" {
+		return nil
+	}
+	return unstructured.SetNestedField(wf.Object, "true", "spec", "podMetadata", "labels", common.AzureUseLabel)
 }
 
 func (w *workflowLifecycle) deleteCollisionWorkflows(ctx context.Context) (bool, error) {
 	var mostRecentWorkflowTime time.Time
-	var mostRecentWorkflow unstructured.Unstructured
+	var mostRecentWorkflow *unstructured.Unstructured
 	var deleted = false
 
-	workflows, err := w.dynClient.Resource(common.WorkflowGVR()).Namespace(w.addon.GetNamespace()).List(ctx, metav1.ListOptions{})
+	workflows, err := w.addonWorkflows(ctx, "")
 	if err != nil {
 		return false, fmt.Errorf("failed to list workflows. %v", err)
 	}
 
 	// Get the most recently run workflow for this addon
-	for _, workflow := range workflows.Items {
-		if strings.Contains(workflow.GetName(), w.addon.Name) {
-			if workflow.UnstructuredContent()["status"] == nil {
-				return false, nil
-			}
-			startedAt := workflow.UnstructuredContent()["status"].(map[string]interface{})["startedAt"].(string)
-			t, err := time.Parse(time.RFC3339, startedAt)
-			if err != nil {
-				return false, err
-			}
-			if !t.Before(mostRecentWorkflowTime) {
-				mostRecentWorkflowTime = t
-				mostRecentWorkflow = workflow
-			}
+	for _, workflow := range workflows {
+		if workflow.UnstructuredContent()["status"] == nil {
+			return false, nil
+		}
+		startedAt := workflow.UnstructuredContent()["status"].(map[string]interface{})["startedAt"].(string)
+		t, err := time.Parse(time.RFC3339, startedAt)
+		if err != nil {
+			return false, err
+		}
+		if !t.Before(mostRecentWorkflowTime) {
+			mostRecentWorkflowTime = t
+			mostRecentWorkflow = workflow
 		}
 	}
 
-	if mostRecentWorkflow.Object == nil {
+	if mostRecentWorkflow == nil {
 		return false, nil
 	}
 
 	// If the most recently run workflow doesn't have the current checksum, delete the old checksum workflows
-	if !strings.Contains(mostRecentWorkflow.GetName(), w.addon.Status.Checksum) {
-		for _, workflow := range workflows.Items {
-			phase := workflow.UnstructuredContent()["status"].(map[string]interface{})["phase"].(string)
-			if strings.Contains(workflow.GetName(), w.addon.Status.Checksum) && phase != "Pending" {
-				_ = w.Delete(ctx, workflow.GetName())
-				deleted = true
+	if workflowChecksum(mostRecentWorkflow) != w.addon.Status.Checksum {
+		current, err := w.addonWorkflows(ctx, w.addon.Status.Checksum)
+		if err != nil {
+			return false, fmt.Errorf("failed to list workflows. %v", err)
+		}
+		var labelled bool
+		for _, workflow := range current {
+			phase, _, _ := unstructured.NestedString(workflow.UnstructuredContent(), "status", "phase")
+			if phase == "Pending" {
+				continue
+			}
+			deleted = true
+			if l := workflow.GetLabels(); l[AddonLabel] != "" && l[ChecksumLabel] != "" {
+				labelled = true
+				continue
 			}
+			// Workflows submitted before they were labelled are not matched by the selector
+			_ = w.Delete(ctx, workflow.GetName())
+		}
+		if labelled {
+			_ = w.deleteChecksumWorkflows(ctx, w.addon.Status.Checksum)
 		}
 	}
 
 	return deleted, nil
 }
 
+// deleteChecksumWorkflows deletes the labelled workflows of the addon spec checksum that are not pending
+// with a single DeleteCollection call
+func (w *workflowLifecycle) deleteChecksumWorkflows(ctx context.Context, checksum string) error {
+	set := w.addonSelector()
+	set[ChecksumLabel] = checksum
+	selector := set.String() + "," + WfPhaseLabelKey + "!=Pending"
+	return w.dynClient.Resource(common.WorkflowGVR()).Namespace(w.workflowNamespace()).DeleteCollection(ctx, metav1.DeleteOptions{}, metav1.ListOptions{LabelSelector: selector})
+}
+
 func (w *workflowLifecycle) injectTTLs(wf *unstructured.Unstructured) error {
 	// Default ttl is to cleanup workflows after 3 days
 	var ttl, _ = time.ParseDuration("72h")
@@ -0,0 +1,70 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package workflows
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	addonmgrv1alpha1 "github.com/keikoproj/addon-manager/api/v1alpha1"
+	"github.com/keikoproj/addon-manager/pkg/common"
+)
+
+// GetWorkflowProgress returns the progress of an Argo workflow and the templates currently executing
+func GetWorkflowProgress(workflow *unstructured.Unstructured) *addonmgrv1alpha1.WorkflowProgress {
+	progress := &addonmgrv1alpha1.WorkflowProgress{
+		Workflow: workflow.GetName(),
+	}
+
+	// Newer Argo versions report progress directly
+	reported, _, _ := unstructured.NestedString(workflow.UnstructuredContent(), "status", "progress")
+
+	// Nodes are only read, avoid copying them
+	val, _, _ := unstructured.NestedFieldNoCopy(workflow.UnstructuredContent(), "status", "nodes")
+	nodes, _ := val.(map[string]interface{})
+
+	var total, completed int
+	var current []string
+	for _, n := range nodes {
+		node, ok := n.(map[string]interface{})
+		if !ok || node["type"] != "Pod" {
+			continue
+		}
+
+		total++
+		switch node["phase"] {
+		case "Succeeded", "Skipped", "Omitted":
+			completed++
+		case "Running":
+			if name, ok := node["templateName"].(string); ok && name != "" && !common.ContainsString(current, name) {
+				current = append(current, name)
+			}
+		}
+	}
+
+	if reported != "" {
+		progress.Progress = reported
+	} else if total > 0 {
+		progress.Progress = fmt.Sprintf("%d/%d", completed, total)
+	}
+
+	sort.Strings(current)
+	progress.CurrentTemplate = strings.Join(current, ",")
+
+	return progress
+}
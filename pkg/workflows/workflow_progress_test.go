@@ -0,0 +1,61 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package workflows
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestGetWorkflowProgress(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	wf := &unstructured.Unstructured{Object: map[string]interface{}{
+		"metadata": map[string]interface{}{"name": "addon-install-wf"},
+		"status": map[string]interface{}{
+			"phase": "Running",
+			"nodes": map[string]interface{}{
+				"a": map[string]interface{}{"type": "Steps", "phase": "Running", "templateName": "entry"},
+				"b": map[string]interface{}{"type": "Pod", "phase": "Succeeded", "templateName": "prereqs"},
+				"c": map[string]interface{}{"type": "Pod", "phase": "Running", "templateName": "submit"},
+				"d": map[string]interface{}{"type": "Pod", "phase": "Running", "templateName": "apply-crds"},
+				"e": map[string]interface{}{"type": "Pod", "phase": "Pending", "templateName": "validate"},
+			},
+		},
+	}}
+
+	progress := GetWorkflowProgress(wf)
+	g.Expect(progress.Workflow).To(Equal("addon-install-wf"))
+	g.Expect(progress.Progress).To(Equal("1/4"))
+	g.Expect(progress.CurrentTemplate).To(Equal("apply-crds,submit"))
+
+	// Progress reported by Argo takes precedence
+	g.Expect(unstructured.SetNestedField(wf.Object, "3/7", "status", "progress")).To(Succeed())
+	g.Expect(GetWorkflowProgress(wf).Progress).To(Equal("3/7"))
+}
+
+func TestGetWorkflowProgress_NoStatus(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	wf := &unstructured.Unstructured{Object: map[string]interface{}{
+		"metadata": map[string]interface{}{"name": "addon-install-wf"},
+	}}
+
+	progress := GetWorkflowProgress(wf)
+	g.Expect(progress.Progress).To(BeEmpty())
+	g.Expect(progress.CurrentTemplate).To(BeEmpty())
+}
@@ -16,6 +16,9 @@ package workflows
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"reflect"
 	"strings"
 	"testing"
 	"time"
@@ -23,17 +26,22 @@ import (
 	. "github.com/onsi/gomega"
 	"gopkg.in/yaml.v3"
 	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
 	dynfake "k8s.io/client-go/dynamic/fake"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	k8stesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/cache"
 	"k8s.io/client-go/tools/record"
 	runtimefake "sigs.k8s.io/controller-runtime/pkg/client/fake"
 
 	"github.com/keikoproj/addon-manager/api/v1alpha1"
 	"github.com/keikoproj/addon-manager/pkg/common"
+	"github.com/keikoproj/addon-manager/pkg/faults"
 )
 
 var sch = runtime.NewScheme()
@@ -326,6 +334,339 @@ func TestNewWorkflowLifecycle(t *testing.T) {
 	g.Expect(wfl).To(BeAssignableToTypeOf(expected))
 }
 
+type denyVerifier map[string]bool
+
+func (d denyVerifier) Verify(_ context.Context, image string) (string, error) {
+	if d[image] {
+		return "", fmt.Errorf("image %s is not signed", image)
+	}
+	return image + "@sha256:0123", nil
+}
+
+func TestWorkflowImages(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	var templates []interface{}
+	g.Expect(yaml.Unmarshal([]byte(`
+- name: main
+  container:
+    image: alpine:3.12
+  initContainers:
+  - image: busybox
+  sidecars:
+  - image: alpine:3.12
+- name: script
+  script:
+    image: python:3
+- name: apply
+  resource:
+    manifest: |
+      spec:
+        image: nginx
+`), &templates)).To(Succeed())
+
+	g.Expect(workflowImages(templates)).To(Equal([]string{"alpine:3.12", "busybox", "python:3"}))
+}
+
+func TestWorkflowLifecycle_VerifyImages(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	a := &v1alpha1.Addon{}
+	wf := &unstructured.Unstructured{Object: map[string]interface{}{"spec": map[string]interface{}{
+		"templates": []interface{}{map[string]interface{}{"container": map[string]interface{}{"image": "unsigned:1"}}},
+	}}}
+
+	// Verified images are pinned to their digest
+	wfl := NewWorkflowLifecycle(fclient, dynClient, a, rcdr, sch, WithImageVerifier(denyVerifier{})).(*workflowLifecycle)
+	pinned := wf.DeepCopy()
+	g.Expect(wfl.verifyImages(ctx, pinned)).To(Succeed())
+	templates, _, _ := unstructured.NestedSlice(pinned.Object, "spec", "templates")
+	g.Expect(workflowImages(templates)).To(Equal([]string{"unsigned:1@sha256:0123"}))
+
+	wfl = NewWorkflowLifecycle(fclient, dynClient, a, rcdr, sch, WithImageVerifier(denyVerifier{"unsigned:1": true})).(*workflowLifecycle)
+	g.Expect(wfl.verifyImages(ctx, wf)).To(MatchError(ContainSubstring("not signed")))
+
+	_ = unstructured.SetNestedSlice(wf.Object, []interface{}{map[string]interface{}{"container": map[string]interface{}{"image": "{{inputs.parameters.image}}"}}}, "spec", "templates")
+	g.Expect(wfl.verifyImages(ctx, wf)).To(MatchError(ContainSubstring("templated")))
+}
+
+type adminChecker map[string]bool
+
+func (c adminChecker) Check(_ context.Context, namespace, name string) error {
+	if c[namespace+"/"+name] {
+		return fmt.Errorf("service account %s/%s has cluster-admin permissions", namespace, name)
+	}
+	return nil
+}
+
+func TestWorkflowLifecycle_CheckServiceAccount(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	a := &v1alpha1.Addon{ObjectMeta: metav1.ObjectMeta{Name: "scoped-addon", Namespace: "default"}}
+	wf := &unstructured.Unstructured{}
+	wf.SetNamespace("default")
+	g.Expect(unstructured.SetNestedField(wf.Object, "admin-sa", "spec", "serviceAccountName")).To(Succeed())
+
+	checker := adminChecker{"default/admin-sa": true}
+	wfl := NewWorkflowLifecycle(fclient, dynClient, a, rcdr, sch, WithServiceAccountChecker(checker)).(*workflowLifecycle)
+	g.Expect(wfl.checkServiceAccount(ctx, wf)).To(MatchError(ContainSubstring("cluster-admin")))
+
+	a.Spec.ClusterScoped = true
+	g.Expect(wfl.checkServiceAccount(ctx, wf)).To(Succeed())
+
+	a.Spec.ClusterScoped = false
+	g.Expect(unstructured.SetNestedField(wf.Object, "scoped-sa", "spec", "serviceAccountName")).To(Succeed())
+	g.Expect(wfl.checkServiceAccount(ctx, wf)).To(Succeed())
+}
+
+type recordingProvisioner struct {
+	role  string
+	kinds []string
+}
+
+func (r *recordingProvisioner) Provision(_ context.Context, addon *v1alpha1.Addon, role string, objects []*unstructured.Unstructured) (string, error) {
+	r.role = role
+	for _, obj := range objects {
+		r.kinds = append(r.kinds, obj.GetKind())
+	}
+	return addon.Name + "-workflow", nil
+}
+
+func TestWorkflowLifecycle_ProvisionServiceAccount(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	a := &v1alpha1.Addon{ObjectMeta: metav1.ObjectMeta{Name: "rbac-addon", Namespace: "default"}}
+	a.Spec.Lifecycle.Prereqs.Template = wfPrereqsTemplate
+	a.Spec.Lifecycle.Install.Template = wfSpecTemplate
+
+	p := &recordingProvisioner{}
+	wfl := NewWorkflowLifecycle(fclient, dynClient, a, rcdr, sch, WithServiceAccountProvisioner(p)).(*workflowLifecycle)
+
+	wf := &unstructured.Unstructured{Object: map[string]interface{}{"spec": map[string]interface{}{"serviceAccountName": "admin"}}}
+	g.Expect(wfl.provisionServiceAccount(ctx, wf, "arn:aws:iam::123456789012:role/addon")).To(Succeed())
+
+	sa, _, _ := unstructured.NestedString(wf.Object, "spec", "serviceAccountName")
+	g.Expect(sa).To(Equal("rbac-addon-workflow"))
+	g.Expect(p.kinds).To(ContainElements("Namespace", "ClusterRole", "Deployment"))
+	g.Expect(p.role).To(Equal("arn:aws:iam::123456789012:role/addon"))
+}
+
+func TestWorkflowLifecycle_SecurityContextDefaults(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	a := &v1alpha1.Addon{ObjectMeta: metav1.ObjectMeta{Name: "psa-addon", Namespace: "default"}}
+	wfl := NewWorkflowLifecycle(fclient, dynClient, a, rcdr, sch, WithSecurityContextDefaults(RestrictedSecurityContext)).(*workflowLifecycle)
+
+	wf := &unstructured.Unstructured{}
+	wt := &v1alpha1.WorkflowType{Template: wfSpecTemplate}
+	g.Expect(wfl.parse(wt, wf, "install")).To(Succeed())
+	g.Expect(unstructured.SetNestedSlice(wf.Object, []interface{}{
+		map[string]interface{}{"name": "script", "container": map[string]interface{}{
+			"image":           "alpine:3.12",
+			"securityContext": map[string]interface{}{"capabilities": map[string]interface{}{"drop": []interface{}{"NET_RAW"}}},
+		}},
+	}, "spec", "templates")).To(Succeed())
+	g.Expect(wfl.injectSecurityContext(wf)).To(Succeed())
+
+	runAsNonRoot, _, _ := unstructured.NestedBool(wf.Object, "spec", "securityContext", "runAsNonRoot")
+	g.Expect(runAsNonRoot).To(BeTrue())
+	seccomp, _, _ := unstructured.NestedString(wf.Object, "spec", "securityContext", "seccompProfile", "type")
+	g.Expect(seccomp).To(Equal("RuntimeDefault"))
+
+	templates, _, _ := unstructured.NestedSlice(wf.Object, "spec", "templates")
+	container := templates[0].(map[string]interface{})["container"].(map[string]interface{})
+	drop, _, _ := unstructured.NestedStringSlice(container, "securityContext", "capabilities", "drop")
+	g.Expect(drop).To(Equal([]string{"NET_RAW"}))
+	escalation, found, _ := unstructured.NestedBool(container, "securityContext", "allowPrivilegeEscalation")
+	g.Expect(found).To(BeTrue())
+	g.Expect(escalation).To(BeFalse())
+
+	deployment := &unstructured.Unstructured{}
+	data, err := wfl.processArtifact(`
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: event-router
+spec:
+  template:
+    spec:
+      containers:
+      - name: kube-event-router
+        image: gcr.io/heptio-images/eventrouter:v0.2
+`, deployment, wt)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(data).To(ContainSubstring("runAsNonRoot: true"))
+	containers, _, _ := unstructured.NestedSlice(deployment.Object, "spec", "template", "spec", "containers")
+	drop, _, _ = unstructured.NestedStringSlice(containers[0].(map[string]interface{}), "securityContext", "capabilities", "drop")
+	g.Expect(drop).To(Equal([]string{"ALL"}))
+
+	configMap := &unstructured.Unstructured{}
+	data, err = wfl.processArtifact("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: cm\n", configMap, wt)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(data).ToNot(ContainSubstring("securityContext"))
+}
+
+func TestWorkflowLifecycle_GCPRoleAnnotation(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	a := &v1alpha1.Addon{ObjectMeta: metav1.ObjectMeta{Name: "gke-addon", Namespace: "default"}}
+	wfl := NewWorkflowLifecycle(fclient, dynClient, a, rcdr, sch, WithCloudProvider(common.GCPProvider)).(*workflowLifecycle)
+	wt := &v1alpha1.WorkflowType{Role: "addon@project.iam.gserviceaccount.com"}
+
+	sa := &unstructured.Unstructured{}
+	_, err := wfl.processArtifact("apiVersion: v1\nkind: ServiceAccount\nmetadata:\n  name: addon-sa\n", sa, wt)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(sa.GetAnnotations()).To(Equal(map[string]string{common.GCPServiceAccountAnnotation: wt.Role}))
+
+	deployment := &unstructured.Unstructured{}
+	_, err = wfl.processArtifact("apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: addon\n", deployment, wt)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(deployment.GetAnnotations()).To(BeEmpty())
+}
+
+func TestWorkflowLifecycle_AzureIdentity(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	a := &v1alpha1.Addon{ObjectMeta: metav1.ObjectMeta{Name: "aks-addon", Namespace: "default"}}
+	wfl := NewWorkflowLifecycle(fclient, dynClient, a, rcdr, sch, WithCloudProvider(common.AzureProvider)).(*workflowLifecycle)
+	wt := &v1alpha1.WorkflowType{Role: "00000000-0000-0000-0000-000000000000"}
+
+	sa := &unstructured.Unstructured{}
+	_, err := wfl.processArtifact("apiVersion: v1\nkind: ServiceAccount\nmetadata:\n  name: addon-sa\n", sa, wt)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(sa.GetAnnotations()).To(Equal(map[string]string{common.AzureClientIDAnnotation: wt.Role}))
+
+	deployment := &unstructured.Unstructured{}
+	_, err = wfl.processArtifact("apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: addon\n", deployment, wt)
+	g.Expect(err).ToNot(HaveOccurred())
+	use, _, _ := unstructured.NestedString(deployment.Object, "spec", "template", "metadata", "labels", common.AzureUseLabel)
+	g.Expect(use).To(Equal("true"))
+
+	wf := &unstructured.Unstructured{Object: map[string]interface{}{"spec": map[string]interface{}{}}}
+	g.Expect(wfl.injectAzureIdentityLabel(wf, wt)).To(Succeed())
+	use, _, _ = unstructured.NestedString(wf.Object, "spec", "podMetadata", "labels", common.AzureUseLabel)
+	g.Expect(use).To(Equal("true"))
+}
+
+func TestWorkflowLifecycle_SecretParams(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	s := runtime.NewScheme()
+	g.Expect(clientgoscheme.AddToScheme(s)).To(Succeed())
+	g.Expect(v1alpha1.AddToScheme(s)).To(Succeed())
+	c := runtimefake.NewFakeClientWithScheme(s)
+
+	a := &v1alpha1.Addon{ObjectMeta: metav1.ObjectMeta{Name: "sensitive-addon", Namespace: "default", UID: "1234"}}
+	wf := &unstructured.Unstructured{}
+	wf.SetNamespace("default")
+	g.Expect(unstructured.SetNestedSlice(wf.Object, []interface{}{
+		map[string]interface{}{"name": "install", "container": map[string]interface{}{
+			"image": "alpine:3.12",
+			"env":   []interface{}{map[string]interface{}{"name": "DB_PASSWORD", "value": "override"}},
+		}},
+		map[string]interface{}{"name": "steps", "steps": []interface{}{}},
+	}, "spec", "templates")).To(Succeed())
+
+	params := map[string]string{"api-token": "t0ken", "DB_PASSWORD": "s3cret"}
+	wfl := NewWorkflowLifecycle(c, dynClient, a, rcdr, s, WithSecretParams(params)).(*workflowLifecycle)
+	g.Expect(wfl.applySecretParams(ctx, wf)).To(Succeed())
+
+	secret := &v1.Secret{}
+	g.Expect(c.Get(ctx, types.NamespacedName{Namespace: "default", Name: SecretParamsName(a)}, secret)).To(Succeed())
+	g.Expect(secret.Data).To(HaveKeyWithValue("api-token", []byte("t0ken")))
+	g.Expect(secret.OwnerReferences).To(HaveLen(1))
+
+	templates, _, _ := unstructured.NestedSlice(wf.Object, "spec", "templates")
+	env, _, _ := unstructured.NestedSlice(templates[0].(map[string]interface{}), "container", "env")
+	g.Expect(env).To(HaveLen(2))
+	g.Expect(env[0]).To(HaveKeyWithValue("value", "override"))
+	g.Expect(env[1]).To(HaveKeyWithValue("name", "api_token"))
+	g.Expect(templates[1]).ToNot(HaveKey("container"))
+
+	// Parameters are updated on the next workflow
+	wfl = NewWorkflowLifecycle(c, dynClient, a, rcdr, s, WithSecretParams(map[string]string{"api-token": "r0tated"})).(*workflowLifecycle)
+	g.Expect(wfl.applySecretParams(ctx, wf)).To(Succeed())
+	secret = &v1.Secret{}
+	g.Expect(c.Get(ctx, types.NamespacedName{Namespace: "default", Name: SecretParamsName(a)}, secret)).To(Succeed())
+	g.Expect(secret.Data).To(Equal(map[string][]byte{"api-token": []byte("r0tated")}))
+}
+
+func TestWorkflowLifecycle_RemoteCluster(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	s := runtime.NewScheme()
+	g.Expect(clientgoscheme.AddToScheme(s)).To(Succeed())
+	g.Expect(v1alpha1.AddToScheme(s)).To(Succeed())
+	local := runtimefake.NewFakeClientWithScheme(s)
+	remote := runtimefake.NewFakeClientWithScheme(s)
+
+	a := &v1alpha1.Addon{ObjectMeta: metav1.ObjectMeta{Name: "remote-addon", Namespace: "default", UID: "1234"}}
+	wf := &unstructured.Unstructured{}
+	wf.SetNamespace("default")
+
+	wfl := NewWorkflowLifecycle(local, dynClient, a, rcdr, s,
+		WithSecretParams(map[string]string{"token": "t0ken"}), WithRemoteCluster(remote, dynClient)).(*workflowLifecycle)
+	g.Expect(wfl.applySecretParams(ctx, wf)).To(Succeed())
+
+	key := types.NamespacedName{Namespace: "default", Name: SecretParamsName(a)}
+	g.Expect(local.Get(ctx, key, &v1.Secret{})).ToNot(Succeed())
+	secret := &v1.Secret{}
+	g.Expect(remote.Get(ctx, key, secret)).To(Succeed())
+	g.Expect(secret.OwnerReferences).To(BeEmpty())
+	g.Expect(secret.Labels).To(HaveKeyWithValue(AddonLabel, "remote-addon"))
+}
+
+func TestWorkflowLifecycle_WithParams(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	a := &v1alpha1.Addon{}
+	a.Spec.Params.Data = map[string]v1alpha1.FlexString{"user": "admin", "password": "placeholder"}
+
+	wfl := NewWorkflowLifecycle(fclient, dynClient, a, rcdr, sch, WithParams(map[string]string{"password": "s3cret"})).(*workflowLifecycle)
+
+	wf := &unstructured.Unstructured{Object: map[string]interface{}{"spec": map[string]interface{}{}}}
+	g.Expect(wfl.configureGlobalWFParameters(a, wf)).To(BeTrue())
+
+	params, _, _ := unstructured.NestedSlice(wf.Object, "spec", "arguments", "parameters")
+	values := map[string][]interface{}{}
+	for _, p := range params {
+		m := p.(map[string]interface{})
+		values[m["name"].(string)] = append(values[m["name"].(string)], m["value"])
+	}
+	g.Expect(values["user"]).To(Equal([]interface{}{"admin"}))
+	g.Expect(values["password"]).To(Equal([]interface{}{"s3cret"}))
+}
+
+func TestWorkflowLifecycle_Slot(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	a := &v1alpha1.Addon{ObjectMeta: metav1.ObjectMeta{Name: "bg-addon", Namespace: "default"}}
+	wfl := NewWorkflowLifecycle(fclient, dynClient, a, rcdr, sch, WithSlot(v1alpha1.GreenSlot)).(*workflowLifecycle)
+	wt := &v1alpha1.WorkflowType{}
+
+	deployment := &unstructured.Unstructured{}
+	_, err := wfl.processArtifact("apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: addon\nspec:\n  selector:\n    matchLabels:\n      app: addon\n", deployment, wt)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(deployment.GetName()).To(Equal("addon-green"))
+	g.Expect(deployment.GetLabels()).To(HaveKeyWithValue(SlotLabel, v1alpha1.GreenSlot))
+	selector, _, _ := unstructured.NestedStringMap(deployment.Object, "spec", "selector", "matchLabels")
+	g.Expect(selector).To(Equal(map[string]string{"app": "addon", SlotLabel: v1alpha1.GreenSlot}))
+	podLabels, _, _ := unstructured.NestedStringMap(deployment.Object, "spec", "template", "metadata", "labels")
+	g.Expect(podLabels).To(HaveKeyWithValue(SlotLabel, v1alpha1.GreenSlot))
+
+	// Services are shared by both slots
+	service := &unstructured.Unstructured{}
+	_, err = wfl.processArtifact("apiVersion: v1\nkind: Service\nmetadata:\n  name: addon\n", service, wt)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(service.GetName()).To(Equal("addon"))
+	g.Expect(service.GetLabels()).ToNot(HaveKey(SlotLabel))
+
+	wf := &unstructured.Unstructured{Object: map[string]interface{}{"spec": map[string]interface{}{}}}
+	g.Expect(wfl.configureGlobalWFParameters(a, wf)).To(BeTrue())
+	params, _, _ := unstructured.NestedSlice(wf.Object, "spec", "arguments", "parameters")
+	g.Expect(params).To(ContainElement(map[string]interface{}{"name": "slot", "value": v1alpha1.GreenSlot}))
+}
+
 func TestWorkflowLifecycle_Install_Resources(t *testing.T) {
 	g := NewGomegaWithT(t)
 
@@ -744,3 +1085,621 @@ func TestNewWorkflowLifecycle_Delete(t *testing.T) {
 	// Now try to delete
 	g.Expect(wfl.Delete(ctx, "addon-wf-test")).To(Not(HaveOccurred()))
 }
+
+func TestWorkflowLifecycle_WorkflowCache(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	a := &v1alpha1.Addon{ObjectMeta: metav1.ObjectMeta{Name: "cached", Namespace: "addon-manager-system"}}
+	a.Status.Checksum = "abc"
+
+	newWorkflow := func(name string, labels map[string]string, owner string) *unstructured.Unstructured {
+		wf := &unstructured.Unstructured{}
+		wf.SetGroupVersionKind(schema.GroupVersionKind{Kind: "Workflow", Group: "argoproj.io", Version: "v1alpha1"})
+		wf.SetNamespace("addon-manager-system")
+		wf.SetName(name)
+		wf.SetLabels(labels)
+		if owner != "" {
+			controller := true
+			wf.SetOwnerReferences([]metav1.OwnerReference{{Kind: "Addon", Name: owner, Controller: &controller}})
+		}
+		_ = unstructured.SetNestedField(wf.Object, "Succeeded", "status", "phase")
+		return wf
+	}
+
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, Indexers)
+	g.Expect(indexer.Add(newWorkflow("cached-install-abc-wf", map[string]string{AddonLabel: "cached", ChecksumLabel: "abc"}, ""))).To(Succeed())
+	// Workflows submitted before they were labelled are indexed by owner and name
+	g.Expect(indexer.Add(newWorkflow("cached-prereqs-0ld-wf", nil, "cached"))).To(Succeed())
+	// Names containing the addon name belong to other addons
+	g.Expect(indexer.Add(newWorkflow("cached-two-install-abc-wf", map[string]string{AddonLabel: "cached-two", ChecksumLabel: "abc"}, ""))).To(Succeed())
+
+	// The workflows only exist in the cache, the clients are not used
+	wfl := NewWorkflowLifecycle(fclient, dynClient, a, rcdr, sch, WithWorkflowCache(indexer, "addon-manager-system")).(*workflowLifecycle)
+	found, err := wfl.getWorkflow(ctx, types.NamespacedName{Namespace: "addon-manager-system", Name: "cached-install-abc-wf"})
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(found).ToNot(BeNil())
+	// Cached workflows are read only and returned without a copy
+	cached, _, _ := indexer.GetByKey("addon-manager-system/cached-install-abc-wf")
+	g.Expect(found).To(BeIdenticalTo(cached))
+
+	found, err = wfl.getWorkflow(ctx, types.NamespacedName{Namespace: "addon-manager-system", Name: "missing"})
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(found).To(BeNil())
+
+	items, err := wfl.addonWorkflows(ctx, "")
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(items).To(HaveLen(2))
+
+	items, err = wfl.addonWorkflows(ctx, "0ld")
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(items).To(HaveLen(1))
+	g.Expect(items[0].GetName()).To(Equal("cached-prereqs-0ld-wf"))
+
+	// Other namespaces are not cached
+	g.Expect(wfl.cachedWorkflows("default")).To(BeNil())
+}
+
+func TestWorkflowLifecycle_LabelWorkflow(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	a := &v1alpha1.Addon{ObjectMeta: metav1.ObjectMeta{Name: "labelled", Namespace: "default"}}
+	wfl := NewWorkflowLifecycle(fclient, dynClient, a, rcdr, sch).(*workflowLifecycle)
+
+	wf := &unstructured.Unstructured{}
+	wf.SetName(a.GetFormattedWorkflowName(v1alpha1.Install))
+	wfl.labelWorkflow(wf)
+	g.Expect(wf.GetLabels()).To(Equal(map[string]string{AddonLabel: "labelled", ChecksumLabel: a.CalculateChecksum()}))
+
+	wf.SetLabels(nil)
+	g.Expect(workflowChecksum(wf)).To(Equal(a.CalculateChecksum()))
+}
+
+func TestWorkflowLifecycle_DeleteCollisionWorkflows(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	s := runtime.NewScheme()
+	gv := common.WorkflowGVR().GroupVersion()
+	s.AddKnownTypeWithName(gv.WithKind("Workflow"), &unstructured.Unstructured{})
+	s.AddKnownTypeWithName(gv.WithKind("WorkflowList"), &unstructured.UnstructuredList{})
+
+	newWorkflow := func(name, checksum, startedAt string) runtime.Object {
+		wf := &unstructured.Unstructured{Object: map[string]interface{}{
+			"status": map[string]interface{}{"phase": "Succeeded", "startedAt": startedAt},
+		}}
+		wf.SetGroupVersionKind(gv.WithKind("Workflow"))
+		wf.SetNamespace("default")
+		wf.SetName(name)
+		if checksum != "" {
+			wf.SetLabels(map[string]string{AddonLabel: "collision", ChecksumLabel: checksum})
+		} else {
+			controller := true
+			wf.SetOwnerReferences([]metav1.OwnerReference{{Kind: "Addon", Name: "collision", Controller: &controller}})
+		}
+		return wf
+	}
+	dyn := dynfake.NewSimpleDynamicClient(s,
+		newWorkflow("collision-install-old-wf", "old", "2021-01-03T00:00:00Z"),
+		newWorkflow("collision-install-cur-wf", "cur", "2021-01-02T00:00:00Z"),
+		newWorkflow("collision-prereqs-cur-wf", "", "2021-01-01T00:00:00Z"),
+	)
+	var selectors []string
+	dyn.PrependReactor("delete-collection", "workflows", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		selectors = append(selectors, action.(k8stesting.DeleteCollectionAction).GetListRestrictions().Labels.String())
+		return true, nil, nil
+	})
+
+	a := &v1alpha1.Addon{ObjectMeta: metav1.ObjectMeta{Name: "collision", Namespace: "default"}}
+	a.Status.Checksum = "cur"
+	wfl := NewWorkflowLifecycle(fclient, dyn, a, rcdr, sch).(*workflowLifecycle)
+
+	deleted, err := wfl.deleteCollisionWorkflows(ctx)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(deleted).To(BeTrue())
+
+	// Labelled workflows are deleted together, unlabelled ones one by one
+	g.Expect(selectors).To(Equal([]string{"addonmgr.keikoproj.io/addon=collision,addonmgr.keikoproj.io/checksum=cur,workflows.argoproj.io/phase!=Pending"}))
+	_, err = dyn.Resource(common.WorkflowGVR()).Namespace("default").Get(ctx, "collision-prereqs-cur-wf", metav1.GetOptions{})
+	g.Expect(apierrors.IsNotFound(err)).To(BeTrue())
+
+	// Nothing is deleted when the most recent workflow is of the current spec
+	a.Status.Checksum = "old"
+	selectors = nil
+	deleted, err = wfl.deleteCollisionWorkflows(ctx)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(deleted).To(BeFalse())
+	g.Expect(selectors).To(BeEmpty())
+}
+
+func TestNewWorkflowInformer(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	s := runtime.NewScheme()
+	gv := common.WorkflowGVR().GroupVersion()
+	s.AddKnownTypeWithName(gv.WithKind("Workflow"), &unstructured.Unstructured{})
+	s.AddKnownTypeWithName(gv.WithKind("WorkflowList"), &unstructured.UnstructuredList{})
+
+	wf := &unstructured.Unstructured{Object: map[string]interface{}{
+		"spec": map[string]interface{}{"templates": []interface{}{map[string]interface{}{"name": "entry"}}},
+		"status": map[string]interface{}{
+			"phase":           "Running",
+			"storedTemplates": map[string]interface{}{"entry": map[string]interface{}{}},
+			"nodes": map[string]interface{}{
+				"stripped": map[string]interface{}{
+					"type":    "Pod",
+					"phase":   "Running",
+					"outputs": map[string]interface{}{"artifacts": []interface{}{map[string]interface{}{"name": "raw"}}},
+				},
+			},
+		},
+	}}
+	wf.SetGroupVersionKind(gv.WithKind("Workflow"))
+	wf.SetNamespace("default")
+	wf.SetName("stripped-install-abc-wf")
+	wf.SetLabels(map[string]string{AddonLabel: "stripped", ChecksumLabel: "abc"})
+	wf.SetAnnotations(map[string]string{lastAppliedAnnotation: "{}", "keep": "true"})
+
+	inf := NewWorkflowInformer(dynfake.NewSimpleDynamicClient(s, wf), "default", 0)
+	stop := make(chan struct{})
+	defer close(stop)
+	go inf.Run(stop)
+	g.Expect(cache.WaitForCacheSync(stop, inf.HasSynced)).To(BeTrue())
+
+	objs, err := inf.GetIndexer().ByIndex(ChecksumIndex, "default/stripped/abc")
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(objs).To(HaveLen(1))
+	cached := objs[0].(*unstructured.Unstructured)
+	g.Expect(cached.Object).ToNot(HaveKey("spec"))
+	g.Expect(cached.GetAnnotations()).To(Equal(map[string]string{"keep": "true"}))
+	g.Expect(cached.Object["status"]).To(Equal(map[string]interface{}{
+		"phase": "Running",
+		"nodes": map[string]interface{}{
+			"stripped": map[string]interface{}{"type": "Pod", "phase": "Running"},
+		},
+	}))
+	g.Expect(GetWorkflowProgress(cached).Progress).To(Equal("0/1"))
+}
+
+// benchmarkLifecycle returns the lifecycle of an addon with a large install template and its running workflow
+// in a workflow cache with workflows of previous specs
+func benchmarkLifecycle(b *testing.B) (*workflowLifecycle, *v1alpha1.WorkflowType, string) {
+	a := &v1alpha1.Addon{ObjectMeta: metav1.ObjectMeta{Name: "bench", Namespace: "default"}}
+	a.Spec.Lifecycle.Install.Template = wfSpecTemplate + "        data: \"" + strings.Repeat("x", 200*1024) + "\"\n"
+	a.Status.Checksum = a.CalculateChecksum()
+	name := a.GetFormattedWorkflowName(v1alpha1.Install)
+
+	nodes := map[string]interface{}{}
+	for i := 0; i < 20; i++ {
+		nodes[fmt.Sprintf("bench-%d", i)] = map[string]interface{}{"type": "Pod", "phase": "Succeeded", "templateName": "submit"}
+	}
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, Indexers)
+	for i := 0; i < 50; i++ {
+		wf := &unstructured.Unstructured{Object: map[string]interface{}{
+			"status": map[string]interface{}{"phase": "Succeeded", "startedAt": "2021-01-01T00:00:00Z", "nodes": nodes},
+		}}
+		wf.SetNamespace("default")
+		wf.SetName(fmt.Sprintf("bench-install-%d-wf", i))
+		wf.SetLabels(map[string]string{AddonLabel: "bench", ChecksumLabel: fmt.Sprint(i)})
+		if i == 0 {
+			wf.SetName(name)
+			wf.SetLabels(map[string]string{AddonLabel: "bench", ChecksumLabel: a.Status.Checksum})
+			_ = unstructured.SetNestedField(wf.Object, "Running", "status", "phase")
+			_ = unstructured.SetNestedField(wf.Object, "2021-01-02T00:00:00Z", "status", "startedAt")
+		}
+		if err := indexer.Add(wf); err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	wfl := NewWorkflowLifecycle(fclient, dynClient, a, rcdr, sch, WithWorkflowCache(indexer, "default")).(*workflowLifecycle)
+	return wfl, &a.Spec.Lifecycle.Install, name
+}
+
+func BenchmarkWorkflowLifecycle_Install(b *testing.B) {
+	wfl, wt, name := benchmarkLifecycle(b)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		phase, err := wfl.Install(ctx, wt, name)
+		if err != nil || phase != v1alpha1.Pending {
+			b.Fatalf("unexpected install result %s. %v", phase, err)
+		}
+	}
+}
+
+func BenchmarkWorkflowLifecycle_AddonWorkflows(b *testing.B) {
+	wfl, _, _ := benchmarkLifecycle(b)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		items, err := wfl.addonWorkflows(ctx, "")
+		if err != nil || len(items) != 50 {
+			b.Fatalf("unexpected workflows %d. %v", len(items), err)
+		}
+	}
+}
+
+func TestSpecParams(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	names := map[string]bool{}
+	for _, p := range specParams(&v1alpha1.Addon{}) {
+		names[p.(map[string]interface{})["name"].(string)] = true
+	}
+
+	// Every string field of the package spec and the cluster context is a global parameter
+	for _, typ := range []reflect.Type{reflect.TypeOf(v1alpha1.PackageSpec{}), reflect.TypeOf(v1alpha1.ClusterContext{})} {
+		for i := 0; i < typ.NumField(); i++ {
+			if typ.Field(i).Type.Kind() != reflect.String {
+				continue
+			}
+			name := strings.Split(typ.Field(i).Tag.Get("json"), ",")[0]
+			g.Expect(names).To(HaveKey(name), "field %s.%s is not a global parameter", typ.Name(), typ.Field(i).Name)
+		}
+	}
+}
+
+func TestWorkflowLifecycle_AddonWorkflows(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	s := runtime.NewScheme()
+	gv := common.WorkflowGVR().GroupVersion()
+	s.AddKnownTypeWithName(gv.WithKind("Workflow"), &unstructured.Unstructured{})
+	s.AddKnownTypeWithName(gv.WithKind("WorkflowList"), &unstructured.UnstructuredList{})
+
+	newWorkflow := func(name string, labels map[string]string, owner string) runtime.Object {
+		wf := &unstructured.Unstructured{}
+		wf.SetGroupVersionKind(gv.WithKind("Workflow"))
+		wf.SetNamespace("default")
+		wf.SetName(name)
+		wf.SetLabels(labels)
+		if owner != "" {
+			controller := true
+			wf.SetOwnerReferences([]metav1.OwnerReference{{Kind: "Addon", Name: owner, Controller: &controller}})
+		}
+		return wf
+	}
+	dyn := dynfake.NewSimpleDynamicClient(s,
+		newWorkflow("listed-install-abc-wf", map[string]string{AddonLabel: "listed", ChecksumLabel: "abc"}, ""),
+		newWorkflow("listed-install-def-wf", map[string]string{AddonLabel: "listed", ChecksumLabel: "def"}, ""),
+		newWorkflow("listed-prereqs-abc-wf", nil, "listed"),
+		newWorkflow("listed-two-install-abc-wf", map[string]string{AddonLabel: "listed-two", ChecksumLabel: "abc"}, ""),
+	)
+
+	a := &v1alpha1.Addon{ObjectMeta: metav1.ObjectMeta{Name: "listed", Namespace: "default"}}
+	wfl := NewWorkflowLifecycle(fclient, dyn, a, rcdr, sch).(*workflowLifecycle)
+
+	items, err := wfl.addonWorkflows(ctx, "")
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(items).To(HaveLen(3))
+
+	items, err = wfl.addonWorkflows(ctx, "abc")
+	g.Expect(err).ToNot(HaveOccurred())
+	var names []string
+	for _, wf := range items {
+		names = append(names, wf.GetName())
+	}
+	g.Expect(names).To(ConsistOf("listed-install-abc-wf", "listed-prereqs-abc-wf"))
+}
+
+func TestWorkflowLifecycle_Faults(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	a := &v1alpha1.Addon{ObjectMeta: metav1.ObjectMeta{Name: "faulty", Namespace: "default"}}
+	a.Spec.Lifecycle.Install.Template = wfSpecTemplate
+	a.Status.Checksum = a.CalculateChecksum()
+	name := a.GetFormattedWorkflowName(v1alpha1.Install)
+
+	// Submissions fail
+	wfl := NewWorkflowLifecycle(fclient, dynClient, a, rcdr, sch, WithFaultInjector(faults.New(faults.Config{Submit: 1})))
+	phase, err := wfl.Install(ctx, &a.Spec.Lifecycle.Install, name)
+	g.Expect(errors.Is(err, faults.ErrInjected)).To(BeTrue())
+	g.Expect(phase).To(Equal(v1alpha1.Failed))
+
+	// Completed workflows are reported as running
+	wf := &unstructured.Unstructured{Object: map[string]interface{}{
+		"status": map[string]interface{}{"phase": "Succeeded", "startedAt": "2021-01-01T00:00:00Z"},
+	}}
+	wf.SetNamespace("default")
+	wf.SetName(name)
+	wf.SetLabels(map[string]string{AddonLabel: "faulty", ChecksumLabel: a.Status.Checksum})
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, Indexers)
+	g.Expect(indexer.Add(wf)).To(Succeed())
+
+	wfl = NewWorkflowLifecycle(fclient, dynClient, a, rcdr, sch, WithWorkflowCache(indexer, "default"), WithFaultInjector(faults.New(faults.Config{Flap: 1})))
+	phase, err = wfl.Install(ctx, &a.Spec.Lifecycle.Install, name)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(phase).To(Equal(v1alpha1.Pending))
+
+	wfl = NewWorkflowLifecycle(fclient, dynClient, a, rcdr, sch, WithWorkflowCache(indexer, "default"), WithFaultInjector(faults.New(faults.Config{})))
+	phase, err = wfl.Install(ctx, &a.Spec.Lifecycle.Install, name)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(phase).To(Equal(v1alpha1.Succeeded))
+}
+
+func TestWorkflowLifecycle_WorkflowNamespace(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	s := runtime.NewScheme()
+	gv := common.WorkflowGVR().GroupVersion()
+	s.AddKnownTypeWithName(gv.WithKind("Workflow"), &unstructured.Unstructured{})
+	s.AddKnownTypeWithName(gv.WithKind("WorkflowList"), &unstructured.UnstructuredList{})
+	dyn := dynfake.NewSimpleDynamicClient(s)
+	var selectors []string
+	dyn.PrependReactor("delete-collection", "workflows", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		selectors = append(selectors, action.(k8stesting.DeleteCollectionAction).GetListRestrictions().Labels.String())
+		return true, nil, nil
+	})
+
+	a := &v1alpha1.Addon{ObjectMeta: metav1.ObjectMeta{Name: "tenant-addon", Namespace: "tenant", UID: "1234"}}
+	wfl := NewWorkflowLifecycle(fclient, dyn, a, rcdr, sch, WithWorkflowNamespace("addon-manager-system")).(*workflowLifecycle)
+	g.Expect(wfl.crossNamespace()).To(BeTrue())
+
+	// Workflows of addons with the same name in other namespaces do not collide
+	name := QualifyName(a, "addon-manager-system", "tenant-addon-install-abc-wf")
+	g.Expect(name).To(HavePrefix("tenant-addon-install-abc-wf-"))
+	other := &v1alpha1.Addon{ObjectMeta: metav1.ObjectMeta{Name: "tenant-addon", Namespace: "other"}}
+	g.Expect(QualifyName(other, "addon-manager-system", "tenant-addon-install-abc-wf")).ToNot(Equal(name))
+	g.Expect(QualifyName(a, "", "tenant-addon-install-abc-wf")).To(Equal("tenant-addon-install-abc-wf"))
+	g.Expect(QualifyName(a, "tenant", "tenant-addon-install-abc-wf")).To(Equal("tenant-addon-install-abc-wf"))
+
+	// Owner references can not point across namespaces, the workflow is labelled with its addon instead
+	wf := &unstructured.Unstructured{}
+	wf.SetGroupVersionKind(gv.WithKind("Workflow"))
+	wf.SetNamespace("addon-manager-system")
+	wf.SetName(name)
+	g.Expect(wfl.setOwner(wf)).To(Succeed())
+	wfl.labelWorkflow(wf)
+	g.Expect(wf.GetOwnerReferences()).To(BeEmpty())
+	g.Expect(wf.GetLabels()).To(HaveKeyWithValue(AddonLabel, "tenant-addon"))
+	g.Expect(wf.GetLabels()).To(HaveKeyWithValue(AddonNamespaceLabel, "tenant"))
+
+	addon, ok := AddonOf(wf)
+	g.Expect(ok).To(BeTrue())
+	g.Expect(addon).To(Equal(types.NamespacedName{Namespace: "tenant", Name: "tenant-addon"}))
+	keys, err := addonIndexFunc(wf)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(keys).To(Equal([]string{"tenant/tenant-addon"}))
+
+	_, err = dyn.Resource(common.WorkflowGVR()).Namespace("addon-manager-system").Create(ctx, wf, metav1.CreateOptions{})
+	g.Expect(err).ToNot(HaveOccurred())
+	items, err := wfl.addonWorkflows(ctx, "")
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(items).To(HaveLen(1))
+	// The workflows of the addon with the same name in another namespace are not listed
+	items, err = NewWorkflowLifecycle(fclient, dyn, other, rcdr, sch, WithWorkflowNamespace("addon-manager-system")).(*workflowLifecycle).addonWorkflows(ctx, "")
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(items).To(BeEmpty())
+
+	g.Expect(wfl.Delete(ctx, "tenant-addon-install-abc-wf")).To(Succeed())
+	_, err = dyn.Resource(common.WorkflowGVR()).Namespace("addon-manager-system").Get(ctx, name, metav1.GetOptions{})
+	g.Expect(apierrors.IsNotFound(err)).To(BeTrue())
+
+	// The finalizer deletes the labelled workflows, nothing is deleted for workflows in the addon namespace
+	g.Expect(DeleteAddonWorkflows(ctx, dyn, a, "addon-manager-system")).To(Succeed())
+	g.Expect(DeleteAddonWorkflows(ctx, dyn, a, "")).To(Succeed())
+	g.Expect(selectors).To(Equal([]string{"addonmgr.keikoproj.io/addon=tenant-addon,addonmgr.keikoproj.io/addon-namespace=tenant"}))
+}
+
+func TestWorkflowLifecycle_PropagationPolicy(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	_, err := ParsePropagationPolicy("team,[", "")
+	g.Expect(err).To(HaveOccurred())
+	policy, err := ParsePropagationPolicy("team, cost-center", "compliance.example.com/*")
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(policy.Empty()).To(BeFalse())
+	g.Expect(policy.Labels).To(Equal([]string{"team", "cost-center"}))
+
+	a := &v1alpha1.Addon{ObjectMeta: metav1.ObjectMeta{
+		Name:        "tagged-addon",
+		Namespace:   "default",
+		Labels:      map[string]string{"team": "platform", "cost-center": "cc-42", "env": "prod", "app.kubernetes.io/name": "other"},
+		Annotations: map[string]string{"compliance.example.com/pci": "true", "note": "internal"},
+	}}
+	policy.Labels = append(policy.Labels, "app.kubernetes.io/*")
+	wfl := NewWorkflowLifecycle(fclient, dynClient, a, rcdr, sch, WithPropagationPolicy(policy)).(*workflowLifecycle)
+
+	wf := &unstructured.Unstructured{}
+	wt := &v1alpha1.WorkflowType{Template: wfSpecTemplate}
+	g.Expect(wfl.parse(wt, wf, "install")).To(Succeed())
+	g.Expect(wfl.injectPropagatedMetadata(wf)).To(Succeed())
+	podLabels, _, _ := unstructured.NestedStringMap(wf.Object, "spec", "podMetadata", "labels")
+	g.Expect(podLabels).To(HaveKeyWithValue("team", "platform"))
+	g.Expect(podLabels).ToNot(HaveKey("env"))
+	podAnnotations, _, _ := unstructured.NestedStringMap(wf.Object, "spec", "podMetadata", "annotations")
+	g.Expect(podAnnotations).To(Equal(map[string]string{"compliance.example.com/pci": "true"}))
+
+	// Addon values take precedence over the artifact values, the labels of the manager over both
+	deployment := &unstructured.Unstructured{}
+	_, err = wfl.processArtifact(`
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: event-router
+  labels:
+    team: unknown
+`, deployment, wt)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(deployment.GetLabels()).To(HaveKeyWithValue("team", "platform"))
+	g.Expect(deployment.GetLabels()).To(HaveKeyWithValue("cost-center", "cc-42"))
+	g.Expect(deployment.GetLabels()).To(HaveKeyWithValue("app.kubernetes.io/name", "tagged-addon"))
+	g.Expect(deployment.GetLabels()).ToNot(HaveKey("env"))
+	g.Expect(deployment.GetAnnotations()).To(Equal(map[string]string{"compliance.example.com/pci": "true"}))
+
+	// Nothing is propagated without a policy
+	wfl = NewWorkflowLifecycle(fclient, dynClient, a, rcdr, sch).(*workflowLifecycle)
+	configMap := &unstructured.Unstructured{}
+	_, err = wfl.processArtifact("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: cm\n", configMap, wt)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(configMap.GetLabels()).ToNot(HaveKey("team"))
+	g.Expect(configMap.GetAnnotations()).To(BeEmpty())
+}
+
+func TestWorkflowLifecycle_ContextEnv(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	a := &v1alpha1.Addon{ObjectMeta: metav1.ObjectMeta{Name: "context-addon", Namespace: "default"}}
+	a.Spec.Params.Context = v1alpha1.ClusterContext{
+		ClusterName:       "prod-1",
+		ClusterRegion:     "us-west-2",
+		AdditionalConfigs: map[string]v1alpha1.FlexString{"vpc-id": "vpc-1234", "ACCOUNT": "1234"},
+	}
+	wfl := NewWorkflowLifecycle(fclient, dynClient, a, rcdr, sch).(*workflowLifecycle)
+
+	wf := &unstructured.Unstructured{Object: map[string]interface{}{}}
+	g.Expect(unstructured.SetNestedSlice(wf.Object, []interface{}{
+		map[string]interface{}{"name": "install", "container": map[string]interface{}{
+			"image": "alpine:3.12",
+			"env":   []interface{}{map[string]interface{}{"name": "CLUSTER_NAME", "value": "override"}},
+		}},
+		map[string]interface{}{"name": "script", "script": map[string]interface{}{"image": "alpine:3.12"}},
+		map[string]interface{}{"name": "steps", "steps": []interface{}{}},
+	}, "spec", "templates")).To(Succeed())
+	g.Expect(wfl.injectContextEnv(wf)).To(Succeed())
+
+	templates, _, _ := unstructured.NestedSlice(wf.Object, "spec", "templates")
+	env, _, _ := unstructured.NestedSlice(templates[0].(map[string]interface{}), "container", "env")
+	g.Expect(env).To(Equal([]interface{}{
+		map[string]interface{}{"name": "CLUSTER_NAME", "value": "override"},
+		map[string]interface{}{"name": ClusterRegionEnv, "value": "us-west-2"},
+		map[string]interface{}{"name": "ACCOUNT", "value": "1234"},
+		map[string]interface{}{"name": "vpc_id", "value": "vpc-1234"},
+	}))
+	env, _, _ = unstructured.NestedSlice(templates[1].(map[string]interface{}), "script", "env")
+	g.Expect(env).To(HaveLen(4))
+	g.Expect(env[0]).To(HaveKeyWithValue("value", "prod-1"))
+	g.Expect(templates[2]).ToNot(HaveKey("container"))
+
+	// Nothing is injected without a cluster context
+	wfl = NewWorkflowLifecycle(fclient, dynClient, &v1alpha1.Addon{}, rcdr, sch).(*workflowLifecycle)
+	wf = &unstructured.Unstructured{Object: map[string]interface{}{}}
+	g.Expect(unstructured.SetNestedSlice(wf.Object, []interface{}{
+		map[string]interface{}{"name": "install", "container": map[string]interface{}{"image": "alpine:3.12"}},
+	}, "spec", "templates")).To(Succeed())
+	g.Expect(wfl.injectContextEnv(wf)).To(Succeed())
+	templates, _, _ = unstructured.NestedSlice(wf.Object, "spec", "templates")
+	g.Expect(templates[0].(map[string]interface{})["container"]).ToNot(HaveKey("env"))
+}
+
+func TestWorkflowLifecycle_LogArchive(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	archive, err := ParseLogArchive(true, "artifact-repositories/s3-logs")
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(archive).To(Equal(LogArchive{Enabled: true, ConfigMap: "artifact-repositories", Key: "s3-logs"}))
+	_, err = ParseLogArchive(true, "artifact-repositories/")
+	g.Expect(err).To(HaveOccurred())
+
+	a := &v1alpha1.Addon{ObjectMeta: metav1.ObjectMeta{Name: "archive-addon", Namespace: "default"}}
+	wfl := NewWorkflowLifecycle(fclient, dynClient, a, rcdr, sch, WithLogArchive(archive)).(*workflowLifecycle)
+	wf := &unstructured.Unstructured{Object: map[string]interface{}{"spec": map[string]interface{}{}}}
+	g.Expect(wfl.injectArchiveLogs(wf)).To(Succeed())
+	g.Expect(wf.Object["spec"]).To(Equal(map[string]interface{}{
+		"archiveLogs":           true,
+		"artifactRepositoryRef": map[string]interface{}{"configMap": "artifact-repositories", "key": "s3-logs"},
+	}))
+
+	// Addons opt out of the archive
+	disabled := false
+	a.Spec.Lifecycle.ArchiveLogs = &disabled
+	wf = &unstructured.Unstructured{Object: map[string]interface{}{"spec": map[string]interface{}{}}}
+	g.Expect(wfl.injectArchiveLogs(wf)).To(Succeed())
+	g.Expect(wf.Object["spec"]).To(BeEmpty())
+
+	// Addons opt in to the default artifact repository
+	enabled := true
+	a.Spec.Lifecycle.ArchiveLogs = &enabled
+	wfl = NewWorkflowLifecycle(fclient, dynClient, a, rcdr, sch).(*workflowLifecycle)
+	wf = &unstructured.Unstructured{Object: map[string]interface{}{"spec": map[string]interface{}{}}}
+	g.Expect(wfl.injectArchiveLogs(wf)).To(Succeed())
+	g.Expect(wf.Object["spec"]).To(Equal(map[string]interface{}{"archiveLogs": true}))
+}
+
+func TestWorkflowLifecycle_CommonParams(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	ref, err := ParseCommonParams("addon-manager-system/addon-manager-common-params")
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(ref).To(Equal(types.NamespacedName{Namespace: "addon-manager-system", Name: "addon-manager-common-params"}))
+	_, err = ParseCommonParams("addon-manager-common-params")
+	g.Expect(err).To(HaveOccurred())
+
+	a := &v1alpha1.Addon{ObjectMeta: metav1.ObjectMeta{Name: "common-addon", Namespace: "default"}}
+	a.Spec.Params.Namespace = "common-ns"
+	a.Spec.Params.Data = map[string]v1alpha1.FlexString{"dnsZone": "team.example.com"}
+	common := map[string]string{"vpcId": "vpc-1234", "dnsZone": "example.com", "namespace": "ignored", "env": "prod"}
+	wfl := NewWorkflowLifecycle(fclient, dynClient, a, rcdr, sch, WithCommonParams(common)).(*workflowLifecycle)
+
+	wf := &unstructured.Unstructured{Object: map[string]interface{}{"spec": map[string]interface{}{
+		"arguments": map[string]interface{}{"parameters": []interface{}{map[string]interface{}{"name": "env", "value": "staging"}}},
+	}}}
+	g.Expect(wfl.configureGlobalWFParameters(a, wf)).To(BeTrue())
+	params, _, _ := unstructured.NestedSlice(wf.Object, "spec", "arguments", "parameters")
+	g.Expect(params).To(ContainElement(map[string]interface{}{"name": "vpcId", "value": "vpc-1234"}))
+
+	// Workflow and addon parameters take precedence
+	values := map[string][]interface{}{}
+	for _, p := range params {
+		p := p.(map[string]interface{})
+		values[p["name"].(string)] = append(values[p["name"].(string)], p["value"])
+	}
+	g.Expect(values["dnsZone"]).To(Equal([]interface{}{"team.example.com"}))
+	g.Expect(values["namespace"]).To(Equal([]interface{}{"common-ns"}))
+	g.Expect(values["env"]).To(Equal([]interface{}{"staging"}))
+}
+
+func TestRevisionAddon(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	a := &v1alpha1.Addon{ObjectMeta: metav1.ObjectMeta{Name: "rollback-addon", Namespace: "default", UID: "1234"}}
+	a.Spec.PkgName = "rollback-addon"
+	a.Spec.PkgVersion = "1.1.0"
+	a.Spec.Lifecycle.Install.Template = wfSpecTemplate
+	a.Status.Checksum = a.CalculateStatusChecksum()
+
+	spec := a.Spec.DeepCopy()
+	spec.PkgVersion = "1.0.0"
+	prev := RevisionAddon(a, spec)
+	g.Expect(prev.UID).To(Equal(a.UID))
+	g.Expect(prev.Spec.PkgVersion).To(Equal("1.0.0"))
+	g.Expect(prev.Status.Checksum).ToNot(Equal(a.Status.Checksum))
+	g.Expect(a.Spec.PkgVersion).To(Equal("1.1.0"))
+
+	// The workflow of the revision is rendered with the parameters of the revision
+	wf, err := RenderWorkflow(prev, v1alpha1.Install)
+	g.Expect(err).ToNot(HaveOccurred())
+	params, _, _ := unstructured.NestedSlice(wf.Object, "spec", "arguments", "parameters")
+	g.Expect(params).To(ContainElement(map[string]interface{}{"name": "pkgVersion", "value": "1.0.0"}))
+
+	g.Expect(RollbackWorkflowName(a, a.Status.Checksum)).To(Equal("rollback-addon-rollback-" + a.Status.Checksum + "-wf"))
+}
+
+func TestWorkflowLifecycle_OwnerMode(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	mode, err := ParseOwnerMode("")
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(mode).To(Equal(ControllerOwner))
+	_, err = ParseOwnerMode("orphan")
+	g.Expect(err).To(HaveOccurred())
+
+	a := &v1alpha1.Addon{ObjectMeta: metav1.ObjectMeta{Name: "owned-addon", Namespace: "default", UID: "1234"}}
+	for _, tc := range []struct {
+		mode       OwnerMode
+		controller bool
+	}{
+		{ControllerOwner, true},
+		{LooseOwner, false},
+	} {
+		wfl := NewWorkflowLifecycle(fclient, dynClient, a, rcdr, sch, WithOwnerMode(tc.mode)).(*workflowLifecycle)
+		wf := &unstructured.Unstructured{Object: map[string]interface{}{}}
+		wf.SetNamespace("default")
+		wf.SetName("owned-addon-install-abc-wf")
+		g.Expect(wfl.setOwner(wf)).To(Succeed())
+
+		refs := wf.GetOwnerReferences()
+		g.Expect(refs).To(HaveLen(1))
+		g.Expect(refs[0].Name).To(Equal("owned-addon"))
+		g.Expect(metav1.GetControllerOf(wf) != nil).To(Equal(tc.controller), string(tc.mode))
+	}
+}